@@ -961,6 +961,12 @@ func (f *Fs) Precision() time.Duration {
 	return time.Nanosecond
 }
 
+// MaxObjectSize returns the size of the largest blob which can be
+// uploaded in blocks
+func (f *Fs) MaxObjectSize(ctx context.Context) int64 {
+	return maxTotalParts * int64(maxChunkSize)
+}
+
 // Hashes returns the supported hash sets.
 func (f *Fs) Hashes() hash.Set {
 	return hash.Set(hash.MD5)
@@ -1573,13 +1579,14 @@ func (o *Object) GetTier() string {
 
 // Check the interfaces are satisfied
 var (
-	_ fs.Fs          = &Fs{}
-	_ fs.Copier      = &Fs{}
-	_ fs.PutStreamer = &Fs{}
-	_ fs.Purger      = &Fs{}
-	_ fs.ListRer     = &Fs{}
-	_ fs.Object      = &Object{}
-	_ fs.MimeTyper   = &Object{}
-	_ fs.GetTierer   = &Object{}
-	_ fs.SetTierer   = &Object{}
+	_ fs.Fs             = &Fs{}
+	_ fs.Copier         = &Fs{}
+	_ fs.PutStreamer    = &Fs{}
+	_ fs.Purger         = &Fs{}
+	_ fs.ListRer        = &Fs{}
+	_ fs.MaxObjectSizer = &Fs{}
+	_ fs.Object         = &Object{}
+	_ fs.MimeTyper      = &Object{}
+	_ fs.GetTierer      = &Object{}
+	_ fs.SetTierer      = &Object{}
 )
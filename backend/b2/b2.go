@@ -63,6 +63,7 @@ const (
 // Globals
 var (
 	errNotWithVersions = errors.New("can't modify or delete files in --b2-versions mode")
+	errNotAtTime       = errors.New("can't modify or delete files in --at-time mode")
 )
 
 // Register with Fs
@@ -214,6 +215,8 @@ type Fs struct {
 	name            string                                 // name of this remote
 	root            string                                 // the path we are working on if any
 	opt             Options                                // parsed config options
+	m               configmap.Mapper                       // config the Fs was made with
+	atTime          time.Time                              // show the versions current at this time if set
 	features        *fs.Features                           // optional features
 	srv             *rest.Client                           // the connection to the b2 server
 	rootBucket      string                                 // bucket part of root (if any)
@@ -419,6 +422,7 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 	f := &Fs{
 		name:        name,
 		opt:         *opt,
+		m:           m,
 		srv:         rest.NewClient(fshttp.NewClient(fs.Config)).SetErrorHandler(errorHandler),
 		cache:       bucket.NewCache(),
 		_bucketID:   make(map[string]string, 1),
@@ -735,6 +739,9 @@ func (f *Fs) itemToDirEntry(ctx context.Context, remote string, object *api.File
 		d := fs.NewDir(remote, time.Time{})
 		return d, nil
 	}
+	if !f.atTime.IsZero() {
+		return f.itemAtTime(ctx, remote, object, last)
+	}
 	if remote == *last {
 		remote = object.UploadTimestamp.AddVersion(remote)
 	} else {
@@ -751,10 +758,54 @@ func (f *Fs) itemToDirEntry(ctx context.Context, remote string, object *api.File
 	return o, nil
 }
 
+// listVersions returns whether the listings need all the versions
+// of the files rather than just the latest
+func (f *Fs) listVersions() bool {
+	return f.opt.Versions || !f.atTime.IsZero()
+}
+
+// isAtTime checks whether object, which is a version of a file, was
+// current at f.atTime.  Versions are listed newest first so this is
+// the first version uploaded at or before f.atTime which isn't an
+// unfinished large file.  If it is a hide marker then deleted is set
+// as the file didn't exist then.
+func (f *Fs) isAtTime(object *api.File) (current bool, deleted bool) {
+	if time.Time(object.UploadTimestamp).After(f.atTime) || object.Action == "start" {
+		return false, false
+	}
+	return true, object.Action == "hide"
+}
+
+// itemAtTime converts a list item from the versions listing into a
+// DirEntry if it is the version current at f.atTime, otherwise
+// returning nil.
+//
+// last should hold the last file name a version was chosen for.
+func (f *Fs) itemAtTime(ctx context.Context, remote string, object *api.File, last *string) (fs.DirEntry, error) {
+	if remote == *last {
+		return nil, nil
+	}
+	current, deleted := f.isAtTime(object)
+	if !current {
+		return nil, nil
+	}
+	*last = remote
+	if deleted {
+		fs.Debugf(remote, "Not listing as it was deleted at %v for --at-time", time.Time(object.UploadTimestamp))
+		return nil, nil
+	}
+	o, err := f.newObjectWithInfo(ctx, remote, object)
+	if err != nil {
+		return nil, err
+	}
+	fs.Debugf(o, "Using version %q uploaded at %v for --at-time", object.ID, time.Time(object.UploadTimestamp))
+	return o, nil
+}
+
 // listDir lists a single directory
 func (f *Fs) listDir(ctx context.Context, bucket, directory, prefix string, addBucket bool) (entries fs.DirEntries, err error) {
 	last := ""
-	err = f.list(ctx, bucket, directory, prefix, f.rootBucket == "", false, 0, f.opt.Versions, false, func(remote string, object *api.File, isDirectory bool) error {
+	err = f.list(ctx, bucket, directory, prefix, f.rootBucket == "", false, 0, f.listVersions(), false, func(remote string, object *api.File, isDirectory bool) error {
 		entry, err := f.itemToDirEntry(ctx, remote, object, isDirectory, &last)
 		if err != nil {
 			return err
@@ -826,7 +877,7 @@ func (f *Fs) ListR(ctx context.Context, dir string, callback fs.ListRCallback) (
 	list := walk.NewListRHelper(callback)
 	listR := func(bucket, directory, prefix string, addBucket bool) error {
 		last := ""
-		return f.list(ctx, bucket, directory, prefix, addBucket, true, 0, f.opt.Versions, false, func(remote string, object *api.File, isDirectory bool) error {
+		return f.list(ctx, bucket, directory, prefix, addBucket, true, 0, f.listVersions(), false, func(remote string, object *api.File, isDirectory bool) error {
 			entry, err := f.itemToDirEntry(ctx, remote, object, isDirectory, &last)
 			if err != nil {
 				return err
@@ -1356,6 +1407,20 @@ func (f *Fs) getDownloadAuthorization(ctx context.Context, bucket, remote string
 	return response.AuthorizationToken, nil
 }
 
+// AtTime returns a read only Fs showing the files as they were at t
+func (f *Fs) AtTime(ctx context.Context, t time.Time) (fs.Fs, error) {
+	if f.opt.Versions {
+		return nil, errors.New("can't use --at-time with --b2-versions")
+	}
+	newFs, err := NewFs(f.name, f.root, f.m)
+	if err != nil {
+		return nil, err
+	}
+	newF := newFs.(*Fs)
+	newF.atTime = t
+	return newF, nil
+}
+
 // PublicLink returns a link for downloading without account
 func (f *Fs) PublicLink(ctx context.Context, remote string, expire fs.Duration, unlink bool) (link string, err error) {
 	bucket, bucketPath := f.split(remote)
@@ -1501,9 +1566,11 @@ func (o *Object) getMetaData(ctx context.Context) (info *api.File, err error) {
 	if o.fs.opt.Versions {
 		timestamp, bucketPath = api.RemoveVersion(bucketPath)
 		maxSearched = maxVersions
+	} else if !o.fs.atTime.IsZero() {
+		maxSearched = maxVersions
 	}
 
-	err = o.fs.list(ctx, bucket, bucketPath, "", false, true, maxSearched, o.fs.opt.Versions, true, func(remote string, object *api.File, isDirectory bool) error {
+	err = o.fs.list(ctx, bucket, bucketPath, "", false, true, maxSearched, o.fs.listVersions(), true, func(remote string, object *api.File, isDirectory bool) error {
 		if isDirectory {
 			return nil
 		}
@@ -1511,6 +1578,16 @@ func (o *Object) getMetaData(ctx context.Context) (info *api.File, err error) {
 			if !timestamp.IsZero() && !timestamp.Equal(object.UploadTimestamp) {
 				return nil
 			}
+			if !o.fs.atTime.IsZero() {
+				current, deleted := o.fs.isAtTime(object)
+				if !current {
+					return nil
+				}
+				if deleted {
+					return errEndList
+				}
+				fs.Debugf(o, "Using version %q uploaded at %v for --at-time", object.ID, time.Time(object.UploadTimestamp))
+			}
 			info = object
 		}
 		return errEndList // read only 1 item
@@ -1755,6 +1832,9 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	if o.fs.opt.Versions {
 		return errNotWithVersions
 	}
+	if !o.fs.atTime.IsZero() {
+		return errNotAtTime
+	}
 	size := src.Size()
 
 	bucket, bucketPath := o.split()
@@ -1910,6 +1990,9 @@ func (o *Object) Remove(ctx context.Context) error {
 	if o.fs.opt.Versions {
 		return errNotWithVersions
 	}
+	if !o.fs.atTime.IsZero() {
+		return errNotAtTime
+	}
 	if o.fs.opt.HardDelete {
 		return o.fs.deleteByID(ctx, o.id, bucketPath)
 	}
@@ -1935,6 +2018,7 @@ var (
 	_ fs.CleanUpper   = &Fs{}
 	_ fs.ListRer      = &Fs{}
 	_ fs.PublicLinker = &Fs{}
+	_ fs.AtTimer      = &Fs{}
 	_ fs.Object       = &Object{}
 	_ fs.MimeTyper    = &Object{}
 	_ fs.IDer         = &Object{}
@@ -1,10 +1,15 @@
 package b2
 
 import (
+	"context"
 	"testing"
 	"time"
 
+	"github.com/rclone/rclone/backend/b2/api"
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // Test b2 string encoding
@@ -168,3 +173,38 @@ func TestParseTimeString(t *testing.T) {
 	}
 
 }
+
+func TestItemAtTime(t *testing.T) {
+	ctx := context.Background()
+	f := &Fs{atTime: fstest.Time("2001-02-03T00:00:00Z")}
+	version := func(name, id, action, uploaded string) *api.File {
+		return &api.File{
+			ID:              id,
+			Name:            name,
+			Action:          action,
+			UploadTimestamp: api.Timestamp(fstest.Time(uploaded)),
+		}
+	}
+	// Versions are listed newest first
+	listing := []*api.File{
+		version("deleted", "d2", "upload", "2001-02-04T00:00:00Z"),
+		version("deleted", "d1", "hide", "2001-02-02T00:00:00Z"),
+		version("deleted", "d0", "upload", "2001-02-01T00:00:00Z"),
+		version("file", "f3", "upload", "2001-02-05T00:00:00Z"),
+		version("file", "f2", "start", "2001-02-02T12:00:00Z"),
+		version("file", "f1", "upload", "2001-02-02T00:00:00Z"),
+		version("file", "f0", "upload", "2001-02-01T00:00:00Z"),
+		version("new", "n0", "upload", "2001-02-04T00:00:00Z"),
+		version("old", "o0", "upload", "2001-01-01T00:00:00Z"),
+	}
+	last := ""
+	var got []string
+	for _, object := range listing {
+		entry, err := f.itemToDirEntry(ctx, object.Name, object, false, &last)
+		require.NoError(t, err)
+		if entry != nil {
+			got = append(got, entry.Remote()+"="+entry.(fs.IDer).ID())
+		}
+	}
+	assert.Equal(t, []string{"file=f1", "old=o0"}, got)
+}
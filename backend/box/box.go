@@ -23,6 +23,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rclone/rclone/lib/encoder"
@@ -260,6 +261,9 @@ type Fs struct {
 	pacer        *fs.Pacer             // pacer for API calls
 	tokenRenewer *oauthutil.Renew      // renew the token on expiry
 	uploadToken  *pacer.TokenDispenser // control concurrency
+
+	maxUploadSizeOnce *sync.Once // read the max upload size once
+	maxUploadSize     int64      // the largest file the user can upload or -1 if unknown
 }
 
 // Object describes a box object
@@ -397,6 +401,8 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 		srv:         rest.NewClient(oAuthClient).SetRoot(rootURL),
 		pacer:       fs.NewPacer(pacer.NewDefault(pacer.MinSleep(minSleep), pacer.MaxSleep(maxSleep), pacer.DecayConstant(decayConstant))),
 		uploadToken: pacer.NewTokenDispenser(fs.Config.Transfers),
+
+		maxUploadSizeOnce: new(sync.Once),
 	}
 	f.features = (&fs.Features{
 		CaseInsensitive:         true,
@@ -532,10 +538,10 @@ func (f *Fs) CreateDir(ctx context.Context, pathID, leaf string) (newID string,
 			ID: pathID,
 		},
 	}
-	err = f.pacer.Call(func() (bool, error) {
+	err = f.pacer.CallNonIdempotent(func() (bool, error) {
 		resp, err = f.srv.CallJSON(ctx, &opts, &mkdir, &info)
 		return shouldRetry(resp, err)
-	})
+	}, func(error) bool { return fserrors.IsServerError(resp) })
 	if err != nil {
 		//fmt.Printf("...Error %v\n", err)
 		return "", err
@@ -823,10 +829,10 @@ func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object,
 	}
 	var resp *http.Response
 	var info *api.Item
-	err = f.pacer.Call(func() (bool, error) {
+	err = f.pacer.CallNonIdempotent(func() (bool, error) {
 		resp, err = f.srv.CallJSON(ctx, &opts, &copyFile, &info)
 		return shouldRetry(resp, err)
-	})
+	}, func(error) bool { return fserrors.IsServerError(resp) })
 	if err != nil {
 		return nil, err
 	}
@@ -871,13 +877,12 @@ func (f *Fs) move(ctx context.Context, endpoint, id, leaf, directoryID string) (
 	return info, nil
 }
 
-// About gets quota information
-func (f *Fs) About(ctx context.Context) (usage *fs.Usage, err error) {
+// readUser reads the info about the user
+func (f *Fs) readUser(ctx context.Context) (user *api.User, err error) {
 	opts := rest.Opts{
 		Method: "GET",
 		Path:   "/users/me",
 	}
-	var user api.User
 	var resp *http.Response
 	err = f.pacer.Call(func() (bool, error) {
 		resp, err = f.srv.CallJSON(ctx, &opts, nil, &user)
@@ -886,7 +891,15 @@ func (f *Fs) About(ctx context.Context) (usage *fs.Usage, err error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to read user info")
 	}
-	// FIXME max upload size would be useful to use in Update
+	return user, nil
+}
+
+// About gets quota information
+func (f *Fs) About(ctx context.Context) (usage *fs.Usage, err error) {
+	user, err := f.readUser(ctx)
+	if err != nil {
+		return nil, err
+	}
 	usage = &fs.Usage{
 		Used:  fs.NewUsageValue(user.SpaceUsed),                    // bytes in use
 		Total: fs.NewUsageValue(user.SpaceAmount),                  // bytes total
@@ -895,6 +908,24 @@ func (f *Fs) About(ctx context.Context) (usage *fs.Usage, err error) {
 	return usage, nil
 }
 
+// MaxObjectSize returns the largest file the user can upload, read
+// from the user info the first time it is called, or -1 if it can't
+// be read.
+func (f *Fs) MaxObjectSize(ctx context.Context) int64 {
+	f.maxUploadSizeOnce.Do(func() {
+		f.maxUploadSize = -1
+		user, err := f.readUser(ctx)
+		if err != nil {
+			fs.Debugf(f, "Couldn't read the max upload size: %v", err)
+			return
+		}
+		if user.MaxUploadSize > 0 {
+			f.maxUploadSize = user.MaxUploadSize
+		}
+	})
+	return f.maxUploadSize
+}
+
 // Move src to this remote using server side move operations.
 //
 // This is stored with the remote path given
@@ -1239,10 +1270,10 @@ func (o *Object) upload(ctx context.Context, in io.Reader, leaf, directoryID str
 	} else {
 		opts.Path = "/files/content"
 	}
-	err = o.fs.pacer.CallNoRetry(func() (bool, error) {
+	err = o.fs.pacer.CallNoRetry(fs.NonIdempotent(func() (bool, error) {
 		resp, err = o.fs.srv.CallJSON(ctx, &opts, &upload, &result)
 		return shouldRetry(resp, err)
-	})
+	}, func(error) bool { return fserrors.IsServerError(resp) }))
 	if err != nil {
 		return err
 	}
@@ -1297,6 +1328,7 @@ var (
 	_ fs.PutStreamer     = (*Fs)(nil)
 	_ fs.Copier          = (*Fs)(nil)
 	_ fs.Abouter         = (*Fs)(nil)
+	_ fs.MaxObjectSizer  = (*Fs)(nil)
 	_ fs.Mover           = (*Fs)(nil)
 	_ fs.DirMover        = (*Fs)(nil)
 	_ fs.DirCacheFlusher = (*Fs)(nil)
@@ -43,6 +43,8 @@ func TestIntegration(t *testing.T) {
 			"DirCacheFlush",
 			"UserInfo",
 			"Disconnect",
+			"AtTime",
+			"MaxObjectSize",
 		},
 	}
 	if *fstest.RemoteName == "" {
@@ -5,8 +5,11 @@ import (
 	"context"
 	"crypto/aes"
 	gocipher "crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base32"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"strconv"
@@ -18,6 +21,7 @@ import (
 	"github.com/rclone/rclone/backend/crypt/pkcs7"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/lib/cpulimit"
 	"github.com/rfjakob/eme"
 	"golang.org/x/crypto/nacl/secretbox"
 	"golang.org/x/crypto/scrypt"
@@ -28,6 +32,9 @@ const (
 	nameCipherBlockSize = aes.BlockSize
 	fileMagic           = "RCLONE\x00\x00"
 	fileMagicSize       = len(fileMagic)
+	fileMagicRotate     = "RCLONE\x00" // followed by keyRotationFlag | log2 of the blocks per key epoch
+	keyRotationFlag     = 0x80
+	maxKeyEpochShift    = 40
 	fileNonceSize       = 24
 	fileHeaderSize      = fileMagicSize + fileNonceSize
 	blockHeaderSize     = secretbox.Overhead
@@ -46,6 +53,7 @@ var (
 	ErrorEncryptedFileTooShort   = errors.New("file is too short to be encrypted")
 	ErrorEncryptedFileBadHeader  = errors.New("file has truncated block header")
 	ErrorEncryptedBadMagic       = errors.New("not an encrypted file - bad magic string")
+	ErrorEncryptedBadKeyEpoch    = errors.New("encrypted file has bad key rotation size")
 	ErrorEncryptedBadBlock       = errors.New("failed to authenticate decrypted block - bad password?")
 	ErrorBadBase32Encoding       = errors.New("bad base32 filename encoding")
 	ErrorFileClosed              = errors.New("file already closed")
@@ -122,6 +130,8 @@ type Cipher struct {
 	buffers        sync.Pool // encrypt/decrypt buffers
 	cryptoRand     io.Reader // read crypto random numbers from here
 	dirNameEncrypt bool
+	keyRotation    bool // set to rotate the data key in new files
+	keyEpochShift  uint // log2 of the blocks encrypted with each key
 }
 
 // newCipher initialises the cipher.  If salt is "" then it uses a built in salt val
@@ -172,6 +182,17 @@ func (c *Cipher) Key(password, salt string) (err error) {
 	return err
 }
 
+// setKeyRotation sets new files to use a new data key for every
+// rotation bytes of data, rounded up to a power of 2 number of
+// blocks. 0 means don't rotate the key.
+func (c *Cipher) setKeyRotation(rotation int64) {
+	c.keyRotation = rotation > 0
+	c.keyEpochShift = 0
+	for c.keyEpochShift < maxKeyEpochShift && int64(blockDataSize)<<c.keyEpochShift < rotation {
+		c.keyEpochShift++
+	}
+}
+
 // getBlock gets a block from the pool of size blockSize
 func (c *Cipher) getBlock() []byte {
 	return c.buffers.Get().([]byte)
@@ -575,12 +596,49 @@ func (n *nonce) add(x uint64) {
 	}
 }
 
+// keyEpochs finds the data key for each block of a file.
+//
+// Files with key rotation split the blocks into epochs of
+// 1<<shift blocks. The key for each epoch is the HMAC-SHA256 of the
+// file nonce and epoch number keyed with the data key, so knowing the
+// key of one epoch doesn't give away the keys of the others.
+type keyEpochs struct {
+	c       *Cipher
+	rotate  bool     // set if the file rotates the key
+	shift   uint     // log2 of the blocks in each epoch
+	nonce   nonce    // the initial nonce of the file
+	epoch   uint64   // the epoch current is the key for
+	valid   bool     // set if current is valid
+	current [32]byte // the key for epoch
+}
+
+// key returns the key for the block with index block
+func (k *keyEpochs) key(block uint64) *[32]byte {
+	if !k.rotate {
+		return &k.c.dataKey
+	}
+	epoch := block >> k.shift
+	if !k.valid || epoch != k.epoch {
+		mac := hmac.New(sha256.New, k.c.dataKey[:])
+		_, _ = mac.Write(k.nonce[:])
+		var epochBytes [8]byte
+		binary.BigEndian.PutUint64(epochBytes[:], epoch)
+		_, _ = mac.Write(epochBytes[:])
+		copy(k.current[:], mac.Sum(nil))
+		k.epoch = epoch
+		k.valid = true
+	}
+	return &k.current
+}
+
 // encrypter encrypts an io.Reader on the fly
 type encrypter struct {
 	mu       sync.Mutex
 	in       io.Reader
 	c        *Cipher
 	nonce    nonce
+	keys     keyEpochs
+	block    uint64 // index of the next block
 	buf      []byte
 	readBuf  []byte
 	bufIndex int
@@ -606,8 +664,14 @@ func (c *Cipher) newEncrypter(in io.Reader, nonce *nonce) (*encrypter, error) {
 			return nil, err
 		}
 	}
+	fh.keys = keyEpochs{c: c, rotate: c.keyRotation, shift: c.keyEpochShift, nonce: fh.nonce}
 	// Copy magic into buffer
-	copy(fh.buf, fileMagicBytes)
+	if c.keyRotation {
+		copy(fh.buf, fileMagicRotate)
+		fh.buf[fileMagicSize-1] = keyRotationFlag | byte(c.keyEpochShift)
+	} else {
+		copy(fh.buf, fileMagicBytes)
+	}
 	// Copy nonce into buffer
 	copy(fh.buf[fileMagicSize:], fh.nonce[:])
 	return fh, nil
@@ -637,10 +701,14 @@ func (fh *encrypter) Read(p []byte) (n int, err error) {
 		copy(fh.buf, fh.nonce[:])
 		// Encrypt the block using the nonce
 		block := fh.buf
-		secretbox.Seal(block[:0], readBuf[:n], fh.nonce.pointer(), &fh.c.dataKey)
+		key := fh.keys.key(fh.block)
+		cpulimit.Do(func() {
+			secretbox.Seal(block[:0], readBuf[:n], fh.nonce.pointer(), key)
+		})
 		fh.bufIndex = 0
 		fh.bufSize = blockHeaderSize + n
 		fh.nonce.increment()
+		fh.block++
 	}
 	n = copy(p, fh.buf[fh.bufIndex:fh.bufSize])
 	fh.bufIndex += n
@@ -682,6 +750,8 @@ type decrypter struct {
 	rc           io.ReadCloser
 	nonce        nonce
 	initialNonce nonce
+	keys         keyEpochs
+	block        uint64 // index of the next block
 	c            *Cipher
 	buf          []byte
 	readBuf      []byte
@@ -711,12 +781,20 @@ func (c *Cipher) newDecrypter(rc io.ReadCloser) (*decrypter, error) {
 		return nil, fh.finishAndClose(err)
 	}
 	// check the magic
-	if !bytes.Equal(readBuf[:fileMagicSize], fileMagicBytes) {
+	fh.keys.c = c
+	if bytes.Equal(readBuf[:len(fileMagicRotate)], []byte(fileMagicRotate)) && readBuf[fileMagicSize-1]&keyRotationFlag != 0 {
+		fh.keys.rotate = true
+		fh.keys.shift = uint(readBuf[fileMagicSize-1] &^ keyRotationFlag)
+		if fh.keys.shift > maxKeyEpochShift {
+			return nil, fh.finishAndClose(ErrorEncryptedBadKeyEpoch)
+		}
+	} else if !bytes.Equal(readBuf[:fileMagicSize], fileMagicBytes) {
 		return nil, fh.finishAndClose(ErrorEncryptedBadMagic)
 	}
 	// retrieve the nonce
 	fh.nonce.fromBuf(readBuf[fileMagicSize:])
 	fh.initialNonce = fh.nonce
+	fh.keys.nonce = fh.nonce
 	return fh, nil
 }
 
@@ -783,7 +861,11 @@ func (fh *decrypter) fillBuffer() (err error) {
 	}
 	// Decrypt the block using the nonce
 	block := fh.buf
-	_, ok := secretbox.Open(block[:0], readBuf[:n], fh.nonce.pointer(), &fh.c.dataKey)
+	key := fh.keys.key(fh.block)
+	var ok bool
+	cpulimit.Do(func() {
+		_, ok = secretbox.Open(block[:0], readBuf[:n], fh.nonce.pointer(), key)
+	})
 	if !ok {
 		if err != nil {
 			return err // return pending error as it is likely more accurate
@@ -793,6 +875,7 @@ func (fh *decrypter) fillBuffer() (err error) {
 	fh.bufIndex = 0
 	fh.bufSize = n - blockHeaderSize
 	fh.nonce.increment()
+	fh.block++
 	return nil
 }
 
@@ -892,6 +975,7 @@ func (fh *decrypter) RangeSeek(ctx context.Context, offset int64, whence int, li
 	// Move the nonce on the correct number of blocks from the start
 	fh.nonce = fh.initialNonce
 	fh.nonce.add(uint64(blocks))
+	fh.block = uint64(blocks)
 
 	// Can we seek underlying stream directly?
 	if do, ok := fh.rc.(fs.RangeSeeker); ok {
@@ -759,6 +759,79 @@ func TestEncryptData(t *testing.T) {
 	}
 }
 
+func TestSetKeyRotation(t *testing.T) {
+	c, err := newCipher(NameEncryptionStandard, "", "", true)
+	require.NoError(t, err)
+	for _, test := range []struct {
+		in     int64
+		rotate bool
+		shift  uint
+	}{
+		{0, false, 0},
+		{1, true, 0},
+		{blockDataSize, true, 0},
+		{blockDataSize + 1, true, 1},
+		{3 * blockDataSize, true, 2},
+		{1 << 62, true, maxKeyEpochShift},
+	} {
+		c.setKeyRotation(test.in)
+		assert.Equal(t, test.rotate, c.keyRotation, test.in)
+		assert.Equal(t, test.shift, c.keyEpochShift, test.in)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	c, err := newCipher(NameEncryptionStandard, "", "", true)
+	require.NoError(t, err)
+	c.cryptoRand = newRandomSource(1e8) // nodge the crypto rand generator
+	c.setKeyRotation(2 * blockDataSize)
+
+	const dataSize = 5*blockDataSize + 1234
+	plaintext, err := ioutil.ReadAll(newRandomSource(dataSize))
+	require.NoError(t, err)
+	encrypted, err := c.EncryptData(bytes.NewBuffer(plaintext))
+	require.NoError(t, err)
+	ciphertext, err := ioutil.ReadAll(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("RCLONE\x00\x81"), ciphertext[:fileMagicSize])
+	assert.Equal(t, c.EncryptedSize(dataSize), int64(len(ciphertext)))
+
+	// Decrypting doesn't need the option set
+	d, err := newCipher(NameEncryptionStandard, "", "", true)
+	require.NoError(t, err)
+	decrypted, err := d.DecryptData(ioutil.NopCloser(bytes.NewBuffer(ciphertext)))
+	require.NoError(t, err)
+	out, err := ioutil.ReadAll(decrypted)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, out)
+
+	// Seeking selects the key for the epoch
+	open := func(ctx context.Context, underlyingOffset, underlyingLimit int64) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewBuffer(ciphertext[underlyingOffset:])), nil
+	}
+	for _, offset := range []int64{0, blockDataSize + 7, 2 * blockDataSize, 4*blockDataSize + 99, dataSize - 1} {
+		rc, err := d.DecryptDataSeek(context.Background(), open, offset, -1)
+		require.NoError(t, err)
+		out, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		assert.Equal(t, plaintext[offset:], out, offset)
+	}
+
+	// The file isn't encrypted with the data key
+	oldFormat := append([]byte(nil), ciphertext...)
+	copy(oldFormat, fileMagic)
+	decrypted, err = d.DecryptData(ioutil.NopCloser(bytes.NewBuffer(oldFormat)))
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(decrypted)
+	assert.Equal(t, ErrorEncryptedBadBlock, err)
+
+	// Bad rotation sizes are rejected
+	badShift := append([]byte(nil), ciphertext...)
+	badShift[fileMagicSize-1] = keyRotationFlag | (maxKeyEpochShift + 1)
+	_, err = d.DecryptData(ioutil.NopCloser(bytes.NewBuffer(badShift)))
+	assert.Equal(t, ErrorEncryptedBadKeyEpoch, err)
+}
+
 func TestNewEncrypter(t *testing.T) {
 	c, err := newCipher(NameEncryptionStandard, "", "", true)
 	assert.NoError(t, err)
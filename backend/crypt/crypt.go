@@ -100,6 +100,24 @@ names, or for debugging purposes.`,
 			Default:  false,
 			Hide:     fs.OptionHideConfigurator,
 			Advanced: true,
+		}, {
+			Name: "key_rotation",
+			Help: `Use a new data encryption key for every this much of each new file.
+
+If this is set then each new file is encrypted with a series of keys,
+changing key every time this much data has been encrypted, so each
+key protects less data.  The size is rounded up to a power of 2
+multiple of the 64k encryption block size.
+
+The keys are derived from the password and the random nonce of the
+file, and the rotation size used is recorded in the file header, so
+files can always be decrypted whatever this is set to.  Older
+versions of rclone can't decrypt files written with this set.
+
+0 means don't rotate the key, which writes files in the original
+format.`,
+			Default:  fs.SizeSuffix(0),
+			Advanced: true,
 		}},
 	})
 }
@@ -128,6 +146,7 @@ func newCipherForConfig(opt *Options) (*Cipher, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to make cipher")
 	}
+	cipher.setKeyRotation(int64(opt.KeyRotation))
 	return cipher, nil
 }
 
@@ -203,13 +222,14 @@ func NewFs(name, rpath string, m configmap.Mapper) (fs.Fs, error) {
 
 // Options defines the configuration for this backend
 type Options struct {
-	Remote                  string `config:"remote"`
-	FilenameEncryption      string `config:"filename_encryption"`
-	DirectoryNameEncryption bool   `config:"directory_name_encryption"`
-	Password                string `config:"password"`
-	Password2               string `config:"password2"`
-	ServerSideAcrossConfigs bool   `config:"server_side_across_configs"`
-	ShowMapping             bool   `config:"show_mapping"`
+	Remote                  string        `config:"remote"`
+	FilenameEncryption      string        `config:"filename_encryption"`
+	DirectoryNameEncryption bool          `config:"directory_name_encryption"`
+	Password                string        `config:"password"`
+	Password2               string        `config:"password2"`
+	ServerSideAcrossConfigs bool          `config:"server_side_across_configs"`
+	ShowMapping             bool          `config:"show_mapping"`
+	KeyRotation             fs.SizeSuffix `config:"key_rotation"`
 }
 
 // Fs represents a wrapped fs.Fs
@@ -254,7 +274,7 @@ func (f *Fs) add(entries *fs.DirEntries, obj fs.Object) {
 	if f.opt.ShowMapping {
 		fs.Logf(decryptedRemote, "Encrypts to %q", remote)
 	}
-	*entries = append(*entries, f.newObject(obj))
+	*entries = append(*entries, f.newDecryptedObject(obj, decryptedRemote))
 }
 
 // Encrypt a directory file name to entries.
@@ -553,6 +573,29 @@ func (f *Fs) About(ctx context.Context) (*fs.Usage, error) {
 	return do(ctx)
 }
 
+// MaxObjectSize returns the size of the largest file which encrypts to
+// no more than the largest object the wrapped Fs can store, or -1 if
+// that isn't known.
+func (f *Fs) MaxObjectSize(ctx context.Context) int64 {
+	do := f.Fs.Features().MaxObjectSize
+	if do == nil {
+		return -1
+	}
+	limit := do(ctx)
+	if limit < 0 {
+		return -1
+	}
+	// round down to whole blocks if the limit ends in a block header
+	if residue := (limit - int64(fileHeaderSize)) % blockSize; residue > 0 && residue <= blockHeaderSize {
+		limit -= residue
+	}
+	size, err := f.cipher.DecryptedSize(limit)
+	if err != nil {
+		return -1
+	}
+	return size
+}
+
 // UnWrap returns the Fs that this Fs is wrapping
 func (f *Fs) UnWrap() fs.Fs {
 	return f.Fs
@@ -783,13 +826,27 @@ func (f *Fs) Command(ctx context.Context, name string, arg []string, opt map[str
 // This decrypts the remote name and decrypts the data
 type Object struct {
 	fs.Object
-	f *Fs
+	f      *Fs
+	remote string // the decrypted remote
 }
 
 func (f *Fs) newObject(o fs.Object) *Object {
+	remote := o.Remote()
+	decryptedRemote, err := f.cipher.DecryptFileName(remote)
+	if err != nil {
+		fs.Debugf(remote, "Undecryptable file name: %v", err)
+		decryptedRemote = remote
+	}
+	return f.newDecryptedObject(o, decryptedRemote)
+}
+
+// newDecryptedObject wraps o whose remote has already been decrypted
+// to decryptedRemote so it isn't decrypted every time it is needed
+func (f *Fs) newDecryptedObject(o fs.Object, decryptedRemote string) *Object {
 	return &Object{
 		Object: o,
 		f:      f,
+		remote: decryptedRemote,
 	}
 }
 
@@ -808,13 +865,7 @@ func (o *Object) String() string {
 
 // Remote returns the remote path
 func (o *Object) Remote() string {
-	remote := o.Object.Remote()
-	decryptedName, err := o.f.cipher.DecryptFileName(remote)
-	if err != nil {
-		fs.Debugf(remote, "Undecryptable file name: %v", err)
-		return remote
-	}
-	return decryptedName
+	return o.remote
 }
 
 // Size returns the size of the file
@@ -1015,6 +1066,7 @@ var (
 	_ fs.UnWrapper       = (*Fs)(nil)
 	_ fs.ListRer         = (*Fs)(nil)
 	_ fs.Abouter         = (*Fs)(nil)
+	_ fs.MaxObjectSizer  = (*Fs)(nil)
 	_ fs.Wrapper         = (*Fs)(nil)
 	_ fs.MergeDirser     = (*Fs)(nil)
 	_ fs.DirCacheFlusher = (*Fs)(nil)
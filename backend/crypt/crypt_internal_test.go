@@ -6,10 +6,13 @@ import (
 	"crypto/md5"
 	"fmt"
 	"io"
+	"sort"
 	"testing"
 	"time"
 
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/obscure"
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/object"
 	"github.com/rclone/rclone/lib/random"
@@ -141,3 +144,46 @@ func (f *Fs) InternalTest(t *testing.T) {
 	t.Run("ObjectInfoWrap", func(t *testing.T) { testObjectInfo(t, f, true) })
 	t.Run("ComputeHash", func(t *testing.T) { testComputeHash(t, f) })
 }
+
+func TestListPrefix(t *testing.T) {
+	ctx := context.Background()
+	for _, dirNameEncrypt := range []string{"true", "false"} {
+		t.Run("directory_name_encryption="+dirNameEncrypt, func(t *testing.T) {
+			localFs, cleanupLocalFs := makeTempLocalFs(t)
+			defer cleanupLocalFs()
+			f, err := NewFs("TestListPrefix", "", configmap.Simple{
+				"remote":                    localFs.Root(),
+				"password":                  obscure.MustObscure("potato"),
+				"filename_encryption":       "standard",
+				"directory_name_encryption": dirNameEncrypt,
+			})
+			require.NoError(t, err)
+			fcrypt := f.(*Fs)
+			defer func() {
+				require.NoError(t, f.Rmdir(ctx, "photos/2019-01"))
+				require.NoError(t, f.Rmdir(ctx, "photos"))
+			}()
+			for _, remote := range []string{"photos/2019-01/a.jpg", "photos/2019-summer.jpg", "photos/2020.jpg"} {
+				_, cleanup := uploadFile(t, f, remote, "hello")
+				defer cleanup()
+			}
+
+			list := func(prefix string) []string {
+				var got []string
+				err := fcrypt.ListPrefix(ctx, prefix, func(o fs.Object, relative string) {
+					got = append(got, relative)
+				})
+				require.NoError(t, err)
+				sort.Strings(got)
+				return got
+			}
+			assert.Equal(t, []string{"2019-01/a.jpg", "2019-summer.jpg"}, list("photos/2019-"))
+			assert.Equal(t, []string{"2019-01/a.jpg", "2019-summer.jpg", "2020.jpg"}, list("photos/"))
+			assert.Equal(t, []string{"photos/2019-01/a.jpg", "photos/2019-summer.jpg", "photos/2020.jpg"}, list("ph"))
+			assert.Equal(t, []string(nil), list("photos/2021"))
+
+			err = fcrypt.ListPrefix(ctx, "missing/x", func(fs.Object, string) {})
+			assert.Error(t, err)
+		})
+	}
+}
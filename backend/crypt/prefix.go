@@ -0,0 +1,73 @@
+package crypt
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// splitPrefix splits the decrypted path prefix into the directory to
+// list and the prefix the names in it must have.
+//
+// If prefix ends in "/" it is the whole directory.
+func splitPrefix(prefix string) (dir, leaf string) {
+	dir, leaf = path.Split(strings.TrimLeft(prefix, "/"))
+	return strings.TrimSuffix(dir, "/"), leaf
+}
+
+// ListPrefix calls fn for each file whose decrypted path starts with
+// prefix, which needn't end at a directory boundary, along with its
+// path relative to the directory the prefix is in.
+//
+// Only the directory the prefix is in is listed to find the matching
+// names, using its encrypted name if directory_name_encryption is set,
+// then the matching directories are listed in parallel by --checkers.
+// The filters apply as usual.
+//
+// fn is not called concurrently.
+func (f *Fs) ListPrefix(ctx context.Context, prefix string, fn func(o fs.Object, relative string)) error {
+	dir, leaf := splitPrefix(prefix)
+	found := func(o fs.Object) {
+		relative := o.Remote()
+		if dir != "" {
+			relative = strings.TrimPrefix(relative, dir+"/")
+		}
+		fn(o, relative)
+	}
+	entries, err := f.List(ctx, dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list %q", dir)
+	}
+	for _, entry := range entries {
+		if !strings.HasPrefix(path.Base(entry.Remote()), leaf) {
+			continue
+		}
+		switch x := entry.(type) {
+		case fs.Object:
+			if filter.Active.IncludeObject(ctx, x) {
+				found(x)
+			}
+		case fs.Directory:
+			include, err := filter.Active.IncludeDirectory(ctx, f)(x.Remote())
+			if err != nil {
+				return err
+			}
+			if !include {
+				continue
+			}
+			err = walk.ListR(ctx, f, x.Remote(), false, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+				entries.ForObject(found)
+				return nil
+			})
+			if err != nil {
+				return errors.Wrapf(err, "failed to list %q", x.Remote())
+			}
+		}
+	}
+	return nil
+}
@@ -652,6 +652,13 @@ func (f *Fs) shouldRetry(err error) (bool, error) {
 	return false, err
 }
 
+// isServerError returns true if err is a 5xx error where the server
+// may or may not have carried out the operation.
+func isServerError(err error) bool {
+	gerr, ok := errors.Cause(err).(*googleapi.Error)
+	return ok && gerr.Code >= 500 && gerr.Code < 600
+}
+
 // parseParse parses a drive 'url'
 func parseDrivePath(path string) (root string, err error) {
 	root = strings.Trim(path, "/")
@@ -1441,13 +1448,13 @@ func (f *Fs) CreateDir(ctx context.Context, pathID, leaf string) (newID string,
 		Parents:     []string{pathID},
 	}
 	var info *drive.File
-	err = f.pacer.Call(func() (bool, error) {
+	err = f.pacer.CallNonIdempotent(func() (bool, error) {
 		info, err = f.svc.Files.Create(createInfo).
 			Fields("id").
 			SupportsAllDrives(true).
 			Do()
 		return f.shouldRetry(err)
-	})
+	}, isServerError)
 	if err != nil {
 		return "", err
 	}
@@ -2112,7 +2119,7 @@ func (f *Fs) PutUnchecked(ctx context.Context, in io.Reader, src fs.ObjectInfo,
 	if size >= 0 && size < int64(f.opt.UploadCutoff) {
 		// Make the API request to upload metadata and file data.
 		// Don't retry, return a retry error instead
-		err = f.pacer.CallNoRetry(func() (bool, error) {
+		err = f.pacer.CallNoRetry(fs.NonIdempotent(func() (bool, error) {
 			info, err = f.svc.Files.Create(createInfo).
 				Media(in, googleapi.ContentType(srcMimeType)).
 				Fields(partialFields).
@@ -2120,7 +2127,7 @@ func (f *Fs) PutUnchecked(ctx context.Context, in io.Reader, src fs.ObjectInfo,
 				KeepRevisionForever(f.opt.KeepRevisionForever).
 				Do()
 			return f.shouldRetry(err)
-		})
+		}, isServerError))
 		if err != nil {
 			return nil, err
 		}
@@ -2329,14 +2336,14 @@ func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object,
 	id := shortcutID(srcObj.id)
 
 	var info *drive.File
-	err = f.pacer.Call(func() (bool, error) {
+	err = f.pacer.CallNonIdempotent(func() (bool, error) {
 		info, err = f.svc.Files.Copy(id, createInfo).
 			Fields(partialFields).
 			SupportsAllDrives(true).
 			KeepRevisionForever(f.opt.KeepRevisionForever).
 			Do()
 		return f.shouldRetry(err)
-	})
+	}, isServerError)
 	if err != nil {
 		return nil, err
 	}
@@ -2624,7 +2631,10 @@ func (f *Fs) ChangeNotify(ctx context.Context, notifyFunc func(string, fs.EntryT
 					}
 				}
 				fs.Debugf(f, "Checking for changes on remote")
-				startPageToken, err = f.changeNotifyRunner(ctx, notifyFunc, startPageToken)
+				startPageToken, err = f.changeNotifyRunner(ctx, notifyFunc, startPageToken, func(ID string) (string, bool, error) {
+					dirPath, ok := f.dirCache.GetInv(ID)
+					return dirPath, ok, nil
+				})
 				if err != nil {
 					fs.Infof(f, "Change notify listener failure: %s", err)
 				}
@@ -2648,7 +2658,65 @@ func (f *Fs) changeNotifyStartPageToken() (pageToken string, err error) {
 	return startPageToken.StartPageToken, nil
 }
 
-func (f *Fs) changeNotifyRunner(ctx context.Context, notifyFunc func(string, fs.EntryType), startPageToken string) (newStartPageToken string, err error) {
+// ListChanges calls fn with the path of each file or directory which
+// has changed since token was returned and returns the token to use
+// next time.
+//
+// If token is "" then it just returns the token for changes from now
+// on.
+//
+// Files which have been permanently deleted rather than trashed
+// aren't reported as drive no longer knows where they were.
+func (f *Fs) ListChanges(ctx context.Context, token string, fn func(string, fs.EntryType)) (newToken string, err error) {
+	if token == "" {
+		return f.changeNotifyStartPageToken()
+	}
+	err = f.dirCache.FindRoot(ctx, false)
+	if err != nil {
+		return "", err
+	}
+	outside := map[string]struct{}{}
+	return f.changeNotifyRunner(ctx, fn, token, func(ID string) (string, bool, error) {
+		return f.findDirPath(ID, outside)
+	})
+}
+
+// findDirPath returns the path of the directory with ID relative to
+// the root, reading and caching its parents if it isn't in the
+// directory cache.
+//
+// found is false if the directory isn't under the root. IDs found to
+// be outside the root are remembered in outside.
+func (f *Fs) findDirPath(ID string, outside map[string]struct{}) (dirPath string, found bool, err error) {
+	if dirPath, ok := f.dirCache.GetInv(ID); ok {
+		return dirPath, true, nil
+	}
+	if _, ok := outside[ID]; ok {
+		return "", false, nil
+	}
+	info, err := f.getFile(ID, "name,parents")
+	if err != nil {
+		return "", false, errors.Wrapf(err, "couldn't read directory %q", ID)
+	}
+	for _, parent := range info.Parents {
+		parentPath, found, err := f.findDirPath(parent, outside)
+		if err != nil {
+			return "", false, err
+		}
+		if found {
+			dirPath = path.Join(parentPath, f.opt.Enc.ToStandardName(info.Name))
+			f.dirCache.Put(dirPath, ID)
+			return dirPath, true, nil
+		}
+	}
+	outside[ID] = struct{}{}
+	return "", false, nil
+}
+
+// changeNotifyRunner calls notifyFunc with the paths of the changes
+// since startPageToken using findDirPath to find the path of the
+// parent directories.
+func (f *Fs) changeNotifyRunner(ctx context.Context, notifyFunc func(string, fs.EntryType), startPageToken string, findDirPath func(ID string) (dirPath string, found bool, err error)) (newStartPageToken string, err error) {
 	pageToken := startPageToken
 	for {
 		var changeList *drive.ChangeList
@@ -2701,7 +2769,11 @@ func (f *Fs) changeNotifyRunner(ctx context.Context, notifyFunc func(string, fs.
 				// translate the parent dir of this object
 				if len(change.File.Parents) > 0 {
 					for _, parent := range change.File.Parents {
-						if parentPath, ok := f.dirCache.GetInv(parent); ok {
+						parentPath, ok, err := findDirPath(parent)
+						if err != nil {
+							return "", err
+						}
+						if ok {
 							// and append the drive file name to compute the full file name
 							newPath := path.Join(parentPath, change.File.Name)
 							// this will now clear the actual file too
@@ -3497,6 +3569,7 @@ var (
 	_ fs.Commander       = (*Fs)(nil)
 	_ fs.DirCacheFlusher = (*Fs)(nil)
 	_ fs.ChangeNotifier  = (*Fs)(nil)
+	_ fs.ChangeLister    = (*Fs)(nil)
 	_ fs.PutUncheckeder  = (*Fs)(nil)
 	_ fs.PublicLinker    = (*Fs)(nil)
 	_ fs.ListRer         = (*Fs)(nil)
@@ -23,6 +23,7 @@ of path_display and all will be well.
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -248,6 +249,30 @@ func shouldRetry(err error) (bool, error) {
 	return fserrors.ShouldRetry(err), err
 }
 
+// badInputPrefix starts the plain text body of a 400 Bad Input error
+const badInputPrefix = "Error in call to API function"
+
+// isServerError returns true if err is one of the errors the SDK
+// returns for a server error (5xx), where the server may or may not
+// have carried out the operation, rather than an error of the
+// endpoint.
+//
+// The SDK doesn't return the HTTP status.  It puts the plain text body
+// of both a 400 Bad Input and a 500 Internal Server Error into a
+// common API error, so the bad input errors are told apart by their
+// body.  The other statuses which reach a common API error or a body
+// which isn't JSON aren't ones the Dropbox API returns for errors of
+// the call, so are from the servers or the proxies in front of them.
+func isServerError(err error) bool {
+	switch e := errors.Cause(err).(type) {
+	case dropbox.APIError:
+		return !strings.HasPrefix(e.ErrorSummary, badInputPrefix)
+	case *json.SyntaxError:
+		return true
+	}
+	return false
+}
+
 func checkUploadChunkSize(cs fs.SizeSuffix) error {
 	const minChunkSize = fs.Byte
 	if cs < minChunkSize {
@@ -604,10 +629,10 @@ func (f *Fs) Mkdir(ctx context.Context, dir string) error {
 	arg2 := files.CreateFolderArg{
 		Path: f.opt.Enc.FromStandardPath(root),
 	}
-	err = f.pacer.Call(func() (bool, error) {
+	err = f.pacer.CallNonIdempotent(func() (bool, error) {
 		_, err = f.srv.CreateFolderV2(&arg2)
 		return shouldRetry(err)
-	})
+	}, isServerError)
 	return err
 }
 
@@ -693,10 +718,10 @@ func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object,
 	}
 	var err error
 	var result *files.RelocationResult
-	err = f.pacer.Call(func() (bool, error) {
+	err = f.pacer.CallNonIdempotent(func() (bool, error) {
 		result, err = f.srv.CopyV2(&arg)
 		return shouldRetry(err)
-	})
+	}, isServerError)
 	if err != nil {
 		return nil, errors.Wrap(err, "copy failed")
 	}
@@ -1130,7 +1155,7 @@ func (o *Object) uploadChunked(in0 io.Reader, commitInfo *files.CommitInfo, size
 	}
 	fmtChunk(currentChunk, true)
 	chunk = readers.NewRepeatableReaderBuffer(in, buf)
-	err = o.fs.pacer.Call(func() (bool, error) {
+	err = o.fs.pacer.CallNonIdempotent(func() (bool, error) {
 		// seek to the start in case this is a retry
 		if _, err = chunk.Seek(0, io.SeekStart); err != nil {
 			return false, nil
@@ -1145,7 +1170,7 @@ func (o *Object) uploadChunked(in0 io.Reader, commitInfo *files.CommitInfo, size
 		}
 		// after the first chunk is uploaded, we retry everything
 		return err != nil, err
-	})
+	}, isServerError)
 	if err != nil {
 		return nil, err
 	}
@@ -1173,10 +1198,10 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	if size > int64(o.fs.opt.ChunkSize) || size == -1 {
 		entry, err = o.uploadChunked(in, commitInfo, size)
 	} else {
-		err = o.fs.pacer.CallNoRetry(func() (bool, error) {
+		err = o.fs.pacer.CallNoRetry(fs.NonIdempotent(func() (bool, error) {
 			entry, err = o.fs.srv.Upload(commitInfo, in)
 			return shouldRetry(err)
-		})
+		}, isServerError))
 	}
 	if err != nil {
 		return errors.Wrap(err, "upload failed")
@@ -0,0 +1,29 @@
+package dropbox
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsServerError(t *testing.T) {
+	var syntaxErr error = &json.SyntaxError{}
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{err: errors.New("potato"), want: false},
+		{err: dropbox.APIError{ErrorSummary: ""}, want: true},
+		{err: dropbox.APIError{ErrorSummary: "Internal Server Error"}, want: true},
+		{err: errors.Wrap(dropbox.APIError{ErrorSummary: "Internal Server Error"}, "upload failed"), want: true},
+		{err: syntaxErr, want: true},
+		{err: dropbox.APIError{ErrorSummary: `Error in call to API function "files/upload": HTTP header "Dropbox-API-Arg": could not decode input as JSON`}, want: false},
+		{err: files.UploadAPIError{APIError: dropbox.APIError{ErrorSummary: "path/conflict/file/.."}}, want: false},
+	} {
+		assert.Equal(t, test.want, isServerError(test.err), test.err.Error())
+	}
+}
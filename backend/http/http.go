@@ -31,6 +31,12 @@ var (
 	timeUnset     = time.Unix(0, 0)
 )
 
+const defaultMaxRedirects = 10 // same as the Go http client
+
+// authHeaders are the headers which carry the authentication so
+// shouldn't be sent to other hosts on a redirect
+var authHeaders = []string{"Authorization", "Www-Authenticate", "Cookie", "Cookie2"}
+
 func init() {
 	fsi := &fs.RegInfo{
 		Name:        "http",
@@ -98,6 +104,37 @@ If you set this option, rclone will not do the HEAD request.  This will mean
 `,
 			Default:  false,
 			Advanced: true,
+		}, {
+			Name: "max_redirects",
+			Help: `Maximum number of redirects to follow
+
+If the server redirects more times than this for a single request
+then the request fails with an error.
+
+Set this to 0 to not follow any redirects.`,
+			Default:  defaultMaxRedirects,
+			Advanced: true,
+		}, {
+			Name: "no_cross_host_redirects",
+			Help: `Don't follow redirects to other hosts
+
+Set this to fail any request which the server redirects to a
+different host rather than following it.`,
+			Default:  false,
+			Advanced: true,
+		}, {
+			Name: "redirect_auth",
+			Help: `Send the authentication on redirects to other hosts
+
+Normally when rclone follows a redirect to a different host it
+doesn't send the Authorization, Cookie and WWW-Authenticate headers
+or the username and password from the url with it, so they can't
+leak to another site.
+
+Set this if you trust the hosts the server redirects to and they need
+the authentication too.`,
+			Default:  false,
+			Advanced: true,
 		}},
 	}
 	fs.Register(fsi)
@@ -109,6 +146,10 @@ type Options struct {
 	NoSlash  bool            `config:"no_slash"`
 	NoHead   bool            `config:"no_head"`
 	Headers  fs.CommaSepList `config:"headers"`
+
+	MaxRedirects         int  `config:"max_redirects"`
+	NoCrossHostRedirects bool `config:"no_cross_host_redirects"`
+	RedirectAuth         bool `config:"redirect_auth"`
 }
 
 // Fs stores the interface to the remote HTTP files
@@ -173,6 +214,7 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 	}
 
 	client := fshttp.NewClient(fs.Config)
+	client.CheckRedirect = checkRedirect(opt)
 
 	var isFile = false
 	if !strings.HasSuffix(u.String(), "/") {
@@ -364,6 +406,45 @@ func parse(base *url.URL, in io.Reader) (names []string, err error) {
 	return names, nil
 }
 
+// checkRedirect returns the redirect policy for the http client
+// configured by opt.
+//
+// It is called with the headers of the original request already
+// copied to req.
+func checkRedirect(opt *Options) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > opt.MaxRedirects {
+			u := *req.URL
+			u.User = nil
+			return errors.Errorf("too many redirects: not following redirect to %q as max_redirects is %d", u.String(), opt.MaxRedirects)
+		}
+		orig := via[0]
+		if req.URL.Host == orig.URL.Host {
+			return nil
+		}
+		if opt.NoCrossHostRedirects {
+			return errors.Errorf("not following redirect from host %q to host %q as no_cross_host_redirects is set", orig.URL.Host, req.URL.Host)
+		}
+		if !opt.RedirectAuth {
+			// The Go client sends these to subdomains so always remove them
+			for _, header := range authHeaders {
+				req.Header.Del(header)
+			}
+			return nil
+		}
+		for _, header := range authHeaders {
+			if values, ok := orig.Header[header]; ok {
+				req.Header[header] = values
+			}
+		}
+		if user := orig.URL.User; user != nil && req.Header.Get("Authorization") == "" {
+			password, _ := user.Password()
+			req.SetBasicAuth(user.Username(), password)
+		}
+		return nil
+	}
+}
+
 // Adds the configured headers to the request if any
 func addHeaders(req *http.Request, opt *Options) {
 	for i := 0; i < len(opt.Headers); i += 2 {
@@ -353,3 +353,59 @@ func TestParseCaddy(t *testing.T) {
 		"v1.36-22-g06ea13a-ssh-agentβ/",
 	})
 }
+
+func TestRedirects(t *testing.T) {
+	var gotAuth []string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = append(gotAuth, r.Header.Get("Authorization"))
+		assert.Equal(t, "sausage", r.Header.Get("X-Potato"))
+		_, _ = w.Write([]byte("potato"))
+	}))
+	defer target.Close()
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		if _, err := fmt.Sscanf(r.URL.Path, "/loop%d", &n); err == nil && n < 3 {
+			http.Redirect(w, r, fmt.Sprintf("/loop%d", n+1), http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, target.URL+r.URL.Path, http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	newObject := func(remote string, m configmap.Simple) error {
+		m["type"] = "http"
+		m["url"] = redirector.URL
+		m["headers"] = "Authorization,secret,X-Potato,sausage"
+		if _, ok := m["max_redirects"]; !ok {
+			m["max_redirects"] = fmt.Sprint(defaultMaxRedirects)
+		}
+		f, err := NewFs(remoteName, "", m)
+		require.NoError(t, err)
+		_, err = f.NewObject(context.Background(), remote)
+		return err
+	}
+
+	// By default cross host redirects are followed without the auth
+	require.NoError(t, newObject("file.txt", configmap.Simple{}))
+	assert.Equal(t, []string{""}, gotAuth)
+
+	gotAuth = nil
+	require.NoError(t, newObject("file.txt", configmap.Simple{"redirect_auth": "true"}))
+	assert.Equal(t, []string{"secret"}, gotAuth)
+
+	gotAuth = nil
+	err := newObject("file.txt", configmap.Simple{"no_cross_host_redirects": "true"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no_cross_host_redirects")
+	assert.Nil(t, gotAuth)
+
+	// loop0 redirects 3 times on the same host then to the target
+	require.NoError(t, newObject("loop0", configmap.Simple{"max_redirects": "4"}))
+	err = newObject("loop0", configmap.Simple{"max_redirects": "3"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "too many redirects")
+	assert.Contains(t, err.Error(), "max_redirects is 3")
+	err = newObject("file.txt", configmap.Simple{"max_redirects": "0"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "max_redirects is 0")
+}
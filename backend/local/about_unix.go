@@ -23,6 +23,11 @@ func (f *Fs) About(ctx context.Context) (*fs.Usage, error) {
 		Used:  fs.NewUsageValue(bs * int64(s.Blocks-s.Bfree)), // bytes in use
 		Free:  fs.NewUsageValue(bs * int64(s.Bavail)),         // bytes which can be uploaded before reaching the quota
 	}
+	// Some file systems, eg btrfs, don't have a fixed number of inodes
+	// and report zero so only fill in the free inodes if we have some
+	if s.Files > 0 {
+		usage.FreeObjects = fs.NewUsageValue(int64(s.Ffree)) // nolint: unconvert
+	}
 	return usage, nil
 }
 
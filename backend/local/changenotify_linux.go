@@ -0,0 +1,305 @@
+//+build linux
+
+package local
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"golang.org/x/sys/unix"
+)
+
+// events watched for in each directory
+const watchMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_ATTRIB |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE | unix.IN_ONLYDIR
+
+// watcher watches the directories of an Fs with inotify and collects
+// the paths which change
+type watcher struct {
+	f    *Fs
+	fd   int      // the inotify instance
+	file *os.File // fd for reading - don't call Fd() as it blocks
+
+	closing   chan struct{} // closed when the watcher is closed
+	closeOnce sync.Once
+
+	mu      sync.Mutex
+	dirs    map[int]string          // directory for each watch descriptor
+	changes map[string]fs.EntryType // paths changed since the last flush
+	added   []string                // new directories to watch
+}
+
+// errWatcherClosed is returned when adding directories to a closed watcher
+var errWatcherClosed = errors.New("watcher closed")
+
+// newWatcher makes a watcher for f which isn't watching anything yet
+func newWatcher(f *Fs) (*watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to start inotify")
+	}
+	w := &watcher{
+		f:       f,
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		closing: make(chan struct{}),
+		dirs:    make(map[int]string),
+		changes: make(map[string]fs.EntryType),
+	}
+	return w, nil
+}
+
+// watchAll watches all the directories in f, which may take a while
+// on a big tree, closing the watcher if it fails
+func (w *watcher) watchAll() {
+	err := w.addDir("", false)
+	if err == errWatcherClosed {
+		return
+	} else if err != nil {
+		fs.Errorf(w.f, "Failed to watch for changes: %v", err)
+		w.close()
+		return
+	}
+	w.mu.Lock()
+	n := len(w.dirs)
+	w.mu.Unlock()
+	fs.Debugf(w.f, "Watching %d directories for changes", n)
+}
+
+// close stops the watcher
+func (w *watcher) close() {
+	w.closeOnce.Do(func() {
+		close(w.closing)
+		_ = w.file.Close()
+	})
+}
+
+// remote returns the remote for the OS path p
+func (w *watcher) remote(p string) string {
+	rel, err := filepath.Rel(w.f.root, p)
+	if err != nil || rel == "." {
+		return ""
+	}
+	return w.f.opt.Enc.ToStandardPath(filepath.ToSlash(rel))
+}
+
+// addDir watches dir and the directories in it.
+//
+// If notify is set then everything in dir is recorded as changed as
+// it is new to the watcher.
+//
+// Call without mu held.
+func (w *watcher) addDir(dir string, notify bool) error {
+	return filepath.Walk(w.f.localPath(dir), func(p string, info os.FileInfo, err error) error {
+		select {
+		case <-w.closing:
+			return errWatcherClosed
+		default:
+		}
+		if err != nil {
+			// Things may be removed while we walk them
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		remote := w.remote(p)
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if !info.IsDir() {
+			if notify {
+				w.changes[w.objectRemote(remote, info)] = fs.EntryObject
+			}
+			return nil
+		}
+		wd, err := unix.InotifyAddWatch(w.fd, p, watchMask)
+		if err == unix.ENOSPC {
+			return errors.Wrapf(err, "failed to watch %q - too many directories for fs.inotify.max_user_watches", p)
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to watch %q", p)
+		}
+		w.dirs[wd] = remote
+		if notify && remote != dir {
+			w.changes[remote] = fs.EntryDirectory
+		}
+		return nil
+	})
+}
+
+// objectRemote returns the remote for a file which may be a symlink
+func (w *watcher) objectRemote(remote string, info os.FileInfo) string {
+	if w.f.opt.TranslateSymlinks && info.Mode()&os.ModeSymlink != 0 {
+		return remote + linkSuffix
+	}
+	return remote
+}
+
+// removeDir stops watching dir and the directories in it.
+//
+// Call with mu held.
+func (w *watcher) removeDir(dir string) {
+	for wd, watched := range w.dirs {
+		if watched == dir || strings.HasPrefix(watched, dir+"/") {
+			// This fails if the directory has gone already
+			_, _ = unix.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.dirs, wd)
+		}
+	}
+}
+
+// readEvents reads the events from inotify until the watcher is closed
+func (w *watcher) readEvents() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				fs.Errorf(w.f, "Stopped watching for changes: %v", err)
+			}
+			return
+		}
+		w.mu.Lock()
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(event.Len)]
+			w.handleEvent(event, strings.TrimRight(string(nameBytes), "\x00"))
+			offset += unix.SizeofInotifyEvent + int(event.Len)
+		}
+		added := w.added
+		w.added = nil
+		w.mu.Unlock()
+		for _, dir := range added {
+			err := w.addDir(dir, true)
+			if err == errWatcherClosed {
+				return
+			} else if err != nil {
+				fs.Errorf(w.f, "Not watching new directory for changes: %v", err)
+			}
+		}
+	}
+}
+
+// handleEvent records the change event says has happened to name.
+//
+// Call with mu held.
+func (w *watcher) handleEvent(event *unix.InotifyEvent, name string) {
+	mask := event.Mask
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		fs.Logf(w.f, "Too many changes to watch individually - rescanning everything")
+		w.changes[""] = fs.EntryDirectory
+		return
+	}
+	dir, ok := w.dirs[int(event.Wd)]
+	if !ok {
+		return
+	}
+	if mask&unix.IN_IGNORED != 0 {
+		delete(w.dirs, int(event.Wd))
+		return
+	}
+	if name == "" {
+		return
+	}
+	remote := w.f.cleanRemote(dir, name)
+	if mask&unix.IN_ISDIR == 0 {
+		if info, err := w.f.lstat(filepath.Join(w.f.localPath(dir), name)); err == nil {
+			remote = w.objectRemote(remote, info)
+		}
+		w.changes[remote] = fs.EntryObject
+		return
+	}
+	w.changes[remote] = fs.EntryDirectory
+	switch {
+	case mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+		// A directory moved in has contents we haven't seen
+		w.added = append(w.added, remote)
+	case mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+		w.removeDir(remote)
+	}
+}
+
+// flush calls notifyFunc for each change since the last flush in
+// directory order
+func (w *watcher) flush(notifyFunc func(string, fs.EntryType)) {
+	w.mu.Lock()
+	changes := w.changes
+	w.changes = make(map[string]fs.EntryType)
+	w.mu.Unlock()
+	remotes := make([]string, 0, len(changes))
+	for remote := range changes {
+		remotes = append(remotes, remote)
+	}
+	sort.Slice(remotes, func(i, j int) bool {
+		di, dj := path.Dir(remotes[i]), path.Dir(remotes[j])
+		if di != dj {
+			return di < dj
+		}
+		return remotes[i] < remotes[j]
+	})
+	for _, remote := range remotes {
+		notifyFunc(remote, changes[remote])
+	}
+}
+
+// ChangeNotify calls the passed function with a path that has had
+// changes.  If the implementation uses polling, it should adhere to
+// the given interval.
+//
+// The local backend watches the directories with inotify.  The
+// directories are scanned in the background to set up the watches.
+// The changes seen in each interval are coalesced so each path is
+// only notified once per interval.  Changes are not notified while
+// the interval is 0 and the watching stops when pollIntervalChan is
+// closed.
+//
+// This is only in the features with --local-watch-changes.
+func (f *Fs) ChangeNotify(ctx context.Context, notifyFunc func(string, fs.EntryType), pollIntervalChan <-chan time.Duration) {
+	w, err := newWatcher(f)
+	if err != nil {
+		fs.Errorf(f, "Failed to watch for changes: %v", err)
+		go func() {
+			for range pollIntervalChan {
+			}
+		}()
+		return
+	}
+	go w.watchAll()
+	go w.readEvents()
+	go func() {
+		var ticker *time.Ticker
+		var tickerC <-chan time.Time
+		for {
+			select {
+			case pollInterval, ok := <-pollIntervalChan:
+				if ticker != nil {
+					ticker.Stop()
+					ticker, tickerC = nil, nil
+				}
+				if !ok {
+					w.close()
+					return
+				}
+				if pollInterval != 0 {
+					ticker = time.NewTicker(pollInterval)
+					tickerC = ticker.C
+				}
+			case <-tickerC:
+				w.flush(notifyFunc)
+			}
+		}
+	}()
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.ChangeNotifier = &Fs{}
+)
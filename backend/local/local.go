@@ -26,6 +26,8 @@ import (
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/lib/encoder"
 	"github.com/rclone/rclone/lib/file"
+	"github.com/rclone/rclone/lib/openfiles"
+	"github.com/rclone/rclone/lib/random"
 	"github.com/rclone/rclone/lib/readers"
 )
 
@@ -144,6 +146,24 @@ the OS zeros the file. However sparse files may be undesirable as they
 cause disk fragmentation and can be slow to work with.`,
 			Default:  false,
 			Advanced: true,
+		}, {
+			Name: "watch_changes",
+			Help: `Watch the directories for changes (Linux only)
+
+Normally the local backend doesn't tell rclone about changes made to
+the files by other programs, so mounts only see them when the
+directory cache expires.  Set this flag to watch the directories with
+inotify so changes are seen as they happen, as with the polling of
+remotes which support it.
+
+Each directory needs an inotify watch, which are limited by
+fs.inotify.max_user_watches, and the whole tree is scanned to set them
+up when the watching starts so this can take a while on big trees.
+
+This isn't needed for --watch with sync and copy which watches the
+source anyway.`,
+			Default:  false,
+			Advanced: true,
 		}, {
 			Name:     config.ConfigEncoding,
 			Help:     config.ConfigEncodingHelp,
@@ -166,6 +186,7 @@ type Options struct {
 	CaseSensitive     bool                 `config:"case_sensitive"`
 	CaseInsensitive   bool                 `config:"case_insensitive"`
 	NoSparse          bool                 `config:"no_sparse"`
+	WatchChanges      bool                 `config:"watch_changes"`
 	Enc               encoder.MultiEncoder `config:"encoding"`
 }
 
@@ -234,6 +255,10 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 		IsLocal:                 true,
 		SlowHash:                true,
 	}).Fill(f)
+	if !opt.WatchChanges {
+		// Only --watch uses ChangeNotify directly unless asked for
+		f.features.ChangeNotify = nil
+	}
 	if opt.FollowSymlinks {
 		f.lstat = os.Stat
 	}
@@ -679,6 +704,90 @@ func (f *Fs) Move(ctx context.Context, src fs.Object, remote string) (fs.Object,
 	return dstObj, nil
 }
 
+// Link makes an object at remote with the same contents as src
+// without copying the data, using a reflink or a hardlink as set by
+// mode.
+//
+// If remote exists it is replaced.
+//
+// If it isn't possible then return fs.ErrorCantLink
+func (f *Fs) Link(ctx context.Context, src fs.Object, remote string, mode fs.LinkMode) (fs.Object, error) {
+	srcObj, ok := src.(*Object)
+	if !ok {
+		fs.Debugf(src, "Can't link - not same remote type")
+		return nil, fs.ErrorCantLink
+	}
+
+	// Temporary Object under construction
+	dstObj := f.newObject(remote)
+	dstObj.fs.objectMetaMu.RLock()
+	dstObjMode := dstObj.mode
+	dstObj.fs.objectMetaMu.RUnlock()
+
+	// Check it is a file if it exists
+	err := dstObj.lstat()
+	if os.IsNotExist(err) {
+		// OK
+	} else if err != nil {
+		return nil, err
+	} else if !dstObj.fs.isRegular(dstObjMode) {
+		// It isn't a file
+		return nil, errors.New("can't link file onto non-file")
+	}
+
+	// Create destination
+	err = dstObj.mkdirAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// Make the link next to the destination then rename it over
+	// the destination so an existing file is replaced in one go
+	tmpPath := dstObj.path + ".rclone-link-" + random.String(8)
+	switch mode {
+	case fs.LinkModeReflink:
+		err = reflink(srcObj.path, tmpPath)
+	case fs.LinkModeHardlink:
+		err = hardlink(srcObj.path, tmpPath)
+	case fs.LinkModeAuto:
+		err = reflink(srcObj.path, tmpPath)
+		if err == fs.ErrorCantLink {
+			err = hardlink(srcObj.path, tmpPath)
+		}
+	default:
+		err = fs.ErrorCantLink
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = os.Rename(tmpPath, dstObj.path)
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return nil, err
+	}
+
+	// Update the info
+	err = dstObj.lstat()
+	if err != nil {
+		return nil, err
+	}
+
+	return dstObj, nil
+}
+
+// hardlink makes a new file at dstPath which is a hardlink to srcPath
+//
+// If the file system doesn't support hardlinks it returns
+// fs.ErrorCantLink
+func hardlink(srcPath, dstPath string) error {
+	err := os.Link(srcPath, dstPath)
+	if err != nil {
+		fs.Debugf(srcPath, "Can't hardlink: %v", err)
+		return fs.ErrorCantLink
+	}
+	return nil
+}
+
 // DirMove moves src, srcRemote to this remote at dstRemote
 // using server side move operations.
 //
@@ -826,6 +935,12 @@ func (o *Object) Hash(ctx context.Context, r hash.Type) (string, error) {
 		var in io.ReadCloser
 
 		if !o.translatedLink {
+			var release func()
+			_, release, err = openfiles.Reserve(ctx, 1)
+			if err != nil {
+				return "", err
+			}
+			defer release()
 			var fd *os.File
 			fd, err = file.Open(o.path)
 			if fd != nil {
@@ -916,8 +1031,10 @@ func (o *Object) Storable() bool {
 type localOpenFile struct {
 	o    *Object           // object that is open
 	in   io.ReadCloser     // handle we are wrapping
-	hash *hash.MultiHasher // currently accumulating hashes
+	hash *hash.MultiHasher // currently accumulating hashes or nil
 	fd   *os.File          // file object reference
+	size int64             // size of the file when it was opened
+	read int64             // bytes read so far
 }
 
 // Read bytes from the object - see io.Reader
@@ -932,6 +1049,9 @@ func (file *localOpenFile) Read(p []byte) (n int, err error) {
 		oldtime := file.o.modTime
 		oldsize := file.o.size
 		file.o.fs.objectMetaMu.RUnlock()
+		if fi.Size() < oldsize {
+			return 0, file.truncated(fi.Size())
+		}
 		if oldsize != fi.Size() {
 			return 0, fserrors.NoLowLevelRetryError(errors.Errorf("can't copy - source file is being updated (size changed from %d to %d)", oldsize, fi.Size()))
 		}
@@ -941,17 +1061,47 @@ func (file *localOpenFile) Read(p []byte) (n int, err error) {
 	}
 
 	n, err = file.in.Read(p)
-	if n > 0 {
+	file.read += int64(n)
+	if n > 0 && file.hash != nil {
 		// Hash routines never return an error
 		_, _ = file.hash.Write(p[:n])
 	}
+	if err == io.EOF && file.read < file.size {
+		// The file got shorter after it was opened. If it grew
+		// instead then this is an append and we only read the size
+		// it had when it was opened.
+		fi, statErr := file.fd.Stat()
+		if statErr != nil {
+			return n, errors.Wrap(statErr, "can't read status of source file while transferring")
+		}
+		return n, file.truncated(fi.Size())
+	}
 	return
 }
 
+// truncated returns the error for the file being truncated to size
+// while it was being read.
+//
+// The metadata of the object is updated regardless of
+// --local-no-check-updated so it can be transferred again with the
+// size it has now.
+func (file *localOpenFile) truncated(size int64) error {
+	o := file.o
+	if info, err := o.fs.lstat(o.path); err == nil {
+		o.fs.objectMetaMu.Lock()
+		o.size = info.Size()
+		o.modTime = info.ModTime()
+		o.mode = info.Mode()
+		o.hashes = nil
+		o.fs.objectMetaMu.Unlock()
+	}
+	return fserrors.NoLowLevelRetryError(errors.Wrapf(fs.ErrorSourceTruncated, "can't copy - read %d of %d bytes and size is now %d", file.read, file.size, size))
+}
+
 // Close the object and update the hashes
 func (file *localOpenFile) Close() (err error) {
 	err = file.in.Close()
-	if err == nil {
+	if err == nil && file.hash != nil {
 		if file.hash.Size() == file.o.Size() {
 			file.o.fs.objectMetaMu.Lock()
 			file.o.hashes = file.hash.Sums()
@@ -1007,31 +1157,54 @@ func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (in io.Read
 		return o.openTranslatedLink(offset, limit)
 	}
 
+	_, release, err := openfiles.Reserve(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
 	fd, err := file.Open(o.path)
 	if err != nil {
+		release()
 		return
 	}
-	wrappedFd := readers.NewLimitedReadCloser(newFadviseReadCloser(o, fd, offset, limit), limit)
+	wrappedFd := &releaseReadCloser{
+		ReadCloser: readers.NewLimitedReadCloser(newFadviseReadCloser(o, fd, offset, limit), limit),
+		release:    release,
+	}
 	if offset != 0 {
 		// seek the object
 		_, err = fd.Seek(offset, io.SeekStart)
 		// don't attempt to make checksums
 		return wrappedFd, err
 	}
-	if hasher == nil {
-		// no need to wrap since we don't need checksums
+	if limit >= 0 && limit != o.size {
+		// no need to wrap since we don't read the whole file
 		return wrappedFd, nil
 	}
-	// Update the hashes as we go along
+	// Update the hashes and check the file isn't truncated as we go along
 	in = &localOpenFile{
 		o:    o,
 		in:   wrappedFd,
 		hash: hasher,
 		fd:   fd,
+		size: o.Size(),
 	}
 	return in, nil
 }
 
+// releaseReadCloser releases the file handle reserved for the file
+// it is reading when it is closed
+type releaseReadCloser struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close the file and release its file handle
+func (rc *releaseReadCloser) Close() error {
+	err := rc.ReadCloser.Close()
+	rc.release()
+	return err
+}
+
 // mkdirAll makes all the directories needed to store the object
 func (o *Object) mkdirAll() error {
 	dir := filepath.Dir(o.path)
@@ -1074,6 +1247,11 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	// If it is a translated link, just read in the contents, and
 	// then create a symlink
 	if !o.translatedLink {
+		_, release, err := openfiles.Reserve(ctx, 1)
+		if err != nil {
+			return err
+		}
+		defer release()
 		f, err := file.OpenFile(o.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
 		if err != nil {
 			if runtime.GOOS == "windows" && os.IsPermission(err) {
@@ -1153,6 +1331,59 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	return o.lstat()
 }
 
+// Append adds the data from in to the end of the object, which must
+// be offset bytes long, then sets the modification time from src.
+func (o *Object) Append(ctx context.Context, in io.Reader, offset int64, src fs.ObjectInfo, options ...fs.OpenOption) (err error) {
+	if o.translatedLink {
+		return errors.New("can't append to a translated link")
+	}
+	_, release, err := openfiles.Reserve(ctx, 1)
+	if err != nil {
+		return err
+	}
+	defer release()
+	f, err := file.OpenFile(o.path, os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	if info.Size() != offset {
+		_ = f.Close()
+		return errors.Wrapf(fs.ErrorAppendConflict, "expecting size %d but it is %d", offset, info.Size())
+	}
+
+	_, err = io.Copy(f, in)
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fs.Logf(o, "Removing partially appended data on error: %v", err)
+		if truncateErr := os.Truncate(o.path, offset); truncateErr != nil {
+			fs.Errorf(o, "Failed to remove partially appended data: %v", truncateErr)
+		}
+		return err
+	}
+
+	// The hashes will need calculating again
+	o.fs.objectMetaMu.Lock()
+	o.hashes = nil
+	o.fs.objectMetaMu.Unlock()
+
+	// Set the mtime
+	err = o.SetModTime(ctx, src.ModTime(ctx))
+	if err != nil {
+		return err
+	}
+
+	// ReRead info now that we have finished
+	return o.lstat()
+}
+
 var sparseWarning sync.Once
 
 // OpenWriterAt opens with a handle for random access writes
@@ -1196,6 +1427,29 @@ func (f *Fs) OpenWriterAt(ctx context.Context, remote string, size int64) (fs.Wr
 	return out, nil
 }
 
+// ResumeWriterAt opens the file at remote made by OpenWriterAt for
+// random writing without truncating it
+func (f *Fs) ResumeWriterAt(ctx context.Context, remote string, size int64) (fs.WriterAtCloser, error) {
+	o := f.newObject(remote)
+	if o.translatedLink {
+		return nil, errors.New("can't open a symlink for random writing")
+	}
+	out, err := file.OpenFile(o.path, os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := out.Stat()
+	if err != nil {
+		_ = out.Close()
+		return nil, err
+	}
+	if fi.Size() != size {
+		_ = out.Close()
+		return nil, errors.Errorf("can't resume writing as file is %d bytes not %d", fi.Size(), size)
+	}
+	return out, nil
+}
+
 // setMetadata sets the file info from the os.FileInfo passed in
 func (o *Object) setMetadata(info os.FileInfo) {
 	// if not checking updated then don't update the stat
@@ -1254,12 +1508,15 @@ func cleanRootPath(s string, noUNC bool, enc encoder.MultiEncoder) string {
 
 // Check the interfaces are satisfied
 var (
-	_ fs.Fs             = &Fs{}
-	_ fs.Purger         = &Fs{}
-	_ fs.PutStreamer    = &Fs{}
-	_ fs.Mover          = &Fs{}
-	_ fs.DirMover       = &Fs{}
-	_ fs.Commander      = &Fs{}
-	_ fs.OpenWriterAter = &Fs{}
-	_ fs.Object         = &Object{}
+	_ fs.Fs               = &Fs{}
+	_ fs.Purger           = &Fs{}
+	_ fs.PutStreamer      = &Fs{}
+	_ fs.Mover            = &Fs{}
+	_ fs.DirMover         = &Fs{}
+	_ fs.Linker           = &Fs{}
+	_ fs.Commander        = &Fs{}
+	_ fs.OpenWriterAter   = &Fs{}
+	_ fs.ResumeWriterAter = &Fs{}
+	_ fs.Object           = &Object{}
+	_ fs.Appender         = &Object{}
 )
@@ -2,6 +2,7 @@ package local
 
 import (
 	"context"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
@@ -10,10 +11,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/fserrors"
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/fstest/mockobject"
 	"github.com/rclone/rclone/lib/file"
 	"github.com/rclone/rclone/lib/readers"
 	"github.com/stretchr/testify/assert"
@@ -70,6 +74,57 @@ func TestUpdatingCheck(t *testing.T) {
 
 }
 
+// Test reading a source file which is truncated or appended to
+func TestTruncatedCheck(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	f := r.Flocal.(*Fs)
+	oldNoCheckUpdated := f.opt.NoCheckUpdated
+	defer func() { f.opt.NoCheckUpdated = oldNoCheckUpdated }()
+	filePath := "truncated"
+	localPath := filepath.Join(r.LocalName, filePath)
+
+	open := func() (fs.Object, io.ReadCloser) {
+		r.WriteFile(filePath, "0123456789", time.Now())
+		o, err := f.NewObject(ctx, filePath)
+		require.NoError(t, err)
+		in, err := o.Open(ctx)
+		require.NoError(t, err)
+		buf := make([]byte, 5)
+		_, err = io.ReadFull(in, buf)
+		require.NoError(t, err)
+		return o, in
+	}
+
+	for _, noCheckUpdated := range []bool{false, true} {
+		f.opt.NoCheckUpdated = noCheckUpdated
+		o, in := open()
+		require.NoError(t, os.Truncate(localPath, 7))
+		_, err := ioutil.ReadAll(in)
+		require.Error(t, err, noCheckUpdated)
+		assert.Equal(t, fs.ErrorSourceTruncated, errors.Cause(err), noCheckUpdated)
+		assert.Contains(t, err.Error(), "of 10 bytes and size is now 7", noCheckUpdated)
+		assert.True(t, fserrors.IsNoLowLevelRetryError(err), noCheckUpdated)
+		require.NoError(t, in.Close())
+		assert.Equal(t, int64(7), o.Size(), noCheckUpdated)
+	}
+
+	// Appending isn't truncation and with --local-no-check-updated
+	// only the size the file had when opened is read
+	f.opt.NoCheckUpdated = true
+	_, in := open()
+	fd, err := os.OpenFile(localPath, os.O_APPEND|os.O_WRONLY, 0666)
+	require.NoError(t, err)
+	_, err = fd.WriteString("appended")
+	require.NoError(t, err)
+	require.NoError(t, fd.Close())
+	rest, err := ioutil.ReadAll(in)
+	require.NoError(t, err)
+	assert.Equal(t, "56789", string(rest))
+	require.NoError(t, in.Close())
+}
+
 func TestSymlink(t *testing.T) {
 	ctx := context.Background()
 	r := fstest.NewRun(t)
@@ -175,3 +230,42 @@ func TestSymlinkError(t *testing.T) {
 	_, err := NewFs("local", "/", m)
 	assert.Equal(t, errLinksAndCopyLinks, err)
 }
+
+func TestLink(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	f := r.Flocal.(*Fs)
+
+	modTime1 := fstest.Time("2001-02-03T04:05:10.123123123Z")
+	file1 := r.WriteFile("file.txt", "hello", modTime1)
+	src, err := f.NewObject(ctx, "file.txt")
+	require.NoError(t, err)
+
+	// Hardlink into a new directory
+	o, err := f.Link(ctx, src, "sub/hardlink.txt", fs.LinkModeHardlink)
+	require.NoError(t, err)
+	assert.Equal(t, "sub/hardlink.txt", o.Remote())
+	file2 := fstest.NewItem("sub/hardlink.txt", "hello", modTime1)
+	fstest.CheckItems(t, r.Flocal, file1, file2)
+	fi1, err := os.Stat(filepath.Join(f.root, "file.txt"))
+	require.NoError(t, err)
+	fi2, err := os.Stat(filepath.Join(f.root, "sub", "hardlink.txt"))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(fi1, fi2))
+
+	// Replacing an existing file with auto works whether or
+	// not the file system supports reflinks
+	file3 := r.WriteFile("other.txt", "potato", modTime1)
+	_, err = f.Link(ctx, src, "other.txt", fs.LinkModeAuto)
+	require.NoError(t, err)
+	file3 = fstest.NewItem("other.txt", "hello", file3.ModTime)
+	fstest.CheckItems(t, r.Flocal, file1, file2, file3)
+
+	// Can't link to an object of another remote type
+	_, err = f.Link(ctx, mockobject.Object("file.txt"), "nope.txt", fs.LinkModeHardlink)
+	assert.Equal(t, fs.ErrorCantLink, err)
+	_, err = f.Link(ctx, src, "nope.txt", fs.LinkModeOff)
+	assert.Equal(t, fs.ErrorCantLink, err)
+	fstest.CheckItems(t, r.Flocal, file1, file2, file3)
+}
@@ -0,0 +1,38 @@
+//+build linux
+
+package local
+
+import (
+	"os"
+
+	"github.com/rclone/rclone/fs"
+	"golang.org/x/sys/unix"
+)
+
+// ficlone is the FICLONE ioctl which makes the file a reflink of
+// another so they share their data until one is modified
+const ficlone = 0x40049409
+
+// reflink makes a new file at dstPath which is a reflink of srcPath
+//
+// If the file system doesn't support reflinks it returns
+// fs.ErrorCantLink
+func reflink(srcPath, dstPath string) (err error) {
+	in, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(in, &err)
+	out, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	cloneErr := unix.IoctlSetInt(int(out.Fd()), ficlone, int(in.Fd()))
+	err = out.Close()
+	if cloneErr != nil {
+		_ = os.Remove(dstPath)
+		fs.Debugf(srcPath, "Can't reflink: %v", cloneErr)
+		return fs.ErrorCantLink
+	}
+	return err
+}
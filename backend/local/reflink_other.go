@@ -0,0 +1,12 @@
+//+build !linux
+
+package local
+
+import "github.com/rclone/rclone/fs"
+
+// reflink makes a new file at dstPath which is a reflink of srcPath
+//
+// Reflinks aren't supported on this OS so it returns fs.ErrorCantLink
+func reflink(srcPath, dstPath string) error {
+	return fs.ErrorCantLink
+}
@@ -731,10 +731,10 @@ func (f *Fs) CreateDir(ctx context.Context, dirID, leaf string) (newID string, e
 		Name:             f.opt.Enc.FromStandardName(leaf),
 		ConflictBehavior: "fail",
 	}
-	err = f.pacer.Call(func() (bool, error) {
+	err = f.pacer.CallNonIdempotent(func() (bool, error) {
 		resp, err = f.srv.CallJSON(ctx, &opts, &mkdir, &info)
 		return shouldRetry(resp, err)
-	})
+	}, func(error) bool { return fserrors.IsServerError(resp) })
 	if err != nil {
 		//fmt.Printf("...Error %v\n", err)
 		return "", err
@@ -1696,7 +1696,7 @@ func (o *Object) uploadSinglepart(ctx context.Context, in io.Reader, size int64,
 		}
 	}
 
-	err = o.fs.pacer.Call(func() (bool, error) {
+	err = o.fs.pacer.CallNonIdempotent(func() (bool, error) {
 		resp, err = o.fs.srv.CallJSON(ctx, &opts, nil, &info)
 		if apiErr, ok := err.(*api.Error); ok {
 			if apiErr.ErrorInfo.Code == "nameAlreadyExists" {
@@ -1705,7 +1705,7 @@ func (o *Object) uploadSinglepart(ctx context.Context, in io.Reader, size int64,
 			}
 		}
 		return shouldRetry(resp, err)
-	})
+	}, func(error) bool { return fserrors.IsServerError(resp) })
 	if err != nil {
 		return nil, err
 	}
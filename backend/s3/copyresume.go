@@ -0,0 +1,173 @@
+package s3
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+)
+
+// copyResumePart is a part of a multipart server side copy which has
+// been copied
+type copyResumePart struct {
+	PartNumber int64  `json:"partNumber"`
+	ETag       string `json:"etag"`
+}
+
+// copyResumeState is the progress of a multipart server side copy
+// saved with --partial-cleanup resume so an interrupted copy can carry
+// on with the parts already copied instead of starting again.
+type copyResumeState struct {
+	SrcBucket string           `json:"srcBucket"` // bucket of the source
+	SrcPath   string           `json:"srcPath"`   // path of the source in the bucket
+	SrcSize   int64            `json:"srcSize"`   // size of the source
+	SrcETag   string           `json:"srcETag"`   // ETag of the source to spot it changing
+	PartSize  int64            `json:"partSize"`  // size of each part
+	UploadID  string           `json:"uploadId"`  // the multipart upload the parts are in
+	Parts     []copyResumePart `json:"parts"`     // the parts copied so far
+
+	mu   sync.Mutex
+	path string // where the state is saved
+}
+
+// copyResumePath returns the file the state of a multipart copy to
+// dstPath in dstBucket of the remote called name is saved in
+func copyResumePath(name, dstBucket, dstPath string) string {
+	sum := md5.Sum([]byte(name + ":" + dstBucket + "/" + dstPath))
+	return filepath.Join(config.CacheDir, "s3-copy", hex.EncodeToString(sum[:])+".json")
+}
+
+// newCopyResumeState makes the state for a new multipart copy saved
+// in path
+func newCopyResumeState(path, srcBucket, srcPath string, srcSize int64, srcETag string, partSize int64) *copyResumeState {
+	return &copyResumeState{
+		SrcBucket: srcBucket,
+		SrcPath:   srcPath,
+		SrcSize:   srcSize,
+		SrcETag:   srcETag,
+		PartSize:  partSize,
+		path:      path,
+	}
+}
+
+// load the saved state into s if it is for the same source and part
+// size, returning whether it was loaded
+func (s *copyResumeState) load() bool {
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fs.Debugf(nil, "Failed to read multipart copy state: %v", err)
+		}
+		return false
+	}
+	var old copyResumeState
+	err = json.Unmarshal(data, &old)
+	if err != nil {
+		fs.Debugf(nil, "Failed to parse multipart copy state: %v", err)
+		return false
+	}
+	if old.SrcBucket != s.SrcBucket || old.SrcPath != s.SrcPath || old.SrcSize != s.SrcSize || old.SrcETag != s.SrcETag {
+		fs.Debugf(nil, "Not resuming multipart copy as the source has changed")
+		return false
+	}
+	if old.PartSize != s.PartSize || old.UploadID == "" {
+		fs.Debugf(nil, "Not resuming multipart copy as the parts have changed")
+		return false
+	}
+	s.mu.Lock()
+	s.UploadID = old.UploadID
+	s.Parts = old.Parts
+	s.mu.Unlock()
+	return true
+}
+
+// keepParts only keeps the parts copied which the server has with the
+// same ETag, returning how many are left
+func (s *copyResumeState) keepParts(etags map[int64]string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parts := s.Parts[:0]
+	for _, part := range s.Parts {
+		if etag, ok := etags[part.PartNumber]; ok && etag == part.ETag {
+			parts = append(parts, part)
+		}
+	}
+	s.Parts = parts
+	return len(parts)
+}
+
+// reset the state to start a new upload with uploadID
+func (s *copyResumeState) reset(uploadID string) {
+	s.mu.Lock()
+	s.UploadID = uploadID
+	s.Parts = nil
+	s.mu.Unlock()
+}
+
+// done returns the ETags of the parts already copied keyed by part
+// number
+func (s *copyResumeState) done() map[int64]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	etags := make(map[int64]string, len(s.Parts))
+	for _, part := range s.Parts {
+		etags[part.PartNumber] = part.ETag
+	}
+	return etags
+}
+
+// addPart records that partNumber has been copied with etag and
+// saves the state
+func (s *copyResumeState) addPart(partNumber int64, etag string) {
+	s.mu.Lock()
+	s.Parts = append(s.Parts, copyResumePart{PartNumber: partNumber, ETag: etag})
+	sort.Slice(s.Parts, func(i, j int) bool {
+		return s.Parts[i].PartNumber < s.Parts[j].PartNumber
+	})
+	s.mu.Unlock()
+	s.save()
+}
+
+// save the state, logging any errors
+func (s *copyResumeState) save() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.write()
+	if err != nil {
+		fs.Debugf(nil, "Failed to save multipart copy state: %v", err)
+	}
+}
+
+// write the state to its file - call with the lock held
+func (s *copyResumeState) write() error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(s.path), 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to make multipart copy state directory")
+	}
+	tmp := s.path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// remove the state as the copy has finished
+func (s *copyResumeState) remove() {
+	err := os.Remove(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		fs.Debugf(nil, "Failed to remove multipart copy state: %v", err)
+	}
+}
@@ -37,6 +37,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/corehandlers"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/endpointcreds"
 	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/defaults"
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
@@ -47,6 +48,7 @@ import (
 	"github.com/ncw/swift"
 	"github.com/pkg/errors"
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
 	"github.com/rclone/rclone/fs/config"
 	"github.com/rclone/rclone/fs/config/configmap"
 	"github.com/rclone/rclone/fs/config/configstruct"
@@ -857,6 +859,39 @@ to start uploading.`,
 			Name:     "session_token",
 			Help:     "An AWS session token",
 			Advanced: true,
+		}, {
+			Name: "credentials_url",
+			Help: `URL of an HTTP endpoint to get the credentials from.
+
+If set, rclone gets the credentials with a GET from this URL instead
+of using access_key_id and secret_access_key or env_auth. This is for
+metadata services and credential helpers like the ones on EC2 and
+ECS. The endpoint should return JSON like
+
+    {
+        "AccessKeyId": "ACCESS_KEY_ID",
+        "SecretAccessKey": "SECRET_ACCESS_KEY",
+        "Token": "SESSION_TOKEN",
+        "Expiration": "2020-06-01T18:00:00Z"
+    }
+
+"Token" and "Expiration" are optional. The credentials are cached
+until "credentials_expiry_window" before they expire and fetched
+again then, or never fetched again if there is no "Expiration".`,
+			Advanced: true,
+		}, {
+			Name:     "credentials_url_auth",
+			Help:     "Value of the Authorization header to send to the credentials_url, if any.",
+			Advanced: true,
+		}, {
+			Name: "credentials_expiry_window",
+			Help: `How long before they expire to refresh the credentials from the credentials_url.
+
+The credentials are fetched again this long before their
+"Expiration" so requests in flight don't fail with expired
+credentials.`,
+			Default:  fs.Duration(5 * time.Minute),
+			Advanced: true,
 		}, {
 			Name: "upload_concurrency",
 			Help: `Concurrency for multipart uploads.
@@ -962,6 +997,7 @@ const (
 	metaMD5Hash         = "Md5chksum"            // the meta key to store md5hash in
 	maxSizeForCopy      = 5 * 1024 * 1024 * 1024 // The maximum size of object we can COPY
 	maxUploadParts      = 10000                  // maximum allowed number of parts in a multi-part upload
+	maxSizeForUpload    = 5 * fs.TebiByte        // The maximum size of object we can upload
 	minChunkSize        = fs.SizeSuffix(1024 * 1024 * 5)
 	defaultUploadCutoff = fs.SizeSuffix(200 * 1024 * 1024)
 	maxUploadCutoff     = fs.SizeSuffix(5 * 1024 * 1024 * 1024)
@@ -995,6 +1031,9 @@ type Options struct {
 	MaxUploadParts        int64                `config:"max_upload_parts"`
 	DisableChecksum       bool                 `config:"disable_checksum"`
 	SessionToken          string               `config:"session_token"`
+	CredentialsURL        string               `config:"credentials_url"`
+	CredentialsURLAuth    string               `config:"credentials_url_auth"`
+	CredentialsExpiry     fs.Duration          `config:"credentials_expiry_window"`
 	UploadConcurrency     int                  `config:"upload_concurrency"`
 	ForcePathStyle        bool                 `config:"force_path_style"`
 	V2Auth                bool                 `config:"v2_auth"`
@@ -1121,8 +1160,47 @@ func (f *Fs) split(rootRelativePath string) (bucketName, bucketPath string) {
 }
 
 // split returns bucket and bucketPath from the object
+//
+// The trailing "/" of a directory marker is kept.
 func (o *Object) split() (bucket, bucketPath string) {
-	return o.fs.split(o.remote)
+	bucket, bucketPath = o.fs.split(o.remote)
+	if bucketPath != "" && strings.HasSuffix(o.remote, "/") {
+		bucketPath += "/"
+	}
+	return bucket, bucketPath
+}
+
+// urlCredsProvider gets the credentials from the credentials_url
+type urlCredsProvider struct {
+	credentials.Provider
+	url string
+}
+
+// newURLCredsProvider makes a credentials provider which gets the
+// credentials from opt.CredentialsURL
+func newURLCredsProvider(opt *Options, handlers request.Handlers) credentials.Provider {
+	cfg := aws.NewConfig().WithHTTPClient(fshttp.NewClient(fs.Config))
+	return &urlCredsProvider{
+		Provider: endpointcreds.NewProviderClient(*cfg, handlers, opt.CredentialsURL, func(p *endpointcreds.Provider) {
+			p.ExpiryWindow = time.Duration(opt.CredentialsExpiry)
+			p.AuthorizationToken = opt.CredentialsURLAuth
+		}),
+		url: opt.CredentialsURL,
+	}
+}
+
+// Retrieve gets the credentials from the url
+//
+// This is only called when the cached credentials have expired.
+func (p *urlCredsProvider) Retrieve() (credentials.Value, error) {
+	v, err := p.Provider.Retrieve()
+	if err != nil {
+		err = errors.Wrapf(err, "failed to get credentials from credentials_url %q", p.url)
+		fs.Errorf(nil, "s3: %v", err)
+		return v, err
+	}
+	fs.Debugf(nil, "s3: got credentials from credentials_url %q", p.url)
+	return v, nil
 }
 
 // s3Connection makes a connection to s3
@@ -1177,6 +1255,8 @@ func s3Connection(opt *Options) (*s3.S3, *session.Session, error) {
 	cred := credentials.NewChainCredentials(providers)
 
 	switch {
+	case opt.CredentialsURL != "":
+		cred = credentials.NewCredentials(newURLCredsProvider(opt, def.Handlers))
 	case opt.EnvAuth:
 		// No need for empty checks if "env_auth" is true
 	case v.AccessKeyID == "" && v.SecretAccessKey == "":
@@ -1219,7 +1299,7 @@ func s3Connection(opt *Options) (*s3.S3, *session.Session, error) {
 	awsSessionOpts := session.Options{
 		Config: *awsConfig,
 	}
-	if opt.EnvAuth && opt.AccessKeyID == "" && opt.SecretAccessKey == "" {
+	if opt.EnvAuth && opt.CredentialsURL == "" && opt.AccessKeyID == "" && opt.SecretAccessKey == "" {
 		// Enable loading config options from ~/.aws/config (selected by AWS_PROFILE env)
 		awsSessionOpts.SharedConfigState = session.SharedConfigEnable
 		// The session constructor (aws/session/mergeConfigSrcs) will only use the user's preferred credential source
@@ -1395,6 +1475,23 @@ func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
 	return f.newObjectWithInfo(ctx, remote, nil)
 }
 
+// DirMarker returns the directory marker object of dir or
+// fs.ErrorObjectNotFound if it hasn't got one
+func (f *Fs) DirMarker(ctx context.Context, dir string) (fs.Object, error) {
+	bucket, directory := f.split(dir)
+	if bucket == "" || directory == "" {
+		return nil, fs.ErrorObjectNotFound
+	}
+	o, err := f.newObjectWithInfo(ctx, strings.TrimSuffix(dir, "/")+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	if o.Size() != 0 {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return o, nil
+}
+
 // Gets the bucket location
 func (f *Fs) getBucketLocation(bucket string) (string, error) {
 	req := s3.GetBucketLocationInput{
@@ -1560,15 +1657,15 @@ func (f *Fs) list(ctx context.Context, bucket, directory, prefix string, addBuck
 			}
 		}
 		for _, object := range resp.Contents {
-			remote := aws.StringValue(object.Key)
+			key := aws.StringValue(object.Key)
 			if urlEncodeListings {
-				remote, err = url.QueryUnescape(remote)
+				key, err = url.QueryUnescape(key)
 				if err != nil {
 					fs.Logf(f, "failed to URL decode %q in listing: %v", aws.StringValue(object.Key), err)
 					continue
 				}
 			}
-			remote = f.opt.Enc.ToStandardPath(remote)
+			remote := f.opt.Enc.ToStandardPath(key)
 			if !strings.HasPrefix(remote, prefix) {
 				fs.Logf(f, "Odd name received %q", remote)
 				continue
@@ -1609,6 +1706,33 @@ func (f *Fs) list(ctx context.Context, bucket, directory, prefix string, addBuck
 	return nil
 }
 
+// createDirMarker makes the directory marker object key
+func (f *Fs) createDirMarker(ctx context.Context, bucket, key string) error {
+	req := s3.PutObjectInput{
+		Bucket:        &bucket,
+		Key:           &key,
+		ACL:           &f.opt.ACL,
+		Body:          bytes.NewReader(nil),
+		ContentLength: aws.Int64(0),
+	}
+	return f.pacer.Call(func() (bool, error) {
+		_, err := f.c.PutObjectWithContext(ctx, &req)
+		return f.shouldRetry(err)
+	})
+}
+
+// deleteDirMarker deletes the directory marker object key
+func (f *Fs) deleteDirMarker(ctx context.Context, bucket, key string) error {
+	req := s3.DeleteObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	}
+	return f.pacer.Call(func() (bool, error) {
+		_, err := f.c.DeleteObjectWithContext(ctx, &req)
+		return f.shouldRetry(err)
+	})
+}
+
 // Convert a list item into a DirEntry
 func (f *Fs) itemToDirEntry(ctx context.Context, remote string, object *s3.Object, isDirectory bool) (fs.DirEntry, error) {
 	if isDirectory {
@@ -1782,9 +1906,22 @@ func (f *Fs) bucketExists(ctx context.Context, bucket string) (bool, error) {
 }
 
 // Mkdir creates the bucket if it doesn't exist
+//
+// If --handle-dir-markers is create it also makes a directory marker
+// for a directory in a bucket.
 func (f *Fs) Mkdir(ctx context.Context, dir string) error {
-	bucket, _ := f.split(dir)
-	return f.makeBucket(ctx, bucket)
+	bucket, directory := f.split(dir)
+	err := f.makeBucket(ctx, bucket)
+	if err != nil || directory == "" || fs.Config.DirMarkers != fs.DirMarkersCreate {
+		return err
+	}
+	// Make a directory marker so the directory exists even if empty
+	err = f.createDirMarker(ctx, bucket, directory+"/")
+	if err != nil {
+		return errors.Wrap(err, "failed to create directory marker")
+	}
+	accounting.Stats(ctx).DirMarkers(1)
+	return nil
 }
 
 // makeBucket creates the bucket if it doesn't exist
@@ -1819,9 +1956,16 @@ func (f *Fs) makeBucket(ctx context.Context, bucket string) error {
 
 // Rmdir deletes the bucket if the fs is at the root
 //
+// If --handle-dir-markers is create or delete it deletes the
+// directory marker of a directory in a bucket.
+//
 // Returns an error if it isn't empty
 func (f *Fs) Rmdir(ctx context.Context, dir string) error {
 	bucket, directory := f.split(dir)
+	if bucket != "" && directory != "" && fs.Config.DirMarkers != fs.DirMarkersSkip {
+		// Remove any directory marker so the directory goes
+		return f.deleteDirMarker(ctx, bucket, directory+"/")
+	}
 	if bucket == "" || directory != "" {
 		return nil
 	}
@@ -1845,6 +1989,12 @@ func (f *Fs) Precision() time.Duration {
 	return time.Nanosecond
 }
 
+// MaxObjectSize returns the size of the largest object which can be
+// uploaded
+func (f *Fs) MaxObjectSize(ctx context.Context) int64 {
+	return int64(maxSizeForUpload)
+}
+
 // pathEscape escapes s as for a URL path.  It uses rest.URLPathEscape
 // but also escapes '+' for S3 and Digital Ocean spaces compatibility
 func pathEscape(s string) string {
@@ -1893,21 +2043,102 @@ func calculateRange(partSize, partIndex, numParts, totalSize int64) string {
 	return fmt.Sprintf("bytes=%v-%v", start, ends)
 }
 
-func (f *Fs) copyMultipart(ctx context.Context, req *s3.CopyObjectInput, dstBucket, dstPath, srcBucket, srcPath string, srcSize int64) (err error) {
-	var cout *s3.CreateMultipartUploadOutput
-	if err := f.pacer.Call(func() (bool, error) {
+// resumeCopyState returns the state of an interrupted multipart copy
+// to carry on with if --partial-cleanup resume is set, or nil if
+// it isn't.
+//
+// If there is no copy to resume then the state is returned without an
+// UploadID.
+func (f *Fs) resumeCopyState(ctx context.Context, req *s3.CopyObjectInput, dstBucket, dstPath, srcBucket, srcPath string, srcSize, partSize int64) *copyResumeState {
+	if fs.Config.PartialCleanup != fs.PartialCleanupResume {
+		return nil
+	}
+	// Read the ETag of the source to spot it changing
+	var head *s3.HeadObjectOutput
+	err := f.pacer.Call(func() (bool, error) {
 		var err error
-		cout, err = f.c.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
-			Bucket: &dstBucket,
-			Key:    &dstPath,
+		head, err = f.c.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+			Bucket:       &srcBucket,
+			Key:          &srcPath,
+			RequestPayer: req.RequestPayer,
 		})
 		return f.shouldRetry(err)
-	}); err != nil {
-		return err
+	})
+	if err != nil {
+		fs.Debugf(nil, "Can't make multipart copy resumable: failed to read source: %v", err)
+		return nil
+	}
+	state := newCopyResumeState(copyResumePath(f.name, dstBucket, dstPath), srcBucket, srcPath, srcSize, aws.StringValue(head.ETag), partSize)
+	if !state.load() {
+		return state
+	}
+	// Check the upload is still there and which parts it has
+	etags := map[int64]string{}
+	err = f.pacer.Call(func() (bool, error) {
+		err := f.c.ListPartsPagesWithContext(ctx, &s3.ListPartsInput{
+			Bucket:       &dstBucket,
+			Key:          &dstPath,
+			UploadId:     &state.UploadID,
+			RequestPayer: req.RequestPayer,
+		}, func(page *s3.ListPartsOutput, lastPage bool) bool {
+			for _, part := range page.Parts {
+				etags[aws.Int64Value(part.PartNumber)] = aws.StringValue(part.ETag)
+			}
+			return true
+		})
+		return f.shouldRetry(err)
+	})
+	if err != nil {
+		fs.Debugf(nil, "Not resuming multipart copy: failed to list parts: %v", err)
+		state.reset("")
+		return state
+	}
+	state.keepParts(etags)
+	return state
+}
+
+func (f *Fs) copyMultipart(ctx context.Context, req *s3.CopyObjectInput, dstBucket, dstPath, srcBucket, srcPath string, srcSize int64) (err error) {
+	partSize := int64(f.opt.CopyCutoff)
+	numParts := (srcSize-1)/partSize + 1
+
+	// With --partial-cleanup resume carry on from an interrupted copy
+	state := f.resumeCopyState(ctx, req, dstBucket, dstPath, srcBucket, srcPath, srcSize, partSize)
+	var uid *string
+	done := map[int64]string{}
+	resumed := state != nil && state.UploadID != ""
+	if resumed {
+		uid = aws.String(state.UploadID)
+		done = state.done()
+		fs.Infof(nil, "Resuming multipart copy of %q with %d/%d parts already copied", dstPath, len(done), numParts)
+	} else {
+		var cout *s3.CreateMultipartUploadOutput
+		if err := f.pacer.Call(func() (bool, error) {
+			var err error
+			cout, err = f.c.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+				Bucket: &dstBucket,
+				Key:    &dstPath,
+			})
+			return f.shouldRetry(err)
+		}); err != nil {
+			return err
+		}
+		uid = cout.UploadId
+		if state != nil {
+			state.reset(aws.StringValue(uid))
+			state.save()
+		}
+	}
+	if state != nil {
+		accounting.Stats(ctx).ResumableCopy(resumed)
 	}
-	uid := cout.UploadId
 
 	defer atexit.OnError(&err, func() {
+		if state != nil {
+			// Keep the upload to resume from next time
+			fs.Debugf(nil, "Keeping multipart copy to resume from as --partial-cleanup is %v", fs.Config.PartialCleanup)
+			state.save()
+			return
+		}
 		// Try to abort the upload, but ignore the error.
 		fs.Debugf(nil, "Cancelling multipart copy")
 		_ = f.pacer.Call(func() (bool, error) {
@@ -1921,11 +2152,15 @@ func (f *Fs) copyMultipart(ctx context.Context, req *s3.CopyObjectInput, dstBuck
 		})
 	})()
 
-	partSize := int64(f.opt.CopyCutoff)
-	numParts := (srcSize-1)/partSize + 1
-
 	var parts []*s3.CompletedPart
 	for partNum := int64(1); partNum <= numParts; partNum++ {
+		if etag, ok := done[partNum]; ok {
+			parts = append(parts, &s3.CompletedPart{
+				PartNumber: aws.Int64(partNum),
+				ETag:       aws.String(etag),
+			})
+			continue
+		}
 		if err := f.pacer.Call(func() (bool, error) {
 			partNum := partNum
 			uploadPartReq := &s3.UploadPartCopyInput{
@@ -1956,13 +2191,16 @@ func (f *Fs) copyMultipart(ctx context.Context, req *s3.CopyObjectInput, dstBuck
 				PartNumber: &partNum,
 				ETag:       uout.CopyPartResult.ETag,
 			})
+			if state != nil {
+				state.addPart(partNum, aws.StringValue(uout.CopyPartResult.ETag))
+			}
 			return false, nil
 		}); err != nil {
 			return err
 		}
 	}
 
-	return f.pacer.Call(func() (bool, error) {
+	err = f.pacer.Call(func() (bool, error) {
 		_, err := f.c.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
 			Bucket: &dstBucket,
 			Key:    &dstPath,
@@ -1974,6 +2212,10 @@ func (f *Fs) copyMultipart(ctx context.Context, req *s3.CopyObjectInput, dstBuck
 		})
 		return f.shouldRetry(err)
 	})
+	if err == nil && state != nil {
+		state.remove()
+	}
+	return err
 }
 
 // Copy src to this remote using server side copy operations.
@@ -2388,7 +2630,7 @@ func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, si
 	if concurrency < 1 {
 		concurrency = 1
 	}
-	tokens := pacer.NewTokenDispenser(concurrency)
+	tokens := fs.NewChunkUpload(concurrency)
 
 	uploadParts := f.opt.MaxUploadParts
 	if uploadParts < 1 {
@@ -2497,6 +2739,12 @@ func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, si
 		g.Go(func() (err error) {
 			defer free()
 			partLength := int64(len(buf))
+			_, span := accounting.StartSpan(gCtx, "chunk")
+			span.SetAttribute("rclone.part", partNum)
+			span.SetAttribute("rclone.size", partLength)
+			defer func() {
+				span.End(err)
+			}()
 
 			// create checksum of buffer for integrity checking
 			md5sumBinary := md5.Sum(buf)
@@ -2777,15 +3025,175 @@ func (o *Object) GetTier() string {
 	return o.storageClass
 }
 
+// s3ACLGroups maps the URIs of the S3 groups to the grantees which
+// mean the same on any backend
+var s3ACLGroups = map[string]string{
+	"http://acs.amazonaws.com/groups/global/AllUsers":           fs.ACLGranteeAllUsers,
+	"http://acs.amazonaws.com/groups/global/AuthenticatedUsers": fs.ACLGranteeAuthenticatedUsers,
+}
+
+// s3ACLPermissions maps the S3 permissions to the ACL permissions
+var s3ACLPermissions = map[string]string{
+	s3.PermissionRead:        fs.ACLPermissionRead,
+	s3.PermissionWrite:       fs.ACLPermissionWrite,
+	s3.PermissionReadAcp:     fs.ACLPermissionReadACL,
+	s3.PermissionWriteAcp:    fs.ACLPermissionWriteACL,
+	s3.PermissionFullControl: fs.ACLPermissionFullControl,
+}
+
+// s3ToACL converts the S3 grants of an object owned by owner into an
+// ACL
+func s3ToACL(owner *s3.Owner, grants []*s3.Grant) (acl fs.ACL) {
+	ownerID := ""
+	if owner != nil {
+		ownerID = aws.StringValue(owner.ID)
+	}
+	for _, grant := range grants {
+		if grant == nil || grant.Grantee == nil {
+			continue
+		}
+		g := fs.ACLGrant{
+			Permission: s3ACLPermissions[aws.StringValue(grant.Permission)],
+		}
+		if g.Permission == "" {
+			g.Permission = "s3:" + aws.StringValue(grant.Permission)
+		}
+		grantee := grant.Grantee
+		switch aws.StringValue(grantee.Type) {
+		case s3.TypeCanonicalUser:
+			if id := aws.StringValue(grantee.ID); id == ownerID {
+				g.Grantee = fs.ACLGranteeOwner
+			} else {
+				g.Grantee = "id:" + id
+			}
+		case s3.TypeAmazonCustomerByEmail:
+			g.Grantee = "email:" + aws.StringValue(grantee.EmailAddress)
+		case s3.TypeGroup:
+			uri := aws.StringValue(grantee.URI)
+			g.Grantee = s3ACLGroups[uri]
+			if g.Grantee == "" {
+				g.Grantee = "uri:" + uri
+			}
+		default:
+			g.Grantee = "type:" + aws.StringValue(grantee.Type)
+		}
+		acl = append(acl, g)
+	}
+	return acl
+}
+
+// reverseLookup returns the key of m with value or "" if not found
+func reverseLookup(m map[string]string, value string) string {
+	for k, v := range m {
+		if v == value {
+			return k
+		}
+	}
+	return ""
+}
+
+// aclToS3 converts acl into the S3 grants for an object owned by
+// owner
+func aclToS3(owner *s3.Owner, acl fs.ACL) (grants []*s3.Grant, err error) {
+	for _, g := range acl {
+		permission := reverseLookup(s3ACLPermissions, g.Permission)
+		if permission == "" {
+			return nil, errors.Wrapf(fs.ErrorACLUnrepresentable, "unknown permission %q", g.Permission)
+		}
+		grantee := &s3.Grantee{}
+		switch groupURI := reverseLookup(s3ACLGroups, g.Grantee); {
+		case groupURI != "":
+			grantee.Type, grantee.URI = aws.String(s3.TypeGroup), aws.String(groupURI)
+		case g.Grantee == fs.ACLGranteeOwner && owner != nil:
+			grantee.Type, grantee.ID = aws.String(s3.TypeCanonicalUser), owner.ID
+		case strings.HasPrefix(g.Grantee, "id:"):
+			grantee.Type, grantee.ID = aws.String(s3.TypeCanonicalUser), aws.String(g.Grantee[3:])
+		case strings.HasPrefix(g.Grantee, "email:"):
+			grantee.Type, grantee.EmailAddress = aws.String(s3.TypeAmazonCustomerByEmail), aws.String(g.Grantee[6:])
+		case strings.HasPrefix(g.Grantee, "uri:"):
+			grantee.Type, grantee.URI = aws.String(s3.TypeGroup), aws.String(g.Grantee[4:])
+		default:
+			return nil, errors.Wrapf(fs.ErrorACLUnrepresentable, "unknown grantee %q", g.Grantee)
+		}
+		grants = append(grants, &s3.Grant{
+			Grantee:    grantee,
+			Permission: aws.String(permission),
+		})
+	}
+	return grants, nil
+}
+
+// getACL reads the owner and grants of the object
+func (o *Object) getACL(ctx context.Context) (resp *s3.GetObjectAclOutput, err error) {
+	bucket, bucketPath := o.split()
+	req := s3.GetObjectAclInput{
+		Bucket: &bucket,
+		Key:    &bucketPath,
+	}
+	err = o.fs.pacer.Call(func() (bool, error) {
+		var err error
+		resp, err = o.fs.c.GetObjectAclWithContext(ctx, &req)
+		return o.fs.shouldRetry(err)
+	})
+	return resp, err
+}
+
+// ACL returns the access control list of the object
+func (o *Object) ACL(ctx context.Context) (fs.ACL, error) {
+	resp, err := o.getACL(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s3ToACL(resp.Owner, resp.Grants), nil
+}
+
+// SetACL replaces the access control list of the object with acl
+//
+// The grants to the owner are given to the owner of this object.
+func (o *Object) SetACL(ctx context.Context, acl fs.ACL) error {
+	resp, err := o.getACL(ctx)
+	if err != nil {
+		return err
+	}
+	grants, err := aclToS3(resp.Owner, acl)
+	if err != nil {
+		return err
+	}
+	bucket, bucketPath := o.split()
+	req := s3.PutObjectAclInput{
+		Bucket: &bucket,
+		Key:    &bucketPath,
+		AccessControlPolicy: &s3.AccessControlPolicy{
+			Owner:  resp.Owner,
+			Grants: grants,
+		},
+	}
+	err = o.fs.pacer.Call(func() (bool, error) {
+		_, err := o.fs.c.PutObjectAclWithContext(ctx, &req)
+		return o.fs.shouldRetry(err)
+	})
+	if awsErr, ok := err.(awserr.Error); ok {
+		switch awsErr.Code() {
+		case "InvalidArgument", "UnresolvableGrantByEmailAddress":
+			// A grantee which doesn't exist on this provider
+			return errors.Wrap(fs.ErrorACLUnrepresentable, awsErr.Message())
+		}
+	}
+	return err
+}
+
 // Check the interfaces are satisfied
 var (
-	_ fs.Fs          = &Fs{}
-	_ fs.Copier      = &Fs{}
-	_ fs.PutStreamer = &Fs{}
-	_ fs.ListRer     = &Fs{}
-	_ fs.Commander   = &Fs{}
-	_ fs.Object      = &Object{}
-	_ fs.MimeTyper   = &Object{}
-	_ fs.GetTierer   = &Object{}
-	_ fs.SetTierer   = &Object{}
+	_ fs.Fs             = &Fs{}
+	_ fs.Copier         = &Fs{}
+	_ fs.PutStreamer    = &Fs{}
+	_ fs.ListRer        = &Fs{}
+	_ fs.DirMarkerer    = &Fs{}
+	_ fs.Commander      = &Fs{}
+	_ fs.MaxObjectSizer = &Fs{}
+	_ fs.Object         = &Object{}
+	_ fs.MimeTyper      = &Object{}
+	_ fs.GetTierer      = &Object{}
+	_ fs.ACLer          = &Object{}
+	_ fs.SetTierer      = &Object{}
 )
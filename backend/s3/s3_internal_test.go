@@ -0,0 +1,154 @@
+package s3
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLCredsProvider(t *testing.T) {
+	var calls int32
+	expiry := time.Now().Add(10 * time.Minute).UTC()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		assert.Equal(t, "Bearer potato", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+	"AccessKeyId": "AKID",
+	"SecretAccessKey": "SECRET",
+	"Token": "TOKEN",
+	"Expiration": "` + expiry.Format(time.RFC3339) + `"
+}`))
+	}))
+
+	opt := &Options{
+		CredentialsURL:     ts.URL,
+		CredentialsURLAuth: "Bearer potato",
+		CredentialsExpiry:  fs.Duration(5 * time.Minute),
+	}
+	cred := credentials.NewCredentials(newURLCredsProvider(opt, defaults.Handlers()))
+	v, err := cred.Get()
+	require.NoError(t, err)
+	assert.Equal(t, "AKID", v.AccessKeyID)
+	assert.Equal(t, "SECRET", v.SecretAccessKey)
+	assert.Equal(t, "TOKEN", v.SessionToken)
+
+	// Cached until the expiry window
+	_, err = cred.Get()
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.False(t, cred.IsExpired())
+
+	// Refreshed inside the expiry window
+	opt.CredentialsExpiry = fs.Duration(15 * time.Minute)
+	cred = credentials.NewCredentials(newURLCredsProvider(opt, defaults.Handlers()))
+	_, err = cred.Get()
+	require.NoError(t, err)
+	assert.True(t, cred.IsExpired())
+	_, err = cred.Get()
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// Fails clearly when the endpoint goes away
+	ts.Close()
+	_, err = cred.Get()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to get credentials from credentials_url")
+}
+
+func TestACLConversion(t *testing.T) {
+	owner := &s3.Owner{ID: aws.String("ownerID")}
+	group := func(uri string) *s3.Grantee {
+		return &s3.Grantee{Type: aws.String(s3.TypeGroup), URI: aws.String(uri)}
+	}
+	grants := []*s3.Grant{
+		{Grantee: &s3.Grantee{Type: aws.String(s3.TypeCanonicalUser), ID: aws.String("ownerID")}, Permission: aws.String(s3.PermissionFullControl)},
+		{Grantee: &s3.Grantee{Type: aws.String(s3.TypeCanonicalUser), ID: aws.String("otherID")}, Permission: aws.String(s3.PermissionRead)},
+		{Grantee: &s3.Grantee{Type: aws.String(s3.TypeAmazonCustomerByEmail), EmailAddress: aws.String("potato@example.com")}, Permission: aws.String(s3.PermissionWriteAcp)},
+		{Grantee: group("http://acs.amazonaws.com/groups/global/AllUsers"), Permission: aws.String(s3.PermissionRead)},
+		{Grantee: group("http://acs.amazonaws.com/groups/s3/LogDelivery"), Permission: aws.String(s3.PermissionWrite)},
+	}
+	acl := s3ToACL(owner, grants)
+	assert.Equal(t, fs.ACL{
+		{Grantee: fs.ACLGranteeOwner, Permission: fs.ACLPermissionFullControl},
+		{Grantee: "id:otherID", Permission: fs.ACLPermissionRead},
+		{Grantee: "email:potato@example.com", Permission: fs.ACLPermissionWriteACL},
+		{Grantee: fs.ACLGranteeAllUsers, Permission: fs.ACLPermissionRead},
+		{Grantee: "uri:http://acs.amazonaws.com/groups/s3/LogDelivery", Permission: fs.ACLPermissionWrite},
+	}, acl)
+
+	// The grants to the owner go to the owner of the destination
+	newOwner := &s3.Owner{ID: aws.String("newOwnerID")}
+	newGrants, err := aclToS3(newOwner, acl)
+	require.NoError(t, err)
+	assert.Equal(t, "newOwnerID", aws.StringValue(newGrants[0].Grantee.ID))
+	assert.Equal(t, grants[1:], newGrants[1:])
+	assert.Equal(t, acl, s3ToACL(newOwner, newGrants))
+
+	// Grants S3 can't represent are rejected
+	_, err = aclToS3(owner, fs.ACL{{Grantee: "potato", Permission: fs.ACLPermissionRead}})
+	assert.Equal(t, fs.ErrorACLUnrepresentable, errors.Cause(err))
+	_, err = aclToS3(owner, fs.ACL{{Grantee: fs.ACLGranteeOwner, Permission: "potato"}})
+	assert.Equal(t, fs.ErrorACLUnrepresentable, errors.Cause(err))
+}
+
+func TestCopyResumeState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-s3-copy")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	oldCacheDir := config.CacheDir
+	config.CacheDir = dir
+	defer func() { config.CacheDir = oldCacheDir }()
+
+	path := copyResumePath("remote", "bucket", "dir/file")
+	assert.Equal(t, filepath.Join(dir, "s3-copy"), filepath.Dir(path))
+	assert.NotEqual(t, path, copyResumePath("other", "bucket", "dir/file"))
+	newState := func() *copyResumeState {
+		return newCopyResumeState(path, "srcbucket", "src", 100, `"etag"`, 10)
+	}
+
+	// Nothing to resume to start with
+	s := newState()
+	assert.False(t, s.load())
+
+	// The parts copied are saved as they go
+	s.reset("upload-1")
+	s.save()
+	s.addPart(2, "b")
+	s.addPart(1, "a")
+	s.addPart(3, "c")
+	loaded := newState()
+	require.True(t, loaded.load())
+	assert.Equal(t, "upload-1", loaded.UploadID)
+	assert.Equal(t, map[int64]string{1: "a", 2: "b", 3: "c"}, loaded.done())
+
+	// Only the parts the server has with the same ETag are kept
+	assert.Equal(t, 1, loaded.keepParts(map[int64]string{1: "a", 2: "changed"}))
+	assert.Equal(t, map[int64]string{1: "a"}, loaded.done())
+
+	// A changed source or part size isn't resumed
+	changed := newCopyResumeState(path, "srcbucket", "src", 100, `"other"`, 10)
+	assert.False(t, changed.load())
+	changed = newCopyResumeState(path, "srcbucket", "src", 100, `"etag"`, 20)
+	assert.False(t, changed.load())
+
+	// Once finished there is nothing to resume
+	s.remove()
+	assert.False(t, newState().load())
+	s.remove() // removing twice is harmless
+}
@@ -0,0 +1,56 @@
+package appendto
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "appendto source:path dest:path",
+	Short: `Append the new data in the source file to the dest file.`,
+	Long: `
+Brings the file dest:path up to date with the file source:path by
+appending only the part of the source which is past the end of the
+destination.  This is for files which only ever grow, such as log
+files, so they can be shipped without uploading all of them each time.
+
+    rclone appendto /var/log/app.log remote:logs/app.log
+
+If the destination doesn't exist the source is copied to it.
+
+The size of the destination is used as the offset the last append
+finished at, and only the data after it is read from the source,
+transferred and counted in the stats.
+
+This returns an error without changing the destination if
+
+  - the destination is bigger than the source, eg because the source
+    was truncated or rotated
+  - the destination changes size while appending, because something
+    else is writing to it
+
+The destination backend must support appending to objects, which only
+the local backend does at the moment.
+
+Note that the data already in the destination isn't checked against
+the source.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 2, command, args)
+		fsrc, srcFileName, fdst, dstFileName := cmd.NewFsSrcDstFiles(args)
+		cmd.Run(true, true, command, func() error {
+			if srcFileName == "" {
+				return errors.New("appendto only works on files")
+			}
+			return operations.AppendFile(context.Background(), fdst, fsrc, dstFileName, srcFileName)
+		})
+	},
+}
@@ -33,8 +33,8 @@ var commandDefinition = &cobra.Command{
 	Short: `Run a backend specific command.`,
 	Long: `
 This runs a backend specific command. The commands themselves (except
-for "help" and "features") are defined by the backends and you should
-see the backend docs for definitions.
+for "help", "features" and "clock-offset") are defined by the backends
+and you should see the backend docs for definitions.
 
 You can discover what commands a backend implements by using
 
@@ -47,6 +47,19 @@ for more info).
 
     rclone backend features remote:
 
+To help set [--modify-window](/docs/#modify-window-time) you can
+measure how far the clock of the remote is from the local clock with
+
+    rclone backend clock-offset remote:path
+
+This uploads a small test object to remote:path, reads back the
+modification time the remote gave it, then deletes it.  The "offset"
+is how many seconds the remote clock is ahead of the local clock, to
+within +/- "uncertainty" seconds.  This only works on backends which
+set the modification time of new objects from their own clock.  On
+backends which keep the modification time rclone uploads with, "known"
+is false and "reason" says why.
+
 Pass options to the backend command with -o. This should be key=value or key, eg:
 
     rclone backend stats remote:path stats -o format=json -o long
@@ -85,6 +98,8 @@ Note to run these commands on a running backend then see
 				return showHelp(fsInfo)
 			case "features":
 				out = operations.GetFsInfo(f)
+			case "clock-offset":
+				out, err = operations.ClockOffset(context.Background(), f)
 			default:
 				doCommand := f.Features().Command
 				if doCommand == nil {
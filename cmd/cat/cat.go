@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"time"
 
 	"github.com/rclone/rclone/cmd"
 	"github.com/rclone/rclone/fs/config/flags"
@@ -20,6 +21,9 @@ var (
 	offset  = int64(0)
 	count   = int64(-1)
 	discard = false
+	follow  = false
+
+	followInterval = 5 * time.Second
 )
 
 func init() {
@@ -30,6 +34,8 @@ func init() {
 	flags.Int64VarP(cmdFlags, &offset, "offset", "", offset, "Start printing at offset N (or from end if -ve).")
 	flags.Int64VarP(cmdFlags, &count, "count", "", count, "Only print N characters.")
 	flags.BoolVarP(cmdFlags, &discard, "discard", "", discard, "Discard the output instead of printing.")
+	flags.BoolVarP(cmdFlags, &follow, "follow", "", follow, "Keep printing data appended to the file.")
+	flags.DurationVarP(cmdFlags, &followInterval, "follow-interval", "", followInterval, "How often to check for new data with --follow.")
 }
 
 var commandDefinition = &cobra.Command{
@@ -54,6 +60,17 @@ Use the --head flag to print characters only at the start, --tail for
 the end and --offset and --count to print a section in the middle.
 Note that if offset is negative it will count from the end, so
 --offset -1 --count 1 is equivalent to --tail 1.
+
+Use the --follow flag to keep printing any data appended to a single
+file, like "tail -f". The file is checked every --follow-interval and
+any new data is read with a range request, so --bwlimit applies. This
+can be combined with --tail or --offset to choose where to start. If
+the file is truncated or rotated then it is followed again from the
+start. The file is seen as rotated if it disappears or the start of
+it changes, which is read again each time the file changes. Stop it
+with CTRL-C.
+
+    rclone cat --follow --tail 1000 remote:path/to/log.txt
 `,
 	Run: func(command *cobra.Command, args []string) {
 		usedOffset := offset != 0 || count >= 0
@@ -71,11 +88,24 @@ Note that if offset is negative it will count from the end, so
 			count = -1
 		}
 		cmd.CheckArgs(1, 1, command, args)
-		fsrc := cmd.NewFsSrc(args)
 		var w io.Writer = os.Stdout
 		if discard {
 			w = ioutil.Discard
 		}
+		if follow {
+			if count >= 0 {
+				log.Fatalf("Can't use --head or --count with --follow")
+			}
+			fsrc, fileName := cmd.NewFsFile(args[0])
+			if fileName == "" {
+				log.Fatalf("--follow needs a single file to follow")
+			}
+			cmd.Run(false, false, command, func() error {
+				return operations.CatFollow(context.Background(), fsrc, fileName, w, offset, followInterval)
+			})
+			return
+		}
+		fsrc := cmd.NewFsSrc(args)
 		cmd.Run(false, false, command, func() error {
 			return operations.Cat(context.Background(), fsrc, w, offset, count)
 		})
@@ -0,0 +1,107 @@
+package chunkstats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/lib/cdc"
+	"github.com/spf13/cobra"
+)
+
+var (
+	jsonOutput       = false
+	fingerprintsFile = ""
+	minSize          = fs.SizeSuffix(cdc.DefaultParams.MinSize)
+	avgSize          = fs.SizeSuffix(cdc.DefaultParams.AvgSize)
+	maxSize          = fs.SizeSuffix(cdc.DefaultParams.MaxSize)
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	flags.BoolVarP(cmdFlags, &jsonOutput, "json", "", jsonOutput, "format output as JSON")
+	flags.StringVarP(cmdFlags, &fingerprintsFile, "fingerprints", "", fingerprintsFile, "Write the fingerprint of each chunk to this file")
+	flags.FVarP(cmdFlags, &minSize, "min-chunk-size", "", "Minimum size of a chunk")
+	flags.FVarP(cmdFlags, &avgSize, "avg-chunk-size", "", "Average size of a chunk - must be a power of 2")
+	flags.FVarP(cmdFlags, &maxSize, "max-chunk-size", "", "Maximum size of a chunk")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "chunkstats remote:path",
+	Short: `Shows how much block level deduplication could save in remote:path.`,
+	Long: `
+Reads all the objects in the path, splits them into content defined
+chunks and fingerprints each chunk to show how much space block level
+deduplication of the chunks would save.
+
+The chunk boundaries are found with the FastCDC rolling hash so they
+depend only on the data, not where it is in the file.  This means data
+which is repeated within or between files, even at a different
+offset, gives the same chunks.  The chunks are the same on every run
+for the same chunk size flags.  Each chunk is fingerprinted with
+SHA-256.
+
+The chunk size can be set with --min-chunk-size, --avg-chunk-size and
+--max-chunk-size.  Smaller chunks find more duplicate data but there
+are more of them to keep track of.
+
+    $ rclone chunkstats remote:path
+    Total objects: 2
+    Total size: 5.722 MBytes (6000002 Bytes)
+    Chunks: 6 (4 unique)
+    Unique size: 3.203 MBytes (3358739 Bytes)
+    Dedup saving: 44.02%
+
+Use --fingerprints to write a line for each chunk to a file, with the
+SHA-256 of the chunk, its offset and length and the object it is in.
+
+Note that this reads all the data in the path.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		fsrc := cmd.NewFsSrc(args)
+		cmd.Run(false, true, command, func() (err error) {
+			params := cdc.Params{
+				MinSize: int(minSize),
+				AvgSize: int(avgSize),
+				MaxSize: int(maxSize),
+			}
+			var w io.Writer
+			if fingerprintsFile != "" {
+				out, err := os.Create(fingerprintsFile)
+				if err != nil {
+					return errors.Wrap(err, "failed to create fingerprints file")
+				}
+				defer fs.CheckClose(out, &err)
+				w = out
+			}
+			stats, err := operations.ChunkFingerprints(context.Background(), fsrc, params, w)
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				return json.NewEncoder(os.Stdout).Encode(stats)
+			}
+
+			saving := 0.0
+			if stats.Bytes > 0 {
+				saving = 100 * float64(stats.Bytes-stats.UniqueBytes) / float64(stats.Bytes)
+			}
+			fmt.Printf("Total objects: %d\n", stats.Files)
+			fmt.Printf("Total size: %s (%d Bytes)\n", fs.SizeSuffix(stats.Bytes).Unit("Bytes"), stats.Bytes)
+			fmt.Printf("Chunks: %d (%d unique)\n", stats.Chunks, stats.UniqueChunks)
+			fmt.Printf("Unique size: %s (%d Bytes)\n", fs.SizeSuffix(stats.UniqueBytes).Unit("Bytes"), stats.UniqueBytes)
+			fmt.Printf("Dedup saving: %.2f%%\n", saving)
+			return nil
+		})
+	},
+}
@@ -237,7 +237,24 @@ func Run(Retry bool, showStats bool, cmd *cobra.Command, f func() error) {
 	} else if showStats {
 		stopStats = StartStats()
 	}
+	stopSpeedCSV := startSpeedCSV()
+	stopStatsd := accounting.StartStatsd()
+	stopStatsInflux := accounting.StartStatsInflux(*statsInterval)
+	stopPauseWhileRunning := accounting.StartPauseWhileRunning()
+	stopHeartbeat := accounting.StartHeartbeat()
+	stopTracing := accounting.StartTracing("rclone " + cmd.Name())
 	SigInfoHandler()
+	summary, err := newSummaryPrinter(cmd.Name())
+	if err != nil {
+		log.Fatalf("Failed to %s: %v", cmd.Name(), err)
+	}
+	stopTransferLog, err := accounting.StartTransferLog()
+	if err != nil {
+		log.Fatalf("Failed to %s: %v", cmd.Name(), err)
+	}
+	stopManifest := accounting.StartManifest()
+	stopEventSocket := accounting.StartEventSocket()
+	var retryDeadline time.Time
 	for try := 1; try <= *retries; try++ {
 		cmdErr = f()
 		cmdErr = fs.CountError(cmdErr)
@@ -271,6 +288,14 @@ func Run(Retry bool, showStats bool, cmd *cobra.Command, f func() error) {
 		} else {
 			fs.Errorf(nil, "Attempt %d/%d failed with %d errors", try, *retries, accounting.GlobalStats().GetErrors())
 		}
+		if fs.Config.RetryDuration > 0 {
+			if retryDeadline.IsZero() {
+				retryDeadline = time.Now().Add(fs.Config.RetryDuration)
+			} else if !time.Now().Before(retryDeadline) {
+				fs.Errorf(nil, "Not attempting retries as --retry-duration %v is used up", fs.Config.RetryDuration)
+				break
+			}
+		}
 		if try < *retries {
 			accounting.GlobalStats().ResetErrors()
 		}
@@ -279,9 +304,24 @@ func Run(Retry bool, showStats bool, cmd *cobra.Command, f func() error) {
 		}
 	}
 	stopStats()
+	stopSpeedCSV()
+	stopStatsd()
+	stopStatsInflux()
+	stopPauseWhileRunning()
+	stopHeartbeat(cmdErr)
+	stopTracing(cmdErr)
+	stopTransferLog()
+	stopManifest()
+	stopEventSocket()
 	if showStats && (accounting.GlobalStats().Errored() || *statsInterval > 0) {
 		accounting.GlobalStats().Log()
 	}
+	if summary != nil {
+		err := summary.print(cmdErr)
+		if err != nil {
+			fs.Errorf(nil, "Failed to print summary: %v", err)
+		}
+	}
 	fs.Debugf(nil, "%d go routines active\n", runtime.NumGoroutine())
 
 	// dump all running go-routines
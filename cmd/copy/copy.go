@@ -2,8 +2,11 @@ package copy
 
 import (
 	"context"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config/flags"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fs/sync"
@@ -12,12 +15,16 @@ import (
 
 var (
 	createEmptySrcDirs = false
+	watch              = false
+	watchDelay         = time.Second
 )
 
 func init() {
 	cmd.Root.AddCommand(commandDefinition)
 	cmdFlags := commandDefinition.Flags()
 	flags.BoolVarP(cmdFlags, &createEmptySrcDirs, "create-empty-src-dirs", "", createEmptySrcDirs, "Create empty source dirs on destination after copy")
+	flags.BoolVarP(cmdFlags, &watch, "watch", "", watch, "Carry on watching the source for changes and transfer them as they happen")
+	flags.DurationVarP(cmdFlags, &watchDelay, "watch-delay", "", watchDelay, "Time to gather changes for with --watch before transferring them")
 }
 
 var commandDefinition = &cobra.Command{
@@ -71,6 +78,14 @@ recently very efficiently like this:
 
     rclone copy --max-age 24h --no-traverse /path/to/src remote:
 
+Use ` + "`--watch`" + ` to carry on copying the files in the source as they
+change after the copy has finished, until rclone is stopped.  The
+changes are gathered for ` + "`--watch-delay`" + ` (default 1s) before being
+transferred so a file which changes many times in a burst is only
+transferred once.  The transfers obey the usual flags such as
+` + "`--transfers`" + ` and ` + "`--bwlimit`" + `.  Only the local backend on Linux can be
+watched, using inotify.
+
 **Note**: Use the ` + "`-P`" + `/` + "`--progress`" + ` flag to view real-time transfer statistics.
 
 **Note**: Use the ` + "`--dry-run` or the `--interactive`/`-i`" + ` flag to test without copying anything.
@@ -79,6 +94,12 @@ recently very efficiently like this:
 		cmd.CheckArgs(2, 2, command, args)
 		fsrc, srcFileName, fdst := cmd.NewFsSrcFileDst(args)
 		cmd.Run(true, true, command, func() error {
+			if watch {
+				if srcFileName != "" {
+					return errors.New("can't use --watch with a file as the source")
+				}
+				return sync.Watch(context.Background(), fdst, fsrc, fs.DeleteModeOff, createEmptySrcDirs, watchDelay)
+			}
 			if srcFileName == "" {
 				return sync.CopyDir(context.Background(), fdst, fsrc, createEmptySrcDirs)
 			}
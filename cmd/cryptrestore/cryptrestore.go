@@ -0,0 +1,153 @@
+package cryptrestore
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/backend/crypt"
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "cryptrestore cryptedremote:path prefix dest:path",
+	Short: `Restore the files starting with a path prefix from a crypted remote.`,
+	Long: `
+rclone cryptrestore decrypts and copies the files in cryptedremote:path
+whose decrypted path starts with prefix to dest:path.  This is useful
+for recovering part of a large crypted remote without listing all of
+it, eg
+
+    rclone cryptrestore secret: photos/2019- /path/to/restore
+
+restores "photos/2019-01/a.jpg" and "photos/2019-summer.jpg" to
+"/path/to/restore/2019-01/a.jpg" and "/path/to/restore/2019-summer.jpg".
+The prefix needn't end at a directory - end it with "/" to restore a
+whole directory.  The files are put in dest:path relative to the
+directory the prefix is in.
+
+Only the directory the prefix is in is listed to find the names
+matching it, using the encrypted directory name if
+directory_name_encryption is set, then the matching directories are
+listed in parallel.  ` + "`--checkers`" + ` files are decrypted and
+downloaded at once.
+
+Files which are already in dest:path and don't need transferring are
+skipped so an interrupted restore can be run again.  The filters apply
+as usual and the progress shows the decrypted sizes of the files.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(3, 3, command, args)
+		fsrc := cmd.NewFsSrc(args[:1])
+		fdst := cmd.NewFsDir(args[2:])
+		cmd.Run(true, true, command, func() error {
+			return cryptRestore(context.Background(), fdst, fsrc, args[1])
+		})
+	},
+}
+
+// restoreFile is a file to restore
+type restoreFile struct {
+	src    fs.Object
+	remote string // where to put it in the destination
+}
+
+// cryptRestore copies the files in fsrc whose decrypted path starts
+// with prefix to fdst
+func cryptRestore(ctx context.Context, fdst, fsrc fs.Fs, prefix string) error {
+	fcrypt, ok := fsrc.(*crypt.Fs)
+	if !ok {
+		return errors.Errorf("%s:%s is not a crypt remote", fsrc.Name(), fsrc.Root())
+	}
+	var (
+		files []restoreFile
+		size  int64
+	)
+	err := fcrypt.ListPrefix(ctx, prefix, func(o fs.Object, relative string) {
+		files = append(files, restoreFile{src: o, remote: relative})
+		if o.Size() > 0 {
+			size += o.Size()
+		}
+	})
+	if err != nil {
+		return err
+	}
+	fs.Infof(fdst, "Restoring %d files (%v) matching %q", len(files), fs.SizeSuffix(size), prefix)
+
+	var (
+		stats     = accounting.Stats(ctx)
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		queued    = len(files)
+		errCount  int
+		toRestore = make(chan restoreFile, fs.Config.Checkers)
+	)
+	stats.SetTransferQueue(queued, size)
+	wg.Add(fs.Config.Checkers)
+	for i := 0; i < fs.Config.Checkers; i++ {
+		go func() {
+			defer wg.Done()
+			for file := range toRestore {
+				mu.Lock()
+				queued--
+				if file.src.Size() > 0 {
+					size -= file.src.Size()
+				}
+				stats.SetTransferQueue(queued, size)
+				mu.Unlock()
+				err := restore(ctx, fdst, file)
+				if err != nil {
+					mu.Lock()
+					errCount++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+outer:
+	for _, file := range files {
+		select {
+		case <-ctx.Done():
+			break outer
+		case toRestore <- file:
+		}
+	}
+	close(toRestore)
+	wg.Wait()
+	stats.SetTransferQueue(0, 0)
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errCount > 0 {
+		return errors.Errorf("failed to restore %d files", errCount)
+	}
+	return nil
+}
+
+// restore copies file to fdst unless it is there already
+func restore(ctx context.Context, fdst fs.Fs, file restoreFile) error {
+	dst, err := fdst.NewObject(ctx, file.remote)
+	switch err {
+	case nil:
+		if !operations.NeedTransfer(ctx, dst, file.src) {
+			return nil
+		}
+	case fs.ErrorObjectNotFound:
+		dst = nil
+	default:
+		err = fs.CountError(err)
+		fs.Errorf(file.remote, "Failed to read destination: %v", err)
+		return err
+	}
+	_, err = operations.Copy(ctx, fdst, dst, file.remote, file.src)
+	return err
+}
@@ -15,12 +15,14 @@ import (
 
 var (
 	outputBase64 = false
+	outputFile   = ""
 )
 
 func init() {
 	cmd.Root.AddCommand(commandDefinition)
 	cmdFlags := commandDefinition.Flags()
 	flags.BoolVarP(cmdFlags, &outputBase64, "base64", "", outputBase64, "Output base64 encoded hashsum")
+	flags.StringVarP(cmdFlags, &outputFile, "output-file", "", outputFile, "Write the hashsums to this file as they are made, resuming if it exists")
 }
 
 var commandDefinition = &cobra.Command{
@@ -43,6 +45,17 @@ Run without a hash to see the list of supported hashes, eg
 Then
 
     $ rclone hashsum MD5 remote:path
+
+Use the --output-file flag to write the sums to a file instead of
+stdout. Each sum is written to the file as soon as it has been made so
+nothing is lost if rclone is interrupted. If the file already exists
+then any objects which already have a sum in it are skipped and the
+new sums are appended to it, so running the same command again will
+resume where it left off. The ERROR and UNSUPPORTED lines of objects
+which failed are removed so those objects are tried again. Delete the
+file to start again.
+
+    $ rclone hashsum MD5 remote:path --output-file remote.md5
 `,
 	RunE: func(command *cobra.Command, args []string) error {
 		cmd.CheckArgs(0, 2, command, args)
@@ -62,6 +75,9 @@ Then
 		}
 		fsrc := cmd.NewFsSrc(args[1:])
 		cmd.Run(false, false, command, func() error {
+			if outputFile != "" {
+				return operations.HashSumFile(context.Background(), ht, outputBase64, fsrc, outputFile)
+			}
 			if outputBase64 {
 				return operations.HashListerBase64(context.Background(), ht, fsrc, os.Stdout)
 			}
@@ -43,6 +43,11 @@ into ` + "`dest:path`" + ` then delete the original (if no errors on copy) in
 
 If you want to delete empty source directories after move, use the --delete-empty-src-dirs flag.
 
+Use the [--move-delete-after](/docs/#move-delete-after) flag to only
+delete the source files once all the transfers have succeeded so a
+failed move doesn't leave the files half moved. The files are then
+copied rather than moved server side, which can be much slower.
+
 See the [--no-traverse](/docs/#no-traverse) option for controlling
 whether rclone lists the destination directory or not.  Supplying this
 option when moving a small number of files into a large destination
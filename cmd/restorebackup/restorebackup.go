@@ -0,0 +1,77 @@
+package restorebackup
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/spf13/cobra"
+)
+
+var (
+	purge     = false
+	overwrite = false
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	flags.BoolVarP(cmdFlags, &purge, "purge", "", purge, "Permanently delete the files in the backup dir instead of restoring them")
+	flags.BoolVarP(cmdFlags, &overwrite, "overwrite", "", overwrite, "Replace files which exist in the destination when restoring")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "restorebackup backup:path [dest:path]",
+	Short: `Restore or purge the files moved to a --backup-dir.`,
+	Long: `
+Moves the files in backup:path, which was used as the ` + "`--backup-dir`" + `
+of a ` + "`sync`, `copy` or `move`" + ` to dest:path, back to where they
+came from in dest:path.
+
+Each file is restored to the same path relative to dest:path as it has
+relative to backup:path, with the ` + "`--suffix`" + ` removed, which is
+where rclone moved it from.  Use the same ` + "`--suffix`" + ` and
+` + "`--suffix-keep-extension`" + ` flags as the sync - files without the
+suffix are left in the backup dir.  This means files backed up with a
+dated suffix can be restored from a particular run, eg
+
+    rclone sync --backup-dir remote:old --suffix -2020-07-01 /path remote:current
+    rclone restorebackup --suffix -2020-07-01 remote:old remote:current
+
+Files which exist in dest:path are left alone unless the
+` + "`--overwrite`" + ` flag is given.
+
+With the ` + "`--purge`" + ` flag the files in backup:path are permanently
+deleted instead and dest:path isn't needed.
+
+The filters apply to the files in backup:path, so for example
+` + "`--min-age 30d`" + ` can be used to purge only files which were backed
+up at least 30 days ago (on backends which keep the modification time
+of a file when moving it), and empty directories left in the backup
+dir are removed.
+
+Check what would be restored or purged first with ` + "`--dry-run`" + `
+
+    rclone restorebackup --dry-run remote:old remote:current
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 2, command, args)
+		fbackup := cmd.NewFsDir(args[:1])
+		if purge {
+			cmd.Run(true, false, command, func() error {
+				if len(args) != 1 {
+					return errors.New("don't supply dest:path with --purge")
+				}
+				return operations.PurgeBackupDir(context.Background(), fbackup)
+			})
+			return
+		}
+		cmd.CheckArgs(2, 2, command, args)
+		fdst := cmd.NewFsDir(args[1:])
+		cmd.Run(true, true, command, func() error {
+			return operations.RestoreBackupDir(context.Background(), fdst, fbackup, overwrite)
+		})
+	},
+}
@@ -0,0 +1,139 @@
+// Write transfer speed samples to a CSV file
+
+package cmd
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/config/flags"
+)
+
+// Flags
+var (
+	speedCSV = flags.StringP("speed-csv", "", "", "Append a transfer speed sample to this CSV file every --stats interval.")
+)
+
+// speedCSVHeader is the first row of a new --speed-csv file
+var speedCSVHeader = []string{"time", "bytes", "speed", "bwlimit"}
+
+// speedSampler writes transfer speed samples in CSV format
+type speedSampler struct {
+	out       *os.File
+	w         *csv.Writer
+	lastBytes int64
+	lastTime  time.Time
+}
+
+// newSpeedSampler opens fileName for appending samples to, writing
+// the header if it is empty
+func newSpeedSampler(fileName string) (*speedSampler, error) {
+	out, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open --speed-csv file")
+	}
+	s := &speedSampler{
+		out:       out,
+		w:         csv.NewWriter(out),
+		lastBytes: accounting.GlobalStats().GetBytes(),
+		lastTime:  time.Now(),
+	}
+	fi, err := out.Stat()
+	if err != nil {
+		_ = out.Close()
+		return nil, errors.Wrap(err, "failed to read --speed-csv file")
+	}
+	if fi.Size() == 0 {
+		err = s.w.Write(speedCSVHeader)
+		if err != nil {
+			_ = out.Close()
+			return nil, errors.Wrap(err, "failed to write --speed-csv file")
+		}
+	}
+	return s, nil
+}
+
+// sample writes a row with the bytes transferred so far, the speed
+// since the last sample and the current bandwidth limit in bytes/s
+// or -1 if unlimited
+func (s *speedSampler) sample(now time.Time) error {
+	bytes := accounting.GlobalStats().GetBytes()
+	speed := 0.0
+	if dt := now.Sub(s.lastTime).Seconds(); dt > 0 {
+		speed = float64(bytes-s.lastBytes) / dt
+	}
+	s.lastBytes, s.lastTime = bytes, now
+	err := s.w.Write([]string{
+		now.Format(time.RFC3339Nano),
+		strconv.FormatInt(bytes, 10),
+		strconv.FormatFloat(speed, 'f', 0, 64),
+		strconv.FormatInt(int64(accounting.CurrentBwLimit()), 10),
+	})
+	if err != nil {
+		return err
+	}
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// close writes a last sample and closes the file
+func (s *speedSampler) close() error {
+	err := s.sample(time.Now())
+	closeErr := s.out.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// startSpeedCSV writes speed samples to the --speed-csv file every
+// statsInterval if set
+//
+// It returns a func which should be called to stop the samples.
+func startSpeedCSV() func() {
+	if *speedCSV == "" {
+		return func() {}
+	}
+	if *statsInterval <= 0 {
+		fs.Errorf(nil, "Ignoring --speed-csv as --stats is 0")
+		return func() {}
+	}
+	s, err := newSpeedSampler(*speedCSV)
+	if err != nil {
+		fs.Errorf(nil, "Ignoring --speed-csv: %v", err)
+		return func() {}
+	}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(*statsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				err := s.sample(now)
+				if err != nil {
+					fs.Errorf(nil, "Failed to write --speed-csv sample: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		wg.Wait()
+		err := s.close()
+		if err != nil {
+			fs.Errorf(nil, "Failed to write --speed-csv file: %v", err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Print a summary of the run for other tools to read
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/config/flags"
+)
+
+// Flags
+var (
+	summaryTemplate = flags.StringP("summary-template", "", "", "Print a summary at the end of the run using this Go template.")
+	summaryJSON     = flags.BoolP("summary-json", "", false, "Print a summary at the end of the run as JSON.")
+)
+
+// summary is the summary of the run passed to --summary-template or
+// printed by --summary-json
+type summary struct {
+	accounting.Summary
+	Command  string  `json:"command"`  // the name of the command run
+	Duration float64 `json:"duration"` // seconds since the command started
+	Success  bool    `json:"success"`  // set if the command succeeded
+}
+
+// summaryPrinter prints the summary of the run if required
+type summaryPrinter struct {
+	name  string
+	start time.Time
+	tmpl  *template.Template
+}
+
+// newSummaryPrinter makes a summaryPrinter for the command called
+// name, returning nil if no summary is wanted
+func newSummaryPrinter(name string) (*summaryPrinter, error) {
+	if *summaryTemplate == "" && !*summaryJSON {
+		return nil, nil
+	}
+	p := &summaryPrinter{
+		name:  name,
+		start: time.Now(),
+	}
+	if *summaryTemplate != "" {
+		var err error
+		p.tmpl, err = template.New("summary").Parse(*summaryTemplate)
+		if err != nil {
+			return nil, errors.Wrap(err, "bad --summary-template")
+		}
+	}
+	return p, nil
+}
+
+// print the summary to stdout - cmdErr is the result of the command
+func (p *summaryPrinter) print(cmdErr error) error {
+	s := summary{
+		Summary:  accounting.GlobalStats().Summary(),
+		Command:  p.name,
+		Duration: time.Since(p.start).Seconds(),
+		Success:  cmdErr == nil,
+	}
+	if cmdErr != nil && s.LastError == "" {
+		s.LastError = cmdErr.Error()
+	}
+	if *summaryJSON {
+		out, err := json.MarshalIndent(&s, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(append(out, '\n'))
+		if err != nil {
+			return err
+		}
+	}
+	if p.tmpl != nil {
+		err := p.tmpl.Execute(os.Stdout, &s)
+		if err != nil {
+			return errors.Wrap(err, "failed to run --summary-template")
+		}
+	}
+	return nil
+}
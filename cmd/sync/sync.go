@@ -2,8 +2,11 @@ package sync
 
 import (
 	"context"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config/flags"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fs/sync"
@@ -12,12 +15,16 @@ import (
 
 var (
 	createEmptySrcDirs = false
+	watch              = false
+	watchDelay         = time.Second
 )
 
 func init() {
 	cmd.Root.AddCommand(commandDefinition)
 	cmdFlags := commandDefinition.Flags()
 	flags.BoolVarP(cmdFlags, &createEmptySrcDirs, "create-empty-src-dirs", "", createEmptySrcDirs, "Create empty source dirs on destination after sync")
+	flags.BoolVarP(cmdFlags, &watch, "watch", "", watch, "Carry on watching the source for changes and transfer them as they happen")
+	flags.DurationVarP(cmdFlags, &watchDelay, "watch-delay", "", watchDelay, "Time to gather changes for with --watch before transferring them")
 }
 
 var commandDefinition = &cobra.Command{
@@ -45,12 +52,30 @@ extended explanation in the ` + "`" + `copy` + "`" + ` command above if unsure.
 If dest:path doesn't exist, it is created and the source:path contents
 go there.
 
+Use ` + "`--watch`" + ` to carry on mirroring the source after the sync has
+finished, until rclone is stopped.  Files which change in the source
+are transferred and files which are deleted are deleted from the
+destination as they happen.  A rename is a delete of the old name and
+a transfer of the new one.  The changes are gathered for
+` + "`--watch-delay`" + ` (default 1s) before being transferred so a file which
+changes many times in a burst is only transferred once.  This is much
+more responsive than running ` + "`rclone sync`" + ` periodically.  Only the
+local backend on Linux can be watched, using inotify, so files which
+are only written to and not closed are transferred when they are
+closed.
+
 **Note**: Use the ` + "`-P`" + `/` + "`--progress`" + ` flag to view real-time transfer statistics
 `,
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(2, 2, command, args)
 		fsrc, srcFileName, fdst := cmd.NewFsSrcFileDst(args)
 		cmd.Run(true, true, command, func() error {
+			if watch {
+				if srcFileName != "" {
+					return errors.New("can't use --watch with a file as the source")
+				}
+				return sync.Watch(context.Background(), fdst, fsrc, fs.Config.DeleteMode, createEmptySrcDirs, watchDelay)
+			}
 			if srcFileName == "" {
 				return sync.Sync(context.Background(), fdst, fsrc, createEmptySrcDirs)
 			}
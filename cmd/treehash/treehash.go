@@ -0,0 +1,66 @@
+package treehash
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/spf13/cobra"
+)
+
+var (
+	hashType = hash.MD5
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	flags.FVarP(cmdFlags, &hashType, "hash", "", "Hash of the files to build the tree from")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "treehash remote:path",
+	Short: `Prints a Merkle root of all the objects in the path.`,
+	Long: `
+Prints a single hash which covers the paths and contents of all the
+objects in the path, so a whole tree can be checked with one value.
+
+The hashes of the files are read with the hash named by the --hash
+flag (MD5 by default) and combined into a Merkle tree which mirrors
+the directory structure.  All the hashes in the tree are SHA-256:
+
+  - each file's leaf is the hash of a 0x00 byte followed by its
+    file hash in lower case hex
+  - each directory's node is the hash of a 0x01 byte followed by its
+    entries sorted by name (files first if a file and a directory
+    have the same name), each of which is the kind of entry ('f' or
+    'd'), its name, a 0x00 byte, then the 32 byte leaf or node
+  - the root printed is the node of the top directory in lower case
+    hex
+
+This means the result is the same on every run and on every backend
+which supports the hash, whatever order the objects are listed in.
+Empty directories aren't part of the tree and the node of a directory
+is the same as the treehash of that directory on its own.
+
+    $ rclone treehash remote:path
+    $ rclone treehash --hash SHA-1 remote:path
+
+It is an error if the hash can't be read for any of the objects.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		fsrc := cmd.NewFsSrc(args)
+		cmd.Run(false, false, command, func() error {
+			root, err := operations.TreeHash(context.Background(), hashType, fsrc)
+			if err != nil {
+				return err
+			}
+			fmt.Println(root)
+			return nil
+		})
+	},
+}
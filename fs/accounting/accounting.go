@@ -2,6 +2,7 @@
 package accounting
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"sync"
@@ -24,6 +25,10 @@ var ErrorMaxTransferLimitReached = errors.New("Max transfer limit reached as set
 // transfer limit is reached.
 var ErrorMaxTransferLimitReachedFatal = fserrors.FatalError(ErrorMaxTransferLimitReached)
 
+// ErrorTransferCancelled is returned when a transfer was cancelled
+// with Transfer.Cancel
+var ErrorTransferCancelled = errors.New("transfer cancelled")
+
 // Account limits and accounts for one transfer
 type Account struct {
 	stats *StatsInfo
@@ -38,37 +43,56 @@ type Account struct {
 	close   io.Closer
 	size    int64
 	name    string
-	closed  bool          // set if the file is closed
-	exit    chan struct{} // channel that will be closed when transfer is finished
-	withBuf bool          // is using a buffered in
+	bucket  string         // destination bucket to limit bandwidth for if set
+	bind    string         // local address to limit bandwidth for if set
+	limiter *BwLimiter     // extra bandwidth limit, eg of the rc job, if set
+	reserve *bwReservation // guaranteed slice of the bandwidth limit if set
+	closed  bool           // set if the file is closed
+	exit    chan struct{}  // channel that will be closed when transfer is finished
+	withBuf bool           // is using a buffered in
+
+	ctx context.Context // context of the transfer - cancelling it stops the waits for the bandwidth limits
+
+	unconfirmed int64 // bytes read but not confirmed by the transfer finishing - protected by unconfirmed.mu
 
 	values accountValues
 }
 
 // accountValues holds statistics for this Account
 type accountValues struct {
-	mu      sync.Mutex // Mutex for stat values.
-	bytes   int64      // Total number of bytes read
-	max     int64      // if >=0 the max number of bytes to transfer
-	start   time.Time  // Start time of first read
-	lpTime  time.Time  // Time of last average measurement
-	lpBytes int        // Number of bytes read since last measurement
-	avg     float64    // Moving average of last few measurements in bytes/s
+	mu      sync.Mutex    // Mutex for stat values.
+	bytes   int64         // Total number of bytes read
+	max     int64         // if >=0 the max number of bytes to transfer
+	start   time.Time     // Start time of first read
+	lpTime  time.Time     // Time of last average measurement
+	lpBytes int           // Number of bytes read since last measurement
+	avg     float64       // Moving average of last few measurements in bytes/s
+	cancel  error         // if set Read returns this error
+	speed   minSpeed      // checks --min-transfer-speed
+	waited  time.Duration // time spent waiting for the bandwidth limits
 }
 
 const averagePeriod = 16 // period to do exponentially weighted averages over
 
 // newAccountSizeName makes an Account reader for an io.ReadCloser of
 // the given size and name
-func newAccountSizeName(stats *StatsInfo, in io.ReadCloser, size int64, name string) *Account {
+func newAccountSizeName(ctx context.Context, stats *StatsInfo, in io.ReadCloser, size int64, name string) *Account {
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	acc := &Account{
-		stats:  stats,
-		in:     in,
-		close:  in,
-		origIn: in,
-		size:   size,
-		name:   name,
-		exit:   make(chan struct{}),
+		stats:   stats,
+		ctx:     ctx,
+		in:      in,
+		close:   in,
+		origIn:  in,
+		size:    size,
+		name:    name,
+		bucket:  bucketFromContext(ctx),
+		bind:    bindFromContext(ctx),
+		limiter: bwLimiterFromContext(ctx),
+		reserve: newBwReservation(),
+		exit:    make(chan struct{}),
 		values: accountValues{
 			avg:    0,
 			lpTime: time.Now(),
@@ -149,14 +173,20 @@ func (acc *Account) Abandon() {
 }
 
 // UpdateReader updates the underlying io.ReadCloser stopping the
-// async buffer (if any) and re-adding it
-func (acc *Account) UpdateReader(in io.ReadCloser) {
+// async buffer (if any) and re-adding it.
+//
+// ctx is the context of the new attempt at the transfer, or nil to
+// keep the current one.
+func (acc *Account) UpdateReader(ctx context.Context, in io.ReadCloser) {
 	acc.mu.Lock()
 	withBuf := acc.withBuf
 	if withBuf {
 		acc.Abandon()
 		acc.withBuf = false
 	}
+	if ctx != nil {
+		acc.ctx = ctx
+	}
 	acc.in = in
 	acc.close = in
 	acc.origIn = in
@@ -165,12 +195,25 @@ func (acc *Account) UpdateReader(in io.ReadCloser) {
 		acc.WithBuffer()
 	}
 	acc.mu.Unlock()
+	acc.releaseUnconfirmed()
 
 	// Reset counter to stop percentage going over 100%
 	acc.values.mu.Lock()
 	acc.values.lpBytes = 0
 	acc.values.bytes = 0
+	acc.values.cancel = nil
+	acc.values.speed = minSpeed{}
+	acc.values.mu.Unlock()
+}
+
+// Cancel makes all reads of the Account from now on return err until
+// the reader is replaced with UpdateReader.
+func (acc *Account) Cancel(err error) {
+	acc.values.mu.Lock()
+	acc.values.cancel = err
 	acc.values.mu.Unlock()
+	acc.wakePaused()
+	acc.wakeUnconfirmed()
 }
 
 // averageLoop calculates averages for the stats in the background
@@ -190,8 +233,12 @@ func (acc *Account) averageLoop() {
 				period++
 			}
 			acc.values.avg = (avg + (period-1)*acc.values.avg) / period
+			if fs.Config.StatsPercentiles {
+				acc.stats.speeds.add(now, int64(acc.values.lpBytes))
+			}
 			acc.values.lpBytes = 0
 			acc.values.lpTime = now
+			acc.checkMinSpeed(now)
 			// Unlock stats
 			acc.values.mu.Unlock()
 		case <-acc.exit:
@@ -200,10 +247,30 @@ func (acc *Account) averageLoop() {
 	}
 }
 
+// checkMinSpeed cancels the transfer if it is slower than
+// --min-transfer-speed
+//
+// Call with acc.values.mu held
+func (acc *Account) checkMinSpeed(now time.Time) {
+	if fs.Config.MinTransferSpeed <= 0 || acc.values.start.IsZero() || acc.values.cancel != nil {
+		return
+	}
+	err := acc.values.speed.check(now, fs.Config.MinTransferSpeed, fs.Config.MinTransferSpeedWindow)
+	if err != nil {
+		fs.Errorf(acc.name, "Aborting transfer: %v", err)
+		acc.values.cancel = err
+	}
+}
+
 // Check the read before it has happened is valid returning the number
 // of bytes remaining to read.
 func (acc *Account) checkReadBefore() (bytesUntilLimit int64, err error) {
 	acc.values.mu.Lock()
+	if acc.values.cancel != nil {
+		err = acc.values.cancel
+		acc.values.mu.Unlock()
+		return 0, err
+	}
 	if acc.values.max >= 0 {
 		bytesUntilLimit = acc.values.max - acc.stats.GetBytes()
 		if bytesUntilLimit < 0 {
@@ -258,16 +325,77 @@ func (acc *Account) ServerSideCopyEnd(n int64) {
 }
 
 // Account the read and limit bandwidth
-func (acc *Account) accountRead(n int) {
+//
+// It returns an error if the transfer was cancelled while paused or
+// waiting for the --max-unconfirmed or bandwidth limits.
+func (acc *Account) accountRead(n int) error {
 	// Update Stats
 	acc.values.mu.Lock()
 	acc.values.lpBytes += n
 	acc.values.bytes += int64(n)
+	acc.values.speed.bytes += int64(n)
 	acc.values.mu.Unlock()
 
 	acc.stats.Bytes(int64(n))
+	bwBudgetActive(n)
+	bwAfterActive(n)
+
+	start := time.Now()
+	limited, err := acc.waitPaused()
+	if err == nil {
+		var waited bool
+		waited, err = acc.waitUnconfirmed(n)
+		limited = limited || waited
+	}
+	if err == nil {
+		var bwLimited bool
+		bwLimited, err = acc.waitBwLimits(n)
+		limited = limited || bwLimited
+	}
+	if !limited {
+		return err
+	}
+	wait := time.Since(start)
+	acc.stats.BwLimitWait(wait)
+	acc.values.mu.Lock()
+	acc.values.waited += wait
+	acc.values.mu.Unlock()
+	if fs.Config.MinTransferSpeed > 0 {
+		// don't count the time waiting for the bwlimit against
+		// the transfer
+		acc.values.mu.Lock()
+		acc.values.speed.wait += wait
+		acc.values.mu.Unlock()
+	}
+	return err
+}
 
-	limitBandwidth(n)
+// waitBwLimits sleeps for the correct amount of time for the passage
+// of n bytes according to each of the bandwidth limits of acc.
+//
+// It returns true if any of them are limited and an error if the
+// transfer was cancelled while waiting.
+func (acc *Account) waitBwLimits(n int) (limited bool, err error) {
+	ctx := acc.ctx
+	if acc.reserve != nil {
+		limited, err = acc.reserve.wait(ctx, n)
+	} else {
+		limited, err = limitBandwidth(ctx, n)
+	}
+	var waited bool
+	if err == nil && acc.bucket != "" {
+		waited, err = limitBucketBandwidth(ctx, acc.bucket, n)
+		limited = limited || waited
+	}
+	if err == nil && acc.bind != "" {
+		waited, err = limitBindBandwidth(ctx, acc.bind, n)
+		limited = limited || waited
+	}
+	if err == nil && acc.limiter != nil {
+		waited, err = acc.limiter.wait(ctx, n)
+		limited = limited || waited
+	}
+	return limited, err
 }
 
 // read bytes from the io.Reader passed in and account them
@@ -275,8 +403,11 @@ func (acc *Account) read(in io.Reader, p []byte) (n int, err error) {
 	bytesUntilLimit, err := acc.checkReadBefore()
 	if err == nil {
 		n, err = in.Read(p)
-		acc.accountRead(n)
+		limitErr := acc.accountRead(n)
 		n, err = checkReadAfter(bytesUntilLimit, n, err)
+		if err == nil {
+			err = limitErr
+		}
 	}
 	return n, err
 }
@@ -306,7 +437,10 @@ func (awt *accountWriteTo) Write(p []byte) (n int, err error) {
 	if err == nil {
 		n, err = awt.w.Write(p)
 		n, err = checkReadAfter(bytesUntilLimit, n, err)
-		awt.acc.accountRead(n)
+		limitErr := awt.acc.accountRead(n)
+		if err == nil {
+			err = limitErr
+		}
 	}
 	return n, err
 }
@@ -334,7 +468,10 @@ func (acc *Account) AccountRead(n int) (err error) {
 	bytesUntilLimit, err := acc.checkReadBefore()
 	if err == nil {
 		n, err = checkReadAfter(bytesUntilLimit, n, err)
-		acc.accountRead(n)
+		limitErr := acc.accountRead(n)
+		if err == nil {
+			err = limitErr
+		}
 	}
 	return err
 }
@@ -359,6 +496,8 @@ func (acc *Account) Done() {
 	defer acc.mu.Unlock()
 	close(acc.exit)
 	acc.stats.inProgress.clear(acc.name)
+	acc.releaseUnconfirmed()
+	acc.reserve.release()
 }
 
 // progress returns bytes read as well as the size.
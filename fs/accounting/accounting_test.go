@@ -2,11 +2,13 @@ package accounting
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"strings"
 	"testing"
+	"time"
 	"unicode/utf8"
 
 	"github.com/rclone/rclone/fs"
@@ -29,7 +31,7 @@ var (
 func TestNewAccountSizeName(t *testing.T) {
 	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1}))
 	stats := NewStats()
-	acc := newAccountSizeName(stats, in, 1, "test")
+	acc := newAccountSizeName(context.Background(), stats, in, 1, "test")
 	assert.Equal(t, in, acc.in)
 	assert.Equal(t, acc, stats.inProgress.get("test"))
 	err := acc.Close()
@@ -44,7 +46,7 @@ func TestAccountWithBuffer(t *testing.T) {
 	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1}))
 
 	stats := NewStats()
-	acc := newAccountSizeName(stats, in, -1, "test")
+	acc := newAccountSizeName(context.Background(), stats, in, -1, "test")
 	assert.False(t, acc.HasBuffer())
 	acc.WithBuffer()
 	assert.True(t, acc.HasBuffer())
@@ -53,7 +55,7 @@ func TestAccountWithBuffer(t *testing.T) {
 	require.True(t, ok)
 	assert.NoError(t, acc.Close())
 
-	acc = newAccountSizeName(stats, in, 1, "test")
+	acc = newAccountSizeName(context.Background(), stats, in, 1, "test")
 	acc.WithBuffer()
 	// should not have a buffer for a small size
 	_, ok = acc.in.(*asyncreader.AsyncReader)
@@ -61,12 +63,37 @@ func TestAccountWithBuffer(t *testing.T) {
 	assert.NoError(t, acc.Close())
 }
 
+func TestAccountCancel(t *testing.T) {
+	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1, 2, 3}))
+	stats := NewStats()
+	acc := newAccountSizeName(context.Background(), stats, in, 3, "test")
+
+	buf := make([]byte, 1)
+	n, err := acc.Read(buf)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, err)
+
+	acc.Cancel(ErrorTransferCancelled)
+	n, err = acc.Read(buf)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, ErrorTransferCancelled, err)
+
+	// a new reader clears the cancel
+	acc.UpdateReader(context.Background(), ioutil.NopCloser(bytes.NewBuffer([]byte{1})))
+	n, err = acc.Read(buf)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, err)
+
+	assert.NoError(t, acc.Close())
+	acc.Done()
+}
+
 func TestAccountGetUpdateReader(t *testing.T) {
 	test := func(doClose bool) func(t *testing.T) {
 		return func(t *testing.T) {
 			in := ioutil.NopCloser(bytes.NewBuffer([]byte{1}))
 			stats := NewStats()
-			acc := newAccountSizeName(stats, in, 1, "test")
+			acc := newAccountSizeName(context.Background(), stats, in, 1, "test")
 
 			assert.Equal(t, in, acc.GetReader())
 			assert.Equal(t, acc, stats.inProgress.get("test"))
@@ -77,7 +104,7 @@ func TestAccountGetUpdateReader(t *testing.T) {
 			}
 
 			in2 := ioutil.NopCloser(bytes.NewBuffer([]byte{1}))
-			acc.UpdateReader(in2)
+			acc.UpdateReader(context.Background(), in2)
 
 			assert.Equal(t, in2, acc.GetReader())
 			assert.Equal(t, acc, stats.inProgress.get("test"))
@@ -92,7 +119,7 @@ func TestAccountGetUpdateReader(t *testing.T) {
 func TestAccountRead(t *testing.T) {
 	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1, 2, 3}))
 	stats := NewStats()
-	acc := newAccountSizeName(stats, in, 1, "test")
+	acc := newAccountSizeName(context.Background(), stats, in, 1, "test")
 
 	assert.True(t, acc.values.start.IsZero())
 	acc.values.mu.Lock()
@@ -133,7 +160,7 @@ func testAccountWriteTo(t *testing.T, withBuffer bool) {
 	}
 	in := ioutil.NopCloser(bytes.NewBuffer(buf))
 	stats := NewStats()
-	acc := newAccountSizeName(stats, in, int64(len(buf)), "test")
+	acc := newAccountSizeName(context.Background(), stats, in, int64(len(buf)), "test")
 	if withBuffer {
 		acc = acc.WithBuffer()
 	}
@@ -173,7 +200,7 @@ func TestAccountWriteToWithBuffer(t *testing.T) {
 func TestAccountString(t *testing.T) {
 	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1, 2, 3}))
 	stats := NewStats()
-	acc := newAccountSizeName(stats, in, 3, "test")
+	acc := newAccountSizeName(context.Background(), stats, in, 3, "test")
 
 	// FIXME not an exhaustive test!
 
@@ -193,7 +220,7 @@ func TestAccountString(t *testing.T) {
 func TestAccountAccounter(t *testing.T) {
 	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1, 2, 3}))
 	stats := NewStats()
-	acc := newAccountSizeName(stats, in, 3, "test")
+	acc := newAccountSizeName(context.Background(), stats, in, 3, "test")
 
 	assert.True(t, in == acc.OldStream())
 
@@ -260,7 +287,7 @@ func TestAccountMaxTransfer(t *testing.T) {
 
 	in := ioutil.NopCloser(bytes.NewBuffer(make([]byte, 100)))
 	stats := NewStats()
-	acc := newAccountSizeName(stats, in, 1, "test")
+	acc := newAccountSizeName(context.Background(), stats, in, 1, "test")
 
 	var b = make([]byte, 10)
 
@@ -277,7 +304,7 @@ func TestAccountMaxTransfer(t *testing.T) {
 
 	fs.Config.CutoffMode = fs.CutoffModeSoft
 	stats = NewStats()
-	acc = newAccountSizeName(stats, in, 1, "test")
+	acc = newAccountSizeName(context.Background(), stats, in, 1, "test")
 
 	n, err = acc.Read(b)
 	assert.Equal(t, 10, n)
@@ -302,7 +329,7 @@ func TestAccountMaxTransferWriteTo(t *testing.T) {
 
 	in := ioutil.NopCloser(readers.NewPatternReader(1024))
 	stats := NewStats()
-	acc := newAccountSizeName(stats, in, 1, "test")
+	acc := newAccountSizeName(context.Background(), stats, in, 1, "test")
 
 	var b bytes.Buffer
 
@@ -353,3 +380,69 @@ func TestShortenName(t *testing.T) {
 		})
 	}
 }
+
+func TestMinSpeedCheck(t *testing.T) {
+	const window = 10 * time.Second
+	const min = fs.SizeSuffix(100)
+	start := time.Now()
+	var ms minSpeed
+
+	// first call starts the window
+	assert.NoError(t, ms.check(start, min, window))
+
+	// the first window isn't checked even though nothing was read
+	assert.NoError(t, ms.check(start.Add(5*time.Second), min, window))
+	assert.NoError(t, ms.check(start.Add(window), min, window))
+	assert.True(t, ms.ramped)
+
+	// fast enough
+	ms.bytes = 100 * 10
+	assert.NoError(t, ms.check(start.Add(2*window), min, window))
+
+	// slow because of the bwlimit
+	ms.bytes = 100
+	ms.wait = window - 500*time.Millisecond
+	assert.NoError(t, ms.check(start.Add(3*window), min, window))
+
+	// slow partly because of the bwlimit but fast enough when
+	// not waiting
+	ms.bytes = 100 * 5
+	ms.wait = window / 2
+	assert.NoError(t, ms.check(start.Add(4*window), min, window))
+
+	// too slow
+	ms.bytes = 100*10 - 1
+	err := ms.check(start.Add(5*window), min, window)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "below --min-transfer-speed")
+	assert.Equal(t, int64(0), ms.bytes)
+}
+
+func TestAccountMinTransferSpeed(t *testing.T) {
+	oldMin, oldWindow := fs.Config.MinTransferSpeed, fs.Config.MinTransferSpeedWindow
+	fs.Config.MinTransferSpeed = 1024
+	fs.Config.MinTransferSpeedWindow = 10 * time.Second
+	defer func() {
+		fs.Config.MinTransferSpeed, fs.Config.MinTransferSpeedWindow = oldMin, oldWindow
+	}()
+
+	in := ioutil.NopCloser(bytes.NewBuffer(make([]byte, 10)))
+	stats := NewStats()
+	acc := newAccountSizeName(context.Background(), stats, in, 10, "test")
+
+	buf := make([]byte, 1)
+	_, err := acc.Read(buf)
+	require.NoError(t, err)
+
+	// simulate the ticks of averageLoop
+	now := time.Now()
+	acc.values.mu.Lock()
+	for i := 0; i <= 3; i++ {
+		acc.checkMinSpeed(now.Add(time.Duration(i) * 10 * time.Second))
+	}
+	acc.values.mu.Unlock()
+
+	_, err = acc.Read(buf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "below --min-transfer-speed")
+}
@@ -0,0 +1,64 @@
+package accounting
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// bwAfterBytes counts the bytes transferred in the current
+// --bwlimit-after-period when --bwlimit-after is set - use atomic
+var bwAfterBytes int64
+
+// bwAfter tracks whether --bwlimit-after has been transferred in the
+// current period
+var bwAfter = struct {
+	mu          sync.Mutex
+	periodStart time.Time // when the current period started
+	reached     int32     // set if the threshold has been reached - use atomic
+}{}
+
+// bwAfterActive records that n bytes have been transferred, turning
+// the bandwidth limit on as soon as --bwlimit-after is reached.
+func bwAfterActive(n int) {
+	threshold := int64(fs.Config.BwLimitAfter)
+	if threshold <= 0 || n <= 0 {
+		return
+	}
+	total := atomic.AddInt64(&bwAfterBytes, int64(n))
+	if total >= threshold && atomic.CompareAndSwapInt32(&bwAfter.reached, 0, 1) {
+		fs.Logf(nil, "Transferred --bwlimit-after %v, starting the bandwidth limit", fs.Config.BwLimitAfter)
+		updateBwLimit(time.Now())
+	}
+}
+
+// applyBwAfter updates the --bwlimit-after threshold at time now and
+// returns limit turned off if the threshold hasn't been reached yet.
+//
+// The bytes transferred are counted again from 0 at the start of each
+// --bwlimit-after-period if set.
+func applyBwAfter(now time.Time, limit fs.BwTimeSlot) fs.BwTimeSlot {
+	if fs.Config.BwLimitAfter <= 0 {
+		return limit
+	}
+	bwAfter.mu.Lock()
+	defer bwAfter.mu.Unlock()
+	period := fs.Config.BwLimitAfterPeriod
+	if bwAfter.periodStart.IsZero() {
+		bwAfter.periodStart = now
+	} else if period > 0 && now.Sub(bwAfter.periodStart) >= period {
+		for now.Sub(bwAfter.periodStart) >= period {
+			bwAfter.periodStart = bwAfter.periodStart.Add(period)
+		}
+		atomic.StoreInt64(&bwAfterBytes, 0)
+		if atomic.SwapInt32(&bwAfter.reached, 0) != 0 {
+			fs.Logf(nil, "Bandwidth limit threshold reset - unlimited until another --bwlimit-after %v is transferred", fs.Config.BwLimitAfter)
+		}
+	}
+	if atomic.LoadInt32(&bwAfter.reached) == 0 {
+		limit.Bandwidth = 0
+	}
+	return limit
+}
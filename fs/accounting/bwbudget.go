@@ -0,0 +1,73 @@
+package accounting
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// bwBudgetBytes counts the bytes transferred since the last tick of
+// the token ticker when --bwlimit-budget is set - use atomic
+var bwBudgetBytes int64
+
+// bwBudget tracks how much of the daily --bwlimit-budget has been
+// used
+var bwBudget = struct {
+	mu        sync.Mutex
+	day       time.Time     // midnight at the start of the day being tracked
+	used      time.Duration // time spent transferring at the full limit today
+	lastTick  time.Time     // when the budget was last updated
+	exhausted bool          // set if the budget has run out for today
+}{}
+
+// bwBudgetActive records that n bytes have been transferred
+func bwBudgetActive(n int) {
+	if fs.Config.BwLimitBudget > 0 && n > 0 {
+		atomic.AddInt64(&bwBudgetBytes, int64(n))
+	}
+}
+
+// midnight returns the start of the day of t
+func midnight(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// applyBwBudget updates the daily --bwlimit-budget at time now and
+// returns limit lowered to --bwlimit-budget-rate if the budget has
+// been used up.
+//
+// Time since the last call is counted against the budget if anything
+// was transferred in it.  The budget starts again at midnight.
+func applyBwBudget(now time.Time, limit fs.BwTimeSlot) fs.BwTimeSlot {
+	budget := fs.Config.BwLimitBudget
+	if budget <= 0 {
+		return limit
+	}
+	bwBudget.mu.Lock()
+	defer bwBudget.mu.Unlock()
+	active := atomic.SwapInt64(&bwBudgetBytes, 0) > 0
+	today := midnight(now)
+	if !today.Equal(bwBudget.day) {
+		if bwBudget.exhausted {
+			fs.Logf(nil, "Daily bandwidth budget reset")
+		}
+		bwBudget.day = today
+		bwBudget.used = 0
+		bwBudget.exhausted = false
+	} else if active && !bwBudget.exhausted && !bwBudget.lastTick.IsZero() {
+		bwBudget.used += now.Sub(bwBudget.lastTick)
+		if bwBudget.used >= budget {
+			bwBudget.exhausted = true
+			fs.Logf(nil, "Daily bandwidth budget of %v used up, limiting to %vBytes/s until midnight", budget, &fs.Config.BwLimitBudgetRate)
+		}
+	}
+	bwBudget.lastTick = now
+	budgetRate := fs.Config.BwLimitBudgetRate
+	if bwBudget.exhausted && budgetRate > 0 && (limit.Bandwidth <= 0 || limit.Bandwidth > budgetRate) {
+		limit.Bandwidth = budgetRate
+	}
+	return limit
+}
@@ -0,0 +1,141 @@
+package accounting
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fshttp"
+)
+
+const (
+	bwProbeTime    = 10 * time.Second  // longest a measurement of the bandwidth takes
+	bwProbeMaxSize = 256 * 1024 * 1024 // most bytes downloaded to measure the bandwidth
+)
+
+// bwPercentBaseline is the bandwidth last measured for
+// --bwlimit-percent or 0 if it hasn't been measured yet - protected
+// by currLimitMu
+var bwPercentBaseline fs.SizeSuffix
+
+// applyBwPercent returns limit with the bandwidth replaced by the
+// --bwlimit-percent in force at now of the measured bandwidth if it
+// has been measured.
+//
+// The bandwidth limit of limit is used as the ceiling.
+//
+// Call with currLimitMu held.
+func applyBwPercent(now time.Time, limit fs.BwTimeSlot) fs.BwTimeSlot {
+	if len(fs.Config.BwLimitPercent) == 0 || bwPercentBaseline <= 0 {
+		return limit
+	}
+	percent := fs.Config.BwLimitPercent.LimitAt(now).Percent
+	if percent < 0 {
+		return limit
+	}
+	bandwidth := bwPercentBaseline * fs.SizeSuffix(percent) / 100
+	if bandwidth < bwLimitMin {
+		bandwidth = bwLimitMin // so a tiny measurement isn't taken as unlimited
+	}
+	limit.Bandwidth = clampBwLimit(bandwidth, limit.Bandwidth)
+	return limit
+}
+
+// measureBandwidth measures the bandwidth by downloading url with
+// client for at most bwProbeTime or bwProbeMaxSize.
+//
+// The download isn't limited by the --bwlimit.
+func measureBandwidth(ctx context.Context, client *http.Client, url string) (bandwidth fs.SizeSuffix, err error) {
+	ctx, cancel := context.WithTimeout(ctx, bwProbeTime)
+	defer cancel()
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer fs.CheckClose(resp.Body, &err)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, errors.Errorf("HTTP error %v (%v)", resp.StatusCode, resp.Status)
+	}
+	start := time.Now()
+	n, err := io.Copy(ioutil.Discard, io.LimitReader(resp.Body, bwProbeMaxSize))
+	elapsed := time.Since(start)
+	if err != nil && ctx.Err() == nil {
+		return 0, err
+	}
+	err = nil // running out of time ends the measurement
+	if n == 0 || elapsed <= 0 {
+		return 0, errors.New("nothing downloaded")
+	}
+	return fs.SizeSuffix(float64(n) / elapsed.Seconds()), nil
+}
+
+// setBwPercentBaseline records bandwidth as the measured bandwidth and
+// sets the bandwidth limit from it without emptying the token bucket.
+func setBwPercentBaseline(now time.Time, bandwidth fs.SizeSuffix) {
+	currLimitMu.Lock()
+	defer currLimitMu.Unlock()
+	bwPercentBaseline = bandwidth
+	limitNow := bwLimitAt(now)
+	if currLimit.Bandwidth == limitNow.Bandwidth {
+		return
+	}
+	if setBwLimitInPlace(limitNow.Bandwidth) {
+		fs.Logf(nil, "Bandwidth limit set to %vBytes/s from the measured bandwidth %vBytes/s by --bwlimit-percent", &limitNow.Bandwidth, &bandwidth)
+	}
+	currLimit = limitNow
+}
+
+// probeBwPercent measures the bandwidth for --bwlimit-percent and
+// applies it.
+//
+// If the measurement fails the last measured bandwidth is kept.
+func probeBwPercent(ctx context.Context, client *http.Client) {
+	bandwidth, err := measureBandwidth(ctx, client, fs.Config.BwLimitPercentProbe)
+	if err != nil {
+		currLimitMu.Lock()
+		baseline := bwPercentBaseline
+		currLimitMu.Unlock()
+		if baseline > 0 {
+			fs.Errorf(nil, "Failed to measure the bandwidth for --bwlimit-percent - keeping the last measurement %vBytes/s: %v", &baseline, err)
+		} else {
+			fs.Errorf(nil, "Failed to measure the bandwidth for --bwlimit-percent - not limiting by it until it is measured: %v", err)
+		}
+		return
+	}
+	fs.Infof(nil, "Measured the bandwidth for --bwlimit-percent as %vBytes/s", &bandwidth)
+	setBwPercentBaseline(time.Now(), bandwidth)
+}
+
+// startBwPercent starts measuring the bandwidth for --bwlimit-percent
+// every --bwlimit-percent-interval if set.
+func startBwPercent() {
+	if len(fs.Config.BwLimitPercent) == 0 {
+		return
+	}
+	if fs.Config.BwLimitPercentProbe == "" {
+		fs.Errorf(nil, "Ignoring --bwlimit-percent as --bwlimit-percent-probe isn't set")
+		return
+	}
+	if fs.Config.BwLimitPercentInterval <= 0 {
+		fs.Errorf(nil, "Ignoring --bwlimit-percent as --bwlimit-percent-interval is 0")
+		return
+	}
+	fs.Infof(nil, "Limiting the bandwidth to the --bwlimit-percent timetable %q of the bandwidth measured every %v", fs.Config.BwLimitPercent.String(), fs.Config.BwLimitPercentInterval)
+	ctx := context.Background()
+	client := fshttp.NewClient(fs.Config)
+	ticker := time.NewTicker(fs.Config.BwLimitPercentInterval)
+	go func() {
+		probeBwPercent(ctx, client)
+		for range ticker.C {
+			probeBwPercent(ctx, client)
+		}
+	}()
+}
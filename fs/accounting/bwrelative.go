@@ -0,0 +1,53 @@
+package accounting
+
+import (
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// Globals for --bwlimit-relative - protected by currLimitMu
+var (
+	bwRelativeStart    time.Time // when the run started
+	bwRelativeTimersOn bool      // set if the timers for the changes have been started
+)
+
+// applyBwRelative returns limit with the bandwidth replaced by the
+// --bwlimit-relative in force at now if there is one.
+//
+// Before the first offset the limit is returned unchanged so the
+// --bwlimit timetable applies.
+//
+// Call with currLimitMu held.
+func applyBwRelative(now time.Time, limit fs.BwTimeSlot) fs.BwTimeSlot {
+	if len(fs.Config.BwLimitRelative) == 0 || bwRelativeStart.IsZero() {
+		return limit
+	}
+	if ts, ok := fs.Config.BwLimitRelative.LimitAt(now.Sub(bwRelativeStart)); ok {
+		limit.Bandwidth = ts.Bandwidth
+	}
+	return limit
+}
+
+// startBwRelativeTimers starts a timer for each --bwlimit-relative
+// change still to come which logs it and updates the bandwidth limit
+// as soon as it is reached.
+func startBwRelativeTimers() {
+	currLimitMu.Lock()
+	defer currLimitMu.Unlock()
+	if bwRelativeTimersOn || bwRelativeStart.IsZero() {
+		return
+	}
+	bwRelativeTimersOn = true
+	elapsed := time.Since(bwRelativeStart)
+	for _, ts := range fs.Config.BwLimitRelative {
+		if ts.Offset <= elapsed {
+			continue
+		}
+		ts := ts
+		time.AfterFunc(ts.Offset-elapsed, func() {
+			fs.Logf(nil, "Reached %v since the start - --bwlimit-relative sets the bandwidth limit to %v", ts.Offset, ts.Bandwidth)
+			updateBwLimit(time.Now())
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package accounting
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"golang.org/x/time/rate"
+)
+
+// bwSourceMaxSize is the most bytes read from the --bwlimit-source
+const bwSourceMaxSize = 64
+
+// bwLimitMin is the lowest bandwidth limit the --bwlimit-source or
+// the --bwlimit-percent can set so a bad value can't stall the
+// transfers
+const bwLimitMin = fs.SizeSuffix(1024)
+
+// bwSource polls the --bwlimit-source for the bandwidth limit
+type bwSource struct {
+	path    string
+	buf     []byte
+	lastErr string // the last error logged so it isn't logged every poll
+}
+
+// newBwSource makes a new bwSource reading from path
+func newBwSource(path string) *bwSource {
+	return &bwSource{
+		path: path,
+		buf:  make([]byte, bwSourceMaxSize),
+	}
+}
+
+// parseBwSource parses the bandwidth limit in buf which is ended by
+// the end of buf, a NUL or a new line.
+func parseBwSource(buf []byte) (fs.SizeSuffix, error) {
+	if i := bytes.IndexAny(buf, "\x00\n"); i >= 0 {
+		buf = buf[:i]
+	}
+	value := strings.TrimSpace(string(buf))
+	if value == "" {
+		return 0, errors.New("no bandwidth limit found")
+	}
+	var bandwidth fs.SizeSuffix
+	err := bandwidth.Set(value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid bandwidth limit %q", value)
+	}
+	return bandwidth, nil
+}
+
+// clampBwLimit returns the bandwidth limit to use for bandwidth read
+// from the --bwlimit-source or worked out for the --bwlimit-percent
+// when the --bwlimit in force is ceiling.
+//
+// Unlimited ("off" or 0) means use the ceiling, otherwise it is kept
+// between bwLimitMin and the ceiling.
+func clampBwLimit(bandwidth, ceiling fs.SizeSuffix) fs.SizeSuffix {
+	if bandwidth <= 0 {
+		return ceiling
+	}
+	if bandwidth < bwLimitMin {
+		bandwidth = bwLimitMin
+	}
+	if ceiling > 0 && bandwidth > ceiling {
+		bandwidth = ceiling
+	}
+	return bandwidth
+}
+
+// read the bandwidth limit from the file.
+//
+// The file is opened each time so it can be replaced as well as
+// written in place.
+func (s *bwSource) read() (fs.SizeSuffix, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(f, s.buf)
+	_ = f.Close()
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return 0, err
+	}
+	if n == len(s.buf) && bytes.IndexAny(s.buf, "\x00\n") < 0 {
+		return 0, errors.Errorf("bandwidth limit longer than %d bytes", bwSourceMaxSize)
+	}
+	return parseBwSource(s.buf[:n])
+}
+
+// poll reads the bandwidth limit and applies it if it has changed.
+//
+// If it can't be read or is invalid the limit is left as it is.
+func (s *bwSource) poll() {
+	bandwidth, err := s.read()
+	if err != nil {
+		if msg := err.Error(); msg != s.lastErr {
+			s.lastErr = msg
+			fs.Errorf(nil, "Failed to read --bwlimit-source - bandwidth limit unchanged: %v", err)
+		}
+		return
+	}
+	if s.lastErr != "" {
+		s.lastErr = ""
+		fs.Logf(nil, "Reading --bwlimit-source again")
+	}
+	currLimitMu.Lock()
+	ceiling := applyBwRelative(time.Now(), timetableLimitAt(time.Now())).Bandwidth
+	currLimitMu.Unlock()
+	limit := clampBwLimit(bandwidth, ceiling)
+	if setBwLimitInPlace(limit) {
+		fs.Debugf(nil, "Bandwidth limit set to %v by --bwlimit-source %v", limit, bandwidth)
+	}
+}
+
+// setBwLimitInPlace sets the bandwidth limit to bandwidth without
+// emptying the token bucket, returning whether it changed.
+//
+// If the limit is toggled off the change is made when it is toggled
+// on again.
+func setBwLimitInPlace(bandwidth fs.SizeSuffix) (changed bool) {
+	tokenBucketMu.Lock()
+	defer tokenBucketMu.Unlock()
+	targetBucket := &tokenBucket
+	if bwLimitToggledOff {
+		targetBucket = &prevTokenBucket
+	}
+	switch {
+	case bandwidth <= 0:
+		if *targetBucket == nil {
+			return false
+		}
+		*targetBucket = nil
+	case *targetBucket == nil:
+		*targetBucket = newTokenBucket(bandwidth)
+	case (*targetBucket).Limit() == rate.Limit(bandwidth):
+		return false
+	default:
+		(*targetBucket).SetLimit(rate.Limit(bandwidth))
+	}
+	return true
+}
+
+// startBwLimitSource starts polling the --bwlimit-source every
+// --bwlimit-source-interval if set.
+func startBwLimitSource() {
+	if fs.Config.BwLimitSource == "" {
+		return
+	}
+	if fs.Config.BwLimitSourceInterval <= 0 {
+		fs.Errorf(nil, "Ignoring --bwlimit-source as --bwlimit-source-interval is 0")
+		return
+	}
+	fs.Infof(nil, "Reading the bandwidth limit from --bwlimit-source %q every %v", fs.Config.BwLimitSource, fs.Config.BwLimitSourceInterval)
+	s := newBwSource(fs.Config.BwLimitSource)
+	s.poll()
+	ticker := time.NewTicker(fs.Config.BwLimitSourceInterval)
+	go func() {
+		for range ticker.C {
+			s.poll()
+		}
+	}()
+}
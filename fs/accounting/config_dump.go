@@ -0,0 +1,99 @@
+package accounting
+
+import (
+	"context"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+// bwLimitState returns the current state of the bandwidth limiter
+func bwLimitState() rc.Params {
+	currLimitMu.Lock()
+	timetable := fs.Config.BwLimit.String()
+	limitNow := fs.Config.BwLimit.LimitAt(time.Now())
+	currLimitMu.Unlock()
+
+	tokenBucketMu.Lock()
+	bytesPerSecond := int64(-1)
+	if tokenBucket != nil {
+		bytesPerSecond = int64(tokenBucket.Limit())
+	}
+	toggledOff := bwLimitToggledOff
+	tokenBucketMu.Unlock()
+
+	buckets := rc.Params{}
+	bucketLimitsMu.Lock()
+	for bucket, tb := range bucketLimits {
+		buckets[bucket] = int64(tb.Limit())
+	}
+	bucketLimitsMu.Unlock()
+
+	binds := rc.Params{}
+	bindLimitsMu.Lock()
+	for bind, tb := range bindLimits {
+		binds[bind] = int64(tb.Limit())
+	}
+	bindLimitsMu.Unlock()
+
+	return rc.Params{
+		"rate":           fs.SizeSuffix(bytesPerSecond).String(),
+		"bytesPerSecond": bytesPerSecond,
+		"timetable":      timetable,
+		"timetableRate":  limitNow.Bandwidth.String(),
+		"toggledOff":     toggledOff,
+		"buckets":        buckets,
+		"binds":          binds,
+	}
+}
+
+func rcConfigDump(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	out, err = rc.DumpOptions()
+	if err != nil {
+		return nil, err
+	}
+	out["bwlimit"] = bwLimitState()
+	return out, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "core/config/dump",
+		Fn:    rcConfigDump,
+		Title: "Show the effective configuration.",
+		Help: `
+This returns the settings rclone is actually using, after the defaults,
+the config file, the environment and the command line flags have been
+applied and including any changes made with options/set.
+
+The result has an object for each option block as returned by
+options/get, eg "main" for the global flags such as Transfers and
+Checkers, and a "bwlimit" object showing the current state of the
+bandwidth limiter:
+
+` + "```" + `
+{
+	"bwlimit": {
+		"rate": the bandwidth limit in use now, eg "1M" or "off",
+		"bytesPerSecond": the bandwidth limit in use now or -1 for off,
+		"timetable": the --bwlimit timetable,
+		"timetableRate": the bandwidth the timetable sets for now,
+		"toggledOff": whether the limit has been toggled off with SIGUSR2,
+		"buckets": the bytes per second limit of each --bwlimit-bucket,
+		"binds": the bytes per second limit of each --bwlimit-bind
+	},
+	"main": {
+		"Checkers": 8,
+		"Transfers": 4,
+		...
+	},
+	...
+}
+` + "```" + `
+
+Secrets such as passwords and the values of HTTP headers are shown as
+"REDACTED".
+`,
+	})
+}
@@ -0,0 +1,211 @@
+package accounting
+
+import (
+	"context"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/rclone/rclone/fs/rc"
+)
+
+// DirProgress is the progress of the transfers of the files in one
+// directory
+type DirProgress struct {
+	Name   string `json:"name"`   // the directory, "" for the root
+	Total  int64  `json:"total"`  // number of files queued or started
+	Done   int64  `json:"done"`   // number of files transferred
+	Errors int64  `json:"errors"` // number of files which failed
+}
+
+// dirStats holds a synchronized map of the progress of each directory
+type dirStats struct {
+	mu     sync.Mutex
+	dirs   map[string]*DirProgress
+	queued map[string]struct{} // remotes counted in Total but not started
+	failed map[string]struct{} // remotes counted in Errors
+}
+
+// newDirStats makes a new dirStats object
+func newDirStats() *dirStats {
+	return &dirStats{
+		dirs:   make(map[string]*DirProgress),
+		queued: make(map[string]struct{}),
+		failed: make(map[string]struct{}),
+	}
+}
+
+// get the progress for the directory remote is in - call with lock held
+func (ds *dirStats) get(remote string) *DirProgress {
+	dir := path.Dir(remote)
+	if dir == "." || dir == "/" {
+		dir = ""
+	}
+	dp := ds.dirs[dir]
+	if dp == nil {
+		dp = &DirProgress{Name: dir}
+		ds.dirs[dir] = dp
+	}
+	return dp
+}
+
+// add remote to the total for its directory unless it is already
+// counted - call with lock held
+func (ds *dirStats) add(remote string, dp *DirProgress) {
+	if _, found := ds.failed[remote]; found {
+		// being retried so count it as not done yet
+		delete(ds.failed, remote)
+		dp.Errors--
+		return
+	}
+	dp.Total++
+}
+
+// queue marks remote as waiting to be transferred
+func (ds *dirStats) queue(remote string) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if _, found := ds.queued[remote]; found {
+		return
+	}
+	ds.add(remote, ds.get(remote))
+	ds.queued[remote] = struct{}{}
+}
+
+// start marks remote as being transferred
+func (ds *dirStats) start(remote string) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if _, found := ds.queued[remote]; found {
+		delete(ds.queued, remote)
+		return
+	}
+	ds.add(remote, ds.get(remote))
+}
+
+// done marks remote as finished
+func (ds *dirStats) done(remote string, ok bool) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	dp := ds.get(remote)
+	if ok {
+		dp.Done++
+	} else if _, found := ds.failed[remote]; !found {
+		ds.failed[remote] = struct{}{}
+		dp.Errors++
+	}
+}
+
+// merge adds the progress from another dirStats
+func (ds *dirStats) merge(m *dirStats) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for dir, mdp := range m.dirs {
+		dp := ds.dirs[dir]
+		if dp == nil {
+			dp = &DirProgress{Name: dir}
+			ds.dirs[dir] = dp
+		}
+		dp.Total += mdp.Total
+		dp.Done += mdp.Done
+		dp.Errors += mdp.Errors
+	}
+}
+
+// reset clears the progress of all the directories
+func (ds *dirStats) reset() {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.dirs = make(map[string]*DirProgress)
+	ds.queued = make(map[string]struct{})
+	ds.failed = make(map[string]struct{})
+}
+
+// list returns the progress of each directory sorted by name
+func (ds *dirStats) list() []DirProgress {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	out := make([]DirProgress, 0, len(ds.dirs))
+	for _, dp := range ds.dirs {
+		out = append(out, *dp)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// TransferQueued counts remote as waiting to be transferred in the
+// progress of its directory
+func (s *StatsInfo) TransferQueued(remote string) {
+	s.dirs.queue(remote)
+}
+
+// DirProgress returns the progress of the transfers in each directory
+// sorted by directory name
+func (s *StatsInfo) DirProgress() []DirProgress {
+	return s.dirs.list()
+}
+
+func rcDirStats(ctx context.Context, in rc.Params) (rc.Params, error) {
+	// Check to see if we should filter by group.
+	group, err := in.GetString("group")
+	if rc.NotErrParamNotFound(err) {
+		return rc.Params{}, err
+	}
+	var s *StatsInfo
+	if group != "" {
+		s = StatsGroup(group)
+	} else {
+		s = groups.sum()
+	}
+	return rc.Params{
+		"dirs": s.DirProgress(),
+	}, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "core/stats/dirs",
+		Fn:    rcDirStats,
+		Title: "Returns the progress of the transfers in each directory.",
+		Help: `
+This returns how many of the files in each directory have been
+transferred, which gives a clearer picture of the progress of a sync
+of a deep tree than the totals in core/stats.
+
+	rclone rc core/stats/dirs
+
+A file is counted in its directory when the sync queues it for
+transfer, or when the transfer starts if it wasn't queued.  Only files
+which need transferring are counted.
+
+If group is not provided then summed up stats for all groups will be
+returned.
+
+Parameters
+
+- group - name of the stats group (string)
+
+Returns the following values:
+
+` + "```" + `
+{
+	"dirs": an array of the directories sorted by name:
+		[
+			{
+				"name": the directory, "" for the root,
+				"total": number of files to transfer,
+				"done": number of files transferred,
+				"errors": number of files which failed to transfer
+			}
+		]
+}
+` + "```" + `
+Files which fail and are retried by --retries are counted as not done
+again until they finish.
+`,
+	})
+}
@@ -0,0 +1,308 @@
+package accounting
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+const (
+	eventSocketInterval = time.Second      // how often the stats are sent to the --event-socket
+	eventClientBuffer   = 1024             // events queued for a client before it is dropped as too slow
+	eventWriteTimeout   = 10 * time.Second // longest a client can take to read an event
+)
+
+// Event types sent to the --event-socket
+const (
+	eventStats    = "stats"    // the stats as returned by core/stats
+	eventTransfer = "transfer" // a transfer has completed
+)
+
+// event is a JSON line sent to the --event-socket
+type event struct {
+	Event    string            `json:"event"` // one of the event types
+	Time     time.Time         `json:"time"`
+	Stats    rc.Params         `json:"stats,omitempty"`    // set for eventStats
+	Transfer *TransferSnapshot `json:"transfer,omitempty"` // set for eventTransfer
+}
+
+// eventClient is a connection to the --event-socket
+type eventClient struct {
+	conn   net.Conn
+	events chan []byte // JSON lines to send
+}
+
+// eventSocket sends the events to the clients of a Unix socket
+type eventSocket struct {
+	mu       sync.Mutex
+	listener net.Listener
+	clients  map[*eventClient]struct{}
+	closed   bool
+	wg       sync.WaitGroup // for the accept loop and the clients
+}
+
+// Globals
+var (
+	eventSocketMu sync.Mutex   // protects events
+	events        *eventSocket // the running event socket or nil
+)
+
+// listenEventSocket listens on the Unix socket at path.
+//
+// A socket left behind by an rclone which didn't exit cleanly is
+// removed, but not one which is still in use.  The socket can only be
+// used by the user running rclone.
+func listenEventSocket(path string) (net.Listener, error) {
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		if _, statErr := os.Stat(path); statErr != nil {
+			return nil, err
+		}
+		if conn, dialErr := net.Dial("unix", path); dialErr == nil {
+			_ = conn.Close()
+			return nil, errors.Errorf("%q is in use by another process", path)
+		}
+		fs.Debugf(nil, "Removing stale event socket %q", path)
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+		listener, err = net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// newEventSocket starts accepting clients on the Unix socket at path
+func newEventSocket(path string) (*eventSocket, error) {
+	listener, err := listenEventSocket(path)
+	if err != nil {
+		return nil, err
+	}
+	e := &eventSocket{
+		listener: listener,
+		clients:  map[*eventClient]struct{}{},
+	}
+	e.wg.Add(1)
+	go e.accept()
+	return e, nil
+}
+
+// accept adds the clients which connect until the listener is closed
+func (e *eventSocket) accept() {
+	defer e.wg.Done()
+	for {
+		conn, err := e.listener.Accept()
+		if err != nil {
+			e.mu.Lock()
+			closed := e.closed
+			e.mu.Unlock()
+			if !closed {
+				fs.Errorf(nil, "Failed to accept --event-socket client: %v", err)
+			}
+			return
+		}
+		e.add(conn)
+	}
+}
+
+// add the client connected on conn
+func (e *eventSocket) add(conn net.Conn) {
+	c := &eventClient{
+		conn:   conn,
+		events: make(chan []byte, eventClientBuffer),
+	}
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		_ = conn.Close()
+		return
+	}
+	e.clients[c] = struct{}{}
+	e.wg.Add(2)
+	e.mu.Unlock()
+	fs.Debugf(nil, "Event socket client connected")
+	go c.write(e)
+	go c.watch(e)
+}
+
+// drop removes c so no more events are queued for it and stops its
+// writer.  It is safe to call more than once.
+func (e *eventSocket) drop(c *eventClient) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.clients[c]; !ok {
+		return
+	}
+	delete(e.clients, c)
+	close(c.events)
+}
+
+// write sends the events queued for c until it is dropped or the
+// connection fails, then closes the connection.
+func (c *eventClient) write(e *eventSocket) {
+	defer e.wg.Done()
+	defer func() {
+		_ = c.conn.Close()
+	}()
+	for line := range c.events {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+		_, err := c.conn.Write(line)
+		if err != nil {
+			fs.Debugf(nil, "Event socket client disconnected: %v", err)
+			e.drop(c)
+			for range c.events {
+				// discard the rest
+			}
+			return
+		}
+	}
+}
+
+// watch reads from c until it disconnects then drops it, so a client
+// which goes away is noticed even if there are no events to send.
+//
+// Anything the client sends is ignored.
+func (c *eventClient) watch(e *eventSocket) {
+	defer e.wg.Done()
+	_, _ = io.Copy(ioutil.Discard, c.conn)
+	e.drop(c)
+}
+
+// send ev to all the clients.
+//
+// A client which has too many events queued is dropped rather than
+// holding up the transfers.
+func (e *eventSocket) send(ev *event) {
+	line, err := json.Marshal(ev)
+	if err != nil {
+		fs.Errorf(nil, "Failed to encode --event-socket event: %v", err)
+		return
+	}
+	line = append(line, '\n')
+	e.mu.Lock()
+	var slow []*eventClient
+	for c := range e.clients {
+		select {
+		case c.events <- line:
+		default:
+			slow = append(slow, c)
+		}
+	}
+	e.mu.Unlock()
+	for _, c := range slow {
+		fs.Errorf(nil, "Dropping --event-socket client which isn't reading the events")
+		e.drop(c)
+	}
+}
+
+// sendStats sends the stats at now to the clients
+func (e *eventSocket) sendStats(now time.Time) {
+	stats, err := groups.sum().RemoteStats()
+	if err != nil {
+		fs.Errorf(nil, "Failed to read the stats for --event-socket: %v", err)
+		return
+	}
+	e.send(&event{
+		Event: eventStats,
+		Time:  now,
+		Stats: stats,
+	})
+}
+
+// close stops accepting clients and closes the connections once the
+// events queued for them have been sent.
+func (e *eventSocket) close() error {
+	e.mu.Lock()
+	e.closed = true
+	clients := make([]*eventClient, 0, len(e.clients))
+	for c := range e.clients {
+		clients = append(clients, c)
+	}
+	e.mu.Unlock()
+	err := e.listener.Close()
+	for _, c := range clients {
+		e.drop(c)
+	}
+	e.wg.Wait()
+	return err
+}
+
+// eventSocketTransferDone sends an event for the completed transfer tr
+// if the --event-socket is in use
+func eventSocketTransferDone(tr *Transfer, err error) {
+	eventSocketMu.Lock()
+	e := events
+	eventSocketMu.Unlock()
+	if e == nil {
+		return
+	}
+	snapshot := tr.Snapshot()
+	snapshot.Error = err
+	e.send(&event{
+		Event:    eventTransfer,
+		Time:     snapshot.CompletedAt,
+		Transfer: &snapshot,
+	})
+}
+
+// StartEventSocket starts streaming the events to the clients of the
+// --event-socket if set.
+//
+// It returns a function to call to send the final stats and close the
+// socket.
+func StartEventSocket() func() {
+	if fs.Config.EventSocket == "" {
+		return func() {}
+	}
+	e, err := newEventSocket(fs.Config.EventSocket)
+	if err != nil {
+		fs.Errorf(nil, "Ignoring --event-socket: %v", err)
+		return func() {}
+	}
+	fs.Infof(nil, "Sending events to the clients of --event-socket %q", fs.Config.EventSocket)
+	eventSocketMu.Lock()
+	events = e
+	eventSocketMu.Unlock()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(eventSocketInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				e.sendStats(now)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		wg.Wait()
+		eventSocketMu.Lock()
+		events = nil
+		eventSocketMu.Unlock()
+		e.sendStats(time.Now())
+		err := e.close()
+		if err != nil {
+			fs.Errorf(nil, "Failed to close --event-socket: %v", err)
+		}
+	}
+}
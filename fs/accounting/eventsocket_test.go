@@ -0,0 +1,147 @@
+package accounting
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventReader reads the events sent to a client of the event socket
+type eventReader struct {
+	t       *testing.T
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+// dialEventSocket connects a client to the event socket at path
+func dialEventSocket(t *testing.T, path string) *eventReader {
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	return &eventReader{t: t, conn: conn, scanner: bufio.NewScanner(conn)}
+}
+
+// next reads the next event of type eventType skipping the others
+func (r *eventReader) next(eventType string) map[string]interface{} {
+	require.NoError(r.t, r.conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for r.scanner.Scan() {
+		var ev map[string]interface{}
+		require.NoError(r.t, json.Unmarshal(r.scanner.Bytes(), &ev))
+		if ev["event"] == eventType {
+			return ev
+		}
+	}
+	require.NoError(r.t, r.scanner.Err())
+	r.t.Fatal("event socket closed")
+	return nil
+}
+
+// waitForClients waits until e has n clients
+func waitForClients(t *testing.T, e *eventSocket, n int) {
+	assert.Eventually(t, func() bool {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		return len(e.clients) == n
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestEventSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs Unix sockets")
+	}
+	dir, err := ioutil.TempDir("", "rclone-event-socket")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "rclone.sock")
+
+	e, err := newEventSocket(path)
+	require.NoError(t, err)
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	eventSocketMu.Lock()
+	events = e
+	eventSocketMu.Unlock()
+	defer func() {
+		eventSocketMu.Lock()
+		events = nil
+		eventSocketMu.Unlock()
+	}()
+
+	// A second rclone can't use the socket while it is in use
+	_, err = newEventSocket(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "in use")
+
+	// All the clients get the events
+	client1 := dialEventSocket(t, path)
+	client2 := dialEventSocket(t, path)
+	waitForClients(t, e, 2)
+	e.sendStats(time.Now())
+	for _, client := range []*eventReader{client1, client2} {
+		ev := client.next(eventStats)
+		stats, ok := ev["stats"].(map[string]interface{})
+		require.True(t, ok)
+		assert.Contains(t, stats, "bytes")
+		assert.Contains(t, stats, "transfers")
+	}
+
+	// A client disconnecting doesn't affect the others
+	require.NoError(t, client1.conn.Close())
+	waitForClients(t, e, 1)
+	tr := newTransferRemoteSize(NewStats(), "file.txt", 42, false)
+	tr.Done(errors.New("potato"))
+	ev := client2.next(eventTransfer)
+	transfer, ok := ev["transfer"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, "file.txt", transfer["name"])
+	assert.Equal(t, float64(42), transfer["size"])
+	assert.Equal(t, "potato", transfer["error"])
+
+	// A client which doesn't read the events is dropped
+	client3 := dialEventSocket(t, path)
+	waitForClients(t, e, 2)
+	big := []byte(strings.Repeat(" ", 64*1024) + "{}\n")
+	e.mu.Lock()
+	for c := range e.clients {
+		for len(c.events) < cap(c.events) {
+			c.events <- big
+		}
+	}
+	e.mu.Unlock()
+	e.sendStats(time.Now())
+	waitForClients(t, e, 0)
+	require.NoError(t, client2.conn.Close())
+	require.NoError(t, client3.conn.Close())
+
+	// Closing removes the socket
+	require.NoError(t, e.close())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	// A stale socket is removed
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, listener.Close())
+	e, err = newEventSocket(path)
+	require.NoError(t, err)
+	require.NoError(t, e.close())
+
+	// Free the connections and the pooled buffers so they don't
+	// upset the memory measurements of the tests which follow
+	runtime.GC()
+	runtime.GC()
+}
@@ -0,0 +1,157 @@
+package accounting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fshttp"
+)
+
+// heartbeatTimeout is how long to wait for the --heartbeat-url to
+// reply
+const heartbeatTimeout = 30 * time.Second
+
+// Heartbeat statuses
+const (
+	heartbeatRunning = "running"
+	heartbeatSuccess = "success"
+	heartbeatFailed  = "failed"
+)
+
+// heartbeat is the JSON posted to the --heartbeat-url
+type heartbeat struct {
+	Status       string `json:"status"` // one of the heartbeat statuses
+	Transferring int    `json:"transferring"`
+	Summary
+}
+
+// heartbeatSender posts heartbeats to a watchdog
+type heartbeatSender struct {
+	url       string
+	client    *http.Client
+	lastBytes int64 // bytes transferred at the last heartbeat
+}
+
+// newHeartbeatSender makes a new heartbeatSender posting to url
+func newHeartbeatSender(url string) *heartbeatSender {
+	return &heartbeatSender{
+		url:       url,
+		client:    fshttp.NewClient(fs.Config),
+		lastBytes: -1,
+	}
+}
+
+// post the heartbeat to the url
+func (h *heartbeatSender) post(beat *heartbeat) error {
+	body, err := json.Marshal(beat)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), heartbeatTimeout)
+	defer cancel()
+	req, err := http.NewRequest("POST", h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("HTTP error %v", resp.Status)
+	}
+	return nil
+}
+
+// beat posts a heartbeat if the run is healthy.  It isn't healthy if
+// there has been a fatal error or if files are being transferred but
+// no bytes have been transferred since the last heartbeat.
+func (h *heartbeatSender) beat() {
+	s := groups.sum()
+	beat := &heartbeat{
+		Status:       heartbeatRunning,
+		Transferring: s.transferring.count(),
+		Summary:      s.Summary(),
+	}
+	stalled := beat.Transferring > 0 && beat.Bytes == h.lastBytes && !IsPaused()
+	h.lastBytes = beat.Bytes
+	if beat.FatalError {
+		fs.Debugf(nil, "Not sending heartbeat after fatal error")
+		return
+	}
+	if stalled {
+		fs.Debugf(nil, "Not sending heartbeat as the transfers haven't made progress")
+		return
+	}
+	err := h.post(beat)
+	if err != nil {
+		fs.Logf(nil, "Failed to send heartbeat: %v", err)
+	}
+}
+
+// finish posts the final status of the run which finished with err
+func (h *heartbeatSender) finish(err error) {
+	s := groups.sum()
+	beat := &heartbeat{
+		Status:  heartbeatSuccess,
+		Summary: s.Summary(),
+	}
+	if err != nil {
+		beat.Status = heartbeatFailed
+		beat.LastError = err.Error()
+	}
+	err = h.post(beat)
+	if err != nil {
+		fs.Errorf(nil, "Failed to send final heartbeat: %v", err)
+	}
+}
+
+// StartHeartbeat starts posting heartbeats to the --heartbeat-url
+// every --heartbeat-interval if set.
+//
+// It returns a func which should be called with the error the run
+// finished with, if any, to post the final status and stop.
+func StartHeartbeat() func(err error) {
+	if fs.Config.HeartbeatURL == "" {
+		return func(error) {}
+	}
+	if fs.Config.HeartbeatInterval <= 0 {
+		fs.Errorf(nil, "Ignoring --heartbeat-url as --heartbeat-interval is 0")
+		return func(error) {}
+	}
+	h := newHeartbeatSender(fs.Config.HeartbeatURL)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.beat()
+		ticker := time.NewTicker(fs.Config.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				h.beat()
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func(err error) {
+		close(stop)
+		wg.Wait()
+		h.finish(err)
+	}
+}
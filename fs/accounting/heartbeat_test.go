@@ -0,0 +1,93 @@
+package accounting
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeartbeat(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		beats []heartbeat
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var beat heartbeat
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&beat))
+		mu.Lock()
+		beats = append(beats, beat)
+		mu.Unlock()
+	}))
+	defer server.Close()
+	got := func() []heartbeat {
+		mu.Lock()
+		defer mu.Unlock()
+		result := beats
+		beats = nil
+		return result
+	}
+
+	s := GlobalStats()
+	s.ResetCounters()
+	defer s.ResetCounters()
+	s.Bytes(100)
+
+	h := newHeartbeatSender(server.URL)
+	h.beat()
+	result := got()
+	require.Equal(t, 1, len(result))
+	assert.Equal(t, heartbeatRunning, result[0].Status)
+	assert.Equal(t, int64(100), result[0].Bytes)
+
+	// No progress while transferring isn't healthy
+	tr := s.NewTransferRemoteSize("file", 1000)
+	h.beat()
+	assert.Equal(t, 0, len(got()))
+	s.Bytes(10)
+	h.beat()
+	assert.Equal(t, 1, len(got()))
+	tr.Done(nil)
+
+	// Nor is a fatal error
+	s.FatalError()
+	h.beat()
+	assert.Equal(t, 0, len(got()))
+
+	// The final status is always sent
+	h.finish(errors.New("potato"))
+	result = got()
+	require.Equal(t, 1, len(result))
+	assert.Equal(t, heartbeatFailed, result[0].Status)
+	assert.Equal(t, "potato", result[0].LastError)
+	s.ResetCounters()
+	h.finish(nil)
+	result = got()
+	require.Equal(t, 1, len(result))
+	assert.Equal(t, heartbeatSuccess, result[0].Status)
+
+	// Start and stop
+	oldURL, oldInterval := fs.Config.HeartbeatURL, fs.Config.HeartbeatInterval
+	defer func() {
+		fs.Config.HeartbeatURL, fs.Config.HeartbeatInterval = oldURL, oldInterval
+	}()
+	fs.Config.HeartbeatURL = ""
+	StartHeartbeat()(nil)
+	assert.Equal(t, 0, len(got()))
+	fs.Config.HeartbeatURL = server.URL
+	fs.Config.HeartbeatInterval = time.Hour
+	StartHeartbeat()(nil)
+	result = got()
+	require.Equal(t, 2, len(result))
+	assert.Equal(t, heartbeatRunning, result[0].Status)
+	assert.Equal(t, heartbeatSuccess, result[1].Status)
+}
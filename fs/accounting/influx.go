@@ -0,0 +1,283 @@
+package accounting
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fshttp"
+)
+
+// Directions of the data for the InfluxDB remote tag
+const (
+	influxDownload = "download" // read from the source remote
+	influxUpload   = "upload"   // written to the destination remote
+)
+
+// influxKey identifies the totals for one remote and direction
+type influxKey struct {
+	remote    string
+	direction string
+}
+
+// influxTotals are the totals of the completed transfers for one
+// remote and direction
+type influxTotals struct {
+	bytes     int64
+	transfers int64
+	errors    int64
+	throttle  time.Duration
+}
+
+// influxExporter pushes the stats to InfluxDB in the line protocol
+//
+// If the URL is udp://host:port the lines are batched into as few UDP
+// packets as possible, otherwise they are POSTed to the URL.
+type influxExporter struct {
+	mu      sync.Mutex
+	url     string
+	conn    net.Conn     // set if sending over UDP
+	client  *http.Client // set if sending over HTTP
+	remotes map[influxKey]*influxTotals
+}
+
+// Globals
+var (
+	influxMu sync.Mutex      // protects influx
+	influx   *influxExporter // the running exporter or nil
+)
+
+// newInfluxExporter makes a new exporter sending to rawURL
+func newInfluxExporter(rawURL string) (*influxExporter, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse InfluxDB URL")
+	}
+	e := &influxExporter{
+		url:     rawURL,
+		remotes: map[influxKey]*influxTotals{},
+	}
+	switch u.Scheme {
+	case "udp":
+		e.conn, err = net.Dial("udp", u.Host)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to connect to InfluxDB")
+		}
+	case "http", "https":
+		e.client = fshttp.NewClient(fs.Config)
+	default:
+		return nil, errors.Errorf("unsupported InfluxDB URL scheme %q - use udp, http or https", u.Scheme)
+	}
+	return e, nil
+}
+
+// influxEscaper escapes the tag values in the line protocol
+var influxEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// influxInt formats n as an integer field value
+func influxInt(n int64) string {
+	return strconv.FormatInt(n, 10) + "i"
+}
+
+// influxFloat formats f as a float field value
+func influxFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// transferDone adds bytes, waited and err for a completed transfer to
+// the totals for its source and destination remotes
+func (e *influxExporter) transferDone(src, dst fs.Info, bytes int64, waited time.Duration, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	add := func(f fs.Info, direction string) {
+		if f == nil {
+			return
+		}
+		key := influxKey{remote: f.Name(), direction: direction}
+		totals := e.remotes[key]
+		if totals == nil {
+			totals = &influxTotals{}
+			e.remotes[key] = totals
+		}
+		totals.bytes += bytes
+		totals.transfers++
+		if err != nil {
+			totals.errors++
+		}
+		totals.throttle += waited
+	}
+	add(src, influxDownload)
+	add(dst, influxUpload)
+}
+
+// lines returns the stats at now in the line protocol
+func (e *influxExporter) lines(now time.Time) []string {
+	s := groups.sum().Summary()
+	bwLimit := int64(CurrentBwLimit())
+	if bwLimit < 0 {
+		bwLimit = 0
+	}
+	timestamp := " " + strconv.FormatInt(now.UnixNano(), 10)
+	lines := []string{"rclone " + strings.Join([]string{
+		"bytes=" + influxInt(s.Bytes),
+		"transfers=" + influxInt(s.Transfers),
+		"checks=" + influxInt(s.Checks),
+		"deletes=" + influxInt(s.Deletes),
+		"renames=" + influxInt(s.Renames),
+		"errors=" + influxInt(s.Errors),
+		"speed=" + influxFloat(s.Speed),
+		"bwlimit=" + influxInt(bwLimit),
+		"throttle=" + influxFloat(s.BwLimitWait),
+	}, ",") + timestamp}
+	e.mu.Lock()
+	for key, totals := range e.remotes {
+		lines = append(lines, "rclone_remote,direction="+influxEscaper.Replace(key.direction)+",remote="+influxEscaper.Replace(key.remote)+" "+strings.Join([]string{
+			"bytes=" + influxInt(totals.bytes),
+			"transfers=" + influxInt(totals.transfers),
+			"errors=" + influxInt(totals.errors),
+			"throttle=" + influxFloat(totals.throttle.Seconds()),
+		}, ",")+timestamp)
+	}
+	e.mu.Unlock()
+	sort.Strings(lines[1:])
+	return lines
+}
+
+// send the stats at now
+func (e *influxExporter) send(now time.Time) {
+	lines := e.lines(now)
+	var err error
+	if e.conn != nil {
+		err = e.sendUDP(lines)
+	} else {
+		err = e.sendHTTP(lines)
+	}
+	if err != nil {
+		fs.Debugf(nil, "Failed to send stats to InfluxDB: %v", err)
+	}
+}
+
+// sendUDP sends the lines in packets small enough not to be
+// fragmented
+func (e *influxExporter) sendUDP(lines []string) error {
+	var buf bytes.Buffer
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		_, err := e.conn.Write(buf.Bytes())
+		buf.Reset()
+		return err
+	}
+	for _, line := range lines {
+		if buf.Len()+len(line)+1 > statsdMaxPacket {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	return flush()
+}
+
+// sendHTTP POSTs the lines to the URL
+func (e *influxExporter) sendHTTP(lines []string) error {
+	body := strings.Join(lines, "\n") + "\n"
+	req, err := http.NewRequest("POST", e.url, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	fs.CheckClose(res.Body, &err)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return errors.Errorf("HTTP error %d: %s", res.StatusCode, res.Status)
+	}
+	return err
+}
+
+// close sends the last stats and closes the connection
+func (e *influxExporter) close() error {
+	e.send(time.Now())
+	if e.conn != nil {
+		return e.conn.Close()
+	}
+	return nil
+}
+
+// influxTransferDone records a completed transfer if the stats are
+// being sent to InfluxDB
+func influxTransferDone(tr *Transfer, bytes int64, waited time.Duration, err error) {
+	influxMu.Lock()
+	e := influx
+	influxMu.Unlock()
+	if e == nil {
+		return
+	}
+	tr.mu.RLock()
+	dstFs := tr.dstFs
+	tr.mu.RUnlock()
+	e.transferDone(tr.srcFs, dstFs, bytes, waited, err)
+}
+
+// StartStatsInflux starts sending the stats to the --stats-influx URL
+// in the InfluxDB line protocol every interval if set.
+//
+// It returns a func which should be called to send the last stats
+// and stop.
+func StartStatsInflux(interval time.Duration) func() {
+	if fs.Config.StatsInflux == "" {
+		return func() {}
+	}
+	if interval <= 0 {
+		fs.Errorf(nil, "Ignoring --stats-influx as --stats is 0")
+		return func() {}
+	}
+	e, err := newInfluxExporter(fs.Config.StatsInflux)
+	if err != nil {
+		fs.Errorf(nil, "Ignoring --stats-influx: %v", err)
+		return func() {}
+	}
+	influxMu.Lock()
+	influx = e
+	influxMu.Unlock()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				e.send(now)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		wg.Wait()
+		influxMu.Lock()
+		influx = nil
+		influxMu.Unlock()
+		err := e.close()
+		if err != nil {
+			fs.Errorf(nil, "Failed to close InfluxDB connection: %v", err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package accounting
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfluxLines(t *testing.T) {
+	e := &influxExporter{remotes: map[influxKey]*influxTotals{}}
+	s := GlobalStats()
+	s.ResetCounters()
+	s.Bytes(150)
+	s.Errors(1)
+	defer s.ResetCounters()
+
+	src := mockfs.NewFs("my src", "root")
+	dst := mockfs.NewFs("dst,1", "root")
+	e.transferDone(src, dst, 100, 2*time.Second, nil)
+	e.transferDone(src, nil, 50, 0, errors.New("potato"))
+
+	lines := e.lines(time.Unix(1, 5))
+	require.Equal(t, 3, len(lines))
+	assert.True(t, strings.HasPrefix(lines[0], "rclone bytes=150i,transfers=0i,"), lines[0])
+	assert.Contains(t, lines[0], ",errors=1i,")
+	assert.Contains(t, lines[0], ",bwlimit=0i,")
+	assert.True(t, strings.HasSuffix(lines[0], " 1000000005"), lines[0])
+	assert.Equal(t, `rclone_remote,direction=download,remote=my\ src bytes=150i,transfers=2i,errors=1i,throttle=2 1000000005`, lines[1])
+	assert.Equal(t, `rclone_remote,direction=upload,remote=dst\,1 bytes=100i,transfers=1i,errors=0i,throttle=2 1000000005`, lines[2])
+}
+
+func TestInfluxUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	e, err := newInfluxExporter("udp://" + conn.LocalAddr().String())
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		e.transferDone(mockfs.NewFs(strings.Repeat("x", i), "root"), nil, 1, 0, nil)
+	}
+	require.NoError(t, e.close())
+
+	var lines []string
+	for len(lines) < 101 {
+		buf := make([]byte, 2*statsdMaxPacket)
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		assert.True(t, n <= statsdMaxPacket, "packet too big %d", n)
+		assert.True(t, strings.HasSuffix(string(buf[:n]), "\n"))
+		lines = append(lines, strings.Split(strings.TrimSuffix(string(buf[:n]), "\n"), "\n")...)
+	}
+	assert.Equal(t, 101, len(lines))
+	assert.True(t, strings.HasPrefix(lines[0], "rclone "), lines[0])
+}
+
+func TestInfluxHTTP(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/write", r.URL.Path)
+		assert.Equal(t, "rclone", r.URL.Query().Get("db"))
+		buf, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		body = string(buf)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	oldInflux := fs.Config.StatsInflux
+	defer func() {
+		fs.Config.StatsInflux = oldInflux
+	}()
+	fs.Config.StatsInflux = server.URL + "/write?db=rclone"
+	stop := StartStatsInflux(time.Hour)
+	s := NewStats()
+	tr := newTransferRemoteSize(s, "file", 100, false)
+	tr.srcFs = mockfs.NewFs("src", "root")
+	tr.SetDst(mockfs.NewFs("dst", "root"), "file")
+	tr.Done(nil)
+	stop()
+
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	require.Equal(t, 3, len(lines))
+	assert.True(t, strings.HasPrefix(lines[0], "rclone "), lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "rclone_remote,direction=download,remote=src bytes=0i,transfers=1i,errors=0i"), lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "rclone_remote,direction=upload,remote=dst bytes=0i,transfers=1i,errors=0i"), lines[2])
+
+	// Bad URLs are rejected
+	_, err := newInfluxExporter("potato://localhost")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported InfluxDB URL scheme")
+}
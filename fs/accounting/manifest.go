@@ -0,0 +1,204 @@
+package accounting
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// manifestFile is a file transferred in the --write-manifest
+type manifestFile struct {
+	Path   string            `json:"path"`             // path of the file relative to the destination
+	Size   int64             `json:"size"`             // size of the file or -1 if unknown
+	Hashes map[string]string `json:"hashes,omitempty"` // hashes of the file checked after the transfer keyed by hash type
+}
+
+// manifestFailure is a file which failed to transfer in the
+// --write-manifest
+type manifestFailure struct {
+	Path   string `json:"path"`   // path of the file relative to the destination
+	Source string `json:"source"` // where the transfer was from
+	Error  string `json:"error"`  // the error it failed with
+}
+
+// manifest is the JSON written to the --write-manifest
+type manifest struct {
+	Created  time.Time         `json:"created"`  // when the manifest was written
+	Dest     string            `json:"dest"`     // the destination the paths are relative to
+	Complete bool              `json:"complete"` // set if none of the transfers failed
+	Files    []manifestFile    `json:"files"`    // the files transferred sorted by path
+	Failed   []manifestFailure `json:"failed"`   // the files which failed sorted by path
+}
+
+// manifestWriter collects the transfers for the --write-manifest
+type manifestWriter struct {
+	mu     sync.Mutex
+	path   string                     // file to write the manifest to
+	dest   string                     // the destination of the first transfer
+	files  map[string]manifestFile    // keyed by full destination path
+	failed map[string]manifestFailure // keyed by full destination path
+}
+
+// Globals
+var (
+	manifestMu  sync.Mutex      // protects manifestOut
+	manifestOut *manifestWriter // the manifest being collected or nil
+)
+
+// newManifestWriter makes a new manifestWriter writing to path
+func newManifestWriter(path string) *manifestWriter {
+	return &manifestWriter{
+		path:   path,
+		files:  map[string]manifestFile{},
+		failed: map[string]manifestFailure{},
+	}
+}
+
+// transferDone records tr which finished with err.
+//
+// If a file is transferred more than once, say if it failed and
+// succeeded when the sync was retried, only the last result is kept.
+func (m *manifestWriter) transferDone(tr *Transfer, err error) {
+	source := tr.remote
+	if tr.srcFs != nil {
+		source = objectPath(tr.srcFs, tr.remote)
+	}
+	tr.mu.RLock()
+	dst, dstFs, dstRemote := tr.dst, tr.dstFs, tr.dstRemote
+	var hashes map[string]string
+	if err == nil && len(tr.hashes) > 0 {
+		hashes = make(map[string]string, len(tr.hashes))
+		for ht, sum := range tr.hashes {
+			hashes[ht] = sum
+		}
+	}
+	tr.mu.RUnlock()
+	if dst == "" {
+		if err == nil {
+			// Not a copy to a destination so not in the manifest
+			return
+		}
+		dst = source
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path := dst
+	if dstFs != nil {
+		root := objectPath(dstFs, "")
+		if m.dest == "" {
+			m.dest = root
+		}
+		if root == m.dest {
+			path = dstRemote
+		}
+	}
+	if err == nil {
+		delete(m.failed, dst)
+		m.files[dst] = manifestFile{
+			Path:   path,
+			Size:   tr.size,
+			Hashes: hashes,
+		}
+	} else {
+		delete(m.files, dst)
+		m.failed[dst] = manifestFailure{
+			Path:   path,
+			Source: source,
+			Error:  err.Error(),
+		}
+	}
+}
+
+// manifest returns the manifest of the transfers so far
+func (m *manifestWriter) manifest() *manifest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := &manifest{
+		Created:  time.Now(),
+		Dest:     m.dest,
+		Complete: len(m.failed) == 0,
+		Files:    make([]manifestFile, 0, len(m.files)),
+		Failed:   make([]manifestFailure, 0, len(m.failed)),
+	}
+	for _, file := range m.files {
+		out.Files = append(out.Files, file)
+	}
+	for _, failure := range m.failed {
+		out.Failed = append(out.Failed, failure)
+	}
+	sort.Slice(out.Files, func(i, j int) bool {
+		return out.Files[i].Path < out.Files[j].Path
+	})
+	sort.Slice(out.Failed, func(i, j int) bool {
+		return out.Failed[i].Path < out.Failed[j].Path
+	})
+	return out
+}
+
+// write the manifest to the file.
+//
+// It is written to a temporary file which is renamed over the file
+// so it is never left half written.
+func (m *manifestWriter) write() error {
+	buf, err := json.MarshalIndent(m.manifest(), "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := m.path + ".partial"
+	err = ioutil.WriteFile(tmp, append(buf, '\n'), 0666)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, m.path)
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// manifestTransferDone records tr in the --write-manifest if it is
+// being collected
+func manifestTransferDone(tr *Transfer, err error) {
+	manifestMu.Lock()
+	m := manifestOut
+	manifestMu.Unlock()
+	if m != nil {
+		m.transferDone(tr, err)
+	}
+}
+
+// StartManifest starts collecting the transfers for the
+// --write-manifest if set.
+//
+// It returns a func which should be called at the end of the run to
+// write the manifest, which lists the files transferred with their
+// sizes and hashes and the files which failed separately.
+func StartManifest() func() {
+	if fs.Config.WriteManifest == "" {
+		return func() {}
+	}
+	if fs.Config.DryRun {
+		fs.Logf(nil, "Not writing --write-manifest as --dry-run is set")
+		return func() {}
+	}
+	m := newManifestWriter(fs.Config.WriteManifest)
+	manifestMu.Lock()
+	manifestOut = m
+	manifestMu.Unlock()
+	return func() {
+		manifestMu.Lock()
+		manifestOut = nil
+		manifestMu.Unlock()
+		err := m.write()
+		if err != nil {
+			fs.Errorf(nil, "Failed to write --write-manifest %q: %v", m.path, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package accounting
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-manifest")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	manifestPath := filepath.Join(dir, "manifest.json")
+
+	oldWriteManifest := fs.Config.WriteManifest
+	defer func() {
+		fs.Config.WriteManifest = oldWriteManifest
+	}()
+	fs.Config.WriteManifest = manifestPath
+	stop := StartManifest()
+
+	s := NewStats()
+	srcFs := mockfs.NewFs("src", "dir")
+	dstFs := mockfs.NewFs("dst", "backup")
+	transfer := func(remote string, dstFs fs.Fs, err error) {
+		src := mockobject.New(remote).WithContent([]byte("hello"), mockobject.SeekModeNone)
+		src.SetFs(srcFs)
+		tr := s.NewTransfer(src)
+		tr.SetDst(dstFs, remote)
+		if err == nil {
+			tr.SetHash(hash.MD5, "5d41402abc4b2a76b9719d911017c592")
+		}
+		tr.Done(err)
+	}
+	transfer("b/file", dstFs, nil)
+	transfer("a", dstFs, nil)
+	transfer("failed", dstFs, errors.New("potato"))
+	// failed then succeeded on a retry
+	transfer("retried", dstFs, errors.New("potato"))
+	transfer("retried", dstFs, nil)
+	// to somewhere else
+	transfer("other", mockfs.NewFs("other", "root"), nil)
+
+	// Checks aren't transfers
+	tr := s.NewCheckingTransfer(mockobject.New("checked"))
+	tr.Done(nil)
+	stop()
+
+	buf, err := ioutil.ReadFile(manifestPath)
+	require.NoError(t, err)
+	var m manifest
+	require.NoError(t, json.Unmarshal(buf, &m))
+	assert.Equal(t, "dst:backup/", m.Dest)
+	assert.False(t, m.Complete)
+	md5 := map[string]string{"MD5": "5d41402abc4b2a76b9719d911017c592"}
+	assert.Equal(t, []manifestFile{
+		{Path: "a", Size: 5, Hashes: md5},
+		{Path: "b/file", Size: 5, Hashes: md5},
+		{Path: "other:root/other", Size: 5, Hashes: md5},
+		{Path: "retried", Size: 5, Hashes: md5},
+	}, m.Files)
+	assert.Equal(t, []manifestFailure{
+		{Path: "failed", Source: "src:dir/failed", Error: "potato"},
+	}, m.Failed)
+
+	// The temporary file is gone
+	_, err = os.Stat(manifestPath + ".partial")
+	assert.True(t, os.IsNotExist(err))
+
+	// Nothing is collected when the manifest is stopped
+	tr = s.NewTransferRemoteSize("after", 1)
+	tr.Done(nil)
+	manifestMu.Lock()
+	assert.Nil(t, manifestOut)
+	manifestMu.Unlock()
+}
@@ -0,0 +1,53 @@
+package accounting
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+)
+
+// minSpeed measures the speed of a transfer to check it is keeping
+// up --min-transfer-speed.
+//
+// The speed is measured over consecutive windows of
+// --min-transfer-speed-window.  The time spent waiting for the
+// bandwidth limiter is taken out of the window so a transfer held
+// up by --bwlimit isn't thought slow.
+type minSpeed struct {
+	start  time.Time     // start of the current window
+	bytes  int64         // bytes read in the window
+	wait   time.Duration // time spent waiting for the bandwidth limiter in the window
+	ramped bool          // set once the first window is over
+}
+
+// check the speed at now returning an error if the transfer was too
+// slow over the window which has just finished.
+//
+// The first window after a transfer starts isn't checked to give it
+// time to get up to speed.
+func (ms *minSpeed) check(now time.Time, min fs.SizeSuffix, window time.Duration) error {
+	if ms.start.IsZero() {
+		ms.start = now
+		return nil
+	}
+	elapsed := now.Sub(ms.start)
+	if elapsed < window {
+		return nil
+	}
+	ramped, bytes, active := ms.ramped, ms.bytes, elapsed-ms.wait
+	*ms = minSpeed{start: now, ramped: true}
+	if !ramped {
+		return nil
+	}
+	// Waiting for the bandwidth limiter nearly all the time so
+	// can't tell how fast the transfer would go
+	if active < time.Second {
+		return nil
+	}
+	speed := float64(bytes) / active.Seconds()
+	if speed >= float64(min) {
+		return nil
+	}
+	return errors.Errorf("transfer speed %vBytes/s is below --min-transfer-speed %vBytes/s over %v", fs.SizeSuffix(speed), min, window)
+}
@@ -0,0 +1,156 @@
+package accounting
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/process"
+)
+
+// pauseWhileRunningInterval is how often to check for the
+// --pause-while-running processes
+var pauseWhileRunningInterval = 5 * time.Second
+
+// paused holds the reasons the transfers are paused for
+var paused = struct {
+	mu      sync.Mutex
+	reasons map[string]struct{}
+	changed chan struct{} // closed and remade when resumed or woken
+}{
+	reasons: make(map[string]struct{}),
+	changed: make(chan struct{}),
+}
+
+// broadcastPaused wakes up the reads waiting for the transfers to be
+// resumed - call with paused.mu held
+func broadcastPaused() {
+	close(paused.changed)
+	paused.changed = make(chan struct{})
+}
+
+// Pause pauses all transfers for reason until Resume is called with
+// the same reason.  Transfers stop at their next read so they can
+// carry on where they left off.
+func Pause(reason string) {
+	paused.mu.Lock()
+	defer paused.mu.Unlock()
+	paused.reasons[reason] = struct{}{}
+}
+
+// Resume resumes the transfers paused for reason unless they are
+// paused for other reasons too.
+func Resume(reason string) {
+	paused.mu.Lock()
+	defer paused.mu.Unlock()
+	if _, found := paused.reasons[reason]; !found {
+		return
+	}
+	delete(paused.reasons, reason)
+	if len(paused.reasons) == 0 {
+		// Don't let the transfers burst with the tokens saved up
+		// while paused
+		emptyTokenBuckets()
+		broadcastPaused()
+	}
+}
+
+// IsPaused returns true if the transfers are paused
+func IsPaused() bool {
+	paused.mu.Lock()
+	defer paused.mu.Unlock()
+	return len(paused.reasons) != 0
+}
+
+// waitPaused waits while the transfers are paused unless acc is
+// cancelled, returning whether it had to wait.
+//
+// It returns the error of the context of acc if that is done while
+// waiting.
+func (acc *Account) waitPaused() (waited bool, err error) {
+	ctx := acc.ctx
+	paused.mu.Lock()
+	defer paused.mu.Unlock()
+	for len(paused.reasons) != 0 && !acc.cancelled() {
+		waited = true
+		changed := paused.changed
+		paused.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+		}
+		paused.mu.Lock()
+		if err = ctx.Err(); err != nil {
+			return waited, err
+		}
+	}
+	return waited, nil
+}
+
+// wakePaused wakes up a read of acc waiting for the transfers to be
+// resumed, eg because it has been cancelled
+func (acc *Account) wakePaused() {
+	paused.mu.Lock()
+	defer paused.mu.Unlock()
+	broadcastPaused()
+}
+
+// runningProcess returns the first of names which is running or ""
+func runningProcess(names []string) (string, error) {
+	for _, name := range names {
+		running, err := process.Running(name)
+		if err != nil {
+			return "", err
+		}
+		if running {
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+// StartPauseWhileRunning starts pausing the transfers while any of
+// the --pause-while-running processes is running if set.
+//
+// It returns a func which should be called to stop.
+func StartPauseWhileRunning() func() {
+	names := fs.Config.PauseWhileRunning
+	if len(names) == 0 {
+		return func() {}
+	}
+	const reason = "pause-while-running"
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer Resume(reason)
+		ticker := time.NewTicker(pauseWhileRunningInterval)
+		defer ticker.Stop()
+		pausedFor := ""
+		for {
+			name, err := runningProcess(names)
+			if err != nil {
+				fs.Errorf(nil, "Ignoring --pause-while-running: %v", err)
+				return
+			}
+			if name != "" && pausedFor == "" {
+				fs.Logf(nil, "Pausing transfers while %q is running", name)
+				Pause(reason)
+			} else if name == "" && pausedFor != "" {
+				fs.Logf(nil, "Resuming transfers as %q is no longer running", pausedFor)
+				Resume(reason)
+			}
+			pausedFor = name
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		wg.Wait()
+	}
+}
@@ -0,0 +1,148 @@
+package accounting
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountPause(t *testing.T) {
+	in := ioutil.NopCloser(bytes.NewBuffer(make([]byte, 100)))
+	acc := newAccountSizeName(context.Background(), NewStats(), in, 100, "test")
+	defer acc.Done()
+	buf := make([]byte, 10)
+
+	Pause("test")
+	assert.True(t, IsPaused())
+	done := make(chan struct{})
+	go func() {
+		_, err := acc.Read(buf)
+		assert.NoError(t, err)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("read didn't wait while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Resuming for a different reason does nothing
+	Resume("potato")
+	assert.True(t, IsPaused())
+
+	Resume("test")
+	assert.False(t, IsPaused())
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("read didn't carry on after resuming")
+	}
+}
+
+func TestAccountPauseCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in := ioutil.NopCloser(bytes.NewBuffer(make([]byte, 100)))
+	acc := newAccountSizeName(ctx, NewStats(), in, 100, "test")
+	defer acc.Done()
+	buf := make([]byte, 10)
+
+	Pause("test")
+	defer Resume("test")
+	done := make(chan error)
+	go func() {
+		_, err := acc.Read(buf)
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// Cancelling the transfer stops the read while still paused
+	cancel()
+	select {
+	case err := <-done:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("read didn't stop when cancelled")
+	}
+	assert.True(t, IsPaused())
+}
+
+func TestResumeEmptiesTokenBucket(t *testing.T) {
+	tokenBucketMu.Lock()
+	oldTokenBucket := tokenBucket
+	tokenBucket = newTokenBucket(10 * 1024 * 1024)
+	tokenBucketMu.Unlock()
+	defer func() {
+		tokenBucketMu.Lock()
+		tokenBucket = oldTokenBucket
+		tokenBucketMu.Unlock()
+	}()
+
+	Pause("test")
+	time.Sleep(200 * time.Millisecond)
+	Resume("test")
+
+	tokenBucketMu.Lock()
+	defer tokenBucketMu.Unlock()
+	assert.False(t, tokenBucket.AllowN(time.Now(), 1024*1024), "token bucket should be empty after resuming")
+}
+
+func TestStartPauseWhileRunning(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sleep")
+	}
+	oldInterval, oldPauseWhileRunning := pauseWhileRunningInterval, fs.Config.PauseWhileRunning
+	defer func() {
+		pauseWhileRunningInterval, fs.Config.PauseWhileRunning = oldInterval, oldPauseWhileRunning
+	}()
+	pauseWhileRunningInterval = 10 * time.Millisecond
+	fs.Config.PauseWhileRunning = []string{"rclone-no-such-process", "rclonetestsleep"}
+
+	// Run sleep under a name nothing else will be using
+	dir, err := ioutil.TempDir("", "rclone-pause-test")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	sleep := filepath.Join(dir, "rclonetestsleep")
+	src, err := exec.LookPath("sleep")
+	require.NoError(t, err)
+	data, err := ioutil.ReadFile(src)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(sleep, data, 0755))
+
+	waitFor := func(want bool) {
+		for i := 0; i < 500 && IsPaused() != want; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.Equal(t, want, IsPaused())
+	}
+
+	cmd := exec.Command(sleep, "30")
+	require.NoError(t, cmd.Start())
+	stop := StartPauseWhileRunning()
+	waitFor(true)
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+	waitFor(false)
+
+	// Stopping resumes the transfers
+	cmd = exec.Command(sleep, "30")
+	require.NoError(t, cmd.Start())
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	waitFor(true)
+	stop()
+	assert.False(t, IsPaused())
+}
@@ -0,0 +1,140 @@
+package accounting
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"golang.org/x/time/rate"
+)
+
+// bwReservations shares the --bwlimit out between the transfers in
+// progress when --min-transfer-rate is set.
+//
+// Each transfer is guaranteed a slice of the limit of up to
+// --min-transfer-rate and the transfers compete for the remainder.
+var bwReservations = struct {
+	mu     sync.Mutex
+	active map[*bwReservation]struct{} // the transfers in progress
+	shared *rate.Limiter               // the bandwidth left over from the guaranteed slices
+}{
+	active: map[*bwReservation]struct{}{},
+}
+
+// bwReservation is the guaranteed slice of the bandwidth limit for
+// one transfer
+type bwReservation struct {
+	tb *rate.Limiter
+}
+
+// newBwReservation reserves a slice of the bandwidth limit for a new
+// transfer if --min-transfer-rate is set, otherwise it returns nil.
+//
+// It must be released with release when the transfer is finished.
+func newBwReservation() *bwReservation {
+	if fs.Config.MinTransferRate <= 0 {
+		return nil
+	}
+	r := &bwReservation{
+		tb: newTokenBucket(fs.Config.MinTransferRate),
+	}
+	bwReservations.mu.Lock()
+	bwReservations.active[r] = struct{}{}
+	if bwReservations.shared == nil {
+		bwReservations.shared = newTokenBucket(fs.Config.MinTransferRate)
+	}
+	bwReservations.mu.Unlock()
+	return r
+}
+
+// release the slice of the bandwidth limit so it is shared between
+// the other transfers
+func (r *bwReservation) release() {
+	if r == nil {
+		return
+	}
+	bwReservations.mu.Lock()
+	delete(bwReservations.active, r)
+	bwReservations.mu.Unlock()
+}
+
+// share the bandwidth limit between the active transfers, returning
+// the guaranteed rate for each transfer and the rate left over for
+// them to compete for.
+func share(limit rate.Limit, active int) (guaranteed, remainder rate.Limit) {
+	guaranteed = rate.Limit(fs.Config.MinTransferRate)
+	if active <= 0 {
+		return guaranteed, limit
+	}
+	if guaranteed*rate.Limit(active) > limit {
+		guaranteed = limit / rate.Limit(active)
+	}
+	return guaranteed, limit - guaranteed*rate.Limit(active)
+}
+
+// wait sleeps for the correct amount of time for the passage of n
+// bytes according to the bandwidth limit, taking the bytes from
+// whichever of the guaranteed slice for this transfer or the shared
+// remainder has them available first.
+//
+// This doesn't hold tokenBucketMu while waiting so transfers waiting
+// for their slice don't hold up the others.
+//
+// It returns true if there is a bandwidth limit and an error if ctx
+// was cancelled while waiting.
+func (r *bwReservation) wait(ctx context.Context, n int) (limited bool, err error) {
+	tokenBucketMu.Lock()
+	tb := tokenBucket
+	tokenBucketMu.Unlock()
+	if tb == nil {
+		return false, nil
+	}
+	// The reservations fail if n is bigger than the burst size so
+	// wait for big requests a burst at a time
+	for ; n > maxBurstSize; n -= maxBurstSize {
+		err = r.waitBurst(ctx, tb, maxBurstSize)
+		if err != nil {
+			return true, err
+		}
+	}
+	return true, r.waitBurst(ctx, tb, n)
+}
+
+// waitBurst does the work of wait for n bytes which must be no
+// bigger than the burst size.
+func (r *bwReservation) waitBurst(ctx context.Context, tb *rate.Limiter, n int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := time.Now()
+	bwReservations.mu.Lock()
+	guaranteed, remainder := share(tb.Limit(), len(bwReservations.active))
+	if r.tb.Limit() != guaranteed {
+		r.tb.SetLimitAt(now, guaranteed)
+	}
+	shared := bwReservations.shared
+	if shared.Limit() != remainder {
+		shared.SetLimitAt(now, remainder)
+	}
+	own := r.tb.ReserveN(now, n)
+	var fromShared *rate.Reservation
+	if remainder > 0 {
+		fromShared = shared.ReserveN(now, n)
+	}
+	bwReservations.mu.Unlock()
+
+	used, delay := own, own.DelayFrom(now)
+	if fromShared != nil && fromShared.OK() {
+		if sharedDelay := fromShared.DelayFrom(now); !own.OK() || sharedDelay < delay {
+			own.CancelAt(now)
+			used, delay = fromShared, sharedDelay
+		} else {
+			fromShared.CancelAt(now)
+		}
+	} else if !own.OK() {
+		fs.Errorf(nil, "Token bucket error: can't reserve %d bytes", n)
+		return nil
+	}
+	return waitReservation(ctx, used, delay)
+}
@@ -0,0 +1,126 @@
+package accounting
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	speedBucketsPerDoubling = 8 // so each bucket is about 9% wide
+	speedBuckets            = 1 + 48*speedBucketsPerDoubling
+)
+
+// SpeedPercentiles are the percentiles of the speed of a stats group
+// sampled every second while transferring
+type SpeedPercentiles struct {
+	P50     float64 `json:"p50"`     // median speed in bytes/s
+	P95     float64 `json:"p95"`     // 95th percentile speed in bytes/s
+	P99     float64 `json:"p99"`     // 99th percentile speed in bytes/s
+	Samples int64   `json:"samples"` // number of seconds sampled
+}
+
+// speedStats keeps a histogram of the speed of a stats group sampled
+// every second for --stats-percentiles.
+//
+// The speeds are counted in fixed logarithmic buckets so recording a
+// sample is cheap and the memory used doesn't grow with the length of
+// the run.
+type speedStats struct {
+	mu      sync.Mutex
+	second  int64 // the unix time of the second being sampled
+	bytes   int64 // bytes transferred in that second so far
+	counts  [speedBuckets]int64
+	samples int64
+}
+
+// newSpeedStats makes a new speedStats object
+func newSpeedStats() *speedStats {
+	return &speedStats{}
+}
+
+// speedBucket returns the bucket for speed in bytes/s
+func speedBucket(speed int64) int {
+	if speed <= 0 {
+		return 0
+	}
+	i := 1 + int(math.Log2(float64(speed))*speedBucketsPerDoubling)
+	if i >= speedBuckets {
+		i = speedBuckets - 1
+	}
+	return i
+}
+
+// speedBucketValue returns the speed in the middle of bucket i
+func speedBucketValue(i int) float64 {
+	if i == 0 {
+		return 0
+	}
+	return math.Exp2((float64(i-1) + 0.5) / speedBucketsPerDoubling)
+}
+
+// add n bytes transferred by one transfer in the second before now.
+//
+// The bytes from all the transfers in each second are added up into
+// one sample when the next second starts.
+func (ss *speedStats) add(now time.Time, n int64) {
+	second := now.Unix()
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if second != ss.second {
+		if ss.second != 0 {
+			ss.counts[speedBucket(ss.bytes)]++
+			ss.samples++
+		}
+		ss.second = second
+		ss.bytes = 0
+	}
+	ss.bytes += n
+}
+
+// percentiles returns the percentiles of the speeds sampled so far
+func (ss *speedStats) percentiles() SpeedPercentiles {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	p := SpeedPercentiles{Samples: ss.samples}
+	if ss.samples == 0 {
+		return p
+	}
+	percentile := func(q float64) float64 {
+		rank := int64(math.Ceil(q * float64(ss.samples)))
+		var total int64
+		for i, count := range ss.counts {
+			total += count
+			if total >= rank {
+				return speedBucketValue(i)
+			}
+		}
+		return speedBucketValue(speedBuckets - 1)
+	}
+	p.P50 = percentile(0.50)
+	p.P95 = percentile(0.95)
+	p.P99 = percentile(0.99)
+	return p
+}
+
+// merge the samples of ssToMerge into ss
+func (ss *speedStats) merge(ssToMerge *speedStats) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ssToMerge.mu.Lock()
+	defer ssToMerge.mu.Unlock()
+	for i, count := range ssToMerge.counts {
+		ss.counts[i] += count
+	}
+	ss.samples += ssToMerge.samples
+}
+
+// reset the samples
+func (ss *speedStats) reset() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.second = 0
+	ss.bytes = 0
+	ss.counts = [speedBuckets]int64{}
+	ss.samples = 0
+}
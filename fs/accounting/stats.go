@@ -10,6 +10,7 @@ import (
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/fshttp"
 	"github.com/rclone/rclone/fs/rc"
 )
 
@@ -37,7 +38,24 @@ type StatsInfo struct {
 	renameQueue       int
 	renameQueueSize   int64
 	deletes           int64
+	deletesStart      time.Time // time of the first delete
+	deletesLast       time.Time // time of the last delete
+	partialsCleaned   int64
+	dirMarkers        int64
+	postFileCmdErrors int64
+	bwLimitWait       time.Duration // time transfers spent waiting for the bandwidth limit
+	serverMoves       int64
+	serverMoveBytes   int64
+	tooLarge          int64
+	tooLargeBytes     int64
+	deduped           int64
+	dedupedBytes      int64
+	sourceChanged     int64
+	copiesResumed     int64 // resumable server side copies which carried on from an interrupted one
+	copiesFresh       int64 // resumable server side copies which started from the beginning
 	inProgress        *inProgress
+	dirs              *dirStats
+	speeds            *speedStats   // samples the speed for --stats-percentiles
 	startedTransfers  []*Transfer   // currently active transfers
 	oldTimeRanges     timeRanges    // a merged list of time ranges for the transfers
 	oldDuration       time.Duration // duration of transfers we have culled
@@ -50,6 +68,8 @@ func NewStats() *StatsInfo {
 		checking:     newStringSet(fs.Config.Checkers, "checking"),
 		transferring: newStringSet(fs.Config.Transfers, "transferring"),
 		inProgress:   newInProgress(),
+		dirs:         newDirStats(),
+		speeds:       newSpeedStats(),
 	}
 }
 
@@ -65,9 +85,45 @@ func (s *StatsInfo) RemoteStats() (out rc.Params, err error) {
 	out["checks"] = s.checks
 	out["transfers"] = s.transfers
 	out["deletes"] = s.deletes
+	out["deletesPerSecond"] = s.deleteRate()
 	out["renames"] = s.renames
+	out["partialsCleaned"] = s.partialsCleaned
+	out["dirMarkers"] = s.dirMarkers
+	out["postFileCmdErrors"] = s.postFileCmdErrors
+	out["serverSideMoves"] = s.serverMoves
+	out["serverSideMoveBytes"] = s.serverMoveBytes
+	out["skippedTooLarge"] = s.tooLarge
+	out["skippedTooLargeBytes"] = s.tooLargeBytes
+	out["deduplicated"] = s.deduped
+	out["deduplicatedBytes"] = s.dedupedBytes
+	out["sourceChanged"] = s.sourceChanged
+	out["serverSideCopiesResumed"] = s.copiesResumed
+	out["serverSideCopiesFresh"] = s.copiesFresh
 	out["elapsedTime"] = s.totalDuration().Seconds()
+	out["bwLimitWait"] = s.bwLimitWait.Seconds()
 	s.mu.RUnlock()
+	if fs.Config.StatsPercentiles {
+		p := s.speeds.percentiles()
+		out["speedPercentiles"] = rc.Params{
+			"p50":     p.P50,
+			"p95":     p.P95,
+			"p99":     p.P99,
+			"samples": p.Samples,
+		}
+	}
+	if listStats := fshttp.GetListStats(); len(listStats) > 0 {
+		// These are for all the groups as the HTTP transport
+		// doesn't know which group the calls are for
+		listing := make([]rc.Params, 0, len(listStats))
+		for _, stats := range listStats {
+			listing = append(listing, rc.Params{
+				"name":  stats.Name,
+				"calls": stats.Calls,
+				"wait":  stats.Wait.Seconds(),
+			})
+		}
+		out["listing"] = listing
+	}
 	if !s.checking.empty() {
 		var c []string
 		s.checking.mu.RLock()
@@ -330,15 +386,55 @@ func (s *StatsInfo) String() string {
 				s.checks, totalChecks, percent(s.checks, totalChecks))
 		}
 		if s.deletes != 0 {
-			_, _ = fmt.Fprintf(buf, "Deleted:       %10d\n", s.deletes)
+			deleteRate := ""
+			if rate := s.deleteRate(); rate > 0 {
+				deleteRate = fmt.Sprintf(", %.1f/s", rate)
+			}
+			_, _ = fmt.Fprintf(buf, "Deleted:       %10d%s\n", s.deletes, deleteRate)
 		}
 		if s.renames != 0 {
 			_, _ = fmt.Fprintf(buf, "Renamed:       %10d\n", s.renames)
 		}
+		if s.serverMoves != 0 {
+			_, _ = fmt.Fprintf(buf, "Server moved:  %10d, %s\n", s.serverMoves, fs.SizeSuffix(s.serverMoveBytes).Unit("Bytes"))
+		}
+		if s.partialsCleaned != 0 {
+			_, _ = fmt.Fprintf(buf, "Cleaned up:    %10d\n", s.partialsCleaned)
+		}
+		if s.dirMarkers != 0 {
+			_, _ = fmt.Fprintf(buf, "Dir markers:   %10d\n", s.dirMarkers)
+		}
+		if s.postFileCmdErrors != 0 {
+			_, _ = fmt.Fprintf(buf, "Hook errors:   %10d\n", s.postFileCmdErrors)
+		}
+		if s.tooLarge != 0 {
+			_, _ = fmt.Fprintf(buf, "Skipped large: %10d, %s\n", s.tooLarge, fs.SizeSuffix(s.tooLargeBytes).Unit("Bytes"))
+		}
+		if s.deduped != 0 {
+			_, _ = fmt.Fprintf(buf, "Deduplicated:  %10d, %s\n", s.deduped, fs.SizeSuffix(s.dedupedBytes).Unit("Bytes"))
+		}
+		if s.sourceChanged != 0 {
+			_, _ = fmt.Fprintf(buf, "Src changed:   %10d\n", s.sourceChanged)
+		}
+		if s.copiesResumed != 0 || s.copiesFresh != 0 {
+			_, _ = fmt.Fprintf(buf, "Copy resumed:  %10d / %d, %s\n",
+				s.copiesResumed, s.copiesResumed+s.copiesFresh, percent(s.copiesResumed, s.copiesResumed+s.copiesFresh))
+		}
 		if s.transfers != 0 || totalTransfer != 0 {
 			_, _ = fmt.Fprintf(buf, "Transferred:   %10d / %d, %s\n",
 				s.transfers, totalTransfer, percent(s.transfers, totalTransfer))
 		}
+		if fs.Config.StatsPercentiles {
+			if p := s.speeds.percentiles(); p.Samples > 0 {
+				speedString := func(speed float64) string {
+					if fs.Config.DataRateUnit == "bits" {
+						speed *= 8
+					}
+					return fs.SizeSuffix(speed).Unit(strings.Title(fs.Config.DataRateUnit) + "/s")
+				}
+				_, _ = fmt.Fprintf(buf, "Speed:         p50 %s, p95 %s, p99 %s\n", speedString(p.P50), speedString(p.P95), speedString(p.P99))
+			}
+		}
 		_, _ = fmt.Fprintf(buf, "Elapsed time:  %10ss\n", strings.TrimRight(dt.Truncate(time.Minute).String(), "0s")+fmt.Sprintf("%.1f", dtSecondsOnly.Seconds()))
 	}
 
@@ -474,10 +570,25 @@ func (s *StatsInfo) HadRetryError() bool {
 func (s *StatsInfo) Deletes(deletes int64) int64 {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	now := time.Now()
+	if s.deletesStart.IsZero() {
+		s.deletesStart = now
+	}
+	s.deletesLast = now
 	s.deletes += deletes
 	return s.deletes
 }
 
+// deleteRate returns the deletes per second between the first and
+// the last delete - call with lock held
+func (s *StatsInfo) deleteRate() float64 {
+	dt := s.deletesLast.Sub(s.deletesStart).Seconds()
+	if dt <= 0 {
+		return 0
+	}
+	return float64(s.deletes) / dt
+}
+
 // Renames updates the stats for renames
 func (s *StatsInfo) Renames(renames int64) int64 {
 	s.mu.Lock()
@@ -486,6 +597,175 @@ func (s *StatsInfo) Renames(renames int64) int64 {
 	return s.renames
 }
 
+// ServerSideMove records a server side move of an object of size
+// bytes
+func (s *StatsInfo) ServerSideMove(size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.serverMoves++
+	if size > 0 {
+		s.serverMoveBytes += size
+	}
+}
+
+// ServerSideMoves returns the number of server side moves and the
+// bytes they saved transferring
+func (s *StatsInfo) ServerSideMoves() (moves, bytes int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.serverMoves, s.serverMoveBytes
+}
+
+// PartialsCleaned updates the stats for partial objects removed after
+// failed transfers
+func (s *StatsInfo) PartialsCleaned(partials int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.partialsCleaned += partials
+	return s.partialsCleaned
+}
+
+// DirMarkers updates the stats for directory marker objects handled
+// by --handle-dir-markers
+func (s *StatsInfo) DirMarkers(markers int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirMarkers += markers
+	return s.dirMarkers
+}
+
+// PostFileCmdErrors updates the stats for failed runs of the
+// --post-file-cmd
+func (s *StatsInfo) PostFileCmdErrors(failures int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.postFileCmdErrors += failures
+	return s.postFileCmdErrors
+}
+
+// BwLimitWait adds to the time transfers have spent waiting for the
+// bandwidth limit
+func (s *StatsInfo) BwLimitWait(wait time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bwLimitWait += wait
+}
+
+// SkippedTooLarge updates the stats for a file of size bytes which
+// wasn't transferred because it is bigger than --max-size
+func (s *StatsInfo) SkippedTooLarge(size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tooLarge++
+	s.tooLargeBytes += size
+}
+
+// Deduplicated updates the stats for a file of size bytes which
+// wasn't uploaded because the destination already has its contents
+func (s *StatsInfo) Deduplicated(size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deduped++
+	s.dedupedBytes += size
+}
+
+// GetDeduplicated returns the number and total size of the files
+// which weren't uploaded because the destination already had their
+// contents
+func (s *StatsInfo) GetDeduplicated() (files, bytes int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.deduped, s.dedupedBytes
+}
+
+// SourceChanged updates the stats for a file which changed on the
+// source while it was being transferred
+func (s *StatsInfo) SourceChanged() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sourceChanged++
+}
+
+// ResumableCopy updates the stats for a resumable server side copy
+// which carried on from an interrupted copy if resumed is set or
+// started from the beginning if not
+func (s *StatsInfo) ResumableCopy(resumed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if resumed {
+		s.copiesResumed++
+	} else {
+		s.copiesFresh++
+	}
+}
+
+// GetResumableCopies returns the number of resumable server side
+// copies which were resumed and which started from the beginning
+func (s *StatsInfo) GetResumableCopies() (resumed, fresh int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.copiesResumed, s.copiesFresh
+}
+
+// GetSourceChanged returns the number of files which changed on the
+// source while they were being transferred
+func (s *StatsInfo) GetSourceChanged() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sourceChanged
+}
+
+// GetSkippedTooLarge returns the number and total size of the files
+// skipped because they are bigger than --max-size
+func (s *StatsInfo) GetSkippedTooLarge() (files, bytes int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tooLarge, s.tooLargeBytes
+}
+
+// Summary is the totals of the stats for the end of a run
+type Summary struct {
+	Bytes       int64   `json:"bytes"`       // bytes transferred
+	Transfers   int64   `json:"transfers"`   // files transferred
+	Checks      int64   `json:"checks"`      // files checked
+	Deletes     int64   `json:"deletes"`     // files deleted
+	Renames     int64   `json:"renames"`     // files renamed
+	Errors      int64   `json:"errors"`      // errors
+	FatalError  bool    `json:"fatalError"`  // set if there was a fatal error
+	LastError   string  `json:"lastError"`   // the last error or "" if none
+	ElapsedTime float64 `json:"elapsedTime"` // seconds spent transferring
+	Speed       float64 `json:"speed"`       // average speed in bytes/s while transferring
+	BwLimitWait float64 `json:"bwLimitWait"` // seconds transfers spent waiting for the bandwidth limit
+
+	SpeedPercentiles *SpeedPercentiles `json:"speedPercentiles,omitempty"` // set if --stats-percentiles is in use
+}
+
+// Summary returns the totals of the stats
+func (s *StatsInfo) Summary() Summary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	summary := Summary{
+		Bytes:       s.bytes,
+		Transfers:   s.transfers,
+		Checks:      s.checks,
+		Deletes:     s.deletes,
+		Renames:     s.renames,
+		Errors:      s.errors,
+		FatalError:  s.fatalError,
+		ElapsedTime: s.totalDuration().Seconds(),
+		Speed:       s.Speed(),
+		BwLimitWait: s.bwLimitWait.Seconds(),
+	}
+	if s.lastError != nil {
+		summary.LastError = s.lastError.Error()
+	}
+	if fs.Config.StatsPercentiles {
+		p := s.speeds.percentiles()
+		summary.SpeedPercentiles = &p
+	}
+	return summary
+}
+
 // ResetCounters sets the counters (bytes, checks, errors, transfers, deletes, renames) to 0 and resets lastError, fatalError and retryError
 func (s *StatsInfo) ResetCounters() {
 	s.mu.Lock()
@@ -499,9 +779,26 @@ func (s *StatsInfo) ResetCounters() {
 	s.checks = 0
 	s.transfers = 0
 	s.deletes = 0
+	s.deletesStart = time.Time{}
+	s.deletesLast = time.Time{}
 	s.renames = 0
+	s.partialsCleaned = 0
+	s.dirMarkers = 0
+	s.postFileCmdErrors = 0
+	s.bwLimitWait = 0
+	s.serverMoves = 0
+	s.serverMoveBytes = 0
+	s.tooLarge = 0
+	s.tooLargeBytes = 0
+	s.deduped = 0
+	s.dedupedBytes = 0
+	s.sourceChanged = 0
+	s.copiesResumed = 0
+	s.copiesFresh = 0
 	s.startedTransfers = nil
 	s.oldDuration = 0
+	s.dirs.reset()
+	s.speeds.reset()
 }
 
 // ResetErrors sets the errors count to 0 and resets lastError, fatalError and retryError
@@ -523,14 +820,29 @@ func (s *StatsInfo) Errored() bool {
 }
 
 // Error adds a single error into the stats, assigns lastError and eventually sets fatalError or retryError
+//
+// With --fail-fast the first error is made fatal and the transfers in
+// progress are cancelled.
 func (s *StatsInfo) Error(err error) error {
 	if err == nil || fserrors.IsCounted(err) {
 		return err
 	}
+	failFast := false
+	defer func() {
+		// Cancel the transfers once the lock is released
+		if failFast {
+			groups.cancelTransfers()
+		}
+	}()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.errors++
 	s.lastError = err
+	if fs.Config.FailFast && !s.fatalError {
+		fs.Errorf(nil, "Stopping after the first error as --fail-fast is set: %v", err)
+		failFast = true
+		err = fserrors.FatalError(err)
+	}
 	err = fserrors.FsError(err)
 	fserrors.Count(err)
 	switch {
@@ -580,12 +892,14 @@ func (s *StatsInfo) GetTransfers() int64 {
 // NewTransfer adds a transfer to the stats from the object.
 func (s *StatsInfo) NewTransfer(obj fs.Object) *Transfer {
 	s.transferring.add(obj.Remote())
+	s.dirs.start(obj.Remote())
 	return newTransfer(s, obj)
 }
 
 // NewTransferRemoteSize adds a transfer to the stats based on remote and size.
 func (s *StatsInfo) NewTransferRemoteSize(remote string, size int64) *Transfer {
 	s.transferring.add(remote)
+	s.dirs.start(remote)
 	return newTransferRemoteSize(s, remote, size, false)
 }
 
@@ -594,6 +908,7 @@ func (s *StatsInfo) NewTransferRemoteSize(remote string, size int64) *Transfer {
 // if ok is true then it increments the transfers count
 func (s *StatsInfo) DoneTransferring(remote string, ok bool) {
 	s.transferring.del(remote)
+	s.dirs.done(remote, ok)
 	if ok {
 		s.mu.Lock()
 		s.transfers++
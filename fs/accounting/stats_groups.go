@@ -2,8 +2,11 @@ package accounting
 
 import (
 	"context"
+	"sort"
+	"strings"
 	"sync"
 
+	"github.com/pkg/errors"
 	"github.com/rclone/rclone/fs/rc"
 
 	"github.com/rclone/rclone/fs"
@@ -94,9 +97,30 @@ Returns the following values:
 	"checks": number of checked files,
 	"transfers": number of transferred files,
 	"deletes" : number of deleted files,
+	"deletesPerSecond" : deletes per second while deleting,
 	"renames" : number of renamed files,
+	"serverSideMoves" : number of files moved server side,
+	"serverSideMoveBytes" : total size of the files moved server side,
+	"partialsCleaned" : number of partial objects removed after failed transfers,
+	"dirMarkers" : number of directory markers handled by --handle-dir-markers,
+	"postFileCmdErrors" : number of files the --post-file-cmd failed for,
+	"skippedTooLarge" : number of files skipped by --max-size-skip,
+	"skippedTooLargeBytes" : total size of the files skipped by --max-size-skip,
+	"deduplicated" : number of files not uploaded as --cas already had their contents,
+	"deduplicatedBytes" : total size of the files not uploaded by --cas,
+	"sourceChanged" : number of files which changed on the source while being transferred,
+	"serverSideCopiesResumed" : number of multipart server side copies resumed with --partial-cleanup resume,
+	"serverSideCopiesFresh" : number of multipart server side copies which could have been resumed but started from the beginning,
 	"elapsedTime": time in seconds since the start of the process,
+	"bwLimitWait": time in seconds transfers spent waiting for the bandwidth limit, --max-unconfirmed or paused,
 	"lastError": last occurred error,
+	"speedPercentiles": if --stats-percentiles is set, the speed in bytes/s of the transfers sampled every second:
+		{
+			"p50": median speed,
+			"p95": 95th percentile speed,
+			"p99": 99th percentile speed,
+			"samples": number of seconds sampled
+		}
 	"transferring": an array of currently active file transfers:
 		[
 			{
@@ -110,10 +134,18 @@ Returns the following values:
 			}
 		],
 	"checking": an array of names of currently active file checks
-		[]
+		[],
+	"listing": an array of the HTTP transactions made listing each remote, for all groups:
+		[
+			{
+				"name": name of the remote,
+				"calls": number of listing calls, one for each page of a listing,
+				"wait": time in seconds the calls spent waiting for the --list-rate
+			}
+		]
 }
 ` + "```" + `
-Values for "transferring", "checking" and "lastError" are only assigned if data is available.
+Values for "transferring", "checking", "listing" and "lastError" are only assigned if data is available.
 The value for "eta" is null if an eta cannot be determined.
 `,
 	})
@@ -239,6 +271,90 @@ Parameters
 	})
 }
 
+func rcTransferCancel(ctx context.Context, in rc.Params) (rc.Params, error) {
+	remote, err := in.GetString("remote")
+	if err != nil {
+		return nil, err
+	}
+	if remote == "" {
+		return nil, errors.New("remote must be set")
+	}
+	group, err := in.GetString("group")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	all, err := in.GetBool("all")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	requeue, err := in.GetBool("requeue")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+
+	trs := groups.transfersInProgress(group, remote)
+	if len(trs) == 0 {
+		return nil, errors.Errorf("no transfer of %q in progress", remote)
+	}
+	var inGroups []string
+	for _, tr := range trs {
+		inGroups = append(inGroups, tr.stats.group)
+	}
+	if len(trs) > 1 && !all {
+		return nil, errors.Errorf("%d transfers of %q in progress in groups %s - set group or all=true to cancel them all", len(trs), remote, strings.Join(inGroups, ", "))
+	}
+	cancelled := []string{}
+	for i, tr := range trs {
+		if tr.Cancel(requeue) {
+			fs.Infof(remote, "Cancelling transfer in group %q from the rc", inGroups[i])
+			cancelled = append(cancelled, inGroups[i])
+		}
+	}
+	if len(cancelled) == 0 {
+		return nil, errors.Errorf("transfer of %q can't be cancelled at the moment - try again", remote)
+	}
+	return rc.Params{"cancelled": cancelled}, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "transfers/cancel",
+		Fn:    rcTransferCancel,
+		Title: "Cancel an in progress transfer.",
+		Help: `
+This cancels a single transfer which is in progress leaving all the
+other transfers running, eg
+
+	rclone rc transfers/cancel remote=path/to/file.txt
+
+The remote is the name of the transfer as shown in the "transferring"
+list in core/stats.
+
+The cancelled transfer fails with the error "transfer cancelled" and
+isn't retried, unless requeue is set, in which case it is started
+again from the beginning as a low level retry.
+
+If the same remote is being transferred in more than one stats group
+(eg by two jobs) then this returns an error unless the group is given
+or all is set to cancel all of them.
+
+Parameters
+
+- remote - name of the transfer to cancel (string)
+- group - name of the stats group to look for the transfer in (string)
+- all - cancel all the transfers of remote if more than one is found (bool)
+- requeue - retry the transfer instead of failing it (bool)
+
+Returns the following values:
+` + "```" + `
+{
+	"cancelled": an array of the stats groups the transfer was cancelled in
+}
+` + "```" + `
+`,
+	})
+}
+
 type statsGroupCtx int64
 
 const statsGroupKey statsGroupCtx = 1
@@ -303,6 +419,48 @@ func newStatsGroups() *statsGroups {
 	}
 }
 
+// cancelTransfers cancels all the transfers in progress in all the
+// groups so they fail straight away
+func (sg *statsGroups) cancelTransfers() {
+	for _, tr := range sg.transfersInProgress("", "") {
+		if tr.Cancel(false) {
+			fs.Infof(tr.remote, "Cancelling transfer")
+		}
+	}
+}
+
+// transfersInProgress returns the transfers of remote which are in
+// progress in group or in all groups if group is empty.  All the
+// transfers are returned if remote is empty.
+//
+// They are returned in order of group name.
+func (sg *statsGroups) transfersInProgress(group, remote string) (trs []*Transfer) {
+	sg.mu.Lock()
+	var names []string
+	for name := range sg.m {
+		if group == "" || name == group {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	stats := make([]*StatsInfo, 0, len(names))
+	for _, name := range names {
+		stats = append(stats, sg.m[name])
+	}
+	sg.mu.Unlock()
+
+	for _, s := range stats {
+		s.mu.RLock()
+		for _, tr := range s.startedTransfers {
+			if (remote == "" || tr.remote == remote) && !tr.checking && !tr.IsDone() {
+				trs = append(trs, tr)
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return trs
+}
+
 // set marks the stats as belonging to a group
 func (sg *statsGroups) set(group string, stats *StatsInfo) {
 	sg.mu.Lock()
@@ -357,10 +515,31 @@ func (sg *statsGroups) sum() *StatsInfo {
 			sum.checks += stats.checks
 			sum.transfers += stats.transfers
 			sum.deletes += stats.deletes
+			if sum.deletesStart.IsZero() || (!stats.deletesStart.IsZero() && stats.deletesStart.Before(sum.deletesStart)) {
+				sum.deletesStart = stats.deletesStart
+			}
+			if stats.deletesLast.After(sum.deletesLast) {
+				sum.deletesLast = stats.deletesLast
+			}
 			sum.renames += stats.renames
+			sum.partialsCleaned += stats.partialsCleaned
+			sum.dirMarkers += stats.dirMarkers
+			sum.postFileCmdErrors += stats.postFileCmdErrors
+			sum.bwLimitWait += stats.bwLimitWait
+			sum.serverMoves += stats.serverMoves
+			sum.serverMoveBytes += stats.serverMoveBytes
+			sum.tooLarge += stats.tooLarge
+			sum.tooLargeBytes += stats.tooLargeBytes
+			sum.deduped += stats.deduped
+			sum.dedupedBytes += stats.dedupedBytes
+			sum.sourceChanged += stats.sourceChanged
+			sum.copiesResumed += stats.copiesResumed
+			sum.copiesFresh += stats.copiesFresh
 			sum.checking.merge(stats.checking)
 			sum.transferring.merge(stats.transferring)
 			sum.inProgress.merge(stats.inProgress)
+			sum.dirs.merge(stats.dirs)
+			sum.speeds.merge(stats.speeds)
 			if sum.lastError == nil && stats.lastError != nil {
 				sum.lastError = stats.lastError
 			}
@@ -1,11 +1,20 @@
 package accounting
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io/ioutil"
 	"runtime"
 	"testing"
 
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/rc"
 	"github.com/rclone/rclone/fstest/testy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestStatsGroupOperations(t *testing.T) {
@@ -105,3 +114,117 @@ func TestStatsGroupOperations(t *testing.T) {
 func percentDiff(start, end uint64) uint64 {
 	return (start - end) * 100 / start
 }
+
+func TestRcTransferCancel(t *testing.T) {
+	ctx := context.Background()
+	call := rc.Calls.Get("transfers/cancel")
+	require.NotNil(t, call)
+
+	const remote = "test-transfer-cancel.txt"
+	newTransfer := func(group string) (*Transfer, context.Context, func()) {
+		tr := StatsGroup(group).NewTransferRemoteSize(remote, 3)
+		trCtx, done := tr.WithCancel(ctx)
+		tr.Account(trCtx, ioutil.NopCloser(bytes.NewBuffer([]byte{1, 2, 3})))
+		return tr, trCtx, done
+	}
+	tr1, ctx1, done1 := newTransfer("test-cancel-1")
+	tr2, ctx2, done2 := newTransfer("test-cancel-2")
+	defer func() {
+		done2()
+		tr2.Done(nil)
+		groups.delete("test-cancel-1")
+		groups.delete("test-cancel-2")
+	}()
+
+	// not found
+	_, err := call.Fn(ctx, rc.Params{"remote": "potato"})
+	assert.Error(t, err)
+
+	// more than one match
+	_, err = call.Fn(ctx, rc.Params{"remote": remote})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "test-cancel-1, test-cancel-2")
+	assert.NoError(t, ctx1.Err())
+	assert.NoError(t, ctx2.Err())
+
+	// cancel just one with group
+	out, err := call.Fn(ctx, rc.Params{"remote": remote, "group": "test-cancel-1"})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{"cancelled": []string{"test-cancel-1"}}, out)
+	assert.Equal(t, context.Canceled, ctx1.Err())
+	assert.NoError(t, ctx2.Err())
+	err = tr1.Cancelled()
+	assert.True(t, fserrors.IsNoRetryError(err))
+	_, err = tr1.acc.Read(make([]byte, 1))
+	assert.Equal(t, tr1.Cancelled(), err)
+
+	// cancel the rest with all and requeue
+	out, err = call.Fn(ctx, rc.Params{"remote": remote, "all": true, "requeue": true})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{"cancelled": []string{"test-cancel-2"}}, out)
+	assert.Equal(t, context.Canceled, ctx2.Err())
+	err = tr2.Cancelled()
+	assert.True(t, fserrors.IsRetryError(err))
+	assert.False(t, fserrors.IsNoRetryError(err))
+
+	// the next attempt clears the cancel
+	_, done := tr2.WithCancel(ctx)
+	assert.NoError(t, tr2.Cancelled())
+	done()
+	assert.False(t, tr2.Cancel(false))
+
+	// finished transfers aren't found
+	done1()
+	tr1.Done(nil)
+	out, err = call.Fn(ctx, rc.Params{"remote": remote, "all": true})
+	require.Error(t, err)
+	assert.Nil(t, out)
+}
+
+func TestFailFast(t *testing.T) {
+	ctx := context.Background()
+	oldFailFast := fs.Config.FailFast
+	defer func() { fs.Config.FailFast = oldFailFast }()
+
+	newTransfer := func(group, remote string) (*Transfer, context.Context, func()) {
+		tr := StatsGroup(group).NewTransferRemoteSize(remote, 3)
+		trCtx, done := tr.WithCancel(ctx)
+		tr.Account(trCtx, ioutil.NopCloser(bytes.NewBuffer([]byte{1, 2, 3})))
+		return tr, trCtx, done
+	}
+	tr1, ctx1, done1 := newTransfer("test-fail-fast-1", "file1")
+	tr2, ctx2, done2 := newTransfer("test-fail-fast-2", "file2")
+	defer func() {
+		done1()
+		done2()
+		tr1.Done(nil)
+		tr2.Done(nil)
+		groups.delete("test-fail-fast-1")
+		groups.delete("test-fail-fast-2")
+	}()
+
+	// Without --fail-fast errors are just counted
+	fs.Config.FailFast = false
+	s := NewStats()
+	err := s.Error(errors.New("first"))
+	assert.False(t, fserrors.IsFatalError(err))
+	assert.False(t, s.HadFatalError())
+	assert.NoError(t, ctx1.Err())
+	assert.NoError(t, ctx2.Err())
+
+	// With it the first error is fatal and the transfers are cancelled
+	fs.Config.FailFast = true
+	s = NewStats()
+	err = s.Error(errors.New("boom"))
+	assert.True(t, fserrors.IsFatalError(err))
+	assert.True(t, fserrors.IsCounted(err))
+	assert.True(t, s.HadFatalError())
+	assert.Equal(t, context.Canceled, ctx1.Err())
+	assert.Equal(t, context.Canceled, ctx2.Err())
+	assert.True(t, fserrors.IsNoRetryError(tr1.Cancelled()))
+
+	// The errors which follow are counted as usual
+	err = s.Error(tr2.Cancelled())
+	assert.False(t, fserrors.IsFatalError(err))
+	assert.Equal(t, int64(2), s.GetErrors())
+}
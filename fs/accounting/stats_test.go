@@ -9,6 +9,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/rc"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -431,3 +432,104 @@ func TestPruneTransfers(t *testing.T) {
 		})
 	}
 }
+
+func TestDirProgress(t *testing.T) {
+	s := NewStats()
+	for _, remote := range []string{"a", "dir/b", "dir/c", "dir/sub/d"} {
+		s.TransferQueued(remote)
+	}
+	// queueing twice doesn't count twice
+	s.TransferQueued("dir/b")
+
+	tr := s.NewTransferRemoteSize("dir/b", 1)
+	tr.Done(nil)
+	tr = s.NewTransferRemoteSize("dir/c", 1)
+	tr.Done(errors.New("boom"))
+	// a transfer which wasn't queued is counted when it starts
+	tr = s.NewTransferRemoteSize("e", 1)
+	tr.Done(nil)
+
+	assert.Equal(t, []DirProgress{
+		{Name: "", Total: 2, Done: 1},
+		{Name: "dir", Total: 2, Done: 1, Errors: 1},
+		{Name: "dir/sub", Total: 1},
+	}, s.DirProgress())
+
+	// retrying a failed transfer counts it as not done
+	s.TransferQueued("dir/c")
+	tr = s.NewTransferRemoteSize("dir/c", 1)
+	tr.Done(nil)
+	assert.Equal(t, DirProgress{Name: "dir", Total: 2, Done: 2}, s.DirProgress()[1])
+
+	s.ResetCounters()
+	assert.Equal(t, []DirProgress{}, s.DirProgress())
+}
+
+func TestSummary(t *testing.T) {
+	s := NewStats()
+	s.Bytes(100)
+	s.Deletes(1)
+	s.BwLimitWait(1500 * time.Millisecond)
+	s.Error(errors.New("boom"))
+
+	summary := s.Summary()
+	assert.Equal(t, int64(100), summary.Bytes)
+	assert.Equal(t, int64(1), summary.Deletes)
+	assert.Equal(t, int64(1), summary.Errors)
+	assert.Equal(t, "boom", summary.LastError)
+	assert.Equal(t, 1.5, summary.BwLimitWait)
+
+	s.ResetCounters()
+	assert.Equal(t, Summary{}, s.Summary())
+}
+
+func TestSpeedPercentiles(t *testing.T) {
+	oldPercentiles := fs.Config.StatsPercentiles
+	defer func() {
+		fs.Config.StatsPercentiles = oldPercentiles
+	}()
+	fs.Config.StatsPercentiles = true
+
+	s := NewStats()
+	assert.Equal(t, &SpeedPercentiles{}, s.Summary().SpeedPercentiles)
+
+	// 100 seconds at 1 MiB/s split between two transfers, with
+	// 3 seconds stalled and 2 seconds at 16 MiB/s
+	start := time.Unix(1000, 0)
+	for i := 0; i < 105; i++ {
+		now := start.Add(time.Duration(i) * time.Second)
+		switch {
+		case i < 100:
+			s.speeds.add(now, 512*1024)
+			s.speeds.add(now.Add(100*time.Millisecond), 512*1024)
+		case i < 103:
+			s.speeds.add(now, 0)
+		default:
+			s.speeds.add(now, 16*1024*1024)
+		}
+	}
+	s.speeds.add(start.Add(105*time.Second), 0) // finish the last sample
+
+	p := s.Summary().SpeedPercentiles
+	require.NotNil(t, p)
+	assert.Equal(t, int64(105), p.Samples)
+	assert.InEpsilon(t, 1024*1024, p.P50, 0.05)
+	assert.InEpsilon(t, 1024*1024, p.P95, 0.05)
+	assert.InEpsilon(t, 16*1024*1024, p.P99, 0.05)
+
+	out, err := s.RemoteStats()
+	require.NoError(t, err)
+	assert.Equal(t, int64(105), out["speedPercentiles"].(rc.Params)["samples"])
+
+	// the groups are summed
+	sum := NewStats()
+	sum.speeds.merge(s.speeds)
+	sum.speeds.merge(s.speeds)
+	assert.Equal(t, int64(210), sum.speeds.percentiles().Samples)
+
+	s.ResetCounters()
+	assert.Equal(t, &SpeedPercentiles{}, s.Summary().SpeedPercentiles)
+
+	fs.Config.StatsPercentiles = false
+	assert.Nil(t, s.Summary().SpeedPercentiles)
+}
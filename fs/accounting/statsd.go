@@ -0,0 +1,182 @@
+package accounting
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+)
+
+// statsdMaxPacket is the largest UDP packet to send to StatsD so it
+// isn't fragmented on a typical network
+const statsdMaxPacket = 1432
+
+// statsdExporter pushes the stats to a StatsD server
+//
+// The metrics are batched into as few UDP packets as possible.
+type statsdExporter struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	prefix string
+	buf    bytes.Buffer
+	last   Summary // the totals last sent so counters are sent as changes
+}
+
+// Globals
+var (
+	statsdMu sync.Mutex      // protects statsd
+	statsd   *statsdExporter // the running exporter or nil
+)
+
+// newStatsdExporter makes a new exporter sending to addr with the
+// metric names prefixed by prefix
+func newStatsdExporter(addr, prefix string) (*statsdExporter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to StatsD")
+	}
+	if prefix != "" {
+		prefix += "."
+	}
+	return &statsdExporter{
+		conn:   conn,
+		prefix: prefix,
+		last:   groups.sum().Summary(),
+	}, nil
+}
+
+// add a metric to the batch, sending the batch first if it would be
+// too big - call with the lock held
+func (e *statsdExporter) add(name, value, kind string) {
+	line := e.prefix + name + ":" + value + "|" + kind + "\n"
+	if e.buf.Len()+len(line) > statsdMaxPacket {
+		e.flush()
+	}
+	e.buf.WriteString(line)
+}
+
+// flush sends the batch - call with the lock held
+func (e *statsdExporter) flush() {
+	if e.buf.Len() == 0 {
+		return
+	}
+	_, err := e.conn.Write(e.buf.Bytes())
+	if err != nil {
+		fs.Debugf(nil, "Failed to send stats to StatsD: %v", err)
+	}
+	e.buf.Reset()
+}
+
+// addCounter adds a counter for the change in a total since the last
+// send
+func (e *statsdExporter) addCounter(name string, total, last int64) {
+	delta := total - last
+	if delta < 0 {
+		// The counters have been reset
+		delta = total
+	}
+	if delta != 0 {
+		e.add(name, strconv.FormatInt(delta, 10), "c")
+	}
+}
+
+// send the counters and gauges along with any timers batched up
+func (e *statsdExporter) send() {
+	s := groups.sum().Summary()
+	bwLimit := int64(CurrentBwLimit())
+	if bwLimit < 0 {
+		// StatsD reads a negative gauge as a change
+		bwLimit = 0
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.addCounter("bytes", s.Bytes, e.last.Bytes)
+	e.addCounter("transfers", s.Transfers, e.last.Transfers)
+	e.addCounter("checks", s.Checks, e.last.Checks)
+	e.addCounter("deletes", s.Deletes, e.last.Deletes)
+	e.addCounter("renames", s.Renames, e.last.Renames)
+	e.addCounter("errors", s.Errors, e.last.Errors)
+	e.add("speed", strconv.FormatFloat(s.Speed, 'f', 0, 64), "g")
+	e.add("bwlimit", strconv.FormatInt(bwLimit, 10), "g")
+	e.last = s
+	e.flush()
+}
+
+// transferDone adds a timer for a transfer which took d to the batch
+func (e *statsdExporter) transferDone(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.add("transfer.duration", fmt.Sprintf("%.3f", d.Seconds()*1000), "ms")
+}
+
+// close sends the last stats and closes the connection
+func (e *statsdExporter) close() error {
+	e.send()
+	return e.conn.Close()
+}
+
+// statsdTransferDone records a completed transfer which took d if
+// the stats are being sent to StatsD
+func statsdTransferDone(d time.Duration) {
+	statsdMu.Lock()
+	e := statsd
+	statsdMu.Unlock()
+	if e != nil {
+		e.transferDone(d)
+	}
+}
+
+// StartStatsd starts sending the stats to the --statsd-addr every
+// --statsd-interval if set.
+//
+// It returns a func which should be called to send the last stats
+// and stop.
+func StartStatsd() func() {
+	if fs.Config.StatsdAddr == "" {
+		return func() {}
+	}
+	if fs.Config.StatsdInterval <= 0 {
+		fs.Errorf(nil, "Ignoring --statsd-addr as --statsd-interval is 0")
+		return func() {}
+	}
+	e, err := newStatsdExporter(fs.Config.StatsdAddr, fs.Config.StatsdPrefix)
+	if err != nil {
+		fs.Errorf(nil, "Ignoring --statsd-addr: %v", err)
+		return func() {}
+	}
+	statsdMu.Lock()
+	statsd = e
+	statsdMu.Unlock()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(fs.Config.StatsdInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				e.send()
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		wg.Wait()
+		statsdMu.Lock()
+		statsd = nil
+		statsdMu.Unlock()
+		err := e.close()
+		if err != nil {
+			fs.Errorf(nil, "Failed to close StatsD connection: %v", err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package accounting
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsd(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	// read a packet from the fake server
+	read := func() []string {
+		buf := make([]byte, 2*statsdMaxPacket)
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		assert.True(t, n <= statsdMaxPacket, "packet too big %d", n)
+		return strings.Split(strings.TrimSuffix(string(buf[:n]), "\n"), "\n")
+	}
+
+	e, err := newStatsdExporter(conn.LocalAddr().String(), "test")
+	require.NoError(t, err)
+	e.last = Summary{Bytes: 100, Deletes: 3}
+
+	s := GlobalStats()
+	s.ResetCounters()
+	s.Bytes(150)
+	s.Deletes(2)
+	defer s.ResetCounters()
+
+	e.transferDone(1500 * time.Millisecond)
+	e.send()
+	lines := read()
+	assert.Contains(t, lines, "test.transfer.duration:1500.000|ms")
+	assert.Contains(t, lines, "test.bytes:50|c")
+	assert.Contains(t, lines, "test.deletes:2|c") // reset since last
+	assert.Contains(t, lines, "test.bwlimit:0|g")
+	for _, line := range lines {
+		assert.False(t, strings.HasPrefix(line, "test.checks:"), line)
+	}
+
+	// Lots of timers are split into packets
+	for i := 0; i < 100; i++ {
+		e.transferDone(time.Second)
+	}
+	lines = read()
+	assert.Equal(t, "test.transfer.duration:1000.000|ms", lines[0])
+	assert.NoError(t, e.close())
+
+	// Does nothing if not set
+	oldAddr := fs.Config.StatsdAddr
+	defer func() { fs.Config.StatsdAddr = oldAddr }()
+	fs.Config.StatsdAddr = ""
+	StartStatsd()()
+}
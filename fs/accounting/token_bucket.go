@@ -7,6 +7,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fshttp"
 	"github.com/rclone/rclone/fs/rc"
 	"golang.org/x/time/rate"
 )
@@ -17,102 +18,549 @@ var (
 	tokenBucket       *rate.Limiter
 	prevTokenBucket   = tokenBucket
 	bwLimitToggledOff = false
-	currLimitMu       sync.Mutex // protects changes to the timeslot
+	currLimitMu       sync.Mutex // protects changes to the timeslot and fs.Config.BwLimit
 	currLimit         fs.BwTimeSlot
+	tokenTickerOn     bool          // set if the ticker is running - protected by currLimitMu
+	bwLimitHold       time.Time     // scheduled changes aren't made before this - protected by currLimitMu
+	bwLimitHeld       fs.BwTimeSlot // time slot used until bwLimitHold - protected by currLimitMu
+
+	bucketLimitsMu sync.Mutex                   // protects bucketLimits
+	bucketLimits   = map[string]*rate.Limiter{} // token buckets for --bwlimit-bucket
+
+	bindLimitsMu sync.Mutex                   // protects bindLimits
+	bindLimits   = map[string]*rate.Limiter{} // token buckets for --bwlimit-bind
 )
 
-const maxBurstSize = 4 * 1024 * 1024 // must be bigger than the biggest request
+// bucketKey is the context key for the destination bucket
+type bucketKey struct{}
+
+// WithBucket returns a copy of ctx which marks transfers made with it
+// as going to the destination bucket passed in.
+//
+// The bandwidth of transfers accounted with this context will be
+// limited by the --bwlimit-bucket for that bucket as well as the
+// global bandwidth limit.
+func WithBucket(ctx context.Context, bucket string) context.Context {
+	return context.WithValue(ctx, bucketKey{}, bucket)
+}
+
+// bucketFromContext returns the destination bucket set with
+// WithBucket or "" if not set
+func bucketFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	bucket, _ := ctx.Value(bucketKey{}).(string)
+	return bucket
+}
+
+// bindFromContext returns the local address the transfers made with
+// ctx are bound to with --bind or --bind-remote or "" if not bound
+func bindFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	ip := fshttp.BoundAddr(ctx)
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// BwLimiter is a bandwidth limit which can be attached to a context
+// with WithBwLimiter to limit the transfers made with it, eg those of
+// an rc job.
+//
+// The limit can be changed while the transfers are running.
+type BwLimiter struct {
+	mu sync.Mutex
+	tb *rate.Limiter // nil if unlimited
+}
+
+// NewBwLimiter makes a new BwLimiter with the bandwidth passed in.
+// If bandwidth is <= 0 then it doesn't limit until SetLimit is called.
+func NewBwLimiter(bandwidth fs.SizeSuffix) *BwLimiter {
+	l := &BwLimiter{}
+	l.SetLimit(bandwidth)
+	return l
+}
+
+// SetLimit sets the bandwidth limit. If bandwidth is <= 0 then the
+// limit is removed.
+func (l *BwLimiter) SetLimit(bandwidth fs.SizeSuffix) {
+	var tb *rate.Limiter
+	if bandwidth > 0 {
+		tb = newTokenBucket(bandwidth)
+	}
+	l.mu.Lock()
+	l.tb = tb
+	l.mu.Unlock()
+}
+
+// Limit returns the bandwidth limit or -1 if unlimited
+func (l *BwLimiter) Limit() fs.SizeSuffix {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.tb == nil {
+		return -1
+	}
+	return fs.SizeSuffix(l.tb.Limit())
+}
+
+// wait sleeps for the correct amount of time for the passage of n
+// bytes according to the limit.
+//
+// This doesn't hold the lock while waiting so the limit can be
+// changed by SetLimit.
+//
+// It returns true if there is a limit and an error if ctx was
+// cancelled while waiting.
+func (l *BwLimiter) wait(ctx context.Context, n int) (limited bool, err error) {
+	l.mu.Lock()
+	tb := l.tb
+	l.mu.Unlock()
+	if tb == nil {
+		return false, nil
+	}
+	return true, waitLimit(ctx, nil, tb, n)
+}
+
+// bwLimiterKey is the context key for the BwLimiter
+type bwLimiterKey struct{}
+
+// WithBwLimiter returns a copy of ctx which limits the bandwidth of
+// transfers made with it to that of l as well as the global
+// bandwidth limit.
+func WithBwLimiter(ctx context.Context, l *BwLimiter) context.Context {
+	return context.WithValue(ctx, bwLimiterKey{}, l)
+}
+
+// bwLimiterFromContext returns the BwLimiter set with WithBwLimiter
+// or nil if not set
+func bwLimiterFromContext(ctx context.Context) *BwLimiter {
+	if ctx == nil {
+		return nil
+	}
+	l, _ := ctx.Value(bwLimiterKey{}).(*BwLimiter)
+	return l
+}
+
+const maxBurstSize = 4 * 1024 * 1024 // requests bigger than this are split by waitN
 
 // make a new empty token bucket with the bandwidth given
 func newTokenBucket(bandwidth fs.SizeSuffix) *rate.Limiter {
 	newTokenBucket := rate.NewLimiter(rate.Limit(bandwidth), maxBurstSize)
 	// empty the bucket
-	err := newTokenBucket.WaitN(context.Background(), maxBurstSize)
+	err := waitN(context.Background(), newTokenBucket, newTokenBucket.Burst())
 	if err != nil {
 		fs.Errorf(nil, "Failed to empty token bucket: %v", err)
 	}
 	return newTokenBucket
 }
 
+// waitN sleeps until tb allows n tokens to pass or ctx is cancelled.
+//
+// The reservations fail straight away if n is bigger than the burst
+// size of tb, so bigger requests are split into waits of the burst
+// size.
+func waitN(ctx context.Context, tb *rate.Limiter, n int) error {
+	burst := tb.Burst()
+	if n > burst && tb.Limit() != rate.Inf {
+		if burst <= 0 {
+			return errors.Errorf("can't wait for %d tokens from a token bucket with burst size %d", n, burst)
+		}
+		for ; n > burst; n -= burst {
+			err := waitBurstN(ctx, tb, burst)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return waitBurstN(ctx, tb, n)
+}
+
+// waitBurstN sleeps until tb allows n tokens, no more than its burst
+// size, to pass or ctx is cancelled.
+func waitBurstN(ctx context.Context, tb *rate.Limiter, n int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := time.Now()
+	r := tb.ReserveN(now, n)
+	if !r.OK() {
+		return errors.Errorf("can't wait for %d tokens from a token bucket with burst size %d", n, tb.Burst())
+	}
+	return waitReservation(ctx, r, r.DelayFrom(now))
+}
+
+// waitReservation sleeps for delay, the delay of r, unless ctx is
+// cancelled or reaches its deadline first, in which case the tokens
+// of r are given back for the other transfers and the error is
+// returned.
+//
+// Unlike tb.WaitN a deadline which is sooner than delay doesn't fail
+// the wait straight away.
+func waitReservation(ctx context.Context, r *rate.Reservation, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+	}
+	r.Cancel()
+	return ctx.Err()
+}
+
+// waitLimit sleeps until tb allows n tokens to pass like waitN.
+//
+// If ctx was cancelled or reached its deadline that error is
+// returned, otherwise any error is logged against o as the transfer
+// carries on without the limit.
+func waitLimit(ctx context.Context, o interface{}, tb *rate.Limiter, n int) error {
+	err := waitN(ctx, tb, n)
+	if err == nil {
+		return nil
+	}
+	if err == ctx.Err() {
+		return err
+	}
+	fs.Errorf(o, "Token bucket error: %v", err)
+	return nil
+}
+
+// tokensAvailableIn returns how long it will be until tb has n
+// tokens available, without taking them.
+//
+// Later versions of golang.org/x/time have Limiter.TokensAt for this,
+// but here a reservation is made and cancelled straight away.
+func tokensAvailableIn(tb *rate.Limiter, now time.Time, n int) time.Duration {
+	if burst := tb.Burst(); n > burst {
+		n = burst
+	}
+	r := tb.ReserveN(now, n)
+	if !r.OK() {
+		return 0
+	}
+	delay := r.DelayFrom(now)
+	r.CancelAt(now)
+	return delay
+}
+
+// WaitForStartTokens waits until the token bucket for the --bwlimit
+// has --bwlimit-start-tokens available so a transfer doesn't start
+// reading only to stall straight away on an empty bucket.
+//
+// The tokens aren't taken - the transfer uses them as it reads. It
+// only waits for as long as the bucket takes to fill up once so
+// transfers aren't held up for ever by the ones already running.
+func WaitForStartTokens(ctx context.Context) error {
+	n := int(fs.Config.BwLimitStartTokens)
+	if n <= 0 {
+		return nil
+	}
+	tokenBucketMu.Lock()
+	tb := tokenBucket
+	tokenBucketMu.Unlock()
+	if tb == nil {
+		return nil
+	}
+	delay := tokensAvailableIn(tb, time.Now(), n)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+	return nil
+}
+
+// emptyTokenBuckets empties the token buckets so the transfers don't
+// burst with the tokens saved up while they weren't using them
+func emptyTokenBuckets() {
+	tokenBucketMu.Lock()
+	if tokenBucket != nil {
+		tokenBucket = newTokenBucket(fs.SizeSuffix(tokenBucket.Limit()))
+	}
+	tokenBucketMu.Unlock()
+	bucketLimitsMu.Lock()
+	for bucket, tb := range bucketLimits {
+		bucketLimits[bucket] = newTokenBucket(fs.SizeSuffix(tb.Limit()))
+	}
+	bucketLimitsMu.Unlock()
+	bindLimitsMu.Lock()
+	for bind, tb := range bindLimits {
+		bindLimits[bind] = newTokenBucket(fs.SizeSuffix(tb.Limit()))
+	}
+	bindLimitsMu.Unlock()
+}
+
 // StartTokenBucket starts the token bucket if necessary
 func StartTokenBucket() {
 	currLimitMu.Lock()
-	currLimit := fs.Config.BwLimit.LimitAt(time.Now())
+	now := time.Now()
+	bwRelativeStart = now
+	currLimit := applyBwRelative(now, fs.Config.BwLimit.LimitAt(now))
+	limitNow := applyBwAfter(now, currLimit)
 	currLimitMu.Unlock()
 
+	if len(fs.Config.BwLimitRelative) > 0 {
+		fs.Infof(nil, "Following the --bwlimit-relative timetable %q from now", fs.Config.BwLimitRelative.String())
+	}
 	if currLimit.Bandwidth > 0 {
-		tokenBucket = newTokenBucket(currLimit.Bandwidth)
-		fs.Infof(nil, "Starting bandwidth limiter at %vBytes/s", &currLimit.Bandwidth)
+		if limitNow.Bandwidth > 0 {
+			tokenBucket = newTokenBucket(currLimit.Bandwidth)
+			fs.Infof(nil, "Starting bandwidth limiter at %vBytes/s", &currLimit.Bandwidth)
+		} else {
+			fs.Infof(nil, "Starting bandwidth limiter at %vBytes/s once --bwlimit-after %v has been transferred", &currLimit.Bandwidth, fs.Config.BwLimitAfter)
+		}
 
 		// Start the SIGUSR2 signal handler to toggle bandwidth.
 		// This function does nothing in windows systems.
 		startSignalHandler()
 	}
+
+	bucketLimitsMu.Lock()
+	for bucket, bandwidth := range fs.Config.BwLimitBucket {
+		if bandwidth > 0 {
+			bucketLimits[bucket] = newTokenBucket(bandwidth)
+			fs.Infof(nil, "Starting bandwidth limiter for bucket %q at %vBytes/s", bucket, &bandwidth)
+		}
+	}
+	bucketLimitsMu.Unlock()
+
+	bindLimitsMu.Lock()
+	for bind, bandwidth := range fs.Config.BwLimitBind {
+		if bandwidth > 0 {
+			bindLimits[bind] = newTokenBucket(bandwidth)
+			fs.Infof(nil, "Starting bandwidth limiter for bind address %s at %vBytes/s", bind, &bandwidth)
+		}
+	}
+	bindLimitsMu.Unlock()
 }
 
-// StartTokenTicker creates a ticker to update the bandwidth limiter every minute.
+// StartTokenTicker creates a ticker to update the bandwidth limiter
+// every minute from the timetable, the --bwlimit-percent, the
+// --bwlimit-budget and the --bwlimit-after, timers for the
+// --bwlimit-relative changes, the polling of the --bwlimit-source and
+// the measuring of the bandwidth for the --bwlimit-percent.
 func StartTokenTicker() {
+	startBwRelativeTimers()
+	startBwLimitSource()
+	startBwPercent()
+
 	// If the timetable has a single entry or was not specified, we don't need
-	// a ticker to update the bandwidth.
-	if len(fs.Config.BwLimit) <= 1 {
+	// a ticker to update the bandwidth unless there is a daily budget,
+	// a threshold to reset or a percentage timetable.
+	currLimitMu.Lock()
+	needTicker := len(fs.Config.BwLimit) > 1 || fs.Config.BwLimitBudget > 0 || fs.Config.BwLimitAfter > 0 || len(fs.Config.BwLimitPercent) > 1
+	currLimitMu.Unlock()
+	if needTicker {
+		startTokenTicker()
+	}
+}
+
+// startTokenTicker starts the ticker if it isn't already running
+func startTokenTicker() {
+	currLimitMu.Lock()
+	defer currLimitMu.Unlock()
+	if tokenTickerOn {
 		return
 	}
+	tokenTickerOn = true
 
 	ticker := time.NewTicker(time.Minute)
 	go func() {
 		for range ticker.C {
-			limitNow := fs.Config.BwLimit.LimitAt(time.Now())
-			currLimitMu.Lock()
+			updateBwLimit(time.Now())
+		}
+	}()
+}
 
-			if currLimit.Bandwidth != limitNow.Bandwidth {
-				tokenBucketMu.Lock()
-
-				// If bwlimit is toggled off, the change should only
-				// become active on the next toggle, which causes
-				// an exchange of tokenBucket <-> prevTokenBucket
-				var targetBucket **rate.Limiter
-				if bwLimitToggledOff {
-					targetBucket = &prevTokenBucket
-				} else {
-					targetBucket = &tokenBucket
-				}
+// updateBwLimit sets the bandwidth limit to the one in force at now
+// if it has changed.
+func updateBwLimit(now time.Time) {
+	currLimitMu.Lock()
+	defer currLimitMu.Unlock()
+	limitNow := bwLimitAt(now)
+	if currLimit.Bandwidth == limitNow.Bandwidth {
+		return
+	}
+	tokenBucketMu.Lock()
 
-				// Set new bandwidth. If unlimited, set tokenbucket to nil.
-				if limitNow.Bandwidth > 0 {
-					*targetBucket = newTokenBucket(limitNow.Bandwidth)
-					if bwLimitToggledOff {
-						fs.Logf(nil, "Scheduled bandwidth change. "+
-							"Limit will be set to %vBytes/s when toggled on again.", &limitNow.Bandwidth)
-					} else {
-						fs.Logf(nil, "Scheduled bandwidth change. Limit set to %vBytes/s", &limitNow.Bandwidth)
-					}
-				} else {
-					*targetBucket = nil
-					fs.Logf(nil, "Scheduled bandwidth change. Bandwidth limits disabled")
-				}
+	// If bwlimit is toggled off, the change should only
+	// become active on the next toggle, which causes
+	// an exchange of tokenBucket <-> prevTokenBucket
+	var targetBucket **rate.Limiter
+	if bwLimitToggledOff {
+		targetBucket = &prevTokenBucket
+	} else {
+		targetBucket = &tokenBucket
+	}
 
-				currLimit = limitNow
-				tokenBucketMu.Unlock()
-			}
-			currLimitMu.Unlock()
+	// Set new bandwidth. If unlimited, set tokenbucket to nil.
+	if limitNow.Bandwidth > 0 {
+		*targetBucket = newTokenBucket(limitNow.Bandwidth)
+		if bwLimitToggledOff {
+			fs.Logf(nil, "Scheduled bandwidth change. "+
+				"Limit will be set to %vBytes/s when toggled on again.", &limitNow.Bandwidth)
+		} else {
+			fs.Logf(nil, "Scheduled bandwidth change. Limit set to %vBytes/s", &limitNow.Bandwidth)
 		}
-	}()
+	} else {
+		*targetBucket = nil
+		fs.Logf(nil, "Scheduled bandwidth change. Bandwidth limits disabled")
+	}
+
+	currLimit = limitNow
+	tokenBucketMu.Unlock()
+}
+
+// bwLimitAt returns the time slot in force at now from the timetable,
+// the --bwlimit-relative, the --bwlimit-percent, the --bwlimit-budget
+// and the --bwlimit-after.
+//
+// Call with currLimitMu held.
+func bwLimitAt(now time.Time) fs.BwTimeSlot {
+	return applyBwAfter(now, applyBwBudget(now, applyBwPercent(now, applyBwRelative(now, timetableLimitAt(now)))))
+}
+
+// timetableLimitAt returns the time slot of the timetable in force at
+// now, which is the held one if the next change was cancelled or
+// deferred.
+//
+// Call with currLimitMu held.
+func timetableLimitAt(now time.Time) fs.BwTimeSlot {
+	if now.Before(bwLimitHold) {
+		return bwLimitHeld
+	}
+	return fs.Config.BwLimit.LimitAt(now)
+}
+
+// nextBwLimitChange returns when the timetable will next change the
+// bandwidth limit after now and the time slot it will change to.
+//
+// It returns false if there is no change scheduled.
+//
+// Call with currLimitMu held.
+func nextBwLimitChange(now time.Time) (time.Time, fs.BwTimeSlot, bool) {
+	if now.Before(bwLimitHold) {
+		if ts := fs.Config.BwLimit.LimitAt(bwLimitHold); ts.Bandwidth != bwLimitHeld.Bandwidth {
+			return bwLimitHold, ts, true
+		}
+		return fs.Config.BwLimit.NextChange(bwLimitHold, bwLimitHeld.Bandwidth)
+	}
+	return fs.Config.BwLimit.NextChange(now, fs.Config.BwLimit.LimitAt(now).Bandwidth)
+}
+
+// cancelBwLimitChange cancels the next scheduled change of the
+// bandwidth limit after now so the current limit stays in force
+// until the timetable changes it again.
+func cancelBwLimitChange(now time.Time) error {
+	currLimitMu.Lock()
+	defer currLimitMu.Unlock()
+	at, ts, ok := nextBwLimitChange(now)
+	if !ok {
+		return errors.New("no scheduled bandwidth change to cancel")
+	}
+	until, _, ok := fs.Config.BwLimit.NextChange(at, ts.Bandwidth)
+	if !ok {
+		return errors.New("timetable never changes back from the scheduled bandwidth")
+	}
+	bwLimitHeld = timetableLimitAt(now)
+	bwLimitHold = until
+	fs.Logf(nil, "Cancelled scheduled bandwidth change to %vBytes/s at %v - limit stays at %vBytes/s until %v", &ts.Bandwidth, at, &bwLimitHeld.Bandwidth, until)
+	return nil
+}
+
+// deferBwLimitChange defers the next scheduled change of the
+// bandwidth limit after now by d so the current limit stays in force
+// until then.  Any other changes scheduled before then are skipped.
+func deferBwLimitChange(now time.Time, d time.Duration) error {
+	if d <= 0 {
+		return errors.New("the time to defer the change by must be more than 0")
+	}
+	currLimitMu.Lock()
+	defer currLimitMu.Unlock()
+	at, _, ok := nextBwLimitChange(now)
+	if !ok {
+		return errors.New("no scheduled bandwidth change to defer")
+	}
+	bwLimitHeld = timetableLimitAt(now)
+	bwLimitHold = at.Add(d)
+	fs.Logf(nil, "Deferred scheduled bandwidth change at %v - limit stays at %vBytes/s until %v", at, &bwLimitHeld.Bandwidth, bwLimitHold)
+	return nil
 }
 
 // limitBandwith sleeps for the correct amount of time for the passage
 // of n bytes according to the current bandwidth limit
-func limitBandwidth(n int) {
+//
+// This doesn't hold tokenBucketMu while waiting so a transfer which
+// is cancelled isn't stuck behind the others waiting.
+//
+// It returns true if there is a limit and an error if ctx was
+// cancelled while waiting.
+func limitBandwidth(ctx context.Context, n int) (limited bool, err error) {
 	tokenBucketMu.Lock()
+	tb := tokenBucket
+	tokenBucketMu.Unlock()
+	if tb == nil {
+		return false, nil
+	}
+	return true, waitLimit(ctx, nil, tb, n)
+}
 
-	// Limit the transfer speed if required
-	if tokenBucket != nil {
-		err := tokenBucket.WaitN(context.Background(), n)
-		if err != nil {
-			fs.Errorf(nil, "Token bucket error: %v", err)
-		}
+// limitBucketBandwidth sleeps for the correct amount of time for the
+// passage of n bytes according to the bandwidth limit of bucket if
+// it has one.
+//
+// This doesn't hold tokenBucketMu while waiting so busy buckets don't
+// hold up transfers to other buckets.
+//
+// It returns true if the bucket has a limit and an error if ctx was
+// cancelled while waiting.
+func limitBucketBandwidth(ctx context.Context, bucket string, n int) (limited bool, err error) {
+	bucketLimitsMu.Lock()
+	tb := bucketLimits[bucket]
+	bucketLimitsMu.Unlock()
+	if tb == nil {
+		return false, nil
+	}
+	return true, waitLimit(ctx, bucket, tb, n)
+}
+
+// limitBindBandwidth sleeps for the correct amount of time for the
+// passage of n bytes according to the bandwidth limit of the local
+// address bind if it has one.
+//
+// It returns true if the address has a limit and an error if ctx was
+// cancelled while waiting.
+func limitBindBandwidth(ctx context.Context, bind string, n int) (limited bool, err error) {
+	bindLimitsMu.Lock()
+	tb := bindLimits[bind]
+	bindLimitsMu.Unlock()
+	if tb == nil {
+		return false, nil
 	}
+	return true, waitLimit(ctx, bind, tb, n)
+}
 
-	tokenBucketMu.Unlock()
+// CurrentBwLimit returns the current bandwidth limit or -1 if
+// unlimited
+func CurrentBwLimit() fs.SizeSuffix {
+	tokenBucketMu.Lock()
+	defer tokenBucketMu.Unlock()
+	if tokenBucket == nil {
+		return -1
+	}
+	return fs.SizeSuffix(tokenBucket.Limit())
 }
 
 // SetBwLimit sets the current bandwidth limit
@@ -128,6 +576,20 @@ func SetBwLimit(bandwidth fs.SizeSuffix) {
 	}
 }
 
+// SetBwLimitTimetable installs a new bandwidth timetable.
+//
+// The limit from it is applied at the next minute tick, starting the
+// ticker if it isn't already running.  This undoes any cancelled or
+// deferred changes.
+func SetBwLimitTimetable(timetable fs.BwTimetable) {
+	currLimitMu.Lock()
+	fs.Config.BwLimit = timetable
+	bwLimitHold = time.Time{}
+	currLimitMu.Unlock()
+	fs.Logf(nil, "Bandwidth timetable set to %v", timetable)
+	startTokenTicker()
+}
+
 // Remote control for the token bucket
 func init() {
 	rc.Add(rc.Call{
@@ -193,3 +655,126 @@ In either case "rate" is returned as a human readable string, and
 `,
 	})
 }
+
+// Remote control for the timetable
+func init() {
+	rc.Add(rc.Call{
+		Path: "core/bwlimit/timetable",
+		Fn: func(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+			if in["timetable"] != nil {
+				timetable, err := in.GetString("timetable")
+				if err != nil {
+					return out, err
+				}
+				var bws fs.BwTimetable
+				err = bws.Set(timetable)
+				if err != nil {
+					return out, errors.Wrap(err, "bad timetable")
+				}
+				SetBwLimitTimetable(bws)
+			}
+			currLimitMu.Lock()
+			out = rc.Params{
+				"timetable": fs.Config.BwLimit.String(),
+			}
+			currLimitMu.Unlock()
+			return out, nil
+		},
+		Title: "Set the bandwidth limit timetable.",
+		Help: `
+This replaces the bandwidth limit timetable with the one passed in.
+
+Eg
+
+    rclone rc core/bwlimit/timetable timetable="08:00,512 12:00,10M 13:00,512 18:00,30M 23:00,off"
+    {
+        "timetable": "Sunday-0800,512k Sunday-1200,10M ..."
+    }
+
+The new timetable takes effect at the next minute, and is used from
+then on as if it had been passed to --bwlimit at startup.
+
+If the timetable parameter is not supplied then the timetable is
+queried.
+
+The format of the parameter is exactly the same as passed to
+--bwlimit.
+`,
+	})
+}
+
+// Remote control for the next scheduled change of the bandwidth limit
+func init() {
+	rc.Add(rc.Call{
+		Path: "core/bwlimit/next",
+		Fn: func(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+			now := time.Now()
+			cancel, err := in.GetBool("cancel")
+			if rc.NotErrParamNotFound(err) {
+				return out, err
+			}
+			if cancel {
+				err = cancelBwLimitChange(now)
+				if err != nil {
+					return out, err
+				}
+			}
+			if in["defer"] != nil {
+				d, err := in.GetDuration("defer")
+				if err != nil {
+					return out, err
+				}
+				err = deferBwLimitChange(now, d)
+				if err != nil {
+					return out, err
+				}
+			}
+			currLimitMu.Lock()
+			at, ts, ok := nextBwLimitChange(now)
+			currLimitMu.Unlock()
+			out = rc.Params{
+				"scheduled": ok,
+			}
+			if ok {
+				out["at"] = at.Format(time.RFC3339)
+				out["rate"] = ts.Bandwidth.String()
+				out["bytesPerSecond"] = int64(ts.Bandwidth)
+			}
+			return out, nil
+		},
+		Title: "Query, cancel or defer the next scheduled bandwidth limit change.",
+		Help: `
+This returns the next change the --bwlimit timetable will make to the
+bandwidth limit.
+
+Eg
+
+    rclone rc core/bwlimit/next
+    {
+        "at": "2020-06-01T18:00:00+01:00",
+        "bytesPerSecond": 524288,
+        "rate": "512k",
+        "scheduled": true
+    }
+
+"scheduled" is false and the other values are missing if the
+timetable won't change the limit.  "bytesPerSecond" is -1 and "rate"
+is "off" if the change is to unlimited.  The change is made at the
+first minute tick at or after "at".
+
+Parameters
+
+- cancel - set to true to cancel the next change
+- defer - duration to defer the next change by, eg "2h"
+
+If the next change is cancelled then the current limit stays in
+force until the timetable changes the limit again.  If it is deferred
+then the current limit stays in force until "defer" after the change
+was due, skipping any other changes due before then.  In either case
+the new next change is returned.
+
+Setting the timetable again with core/bwlimit/timetable undoes any
+cancelled or deferred changes.
+`,
+	})
+}
@@ -1,9 +1,21 @@
 package accounting
 
 import (
+	"bytes"
 	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fshttp"
 	"github.com/rclone/rclone/fs/rc"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -25,6 +37,7 @@ func TestRcBwLimit(t *testing.T) {
 		"rate":           "1M",
 	}, out)
 	assert.Equal(t, rate.Limit(1048576), tokenBucket.Limit())
+	assert.Equal(t, fs.SizeSuffix(1048576), CurrentBwLimit())
 
 	// Query
 	in = rc.Params{}
@@ -46,6 +59,7 @@ func TestRcBwLimit(t *testing.T) {
 		"rate":           "off",
 	}, out)
 	assert.Nil(t, tokenBucket)
+	assert.Equal(t, fs.SizeSuffix(-1), CurrentBwLimit())
 
 	// Query
 	in = rc.Params{}
@@ -57,3 +71,769 @@ func TestRcBwLimit(t *testing.T) {
 	}, out)
 
 }
+
+func TestBucketLimits(t *testing.T) {
+	oldBwLimitBucket := fs.Config.BwLimitBucket
+	defer func() {
+		fs.Config.BwLimitBucket = oldBwLimitBucket
+		bucketLimitsMu.Lock()
+		bucketLimits = map[string]*rate.Limiter{}
+		bucketLimitsMu.Unlock()
+	}()
+	fs.Config.BwLimitBucket = map[string]fs.SizeSuffix{
+		"hot":  1024 * 1024,
+		"cold": 0,
+	}
+	StartTokenBucket()
+
+	require.NotNil(t, bucketLimits["hot"])
+	assert.Equal(t, rate.Limit(1024*1024), bucketLimits["hot"].Limit())
+	assert.Nil(t, bucketLimits["cold"])
+
+	ctx := context.Background()
+	assert.Equal(t, "", bucketFromContext(ctx))
+	ctx = WithBucket(ctx, "hot")
+	assert.Equal(t, "hot", bucketFromContext(ctx))
+
+	tr := NewStats().NewTransferRemoteSize("test", 1)
+	defer tr.Done(nil)
+	acc := tr.Account(ctx, nil)
+	assert.Equal(t, "hot", acc.bucket)
+
+	// Unlimited buckets should return immediately
+	limited, err := limitBucketBandwidth(ctx, "cold", 1)
+	require.NoError(t, err)
+	assert.False(t, limited)
+}
+
+func TestBwLimiter(t *testing.T) {
+	l := NewBwLimiter(-1)
+	assert.Equal(t, fs.SizeSuffix(-1), l.Limit())
+	// Unlimited should return immediately
+	limited, err := l.wait(context.Background(), 1)
+	require.NoError(t, err)
+	assert.False(t, limited)
+	l.SetLimit(1024 * 1024)
+	assert.Equal(t, fs.SizeSuffix(1024*1024), l.Limit())
+	l.SetLimit(0)
+	assert.Equal(t, fs.SizeSuffix(-1), l.Limit())
+
+	ctx := context.Background()
+	assert.Nil(t, bwLimiterFromContext(ctx))
+	ctx = WithBwLimiter(ctx, l)
+	assert.Equal(t, l, bwLimiterFromContext(ctx))
+
+	tr := NewStats().NewTransferRemoteSize("test", 1)
+	defer tr.Done(nil)
+	acc := tr.Account(ctx, nil)
+	assert.Equal(t, l, acc.limiter)
+}
+
+func TestRcBwLimitTimetable(t *testing.T) {
+	oldBwLimit := fs.Config.BwLimit
+	defer func() {
+		currLimitMu.Lock()
+		fs.Config.BwLimit = oldBwLimit
+		currLimitMu.Unlock()
+	}()
+
+	call := rc.Calls.Get("core/bwlimit/timetable")
+	assert.NotNil(t, call)
+
+	// Set
+	in := rc.Params{
+		"timetable": "Mon-08:00,512k Mon-12:00,10M",
+	}
+	out, err := call.Fn(context.Background(), in)
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{
+		"timetable": "Monday-0800,512k Monday-1200,10M",
+	}, out)
+	currLimitMu.Lock()
+	assert.Equal(t, 2, len(fs.Config.BwLimit))
+	assert.True(t, tokenTickerOn)
+	currLimitMu.Unlock()
+
+	// Query
+	out, err = call.Fn(context.Background(), rc.Params{})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{
+		"timetable": "Monday-0800,512k Monday-1200,10M",
+	}, out)
+
+	// Bad timetable
+	_, err = call.Fn(context.Background(), rc.Params{"timetable": "potato"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad timetable")
+}
+
+func TestBwLimitNextChange(t *testing.T) {
+	oldBwLimit := fs.Config.BwLimit
+	defer func() {
+		currLimitMu.Lock()
+		fs.Config.BwLimit = oldBwLimit
+		bwLimitHold = time.Time{}
+		currLimitMu.Unlock()
+	}()
+	currLimitMu.Lock()
+	require.NoError(t, fs.Config.BwLimit.Set("11:00,333k 13:00,666k"))
+	currLimitMu.Unlock()
+	// Thursday
+	now := time.Date(2017, time.April, 20, 12, 0, 0, 0, time.UTC)
+	next := func() (time.Time, fs.SizeSuffix) {
+		currLimitMu.Lock()
+		defer currLimitMu.Unlock()
+		at, ts, ok := nextBwLimitChange(now)
+		require.True(t, ok)
+		return at, ts.Bandwidth
+	}
+	limitAt := func(tt time.Time) fs.SizeSuffix {
+		currLimitMu.Lock()
+		defer currLimitMu.Unlock()
+		return timetableLimitAt(tt).Bandwidth
+	}
+
+	at, bw := next()
+	assert.Equal(t, time.Date(2017, time.April, 20, 13, 0, 0, 0, time.UTC), at)
+	assert.Equal(t, fs.SizeSuffix(666*1024), bw)
+
+	// Cancel holds the limit until the change after
+	require.NoError(t, cancelBwLimitChange(now))
+	assert.Equal(t, fs.SizeSuffix(333*1024), limitAt(now.Add(2*time.Hour)))
+	at, bw = next()
+	assert.Equal(t, time.Date(2017, time.April, 21, 13, 0, 0, 0, time.UTC), at)
+	assert.Equal(t, fs.SizeSuffix(666*1024), bw)
+	assert.Equal(t, fs.SizeSuffix(666*1024), limitAt(at))
+
+	// Defer holds the limit for the time given
+	SetBwLimitTimetable(fs.Config.BwLimit)
+	require.NoError(t, deferBwLimitChange(now, 30*time.Minute))
+	assert.Equal(t, fs.SizeSuffix(333*1024), limitAt(now.Add(75*time.Minute)))
+	at, bw = next()
+	assert.Equal(t, time.Date(2017, time.April, 20, 13, 30, 0, 0, time.UTC), at)
+	assert.Equal(t, fs.SizeSuffix(666*1024), bw)
+	assert.Equal(t, fs.SizeSuffix(666*1024), limitAt(at))
+	require.Error(t, deferBwLimitChange(now, 0))
+
+	// Nothing to cancel without a timetable
+	SetBwLimitTimetable(fs.BwTimetable{})
+	require.Error(t, cancelBwLimitChange(now))
+	require.Error(t, deferBwLimitChange(now, time.Hour))
+
+	call := rc.Calls.Get("core/bwlimit/next")
+	require.NotNil(t, call)
+	out, err := call.Fn(context.Background(), rc.Params{})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{"scheduled": false}, out)
+	_, err = call.Fn(context.Background(), rc.Params{"cancel": true})
+	require.Error(t, err)
+}
+
+func TestRcConfigDump(t *testing.T) {
+	call := rc.Calls.Get("core/config/dump")
+	require.NotNil(t, call)
+
+	SetBwLimit(1024 * 1024)
+	defer SetBwLimit(-1)
+
+	out, err := call.Fn(context.Background(), rc.Params{})
+	require.NoError(t, err)
+	bwlimit, ok := out["bwlimit"].(rc.Params)
+	require.True(t, ok)
+	assert.Equal(t, "1M", bwlimit["rate"])
+	assert.Equal(t, int64(1048576), bwlimit["bytesPerSecond"])
+	assert.Equal(t, false, bwlimit["toggledOff"])
+}
+
+func TestApplyBwBudget(t *testing.T) {
+	oldBudget, oldRate := fs.Config.BwLimitBudget, fs.Config.BwLimitBudgetRate
+	defer func() {
+		fs.Config.BwLimitBudget, fs.Config.BwLimitBudgetRate = oldBudget, oldRate
+		bwBudget.day = time.Time{}
+		bwBudget.lastTick = time.Time{}
+		bwBudget.used = 0
+		bwBudget.exhausted = false
+		atomic.StoreInt64(&bwBudgetBytes, 0)
+	}()
+	fs.Config.BwLimitBudget = 2 * time.Minute
+	fs.Config.BwLimitBudgetRate = 1024
+	unlimited := fs.BwTimeSlot{Bandwidth: -1}
+	fast := fs.BwTimeSlot{Bandwidth: 1024 * 1024}
+	slow := fs.BwTimeSlot{Bandwidth: 512}
+
+	now := time.Date(2020, 6, 1, 22, 0, 0, 0, time.Local)
+	tick := func(active bool, limit fs.BwTimeSlot) fs.SizeSuffix {
+		if active {
+			bwBudgetActive(100)
+		}
+		now = now.Add(time.Minute)
+		return applyBwBudget(now, limit).Bandwidth
+	}
+
+	// Starts the budget
+	assert.Equal(t, fs.SizeSuffix(-1), tick(true, unlimited))
+	// Idle time isn't counted
+	assert.Equal(t, fs.SizeSuffix(-1), tick(false, unlimited))
+	assert.Equal(t, fs.SizeSuffix(-1), tick(true, unlimited))
+	assert.False(t, bwBudget.exhausted)
+	// Budget used up so limited
+	assert.Equal(t, fs.SizeSuffix(1024), tick(true, unlimited))
+	assert.True(t, bwBudget.exhausted)
+	assert.Equal(t, fs.SizeSuffix(1024), tick(false, fast))
+	// Lower limits from the timetable are kept
+	assert.Equal(t, fs.SizeSuffix(512), tick(true, slow))
+
+	// Reset at midnight
+	now = time.Date(2020, 6, 1, 23, 59, 30, 0, time.Local)
+	assert.Equal(t, fs.SizeSuffix(1024*1024), tick(true, fast))
+	assert.False(t, bwBudget.exhausted)
+	assert.Equal(t, time.Duration(0), bwBudget.used)
+
+	// Does nothing if not set
+	fs.Config.BwLimitBudget = 0
+	bwBudgetActive(100)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&bwBudgetBytes))
+	assert.Equal(t, fs.SizeSuffix(-1), applyBwBudget(now, unlimited).Bandwidth)
+}
+
+func TestBwLimitAfter(t *testing.T) {
+	oldBwLimit, oldAfter, oldPeriod := fs.Config.BwLimit, fs.Config.BwLimitAfter, fs.Config.BwLimitAfterPeriod
+	defer func() {
+		fs.Config.BwLimit, fs.Config.BwLimitAfter, fs.Config.BwLimitAfterPeriod = oldBwLimit, oldAfter, oldPeriod
+		bwAfter.periodStart = time.Time{}
+		atomic.StoreInt32(&bwAfter.reached, 0)
+		atomic.StoreInt64(&bwAfterBytes, 0)
+		tokenBucketMu.Lock()
+		tokenBucket = nil
+		tokenBucketMu.Unlock()
+		currLimitMu.Lock()
+		currLimit = fs.BwTimeSlot{}
+		currLimitMu.Unlock()
+	}()
+	fast := fs.BwTimeSlot{Bandwidth: 1024 * 1024}
+	fs.Config.BwLimit = fs.BwTimetable{fast}
+	fs.Config.BwLimitAfter = 1000
+	fs.Config.BwLimitAfterPeriod = time.Hour
+
+	// Unlimited until the threshold is reached
+	now := time.Now()
+	assert.Equal(t, fs.SizeSuffix(0), applyBwAfter(now, fast).Bandwidth)
+	bwAfterActive(600)
+	assert.Equal(t, fs.SizeSuffix(0), applyBwAfter(now, fast).Bandwidth)
+	assert.Equal(t, fs.SizeSuffix(-1), CurrentBwLimit())
+
+	// The limit starts as soon as it is reached
+	bwAfterActive(600)
+	assert.Equal(t, fast.Bandwidth, CurrentBwLimit())
+	assert.Equal(t, fast.Bandwidth, applyBwAfter(now.Add(30*time.Minute), fast).Bandwidth)
+
+	// Unlimited again at the start of the next period
+	assert.Equal(t, fs.SizeSuffix(0), applyBwAfter(now.Add(61*time.Minute), fast).Bandwidth)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&bwAfterBytes))
+	bwAfterActive(999)
+	assert.Equal(t, fs.SizeSuffix(0), applyBwAfter(now.Add(62*time.Minute), fast).Bandwidth)
+
+	// Not reset if there is no period
+	fs.Config.BwLimitAfterPeriod = 0
+	bwAfterActive(1)
+	assert.Equal(t, fast.Bandwidth, applyBwAfter(now.Add(1000*time.Hour), fast).Bandwidth)
+}
+
+func TestBwLimitRelative(t *testing.T) {
+	oldBwLimit, oldRelative := fs.Config.BwLimit, fs.Config.BwLimitRelative
+	defer func() {
+		fs.Config.BwLimit, fs.Config.BwLimitRelative = oldBwLimit, oldRelative
+		currLimitMu.Lock()
+		bwRelativeStart = time.Time{}
+		bwRelativeTimersOn = false
+		currLimit = fs.BwTimeSlot{}
+		currLimitMu.Unlock()
+		tokenBucketMu.Lock()
+		tokenBucket = nil
+		tokenBucketMu.Unlock()
+	}()
+	slow := fs.BwTimeSlot{Bandwidth: 1024}
+	fs.Config.BwLimit = fs.BwTimetable{slow}
+	fs.Config.BwLimitRelative = fs.BwRelativeTimetable{
+		{Offset: time.Minute, Bandwidth: -1},
+		{Offset: 10 * time.Minute, Bandwidth: 2 * 1024 * 1024},
+	}
+
+	// Does nothing until the run has started
+	now := time.Now()
+	assert.Equal(t, slow.Bandwidth, applyBwRelative(now, slow).Bandwidth)
+
+	// The timetable applies before the first offset then the
+	// relative timetable overrides it
+	bwRelativeStart = now
+	assert.Equal(t, slow.Bandwidth, applyBwRelative(now.Add(30*time.Second), slow).Bandwidth)
+	assert.Equal(t, fs.SizeSuffix(-1), applyBwRelative(now.Add(time.Minute), slow).Bandwidth)
+	assert.Equal(t, fs.SizeSuffix(2*1024*1024), applyBwRelative(now.Add(time.Hour), slow).Bandwidth)
+
+	// The changes are made when they are reached
+	fs.Config.BwLimitRelative = fs.BwRelativeTimetable{
+		{Offset: 0, Bandwidth: -1},
+		{Offset: 50 * time.Millisecond, Bandwidth: 2 * 1024 * 1024},
+	}
+	bwRelativeStart = time.Now()
+	updateBwLimit(time.Now())
+	assert.Equal(t, fs.SizeSuffix(-1), CurrentBwLimit())
+	startBwRelativeTimers()
+	assert.Eventually(t, func() bool {
+		return CurrentBwLimit() == 2*1024*1024
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestParseBwSource(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want fs.SizeSuffix
+		err  bool
+	}{
+		{"", 0, true},
+		{" \n", 0, true},
+		{"bad", 0, true},
+		{"2M", 2 * 1024 * 1024, false},
+		{" 512k \n", 512 * 1024, false},
+		{"1M\x00\x00\x00", 1024 * 1024, false},
+		{"1M\n2M\n", 1024 * 1024, false},
+		{"off", -1, false},
+	} {
+		got, err := parseBwSource([]byte(test.in))
+		if test.err {
+			assert.Error(t, err, test.in)
+		} else {
+			assert.NoError(t, err, test.in)
+			assert.Equal(t, test.want, got, test.in)
+		}
+	}
+}
+
+func TestClampBwSource(t *testing.T) {
+	for _, test := range []struct {
+		bandwidth fs.SizeSuffix
+		ceiling   fs.SizeSuffix
+		want      fs.SizeSuffix
+	}{
+		{-1, -1, -1},
+		{0, 1024 * 1024, 1024 * 1024},
+		{1, -1, bwLimitMin},
+		{512 * 1024, -1, 512 * 1024},
+		{512 * 1024, 1024 * 1024, 512 * 1024},
+		{2 * 1024 * 1024, 1024 * 1024, 1024 * 1024},
+	} {
+		assert.Equal(t, test.want, clampBwLimit(test.bandwidth, test.ceiling), test)
+	}
+}
+
+func TestBwSourcePoll(t *testing.T) {
+	oldBwLimit := fs.Config.BwLimit
+	defer func() {
+		fs.Config.BwLimit = oldBwLimit
+		tokenBucketMu.Lock()
+		tokenBucket = nil
+		tokenBucketMu.Unlock()
+	}()
+	fs.Config.BwLimit = nil
+	dir, err := ioutil.TempDir("", "rclone-bwsource")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "bwlimit")
+	s := newBwSource(path)
+	write := func(value string) {
+		require.NoError(t, ioutil.WriteFile(path, []byte(value), 0600))
+	}
+
+	// Missing or invalid leaves the limit alone
+	s.poll()
+	assert.Equal(t, fs.SizeSuffix(-1), CurrentBwLimit())
+	assert.NotEqual(t, "", s.lastErr)
+	write("bad")
+	s.poll()
+	assert.Equal(t, fs.SizeSuffix(-1), CurrentBwLimit())
+
+	// The limit is set then changed without replacing the bucket
+	write("1M\n")
+	s.poll()
+	assert.Equal(t, "", s.lastErr)
+	assert.Equal(t, fs.SizeSuffix(1024*1024), CurrentBwLimit())
+	tokenBucketMu.Lock()
+	tb := tokenBucket
+	tokenBucketMu.Unlock()
+	write("2M\x00")
+	s.poll()
+	assert.Equal(t, fs.SizeSuffix(2*1024*1024), CurrentBwLimit())
+	tokenBucketMu.Lock()
+	assert.True(t, tb == tokenBucket)
+	tokenBucketMu.Unlock()
+
+	// Clamped to the --bwlimit
+	fs.Config.BwLimit = fs.BwTimetable{{Bandwidth: 1024 * 1024}}
+	s.poll()
+	assert.Equal(t, fs.SizeSuffix(1024*1024), CurrentBwLimit())
+
+	// Unlimited goes back to the --bwlimit
+	write("off")
+	fs.Config.BwLimit = nil
+	s.poll()
+	assert.Equal(t, fs.SizeSuffix(-1), CurrentBwLimit())
+
+	// Too long
+	write(strings.Repeat("1", bwSourceMaxSize))
+	s.poll()
+	assert.Contains(t, s.lastErr, "longer than")
+}
+
+func TestBwLimitPercent(t *testing.T) {
+	oldBwLimit, oldPercent := fs.Config.BwLimit, fs.Config.BwLimitPercent
+	defer func() {
+		fs.Config.BwLimit, fs.Config.BwLimitPercent = oldBwLimit, oldPercent
+		currLimitMu.Lock()
+		bwPercentBaseline = 0
+		currLimit = fs.BwTimeSlot{}
+		currLimitMu.Unlock()
+		tokenBucketMu.Lock()
+		tokenBucket = nil
+		tokenBucketMu.Unlock()
+	}()
+	fs.Config.BwLimit = nil
+	require.NoError(t, fs.Config.BwLimitPercent.Set("Mon-00:00,50% Tue-00:00,off"))
+	monday := time.Date(2017, time.April, 17, 12, 0, 0, 0, time.Local)
+	tuesday := monday.AddDate(0, 0, 1)
+	unlimited := fs.BwTimeSlot{Bandwidth: -1}
+
+	// Does nothing until the bandwidth has been measured
+	assert.Equal(t, unlimited, applyBwPercent(monday, unlimited))
+
+	// The percentage of the measurement is kept below the --bwlimit
+	bwPercentBaseline = 4 * 1024 * 1024
+	assert.Equal(t, fs.SizeSuffix(2*1024*1024), applyBwPercent(monday, unlimited).Bandwidth)
+	assert.Equal(t, fs.SizeSuffix(1024*1024), applyBwPercent(monday, fs.BwTimeSlot{Bandwidth: 1024 * 1024}).Bandwidth)
+	assert.Equal(t, unlimited, applyBwPercent(tuesday, unlimited))
+	bwPercentBaseline = 1
+	assert.Equal(t, bwLimitMin, applyBwPercent(monday, unlimited).Bandwidth)
+
+	// A new measurement changes the limit without replacing the bucket
+	bwPercentBaseline = 0
+	setBwPercentBaseline(monday, 4*1024*1024)
+	assert.Equal(t, fs.SizeSuffix(2*1024*1024), CurrentBwLimit())
+	tokenBucketMu.Lock()
+	tb := tokenBucket
+	tokenBucketMu.Unlock()
+	setBwPercentBaseline(monday, 2*1024*1024)
+	assert.Equal(t, fs.SizeSuffix(1024*1024), CurrentBwLimit())
+	tokenBucketMu.Lock()
+	assert.True(t, tb == tokenBucket)
+	tokenBucketMu.Unlock()
+}
+
+func TestMeasureBandwidth(t *testing.T) {
+	ctx := context.Background()
+	data := strings.Repeat("x", 1024*1024)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/probe" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(data))
+	}))
+	defer ts.Close()
+	client := ts.Client()
+
+	bandwidth, err := measureBandwidth(ctx, client, ts.URL+"/probe")
+	require.NoError(t, err)
+	assert.True(t, bandwidth > 0)
+
+	_, err = measureBandwidth(ctx, client, ts.URL+"/missing")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "404")
+
+	// A failed measurement keeps the last one
+	oldProbe := fs.Config.BwLimitPercentProbe
+	defer func() {
+		fs.Config.BwLimitPercentProbe = oldProbe
+		currLimitMu.Lock()
+		bwPercentBaseline = 0
+		currLimitMu.Unlock()
+	}()
+	fs.Config.BwLimitPercentProbe = ts.URL + "/missing"
+	bwPercentBaseline = 1024 * 1024
+	probeBwPercent(ctx, client)
+	assert.Equal(t, fs.SizeSuffix(1024*1024), bwPercentBaseline)
+}
+
+func TestBwReservationShare(t *testing.T) {
+	oldRate := fs.Config.MinTransferRate
+	defer func() {
+		fs.Config.MinTransferRate = oldRate
+	}()
+	fs.Config.MinTransferRate = 100
+	for _, test := range []struct {
+		limit      rate.Limit
+		active     int
+		guaranteed rate.Limit
+		remainder  rate.Limit
+	}{
+		{1000, 0, 100, 1000},
+		{1000, 1, 100, 900},
+		{1000, 5, 100, 500},
+		{1000, 10, 100, 0},
+		{1000, 20, 50, 0},
+	} {
+		guaranteed, remainder := share(test.limit, test.active)
+		assert.Equal(t, test.guaranteed, guaranteed, test)
+		assert.Equal(t, test.remainder, remainder, test)
+	}
+}
+
+// waitOK returns a function which checks the wait for a bandwidth
+// limit didn't fail and returns whether it was limited
+func waitOK(t *testing.T) func(limited bool, err error) bool {
+	return func(limited bool, err error) bool {
+		require.NoError(t, err)
+		return limited
+	}
+}
+
+func TestBwReservation(t *testing.T) {
+	ctx := context.Background()
+	limited := waitOK(t)
+	oldRate := fs.Config.MinTransferRate
+	defer func() {
+		fs.Config.MinTransferRate = oldRate
+		tokenBucketMu.Lock()
+		tokenBucket = nil
+		tokenBucketMu.Unlock()
+	}()
+
+	// Not reserved unless --min-transfer-rate is set
+	fs.Config.MinTransferRate = 0
+	assert.Nil(t, newBwReservation())
+
+	fs.Config.MinTransferRate = 1024 * 1024
+	r1 := newBwReservation()
+	r2 := newBwReservation()
+	require.NotNil(t, r1)
+	require.NotNil(t, r2)
+	bwReservations.mu.Lock()
+	assert.Equal(t, 2, len(bwReservations.active))
+	bwReservations.mu.Unlock()
+
+	// Not limited without a --bwlimit
+	assert.False(t, limited(r1.wait(ctx, 100)))
+
+	// With a limit the slices are shared equally
+	tokenBucketMu.Lock()
+	tokenBucket = newTokenBucket(1024 * 1024)
+	tokenBucketMu.Unlock()
+	assert.True(t, limited(r1.wait(ctx, 100)))
+	assert.Equal(t, rate.Limit(512*1024), r1.tb.Limit())
+	assert.Equal(t, rate.Limit(0), bwReservations.shared.Limit())
+
+	// Once released the remaining transfer gets the whole limit
+	r2.release()
+	assert.True(t, limited(r1.wait(ctx, 100)))
+	assert.Equal(t, rate.Limit(1024*1024), r1.tb.Limit())
+	r1.release()
+	bwReservations.mu.Lock()
+	assert.Equal(t, 0, len(bwReservations.active))
+	bwReservations.mu.Unlock()
+}
+
+func TestBindLimits(t *testing.T) {
+	oldBwLimitBind, oldBindRemote := fs.Config.BwLimitBind, fs.Config.BindRemote
+	defer func() {
+		fs.Config.BwLimitBind, fs.Config.BindRemote = oldBwLimitBind, oldBindRemote
+		bindLimitsMu.Lock()
+		bindLimits = map[string]*rate.Limiter{}
+		bindLimitsMu.Unlock()
+	}()
+	fs.Config.BwLimitBind = map[string]fs.SizeSuffix{
+		"192.168.1.2": 1024 * 1024,
+		"192.168.1.3": 0,
+	}
+	fs.Config.BindRemote = map[string]net.IP{
+		"uplink": net.ParseIP("192.168.1.2"),
+	}
+	StartTokenBucket()
+
+	require.NotNil(t, bindLimits["192.168.1.2"])
+	assert.Equal(t, rate.Limit(1024*1024), bindLimits["192.168.1.2"].Limit())
+	assert.Nil(t, bindLimits["192.168.1.3"])
+
+	ctx := context.Background()
+	assert.Equal(t, "", bindFromContext(ctx))
+	ctx = fshttp.WithBind(ctx, "uplink")
+	assert.Equal(t, "192.168.1.2", bindFromContext(ctx))
+
+	tr := NewStats().NewTransferRemoteSize("test", 1)
+	defer tr.Done(nil)
+	acc := tr.Account(ctx, nil)
+	assert.Equal(t, "192.168.1.2", acc.bind)
+
+	// Unlimited addresses should return immediately
+	limited, err := limitBindBandwidth(ctx, "192.168.1.3", 1)
+	require.NoError(t, err)
+	assert.False(t, limited)
+}
+
+func TestWaitNBiggerThanBurst(t *testing.T) {
+	ctx := context.Background()
+	limited := waitOK(t)
+
+	// A request bigger than the burst is split rather than failing
+	tb := rate.NewLimiter(1000, 10)
+	start := time.Now()
+	require.NoError(t, waitN(ctx, tb, 35))
+	// the first 10 tokens are in the bucket and the next 25 take 25ms
+	assert.True(t, time.Since(start) >= 20*time.Millisecond, time.Since(start))
+
+	// No burst can never be satisfied
+	require.Error(t, waitN(ctx, rate.NewLimiter(1000, 0), 1))
+
+	// Unlimited buckets don't care about the burst
+	require.NoError(t, waitN(ctx, rate.NewLimiter(rate.Inf, 0), 1000))
+
+	// The bandwidth limit splits big requests too
+	tokenBucketMu.Lock()
+	tokenBucket = newTokenBucket(1024 * 1024 * 1024)
+	tokenBucketMu.Unlock()
+	defer func() {
+		tokenBucketMu.Lock()
+		tokenBucket = nil
+		tokenBucketMu.Unlock()
+	}()
+	assert.True(t, limited(limitBandwidth(ctx, 3*maxBurstSize)))
+
+	oldRate := fs.Config.MinTransferRate
+	defer func() {
+		fs.Config.MinTransferRate = oldRate
+	}()
+	fs.Config.MinTransferRate = 1024 * 1024 * 1024
+	r := newBwReservation()
+	defer r.release()
+	assert.True(t, limited(r.wait(ctx, 3*maxBurstSize)))
+}
+
+func TestWaitForStartTokens(t *testing.T) {
+	ctx := context.Background()
+	oldStartTokens := fs.Config.BwLimitStartTokens
+	defer func() {
+		fs.Config.BwLimitStartTokens = oldStartTokens
+		tokenBucketMu.Lock()
+		tokenBucket = nil
+		tokenBucketMu.Unlock()
+	}()
+
+	// Nothing to wait for without a --bwlimit
+	fs.Config.BwLimitStartTokens = 1024
+	require.NoError(t, WaitForStartTokens(ctx))
+
+	// An empty bucket takes 100ms to fill up with 100k at 1M/s ...
+	tokenBucketMu.Lock()
+	tokenBucket = newTokenBucket(1024 * 1024)
+	tokenBucketMu.Unlock()
+	fs.Config.BwLimitStartTokens = 100 * 1024
+	start := time.Now()
+	require.NoError(t, WaitForStartTokens(ctx))
+	dt := time.Since(start)
+	assert.True(t, dt >= 80*time.Millisecond, dt)
+
+	// ... and the tokens are left for the transfer to use
+	start = time.Now()
+	assert.True(t, waitOK(t)(limitBandwidth(ctx, 100*1024)))
+	dt = time.Since(start)
+	assert.True(t, dt < 50*time.Millisecond, dt)
+
+	// Waiting can be cancelled
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Equal(t, context.Canceled, WaitForStartTokens(cancelCtx))
+
+	// Nothing is waited for if not set
+	fs.Config.BwLimitStartTokens = 0
+	require.NoError(t, WaitForStartTokens(cancelCtx))
+}
+
+func TestLimitBandwidthCancel(t *testing.T) {
+	oldRate := fs.Config.MinTransferRate
+	defer func() {
+		fs.Config.MinTransferRate = oldRate
+		tokenBucketMu.Lock()
+		tokenBucket = nil
+		tokenBucketMu.Unlock()
+		bucketLimitsMu.Lock()
+		delete(bucketLimits, "slow")
+		bucketLimitsMu.Unlock()
+	}()
+	const bandwidth = 100 * 1024
+	tokenBucketMu.Lock()
+	tokenBucket = newTokenBucket(bandwidth)
+	tokenBucketMu.Unlock()
+	bucketLimitsMu.Lock()
+	bucketLimits["slow"] = newTokenBucket(bandwidth)
+	bucketLimitsMu.Unlock()
+	fs.Config.MinTransferRate = bandwidth
+	r := newBwReservation()
+	defer r.release()
+	l := NewBwLimiter(bandwidth)
+
+	// Cancelling a wait which would take 10s returns at once with
+	// the context error rather than logging it
+	for _, test := range []struct {
+		name string
+		wait func(ctx context.Context, n int) (bool, error)
+	}{
+		{"bwlimit", limitBandwidth},
+		{"bucket", func(ctx context.Context, n int) (bool, error) { return limitBucketBandwidth(ctx, "slow", n) }},
+		{"reservation", r.wait},
+		{"limiter", l.wait},
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		start := time.Now()
+		limited, err := test.wait(ctx, 10*bandwidth)
+		dt := time.Since(start)
+		assert.True(t, limited, test.name)
+		assert.Equal(t, context.Canceled, err, test.name)
+		assert.True(t, dt < time.Second, test.name, dt)
+
+		// An already cancelled context doesn't wait at all
+		limited, err = test.wait(ctx, 1)
+		assert.True(t, limited, test.name)
+		assert.Equal(t, context.Canceled, err, test.name)
+	}
+
+	// The tokens of a cancelled wait are given back
+	start := time.Now()
+	assert.True(t, waitOK(t)(limitBandwidth(context.Background(), 1024)))
+	assert.True(t, time.Since(start) < 500*time.Millisecond, time.Since(start))
+
+	// The wait stops at the deadline too
+	tokenBucketMu.Lock()
+	tokenBucket = newTokenBucket(bandwidth)
+	tokenBucketMu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	limited, err := limitBandwidth(ctx, bandwidth/5)
+	assert.True(t, limited)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(start) < 150*time.Millisecond, time.Since(start))
+
+	// Reads of a cancelled transfer stop with the context error
+	ctx, cancel = context.WithCancel(context.Background())
+	in := ioutil.NopCloser(bytes.NewBuffer(make([]byte, 10*bandwidth)))
+	acc := newAccountSizeName(ctx, NewStats(), in, 10*bandwidth, "test")
+	defer func() {
+		assert.NoError(t, acc.Close())
+		acc.Done()
+	}()
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start = time.Now()
+	_, err = ioutil.ReadAll(acc)
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, time.Since(start) < time.Second, time.Since(start))
+}
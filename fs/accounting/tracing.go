@@ -0,0 +1,371 @@
+package accounting
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fshttp"
+)
+
+// Tracing constants
+const (
+	traceInterval = 5 * time.Second  // how often the spans are sent
+	traceBatch    = 512              // send the spans early if this many are waiting
+	traceTimeout  = 30 * time.Second // how long to wait for the --trace-url to reply
+)
+
+// OTLP span kinds and status codes
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusOK         = 1
+	otlpStatusError      = 2
+)
+
+// Globals
+var (
+	tracerMu sync.Mutex // protects tracer
+	tracer   *otlpTracer
+)
+
+// Span times an operation, such as a transfer or a chunk of one, to
+// be sent to the --trace-url.
+//
+// All the methods may be called on a nil *Span which does nothing so
+// there is no cost to it when tracing isn't enabled.
+type Span struct {
+	t        *otlpTracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte // zero for the root span
+	name     string
+	start    time.Time
+
+	mu    sync.Mutex
+	attrs []otlpAttribute
+}
+
+// spanKey is the context key for the current span
+type spanKey struct{}
+
+// WithSpan returns a copy of ctx with span as the parent for the
+// spans started with it.
+func WithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// StartSpan starts a span called name if tracing is enabled.
+//
+// The span is a child of the span in ctx if there is one, otherwise
+// of the span for the whole run.  The returned context has the new
+// span as the parent for any spans started with it.
+//
+// If tracing isn't enabled this returns ctx and a nil *Span.
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	var parent *Span
+	if ctx != nil {
+		parent, _ = ctx.Value(spanKey{}).(*Span)
+	}
+	span := startSpan(parent, name)
+	if span == nil {
+		return ctx, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return WithSpan(ctx, span), span
+}
+
+// startSpan starts a span called name which is a child of parent or
+// of the root span if parent is nil.  It returns nil if tracing isn't
+// enabled.
+func startSpan(parent *Span, name string) *Span {
+	tracerMu.Lock()
+	t := tracer
+	tracerMu.Unlock()
+	if t == nil {
+		return nil
+	}
+	if parent == nil {
+		parent = t.root
+	}
+	span := &Span{
+		t:        t,
+		traceID:  parent.traceID,
+		parentID: parent.spanID,
+		name:     name,
+		start:    time.Now(),
+	}
+	_, _ = rand.Read(span.spanID[:])
+	return span
+}
+
+// SetAttribute sets the attribute key to value.  Strings, bools,
+// ints and floats are sent as they are, durations as seconds and
+// anything else as a string.
+func (span *Span) SetAttribute(key string, value interface{}) {
+	if span == nil {
+		return
+	}
+	attr := otlpAttribute{Key: key}
+	switch x := value.(type) {
+	case string:
+		attr.Value.StringValue = &x
+	case bool:
+		attr.Value.BoolValue = &x
+	case int:
+		s := strconv.Itoa(x)
+		attr.Value.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(x, 10)
+		attr.Value.IntValue = &s
+	case float64:
+		attr.Value.DoubleValue = &x
+	case time.Duration:
+		seconds := x.Seconds()
+		attr.Value.DoubleValue = &seconds
+	default:
+		s := fmt.Sprint(value)
+		attr.Value.StringValue = &s
+	}
+	span.mu.Lock()
+	span.attrs = append(span.attrs, attr)
+	span.mu.Unlock()
+}
+
+// End finishes the span which failed with err if it is set and queues
+// it to be sent.
+func (span *Span) End(err error) {
+	if span == nil {
+		return
+	}
+	span.mu.Lock()
+	s := otlpSpan{
+		TraceID:      hex.EncodeToString(span.traceID[:]),
+		SpanID:       hex.EncodeToString(span.spanID[:]),
+		Name:         span.name,
+		Kind:         otlpSpanKindInternal,
+		StartTimeNs:  strconv.FormatInt(span.start.UnixNano(), 10),
+		EndTimeNs:    strconv.FormatInt(time.Now().UnixNano(), 10),
+		Attributes:   span.attrs,
+		Status:       otlpStatus{Code: otlpStatusOK},
+		parentSpanID: span.parentID,
+	}
+	span.mu.Unlock()
+	if s.parentSpanID != ([8]byte{}) {
+		s.ParentSpanID = hex.EncodeToString(s.parentSpanID[:])
+	}
+	if err != nil {
+		s.Status = otlpStatus{Code: otlpStatusError, Message: err.Error()}
+	}
+	span.t.add(s)
+}
+
+// The OTLP/HTTP JSON encoding of the spans
+//
+// See https://github.com/open-telemetry/opentelemetry-proto
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	otlpSpan struct {
+		TraceID      string          `json:"traceId"`
+		SpanID       string          `json:"spanId"`
+		ParentSpanID string          `json:"parentSpanId,omitempty"`
+		Name         string          `json:"name"`
+		Kind         int             `json:"kind"`
+		StartTimeNs  string          `json:"startTimeUnixNano"`
+		EndTimeNs    string          `json:"endTimeUnixNano"`
+		Attributes   []otlpAttribute `json:"attributes,omitempty"`
+		Status       otlpStatus      `json:"status"`
+		parentSpanID [8]byte
+	}
+	otlpAttribute struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// otlpTracer sends the finished spans to an OTLP/HTTP endpoint
+type otlpTracer struct {
+	url    string
+	client *http.Client
+	root   *Span
+	full   chan struct{} // signalled when there are traceBatch spans waiting
+
+	mu    sync.Mutex
+	spans []otlpSpan // spans waiting to be sent
+}
+
+// newOTLPTracer makes a tracer sending to url with a root span called
+// name for the whole run
+func newOTLPTracer(url, name string) *otlpTracer {
+	t := &otlpTracer{
+		url:    url,
+		client: fshttp.NewClient(fs.Config),
+		full:   make(chan struct{}, 1),
+	}
+	t.root = &Span{
+		t:     t,
+		name:  name,
+		start: time.Now(),
+	}
+	_, _ = rand.Read(t.root.traceID[:])
+	_, _ = rand.Read(t.root.spanID[:])
+	return t
+}
+
+// add queues a finished span to be sent
+func (t *otlpTracer) add(s otlpSpan) {
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	n := len(t.spans)
+	t.mu.Unlock()
+	if n >= traceBatch {
+		select {
+		case t.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// send the spans waiting to be sent
+func (t *otlpTracer) send() {
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+	err := t.post(spans)
+	if err != nil {
+		fs.Debugf(nil, "Failed to send %d trace spans: %v", len(spans), err)
+	}
+}
+
+// post the spans to the url
+func (t *otlpTracer) post(spans []otlpSpan) error {
+	serviceName := "rclone"
+	req := otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpAttribute{{
+					Key:   "service.name",
+					Value: otlpAnyValue{StringValue: &serviceName},
+				}},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{
+					Name:    "github.com/rclone/rclone/fs/accounting",
+					Version: fs.Version,
+				},
+				Spans: spans,
+			}},
+		}},
+	}
+	body, err := json.Marshal(&req)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), traceTimeout)
+	defer cancel()
+	httpReq, err := http.NewRequest("POST", t.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("HTTP error %v", resp.Status)
+	}
+	return nil
+}
+
+// StartTracing starts sending spans for the transfers to the
+// --trace-url if set.  The whole run is traced as a span called name
+// which the transfers are children of.
+//
+// It returns a func which should be called with the error the run
+// finished with, if any, to send the last spans and stop.
+func StartTracing(name string) func(err error) {
+	if fs.Config.TraceURL == "" {
+		return func(error) {}
+	}
+	t := newOTLPTracer(fs.Config.TraceURL, name)
+	tracerMu.Lock()
+	tracer = t
+	tracerMu.Unlock()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(traceInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.send()
+			case <-t.full:
+				t.send()
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func(err error) {
+		close(stop)
+		wg.Wait()
+		tracerMu.Lock()
+		tracer = nil
+		tracerMu.Unlock()
+		t.root.End(err)
+		t.send()
+	}
+}
@@ -0,0 +1,100 @@
+package accounting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracing(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		spans []otlpSpan
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var req otlpRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		mu.Lock()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	// Nothing is traced without --trace-url
+	oldURL := fs.Config.TraceURL
+	defer func() {
+		fs.Config.TraceURL = oldURL
+	}()
+	fs.Config.TraceURL = ""
+	stop := StartTracing("rclone test")
+	ctx, span := StartSpan(context.Background(), "nothing")
+	assert.Nil(t, span)
+	assert.Equal(t, context.Background(), ctx)
+	span.SetAttribute("ignored", 1)
+	span.End(nil)
+	stop(nil)
+	assert.Equal(t, 0, len(spans))
+
+	fs.Config.TraceURL = server.URL
+	stop = StartTracing("rclone test")
+	s := NewStats()
+	tr := s.NewTransferRemoteSize("file", 100)
+	tr.SetDst(mockfs.NewFs("dst", "root"), "file")
+	tryCtx, done := tr.WithCancel(context.Background())
+	_, chunk := StartSpan(tryCtx, "chunk")
+	chunk.SetAttribute("rclone.part", int64(1))
+	chunk.End(errors.New("potato"))
+	done()
+	tr.Done(nil)
+	stop(nil)
+
+	attrs := func(s otlpSpan) map[string]otlpAnyValue {
+		m := map[string]otlpAnyValue{}
+		for _, attr := range s.Attributes {
+			m[attr.Key] = attr.Value
+		}
+		return m
+	}
+	require.Equal(t, 3, len(spans))
+	chunkSpan, transferSpan, rootSpan := spans[0], spans[1], spans[2]
+
+	assert.Equal(t, "rclone test", rootSpan.Name)
+	assert.Equal(t, "", rootSpan.ParentSpanID)
+	assert.Equal(t, otlpStatusOK, rootSpan.Status.Code)
+
+	assert.Equal(t, "transfer", transferSpan.Name)
+	assert.Equal(t, rootSpan.TraceID, transferSpan.TraceID)
+	assert.Equal(t, rootSpan.SpanID, transferSpan.ParentSpanID)
+	assert.Equal(t, otlpStatusOK, transferSpan.Status.Code)
+	a := attrs(transferSpan)
+	require.NotNil(t, a["rclone.remote"].StringValue)
+	assert.Equal(t, "file", *a["rclone.remote"].StringValue)
+	require.NotNil(t, a["rclone.size"].IntValue)
+	assert.Equal(t, "100", *a["rclone.size"].IntValue)
+	require.NotNil(t, a["rclone.dst"].StringValue)
+	assert.Equal(t, "Mock file system at root", *a["rclone.dst"].StringValue)
+
+	assert.Equal(t, "chunk", chunkSpan.Name)
+	assert.Equal(t, rootSpan.TraceID, chunkSpan.TraceID)
+	assert.Equal(t, transferSpan.SpanID, chunkSpan.ParentSpanID)
+	assert.Equal(t, otlpStatusError, chunkSpan.Status.Code)
+	assert.Equal(t, "potato", chunkSpan.Status.Message)
+	a = attrs(chunkSpan)
+	require.NotNil(t, a["rclone.part"].IntValue)
+	assert.Equal(t, "1", *a["rclone.part"].IntValue)
+}
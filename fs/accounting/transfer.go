@@ -1,12 +1,15 @@
 package accounting
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"sync"
 	"time"
 
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/hash"
 )
 
 // TransferSnapshot represents state of an account at point in time.
@@ -48,6 +51,8 @@ type Transfer struct {
 	size      int64
 	startedAt time.Time
 	checking  bool
+	srcFs     fs.Info // the Fs of the source if known
+	span      *Span   // traces the transfer if --trace-url is set
 
 	// Protects all below
 	//
@@ -58,6 +63,13 @@ type Transfer struct {
 	acc         *Account
 	err         error
 	completedAt time.Time
+	cancel      context.CancelFunc // cancels the current attempt if set
+	cancelErr   error              // the error the current attempt was cancelled with
+	dst         string             // where the transfer is to if known
+	dstFs       fs.Info            // the Fs of the destination if known
+	dstRemote   string             // the remote of the destination in dstFs if known
+	tries       int                // number of low level tries the transfer took
+	hashes      map[string]string  // hashes of the file transferred if known
 }
 
 // newCheckingTransfer instantiates new checking of the object.
@@ -67,7 +79,12 @@ func newCheckingTransfer(stats *StatsInfo, obj fs.Object) *Transfer {
 
 // newTransfer instantiates new transfer.
 func newTransfer(stats *StatsInfo, obj fs.Object) *Transfer {
-	return newTransferRemoteSize(stats, obj.Remote(), obj.Size(), false)
+	tr := newTransferRemoteSize(stats, obj.Remote(), obj.Size(), false)
+	tr.srcFs = obj.Fs()
+	if tr.span != nil && tr.srcFs != nil {
+		tr.span.SetAttribute("rclone.src", tr.srcFs.String())
+	}
+	return tr
 }
 
 func newTransferRemoteSize(stats *StatsInfo, remote string, size int64, checking bool) *Transfer {
@@ -78,6 +95,11 @@ func newTransferRemoteSize(stats *StatsInfo, remote string, size int64, checking
 		startedAt: time.Now(),
 		checking:  checking,
 	}
+	if !checking {
+		tr.span = startSpan(nil, "transfer")
+		tr.span.SetAttribute("rclone.remote", remote)
+		tr.span.SetAttribute("rclone.size", size)
+	}
 	stats.AddTransfer(tr)
 	return tr
 }
@@ -97,7 +119,19 @@ func (tr *Transfer) Done(err error) {
 	acc := tr.acc
 	tr.mu.RUnlock()
 
+	var (
+		bytes  int64
+		waited time.Duration
+	)
 	if acc != nil {
+		acc.values.mu.Lock()
+		bytes = acc.values.bytes
+		waited = acc.values.waited
+		acc.values.mu.Unlock()
+		if tr.span != nil {
+			tr.span.SetAttribute("rclone.bytes", bytes)
+			tr.span.SetAttribute("rclone.throttled", waited)
+		}
 		// Close the file if it is still open
 		if err := acc.Close(); err != nil {
 			fs.LogLevelPrintf(fs.Config.StatsLogLevel, nil, "can't close account: %+v\n", err)
@@ -110,16 +144,57 @@ func (tr *Transfer) Done(err error) {
 
 	tr.mu.Lock()
 	tr.completedAt = time.Now()
+	duration := tr.completedAt.Sub(tr.startedAt)
 	tr.mu.Unlock()
+	tr.span.End(err)
+	if !tr.checking {
+		transferLogDone(tr, bytes, duration, err)
+		manifestTransferDone(tr, err)
+		influxTransferDone(tr, bytes, waited, err)
+		eventSocketTransferDone(tr, err)
+	}
 
 	if tr.checking {
 		tr.stats.DoneChecking(tr.remote)
 	} else {
 		tr.stats.DoneTransferring(tr.remote, err == nil)
+		if err == nil {
+			statsdTransferDone(duration)
+		}
 	}
 	tr.stats.PruneTransfers()
 }
 
+// SetDst sets where the transfer is to for the --transfer-log, the
+// --trace-url, the --stats-influx and the --write-manifest.
+func (tr *Transfer) SetDst(f fs.Fs, remote string) {
+	tr.mu.Lock()
+	tr.dst = objectPath(f, remote)
+	tr.dstFs = f
+	tr.dstRemote = remote
+	tr.mu.Unlock()
+	tr.span.SetAttribute("rclone.dst", f.String())
+}
+
+// SetTries sets the number of low level tries the transfer took
+func (tr *Transfer) SetTries(tries int) {
+	tr.mu.Lock()
+	tr.tries = tries
+	tr.mu.Unlock()
+	tr.span.SetAttribute("rclone.tries", tries)
+}
+
+// SetHash records the hash of type ht of the file transferred for
+// the --transfer-log and the --write-manifest
+func (tr *Transfer) SetHash(ht hash.Type, sum string) {
+	tr.mu.Lock()
+	if tr.hashes == nil {
+		tr.hashes = make(map[string]string, 1)
+	}
+	tr.hashes[ht.String()] = sum
+	tr.mu.Unlock()
+}
+
 // Reset allows to switch the Account to another transfer method.
 func (tr *Transfer) Reset() {
 	tr.mu.RLock()
@@ -135,17 +210,80 @@ func (tr *Transfer) Reset() {
 }
 
 // Account returns reader that knows how to keep track of transfer progress.
-func (tr *Transfer) Account(in io.ReadCloser) *Account {
+//
+// The context is used to find any --bwlimit-bucket limit set with
+// WithBucket.
+func (tr *Transfer) Account(ctx context.Context, in io.ReadCloser) *Account {
 	tr.mu.Lock()
 	if tr.acc == nil {
-		tr.acc = newAccountSizeName(tr.stats, in, tr.size, tr.remote)
+		tr.acc = newAccountSizeName(ctx, tr.stats, in, tr.size, tr.remote)
 	} else {
-		tr.acc.UpdateReader(in)
+		tr.acc.UpdateReader(ctx, in)
 	}
 	tr.mu.Unlock()
 	return tr.acc
 }
 
+// WithCancel returns a copy of ctx to use for one attempt at the
+// transfer which is cancelled if Cancel is called.  The function
+// returned must be called when the attempt is finished.
+//
+// The context also has the span tracing the transfer, if any, so the
+// spans for the chunks of the attempt are children of it.
+func (tr *Transfer) WithCancel(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(WithSpan(ctx, tr.span))
+	tr.mu.Lock()
+	tr.cancel = cancel
+	tr.cancelErr = nil
+	tr.mu.Unlock()
+	return ctx, func() {
+		tr.mu.Lock()
+		tr.cancel = nil
+		tr.mu.Unlock()
+		cancel()
+	}
+}
+
+// Cancel aborts the current attempt at the transfer by cancelling
+// its context and making reads of its Account fail.
+//
+// If requeue is set the attempt fails with a retriable error so the
+// transfer is tried again, otherwise the transfer fails with
+// ErrorTransferCancelled and isn't retried.
+//
+// It returns false if there is no attempt in progress to cancel or it
+// has been cancelled already.
+func (tr *Transfer) Cancel(requeue bool) bool {
+	err := fserrors.NoRetryError(ErrorTransferCancelled)
+	if requeue {
+		err = fserrors.RetryError(ErrorTransferCancelled)
+	}
+	tr.mu.Lock()
+	cancel, acc := tr.cancel, tr.acc
+	if tr.cancelErr != nil {
+		cancel = nil
+	} else if cancel != nil {
+		tr.cancelErr = err
+	}
+	tr.mu.Unlock()
+	if cancel == nil {
+		return false
+	}
+	if acc != nil {
+		acc.Cancel(err)
+	}
+	cancel()
+	return true
+}
+
+// Cancelled returns the error the current attempt at the transfer was
+// cancelled with or nil if it wasn't cancelled.
+func (tr *Transfer) Cancelled() error {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	return tr.cancelErr
+}
+
 // TimeRange returns the time transfer started and ended at. If not completed
 // it will return zero time for end time.
 func (tr *Transfer) TimeRange() (time.Time, time.Time) {
@@ -0,0 +1,150 @@
+package accounting
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+)
+
+// Transfer log results
+const (
+	transferLogOK    = "ok"
+	transferLogError = "error"
+)
+
+// transferLogRecord is the JSON written to the --transfer-log for
+// each transfer
+type transferLogRecord struct {
+	Time     time.Time         `json:"time"`             // when the transfer finished
+	Source   string            `json:"source"`           // where the transfer was from
+	Dest     string            `json:"dest,omitempty"`   // where the transfer was to if known
+	Size     int64             `json:"size"`             // size of the file or -1 if unknown
+	Bytes    int64             `json:"bytes"`            // bytes transferred
+	Hashes   map[string]string `json:"hashes,omitempty"` // hashes of the file if checked
+	Duration float64           `json:"duration"`         // how long the transfer took in seconds
+	Result   string            `json:"result"`           // one of the transfer log results
+	Tries    int               `json:"tries"`            // number of low level tries it took
+	Error    string            `json:"error,omitempty"`  // the error if it failed
+}
+
+// transferLogger writes a record of each transfer to the
+// --transfer-log
+type transferLogger struct {
+	mu  sync.Mutex
+	out *os.File
+}
+
+// Globals
+var (
+	transferLogMu sync.Mutex      // protects transferLog
+	transferLog   *transferLogger // the open transfer log or nil
+)
+
+// objectPath returns the path of remote in f as would be given on the
+// command line
+func objectPath(f fs.Info, remote string) string {
+	configString := f.Name() + ":" + f.Root()
+	if do, ok := f.(fs.Fs); ok {
+		configString = fs.ConfigString(do)
+	}
+	if configString == "" || strings.HasSuffix(configString, ":") || strings.HasSuffix(configString, "/") {
+		return configString + remote
+	}
+	return configString + "/" + remote
+}
+
+// write the record for tr which transferred bytes in duration and
+// finished with err.
+//
+// Each record is written to the file with a single write as soon as
+// the transfer is done so none are lost if rclone is stopped.
+func (l *transferLogger) write(tr *Transfer, bytes int64, duration time.Duration, err error) {
+	record := transferLogRecord{
+		Source:   tr.remote,
+		Size:     tr.size,
+		Bytes:    bytes,
+		Duration: duration.Seconds(),
+		Result:   transferLogOK,
+	}
+	if tr.srcFs != nil {
+		record.Source = objectPath(tr.srcFs, tr.remote)
+	}
+	tr.mu.RLock()
+	record.Time = tr.completedAt
+	record.Dest = tr.dst
+	record.Tries = tr.tries
+	record.Hashes = tr.hashes
+	tr.mu.RUnlock()
+	if err != nil {
+		record.Result = transferLogError
+		record.Error = err.Error()
+	}
+	buf, jsonErr := json.Marshal(&record)
+	if jsonErr != nil {
+		fs.Errorf(tr.remote, "Failed to make transfer log record: %v", jsonErr)
+		return
+	}
+	buf = append(buf, '\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, writeErr := l.out.Write(buf)
+	if writeErr != nil {
+		fs.Errorf(tr.remote, "Failed to write transfer log record: %v", writeErr)
+	}
+}
+
+// close the transfer log making sure the records are on disk
+func (l *transferLogger) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	err := l.out.Sync()
+	closeErr := l.out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// transferLogDone writes the record for tr to the --transfer-log if
+// it is open
+func transferLogDone(tr *Transfer, bytes int64, duration time.Duration, err error) {
+	transferLogMu.Lock()
+	l := transferLog
+	transferLogMu.Unlock()
+	if l != nil {
+		l.write(tr, bytes, duration, err)
+	}
+}
+
+// StartTransferLog opens the --transfer-log if set so a JSON record
+// of each transfer, whether it succeeded or failed, is appended to
+// it as the transfer finishes.
+//
+// It returns a func which should be called to close it.
+func StartTransferLog() (func(), error) {
+	if fs.Config.TransferLog == "" {
+		return func() {}, nil
+	}
+	out, err := os.OpenFile(fs.Config.TransferLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open --transfer-log")
+	}
+	l := &transferLogger{out: out}
+	transferLogMu.Lock()
+	transferLog = l
+	transferLogMu.Unlock()
+	return func() {
+		transferLogMu.Lock()
+		transferLog = nil
+		transferLogMu.Unlock()
+		err := l.close()
+		if err != nil {
+			fs.Errorf(nil, "Failed to close --transfer-log: %v", err)
+		}
+	}, nil
+}
@@ -0,0 +1,105 @@
+package accounting
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectPath(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		root   string
+		remote string
+		want   string
+	}{
+		{"remote", "", "file", "remote:file"},
+		{"remote", "bucket", "dir/file", "remote:bucket/dir/file"},
+		{"remote", "bucket/", "file", "remote:bucket/file"},
+	} {
+		got := objectPath(mockfs.NewFs(test.name, test.root), test.remote)
+		assert.Equal(t, test.want, got, test)
+	}
+}
+
+func TestTransferLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-transfer-log")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	logPath := filepath.Join(dir, "transfers.jsonl")
+
+	oldTransferLog := fs.Config.TransferLog
+	defer func() {
+		fs.Config.TransferLog = oldTransferLog
+	}()
+	fs.Config.TransferLog = ""
+	stop, err := StartTransferLog()
+	require.NoError(t, err)
+	stop()
+
+	fs.Config.TransferLog = logPath
+	stop, err = StartTransferLog()
+	require.NoError(t, err)
+
+	s := NewStats()
+	src := mockobject.New("file").WithContent([]byte("hello"), mockobject.SeekModeNone)
+	src.SetFs(mockfs.NewFs("src", "dir"))
+	tr := s.NewTransfer(src)
+	tr.SetDst(mockfs.NewFs("dst", "backup"), "file")
+	tr.SetTries(1)
+	tr.SetHash(hash.MD5, "5d41402abc4b2a76b9719d911017c592")
+	tr.Done(nil)
+
+	tr = s.NewTransferRemoteSize("failed", 10)
+	tr.SetTries(3)
+	tr.Done(errors.New("potato"))
+
+	// Checks aren't transfers
+	tr = s.NewCheckingTransfer(src)
+	tr.Done(nil)
+	stop()
+
+	in, err := os.Open(logPath)
+	require.NoError(t, err)
+	defer func() {
+		_ = in.Close()
+	}()
+	var records []transferLogRecord
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		var record transferLogRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	require.Equal(t, 2, len(records))
+
+	assert.Equal(t, "src:dir/file", records[0].Source)
+	assert.Equal(t, "dst:backup/file", records[0].Dest)
+	assert.Equal(t, int64(5), records[0].Size)
+	assert.Equal(t, map[string]string{"MD5": "5d41402abc4b2a76b9719d911017c592"}, records[0].Hashes)
+	assert.Equal(t, transferLogOK, records[0].Result)
+	assert.Equal(t, 1, records[0].Tries)
+	assert.Equal(t, "", records[0].Error)
+	assert.False(t, records[0].Time.IsZero())
+
+	assert.Equal(t, "failed", records[1].Source)
+	assert.Equal(t, "", records[1].Dest)
+	assert.Equal(t, int64(10), records[1].Size)
+	assert.Equal(t, transferLogError, records[1].Result)
+	assert.Equal(t, 3, records[1].Tries)
+	assert.Equal(t, "potato", records[1].Error)
+}
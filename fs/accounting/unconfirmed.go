@@ -0,0 +1,100 @@
+package accounting
+
+import (
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// unconfirmed tracks the bytes read by transfers which haven't
+// finished yet so may still be buffered by the destination rather
+// than stored.  This is used to slow down reads with
+// --max-unconfirmed.
+var unconfirmed = struct {
+	mu      sync.Mutex
+	total   int64         // total unconfirmed bytes
+	holders []*Account    // accounts with unconfirmed bytes, oldest first
+	changed chan struct{} // closed and remade when total goes down
+}{
+	changed: make(chan struct{}),
+}
+
+// broadcastUnconfirmed wakes up the reads waiting for the
+// unconfirmed bytes to go down - call with unconfirmed.mu held
+func broadcastUnconfirmed() {
+	close(unconfirmed.changed)
+	unconfirmed.changed = make(chan struct{})
+}
+
+// waitUnconfirmed records that acc has read n more bytes, first
+// waiting until the total unconfirmed bytes are below
+// --max-unconfirmed.
+//
+// The account which has held unconfirmed bytes the longest never
+// waits, otherwise a transfer bigger than --max-unconfirmed could
+// never finish.  It returns whether it had to wait.
+//
+// If the context of acc is done while waiting the bytes aren't
+// recorded and its error is returned.
+func (acc *Account) waitUnconfirmed(n int) (waited bool, err error) {
+	max := int64(fs.Config.MaxUnconfirmed)
+	if max <= 0 || n <= 0 {
+		return false, nil
+	}
+	ctx := acc.ctx
+	unconfirmed.mu.Lock()
+	defer unconfirmed.mu.Unlock()
+	for unconfirmed.total >= max && unconfirmed.holders[0] != acc && !acc.cancelled() {
+		waited = true
+		changed := unconfirmed.changed
+		unconfirmed.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+		}
+		unconfirmed.mu.Lock()
+		if err = ctx.Err(); err != nil {
+			return waited, err
+		}
+	}
+	if acc.unconfirmed == 0 {
+		unconfirmed.holders = append(unconfirmed.holders, acc)
+	}
+	acc.unconfirmed += int64(n)
+	unconfirmed.total += int64(n)
+	return waited, nil
+}
+
+// releaseUnconfirmed marks the bytes read by acc as no longer
+// unconfirmed as the transfer has finished or is starting again
+func (acc *Account) releaseUnconfirmed() {
+	unconfirmed.mu.Lock()
+	defer unconfirmed.mu.Unlock()
+	if acc.unconfirmed == 0 {
+		return
+	}
+	unconfirmed.total -= acc.unconfirmed
+	acc.unconfirmed = 0
+	for i, holder := range unconfirmed.holders {
+		if holder == acc {
+			unconfirmed.holders = append(unconfirmed.holders[:i], unconfirmed.holders[i+1:]...)
+			break
+		}
+	}
+	broadcastUnconfirmed()
+}
+
+// wakeUnconfirmed wakes up a read of acc waiting for the unconfirmed
+// bytes to go down, eg because it has been cancelled
+func (acc *Account) wakeUnconfirmed() {
+	unconfirmed.mu.Lock()
+	defer unconfirmed.mu.Unlock()
+	broadcastUnconfirmed()
+}
+
+// cancelled returns true if the account has been cancelled
+func (acc *Account) cancelled() bool {
+	acc.values.mu.Lock()
+	defer acc.values.mu.Unlock()
+	return acc.values.cancel != nil
+}
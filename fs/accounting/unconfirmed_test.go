@@ -0,0 +1,106 @@
+package accounting
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountMaxUnconfirmed(t *testing.T) {
+	oldMaxUnconfirmed := fs.Config.MaxUnconfirmed
+	fs.Config.MaxUnconfirmed = 100
+	defer func() { fs.Config.MaxUnconfirmed = oldMaxUnconfirmed }()
+
+	stats := NewStats()
+	newAcc := func(name string) *Account {
+		in := ioutil.NopCloser(bytes.NewBuffer(make([]byte, 1000)))
+		return newAccountSizeName(context.Background(), stats, in, 1000, name)
+	}
+	buf := make([]byte, 60)
+	acc1 := newAcc("acc1")
+	acc2 := newAcc("acc2")
+
+	// Both can read while under the limit
+	_, err := acc1.Read(buf)
+	require.NoError(t, err)
+	_, err = acc2.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(120), unconfirmed.total)
+
+	// The oldest can carry on over the limit
+	_, err = acc1.Read(buf)
+	require.NoError(t, err)
+
+	// The other waits until the oldest has finished
+	done := make(chan struct{})
+	go func() {
+		_, err := acc2.Read(buf)
+		assert.NoError(t, err)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("read didn't wait for the unconfirmed bytes")
+	case <-time.After(50 * time.Millisecond):
+	}
+	acc1.Done()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("read didn't carry on after the unconfirmed bytes went down")
+	}
+	assert.Equal(t, int64(120), unconfirmed.total)
+
+	// Cancelling wakes up a waiting read
+	acc3 := newAcc("acc3")
+	done = make(chan struct{})
+	go func() {
+		_, _ = acc3.Read(buf)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("read didn't wait for the unconfirmed bytes")
+	case <-time.After(50 * time.Millisecond):
+	}
+	acc3.Cancel(ErrorTransferCancelled)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("read didn't carry on after being cancelled")
+	}
+	_, err = acc3.Read(buf)
+	assert.Equal(t, ErrorTransferCancelled, err)
+
+	// Cancelling the context of a waiting read stops it with the
+	// error without counting the bytes
+	ctx, cancel := context.WithCancel(context.Background())
+	in := ioutil.NopCloser(bytes.NewBuffer(make([]byte, 1000)))
+	acc4 := newAccountSizeName(ctx, stats, in, 1000, "acc4")
+	errs := make(chan error)
+	go func() {
+		_, err := acc4.Read(buf)
+		errs <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-errs:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("read didn't stop when its context was cancelled")
+	}
+	assert.Equal(t, int64(0), acc4.unconfirmed)
+
+	acc2.Done()
+	acc3.Done()
+	acc4.Done()
+	assert.Equal(t, int64(0), unconfirmed.total)
+	assert.Equal(t, 0, len(unconfirmed.holders))
+}
@@ -0,0 +1,88 @@
+package fs
+
+import (
+	"sort"
+	"strings"
+)
+
+// Grantees of an ACLGrant which mean the same on any backend.
+//
+// Other grantees are prefixed with the way the backend identifies
+// them, eg "id:" or "email:", and only mean the same between remotes
+// which share the accounts.
+const (
+	ACLGranteeOwner              = "owner"               // the owner of the object
+	ACLGranteeAllUsers           = "all-users"           // anyone, signed in or not
+	ACLGranteeAuthenticatedUsers = "authenticated-users" // anyone signed in to the provider
+)
+
+// Permissions of an ACLGrant
+const (
+	ACLPermissionRead        = "read"         // read the object
+	ACLPermissionWrite       = "write"        // write the object
+	ACLPermissionReadACL     = "read-acl"     // read the ACL of the object
+	ACLPermissionWriteACL    = "write-acl"    // write the ACL of the object
+	ACLPermissionFullControl = "full-control" // all of the above
+)
+
+// ACLGrant gives a grantee a permission on an object
+type ACLGrant struct {
+	Grantee    string `json:"grantee"`
+	Permission string `json:"permission"`
+}
+
+// String turns an ACLGrant into a string
+func (g ACLGrant) String() string {
+	return g.Grantee + "=" + g.Permission
+}
+
+// Portable returns true if the grantee of g means the same on any
+// backend
+func (g ACLGrant) Portable() bool {
+	switch g.Grantee {
+	case ACLGranteeOwner, ACLGranteeAllUsers, ACLGranteeAuthenticatedUsers:
+		return true
+	}
+	return false
+}
+
+// ACL is the access control list of an object in a form which can be
+// copied between backends
+type ACL []ACLGrant
+
+// sorted returns a sorted copy of acl
+func (acl ACL) sorted() ACL {
+	out := append(ACL(nil), acl...)
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].String() < out[j].String()
+	})
+	return out
+}
+
+// String turns an ACL into a comma separated list of sorted grants
+func (acl ACL) String() string {
+	grants := make([]string, len(acl))
+	for i, g := range acl.sorted() {
+		grants[i] = g.String()
+	}
+	return strings.Join(grants, ",")
+}
+
+// Equal returns true if acl and other have the same grants in any
+// order
+func (acl ACL) Equal(other ACL) bool {
+	return acl.String() == other.String()
+}
+
+// Portable splits acl into the grants which mean the same on any
+// backend and those which don't
+func (acl ACL) Portable() (portable, other ACL) {
+	for _, g := range acl {
+		if g.Portable() {
+			portable = append(portable, g)
+		} else {
+			other = append(other, g)
+		}
+	}
+	return portable, other
+}
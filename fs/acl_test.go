@@ -0,0 +1,27 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestACL(t *testing.T) {
+	acl := ACL{
+		{Grantee: ACLGranteeOwner, Permission: ACLPermissionFullControl},
+		{Grantee: "id:potato", Permission: ACLPermissionRead},
+		{Grantee: ACLGranteeAllUsers, Permission: ACLPermissionRead},
+	}
+	assert.Equal(t, "all-users=read,id:potato=read,owner=full-control", acl.String())
+
+	// The order of the grants doesn't matter
+	reordered := ACL{acl[2], acl[0], acl[1]}
+	assert.True(t, acl.Equal(reordered))
+	assert.Equal(t, ACLGranteeOwner, acl[0].Grantee, "sorting mustn't change the ACL")
+	assert.False(t, acl.Equal(acl[:2]))
+	assert.True(t, ACL(nil).Equal(ACL{}))
+
+	portable, other := acl.Portable()
+	assert.Equal(t, ACL{acl[0], acl[2]}, portable)
+	assert.Equal(t, ACL{acl[1]}, other)
+}
@@ -0,0 +1,50 @@
+package fs
+
+import (
+	"sync"
+	"time"
+)
+
+// BackendFeatures is what a backend was found to support when a
+// remote of it was made
+type BackendFeatures struct {
+	Features  map[string]bool `json:"features"`  // optional features and whether they are available
+	Hashes    []string        `json:"hashes"`    // names of the hashes supported
+	Precision time.Duration   `json:"precision"` // precision of the modification times in ns
+}
+
+// backendFeatures holds the features of the last remote made of each
+// backend keyed by backend name
+var backendFeatures = struct {
+	mu sync.Mutex
+	m  map[string]*BackendFeatures
+}{
+	m: map[string]*BackendFeatures{},
+}
+
+// rememberBackendFeatures records the features of f, a remote of the
+// backend called name
+func rememberBackendFeatures(name string, f Fs) {
+	bf := &BackendFeatures{
+		Features:  f.Features().Enabled(),
+		Hashes:    []string{},
+		Precision: f.Precision(),
+	}
+	for _, hashType := range f.Hashes().Array() {
+		bf.Hashes = append(bf.Hashes, hashType.String())
+	}
+	backendFeatures.mu.Lock()
+	backendFeatures.m[name] = bf
+	backendFeatures.mu.Unlock()
+}
+
+// GetBackendFeatures returns the features of the backend called name
+// or nil if no remote of it has been made yet.
+//
+// As the features of some backends depend on their config these are
+// the features of the last remote of the backend which was made.
+func GetBackendFeatures(name string) *BackendFeatures {
+	backendFeatures.mu.Lock()
+	defer backendFeatures.mu.Unlock()
+	return backendFeatures.m[name]
+}
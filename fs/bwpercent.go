@@ -0,0 +1,138 @@
+package fs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// BwPercentSlot is a percentage of the measured bandwidth to use from
+// a point in the week.
+//
+// A Percent of -1 means don't limit the bandwidth by it.
+type BwPercentSlot struct {
+	DayOfTheWeek int
+	HHMM         int
+	Percent      int
+}
+
+// BwPercentTimetable contains the time slots of percentages of the
+// measured bandwidth.
+type BwPercentTimetable []BwPercentSlot
+
+// percentString returns percent as it is written in the timetable
+func percentString(percent int) string {
+	if percent < 0 {
+		return "off"
+	}
+	return fmt.Sprintf("%d%%", percent)
+}
+
+// String returns a printable representation of BwPercentTimetable.
+func (x BwPercentTimetable) String() string {
+	ret := []string{}
+	for _, ts := range x {
+		ret = append(ret, fmt.Sprintf("%s-%04.4d,%s", time.Weekday(ts.DayOfTheWeek), ts.HHMM, percentString(ts.Percent)))
+	}
+	return strings.Join(ret, " ")
+}
+
+// parsePercent parses a percentage from 1% to 100% or "off"
+func parsePercent(s string) (int, error) {
+	if strings.ToLower(s) == "off" {
+		return -1, nil
+	}
+	if !strings.HasSuffix(s, "%") {
+		return 0, errors.Errorf("percentage must end in %%: %q", s)
+	}
+	percent, err := strconv.Atoi(strings.TrimSuffix(s, "%"))
+	if err != nil {
+		return 0, errors.Errorf("invalid percentage %q: %v", s, err)
+	}
+	if percent < 1 || percent > 100 {
+		return 0, errors.Errorf("percentage must be between 1%% and 100%%: %q", s)
+	}
+	return percent, nil
+}
+
+// table returns x as a BwTimetable with the percentages in place of
+// the bandwidths so the time slots can be found in the same way.
+func (x BwPercentTimetable) table() BwTimetable {
+	timetable := make(BwTimetable, 0, len(x))
+	for _, ts := range x {
+		timetable = append(timetable, BwTimeSlot{
+			DayOfTheWeek: ts.DayOfTheWeek,
+			HHMM:         ts.HHMM,
+			Bandwidth:    SizeSuffix(ts.Percent),
+		})
+	}
+	return timetable
+}
+
+// Set the percentage timetable.
+func (x *BwPercentTimetable) Set(s string) error {
+	// The timetable is formatted as for --bwlimit but with
+	// percentages in place of the bandwidths ex: "Mon-10:00,50% 18:00,100% 23:00,off"
+	// If only a single percentage is provided, it is used all the time.
+	if len(s) == 0 {
+		return errors.New("empty string")
+	}
+	// Parse the percentages then the times as a BwTimetable
+	tokens := strings.Split(s, " ")
+	for i, tok := range tokens {
+		tv := strings.Split(tok, ",")
+		percent, err := parsePercent(tv[len(tv)-1])
+		if err != nil {
+			return err
+		}
+		if percent < 0 {
+			tv[len(tv)-1] = "off"
+		} else {
+			tv[len(tv)-1] = fmt.Sprintf("%db", percent)
+		}
+		tokens[i] = strings.Join(tv, ",")
+	}
+	var timetable BwTimetable
+	err := timetable.Set(strings.Join(tokens, " "))
+	if err != nil {
+		return err
+	}
+	percents := make(BwPercentTimetable, 0, len(timetable))
+	for _, ts := range timetable {
+		percent := int(ts.Bandwidth)
+		if ts.Bandwidth < 0 {
+			percent = -1
+		}
+		percents = append(percents, BwPercentSlot{
+			DayOfTheWeek: ts.DayOfTheWeek,
+			HHMM:         ts.HHMM,
+			Percent:      percent,
+		})
+	}
+	*x = percents
+	return nil
+}
+
+// LimitAt returns the BwPercentSlot in force at the time requested.
+//
+// If the timetable is empty it returns a slot with Percent -1.
+func (x BwPercentTimetable) LimitAt(tt time.Time) BwPercentSlot {
+	ts := x.table().LimitAt(tt)
+	percent := int(ts.Bandwidth)
+	if ts.Bandwidth < 0 {
+		percent = -1
+	}
+	return BwPercentSlot{
+		DayOfTheWeek: ts.DayOfTheWeek,
+		HHMM:         ts.HHMM,
+		Percent:      percent,
+	}
+}
+
+// Type of the value
+func (x BwPercentTimetable) Type() string {
+	return "BwPercentTimetable"
+}
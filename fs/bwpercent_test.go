@@ -0,0 +1,82 @@
+package fs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Check it satisfies the interface
+var _ pflag.Value = (*BwPercentTimetable)(nil)
+
+func TestBwPercentTimetableSet(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want BwPercentTimetable
+		err  bool
+	}{
+		{"", nil, true},
+		{"50", nil, true},
+		{"0%", nil, true},
+		{"101%", nil, true},
+		{"bad%", nil, true},
+		{"10:00,1M", nil, true},
+		{"25:00,50%", nil, true},
+		{"50%", BwPercentTimetable{{DayOfTheWeek: 0, HHMM: 0, Percent: 50}}, false},
+		{"OFF", BwPercentTimetable{{DayOfTheWeek: 0, HHMM: 0, Percent: -1}}, false},
+		{
+			"Mon-10:00,20% Tue-18:30,100% Sun-23:00,off",
+			BwPercentTimetable{
+				{DayOfTheWeek: 1, HHMM: 1000, Percent: 20},
+				{DayOfTheWeek: 2, HHMM: 1830, Percent: 100},
+				{DayOfTheWeek: 0, HHMM: 2300, Percent: -1},
+			},
+			false,
+		},
+	} {
+		var tt BwPercentTimetable
+		err := tt.Set(test.in)
+		if test.err {
+			require.Error(t, err, test.in)
+		} else {
+			require.NoError(t, err, test.in)
+		}
+		assert.Equal(t, test.want, tt, test.in)
+	}
+
+	// Times without a day apply to every day
+	var tt BwPercentTimetable
+	require.NoError(t, tt.Set("08:00,30% 18:00,off"))
+	assert.Len(t, tt, 14)
+}
+
+func TestBwPercentTimetableString(t *testing.T) {
+	assert.Equal(t, "", BwPercentTimetable{}.String())
+	tt := BwPercentTimetable{
+		{DayOfTheWeek: 1, HHMM: 1000, Percent: 20},
+		{DayOfTheWeek: 0, HHMM: 2300, Percent: -1},
+	}
+	assert.Equal(t, "Monday-1000,20% Sunday-2300,off", tt.String())
+}
+
+func TestBwPercentTimetableLimitAt(t *testing.T) {
+	assert.Equal(t, -1, BwPercentTimetable{}.LimitAt(time.Now()).Percent)
+
+	var tt BwPercentTimetable
+	require.NoError(t, tt.Set("Mon-10:00,20% Mon-18:00,80% Fri-23:00,off"))
+	for _, test := range []struct {
+		at   time.Time
+		want int
+	}{
+		{time.Date(2017, time.April, 17, 9, 59, 0, 0, time.UTC), -1}, // Monday before the first slot wraps around
+		{time.Date(2017, time.April, 17, 10, 0, 0, 0, time.UTC), 20},
+		{time.Date(2017, time.April, 17, 20, 0, 0, 0, time.UTC), 80},
+		{time.Date(2017, time.April, 20, 12, 0, 0, 0, time.UTC), 80},
+		{time.Date(2017, time.April, 21, 23, 30, 0, 0, time.UTC), -1},
+	} {
+		assert.Equal(t, test.want, tt.LimitAt(test.at).Percent, test.at.String())
+	}
+}
@@ -0,0 +1,86 @@
+package fs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// BwRelativeSlot is a bandwidth limit which starts at an offset from
+// the start of the run.
+type BwRelativeSlot struct {
+	Offset    time.Duration
+	Bandwidth SizeSuffix
+}
+
+// BwRelativeTimetable contains the bandwidth limits to use relative
+// to the start of the run sorted by offset.
+type BwRelativeTimetable []BwRelativeSlot
+
+// String returns a printable representation of BwRelativeTimetable.
+func (x BwRelativeTimetable) String() string {
+	ret := []string{}
+	for _, ts := range x {
+		ret = append(ret, fmt.Sprintf("%v,%s", ts.Offset, ts.Bandwidth.String()))
+	}
+	return strings.Join(ret, " ")
+}
+
+// Set the bandwidth timetable from a string.
+func (x *BwRelativeTimetable) Set(s string) error {
+	// The timetable is formatted as:
+	// "offset,bandwidth offset,bandwidth..." ex: "0,off 10m,2M 1h,512k"
+	if len(s) == 0 {
+		return errors.New("empty string")
+	}
+	var timetable BwRelativeTimetable
+	for _, tok := range strings.Split(s, " ") {
+		tv := strings.Split(tok, ",")
+		if len(tv) != 2 {
+			return errors.Errorf("invalid offset/bandwidth specification: %q", tok)
+		}
+		offset, err := ParseDuration(tv[0])
+		if err != nil {
+			return errors.Wrapf(err, "invalid offset %q", tv[0])
+		}
+		if offset < 0 {
+			return errors.Errorf("offset can't be negative: %q", tv[0])
+		}
+		ts := BwRelativeSlot{Offset: offset}
+		if err := ts.Bandwidth.Set(tv[1]); err != nil {
+			return err
+		}
+		timetable = append(timetable, ts)
+	}
+	sort.SliceStable(timetable, func(i, j int) bool {
+		return timetable[i].Offset < timetable[j].Offset
+	})
+	for i := 1; i < len(timetable); i++ {
+		if timetable[i].Offset == timetable[i-1].Offset {
+			return errors.Errorf("offset %v given more than once", timetable[i].Offset)
+		}
+	}
+	*x = timetable
+	return nil
+}
+
+// LimitAt returns the slot in force at elapsed since the start of the
+// run.
+//
+// It returns false if elapsed is before the first slot.
+func (x BwRelativeTimetable) LimitAt(elapsed time.Duration) (BwRelativeSlot, bool) {
+	for i := len(x) - 1; i >= 0; i-- {
+		if x[i].Offset <= elapsed {
+			return x[i], true
+		}
+	}
+	return BwRelativeSlot{}, false
+}
+
+// Type of the value
+func (x BwRelativeTimetable) Type() string {
+	return "BwRelativeTimetable"
+}
@@ -0,0 +1,76 @@
+package fs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Check it satisfies the interface
+var _ pflag.Value = (*BwRelativeTimetable)(nil)
+
+func TestBwRelativeTimetableSet(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want BwRelativeTimetable
+		err  bool
+	}{
+		{"", nil, true},
+		{"10m", nil, true},
+		{"bad,1M", nil, true},
+		{"10m,bad", nil, true},
+		{"-1m,1M", nil, true},
+		{"10m,1M 10m,2M", nil, true},
+		{"0,off", BwRelativeTimetable{{Offset: 0, Bandwidth: -1}}, false},
+		{
+			"1h,512k 0,off 10m,2M",
+			BwRelativeTimetable{
+				{Offset: 0, Bandwidth: -1},
+				{Offset: 10 * time.Minute, Bandwidth: 2 * 1024 * 1024},
+				{Offset: time.Hour, Bandwidth: 512 * 1024},
+			},
+			false,
+		},
+	} {
+		var tt BwRelativeTimetable
+		err := tt.Set(test.in)
+		if test.err {
+			require.Error(t, err, test.in)
+		} else {
+			require.NoError(t, err, test.in)
+		}
+		assert.Equal(t, test.want, tt, test.in)
+	}
+}
+
+func TestBwRelativeTimetableString(t *testing.T) {
+	assert.Equal(t, "", BwRelativeTimetable{}.String())
+	tt := BwRelativeTimetable{
+		{Offset: 0, Bandwidth: -1},
+		{Offset: 10 * time.Minute, Bandwidth: 2 * 1024 * 1024},
+	}
+	assert.Equal(t, "0s,off 10m0s,2M", tt.String())
+}
+
+func TestBwRelativeTimetableLimitAt(t *testing.T) {
+	tt := BwRelativeTimetable{
+		{Offset: 5 * time.Minute, Bandwidth: -1},
+		{Offset: 10 * time.Minute, Bandwidth: 2 * 1024 * 1024},
+	}
+	_, ok := tt.LimitAt(time.Minute)
+	assert.False(t, ok)
+	ts, ok := tt.LimitAt(5 * time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, SizeSuffix(-1), ts.Bandwidth)
+	ts, ok = tt.LimitAt(9 * time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, SizeSuffix(-1), ts.Bandwidth)
+	ts, ok = tt.LimitAt(time.Hour)
+	assert.True(t, ok)
+	assert.Equal(t, SizeSuffix(2*1024*1024), ts.Bandwidth)
+	_, ok = BwRelativeTimetable{}.LimitAt(time.Hour)
+	assert.False(t, ok)
+}
@@ -2,6 +2,7 @@ package fs
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -208,6 +209,32 @@ func (x BwTimetable) LimitAt(tt time.Time) BwTimeSlot {
 	return ret
 }
 
+// NextChange returns the first time after tt when the timetable
+// changes the bandwidth away from bandwidth along with the time slot
+// which starts then.
+//
+// It returns false if the timetable never changes from bandwidth.
+func (x BwTimetable) NextChange(tt time.Time, bandwidth SizeSuffix) (time.Time, BwTimeSlot, bool) {
+	starts := make([]time.Time, 0, len(x))
+	for _, ts := range x {
+		days := (ts.DayOfTheWeek - int(tt.Weekday()) + 7) % 7
+		start := time.Date(tt.Year(), tt.Month(), tt.Day()+days, ts.HHMM/100, ts.HHMM%100, 0, 0, tt.Location())
+		if !start.After(tt) {
+			start = start.AddDate(0, 0, 7)
+		}
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i].Before(starts[j])
+	})
+	for _, start := range starts {
+		if ts := x.LimitAt(start); ts.Bandwidth != bandwidth {
+			return start, ts, true
+		}
+	}
+	return time.Time{}, BwTimeSlot{}, false
+}
+
 // Type of the value
 func (x BwTimetable) Type() string {
 	return "BwTimetable"
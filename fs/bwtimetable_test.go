@@ -327,3 +327,33 @@ func TestBwTimetableLimitAt(t *testing.T) {
 		assert.Equal(t, test.want, slot)
 	}
 }
+
+func TestBwTimetableNextChange(t *testing.T) {
+	var tt BwTimetable
+	require.NoError(t, tt.Set("11:00,333k 13:00,666k Sat-00:00,off"))
+	// Thursday
+	now := time.Date(2017, time.April, 20, 12, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		now       time.Time
+		bandwidth SizeSuffix
+		want      time.Time
+		wantBw    SizeSuffix
+	}{
+		{now, 333 * 1024, time.Date(2017, time.April, 20, 13, 0, 0, 0, time.UTC), 666 * 1024},
+		{now, 666 * 1024, time.Date(2017, time.April, 21, 11, 0, 0, 0, time.UTC), 333 * 1024},
+		{now.Add(time.Hour), 333 * 1024, time.Date(2017, time.April, 20, 13, 0, 0, 0, time.UTC).Add(24 * time.Hour), 666 * 1024},
+		{now.Add(2 * time.Hour), 666 * 1024, time.Date(2017, time.April, 21, 11, 0, 0, 0, time.UTC), 333 * 1024},
+		// Friday evening the next change is to off
+		{now.Add(30 * time.Hour), 666 * 1024, time.Date(2017, time.April, 22, 0, 0, 0, 0, time.UTC), -1},
+	} {
+		at, ts, ok := tt.NextChange(test.now, test.bandwidth)
+		require.True(t, ok, test.now)
+		assert.Equal(t, test.want, at, test.now)
+		assert.Equal(t, test.wantBw, ts.Bandwidth, test.now)
+	}
+
+	// A single bandwidth never changes
+	require.NoError(t, tt.Set("1M"))
+	_, _, ok := tt.NextChange(now, 1024*1024)
+	assert.False(t, ok)
+}
@@ -0,0 +1,26 @@
+package fs
+
+import (
+	"sync"
+
+	"github.com/rclone/rclone/lib/pacer"
+)
+
+var (
+	chunkSchedulerOnce sync.Once
+	chunkScheduler     *pacer.ChunkScheduler
+)
+
+// NewChunkUpload starts a multipart upload which uploads up to
+// concurrency chunks at once.
+//
+// If --multipart-chunks is set the chunks are also limited to that
+// many at once across all the multipart uploads in progress.
+func NewChunkUpload(concurrency int) *pacer.ChunkUpload {
+	chunkSchedulerOnce.Do(func() {
+		if Config.MultipartChunks > 0 {
+			chunkScheduler = pacer.NewChunkScheduler(Config.MultipartChunks)
+		}
+	})
+	return chunkScheduler.NewUpload(concurrency)
+}
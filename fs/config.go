@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs/hash"
 )
 
 // Global
@@ -53,24 +54,40 @@ type ConfigInfo struct {
 	ModifyWindow           time.Duration
 	Checkers               int
 	Transfers              int
+	TransfersLarge         int           // if set run transfers of files of --size-threshold or more separately, this many at once
+	SizeThreshold          SizeSuffix    // files at least this big are large for --transfers-large
+	MkdirConcurrency       int           // max number of directories to make at once
+	TransferStartRate      float64       // max number of transfers to start per second
 	ConnectTimeout         time.Duration // Connect timeout
+	ConsistencyWindow      time.Duration // time uploads may take to be visible on eventually consistent remotes
 	Timeout                time.Duration // Data channel timeout
 	ExpectContinueTimeout  time.Duration
 	Dump                   DumpFlags
 	InsecureSkipVerify     bool // Skip server certificate verification
 	DeleteMode             DeleteMode
 	MaxDelete              int64
+	MaxDeleteFraction      float64 // max fraction of the destination files to delete without --force
+	Force                  bool    // do deletes over --max-delete-fraction without asking
+	Deleters               int
+	DeleteTPSLimit         float64
+	DeleteDeepestFirst     bool
 	TrackRenames           bool   // Track file renames.
 	TrackRenamesStrategy   string // Comma separated list of stratgies used to track renames
+	DetectMoves            bool   // Use server side moves for renames found by hash on the same backend
 	LowLevelRetries        int
+	RetryDuration          time.Duration
+	NoUnsafeRetries        bool // Don't retry non idempotent operations on HTTP 5xx errors
 	UpdateOlder            bool // Skip files that are newer on the destination
 	NoGzip                 bool // Disable compression
 	MaxDepth               int
 	IgnoreSize             bool
 	IgnoreChecksum         bool
+	HashPreference         hash.TypeList // order to choose the hash to compare files with
 	IgnoreCaseSync         bool
 	NoTraverse             bool
+	ProbeDest              bool // check each source file on the destination instead of listing it
 	CheckFirst             bool
+	CheckFreeInodes        bool
 	NoCheckDest            bool
 	NoUnicodeNormalization bool
 	NoUpdateModTime        bool
@@ -83,9 +100,28 @@ type ConfigInfo struct {
 	UseListR               bool
 	BufferSize             SizeSuffix
 	BwLimit                BwTimetable
+	BwLimitBucket          map[string]SizeSuffix // bandwidth limits for individual destination buckets
+	BwLimitBind            map[string]SizeSuffix // bandwidth limits for the transfers bound to each local address
+	BwLimitBudget          time.Duration         // time per day to transfer at the full bandwidth limit
+	BwLimitBudgetRate      SizeSuffix            // bandwidth limit once the --bwlimit-budget is used up
+	BwLimitAfter           SizeSuffix            // don't limit the bandwidth until this much has been transferred
+	BwLimitAfterPeriod     time.Duration         // count the --bwlimit-after again from 0 this often
+	BwLimitStartTokens     SizeSuffix            // don't start a transfer until the --bwlimit has this many tokens available
+	BwLimitRelative        BwRelativeTimetable   // bandwidth limits from offsets after the start of the run overriding the --bwlimit
+	BwLimitSource          string                // file to read the bandwidth limit from, eg in shared memory
+	BwLimitSourceInterval  time.Duration         // how often to read the --bwlimit-source
+	BwLimitPercent         BwPercentTimetable    // timetable of percentages of the measured bandwidth to limit to
+	BwLimitPercentProbe    string                // URL to download to measure the bandwidth for --bwlimit-percent
+	BwLimitPercentInterval time.Duration         // how often to measure the bandwidth for --bwlimit-percent
+	MaxUnconfirmed         SizeSuffix            // slow reads when transfers in progress have read more than this
+	PlannerMemoryLimit     SizeSuffix            // abort if the sync planner would hold more listings than this
+	PauseWhileRunning      []string              // pause the transfers while any of these programs are running
+	ConcurrencyGroup       map[string]int        // max number of operations to run at once on the remotes in each concurrency group
 	TPSLimit               float64
 	TPSLimitBurst          int
+	ListRate               float64 // max listing calls per second
 	BindAddr               net.IP
+	BindRemote             map[string]net.IP // local addresses to bind to for the transfers of individual remotes
 	DisableFeatures        []string
 	UserAgent              string
 	Immutable              bool
@@ -97,13 +133,44 @@ type ConfigInfo struct {
 	UseServerModTime       bool
 	MaxTransfer            SizeSuffix
 	MaxDuration            time.Duration
+	FailFast               bool
+	MinTransferSpeed       SizeSuffix
+	MinTransferSpeedWindow time.Duration
+	MinTransferRate        SizeSuffix // bandwidth guaranteed to each transfer within the --bwlimit
 	CutoffMode             CutoffMode
+	PartialCleanup         PartialCleanup
+	ModTimeFallback        ModTimeFallback
+	DirMarkers             DirMarkers         // what to do with directory marker objects
+	IllegalChars           IllegalChars       // what to do with names which have characters illegal on the destination
+	OnSourceChange         SourceChange       // what to do when a file changes on the source while being transferred
+	OnTruncatedRead        TruncatedRead      // what to do when a source file is truncated while being read
+	OnOversize             Oversize           // what to do with files bigger than the destination can store
+	MaxObjectSize          SizeSuffix         // the largest object the destination can store overriding the backend's limit
+	OnHashMismatch         HashMismatch       // what to do when the hash the destination computed on upload doesn't match
+	VerifyUpload           bool               // read the size and hash of each upload back with NewObject to check it was stored correctly
+	VerifyHashMismatch     VerifyHashMismatch // extra check to make when the sizes match but the hashes differ
+	IllegalCharsMap        map[rune]string    // substitutes for illegal characters with --illegal-chars substitute
+	IllegalCharsManifest   string             // file to record names changed by --illegal-chars in
+	PostFileCmd            SpaceSepList       // command to run on each transferred file
+	PostFileCmdConcurrency int                // max number of --post-file-cmd to run at once
+	PostFileCmdError       HookErrorMode      // what to do if the --post-file-cmd fails
 	MaxBacklog             int
 	MaxStatsGroups         int
 	StatsOneLine           bool
-	StatsOneLineDate       bool   // If we want a date prefix at all
-	StatsOneLineDateFormat string // If we want to customize the prefix
-	ErrorOnNoTransfer      bool   // Set appropriate exit code if no files transferred
+	StatsPercentiles       bool          // Sample the speed every second to report its percentiles
+	StatsOneLineDate       bool          // If we want a date prefix at all
+	StatsOneLineDateFormat string        // If we want to customize the prefix
+	ErrorOnNoTransfer      bool          // Set appropriate exit code if no files transferred
+	StatsdAddr             string        // host:port of the StatsD server to send the stats to
+	StatsdPrefix           string        // prefix for the StatsD metric names
+	StatsdInterval         time.Duration // how often to send the stats to StatsD
+	StatsInflux            string        // URL to send the stats to in the InfluxDB line protocol
+	HeartbeatURL           string        // URL to post heartbeats to
+	HeartbeatInterval      time.Duration // how often to post heartbeats
+	TraceURL               string        // OTLP/HTTP endpoint to send the transfer traces to
+	TransferLog            string        // append a JSON record of each transfer to this file
+	WriteManifest          string        // write a manifest of the files transferred to this file
+	EventSocket            string        // Unix socket to stream the transfer events to as JSON lines
 	Progress               bool
 	Cookie                 bool
 	UseMmap                bool
@@ -112,12 +179,31 @@ type ConfigInfo struct {
 	ClientKey              string // Client Side Key
 	MultiThreadCutoff      SizeSuffix
 	MultiThreadStreams     int
-	MultiThreadSet         bool   // whether MultiThreadStreams was set (set in fs/config/configflags)
-	OrderBy                string // instructions on how to order the transfer
+	MultiThreadSet         bool     // whether MultiThreadStreams was set (set in fs/config/configflags)
+	MultipartChunks        int      // max number of multipart upload chunks to upload at once
+	MaxCPU                 int      // max number of CPU intensive hashing/encryption operations at once
+	MaxOpenFiles           int      // max number of file handles to have open at once
+	OrderBy                string   // instructions on how to order the transfer
+	PlanOut                string   // write the actions to this file instead of doing them
+	PlanIn                 string   // do the actions in this file instead of a sync
+	PlanForce              bool     // run the --plan-in file even if it is stale
+	ChangesFile            string   // keep the source change feed token in this file to only sync changes
+	CAS                    bool     // store files on the destination by the hash of their contents
+	CASManifest            string   // name of the manifest of the --cas destination
+	AtTime                 Time     // copy the versions of the source objects current at this time
+	ShareReads             bool     // share reads of a source object between transfers of it at once
+	LinkDuplicates         LinkMode // link transfers of the same contents to the first instead of copying
+	DirShardThreshold      int      // split directories with more entries than this into shards
+	ResumeFilter           string   // keep a bloom filter of the completed files in this file to resume the sync
+	MoveDeleteAfter        bool     // only delete the sources of a move once all the transfers have succeeded
+	ResumeFilterItems      int64    // number of files to size the --resume-filter for
+	ResumeFilterErrorRate  float64  // false positive rate of the --resume-filter
 	UploadHeaders          []*HTTPOption
 	DownloadHeaders        []*HTTPOption
 	Headers                []*HTTPOption
 	RefreshTimes           bool
+	DeferModTime           bool // set the modification times in a batch after the transfers
+	SyncMetadata           bool // copy the metadata both backends support, currently the ACLs
 }
 
 // NewConfig creates a new config with everything set to the default
@@ -131,24 +217,41 @@ func NewConfig() *ConfigInfo {
 	c.StatsLogLevel = LogLevelInfo
 	c.ModifyWindow = time.Nanosecond
 	c.Checkers = 8
+	c.DirShardThreshold = 100000
 	c.Transfers = 4
+	c.SizeThreshold = SizeSuffix(GibiByte)
+	c.MkdirConcurrency = 1
 	c.ConnectTimeout = 60 * time.Second
 	c.Timeout = 5 * 60 * time.Second
 	c.ExpectContinueTimeout = 1 * time.Second
 	c.DeleteMode = DeleteModeDefault
+	c.PartialCleanup = PartialCleanupDefault
 	c.MaxDelete = -1
 	c.LowLevelRetries = 10
 	c.MaxDepth = -1
 	c.DataRateUnit = "bytes"
 	c.BufferSize = SizeSuffix(16 << 20)
+	c.BwLimitBudgetRate = SizeSuffix(100 * 1024)
+	c.BwLimitSourceInterval = 100 * time.Millisecond
+	c.BwLimitPercentInterval = 15 * time.Minute
+	c.MaxObjectSize = -1
 	c.UserAgent = "rclone/" + Version
 	c.StreamingUploadCutoff = SizeSuffix(100 * 1024)
 	c.MaxStatsGroups = 1000
+	c.StatsdPrefix = "rclone"
+	c.StatsdInterval = 10 * time.Second
+	c.HeartbeatInterval = time.Minute
 	c.StatsFileNameLength = 45
 	c.AskPassword = true
 	c.TPSLimitBurst = 1
 	c.MaxTransfer = -1
+	c.MinTransferSpeed = -1
+	c.MinTransferSpeedWindow = 60 * time.Second
 	c.MaxBacklog = 10000
+	c.PostFileCmdConcurrency = 1
+	c.CASManifest = "manifest.json"
+	c.ResumeFilterItems = 1000000
+	c.ResumeFilterErrorRate = 0.001
 	// We do not want to set the default here. We use this variable being empty as part of the fall-through of options.
 	//	c.StatsOneLineDateFormat = "2006/01/02 15:04:05 - "
 	c.MultiThreadCutoff = SizeSuffix(250 * 1024 * 1024)
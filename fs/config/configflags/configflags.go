@@ -7,13 +7,18 @@ import (
 	"log"
 	"net"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config"
 	"github.com/rclone/rclone/fs/config/flags"
 	fsLog "github.com/rclone/rclone/fs/log"
 	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/lib/concurrencygroup"
+	"github.com/rclone/rclone/lib/cpulimit"
+	"github.com/rclone/rclone/lib/openfiles"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 )
@@ -30,6 +35,11 @@ var (
 	bindAddr        string
 	disableFeatures string
 	uploadHeaders   []string
+	bwLimitBuckets  []string
+	bindRemotes     []string
+	bwLimitBinds    []string
+	groupLimits     []string
+	illegalCharsMap []string
 	downloadHeaders []string
 	headers         []string
 )
@@ -43,6 +53,10 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.DurationVarP(flagSet, &fs.Config.ModifyWindow, "modify-window", "", fs.Config.ModifyWindow, "Max time diff to be considered the same")
 	flags.IntVarP(flagSet, &fs.Config.Checkers, "checkers", "", fs.Config.Checkers, "Number of checkers to run in parallel.")
 	flags.IntVarP(flagSet, &fs.Config.Transfers, "transfers", "", fs.Config.Transfers, "Number of file transfers to run in parallel.")
+	flags.IntVarP(flagSet, &fs.Config.TransfersLarge, "transfers-large", "", fs.Config.TransfersLarge, "Number of transfers of files of --size-threshold or more to run in parallel, separately from --transfers.")
+	flags.FVarP(flagSet, &fs.Config.SizeThreshold, "size-threshold", "", "Files at least this big are transferred by the --transfers-large transfers.")
+	flags.Float64VarP(flagSet, &fs.Config.TransferStartRate, "transfer-start-rate", "", fs.Config.TransferStartRate, "Limit the number of transfers started per second to this.")
+	flags.IntVarP(flagSet, &fs.Config.MkdirConcurrency, "mkdir-concurrency", "", fs.Config.MkdirConcurrency, "Number of directories to make in parallel.")
 	flags.StringVarP(flagSet, &config.ConfigPath, "config", "", config.ConfigPath, "Config file.")
 	flags.StringVarP(flagSet, &config.CacheDir, "cache-dir", "", config.CacheDir, "Directory rclone will use for caching.")
 	flags.BoolVarP(flagSet, &fs.Config.CheckSum, "checksum", "c", fs.Config.CheckSum, "Skip based on checksum (if available) & size, not mod-time & size")
@@ -64,18 +78,29 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.BoolVarP(flagSet, &deleteDuring, "delete-during", "", false, "When synchronizing, delete files during transfer")
 	flags.BoolVarP(flagSet, &deleteAfter, "delete-after", "", false, "When synchronizing, delete files on destination after transferring (default)")
 	flags.Int64VarP(flagSet, &fs.Config.MaxDelete, "max-delete", "", -1, "When synchronizing, limit the number of deletes")
+	flags.Float64VarP(flagSet, &fs.Config.MaxDeleteFraction, "max-delete-fraction", "", fs.Config.MaxDeleteFraction, "When synchronizing, don't delete more than this fraction of the destination files without --force, 0 for no limit.")
+	flags.BoolVarP(flagSet, &fs.Config.Force, "force", "", fs.Config.Force, "Delete more than the --max-delete-fraction of the destination files.")
 	flags.BoolVarP(flagSet, &fs.Config.TrackRenames, "track-renames", "", fs.Config.TrackRenames, "When synchronizing, track file renames and do a server side move if possible")
+	flags.BoolVarP(flagSet, &fs.Config.DetectMoves, "detect-moves", "", fs.Config.DetectMoves, "When synchronizing, move files server side instead of copy and delete if the destination can.")
 	flags.StringVarP(flagSet, &fs.Config.TrackRenamesStrategy, "track-renames-strategy", "", fs.Config.TrackRenamesStrategy, "Strategies to use when synchronizing using track-renames hash|modtime")
+	flags.IntVarP(flagSet, &fs.Config.Deleters, "deleters", "", fs.Config.Deleters, "Number of deletes to run in parallel, 0 for the same as --transfers.")
+	flags.Float64VarP(flagSet, &fs.Config.DeleteTPSLimit, "delete-tpslimit", "", fs.Config.DeleteTPSLimit, "Limit deletes per second to this.")
+	flags.BoolVarP(flagSet, &fs.Config.DeleteDeepestFirst, "delete-deepest-first", "", fs.Config.DeleteDeepestFirst, "Delete files in the deepest directories first with --delete-after.")
 	flags.IntVarP(flagSet, &fs.Config.LowLevelRetries, "low-level-retries", "", fs.Config.LowLevelRetries, "Number of low level retries to do.")
+	flags.DurationVarP(flagSet, &fs.Config.RetryDuration, "retry-duration", "", fs.Config.RetryDuration, "Max time to keep retrying for, 0 for no limit.")
+	flags.BoolVarP(flagSet, &fs.Config.NoUnsafeRetries, "no-unsafe-retries", "", fs.Config.NoUnsafeRetries, "Don't retry non idempotent operations on HTTP 5xx errors.")
 	flags.BoolVarP(flagSet, &fs.Config.UpdateOlder, "update", "u", fs.Config.UpdateOlder, "Skip files that are newer on the destination.")
 	flags.BoolVarP(flagSet, &fs.Config.UseServerModTime, "use-server-modtime", "", fs.Config.UseServerModTime, "Use server modified time instead of object metadata")
 	flags.BoolVarP(flagSet, &fs.Config.NoGzip, "no-gzip-encoding", "", fs.Config.NoGzip, "Don't set Accept-Encoding: gzip.")
 	flags.IntVarP(flagSet, &fs.Config.MaxDepth, "max-depth", "", fs.Config.MaxDepth, "If set limits the recursion depth to this.")
 	flags.BoolVarP(flagSet, &fs.Config.IgnoreSize, "ignore-size", "", false, "Ignore size when skipping use mod-time or checksum.")
 	flags.BoolVarP(flagSet, &fs.Config.IgnoreChecksum, "ignore-checksum", "", fs.Config.IgnoreChecksum, "Skip post copy check of checksums.")
+	flags.FVarP(flagSet, &fs.Config.HashPreference, "hash-preference", "", "Comma separated list of hashes to compare files with in order of preference, eg sha1,md5")
 	flags.BoolVarP(flagSet, &fs.Config.IgnoreCaseSync, "ignore-case-sync", "", fs.Config.IgnoreCaseSync, "Ignore case when synchronizing")
 	flags.BoolVarP(flagSet, &fs.Config.NoTraverse, "no-traverse", "", fs.Config.NoTraverse, "Don't traverse destination file system on copy.")
+	flags.BoolVarP(flagSet, &fs.Config.ProbeDest, "probe-dest", "", fs.Config.ProbeDest, "Look up each source file on the destination instead of listing it - disables deletes.")
 	flags.BoolVarP(flagSet, &fs.Config.CheckFirst, "check-first", "", fs.Config.CheckFirst, "Do all the checks before starting transfers.")
+	flags.BoolVarP(flagSet, &fs.Config.CheckFreeInodes, "check-free-inodes", "", fs.Config.CheckFreeInodes, "Check the destination has enough free inodes before starting transfers.")
 	flags.BoolVarP(flagSet, &fs.Config.NoCheckDest, "no-check-dest", "", fs.Config.NoCheckDest, "Don't check the destination, copy regardless.")
 	flags.BoolVarP(flagSet, &fs.Config.NoUnicodeNormalization, "no-unicode-normalization", "", fs.Config.NoUnicodeNormalization, "Don't normalize unicode characters in filenames.")
 	flags.BoolVarP(flagSet, &fs.Config.NoUpdateModTime, "no-update-modtime", "", fs.Config.NoUpdateModTime, "Don't update destination mod-time if files identical.")
@@ -87,7 +112,9 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.BoolVarP(flagSet, &fs.Config.UseListR, "fast-list", "", fs.Config.UseListR, "Use recursive list if available. Uses more memory but fewer transactions.")
 	flags.Float64VarP(flagSet, &fs.Config.TPSLimit, "tpslimit", "", fs.Config.TPSLimit, "Limit HTTP transactions per second to this.")
 	flags.IntVarP(flagSet, &fs.Config.TPSLimitBurst, "tpslimit-burst", "", fs.Config.TPSLimitBurst, "Max burst of transactions for --tpslimit.")
+	flags.Float64VarP(flagSet, &fs.Config.ListRate, "list-rate", "", fs.Config.ListRate, "Limit the HTTP transactions made listing directories to this many per second.")
 	flags.StringVarP(flagSet, &bindAddr, "bind", "", "", "Local address to bind to for outgoing connections, IPv4, IPv6 or name.")
+	flags.StringArrayVarP(flagSet, &bindRemotes, "bind-remote", "", nil, "Local address to bind to for the transfers of a remote as remoteName:address, may be repeated.")
 	flags.StringVarP(flagSet, &disableFeatures, "disable", "", "", "Disable a comma separated list of features.  Use help to see a list.")
 	flags.StringVarP(flagSet, &fs.Config.UserAgent, "user-agent", "", fs.Config.UserAgent, "Set the user-agent to a specified string. The default is rclone/ version")
 	flags.BoolVarP(flagSet, &fs.Config.Immutable, "immutable", "", fs.Config.Immutable, "Do not modify files. Fail if existing files have been modified.")
@@ -96,18 +123,65 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.FVarP(flagSet, &fs.Config.LogLevel, "log-level", "", "Log level DEBUG|INFO|NOTICE|ERROR")
 	flags.FVarP(flagSet, &fs.Config.StatsLogLevel, "stats-log-level", "", "Log level to show --stats output DEBUG|INFO|NOTICE|ERROR")
 	flags.FVarP(flagSet, &fs.Config.BwLimit, "bwlimit", "", "Bandwidth limit in kBytes/s, or use suffix b|k|M|G or a full timetable.")
+	flags.StringArrayVarP(flagSet, &bwLimitBuckets, "bwlimit-bucket", "", nil, "Bandwidth limit for uploads to a bucket as bucketName:rate, may be repeated.")
+	flags.StringArrayVarP(flagSet, &bwLimitBinds, "bwlimit-bind", "", nil, "Bandwidth limit for the transfers bound to a local address as address:rate, may be repeated.")
+	flags.DurationVarP(flagSet, &fs.Config.BwLimitBudget, "bwlimit-budget", "", fs.Config.BwLimitBudget, "Time per day to transfer at the full bandwidth before limiting to --bwlimit-budget-rate.")
+	flags.FVarP(flagSet, &fs.Config.BwLimitBudgetRate, "bwlimit-budget-rate", "", "Bandwidth limit once the --bwlimit-budget is used up in kBytes/s, or use suffix b|k|M|G")
+	flags.FVarP(flagSet, &fs.Config.BwLimitAfter, "bwlimit-after", "", "Don't start the --bwlimit until this much has been transferred in kBytes, or use suffix b|k|M|G")
+	flags.DurationVarP(flagSet, &fs.Config.BwLimitAfterPeriod, "bwlimit-after-period", "", fs.Config.BwLimitAfterPeriod, "Count the --bwlimit-after again from 0 this often, 0 for never.")
+	flags.FVarP(flagSet, &fs.Config.BwLimitPercent, "bwlimit-percent", "", "Timetable of percentages of the bandwidth measured with --bwlimit-percent-probe to limit to, eg \"08:00,20% 18:00,80%\".")
+	flags.StringVarP(flagSet, &fs.Config.BwLimitPercentProbe, "bwlimit-percent-probe", "", fs.Config.BwLimitPercentProbe, "URL to download to measure the bandwidth for --bwlimit-percent.")
+	flags.DurationVarP(flagSet, &fs.Config.BwLimitPercentInterval, "bwlimit-percent-interval", "", fs.Config.BwLimitPercentInterval, "How often to measure the bandwidth for --bwlimit-percent.")
+	flags.FVarP(flagSet, &fs.Config.BwLimitRelative, "bwlimit-relative", "", "Bandwidth timetable relative to the start of the run, eg \"0,off 10m,2M\", overriding the --bwlimit.")
+	flags.StringVarP(flagSet, &fs.Config.BwLimitSource, "bwlimit-source", "", fs.Config.BwLimitSource, "File to read the bandwidth limit from many times a second, eg in shared memory.")
+	flags.DurationVarP(flagSet, &fs.Config.BwLimitSourceInterval, "bwlimit-source-interval", "", fs.Config.BwLimitSourceInterval, "How often to read the --bwlimit-source.")
+	flags.FVarP(flagSet, &fs.Config.BwLimitStartTokens, "bwlimit-start-tokens", "", "Wait until the --bwlimit has this much bandwidth available before starting a transfer in kBytes, or use suffix b|k|M|G")
 	flags.FVarP(flagSet, &fs.Config.BufferSize, "buffer-size", "", "In memory buffer size when reading files for each --transfer.")
 	flags.FVarP(flagSet, &fs.Config.StreamingUploadCutoff, "streaming-upload-cutoff", "", "Cutoff for switching to chunked upload if file size is unknown. Upload starts after reaching cutoff or when file ends.")
 	flags.FVarP(flagSet, &fs.Config.Dump, "dump", "", "List of items to dump from: "+fs.DumpFlagsList)
 	flags.FVarP(flagSet, &fs.Config.MaxTransfer, "max-transfer", "", "Maximum size of data to transfer.")
 	flags.DurationVarP(flagSet, &fs.Config.MaxDuration, "max-duration", "", 0, "Maximum duration rclone will transfer data for.")
+	flags.BoolVarP(flagSet, &fs.Config.FailFast, "fail-fast", "", fs.Config.FailFast, "Stop the run, cancelling the transfers in progress, on the first error.")
+	flags.FVarP(flagSet, &fs.Config.MinTransferSpeed, "min-transfer-speed", "", "Abort transfers slower than this in k or suffix b|k|M|G")
+	flags.DurationVarP(flagSet, &fs.Config.MinTransferSpeedWindow, "min-transfer-speed-window", "", fs.Config.MinTransferSpeedWindow, "Time to measure the speed over for --min-transfer-speed")
+	flags.FVarP(flagSet, &fs.Config.MinTransferRate, "min-transfer-rate", "", "Bandwidth guaranteed to each transfer within the --bwlimit in k or suffix b|k|M|G")
 	flags.FVarP(flagSet, &fs.Config.CutoffMode, "cutoff-mode", "", "Mode to stop transfers when reaching the max transfer limit HARD|SOFT|CAUTIOUS")
+	flags.FVarP(flagSet, &fs.Config.ModTimeFallback, "modtime-fallback", "", "What to do if the modification time can't be set on the destination upload|metadata|ignore")
+	flags.FVarP(flagSet, &fs.Config.DirMarkers, "handle-dir-markers", "", "What to do with directory marker objects skip|create|delete")
+	flags.FVarP(flagSet, &fs.Config.IllegalChars, "illegal-chars", "", "What to do with names which have characters illegal on the destination encode|error|strip|substitute")
+	flags.StringArrayVarP(flagSet, &illegalCharsMap, "illegal-chars-map", "", nil, "Substitute for an illegal character with --illegal-chars substitute as char=replacement, may be repeated.")
+	flags.StringVarP(flagSet, &fs.Config.IllegalCharsManifest, "illegal-chars-manifest", "", fs.Config.IllegalCharsManifest, "File to record the names changed by --illegal-chars in.")
+	flags.FVarP(flagSet, &fs.Config.PartialCleanup, "partial-cleanup", "", "What to do with partial objects left by failed transfers delete|keep|resume")
+	flags.FVarP(flagSet, &fs.Config.OnSourceChange, "on-source-change", "", "What to do when a file changes on the source while being transferred retry|fail|ignore")
+	flags.FVarP(flagSet, &fs.Config.OnTruncatedRead, "on-truncated-read", "", "What to do when a source file is truncated while being read error|retry|skip")
+	flags.FVarP(flagSet, &fs.Config.OnOversize, "on-oversize", "", "What to do with files bigger than the destination can store error|skip|split")
+	flags.FVarP(flagSet, &fs.Config.MaxObjectSize, "max-object-size", "", "Largest file the destination can store, overriding the backend's limit, in k or suffix b|k|M|G")
+	flags.FVarP(flagSet, &fs.Config.VerifyHashMismatch, "verify-hash-mismatch", "", "Extra check before transferring files whose sizes match but hashes differ off|rehash|second-hash")
+	flags.FVarP(flagSet, &fs.Config.OnHashMismatch, "on-hash-mismatch", "", "What to do when the hash the destination computed on upload doesn't match error|fatal|warn|off")
+	flags.BoolVarP(flagSet, &fs.Config.VerifyUpload, "verify-upload", "", fs.Config.VerifyUpload, "Check the size and hash of each upload by fetching its metadata again.")
+	flags.FVarP(flagSet, &fs.Config.PostFileCmd, "post-file-cmd", "", "Command to run on each transferred file, with its path added as the last argument.")
+	flags.IntVarP(flagSet, &fs.Config.PostFileCmdConcurrency, "post-file-cmd-concurrency", "", fs.Config.PostFileCmdConcurrency, "Max number of --post-file-cmd to run at once.")
+	flags.FVarP(flagSet, &fs.Config.PostFileCmdError, "post-file-cmd-error", "", "What to do if the --post-file-cmd fails warn|fail")
+	flags.FVarP(flagSet, &fs.Config.MaxUnconfirmed, "max-unconfirmed", "", "Slow down reading when unfinished transfers have read more than this.")
+	flags.FVarP(flagSet, &fs.Config.PlannerMemoryLimit, "planner-memory-limit", "", "Abort if the sync planner would need more than this much memory for its listings.")
+	flags.StringArrayVarP(flagSet, &fs.Config.PauseWhileRunning, "pause-while-running", "", nil, "Pause the transfers while a program with this name is running, may be repeated.")
 	flags.IntVarP(flagSet, &fs.Config.MaxBacklog, "max-backlog", "", fs.Config.MaxBacklog, "Maximum number of objects in sync or check backlog.")
 	flags.IntVarP(flagSet, &fs.Config.MaxStatsGroups, "max-stats-groups", "", fs.Config.MaxStatsGroups, "Maximum number of stats groups to keep in memory. On max oldest is discarded.")
+	flags.BoolVarP(flagSet, &fs.Config.StatsPercentiles, "stats-percentiles", "", fs.Config.StatsPercentiles, "Sample the speed every second and show its p50/p95/p99 in the stats.")
 	flags.BoolVarP(flagSet, &fs.Config.StatsOneLine, "stats-one-line", "", fs.Config.StatsOneLine, "Make the stats fit on one line.")
 	flags.BoolVarP(flagSet, &fs.Config.StatsOneLineDate, "stats-one-line-date", "", fs.Config.StatsOneLineDate, "Enables --stats-one-line and add current date/time prefix.")
 	flags.StringVarP(flagSet, &fs.Config.StatsOneLineDateFormat, "stats-one-line-date-format", "", fs.Config.StatsOneLineDateFormat, "Enables --stats-one-line-date and uses custom formatted date. Enclose date string in double quotes (\"). See https://golang.org/pkg/time/#Time.Format")
 	flags.BoolVarP(flagSet, &fs.Config.ErrorOnNoTransfer, "error-on-no-transfer", "", fs.Config.ErrorOnNoTransfer, "Sets exit code 9 if no files are transferred, useful in scripts")
+	flags.StringVarP(flagSet, &fs.Config.StatsdAddr, "statsd-addr", "", fs.Config.StatsdAddr, "Send the stats to the StatsD server at this host:port.")
+	flags.StringVarP(flagSet, &fs.Config.StatsdPrefix, "statsd-prefix", "", fs.Config.StatsdPrefix, "Prefix for the StatsD metric names.")
+	flags.DurationVarP(flagSet, &fs.Config.StatsdInterval, "statsd-interval", "", fs.Config.StatsdInterval, "Interval between sending the stats to StatsD.")
+	flags.StringVarP(flagSet, &fs.Config.StatsInflux, "stats-influx", "", fs.Config.StatsInflux, "Send the stats to this udp:// or http(s):// URL in the InfluxDB line protocol every --stats interval.")
+	flags.StringVarP(flagSet, &fs.Config.HeartbeatURL, "heartbeat-url", "", fs.Config.HeartbeatURL, "Post a heartbeat with the progress to this URL while running.")
+	flags.DurationVarP(flagSet, &fs.Config.HeartbeatInterval, "heartbeat-interval", "", fs.Config.HeartbeatInterval, "Interval between posting heartbeats to the --heartbeat-url.")
+	flags.StringVarP(flagSet, &fs.Config.TraceURL, "trace-url", "", fs.Config.TraceURL, "Send OpenTelemetry traces of the transfers to this OTLP/HTTP URL.")
+	flags.StringVarP(flagSet, &fs.Config.TransferLog, "transfer-log", "", fs.Config.TransferLog, "Append a JSON record of each transfer to this file.")
+	flags.StringVarP(flagSet, &fs.Config.WriteManifest, "write-manifest", "", fs.Config.WriteManifest, "Write a JSON manifest of the paths, sizes and hashes of the files transferred to this file.")
+	flags.StringVarP(flagSet, &fs.Config.EventSocket, "event-socket", "", fs.Config.EventSocket, "Stream the progress and transfer events as JSON lines to the clients of this Unix socket.")
 	flags.BoolVarP(flagSet, &fs.Config.Progress, "progress", "P", fs.Config.Progress, "Show progress during transfer.")
 	flags.BoolVarP(flagSet, &fs.Config.Cookie, "use-cookies", "", fs.Config.Cookie, "Enable session cookiejar.")
 	flags.BoolVarP(flagSet, &fs.Config.UseMmap, "use-mmap", "", fs.Config.UseMmap, "Use mmap allocator (see docs).")
@@ -116,12 +190,33 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.StringVarP(flagSet, &fs.Config.ClientKey, "client-key", "", fs.Config.ClientKey, "Client SSL private key (PEM) for mutual TLS auth")
 	flags.FVarP(flagSet, &fs.Config.MultiThreadCutoff, "multi-thread-cutoff", "", "Use multi-thread downloads for files above this size.")
 	flags.IntVarP(flagSet, &fs.Config.MultiThreadStreams, "multi-thread-streams", "", fs.Config.MultiThreadStreams, "Max number of streams to use for multi-thread downloads.")
+	flags.IntVarP(flagSet, &fs.Config.MultipartChunks, "multipart-chunks", "", fs.Config.MultipartChunks, "Max number of multipart upload chunks to upload at once across all transfers, 0 for unlimited.")
+	flags.IntVarP(flagSet, &fs.Config.MaxCPU, "max-cpu", "", fs.Config.MaxCPU, "Max number of hashing/encryption operations to run at once, 0 for unlimited.")
+	flags.IntVarP(flagSet, &fs.Config.MaxOpenFiles, "max-open-files", "", fs.Config.MaxOpenFiles, "Max number of file handles to have open at once, 0 for unlimited.")
+	flags.StringArrayVarP(flagSet, &groupLimits, "concurrency-group", "", nil, "Max number of operations to run at once on the remotes in a concurrency group as name=N, may be repeated.")
 	flags.BoolVarP(flagSet, &fs.Config.UseJSONLog, "use-json-log", "", fs.Config.UseJSONLog, "Use json log format.")
 	flags.StringVarP(flagSet, &fs.Config.OrderBy, "order-by", "", fs.Config.OrderBy, "Instructions on how to order the transfers, eg 'size,descending'")
+	flags.StringVarP(flagSet, &fs.Config.PlanOut, "plan-out", "", fs.Config.PlanOut, "Write the transfers and deletes to this file instead of doing them.")
+	flags.IntVarP(flagSet, &fs.Config.DirShardThreshold, "dir-shard-threshold", "", fs.Config.DirShardThreshold, "Match directories with more entries than this in parallel shards, 0 to disable.")
+	flags.BoolVarP(flagSet, &fs.Config.MoveDeleteAfter, "move-delete-after", "", fs.Config.MoveDeleteAfter, "With move, only delete the source files once all the transfers have succeeded. Files are copied, not moved server side.")
+	flags.StringVarP(flagSet, &fs.Config.ResumeFilter, "resume-filter", "", fs.Config.ResumeFilter, "Keep a bloom filter of the completed files in this file to skip them if the sync is run again.")
+	flags.Int64VarP(flagSet, &fs.Config.ResumeFilterItems, "resume-filter-items", "", fs.Config.ResumeFilterItems, "Number of files to size a new --resume-filter for.")
+	flags.Float64VarP(flagSet, &fs.Config.ResumeFilterErrorRate, "resume-filter-error-rate", "", fs.Config.ResumeFilterErrorRate, "Fraction of files the --resume-filter skips by mistake.")
+	flags.DurationVarP(flagSet, &fs.Config.ConsistencyWindow, "consistency-window", "", fs.Config.ConsistencyWindow, "Wait up to this long for uploads to become visible and don't upload them again in this time.")
+	flags.BoolVarP(flagSet, &fs.Config.ShareReads, "share-reads", "", fs.Config.ShareReads, "Read a source file once when transferring it to several places at once.")
+	flags.FVarP(flagSet, &fs.Config.LinkDuplicates, "link-duplicates", "", "Link files with the same contents as one already copied instead of copying them off|reflink|hardlink|auto")
+	flags.StringVarP(flagSet, &fs.Config.PlanIn, "plan-in", "", fs.Config.PlanIn, "Do exactly the transfers and deletes in this plan file.")
+	flags.BoolVarP(flagSet, &fs.Config.PlanForce, "plan-force", "", fs.Config.PlanForce, "Run the --plan-in file even if files have changed since it was made.")
+	flags.StringVarP(flagSet, &fs.Config.ChangesFile, "changes-file", "", fs.Config.ChangesFile, "Only sync what the source change feed says has changed since the token in this file.")
+	flags.BoolVarP(flagSet, &fs.Config.CAS, "cas", "", fs.Config.CAS, "Copy files to the destination by the hash of their contents with a manifest of their names.")
+	flags.StringVarP(flagSet, &fs.Config.CASManifest, "cas-manifest", "", fs.Config.CASManifest, "Name of the manifest on the destination for --cas.")
+	flags.FVarP(flagSet, &fs.Config.AtTime, "at-time", "", "Copy the version of each source object current at this time or this long ago.")
 	flags.StringArrayVarP(flagSet, &uploadHeaders, "header-upload", "", nil, "Set HTTP header for upload transactions")
 	flags.StringArrayVarP(flagSet, &downloadHeaders, "header-download", "", nil, "Set HTTP header for download transactions")
 	flags.StringArrayVarP(flagSet, &headers, "header", "", nil, "Set HTTP header for all transactions")
 	flags.BoolVarP(flagSet, &fs.Config.RefreshTimes, "refresh-times", "", fs.Config.RefreshTimes, "Refresh the modtime of remote files.")
+	flags.BoolVarP(flagSet, &fs.Config.DeferModTime, "defer-modtime", "", fs.Config.DeferModTime, "Set the modification times in a batch after the transfers.")
+	flags.BoolVarP(flagSet, &fs.Config.SyncMetadata, "sync-metadata", "", fs.Config.SyncMetadata, "Copy the metadata, such as ACLs, both backends support with the files.")
 }
 
 // ParseHeaders converts the strings passed in via the header flags into HTTPOptions
@@ -141,6 +236,105 @@ func ParseHeaders(headers []string) []*fs.HTTPOption {
 	return opts
 }
 
+// ParseBwLimitBuckets converts the strings passed in via the
+// --bwlimit-bucket flags into bandwidth limits keyed by bucket name
+func ParseBwLimitBuckets(limits []string) map[string]fs.SizeSuffix {
+	bucketLimits := make(map[string]fs.SizeSuffix, len(limits))
+	for _, limit := range limits {
+		colon := strings.LastIndex(limit, ":")
+		if colon <= 0 {
+			log.Fatalf("Failed to parse '%s' as a bucket bandwidth limit. Expecting a string like: 'bucketName:1M'", limit)
+		}
+		var bandwidth fs.SizeSuffix
+		err := bandwidth.Set(limit[colon+1:])
+		if err != nil {
+			log.Fatalf("Failed to parse bandwidth in '%s': %v", limit, err)
+		}
+		bucketLimits[limit[:colon]] = bandwidth
+	}
+	return bucketLimits
+}
+
+// lookupBindAddr looks up addr given to flag as the local address to
+// bind to
+func lookupBindAddr(flag, addr string) net.IP {
+	addrs, err := net.LookupIP(addr)
+	if err != nil {
+		log.Fatalf("%s: Failed to parse %q as IP address: %v", flag, addr, err)
+	}
+	if len(addrs) != 1 {
+		log.Fatalf("%s: Expecting 1 IP address for %q but got %d", flag, addr, len(addrs))
+	}
+	return addrs[0]
+}
+
+// ParseBindRemotes converts the strings passed in via the
+// --bind-remote flags into local addresses keyed by remote name
+func ParseBindRemotes(binds []string) map[string]net.IP {
+	bindRemotes := make(map[string]net.IP, len(binds))
+	for _, bind := range binds {
+		// remote names can't contain a : but IPv6 addresses can
+		colon := strings.Index(bind, ":")
+		if colon <= 0 {
+			log.Fatalf("Failed to parse '%s' as a remote bind address. Expecting a string like: 'remoteName:192.168.1.2'", bind)
+		}
+		bindRemotes[bind[:colon]] = lookupBindAddr("--bind-remote", bind[colon+1:])
+	}
+	return bindRemotes
+}
+
+// ParseBwLimitBinds converts the strings passed in via the
+// --bwlimit-bind flags into bandwidth limits keyed by local address
+func ParseBwLimitBinds(limits []string) map[string]fs.SizeSuffix {
+	bindLimits := make(map[string]fs.SizeSuffix, len(limits))
+	for _, limit := range limits {
+		colon := strings.LastIndex(limit, ":")
+		if colon <= 0 {
+			log.Fatalf("Failed to parse '%s' as a bind address bandwidth limit. Expecting a string like: '192.168.1.2:1M'", limit)
+		}
+		var bandwidth fs.SizeSuffix
+		err := bandwidth.Set(limit[colon+1:])
+		if err != nil {
+			log.Fatalf("Failed to parse bandwidth in '%s': %v", limit, err)
+		}
+		bindLimits[lookupBindAddr("--bwlimit-bind", limit[:colon]).String()] = bandwidth
+	}
+	return bindLimits
+}
+
+// ParseConcurrencyGroups converts the strings passed in via the
+// --concurrency-group flags into limits keyed by group
+func ParseConcurrencyGroups(groups []string) map[string]int {
+	limits := make(map[string]int, len(groups))
+	for _, group := range groups {
+		equals := strings.LastIndex(group, "=")
+		if equals <= 0 {
+			log.Fatalf("Failed to parse '%s' as a concurrency group limit. Expecting a string like: 'shared=4'", group)
+		}
+		limit, err := strconv.Atoi(group[equals+1:])
+		if err != nil || limit <= 0 {
+			log.Fatalf("Failed to parse limit in '%s': expecting a number more than 0", group)
+		}
+		limits[group[:equals]] = limit
+	}
+	return limits
+}
+
+// ParseIllegalCharsMap converts the strings passed in via the
+// --illegal-chars-map flags into substitutes keyed by character
+func ParseIllegalCharsMap(substitutes []string) map[rune]string {
+	charMap := make(map[rune]string, len(substitutes))
+	for _, substitute := range substitutes {
+		equals := strings.Index(substitute, "=")
+		if equals <= 0 || utf8.RuneCountInString(substitute[:equals]) != 1 {
+			log.Fatalf("Failed to parse '%s' as an illegal character substitute. Expecting a string like: ':=_'", substitute)
+		}
+		r, _ := utf8.DecodeRuneInString(substitute)
+		charMap[r] = substitute[equals+1:]
+	}
+	return charMap
+}
+
 // SetFlags converts any flags into config which weren't straight forward
 func SetFlags() {
 	if verbose >= 2 {
@@ -222,14 +416,15 @@ func SetFlags() {
 	}
 
 	if bindAddr != "" {
-		addrs, err := net.LookupIP(bindAddr)
-		if err != nil {
-			log.Fatalf("--bind: Failed to parse %q as IP address: %v", bindAddr, err)
-		}
-		if len(addrs) != 1 {
-			log.Fatalf("--bind: Expecting 1 IP address for %q but got %d", bindAddr, len(addrs))
-		}
-		fs.Config.BindAddr = addrs[0]
+		fs.Config.BindAddr = lookupBindAddr("--bind", bindAddr)
+	}
+
+	if len(bindRemotes) != 0 {
+		fs.Config.BindRemote = ParseBindRemotes(bindRemotes)
+	}
+
+	if len(bwLimitBinds) != 0 {
+		fs.Config.BwLimitBind = ParseBwLimitBinds(bwLimitBinds)
 	}
 
 	if disableFeatures != "" {
@@ -239,6 +434,14 @@ func SetFlags() {
 		fs.Config.DisableFeatures = strings.Split(disableFeatures, ",")
 	}
 
+	if len(bwLimitBuckets) != 0 {
+		fs.Config.BwLimitBucket = ParseBwLimitBuckets(bwLimitBuckets)
+	}
+
+	if len(illegalCharsMap) != 0 {
+		fs.Config.IllegalCharsMap = ParseIllegalCharsMap(illegalCharsMap)
+	}
+
 	if len(uploadHeaders) != 0 {
 		fs.Config.UploadHeaders = ParseHeaders(uploadHeaders)
 	}
@@ -259,4 +462,15 @@ func SetFlags() {
 	multiThreadStreamsFlag := pflag.Lookup("multi-thread-streams")
 	fs.Config.MultiThreadSet = multiThreadStreamsFlag != nil && multiThreadStreamsFlag.Changed
 
+	// Limit the CPU intensive operations
+	cpulimit.SetMax(fs.Config.MaxCPU)
+
+	// Limit the open file handles
+	openfiles.SetMax(fs.Config.MaxOpenFiles)
+
+	// Limit the operations in each concurrency group
+	if len(groupLimits) != 0 {
+		fs.Config.ConcurrencyGroup = ParseConcurrencyGroups(groupLimits)
+	}
+	concurrencygroup.SetLimits(fs.Config.ConcurrencyGroup)
 }
@@ -0,0 +1,59 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DirMarkers describes what to do with the zero length objects with
+// a trailing "/" which some object stores use to mark directories
+type DirMarkers byte
+
+// DirMarkers constants
+const (
+	DirMarkersSkip DirMarkers = iota
+	DirMarkersCreate
+	DirMarkersDelete
+	DirMarkersDefault = DirMarkersSkip
+)
+
+var dirMarkersToString = []string{
+	DirMarkersSkip:   "skip",
+	DirMarkersCreate: "create",
+	DirMarkersDelete: "delete",
+}
+
+// String turns a DirMarkers into a string
+func (m DirMarkers) String() string {
+	if m >= DirMarkers(len(dirMarkersToString)) {
+		return fmt.Sprintf("DirMarkers(%d)", m)
+	}
+	return dirMarkersToString[m]
+}
+
+// Set a DirMarkers
+func (m *DirMarkers) Set(s string) error {
+	for n, name := range dirMarkersToString {
+		if s != "" && name == strings.ToLower(s) {
+			*m = DirMarkers(n)
+			return nil
+		}
+	}
+	return errors.Errorf("Unknown dir markers mode %q", s)
+}
+
+// Type of the value
+func (m *DirMarkers) Type() string {
+	return "string"
+}
+
+// DirMarkerer is an optional interface for Fs which can have
+// directory marker objects
+type DirMarkerer interface {
+	// DirMarker returns the directory marker object of dir or
+	// ErrorObjectNotFound if it hasn't got one
+	DirMarker(ctx context.Context, dir string) (Object, error)
+}
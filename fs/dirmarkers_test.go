@@ -0,0 +1,22 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// Check it satisfies the interface
+var _ pflag.Value = (*DirMarkers)(nil)
+
+func TestDirMarkersSet(t *testing.T) {
+	var m DirMarkers
+	assert.NoError(t, m.Set("CREATE"))
+	assert.Equal(t, DirMarkersCreate, m)
+	assert.Equal(t, "create", m.String())
+	assert.NoError(t, m.Set("delete"))
+	assert.Equal(t, DirMarkersDelete, m)
+	assert.Error(t, m.Set("potato"))
+	assert.Equal(t, "DirMarkers(17)", DirMarkers(17).String())
+}
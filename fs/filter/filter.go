@@ -93,6 +93,11 @@ type Opt struct {
 	MaxAge         fs.Duration
 	MinSize        fs.SizeSuffix
 	MaxSize        fs.SizeSuffix
+	MaxSizeSkip    bool
+	MinFileDepth   int
+	MaxFileDepth   int
+	Sample         Percent
+	SampleSeed     int64
 	IgnoreCase     bool
 }
 
@@ -102,6 +107,9 @@ var DefaultOpt = Opt{
 	MaxAge:  fs.DurationOff,
 	MinSize: fs.SizeSuffix(-1),
 	MaxSize: fs.SizeSuffix(-1),
+
+	MinFileDepth: -1,
+	MaxFileDepth: -1,
 }
 
 // Filter describes any filtering in operation
@@ -139,6 +147,12 @@ func NewFilter(opt *Opt) (f *Filter, err error) {
 		}
 		fs.Debugf(nil, "--max-age %v to %v", f.Opt.MaxAge, f.ModTimeFrom)
 	}
+	if f.Opt.MinFileDepth >= 0 && f.Opt.MaxFileDepth >= 0 && f.Opt.MinFileDepth > f.Opt.MaxFileDepth {
+		return nil, errors.New("filter: --min-file-depth can't be larger than --max-file-depth")
+	}
+	if f.Opt.Sample > 0 && f.Opt.DeleteExcluded {
+		return nil, errors.New("filter: can't use --sample with --delete-excluded as it would delete the files not in the sample")
+	}
 
 	addImplicitExclude := false
 	foundExcludeRule := false
@@ -362,6 +376,9 @@ func (f *Filter) InActive() bool {
 		f.ModTimeTo.IsZero() &&
 		f.Opt.MinSize < 0 &&
 		f.Opt.MaxSize < 0 &&
+		f.Opt.MinFileDepth < 0 &&
+		f.Opt.MaxFileDepth < 0 &&
+		f.Opt.Sample <= 0 &&
 		f.fileRules.len() == 0 &&
 		f.dirRules.len() == 0 &&
 		len(f.Opt.ExcludeFile) == 0)
@@ -414,6 +431,10 @@ func (f *Filter) IncludeDirectory(ctx context.Context, fs fs.Fs) func(string) (b
 			_, include := f.dirs[remote]
 			return include, nil
 		}
+		// Files in this directory are one level deeper than it
+		if f.Opt.MaxFileDepth >= 0 && fileDepth(remote)+1 > f.Opt.MaxFileDepth {
+			return false, nil
+		}
 		remote += "/"
 		for _, rule := range f.dirRules.rules {
 			if rule.Match(remote) {
@@ -447,7 +468,7 @@ func (f *Filter) Include(remote string, size int64, modTime time.Time) bool {
 	// filesFrom takes precedence
 	if f.files != nil {
 		_, include := f.files[remote]
-		return include
+		return include && f.inSample(remote)
 	}
 	if !f.ModTimeFrom.IsZero() && modTime.Before(f.ModTimeFrom) {
 		return false
@@ -458,10 +479,38 @@ func (f *Filter) Include(remote string, size int64, modTime time.Time) bool {
 	if f.Opt.MinSize >= 0 && size < int64(f.Opt.MinSize) {
 		return false
 	}
-	if f.Opt.MaxSize >= 0 && size > int64(f.Opt.MaxSize) {
+	if f.Opt.MaxSize >= 0 && size > int64(f.Opt.MaxSize) && !f.Opt.MaxSizeSkip {
 		return false
 	}
-	return f.includeRemote(remote)
+	if f.Opt.MinFileDepth >= 0 || f.Opt.MaxFileDepth >= 0 {
+		depth := fileDepth(remote)
+		if f.Opt.MinFileDepth >= 0 && depth < f.Opt.MinFileDepth {
+			return false
+		}
+		if f.Opt.MaxFileDepth >= 0 && depth > f.Opt.MaxFileDepth {
+			return false
+		}
+	}
+	return f.includeRemote(remote) && f.inSample(remote)
+}
+
+// TooLarge returns true if a file of size bytes should be skipped and
+// reported because it is bigger than --max-size and --max-size-skip
+// is set.  These files are included by Include so they can be
+// reported when they are transferred.
+func (f *Filter) TooLarge(size int64) bool {
+	return f.Opt.MaxSizeSkip && f.Opt.MaxSize >= 0 && size > int64(f.Opt.MaxSize)
+}
+
+// fileDepth returns the number of path components in remote.
+//
+// A file in the root has depth 1 and the root itself has depth 0.
+func fileDepth(remote string) int {
+	remote = strings.Trim(remote, "/")
+	if remote == "" {
+		return 0
+	}
+	return strings.Count(remote, "/") + 1
 }
 
 // IncludeObject returns whether this object should be included into
@@ -519,6 +568,15 @@ func (f *Filter) DumpFilters() string {
 	if !f.ModTimeTo.IsZero() {
 		rules = append(rules, fmt.Sprintf("Last-modified date must be equal or less than: %s", f.ModTimeTo.String()))
 	}
+	if f.Opt.MinFileDepth >= 0 {
+		rules = append(rules, fmt.Sprintf("File depth must be equal or greater than: %d", f.Opt.MinFileDepth))
+	}
+	if f.Opt.MaxFileDepth >= 0 {
+		rules = append(rules, fmt.Sprintf("File depth must be equal or less than: %d", f.Opt.MaxFileDepth))
+	}
+	if f.Opt.Sample > 0 {
+		rules = append(rules, fmt.Sprintf("Sample of files: %v with seed %d", f.Opt.Sample, f.Opt.SampleSeed))
+	}
 	rules = append(rules, "--- File filter rules ---")
 	for _, rule := range f.fileRules.rules {
 		rules = append(rules, rule.String())
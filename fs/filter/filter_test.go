@@ -22,6 +22,8 @@ func TestNewFilterDefault(t *testing.T) {
 	assert.False(t, f.Opt.DeleteExcluded)
 	assert.Equal(t, fs.SizeSuffix(-1), f.Opt.MinSize)
 	assert.Equal(t, fs.SizeSuffix(-1), f.Opt.MaxSize)
+	assert.Equal(t, -1, f.Opt.MinFileDepth)
+	assert.Equal(t, -1, f.Opt.MaxFileDepth)
 	assert.Len(t, f.fileRules.rules, 0)
 	assert.Len(t, f.dirRules.rules, 0)
 	assert.Nil(t, f.files)
@@ -386,6 +388,164 @@ func TestNewFilterMaxSize(t *testing.T) {
 	assert.False(t, f.InActive())
 }
 
+func TestNewFilterMaxSizeSkip(t *testing.T) {
+	f, err := NewFilter(nil)
+	require.NoError(t, err)
+	f.Opt.MaxSize = 100
+	f.Opt.MaxSizeSkip = true
+	testInclude(t, f, []includeTest{
+		{"file1.jpg", 100, 0, true},
+		{"file2.jpg", 101, 0, true},
+		{"potato/file2.jpg", 99, 0, true},
+	})
+	assert.False(t, f.TooLarge(100))
+	assert.True(t, f.TooLarge(101))
+	f.Opt.MaxSizeSkip = false
+	assert.False(t, f.TooLarge(101))
+	assert.False(t, f.InActive())
+}
+
+func TestNewFilterMinFileDepth(t *testing.T) {
+	f, err := NewFilter(nil)
+	require.NoError(t, err)
+	f.Opt.MinFileDepth = 2
+	testInclude(t, f, []includeTest{
+		{"file1.jpg", 100, 0, false},
+		{"potato/file2.jpg", 100, 0, true},
+		{"potato/sausage/file3.jpg", 100, 0, true},
+	})
+	testDirInclude(t, f, []includeDirTest{
+		{"potato", true},
+		{"potato/sausage", true},
+	})
+	assert.False(t, f.InActive())
+}
+
+func TestNewFilterMaxFileDepth(t *testing.T) {
+	f, err := NewFilter(nil)
+	require.NoError(t, err)
+	f.Opt.MaxFileDepth = 2
+	testInclude(t, f, []includeTest{
+		{"file1.jpg", 100, 0, true},
+		{"potato/file2.jpg", 100, 0, true},
+		{"potato/sausage/file3.jpg", 100, 0, false},
+	})
+	testDirInclude(t, f, []includeDirTest{
+		{"potato", true},
+		{"potato/sausage", false},
+	})
+	assert.False(t, f.InActive())
+}
+
+func TestNewFilterExactFileDepthWithRules(t *testing.T) {
+	f, err := NewFilter(nil)
+	require.NoError(t, err)
+	f.Opt.MinFileDepth = 3
+	f.Opt.MaxFileDepth = 3
+	require.NoError(t, f.Add(false, "*.bak"))
+	testInclude(t, f, []includeTest{
+		{"file1.jpg", 100, 0, false},
+		{"a/file2.jpg", 100, 0, false},
+		{"a/b/file3.jpg", 100, 0, true},
+		{"a/b/file3.bak", 100, 0, false},
+		{"a/b/c/file4.jpg", 100, 0, false},
+	})
+	got := f.DumpFilters()
+	want := `File depth must be equal or greater than: 3
+File depth must be equal or less than: 3
+--- File filter rules ---
+- (^|/)[^/]*\.bak$
+--- Directory filter rules ---`
+	assert.Equal(t, want, got)
+}
+
+func TestNewFilterMinFileDepthTooLarge(t *testing.T) {
+	opt := DefaultOpt
+	opt.MinFileDepth = 3
+	opt.MaxFileDepth = 2
+	_, err := NewFilter(&opt)
+	require.Error(t, err)
+}
+
+func TestNewFilterSample(t *testing.T) {
+	f, err := NewFilter(nil)
+	require.NoError(t, err)
+	f.Opt.Sample = 10
+	assert.False(t, f.InActive())
+	sample := func() (included map[string]bool) {
+		included = map[string]bool{}
+		for i := 0; i < 1000; i++ {
+			remote := fmt.Sprintf("dir%d/file%d.jpg", i%7, i)
+			if f.Include(remote, 100, time.Unix(0, 0)) {
+				included[remote] = true
+			}
+		}
+		return included
+	}
+	first := sample()
+	assert.InDelta(t, 100, len(first), 40)
+
+	// The sample is repeatable
+	assert.Equal(t, first, sample())
+
+	// A different seed chooses different files
+	f.Opt.SampleSeed = 42
+	assert.NotEqual(t, first, sample())
+
+	// The sample is taken from the files the rules include
+	require.NoError(t, f.Add(false, "dir0/**"))
+	for remote := range sample() {
+		assert.False(t, strings.HasPrefix(remote, "dir0/"), remote)
+	}
+	got := f.DumpFilters()
+	assert.Contains(t, got, "Sample of files: 10% with seed 42")
+
+	// Directories aren't sampled
+	testDirInclude(t, f, []includeDirTest{
+		{"dir1", true},
+	})
+
+	// Everything is in a 100% sample
+	f.Opt.Sample = 100
+	assert.True(t, f.Include("dir1/file1.jpg", 100, time.Unix(0, 0)))
+}
+
+func TestNewFilterSampleDeleteExcluded(t *testing.T) {
+	opt := DefaultOpt
+	opt.Sample = 1
+	opt.DeleteExcluded = true
+	_, err := NewFilter(&opt)
+	require.Error(t, err)
+}
+
+func TestPercentSet(t *testing.T) {
+	var p Percent
+	require.NoError(t, p.Set("1.5%"))
+	assert.Equal(t, Percent(1.5), p)
+	assert.Equal(t, "1.5%", p.String())
+	require.NoError(t, p.Set("20"))
+	assert.Equal(t, Percent(20), p)
+	assert.Error(t, p.Set("101%"))
+	assert.Error(t, p.Set("-1"))
+	assert.Error(t, p.Set("potato"))
+}
+
+func TestFileDepth(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"/", 0},
+		{"file", 1},
+		{"dir/", 1},
+		{"dir/file", 2},
+		{"/dir/sub/file", 3},
+	} {
+		assert.Equal(t, test.want, fileDepth(test.in), test.in)
+	}
+}
+
 func TestNewFilterMinAndMaxAge(t *testing.T) {
 	f, err := NewFilter(nil)
 	require.NoError(t, err)
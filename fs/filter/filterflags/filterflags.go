@@ -36,6 +36,11 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.FVarP(flagSet, &Opt.MaxAge, "max-age", "", "Only transfer files younger than this in s or suffix ms|s|m|h|d|w|M|y")
 	flags.FVarP(flagSet, &Opt.MinSize, "min-size", "", "Only transfer files bigger than this in k or suffix b|k|M|G")
 	flags.FVarP(flagSet, &Opt.MaxSize, "max-size", "", "Only transfer files smaller than this in k or suffix b|k|M|G")
+	flags.BoolVarP(flagSet, &Opt.MaxSizeSkip, "max-size-skip", "", false, "Skip and report files bigger than --max-size instead of excluding them")
+	flags.IntVarP(flagSet, &Opt.MinFileDepth, "min-file-depth", "", Opt.MinFileDepth, "Only transfer files at least this many directory levels deep (1 is the root)")
+	flags.IntVarP(flagSet, &Opt.MaxFileDepth, "max-file-depth", "", Opt.MaxFileDepth, "Only transfer files at most this many directory levels deep (1 is the root)")
+	flags.FVarP(flagSet, &Opt.Sample, "sample", "", "Only transfer a repeatable sample of this percentage of the files")
+	flags.Int64VarP(flagSet, &Opt.SampleSeed, "sample-seed", "", Opt.SampleSeed, "Seed to choose the files in the --sample with")
 	flags.BoolVarP(flagSet, &Opt.IgnoreCase, "ignore-case", "", false, "Ignore case in filters (case insensitive)")
 	//cvsExclude     = BoolP("cvs-exclude", "C", false, "Exclude files in the same way CVS does")
 }
@@ -0,0 +1,58 @@
+package filter
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Percent is a percentage which can be set from a flag with or
+// without a % suffix
+type Percent float64
+
+// String turns a Percent into a string
+func (p Percent) String() string {
+	return strconv.FormatFloat(float64(p), 'f', -1, 64) + "%"
+}
+
+// Set a Percent
+func (p *Percent) Set(s string) error {
+	value, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, "%")), 64)
+	if err != nil {
+		return errors.Errorf("bad percentage %q", s)
+	}
+	if value < 0 || value > 100 {
+		return errors.Errorf("percentage %q must be between 0%% and 100%%", s)
+	}
+	*p = Percent(value)
+	return nil
+}
+
+// Type of the value
+func (p *Percent) Type() string {
+	return "Percent"
+}
+
+// inSample returns whether remote is in the --sample of the files
+// chosen with --sample-seed.
+//
+// Whether a file is in the sample only depends on its name and the
+// seed so the same files are chosen each time.
+func (f *Filter) inSample(remote string) bool {
+	if f.Opt.Sample <= 0 || f.Opt.Sample >= 100 {
+		return true
+	}
+	if f.Opt.IgnoreCase {
+		remote = strings.ToLower(remote)
+	}
+	var seed [8]byte
+	binary.BigEndian.PutUint64(seed[:], uint64(f.Opt.SampleSeed))
+	h := fnv.New64a()
+	_, _ = h.Write(seed[:])
+	_, _ = h.Write([]byte(remote))
+	return float64(h.Sum64()) < float64(f.Opt.Sample)/100*math.MaxUint64
+}
@@ -51,6 +51,7 @@ var (
 	ErrorCantCopy                    = errors.New("can't copy object - incompatible remotes")
 	ErrorCantMove                    = errors.New("can't move object - incompatible remotes")
 	ErrorCantDirMove                 = errors.New("can't move directory - incompatible remotes")
+	ErrorCantLink                    = errors.New("can't link object - not supported")
 	ErrorCantUploadEmptyFiles        = errors.New("can't upload empty files to this remote")
 	ErrorDirExists                   = errors.New("can't copy directory - destination already exists")
 	ErrorCantSetModTime              = errors.New("can't set modified time")
@@ -71,6 +72,9 @@ var (
 	ErrorCantShareDirectories        = errors.New("this backend can't share directories with link")
 	ErrorNotImplemented              = errors.New("optional feature not implemented")
 	ErrorCommandNotFound             = errors.New("command not found")
+	ErrorAppendConflict              = errors.New("object isn't the size expected to append to")
+	ErrorSourceTruncated             = errors.New("source file was truncated while being read")
+	ErrorACLUnrepresentable          = errors.New("destination can't represent the ACL")
 )
 
 // RegInfo provides information about a filesystem
@@ -415,6 +419,44 @@ type GetTierer interface {
 	GetTier() string
 }
 
+// SourceModTimer is an optional interface for Object which can
+// store the modification time of the source in its metadata for
+// backends which can't set the modification time of the object
+// itself.
+type SourceModTimer interface {
+	// SourceModTime returns the modification time of the source
+	// stored in the metadata, or the zero time if there isn't one
+	SourceModTime(ctx context.Context) time.Time
+
+	// SetSourceModTime stores t in the metadata as the
+	// modification time of the source
+	SetSourceModTime(ctx context.Context, t time.Time) error
+}
+
+// ACLer is an optional interface for Object which can read and
+// write its access control list for --sync-metadata.
+type ACLer interface {
+	// ACL returns the access control list of the object
+	ACL(ctx context.Context) (ACL, error)
+
+	// SetACL replaces the access control list of the object with
+	// acl. It returns an error with ErrorACLUnrepresentable as the
+	// cause if the backend can't represent some of the grants.
+	SetACL(ctx context.Context, acl ACL) error
+}
+
+// Appender is an optional interface for Object which can add data to
+// the end of an existing object without uploading all of it again.
+type Appender interface {
+	// Append adds the data from in to the end of the object then
+	// sets its modification time from src.
+	//
+	// The object must be offset bytes long or Append returns an
+	// error wrapping ErrorAppendConflict without changing it, so
+	// it is safe if something else is also writing the object.
+	Append(ctx context.Context, in io.Reader, offset int64, src ObjectInfo, options ...OpenOption) error
+}
+
 // FullObjectInfo contains all the read-only optional interfaces
 //
 // Use for checking making wrapping ObjectInfos implement everything
@@ -493,6 +535,8 @@ type Usage struct {
 	Other   *int64 `json:"other,omitempty"`   // other usage eg gmail in drive
 	Free    *int64 `json:"free,omitempty"`    // bytes which can be uploaded before reaching the quota
 	Objects *int64 `json:"objects,omitempty"` // objects in the storage system
+
+	FreeObjects *int64 `json:"freeObjects,omitempty"` // objects which can be created before reaching the limit, eg free inodes
 }
 
 // WriterAtCloser wraps io.WriterAt and io.Closer
@@ -640,6 +684,14 @@ type Features struct {
 	// Disconnect the current user
 	Disconnect func(ctx context.Context) error
 
+	// AtTime returns a read only Fs showing the objects as they
+	// were at t, using the version of each one current then
+	AtTime func(ctx context.Context, t time.Time) (Fs, error)
+
+	// MaxObjectSize returns the size of the largest object which can
+	// be stored or -1 if there is no limit or it isn't known
+	MaxObjectSize func(ctx context.Context) int64
+
 	// Command the backend to run a named command
 	//
 	// The command run is name
@@ -771,6 +823,12 @@ func (ft *Features) Fill(f Fs) *Features {
 	if do, ok := f.(Disconnecter); ok {
 		ft.Disconnect = do.Disconnect
 	}
+	if do, ok := f.(AtTimer); ok {
+		ft.AtTime = do.AtTime
+	}
+	if do, ok := f.(MaxObjectSizer); ok {
+		ft.MaxObjectSize = do.MaxObjectSize
+	}
 	if do, ok := f.(Commander); ok {
 		ft.Command = do.Command
 	}
@@ -853,6 +911,12 @@ func (ft *Features) Mask(f Fs) *Features {
 	if mask.Disconnect == nil {
 		ft.Disconnect = nil
 	}
+	if mask.AtTime == nil {
+		ft.AtTime = nil
+	}
+	if mask.MaxObjectSize == nil {
+		ft.MaxObjectSize = nil
+	}
 	// Command is always local so we don't mask it
 	return ft.DisableList(Config.DisableFeatures)
 }
@@ -947,6 +1011,29 @@ type ChangeNotifier interface {
 	ChangeNotify(context.Context, func(string, EntryType), <-chan time.Duration)
 }
 
+// ChangeLister is an optional interface for Fs
+type ChangeLister interface {
+	// ListChanges calls fn with the path of each file or
+	// directory which has changed since token was returned and
+	// returns the token to use next time.
+	//
+	// If token is "" then it doesn't call fn and just returns the
+	// token for changes from now on.
+	ListChanges(ctx context.Context, token string, fn func(path string, entryType EntryType)) (newToken string, err error)
+}
+
+// Linker is an optional interface for Fs
+type Linker interface {
+	// Link makes an object at remote with the same contents as
+	// src without copying the data, using a reflink or a hardlink
+	// as set by mode.  src is an object of this remote.
+	//
+	// If remote exists it is replaced.
+	//
+	// If it isn't possible then return fs.ErrorCantLink
+	Link(ctx context.Context, src Object, remote string, mode LinkMode) (Object, error)
+}
+
 // UnWrapper is an optional interfaces for Fs
 type UnWrapper interface {
 	// UnWrap returns the Fs that this Fs is wrapping
@@ -1063,6 +1150,16 @@ type OpenWriterAter interface {
 	OpenWriterAt(ctx context.Context, remote string, size int64) (WriterAtCloser, error)
 }
 
+// ResumeWriterAter is an optional interface for Fs
+type ResumeWriterAter interface {
+	// ResumeWriterAt opens the existing object at remote written
+	// with OpenWriterAt for writing at random without truncating
+	// it, so an interrupted multi-thread copy can carry on.
+	//
+	// It returns an error if the object isn't size bytes long.
+	ResumeWriterAt(ctx context.Context, remote string, size int64) (WriterAtCloser, error)
+}
+
 // UserInfoer is an optional interface for Fs
 type UserInfoer interface {
 	// UserInfo returns info about the connected user
@@ -1075,6 +1172,20 @@ type Disconnecter interface {
 	Disconnect(ctx context.Context) error
 }
 
+// AtTimer is an optional interface for Fs
+type AtTimer interface {
+	// AtTime returns a read only Fs showing the objects as they
+	// were at t, using the version of each one current then
+	AtTime(ctx context.Context, t time.Time) (Fs, error)
+}
+
+// MaxObjectSizer is an optional interface for Fs
+type MaxObjectSizer interface {
+	// MaxObjectSize returns the size of the largest object which can
+	// be stored or -1 if there is no limit or it isn't known
+	MaxObjectSize(ctx context.Context) int64
+}
+
 // CommandHelp describes a single backend Command
 //
 // These are automatically inserted in the docs
@@ -1334,7 +1445,11 @@ func NewFs(path string) (Fs, error) {
 	if err != nil {
 		return nil, err
 	}
-	return fsInfo.NewFs(configName, fsPath, config)
+	f, err := fsInfo.NewFs(configName, fsPath, config)
+	if f != nil {
+		rememberBackendFeatures(fsInfo.Name, f)
+	}
+	return f, err
 }
 
 // ConfigString returns a canonical version of the config string used
@@ -1419,6 +1534,7 @@ func NewPacer(c pacer.Calculator) *Pacer {
 			pacer.InvokerOption(pacerInvoker),
 			pacer.MaxConnectionsOption(Config.Checkers+Config.Transfers),
 			pacer.RetriesOption(Config.LowLevelRetries),
+			pacer.RetryDurationOption(Config.RetryDuration),
 			pacer.CalculatorOption(c),
 		),
 	}
@@ -1472,10 +1588,50 @@ func (p *Pacer) ModifyCalculator(f func(pacer.Calculator)) {
 	})
 }
 
-func pacerInvoker(try, retries int, f pacer.Paced) (retry bool, err error) {
+// RetryBudget returns a description of the time left before deadline
+// for use in low level retry log messages, or "" if deadline is zero
+// as there is no --retry-duration.
+func RetryBudget(deadline time.Time) string {
+	if deadline.IsZero() {
+		return ""
+	}
+	left := time.Until(deadline)
+	if left < 0 {
+		left = 0
+	}
+	return fmt.Sprintf(", %v of --retry-duration left", left.Truncate(time.Millisecond))
+}
+
+// CallNonIdempotent paces and retries fn like Call, but for an
+// operation which isn't idempotent, such as making a directory or
+// uploading a file, where retrying after an ambiguous error could make
+// a duplicate.
+//
+// ambiguous is called with the error fn returns and should return
+// true if the server might have done the operation anyway, eg on an
+// HTTP 5xx error.  If --no-unsafe-retries is set these errors aren't
+// retried with a low level or a high level retry.
+func (p *Pacer) CallNonIdempotent(fn pacer.Paced, ambiguous func(error) bool) error {
+	return p.Call(NonIdempotent(fn, ambiguous))
+}
+
+// NonIdempotent wraps fn so it isn't retried after an ambiguous error
+// if --no-unsafe-retries is set, as in CallNonIdempotent.  This is for
+// use with CallNoRetry.
+func NonIdempotent(fn pacer.Paced, ambiguous func(error) bool) pacer.Paced {
+	return func() (bool, error) {
+		retry, err := fn()
+		if err != nil && Config.NoUnsafeRetries && ambiguous(err) {
+			return false, fserrors.NoRetryError(err)
+		}
+		return retry, err
+	}
+}
+
+func pacerInvoker(try, retries int, deadline time.Time, f pacer.Paced) (retry bool, err error) {
 	retry, err = f()
 	if retry {
-		Debugf("pacer", "low level retry %d/%d (error %v)", try, retries, err)
+		Debugf("pacer", "low level retry %d/%d%s (error %v)", try, retries, RetryBudget(deadline), err)
 		err = fserrors.RetryError(err)
 	}
 	return
@@ -152,6 +152,45 @@ func TestPacerCallNoRetry(t *testing.T) {
 	require.Implements(t, (*fserrors.Retrier)(nil), err)
 }
 
+func TestPacerCallNonIdempotent(t *testing.T) {
+	p := NewPacer(pacer.NewDefault(pacer.MinSleep(1*time.Millisecond), pacer.MaxSleep(2*time.Millisecond)))
+	p.SetRetries(3)
+	defer func() { Config.NoUnsafeRetries = false }()
+	isFoo := func(err error) bool { return err == errFoo }
+	isNotFoo := func(err error) bool { return err != errFoo }
+
+	// Retried as normal unless --no-unsafe-retries is set
+	dp := &dummyPaced{retry: true}
+	err := p.CallNonIdempotent(dp.fn, isFoo)
+	assert.Equal(t, 3, dp.called)
+	assert.Implements(t, (*fserrors.Retrier)(nil), err)
+
+	Config.NoUnsafeRetries = true
+	dp = &dummyPaced{retry: true}
+	err = p.CallNonIdempotent(dp.fn, isFoo)
+	assert.Equal(t, 1, dp.called)
+	assert.True(t, fserrors.IsNoRetryError(err))
+	assert.False(t, fserrors.ShouldRetry(err))
+
+	// Errors which aren't ambiguous are still retried
+	dp = &dummyPaced{retry: true}
+	err = p.CallNonIdempotent(dp.fn, isNotFoo)
+	assert.Equal(t, 3, dp.called)
+	assert.False(t, fserrors.IsNoRetryError(err))
+
+	// With CallNoRetry the high level retry is stopped too
+	dp = &dummyPaced{retry: true}
+	err = p.CallNoRetry(NonIdempotent(dp.fn, isFoo))
+	assert.Equal(t, 1, dp.called)
+	assert.True(t, fserrors.IsNoRetryError(err))
+}
+
+func TestRetryBudget(t *testing.T) {
+	assert.Equal(t, "", RetryBudget(time.Time{}))
+	assert.Equal(t, ", 0s of --retry-duration left", RetryBudget(time.Now().Add(-time.Second)))
+	assert.Contains(t, RetryBudget(time.Now().Add(time.Hour)), "m59.")
+}
+
 // Test options
 var (
 	nouncOption = Option{
@@ -437,6 +437,14 @@ func ShouldRetryHTTP(resp *http.Response, retryErrorCodes []int) bool {
 	return false
 }
 
+// IsServerError returns true if resp is an HTTP 5xx server error.
+//
+// These errors are ambiguous as the server may or may not have
+// carried out the operation before returning the error.
+func IsServerError(resp *http.Response) bool {
+	return resp != nil && resp.StatusCode >= 500 && resp.StatusCode < 600
+}
+
 type causer interface {
 	Cause() error
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"syscall"
@@ -170,3 +171,21 @@ func TestRetryAfter(t *testing.T) {
 	assert.True(t, IsRetryAfterError(err))
 	assert.Contains(t, e.Error(), "try again after")
 }
+
+func TestIsServerError(t *testing.T) {
+	for _, test := range []struct {
+		resp *http.Response
+		want bool
+	}{
+		{nil, false},
+		{&http.Response{StatusCode: 200}, false},
+		{&http.Response{StatusCode: 429}, false},
+		{&http.Response{StatusCode: 500}, true},
+		{&http.Response{StatusCode: 503}, true},
+		{&http.Response{StatusCode: 599}, true},
+		{&http.Response{StatusCode: 600}, false},
+	} {
+		got := IsServerError(test.resp)
+		assert.Equal(t, test.want, got, fmt.Sprintf("%+v", test.resp))
+	}
+}
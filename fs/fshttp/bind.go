@@ -0,0 +1,61 @@
+package fshttp
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// bindKey is the context key for the local address to bind to
+type bindKey struct{}
+
+// WithBind returns a copy of ctx which binds the connections for the
+// HTTP transactions made with it to the --bind-remote address of the
+// remote called name.
+//
+// ctx is returned unchanged if the remote doesn't have its own
+// address, so the connections are bound to the --bind address if set.
+func WithBind(ctx context.Context, name string) context.Context {
+	ip := fs.Config.BindRemote[name]
+	if ip == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, bindKey{}, ip)
+}
+
+// BoundAddr returns the local address the connections made with ctx
+// are bound to or nil if they aren't bound.
+func BoundAddr(ctx context.Context) net.IP {
+	if ip, ok := ctx.Value(bindKey{}).(net.IP); ok {
+		return ip
+	}
+	return fs.Config.BindAddr
+}
+
+// transportFor returns the http.Transport to use for req.
+//
+// Each --bind-remote address has its own copy of the transport so the
+// connections bound to different addresses are kept apart.
+func (t *Transport) transportFor(req *http.Request) *http.Transport {
+	ip, ok := req.Context().Value(bindKey{}).(net.IP)
+	if !ok || ip.Equal(t.ci.BindAddr) {
+		return t.Transport
+	}
+	key := ip.String()
+	t.boundMu.Lock()
+	defer t.boundMu.Unlock()
+	bound := t.bound[key]
+	if bound == nil {
+		bound = t.Transport.Clone()
+		bound.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialContextTimeoutBind(ctx, network, addr, t.ci, ip)
+		}
+		if t.bound == nil {
+			t.bound = make(map[string]*http.Transport)
+		}
+		t.bound[key] = bound
+	}
+	return bound
+}
@@ -43,6 +43,7 @@ func StartHTTPTokenBucket() {
 		tpsBucket = rate.NewLimiter(rate.Limit(fs.Config.TPSLimit), tpsBurst)
 		fs.Infof(nil, "Starting HTTP transaction limiter: max %g transactions/s with burst %d", fs.Config.TPSLimit, tpsBurst)
 	}
+	startListTokenBucket()
 }
 
 // A net.Conn that sets a deadline for every Read or Write operation
@@ -94,7 +95,15 @@ func (c *timeoutConn) Write(b []byte) (n int, err error) {
 
 // dial with context and timeouts
 func dialContextTimeout(ctx context.Context, network, address string, ci *fs.ConfigInfo) (net.Conn, error) {
+	return dialContextTimeoutBind(ctx, network, address, ci, nil)
+}
+
+// dial with context and timeouts bound to the local address ip if set
+func dialContextTimeoutBind(ctx context.Context, network, address string, ci *fs.ConfigInfo, ip net.IP) (net.Conn, error) {
 	dialer := NewDialer(ci)
+	if ip != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
 	c, err := dialer.DialContext(ctx, network, address)
 	if err != nil {
 		return c, err
@@ -201,10 +210,13 @@ func NewClient(ci *fs.ConfigInfo) *http.Client {
 // * Does logging
 type Transport struct {
 	*http.Transport
+	ci            *fs.ConfigInfo
 	dump          fs.DumpFlags
 	filterRequest func(req *http.Request)
 	userAgent     string
 	headers       []*fs.HTTPOption
+	boundMu       sync.Mutex                 // protects bound
+	bound         map[string]*http.Transport // transports for the --bind-remote addresses
 }
 
 // newTransport wraps the http.Transport passed in and logs all
@@ -212,6 +224,7 @@ type Transport struct {
 func newTransport(ci *fs.ConfigInfo, transport *http.Transport) *Transport {
 	return &Transport{
 		Transport: transport,
+		ci:        ci,
 		dump:      ci.Dump,
 		userAgent: ci.UserAgent,
 		headers:   ci.Headers,
@@ -312,6 +325,8 @@ func (t *Transport) RoundTrip(req *http.Request) (resp *http.Response, err error
 			fs.Errorf(nil, "HTTP token bucket error: %v", tbErr)
 		}
 	}
+	// Get a listing token if this is listing a remote
+	limitListing(req.Context())
 	// Force user agent
 	req.Header.Set("User-Agent", t.userAgent)
 	// Set user defined headers
@@ -334,7 +349,7 @@ func (t *Transport) RoundTrip(req *http.Request) (resp *http.Response, err error
 		fs.Debugf(nil, "%s", separatorReq)
 	}
 	// Do round trip
-	resp, err = t.Transport.RoundTrip(req)
+	resp, err = t.transportFor(req).RoundTrip(req)
 	// Logf response
 	if t.dump&(fs.DumpHeaders|fs.DumpBodies|fs.DumpAuth|fs.DumpRequests|fs.DumpResponses) != 0 {
 		fs.Debugf(nil, "%s", separatorResp)
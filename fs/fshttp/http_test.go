@@ -1,9 +1,16 @@
 package fshttp
 
 import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/rclone/rclone/fs"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCleanAuth(t *testing.T) {
@@ -45,3 +52,88 @@ func TestCleanAuths(t *testing.T) {
 		assert.Equal(t, test.want, got, test.in)
 	}
 }
+
+func TestListRate(t *testing.T) {
+	oldListRate := fs.Config.ListRate
+	defer func() {
+		fs.Config.ListRate = oldListRate
+		listBucket = nil
+		listStatsMu.Lock()
+		listStats = map[string]*ListStats{}
+		listStatsMu.Unlock()
+	}()
+	fs.Config.ListRate = 20
+	startListTokenBucket()
+	require.NotNil(t, listBucket)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := NewClient(fs.Config)
+	get := func(ctx context.Context) {
+		req, err := http.NewRequest("GET", server.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req.WithContext(ctx))
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+
+	// Transactions which aren't listing aren't limited or counted
+	for i := 0; i < 5; i++ {
+		get(context.Background())
+	}
+	assert.Equal(t, []ListStats{}, GetListStats())
+
+	// Listing calls are limited to 20 per second
+	ctx := WithListing(context.Background(), "remote")
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		get(ctx)
+	}
+	assert.True(t, time.Since(start) >= 150*time.Millisecond, "too quick %v", time.Since(start))
+	stats := GetListStats()
+	require.Equal(t, 1, len(stats))
+	assert.Equal(t, "remote", stats[0].Name)
+	assert.Equal(t, int64(5), stats[0].Calls)
+	assert.True(t, stats[0].Wait > 0)
+}
+
+func TestBindRemote(t *testing.T) {
+	oldBindRemote := fs.Config.BindRemote
+	defer func() {
+		fs.Config.BindRemote = oldBindRemote
+	}()
+	fs.Config.BindRemote = map[string]net.IP{
+		"remote": net.ParseIP("127.0.0.2"),
+	}
+
+	var remoteAddr string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteAddr, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}))
+	defer server.Close()
+	client := NewClient(fs.Config)
+	get := func(ctx context.Context) {
+		req, err := http.NewRequest("GET", server.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req.WithContext(ctx))
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+
+	ctx := context.Background()
+	assert.Nil(t, BoundAddr(ctx))
+	get(ctx)
+	assert.Equal(t, "127.0.0.1", remoteAddr)
+
+	// Remotes without their own address aren't bound
+	assert.Equal(t, ctx, WithBind(ctx, "other"))
+
+	boundCtx := WithBind(ctx, "remote")
+	assert.Equal(t, "127.0.0.2", BoundAddr(boundCtx).String())
+	get(boundCtx)
+	assert.Equal(t, "127.0.0.2", remoteAddr)
+
+	// The connections aren't shared
+	get(ctx)
+	assert.Equal(t, "127.0.0.1", remoteAddr)
+}
@@ -0,0 +1,88 @@
+package fshttp
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"golang.org/x/time/rate"
+)
+
+var (
+	listBucket *rate.Limiter // for limiting the number of listing calls per second
+
+	listStatsMu sync.Mutex
+	listStats   = map[string]*ListStats{} // stats for each remote
+)
+
+// ListStats are the stats for the listing calls made to one remote
+type ListStats struct {
+	Name  string        // name of the remote
+	Calls int64         // number of HTTP transactions made to list it
+	Wait  time.Duration // time the calls spent waiting for the --list-rate
+}
+
+// startListTokenBucket starts the token bucket for the --list-rate if
+// necessary
+func startListTokenBucket() {
+	if fs.Config.ListRate > 0 {
+		listBucket = rate.NewLimiter(rate.Limit(fs.Config.ListRate), 1)
+		fs.Infof(nil, "Starting listing limiter: max %g listing calls/s", fs.Config.ListRate)
+	}
+}
+
+// listingKey is the context key for the name of the remote being listed
+type listingKey struct{}
+
+// WithListing returns a copy of ctx which marks the HTTP transactions
+// made with it as listing the remote called name.
+//
+// These are limited by the --list-rate, each page of a paginated
+// listing counting as one call, and counted in the ListStats.
+func WithListing(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, listingKey{}, name)
+}
+
+// limitListing waits for the --list-rate if the HTTP transaction made
+// with ctx is listing a remote and accounts for it
+func limitListing(ctx context.Context) {
+	name, ok := ctx.Value(listingKey{}).(string)
+	if !ok {
+		return
+	}
+	var wait time.Duration
+	if listBucket != nil {
+		start := time.Now()
+		err := listBucket.Wait(ctx)
+		if err != nil && err != context.Canceled {
+			fs.Errorf(nil, "Listing token bucket error: %v", err)
+		}
+		wait = time.Since(start)
+	}
+	listStatsMu.Lock()
+	stats := listStats[name]
+	if stats == nil {
+		stats = &ListStats{Name: name}
+		listStats[name] = stats
+	}
+	stats.Calls++
+	stats.Wait += wait
+	listStatsMu.Unlock()
+}
+
+// GetListStats returns the stats for the listing calls made to each
+// remote sorted by name
+func GetListStats() []ListStats {
+	listStatsMu.Lock()
+	defer listStatsMu.Unlock()
+	out := make([]ListStats, 0, len(listStats))
+	for _, stats := range listStats {
+		out = append(out, *stats)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/jzelinskie/whirlpool"
 	"github.com/pkg/errors"
+	"github.com/rclone/rclone/lib/cpulimit"
 )
 
 // Type indicates a standard hashing algorithm
@@ -27,8 +28,33 @@ type hashDefinition struct {
 var hashes []*hashDefinition
 var highestType Type = 1
 
-// RegisterHash adds a new Hash to the list and returns it Type
+// RegisterHash adds a new Hash to the list and returns its Type
+//
+// This is how backends add the hashes only their remote uses, and
+// how code built into rclone can add further hashes, eg
+//
+//	var BLAKE3 = hash.RegisterHash("BLAKE3", 64, blake3.New)
+//
+// It should be called from an init function. width is the length of
+// the hash in hex, and newFunc makes a new hash.Hash to calculate it.
+// The hash is then supported by Stream, MultiHasher and "rclone
+// hashsum", is calculated by the local backend and can be used for
+// comparisons with any backend which returns it in Hashes.
+//
+// It panics if the name is already in use or too many hashes have
+// been registered.
 func RegisterHash(name string, width int, newFunc func() hash.Hash) Type {
+	if name == "" || name == "None" {
+		panic(fmt.Sprintf("hash: invalid name %q", name))
+	}
+	for _, v := range hashes {
+		if v.name == name {
+			panic(fmt.Sprintf("hash: %q is already registered", name))
+		}
+	}
+	if highestType <= 0 {
+		panic(fmt.Sprintf("hash: too many hashes registered to add %q", name))
+	}
 	definition := &hashDefinition{
 		name:     name,
 		width:    width,
@@ -152,6 +178,67 @@ func (h Type) Type() string {
 	return "string"
 }
 
+// TypeList is an ordered list of hash types, set from a comma
+// separated list of names, eg "sha-1,md5".
+//
+// The names are matched ignoring case and "-" so "sha1" and "SHA-1"
+// are the same.
+type TypeList []Type
+
+// normalName returns name in lower case with any "-" removed
+func normalName(name string) string {
+	return strings.ToLower(strings.Replace(name, "-", "", -1))
+}
+
+// String turns a TypeList into a comma separated list of names
+func (l TypeList) String() string {
+	names := make([]string, len(l))
+	for i, h := range l {
+		names[i] = h.String()
+	}
+	return strings.Join(names, ",")
+}
+
+// Set a TypeList from a flag
+func (l *TypeList) Set(s string) error {
+	var types TypeList
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, v := range hashes {
+			if normalName(v.name) == normalName(name) {
+				types = append(types, v.hashType)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.Errorf("Unknown hash type %q", name)
+		}
+	}
+	*l = types
+	return nil
+}
+
+// Type of the value
+func (l TypeList) Type() string {
+	return "string"
+}
+
+// Preferred returns the first hash type in the list which is in set
+// or None if there isn't one.
+func (l TypeList) Preferred(set Set) Type {
+	for _, h := range l {
+		if set.Contains(h) {
+			return h
+		}
+	}
+	return None
+}
+
 // fromTypes will return hashers for all the requested types.
 // The types must be a subset of SupportedHashes,
 // and this function must support all types.
@@ -223,7 +310,9 @@ func NewMultiHasherTypes(set Set) (*MultiHasher, error) {
 }
 
 func (m *MultiHasher) Write(p []byte) (n int, err error) {
-	n, err = m.w.Write(p)
+	cpulimit.Do(func() {
+		n, err = m.w.Write(p)
+	})
 	m.size += int64(n)
 	return n, err
 }
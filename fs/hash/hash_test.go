@@ -2,6 +2,8 @@ package hash_test
 
 import (
 	"bytes"
+	gohash "hash"
+	"hash/fnv"
 	"io"
 	"log"
 	"testing"
@@ -14,6 +16,7 @@ import (
 
 // Check it satisfies the interface
 var _ pflag.Value = (*hash.Type)(nil)
+var _ pflag.Value = (*hash.TypeList)(nil)
 
 func TestHashSet(t *testing.T) {
 	var h hash.Set
@@ -169,3 +172,42 @@ func TestHashStringer(t *testing.T) {
 	h = hash.None
 	assert.Equal(t, h.String(), "None")
 }
+
+// This must be run last as it adds a hash to Supported
+func TestTypeList(t *testing.T) {
+	var l hash.TypeList
+	require.NoError(t, l.Set("sha1, MD5,crc-32"))
+	assert.Equal(t, hash.TypeList{hash.SHA1, hash.MD5, hash.CRC32}, l)
+	assert.Equal(t, "SHA-1,MD5,CRC-32", l.String())
+	assert.Equal(t, hash.MD5, l.Preferred(hash.NewHashSet(hash.MD5, hash.CRC32)))
+	assert.Equal(t, hash.SHA1, l.Preferred(hash.NewHashSet(hash.MD5, hash.SHA1)))
+	assert.Equal(t, hash.None, l.Preferred(hash.NewHashSet(hash.Whirlpool)))
+	assert.Error(t, l.Set("md5,potato"))
+	require.NoError(t, l.Set(""))
+	assert.Nil(t, l)
+}
+
+func TestRegisterHash(t *testing.T) {
+	fnvType := hash.RegisterHash("FNV-64a", 16, func() gohash.Hash { return fnv.New64a() })
+	assert.True(t, hash.Supported().Contains(fnvType))
+	assert.Equal(t, "FNV-64a", fnvType.String())
+	assert.Equal(t, 16, hash.Width(fnvType))
+
+	var ht hash.Type
+	require.NoError(t, ht.Set("FNV-64a"))
+	assert.Equal(t, fnvType, ht)
+
+	sums, err := hash.StreamTypes(bytes.NewBufferString("hello"), hash.NewHashSet(fnvType, hash.MD5))
+	require.NoError(t, err)
+	assert.Equal(t, map[hash.Type]string{
+		fnvType:  "a430d84680aabd0b",
+		hash.MD5: "5d41402abc4b2a76b9719d911017c592",
+	}, sums)
+
+	assert.Panics(t, func() {
+		hash.RegisterHash("FNV-64a", 16, func() gohash.Hash { return fnv.New64a() })
+	})
+	assert.Panics(t, func() {
+		hash.RegisterHash("None", 16, func() gohash.Hash { return fnv.New64a() })
+	})
+}
@@ -0,0 +1,52 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// HashMismatch describes what to do when the hash the destination
+// computed for an upload doesn't match the data uploaded
+type HashMismatch byte
+
+// HashMismatch constants
+const (
+	HashMismatchError HashMismatch = iota
+	HashMismatchFatal
+	HashMismatchWarn
+	HashMismatchOff
+	HashMismatchDefault = HashMismatchError
+)
+
+var hashMismatchToString = []string{
+	HashMismatchError: "error",
+	HashMismatchFatal: "fatal",
+	HashMismatchWarn:  "warn",
+	HashMismatchOff:   "off",
+}
+
+// String turns a HashMismatch into a string
+func (m HashMismatch) String() string {
+	if m >= HashMismatch(len(hashMismatchToString)) {
+		return fmt.Sprintf("HashMismatch(%d)", m)
+	}
+	return hashMismatchToString[m]
+}
+
+// Set a HashMismatch
+func (m *HashMismatch) Set(s string) error {
+	for n, name := range hashMismatchToString {
+		if s != "" && name == strings.ToLower(s) {
+			*m = HashMismatch(n)
+			return nil
+		}
+	}
+	return errors.Errorf("Unknown hash mismatch mode %q", s)
+}
+
+// Type of the value
+func (m *HashMismatch) Type() string {
+	return "string"
+}
@@ -0,0 +1,24 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// Check it satisfies the interface
+var _ pflag.Value = (*HashMismatch)(nil)
+
+func TestHashMismatchSet(t *testing.T) {
+	var m HashMismatch
+	assert.NoError(t, m.Set("FATAL"))
+	assert.Equal(t, HashMismatchFatal, m)
+	assert.Equal(t, "fatal", m.String())
+	assert.NoError(t, m.Set("warn"))
+	assert.Equal(t, HashMismatchWarn, m)
+	assert.NoError(t, m.Set("off"))
+	assert.Equal(t, HashMismatchOff, m)
+	assert.Error(t, m.Set("potato"))
+	assert.Equal(t, "HashMismatch(17)", HashMismatch(17).String())
+}
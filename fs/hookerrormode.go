@@ -0,0 +1,48 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// HookErrorMode describes what to do when a command run for a
+// transferred file, such as the --post-file-cmd, fails
+type HookErrorMode byte
+
+// HookErrorMode constants
+const (
+	HookErrorWarn HookErrorMode = iota
+	HookErrorFail
+	HookErrorDefault = HookErrorWarn
+)
+
+var hookErrorModeToString = []string{
+	HookErrorWarn: "warn",
+	HookErrorFail: "fail",
+}
+
+// String turns a HookErrorMode into a string
+func (m HookErrorMode) String() string {
+	if m >= HookErrorMode(len(hookErrorModeToString)) {
+		return fmt.Sprintf("HookErrorMode(%d)", m)
+	}
+	return hookErrorModeToString[m]
+}
+
+// Set a HookErrorMode
+func (m *HookErrorMode) Set(s string) error {
+	for n, name := range hookErrorModeToString {
+		if s != "" && name == strings.ToLower(s) {
+			*m = HookErrorMode(n)
+			return nil
+		}
+	}
+	return errors.Errorf("Unknown hook error mode %q", s)
+}
+
+// Type of the value
+func (m *HookErrorMode) Type() string {
+	return "string"
+}
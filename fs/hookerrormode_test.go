@@ -0,0 +1,22 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// Check it satisfies the interface
+var _ pflag.Value = (*HookErrorMode)(nil)
+
+func TestHookErrorModeSet(t *testing.T) {
+	var m HookErrorMode
+	assert.NoError(t, m.Set("FAIL"))
+	assert.Equal(t, HookErrorFail, m)
+	assert.Equal(t, "fail", m.String())
+	assert.NoError(t, m.Set("warn"))
+	assert.Equal(t, HookErrorWarn, m)
+	assert.Error(t, m.Set("potato"))
+	assert.Equal(t, "HookErrorMode(17)", HookErrorMode(17).String())
+}
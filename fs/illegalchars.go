@@ -0,0 +1,134 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/lib/encoder"
+)
+
+// IllegalChars describes what to do with file names which have
+// characters in which the destination can't store
+type IllegalChars byte
+
+// IllegalChars constants
+const (
+	IllegalCharsEncode IllegalChars = iota
+	IllegalCharsError
+	IllegalCharsStrip
+	IllegalCharsSubstitute
+)
+
+var illegalCharsToString = []string{
+	IllegalCharsEncode:     "encode",
+	IllegalCharsError:      "error",
+	IllegalCharsStrip:      "strip",
+	IllegalCharsSubstitute: "substitute",
+}
+
+// IllegalCharsDefaultSubstitute is used with --illegal-chars
+// substitute for characters which aren't in the --illegal-chars-map
+const IllegalCharsDefaultSubstitute = "_"
+
+// String turns an IllegalChars into a string
+func (m IllegalChars) String() string {
+	if m >= IllegalChars(len(illegalCharsToString)) {
+		return fmt.Sprintf("IllegalChars(%d)", m)
+	}
+	return illegalCharsToString[m]
+}
+
+// Set an IllegalChars
+func (m *IllegalChars) Set(s string) error {
+	for n, name := range illegalCharsToString {
+		if s != "" && name == strings.ToLower(s) {
+			*m = IllegalChars(n)
+			return nil
+		}
+	}
+	return errors.Errorf("Unknown illegal chars mode %q", s)
+}
+
+// Type of the value
+func (m *IllegalChars) Type() string {
+	return "string"
+}
+
+// illegalCharsEncodings caches the encoding of each destination
+// keyed by its ConfigString
+var illegalCharsEncodings = struct {
+	mu  sync.Mutex
+	enc map[string]encoder.MultiEncoder
+}{
+	enc: make(map[string]encoder.MultiEncoder),
+}
+
+// illegalCharsEncoding returns the encoding f uses for file names
+// from the encoding option of its backend, or encoder.EncodeZero if
+// it doesn't have one.
+func illegalCharsEncoding(f Fs) encoder.MultiEncoder {
+	configString := ConfigString(f)
+	illegalCharsEncodings.mu.Lock()
+	defer illegalCharsEncodings.mu.Unlock()
+	if enc, ok := illegalCharsEncodings.enc[configString]; ok {
+		return enc
+	}
+	enc := encoder.EncodeZero
+	fsInfo, configName, _, err := ParseRemote(configString)
+	if err == nil && fsInfo.Options.Get("encoding") != nil {
+		value, _ := ConfigMap(fsInfo, configName).Get("encoding")
+		err = enc.Set(value)
+		if err != nil {
+			Debugf(f, "Ignoring encoding for --illegal-chars: %v", err)
+			enc = encoder.EncodeZero
+		}
+	}
+	illegalCharsEncodings.enc[configString] = enc
+	return enc
+}
+
+// IllegalCharsRemote returns the name remote should be stored under
+// on f according to --illegal-chars.
+//
+// With --illegal-chars encode, which is the default, remote is
+// returned unchanged for the backend to encode as usual.  With error
+// an error is returned if remote has any characters which the
+// encoding of f would have to replace, and with strip or substitute
+// they are removed or replaced with the --illegal-chars-map.
+func IllegalCharsRemote(f Fs, remote string) (string, error) {
+	mode := Config.IllegalChars
+	if mode == IllegalCharsEncode {
+		return remote, nil
+	}
+	enc := illegalCharsEncoding(f)
+	newRemote := remote
+	switch mode {
+	case IllegalCharsError:
+		if enc.HasIllegal(remote) {
+			return remote, errors.Errorf("%q has characters which are illegal on %v", remote, f)
+		}
+		return remote, nil
+	case IllegalCharsStrip:
+		newRemote = enc.ReplaceIllegalPath(remote, func(r rune) string {
+			return ""
+		})
+	case IllegalCharsSubstitute:
+		newRemote = enc.ReplaceIllegalPath(remote, func(r rune) string {
+			if substitute, ok := Config.IllegalCharsMap[r]; ok {
+				return substitute
+			}
+			return IllegalCharsDefaultSubstitute
+		})
+	}
+	if newRemote == remote {
+		return remote, nil
+	}
+	for _, part := range strings.Split(newRemote, "/") {
+		if part == "" {
+			return remote, errors.Errorf("%q would have an empty name on %v without its illegal characters", remote, f)
+		}
+	}
+	return newRemote, nil
+}
@@ -0,0 +1,26 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// Check it satisfies the interface
+var _ pflag.Value = (*IllegalChars)(nil)
+
+func TestIllegalCharsSet(t *testing.T) {
+	var m IllegalChars
+	assert.NoError(t, m.Set("Strip"))
+	assert.Equal(t, IllegalCharsStrip, m)
+	assert.Equal(t, "strip", m.String())
+	assert.NoError(t, m.Set("substitute"))
+	assert.Equal(t, IllegalCharsSubstitute, m)
+	assert.NoError(t, m.Set("error"))
+	assert.Equal(t, IllegalCharsError, m)
+	assert.NoError(t, m.Set("encode"))
+	assert.Equal(t, IllegalCharsEncode, m)
+	assert.Error(t, m.Set("potato"))
+	assert.Equal(t, "IllegalChars(17)", IllegalChars(17).String())
+}
@@ -0,0 +1,52 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LinkMode describes how to link a new object to an existing object
+// with the same contents instead of copying it
+type LinkMode byte
+
+// LinkMode constants
+const (
+	LinkModeOff LinkMode = iota
+	LinkModeReflink
+	LinkModeHardlink
+	LinkModeAuto
+	LinkModeDefault = LinkModeOff
+)
+
+var linkModeToString = []string{
+	LinkModeOff:      "off",
+	LinkModeReflink:  "reflink",
+	LinkModeHardlink: "hardlink",
+	LinkModeAuto:     "auto",
+}
+
+// String turns a LinkMode into a string
+func (m LinkMode) String() string {
+	if m >= LinkMode(len(linkModeToString)) {
+		return fmt.Sprintf("LinkMode(%d)", m)
+	}
+	return linkModeToString[m]
+}
+
+// Set a LinkMode
+func (m *LinkMode) Set(s string) error {
+	for n, name := range linkModeToString {
+		if s != "" && name == strings.ToLower(s) {
+			*m = LinkMode(n)
+			return nil
+		}
+	}
+	return errors.Errorf("Unknown link mode %q", s)
+}
+
+// Type of the value
+func (m *LinkMode) Type() string {
+	return "string"
+}
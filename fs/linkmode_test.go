@@ -0,0 +1,22 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// Check it satisfies the interface
+var _ pflag.Value = (*LinkMode)(nil)
+
+func TestLinkModeSet(t *testing.T) {
+	var m LinkMode
+	assert.NoError(t, m.Set("REFLINK"))
+	assert.Equal(t, LinkModeReflink, m)
+	assert.Equal(t, "reflink", m.String())
+	assert.NoError(t, m.Set("hardlink"))
+	assert.Equal(t, LinkModeHardlink, m)
+	assert.Error(t, m.Set("potato"))
+	assert.Equal(t, "LinkMode(17)", LinkMode(17).String())
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/fshttp"
 )
 
 // DirSorted reads Object and *Dir into entries for the given Fs.
@@ -21,7 +22,7 @@ import (
 // Files will be returned in sorted order
 func DirSorted(ctx context.Context, f fs.Fs, includeAll bool, dir string) (entries fs.DirEntries, err error) {
 	// Get unfiltered entries from the fs
-	entries, err = f.List(ctx, dir)
+	entries, err = f.List(fshttp.WithBind(fshttp.WithListing(ctx, f.Name()), f.Name()), dir)
 	if err != nil {
 		return nil, err
 	}
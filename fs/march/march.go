@@ -3,6 +3,7 @@ package march
 
 import (
 	"context"
+	"hash/fnv"
 	"path"
 	"sort"
 	"strings"
@@ -36,6 +37,7 @@ type March struct {
 	srcListDir listDirFn // function to call to list a directory in the src
 	dstListDir listDirFn // function to call to list a directory in the dst
 	transforms []matchTransformFn
+	memory     *memoryAccount // approximate memory used by the listings
 }
 
 // Marcher is called on each match
@@ -50,6 +52,7 @@ type Marcher interface {
 
 // init sets up a march over opt.Fsrc, and opt.Fdst calling back callback for each match
 func (m *March) init() {
+	m.memory = newMemoryAccount()
 	m.srcListDir = m.makeListDir(m.Fsrc, m.SrcIncludeAll)
 	if !m.NoTraverse {
 		m.dstListDir = m.makeListDir(m.Fdst, m.DstIncludeAll)
@@ -59,6 +62,17 @@ func (m *March) init() {
 	if !m.NoUnicodeNormalization {
 		m.transforms = append(m.transforms, norm.NFC.String)
 	}
+	// ..then match the names the source is stored under on the
+	// destination with --illegal-chars strip or substitute
+	if fs.Config.IllegalChars == fs.IllegalCharsStrip || fs.Config.IllegalChars == fs.IllegalCharsSubstitute {
+		m.transforms = append(m.transforms, func(name string) string {
+			newName, err := fs.IllegalCharsRemote(m.Fdst, name)
+			if err != nil {
+				return name
+			}
+			return newName
+		})
+	}
 	// ..if destination is caseInsensitive then make it lower case
 	// case Insensitive | src | dst | lower case compare |
 	//                  | No  | No  | No                 |
@@ -77,7 +91,7 @@ type listDirFn func(dir string) (entries fs.DirEntries, err error)
 // and includeAll flags for marching through the file system.
 func (m *March) makeListDir(f fs.Fs, includeAll bool) listDirFn {
 	if !(fs.Config.UseListR && f.Features().ListR != nil) && // !--fast-list active and
-		!(fs.Config.NoTraverse && filter.Active.HaveFilesFrom()) { // !(--files-from and --no-traverse)
+		!((fs.Config.NoTraverse || fs.Config.ProbeDest) && filter.Active.HaveFilesFrom()) { // !(--files-from and --no-traverse)
 		return func(dir string) (entries fs.DirEntries, err error) {
 			return list.DirSorted(m.Ctx, f, includeAll, dir)
 		}
@@ -97,6 +111,15 @@ func (m *March) makeListDir(f fs.Fs, includeAll bool) listDirFn {
 		if !started {
 			dirs, dirsErr = walk.NewDirTree(m.Ctx, f, m.Dir, includeAll, fs.Config.MaxDepth)
 			started = true
+			if dirsErr == nil {
+				// Account for the whole tree being held until
+				// each directory is handed out
+				var total int64
+				for _, entries := range dirs {
+					total += entriesMemory(entries)
+				}
+				dirsErr = m.memory.reserve(total)
+			}
 		}
 		if dirsErr != nil {
 			return nil, dirsErr
@@ -106,6 +129,7 @@ func (m *March) makeListDir(f fs.Fs, includeAll bool) listDirFn {
 			err = fs.ErrorDirNotFound
 		} else {
 			delete(dirs, dir)
+			m.memory.release(entriesMemory(entries))
 		}
 		return entries, err
 	}
@@ -204,6 +228,7 @@ func (m *March) Run() error {
 	traversing.Wait()
 	close(in)
 	wg.Wait()
+	m.memory.report()
 
 	if errCount > 1 {
 		return errors.Wrapf(jobError, "march failed with %d error(s): first error", errCount)
@@ -372,12 +397,16 @@ func matchListings(srcListEntries, dstListEntries fs.DirEntries, transforms []ma
 // returns errors using processError
 func (m *March) processJob(job listDirJob) ([]listDirJob, error) {
 	var (
-		jobs                   []listDirJob
 		srcList, dstList       fs.DirEntries
 		srcListErr, dstListErr error
 		wg                     sync.WaitGroup
 	)
 
+	// Stop if the listings won't fit in --planner-memory-limit
+	if err := m.memory.exceeded(); err != nil {
+		return nil, err
+	}
+
 	// List the src and dst directories
 	if !job.noSrc {
 		wg.Add(1)
@@ -409,6 +438,90 @@ func (m *March) processJob(job listDirJob) ([]listDirJob, error) {
 		return nil, dstListErr
 	}
 
+	// Account for the memory used by the listings while they are
+	// being matched
+	memory := entriesMemory(srcList) + entriesMemory(dstList)
+	err := m.memory.reserve(memory)
+	if err != nil {
+		return nil, err
+	}
+	defer m.memory.release(memory)
+
+	// Split large directories into shards which are matched in parallel
+	if shards := m.numShards(len(srcList) + len(dstList)); shards > 1 {
+		return m.processShards(job, srcList, dstList, shards)
+	}
+	return m.processEntries(job, srcList, dstList)
+}
+
+// numShards returns how many shards to split a directory with the
+// number of entries passed in into
+func (m *March) numShards(entries int) int {
+	threshold := fs.Config.DirShardThreshold
+	if threshold <= 0 || entries <= threshold {
+		return 1
+	}
+	shards := (entries + threshold - 1) / threshold
+	if shards > fs.Config.Checkers {
+		shards = fs.Config.Checkers
+	}
+	return shards
+}
+
+// shardEntries splits entries into the number of shards passed in.
+//
+// This uses the transformed name so entries which would match each
+// other are always in the same shard.
+func (m *March) shardEntries(entries fs.DirEntries, shards int) []fs.DirEntries {
+	out := make([]fs.DirEntries, shards)
+	for _, entry := range entries {
+		name := path.Base(entry.Remote())
+		for _, transform := range m.transforms {
+			name = transform(name)
+		}
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(name))
+		i := h.Sum32() % uint32(shards)
+		out[i] = append(out[i], entry)
+	}
+	return out
+}
+
+// processShards splits the listings into shards and processes them
+// in parallel, returning the jobs from all of them
+func (m *March) processShards(job listDirJob, srcList, dstList fs.DirEntries, shards int) ([]listDirJob, error) {
+	fs.Debugf(job.srcRemote, "Splitting directory with %d entries into %d shards", len(srcList)+len(dstList), shards)
+	srcShards := m.shardEntries(srcList, shards)
+	dstShards := m.shardEntries(dstList, shards)
+	var (
+		mu   sync.Mutex // protects jobs and err
+		jobs []listDirJob
+		err  error
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < shards; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			shardJobs, shardErr := m.processEntries(job, srcShards[i], dstShards[i])
+			mu.Lock()
+			jobs = append(jobs, shardJobs...)
+			if err == nil {
+				err = shardErr
+			}
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+	return jobs, err
+}
+
+// processEntries matches up the srcList and dstList from the
+// directories in job, calling the callbacks and returning the jobs
+// for any directories that need recursing into
+func (m *March) processEntries(job listDirJob, srcList, dstList fs.DirEntries) ([]listDirJob, error) {
+	var jobs []listDirJob
+
 	// If NoTraverse is set, then try to find a matching object
 	// for each item in the srcList
 	if m.NoTraverse && !m.NoCheckDest {
@@ -282,6 +282,133 @@ func TestMarchNoTraverse(t *testing.T) {
 	}
 }
 
+func TestMarchSharded(t *testing.T) {
+	oldThreshold := fs.Config.DirShardThreshold
+	fs.Config.DirShardThreshold = 4
+	defer func() { fs.Config.DirShardThreshold = oldThreshold }()
+
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var srcOnly, dstOnly, match []fstest.Item
+	for i := 0; i < 10; i++ {
+		srcOnly = append(srcOnly, r.WriteFile(fmt.Sprintf("srcOnly%d", i), "hello world", t1))
+		dstOnly = append(dstOnly, r.WriteObject(ctx, fmt.Sprintf("dstOnly%d", i), "hello world", t1))
+		match = append(match, r.WriteBoth(ctx, fmt.Sprintf("match%d", i), "hello world", t1))
+	}
+	match = append(match, r.WriteBoth(ctx, "matchDir/match file", "hello world", t1))
+
+	mt := &marchTester{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	m := &March{
+		Ctx:      ctx,
+		Fdst:     r.Fremote,
+		Fsrc:     r.Flocal,
+		Callback: mt,
+	}
+	mt.processError(m.Run())
+	mt.cancel()
+	require.NoError(t, mt.currentError())
+
+	precision := fs.GetModifyWindow(r.Fremote, r.Flocal)
+	fstest.CompareItems(t, mt.srcOnly, srcOnly, nil, precision, "srcOnly")
+	fstest.CompareItems(t, mt.dstOnly, dstOnly, nil, precision, "dstOnly")
+	fstest.CompareItems(t, mt.match, match, []string{"matchDir"}, precision, "match")
+}
+
+func TestMarchPlannerMemoryLimit(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	ctx := context.Background()
+
+	var items []fstest.Item
+	for i := 0; i < 10; i++ {
+		items = append(items, r.WriteBoth(ctx, fmt.Sprintf("file%d", i), "hello world", t1))
+	}
+	// each file is listed in both the source and the destination
+	need := 2 * int64(len(items)) * (entryOverhead + 2*int64(len("file0")))
+
+	oldLimit := fs.Config.PlannerMemoryLimit
+	defer func() { fs.Config.PlannerMemoryLimit = oldLimit }()
+	for _, test := range []struct {
+		limit   int64
+		wantErr bool
+	}{
+		{0, false},
+		{need, false},
+		{need - 1, true},
+	} {
+		fs.Config.PlannerMemoryLimit = fs.SizeSuffix(test.limit)
+		mt := &marchTester{ctx: ctx}
+		m := &March{
+			Ctx:      ctx,
+			Fdst:     r.Fremote,
+			Fsrc:     r.Flocal,
+			Callback: mt,
+		}
+		err := m.Run()
+		assert.Equal(t, int64(0), m.memory.current)
+		if test.wantErr {
+			require.Error(t, err)
+			assert.True(t, fserrors.IsFatalError(err))
+			assert.Contains(t, err.Error(), "--planner-memory-limit")
+			assert.Equal(t, 0, len(mt.match))
+		} else {
+			require.NoError(t, err)
+			assert.Equal(t, need, m.memory.peak)
+			assert.Equal(t, len(items), len(mt.match))
+		}
+	}
+}
+
+func TestMarchNumShards(t *testing.T) {
+	oldThreshold, oldCheckers := fs.Config.DirShardThreshold, fs.Config.Checkers
+	defer func() { fs.Config.DirShardThreshold, fs.Config.Checkers = oldThreshold, oldCheckers }()
+	fs.Config.Checkers = 8
+	m := &March{}
+	for _, test := range []struct {
+		threshold int
+		entries   int
+		want      int
+	}{
+		{0, 1000, 1},
+		{100, 100, 1},
+		{100, 101, 2},
+		{100, 350, 4},
+		{100, 100000, 8},
+	} {
+		fs.Config.DirShardThreshold = test.threshold
+		assert.Equal(t, test.want, m.numShards(test.entries), fmt.Sprintf("%+v", test))
+	}
+}
+
+func TestMarchShardEntries(t *testing.T) {
+	var (
+		a = mockobject.Object("path/a")
+		A = mockobject.Object("path/A")
+		B = mockobject.Object("path/B")
+		c = mockobject.Object("path/c")
+	)
+	m := &March{transforms: []matchTransformFn{strings.ToLower}}
+	shards := m.shardEntries(fs.DirEntries{a, A, B, c}, 3)
+	require.Equal(t, 3, len(shards))
+	total := 0
+	for _, shard := range shards {
+		total += len(shard)
+		// entries which match each other must be in the same shard
+		var hasa, hasA bool
+		for _, entry := range shard {
+			hasa = hasa || entry == a
+			hasA = hasA || entry == A
+		}
+		assert.Equal(t, hasa, hasA)
+	}
+	assert.Equal(t, 4, total)
+}
+
 func TestNewMatchEntries(t *testing.T) {
 	var (
 		a = mockobject.Object("path/a")
@@ -0,0 +1,103 @@
+package march
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+)
+
+// entryOverhead is the approximate memory used by a listed DirEntry
+// not counting its name.  This is the size of a typical backend
+// Object along with its interface in the listing.
+const entryOverhead = 160
+
+// entryMemory returns the approximate memory used by entry while it
+// is held in a listing.  The name is usually stored twice, as the
+// remote and as the path on the backend.
+func entryMemory(entry fs.DirEntry) int64 {
+	return entryOverhead + 2*int64(len(entry.Remote()))
+}
+
+// entriesMemory returns the approximate memory used by entries
+func entriesMemory(entries fs.DirEntries) (total int64) {
+	for _, entry := range entries {
+		total += entryMemory(entry)
+	}
+	return total
+}
+
+// memoryAccount keeps track of the approximate memory used by the
+// listings the march holds to see if it fits in
+// --planner-memory-limit
+type memoryAccount struct {
+	mu      sync.Mutex
+	limit   int64 // limit or 0 for no limit
+	current int64 // memory in use now
+	peak    int64 // most memory in use at once
+	err     error // set if the limit was exceeded
+}
+
+// newMemoryAccount makes a memoryAccount using --planner-memory-limit
+func newMemoryAccount() *memoryAccount {
+	return &memoryAccount{
+		limit: int64(fs.Config.PlannerMemoryLimit),
+	}
+}
+
+// reserve accounts n more bytes of memory, returning an error if
+// this would take it over the limit
+func (ma *memoryAccount) reserve(n int64) error {
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+	if ma.err != nil {
+		return ma.err
+	}
+	if ma.limit > 0 && ma.current+n > ma.limit {
+		ma.err = fserrors.FatalError(errors.Errorf("sync planner would need more than --planner-memory-limit %v of memory to hold the listings (about %v) - %s", fs.SizeSuffix(ma.limit), fs.SizeSuffix(ma.current+n), lowMemoryAdvice()))
+		return ma.err
+	}
+	ma.current += n
+	if ma.current > ma.peak {
+		ma.peak = ma.current
+	}
+	return nil
+}
+
+// release returns n bytes of memory reserved with reserve
+func (ma *memoryAccount) release(n int64) {
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+	ma.current -= n
+}
+
+// exceeded returns the error if the limit has been exceeded
+func (ma *memoryAccount) exceeded() error {
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+	return ma.err
+}
+
+// report logs the peak memory used
+func (ma *memoryAccount) report() {
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+	if ma.limit > 0 {
+		fs.Infof(nil, "Sync planner used about %v of memory at peak (limit %v)", fs.SizeSuffix(ma.peak), fs.SizeSuffix(ma.limit))
+	} else {
+		fs.Debugf(nil, "Sync planner used about %v of memory at peak", fs.SizeSuffix(ma.peak))
+	}
+}
+
+// lowMemoryAdvice returns how the user can make the planner hold
+// fewer listings in memory at once
+func lowMemoryAdvice() string {
+	if fs.Config.UseListR {
+		return "try without --fast-list which holds the whole listing in memory"
+	}
+	if !fs.Config.NoTraverse {
+		return "try --no-traverse to avoid listing the destination, or sync the largest directories separately"
+	}
+	return "try syncing the largest directories separately"
+}
@@ -0,0 +1,50 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ModTimeFallback describes what to do when the modification time
+// can't be set on an object in the destination
+type ModTimeFallback byte
+
+// ModTimeFallback constants
+const (
+	ModTimeFallbackUpload ModTimeFallback = iota
+	ModTimeFallbackMetadata
+	ModTimeFallbackIgnore
+	ModTimeFallbackDefault = ModTimeFallbackUpload
+)
+
+var modTimeFallbackToString = []string{
+	ModTimeFallbackUpload:   "upload",
+	ModTimeFallbackMetadata: "metadata",
+	ModTimeFallbackIgnore:   "ignore",
+}
+
+// String turns a ModTimeFallback into a string
+func (m ModTimeFallback) String() string {
+	if m >= ModTimeFallback(len(modTimeFallbackToString)) {
+		return fmt.Sprintf("ModTimeFallback(%d)", m)
+	}
+	return modTimeFallbackToString[m]
+}
+
+// Set a ModTimeFallback
+func (m *ModTimeFallback) Set(s string) error {
+	for n, name := range modTimeFallbackToString {
+		if s != "" && name == strings.ToLower(s) {
+			*m = ModTimeFallback(n)
+			return nil
+		}
+	}
+	return errors.Errorf("Unknown modtime fallback %q", s)
+}
+
+// Type of the value
+func (m *ModTimeFallback) Type() string {
+	return "string"
+}
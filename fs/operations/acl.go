@@ -0,0 +1,81 @@
+package operations
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+)
+
+// aclers returns the ACLers of src and dst or false if they don't
+// both support ACLs
+func aclers(src fs.ObjectInfo, dst fs.Object) (srcACLer, dstACLer fs.ACLer, ok bool) {
+	srcObj, ok := src.(fs.Object)
+	if !ok {
+		return nil, nil, false
+	}
+	srcACLer, ok = fs.UnWrapObject(srcObj).(fs.ACLer)
+	if !ok {
+		return nil, nil, false
+	}
+	dstACLer, ok = fs.UnWrapObject(dst).(fs.ACLer)
+	return srcACLer, dstACLer, ok
+}
+
+// SyncACL copies the access control list of src to dst if
+// --sync-metadata is set and they both support ACLs.
+//
+// If dst can't represent all the grants of src then only the grants
+// which mean the same on any backend are copied and the others are
+// logged.
+//
+// Errors are logged and counted.
+func SyncACL(ctx context.Context, src fs.ObjectInfo, dst fs.Object) {
+	if !fs.Config.SyncMetadata || dst == nil {
+		return
+	}
+	err := syncACL(ctx, src, dst)
+	if err != nil {
+		err = fs.CountError(err)
+		fs.Errorf(dst, "Failed to copy ACL: %v", err)
+	}
+}
+
+// syncACL does the work of SyncACL
+func syncACL(ctx context.Context, src fs.ObjectInfo, dst fs.Object) error {
+	srcACLer, dstACLer, ok := aclers(src, dst)
+	if !ok {
+		fs.Debugf(dst, "Not copying ACL as the source and destination don't both support ACLs")
+		return nil
+	}
+	srcACL, err := srcACLer.ACL(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to read source ACL")
+	}
+	dstACL, err := dstACLer.ACL(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to read destination ACL")
+	}
+	if srcACL.Equal(dstACL) {
+		fs.Debugf(dst, "ACL unchanged")
+		return nil
+	}
+	if SkipDestructive(ctx, dst, "update ACL") {
+		return nil
+	}
+	err = dstACLer.SetACL(ctx, srcACL)
+	if errors.Cause(err) == fs.ErrorACLUnrepresentable {
+		portable, other := srcACL.Portable()
+		fs.Logf(dst, "Not copying the ACL grants %v as the destination can't represent them: %v", other, err)
+		if portable.Equal(dstACL) {
+			return nil
+		}
+		srcACL = portable
+		err = dstACLer.SetACL(ctx, srcACL)
+	}
+	if err != nil {
+		return err
+	}
+	fs.Infof(dst, "Updated ACL to %v", srcACL)
+	return nil
+}
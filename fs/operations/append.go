@@ -0,0 +1,87 @@
+package operations
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+)
+
+// AppendFile brings dstFileName in fdst up to date with srcFileName
+// in fsrc by appending the data from the source which is past the end
+// of the destination, for sources such as log files which only grow.
+//
+// The size of the destination is the offset the last append finished
+// at, so only the new data is read and transferred.  If the
+// destination doesn't exist the source is copied.
+//
+// It returns an error if the destination is bigger than the source,
+// or if it changes size while the data is being appended, as then
+// something other than AppendFile has written to it.
+func AppendFile(ctx context.Context, fdst fs.Fs, fsrc fs.Fs, dstFileName string, srcFileName string) (err error) {
+	src, err := fsrc.NewObject(ctx, srcFileName)
+	if err != nil {
+		return err
+	}
+	dst, err := fdst.NewObject(ctx, dstFileName)
+	if err == fs.ErrorObjectNotFound {
+		_, err = Copy(ctx, fdst, nil, dstFileName, src)
+		return err
+	} else if err != nil {
+		return err
+	}
+	do, ok := dst.(fs.Appender)
+	if !ok {
+		return errors.Errorf("can't append to %v: backend doesn't support appending", fdst)
+	}
+	offset := dst.Size()
+	if offset < 0 || src.Size() < 0 {
+		return errors.New("can't append with unknown object sizes")
+	}
+	if src.Size() < offset {
+		err = errors.Wrapf(fs.ErrorAppendConflict, "destination is %d bytes but source is only %d bytes - source truncated or destination written to", offset, src.Size())
+		fs.Errorf(dst, "Failed to append: %v", err)
+		return fs.CountError(err)
+	}
+	if src.Size() == offset {
+		fs.Debugf(src, "Nothing to append")
+		return nil
+	}
+	if SkipDestructive(ctx, src, "append") {
+		return nil
+	}
+
+	// Only account the data being appended
+	size := src.Size() - offset
+	tr := accounting.Stats(ctx).NewTransferRemoteSize(dstFileName, size)
+	defer func() {
+		tr.Done(err)
+	}()
+	var options []fs.OpenOption
+	for _, option := range fs.Config.DownloadHeaders {
+		options = append(options, option)
+	}
+	options = append(options, &fs.RangeOption{Start: offset, End: src.Size() - 1})
+	in, err := src.Open(ctx, options...)
+	if err != nil {
+		err = errors.Wrap(err, "failed to open source object")
+		fs.Errorf(src, "Failed to append: %v", err)
+		return fs.CountError(err)
+	}
+	acc := tr.Account(ctx, in).WithBuffer()
+	err = do.Append(ctx, acc, offset, src)
+	closeErr := acc.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil && dst.Size() != src.Size() {
+		err = errors.Errorf("corrupted on transfer: sizes differ %d vs %d after append", src.Size(), dst.Size())
+	}
+	if err != nil {
+		fs.Errorf(dst, "Failed to append: %v", err)
+		return fs.CountError(err)
+	}
+	fs.Infof(src, "Appended %d bytes at offset %d", size, offset)
+	return nil
+}
@@ -0,0 +1,110 @@
+package operations
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/lib/cdc"
+)
+
+// ChunkStats is the result of ChunkFingerprints
+type ChunkStats struct {
+	Files        int64 `json:"files"`        // number of files read
+	Bytes        int64 `json:"bytes"`        // total size of the files
+	Chunks       int64 `json:"chunks"`       // number of chunks
+	UniqueChunks int64 `json:"uniqueChunks"` // number of distinct chunks
+	UniqueBytes  int64 `json:"uniqueBytes"`  // total size of the distinct chunks
+}
+
+// ChunkFingerprints reads all the objects in f, splits them into
+// content defined chunks according to params and fingerprints each
+// chunk with SHA-256 to show how much data could be saved by block
+// level deduplication.
+//
+// If w is not nil a line is written to it for each chunk with the
+// fingerprint in hex, the offset and length of the chunk and the
+// remote it is in.
+func ChunkFingerprints(ctx context.Context, f fs.Fs, params cdc.Params, w io.Writer) (stats ChunkStats, err error) {
+	if err := params.Check(); err != nil {
+		return stats, err
+	}
+	var (
+		mu      sync.Mutex // protects stats and seen
+		seen    = map[[sha256.Size]byte]struct{}{}
+		errs    int
+		lastErr error
+	)
+	err = ListFn(ctx, f, func(o fs.Object) {
+		var err error
+		tr := accounting.Stats(ctx).NewTransfer(o)
+		defer func() {
+			tr.Done(err)
+		}()
+		err = chunkObject(ctx, o, params, tr, func(chunk cdc.Chunk) {
+			sum := sha256.Sum256(chunk.Data)
+			mu.Lock()
+			defer mu.Unlock()
+			stats.Chunks++
+			if _, found := seen[sum]; !found {
+				seen[sum] = struct{}{}
+				stats.UniqueChunks++
+				stats.UniqueBytes += int64(len(chunk.Data))
+			}
+			if w != nil {
+				syncFprintf(w, "%s  %d  %d  %s\n", hex.EncodeToString(sum[:]), chunk.Offset, len(chunk.Data), o.Remote())
+			}
+		})
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			err = fs.CountError(err)
+			fs.Errorf(o, "Failed to fingerprint chunks: %v", err)
+			errs++
+			lastErr = err
+			return
+		}
+		stats.Files++
+		stats.Bytes += o.Size()
+	})
+	if err != nil {
+		return stats, err
+	}
+	if errs > 0 {
+		return stats, errors.Wrapf(lastErr, "failed to read %d files, last error", errs)
+	}
+	return stats, nil
+}
+
+// chunkObject reads o and calls fn for each chunk of it
+func chunkObject(ctx context.Context, o fs.Object, params cdc.Params, tr *accounting.Transfer, fn func(cdc.Chunk)) (err error) {
+	var options []fs.OpenOption
+	for _, option := range fs.Config.DownloadHeaders {
+		options = append(options, option)
+	}
+	in, err := NewReOpen(ctx, o, fs.Config.LowLevelRetries, options...)
+	if err != nil {
+		return errors.Wrap(err, "failed to open")
+	}
+	acc := tr.Account(ctx, in).WithBuffer()
+	defer fs.CheckClose(acc, &err)
+	chunker, err := cdc.New(acc, params)
+	if err != nil {
+		return err
+	}
+	for {
+		var chunk cdc.Chunk
+		chunk, err = chunker.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "failed to read")
+		}
+		fn(chunk)
+	}
+}
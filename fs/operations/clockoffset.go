@@ -0,0 +1,78 @@
+package operations
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/lib/random"
+)
+
+// clockOffsetModTime is the modification time the test object is
+// uploaded with. It is far enough from now that a backend keeping it
+// can't be mistaken for one using its own clock.
+var clockOffsetModTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ClockOffsetInfo is the result of ClockOffset
+type ClockOffsetInfo struct {
+	Known       bool    `json:"known"`            // set if the offset could be measured
+	Offset      float64 `json:"offset"`           // seconds the remote clock is ahead of the local clock
+	Uncertainty float64 `json:"uncertainty"`      // the offset is only known to +/- this many seconds
+	Reason      string  `json:"reason,omitempty"` // why the offset is unknown
+}
+
+// ClockOffset measures how far the clock of the remote f is from the
+// local clock by uploading a small object and reading back the
+// modification time the remote gives it.
+//
+// This only works on remotes which set the modification time of new
+// objects from their own clock. If the remote keeps the modification
+// time rclone uploads with then the offset is reported as unknown.
+func ClockOffset(ctx context.Context, f fs.Fs) (info ClockOffsetInfo, err error) {
+	remote := ".rclone-clock-offset-" + random.String(8)
+	if SkipDestructive(ctx, fs.LogDirName(f, remote), "measure clock offset") {
+		return info, errors.New("can't measure the clock offset with --dry-run")
+	}
+	data := []byte("rclone clock offset\n")
+	src := object.NewStaticObjectInfo(remote, clockOffsetModTime, int64(len(data)), true, nil, nil)
+	before := time.Now()
+	uploaded, err := f.Put(ctx, bytes.NewReader(data), src)
+	after := time.Now()
+	if err != nil {
+		return info, errors.Wrap(err, "failed to upload test object")
+	}
+	defer func() {
+		removeErr := uploaded.Remove(ctx)
+		if removeErr != nil {
+			fs.Errorf(uploaded, "Failed to remove test object: %v", removeErr)
+			if err == nil {
+				err = removeErr
+			}
+		}
+	}()
+	// Read the object again so we see what the remote stored
+	o, err := f.NewObject(ctx, remote)
+	if err != nil {
+		return info, errors.Wrap(err, "failed to read test object")
+	}
+	modTime := o.ModTime(ctx)
+	precision := f.Precision()
+	if precision == fs.ModTimeNotSupported || precision < time.Second {
+		// The time the remote assigns may be truncated to
+		// seconds whatever the stored precision is
+		precision = time.Second
+	}
+	if dt := modTime.Sub(clockOffsetModTime); dt > -precision && dt < precision {
+		info.Reason = "the remote keeps the modification time uploaded with so doesn't expose its clock"
+		return info, nil
+	}
+	// The remote stamped the object some time between before and after
+	mid := before.Add(after.Sub(before) / 2)
+	info.Known = true
+	info.Offset = modTime.Sub(mid).Seconds()
+	info.Uncertainty = (after.Sub(before)/2 + precision).Seconds()
+	return info, nil
+}
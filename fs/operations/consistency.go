@@ -0,0 +1,116 @@
+package operations
+
+import (
+	"context"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// recentWrites remembers the objects uploaded within the
+// --consistency-window so they aren't uploaded again if they don't
+// show up in a listing of an eventually consistent remote yet.
+var recentWrites = struct {
+	mu        sync.Mutex
+	objects   map[string]recentWrite
+	lastPrune time.Time
+}{
+	objects: map[string]recentWrite{},
+}
+
+// recentWrite is an object which was recently uploaded
+type recentWrite struct {
+	when    time.Time // when it was uploaded
+	size    int64     // size of the source
+	modTime time.Time // modification time of the source
+}
+
+// recentWriteKey returns the key for remote in f in recentWrites
+func recentWriteKey(f fs.Fs, remote string) string {
+	return f.Name() + ":" + path.Join(f.Root(), remote)
+}
+
+// consistencyWindow returns the --consistency-window to use for f
+// or 0 if f doesn't need one.
+func consistencyWindow(f fs.Fs) time.Duration {
+	if f.Features().IsLocal {
+		return 0
+	}
+	return fs.Config.ConsistencyWindow
+}
+
+// waitForConsistency is called when src has been uploaded to f as
+// remote.
+//
+// If --consistency-window is set this remembers the upload and polls
+// until the object can be found or the window has passed.  Not
+// finding the object isn't an error as it may still appear later.
+func waitForConsistency(ctx context.Context, f fs.Fs, remote string, src fs.ObjectInfo) {
+	window := consistencyWindow(f)
+	if window <= 0 {
+		return
+	}
+	now := time.Now()
+	recentWrites.mu.Lock()
+	recentWrites.objects[recentWriteKey(f, remote)] = recentWrite{
+		when:    now,
+		size:    src.Size(),
+		modTime: src.ModTime(ctx),
+	}
+	if now.Sub(recentWrites.lastPrune) > window {
+		for key, write := range recentWrites.objects {
+			if now.Sub(write.when) > window {
+				delete(recentWrites.objects, key)
+			}
+		}
+		recentWrites.lastPrune = now
+	}
+	recentWrites.mu.Unlock()
+
+	sleep := 100 * time.Millisecond
+	for {
+		_, err := f.NewObject(ctx, remote)
+		if err == nil {
+			fs.Debugf(fs.LogDirName(f, remote), "Object visible after %v", time.Since(now))
+			return
+		}
+		if time.Since(now)+sleep > window {
+			fs.Debugf(fs.LogDirName(f, remote), "Object not visible after %v: %v", time.Since(now), err)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(sleep):
+		}
+		sleep *= 2
+	}
+}
+
+// RecentlyWritten returns true if src was uploaded to remote in f
+// within the --consistency-window so might not show up in listings
+// yet.
+func RecentlyWritten(ctx context.Context, f fs.Fs, remote string, src fs.ObjectInfo) bool {
+	window := consistencyWindow(f)
+	if window <= 0 {
+		return false
+	}
+	key := recentWriteKey(f, remote)
+	recentWrites.mu.Lock()
+	write, found := recentWrites.objects[key]
+	if found && time.Since(write.when) > window {
+		delete(recentWrites.objects, key)
+		found = false
+	}
+	recentWrites.mu.Unlock()
+	if !found || write.size != src.Size() {
+		return false
+	}
+	dt := write.modTime.Sub(src.ModTime(ctx))
+	if dt < 0 {
+		dt = -dt
+	}
+	return dt <= fs.GetModifyWindow(f, src.Fs())
+}
@@ -0,0 +1,202 @@
+package operations
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/atexit"
+)
+
+// deferredModTime is a modification time waiting to be set by the
+// --defer-modtime pass
+type deferredModTime struct {
+	src      fs.Object // the object the modification time came from
+	dst      fs.Object // the object to set it on
+	modTime  time.Time // the modification time to set
+	reupload bool      // set if dst should be uploaded again if the backend can't set it
+}
+
+// DeferredModTimes collects the modification times to set on the
+// destination for --defer-modtime so they can be set in a batch after
+// the transfers instead of in between them.
+type DeferredModTimes struct {
+	f            fs.Fs // the destination
+	mu           sync.Mutex
+	pending      map[string]deferredModTime // keyed by remote
+	atexitHandle atexit.FnHandle
+}
+
+// NewDeferredModTimes makes a DeferredModTimes for the destination f.
+//
+// If rclone is interrupted before Apply has finished the files whose
+// modification time hasn't been set are logged.
+func NewDeferredModTimes(f fs.Fs) *DeferredModTimes {
+	d := &DeferredModTimes{
+		f:       f,
+		pending: map[string]deferredModTime{},
+	}
+	d.atexitHandle = atexit.Register(func() {
+		d.reportPending("rclone was interrupted before the --defer-modtime pass")
+	})
+	return d
+}
+
+// deferredModTimesKey is the context key for the DeferredModTimes
+type deferredModTimesKey struct{}
+
+// WithDeferredModTimes returns a context which makes the operations
+// using it add the modification times they would set to d rather
+// than setting them straight away.
+func WithDeferredModTimes(ctx context.Context, d *DeferredModTimes) context.Context {
+	return context.WithValue(ctx, deferredModTimesKey{}, d)
+}
+
+// deferredModTimes returns the DeferredModTimes in ctx or nil if
+// modification times aren't being deferred
+func deferredModTimes(ctx context.Context) *DeferredModTimes {
+	d, _ := ctx.Value(deferredModTimesKey{}).(*DeferredModTimes)
+	return d
+}
+
+// add queues setting the modification time of dst to modTime.
+//
+// If reupload is set then src is uploaded to dst again if the backend
+// can't set the modification time without uploading it.
+func (d *DeferredModTimes) add(src, dst fs.Object, modTime time.Time, reupload bool) {
+	fs.Debugf(dst, "Deferring setting modification time to %v", modTime)
+	d.mu.Lock()
+	d.pending[dst.Remote()] = deferredModTime{
+		src:      src,
+		dst:      dst,
+		modTime:  modTime,
+		reupload: reupload,
+	}
+	d.mu.Unlock()
+}
+
+// done removes item from the pending modification times
+func (d *DeferredModTimes) done(item deferredModTime) {
+	d.mu.Lock()
+	if d.pending[item.dst.Remote()].dst == item.dst {
+		delete(d.pending, item.dst.Remote())
+	}
+	d.mu.Unlock()
+}
+
+// reportPending logs the files whose modification time hasn't been
+// set because of reason and returns how many there are
+func (d *DeferredModTimes) reportPending(reason string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	remotes := make([]string, 0, len(d.pending))
+	for remote := range d.pending {
+		remotes = append(remotes, remote)
+	}
+	sort.Strings(remotes)
+	for _, remote := range remotes {
+		fs.Errorf(d.pending[remote].dst, "Modification time not set as %s", reason)
+	}
+	return len(remotes)
+}
+
+// Apply sets the modification times collected, running
+// --checkers of them at once.
+//
+// If ctx is cancelled before they are all set the files which haven't
+// been set are logged and the error of ctx returned.
+func (d *DeferredModTimes) Apply(ctx context.Context) error {
+	defer atexit.Unregister(d.atexitHandle)
+	d.mu.Lock()
+	items := make([]deferredModTime, 0, len(d.pending))
+	for _, item := range d.pending {
+		items = append(items, item)
+	}
+	d.mu.Unlock()
+	if len(items) == 0 {
+		return nil
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].dst.Remote() < items[j].dst.Remote()
+	})
+	fs.Infof(d.f, "Setting the modification times of %d files", len(items))
+
+	var (
+		wg       sync.WaitGroup
+		errorsMu sync.Mutex
+		errCount int
+		toBeSet  = make(chan deferredModTime, fs.Config.Checkers)
+	)
+	wg.Add(fs.Config.Checkers)
+	for i := 0; i < fs.Config.Checkers; i++ {
+		go func() {
+			defer wg.Done()
+			for item := range toBeSet {
+				if ctx.Err() != nil {
+					// Leave it pending so it gets reported
+					continue
+				}
+				err := d.set(ctx, item)
+				if err != nil {
+					errorsMu.Lock()
+					errCount++
+					errorsMu.Unlock()
+				}
+				d.done(item)
+			}
+		}()
+	}
+outer:
+	for _, item := range items {
+		select {
+		case <-ctx.Done():
+			break outer
+		case toBeSet <- item:
+		}
+	}
+	close(toBeSet)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		n := d.reportPending("the --defer-modtime pass was interrupted")
+		fs.Errorf(d.f, "Modification time not set on %d files as the --defer-modtime pass was interrupted", n)
+		return ctx.Err()
+	}
+	if errCount > 0 {
+		return errors.Errorf("failed to set modification time on %d files", errCount)
+	}
+	return nil
+}
+
+// set the modification time of item.
+//
+// If the backend can't set it then it is stored in the metadata if
+// --modtime-fallback is set, or if the file was found to be identical
+// apart from the modification time, uploaded again.
+func (d *DeferredModTimes) set(ctx context.Context, item deferredModTime) error {
+	err := item.dst.SetModTime(ctx, item.modTime)
+	switch err {
+	case nil:
+		fs.Infof(item.dst, "Updated modification time in destination")
+		return nil
+	case fs.ErrorCantSetModTime, fs.ErrorCantSetModTimeWithoutDelete:
+		if modTimeFallback(ctx, item.dst, item.modTime) || !item.reupload {
+			return nil
+		}
+		fs.Debugf(item.dst, "Can't set mod time without re-uploading so uploading again")
+		if err == fs.ErrorCantSetModTimeWithoutDelete && fs.Config.BackupDir == "" {
+			err = item.dst.Remove(ctx)
+			if err != nil {
+				fs.Errorf(item.dst, "failed to delete before re-upload: %v", err)
+			}
+		}
+		_, err = Copy(ctx, d.f, item.dst, item.dst.Remote(), item.src)
+		return err
+	}
+	err = fs.CountError(err)
+	fs.Errorf(item.dst, "Failed to set modification time: %v", err)
+	return err
+}
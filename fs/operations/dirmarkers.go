@@ -0,0 +1,34 @@
+package operations
+
+import (
+	"context"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+)
+
+// DeleteDirMarker deletes the directory marker object of dir in f if
+// it has one, respecting --dry-run and --interactive.
+//
+// If backupDir is set the marker will be placed into that directory
+// instead of being deleted.
+func DeleteDirMarker(ctx context.Context, f fs.Fs, dir string, backupDir fs.Fs) error {
+	do, ok := f.(fs.DirMarkerer)
+	if !ok {
+		return nil
+	}
+	marker, err := do.DirMarker(ctx, dir)
+	if err == fs.ErrorObjectNotFound {
+		return nil
+	}
+	if err != nil {
+		err = fs.CountError(err)
+		fs.Errorf(fs.LogDirName(f, dir), "Failed to read directory marker: %v", err)
+		return err
+	}
+	err = DeleteFileWithBackupDir(ctx, marker, backupDir)
+	if err == nil && !fs.Config.DryRun {
+		accounting.Stats(ctx).DirMarkers(1)
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package operations
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/fspath"
+)
+
+// illegalCharsRename is a line of the --illegal-chars-manifest
+type illegalCharsRename struct {
+	Remote   string `json:"remote"`   // where the file or directory was stored
+	Original string `json:"original"` // where it would have been stored
+}
+
+// illegalCharsManifestMu serialises writes to the
+// --illegal-chars-manifest
+var illegalCharsManifestMu sync.Mutex
+
+// recordIllegalCharsRename appends the change of remote in f to
+// newRemote to the --illegal-chars-manifest if set
+func recordIllegalCharsRename(f fs.Fs, remote, newRemote string) {
+	if fs.Config.IllegalCharsManifest == "" {
+		return
+	}
+	data, err := json.Marshal(illegalCharsRename{
+		Remote:   fspath.JoinRootPath(fs.ConfigString(f), newRemote),
+		Original: fspath.JoinRootPath(fs.ConfigString(f), remote),
+	})
+	if err != nil {
+		fs.Errorf(nil, "Failed to make --illegal-chars-manifest entry: %v", err)
+		return
+	}
+	illegalCharsManifestMu.Lock()
+	defer illegalCharsManifestMu.Unlock()
+	out, err := os.OpenFile(fs.Config.IllegalCharsManifest, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err == nil {
+		_, err = out.Write(append(data, '\n'))
+		closeErr := out.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fs.Errorf(nil, "Failed to write --illegal-chars-manifest: %v", err)
+	}
+}
+
+// illegalCharsRemote returns the name remote should be stored under
+// on f according to --illegal-chars, recording it in the
+// --illegal-chars-manifest if it is changed.
+func illegalCharsRemote(f fs.Fs, remote string) (string, error) {
+	newRemote, err := fs.IllegalCharsRemote(f, remote)
+	if err != nil {
+		return remote, fserrors.NoRetryError(err)
+	}
+	if newRemote != remote {
+		fs.Infof(fs.LogDirName(f, remote), "Storing as %q as it has characters which are illegal on the destination", newRemote)
+		recordIllegalCharsRename(f, remote, newRemote)
+	}
+	return newRemote, nil
+}
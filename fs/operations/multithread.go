@@ -51,14 +51,19 @@ type multiThreadCopyState struct {
 	src      fs.Object
 	acc      *accounting.Account
 	streams  int
+
+	checkpoint *multiThreadCheckpoint // progress of the streams if resumable
 }
 
 // Copy a single stream into place
 func (mc *multiThreadCopyState) copyStream(ctx context.Context, stream int) (err error) {
+	ctx, span := accounting.StartSpan(ctx, "chunk")
+	span.SetAttribute("rclone.stream", stream+1)
 	defer func() {
 		if err != nil {
 			fs.Debugf(mc.src, "multi-thread copy: stream %d/%d failed: %v", stream+1, mc.streams, err)
 		}
+		span.End(err)
 	}()
 	start := int64(stream) * mc.partSize
 	if start >= mc.size {
@@ -68,8 +73,18 @@ func (mc *multiThreadCopyState) copyStream(ctx context.Context, stream int) (err
 	if end > mc.size {
 		end = mc.size
 	}
+	streamStart := start
+	if mc.checkpoint != nil {
+		start += mc.checkpoint.streamDone(stream)
+		if start >= end {
+			fs.Debugf(mc.src, "multi-thread copy: stream %d/%d (%d-%d) already copied", stream+1, mc.streams, streamStart, end)
+			return nil
+		}
+	}
 
 	fs.Debugf(mc.src, "multi-thread copy: stream %d/%d (%d-%d) size %v starting", stream+1, mc.streams, start, end, fs.SizeSuffix(end-start))
+	span.SetAttribute("rclone.offset", start)
+	span.SetAttribute("rclone.size", end-start)
 
 	rc, err := NewReOpen(ctx, mc.src, fs.Config.LowLevelRetries, &fs.RangeOption{Start: start, End: end - 1})
 	if err != nil {
@@ -94,6 +109,9 @@ func (mc *multiThreadCopyState) copyStream(ctx context.Context, stream int) (err
 			nw, ew := mc.wc.WriteAt(buf[0:nr], offset)
 			if nw > 0 {
 				offset += int64(nw)
+				if mc.checkpoint != nil {
+					mc.checkpoint.progress(stream, offset-streamStart)
+				}
 			}
 			if ew != nil {
 				return errors.Wrap(ew, "multpart copy: write failed")
@@ -158,12 +176,29 @@ func multiThreadCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object,
 	mc.calculateChunks()
 
 	// Make accounting
-	mc.acc = tr.Account(nil)
+	mc.acc = tr.Account(ctx, nil)
+
+	// With --partial-cleanup resume carry on from an interrupted copy
+	if fs.Config.PartialCleanup == fs.PartialCleanupResume {
+		mc.checkpoint = newMultiThreadCheckpoint(ctx, f, remote, src, mc.partSize, mc.streams)
+		if resumer, ok := f.(fs.ResumeWriterAter); ok && mc.checkpoint.load() {
+			mc.wc, err = resumer.ResumeWriterAt(gCtx, remote, mc.size)
+			if err != nil {
+				fs.Debugf(src, "multi-thread copy: can't resume: %v", err)
+				mc.checkpoint.reset()
+				mc.wc = nil
+			} else {
+				fs.Infof(src, "Resuming multi-thread copy with %v already copied", fs.SizeSuffix(mc.checkpoint.total()))
+			}
+		}
+	}
 
 	// create write file handle
-	mc.wc, err = openWriterAt(gCtx, remote, mc.size)
-	if err != nil {
-		return nil, errors.Wrap(err, "multpart copy: failed to open destination")
+	if mc.wc == nil {
+		mc.wc, err = openWriterAt(gCtx, remote, mc.size)
+		if err != nil {
+			return nil, errors.Wrap(err, "multpart copy: failed to open destination")
+		}
 	}
 
 	fs.Debugf(src, "Starting multi-thread copy with %d parts of size %v", mc.streams, fs.SizeSuffix(mc.partSize))
@@ -175,6 +210,13 @@ func multiThreadCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object,
 	}
 	err = g.Wait()
 	closeErr := mc.wc.Close()
+	if mc.checkpoint != nil {
+		if err != nil || closeErr != nil {
+			mc.checkpoint.save()
+		} else {
+			mc.checkpoint.remove()
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -187,11 +229,15 @@ func multiThreadCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object,
 		return nil, errors.Wrap(err, "multi-thread copy: failed to find object after copy")
 	}
 
-	err = obj.SetModTime(ctx, src.ModTime(ctx))
-	switch err {
-	case nil, fs.ErrorCantSetModTime, fs.ErrorCantSetModTimeWithoutDelete:
-	default:
-		return nil, errors.Wrap(err, "multi-thread copy: failed to set modification time")
+	if d := deferredModTimes(ctx); d != nil {
+		d.add(src, obj, src.ModTime(ctx), false)
+	} else {
+		err = obj.SetModTime(ctx, src.ModTime(ctx))
+		switch err {
+		case nil, fs.ErrorCantSetModTime, fs.ErrorCantSetModTimeWithoutDelete:
+		default:
+			return nil, errors.Wrap(err, "multi-thread copy: failed to set modification time")
+		}
 	}
 
 	fs.Debugf(src, "Finished multi-thread copy with %d parts of size %v", mc.streams, fs.SizeSuffix(mc.partSize))
@@ -3,9 +3,14 @@ package operations
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/config"
 	"github.com/rclone/rclone/fstest/mockfs"
 	"github.com/rclone/rclone/fstest/mockobject"
 	"github.com/rclone/rclone/lib/random"
@@ -144,3 +149,70 @@ func TestMultithreadCopy(t *testing.T) {
 	}
 
 }
+
+func TestMultithreadCopyResume(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	ctx := context.Background()
+
+	oldCacheDir, oldPartialCleanup := config.CacheDir, fs.Config.PartialCleanup
+	defer func() {
+		config.CacheDir, fs.Config.PartialCleanup = oldCacheDir, oldPartialCleanup
+	}()
+	var err error
+	config.CacheDir, err = ioutil.TempDir("", "rclone-multithread-resume")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(config.CacheDir)
+	}()
+	fs.Config.PartialCleanup = fs.PartialCleanupResume
+
+	const size = multithreadChunkSize * 4
+	const partSize = multithreadChunkSize * 2
+	contents := random.String(size)
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	file1 := r.WriteObject(ctx, "file1", contents, t1)
+	fstest.CheckItems(t, r.Fremote, file1)
+	src, err := r.Fremote.NewObject(ctx, "file1")
+	require.NoError(t, err)
+
+	// Make a partial copy with the first stream done and the
+	// second half done
+	partial := contents[:partSize+multithreadChunkSize] + strings.Repeat("x", multithreadChunkSize)
+	r.WriteFile("file1", partial, t1)
+	c := newMultiThreadCheckpoint(ctx, r.Flocal, "file1", src, partSize, 2)
+	c.Done = []int64{partSize, multithreadChunkSize}
+	c.save()
+
+	accounting.GlobalStats().ResetCounters()
+	tr := accounting.GlobalStats().NewTransfer(src)
+	dst, err := multiThreadCopy(ctx, r.Flocal, "file1", src, 2, tr)
+	tr.Done(err)
+	require.NoError(t, err)
+	assert.Equal(t, src.Size(), dst.Size())
+	assert.Equal(t, int64(multithreadChunkSize), accounting.GlobalStats().GetBytes())
+	data, err := ioutil.ReadFile(filepath.Join(r.LocalName, "file1"))
+	require.NoError(t, err)
+	assert.True(t, string(data) == contents, "contents differ")
+	_, err = os.Stat(c.path)
+	assert.True(t, os.IsNotExist(err), "checkpoint not removed")
+
+	// A checkpoint for a changed source is ignored
+	r.WriteFile("file1", partial, t1)
+	c = newMultiThreadCheckpoint(ctx, r.Flocal, "file1", src, partSize, 2)
+	c.Size++
+	c.Done = []int64{partSize, partSize}
+	c.save()
+	c.Size--
+	assert.False(t, c.load())
+
+	accounting.GlobalStats().ResetCounters()
+	tr = accounting.GlobalStats().NewTransfer(src)
+	_, err = multiThreadCopy(ctx, r.Flocal, "file1", src, 2, tr)
+	tr.Done(err)
+	require.NoError(t, err)
+	assert.Equal(t, int64(size), accounting.GlobalStats().GetBytes())
+	data, err = ioutil.ReadFile(filepath.Join(r.LocalName, "file1"))
+	require.NoError(t, err)
+	assert.True(t, string(data) == contents, "contents differ")
+}
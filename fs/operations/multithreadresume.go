@@ -0,0 +1,183 @@
+package operations
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// multithreadCheckpointSize is how often to save the progress of
+// each stream of a resumable multi-thread copy
+const multithreadCheckpointSize = 16 << 20
+
+// multiThreadCheckpoint is the progress of a multi-thread copy saved
+// with --partial-cleanup resume so an interrupted copy can carry on
+// from where it got to.
+type multiThreadCheckpoint struct {
+	Size     int64     `json:"size"`           // size of the source
+	ModTime  time.Time `json:"modTime"`        // modification time of the source
+	ID       string    `json:"id,omitempty"`   // ID of the source if known
+	Hash     string    `json:"hash,omitempty"` // hash of the source if cheap to read
+	PartSize int64     `json:"partSize"`       // size of each stream
+	Done     []int64   `json:"done"`           // bytes written from the start of each stream
+
+	mu    sync.Mutex
+	path  string  // where the checkpoint is saved
+	saved []int64 // Done when last saved
+}
+
+// multiThreadCheckpointPath returns the file the checkpoint for a copy
+// to remote in f is saved in
+func multiThreadCheckpointPath(f fs.Fs, remote string) string {
+	sum := md5.Sum([]byte(fs.ConfigString(f) + "\x00" + remote))
+	return filepath.Join(config.CacheDir, "multi-thread", hex.EncodeToString(sum[:])+".json")
+}
+
+// newMultiThreadCheckpoint makes a new checkpoint for copying src to
+// remote in f with streams of partSize
+func newMultiThreadCheckpoint(ctx context.Context, f fs.Fs, remote string, src fs.Object, partSize int64, streams int) *multiThreadCheckpoint {
+	c := &multiThreadCheckpoint{
+		Size:     src.Size(),
+		ModTime:  src.ModTime(ctx).UTC(),
+		PartSize: partSize,
+		Done:     make([]int64, streams),
+		path:     multiThreadCheckpointPath(f, remote),
+		saved:    make([]int64, streams),
+	}
+	if do, ok := src.(fs.IDer); ok {
+		c.ID = do.ID()
+	}
+	// Don't read the hash of local files as it means reading them
+	if !src.Fs().Features().IsLocal {
+		if ht := src.Fs().Hashes().GetOne(); ht != hash.None {
+			c.Hash, _ = src.Hash(ctx, ht)
+		}
+	}
+	return c
+}
+
+// load the saved progress into c if it is for the same source and
+// streams, returning whether it was loaded
+func (c *multiThreadCheckpoint) load() bool {
+	data, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fs.Debugf(nil, "Failed to read multi-thread checkpoint: %v", err)
+		}
+		return false
+	}
+	var old multiThreadCheckpoint
+	err = json.Unmarshal(data, &old)
+	if err != nil {
+		fs.Debugf(nil, "Failed to parse multi-thread checkpoint: %v", err)
+		return false
+	}
+	if old.Size != c.Size || !old.ModTime.Equal(c.ModTime) || old.ID != c.ID || old.Hash != c.Hash {
+		fs.Debugf(nil, "Not resuming multi-thread copy as the source has changed")
+		return false
+	}
+	if old.PartSize != c.PartSize || len(old.Done) != len(c.Done) {
+		fs.Debugf(nil, "Not resuming multi-thread copy as the streams have changed")
+		return false
+	}
+	for _, done := range old.Done {
+		if done < 0 || done > c.PartSize {
+			return false
+		}
+	}
+	copy(c.Done, old.Done)
+	copy(c.saved, old.Done)
+	return true
+}
+
+// reset the progress so the copy starts again from the beginning
+func (c *multiThreadCheckpoint) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.Done {
+		c.Done[i] = 0
+		c.saved[i] = 0
+	}
+}
+
+// streamDone returns the number of bytes stream has already written
+func (c *multiThreadCheckpoint) streamDone(stream int) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Done[stream]
+}
+
+// total returns the number of bytes already copied
+func (c *multiThreadCheckpoint) total() (total int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, done := range c.Done {
+		total += done
+	}
+	return total
+}
+
+// progress records that stream has written done bytes, saving the
+// checkpoint if it has got far enough since the last save
+func (c *multiThreadCheckpoint) progress(stream int, done int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Done[stream] = done
+	if done-c.saved[stream] >= multithreadCheckpointSize {
+		c.saveLocked()
+	}
+}
+
+// save the checkpoint
+func (c *multiThreadCheckpoint) save() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.saveLocked()
+}
+
+// save the checkpoint - call with the lock held
+func (c *multiThreadCheckpoint) saveLocked() {
+	err := c.write()
+	if err != nil {
+		fs.Debugf(nil, "Failed to save multi-thread checkpoint: %v", err)
+		return
+	}
+	copy(c.saved, c.Done)
+}
+
+// write the checkpoint to its file - call with the lock held
+func (c *multiThreadCheckpoint) write() error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(c.path), 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to make checkpoint directory")
+	}
+	tmp := c.path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// remove the checkpoint as the copy has finished
+func (c *multiThreadCheckpoint) remove() {
+	err := os.Remove(c.path)
+	if err != nil && !os.IsNotExist(err) {
+		fs.Debugf(nil, "Failed to remove multi-thread checkpoint: %v", err)
+	}
+}
@@ -2,6 +2,7 @@
 package operations
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/base64"
@@ -33,9 +34,13 @@ import (
 	"github.com/rclone/rclone/fs/object"
 	"github.com/rclone/rclone/fs/walk"
 	"github.com/rclone/rclone/lib/atexit"
+	"github.com/rclone/rclone/lib/bucket"
+	"github.com/rclone/rclone/lib/concurrencygroup"
+	"github.com/rclone/rclone/lib/openfiles"
 	"github.com/rclone/rclone/lib/random"
 	"github.com/rclone/rclone/lib/readers"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
 )
 
 // CheckHashes checks the two files to see if they have common
@@ -52,13 +57,44 @@ import (
 //
 // If an error is returned it will return equal as false
 func CheckHashes(ctx context.Context, src fs.ObjectInfo, dst fs.Object) (equal bool, ht hash.Type, err error) {
+	equal, ht, _, _, err = checkHashSums(ctx, src, dst)
+	return equal, ht, err
+}
+
+// checkHashSums does the work of CheckHashes and also returns the
+// hashes compared.
+func checkHashSums(ctx context.Context, src fs.ObjectInfo, dst fs.Object) (equal bool, ht hash.Type, srcHash, dstHash string, err error) {
 	common := src.Fs().Hashes().Overlap(dst.Fs().Hashes())
 	// fs.Debugf(nil, "Shared hashes: %v", common)
 	if common.Count() == 0 {
-		return true, hash.None, nil
+		return true, hash.None, "", "", nil
 	}
-	equal, ht, _, _, err = checkHashes(ctx, src, dst, common.GetOne())
-	return equal, ht, err
+	ht, err = PreferredHash(src.Fs(), dst.Fs())
+	if err != nil {
+		err = fs.CountError(err)
+		fs.Errorf(src, "Failed to choose hash: %v", err)
+		return false, hash.None, "", "", err
+	}
+	return checkHashes(ctx, src, dst, ht)
+}
+
+// PreferredHash returns the hash type to use to compare files
+// between fa and fb.
+//
+// This is one of the hashes they have in common, the first in
+// --hash-preference if set, or hash.None if they have none in
+// common.  If --hash-preference is set and none of its hashes are in
+// common then it returns an error.
+func PreferredHash(fa, fb fs.Info) (hash.Type, error) {
+	common := fa.Hashes().Overlap(fb.Hashes())
+	preference := fs.Config.HashPreference
+	if len(preference) == 0 {
+		return common.GetOne(), nil
+	}
+	if ht := preference.Preferred(common); ht != hash.None {
+		return ht, nil
+	}
+	return hash.None, errors.Errorf("none of the --hash-preference hashes %q are supported by both %v and %v which have %v in common", preference.String(), fa, fb, common)
 }
 
 // checkHashes does the work of CheckHashes but takes a hash.Type and
@@ -167,9 +203,12 @@ func equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object, opt equalOpt)
 	// If checking checksum and not modtime
 	if opt.checkSum {
 		// Check the hash
-		same, ht, _ := CheckHashes(ctx, src, dst)
+		same, ht, srcSum, dstSum, err := checkHashSums(ctx, src, dst)
+		if !same && err == nil && fs.Config.VerifyHashMismatch != fs.VerifyHashMismatchOff {
+			same = verifyHashMismatch(ctx, src, dst, ht)
+		}
 		if !same {
-			fs.Debugf(src, "%v differ", ht)
+			fs.Debugf(src, "%v differ (src %s vs dst %s)", ht, srcSum, dstSum)
 			return false
 		}
 		if ht == hash.None {
@@ -178,7 +217,7 @@ func equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object, opt equalOpt)
 			})
 			fs.Debugf(src, "Size of src and dst objects identical")
 		} else {
-			fs.Debugf(src, "Size and %v of src and dst objects identical", ht)
+			fs.Debugf(src, "Size and %v of src and dst objects identical (%s)", ht, srcSum)
 		}
 		return true
 	}
@@ -197,14 +236,24 @@ func equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object, opt equalOpt)
 			fs.Debugf(src, "Size and modification time the same (differ by %s, within tolerance %s)", dt, modifyWindow)
 			return true
 		}
+		if storedModTime, ok := storedSourceModTime(ctx, dst); ok {
+			dt := storedModTime.Sub(srcModTime)
+			if dt < modifyWindow && dt > -modifyWindow {
+				fs.Debugf(src, "Size and modification time stored in metadata the same (differ by %s, within tolerance %s)", dt, modifyWindow)
+				return true
+			}
+		}
 
 		fs.Debugf(src, "Modification times differ by %s: %v, %v", dt, srcModTime, dstModTime)
 	}
 
 	// Check if the hashes are the same
-	same, ht, _ := CheckHashes(ctx, src, dst)
+	same, ht, srcSum, dstSum, err := checkHashSums(ctx, src, dst)
+	if !same && err == nil && fs.Config.VerifyHashMismatch != fs.VerifyHashMismatchOff {
+		same = verifyHashMismatch(ctx, src, dst, ht)
+	}
 	if !same {
-		fs.Debugf(src, "%v differ", ht)
+		fs.Debugf(src, "%v differ (src %s vs dst %s)", ht, srcSum, dstSum)
 		return false
 	}
 	if ht == hash.None && !fs.Config.RefreshTimes {
@@ -221,8 +270,18 @@ func equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object, opt equalOpt)
 				fs.Errorf(dst, "StartedAt mismatch between immutable objects")
 				return false
 			}
+			// Leave it to the --defer-modtime pass if set
+			if d := deferredModTimes(ctx); d != nil {
+				if srcObj, ok := src.(fs.Object); ok {
+					d.add(srcObj, dst, srcModTime, true)
+					return true
+				}
+			}
 			// Update the mtime of the dst object here
 			err := dst.SetModTime(ctx, srcModTime)
+			if (err == fs.ErrorCantSetModTime || err == fs.ErrorCantSetModTimeWithoutDelete) && modTimeFallback(ctx, dst, srcModTime) {
+				return true
+			}
 			if err == fs.ErrorCantSetModTime {
 				fs.Debugf(dst, "src and dst identical but can't set mod time without re-uploading")
 				return false
@@ -248,6 +307,74 @@ func equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object, opt equalOpt)
 	return true
 }
 
+// storedSourceModTime returns the modification time of the source
+// stored in the metadata of dst if --modtime-fallback is metadata
+func storedSourceModTime(ctx context.Context, dst fs.Object) (t time.Time, ok bool) {
+	if fs.Config.ModTimeFallback != fs.ModTimeFallbackMetadata {
+		return t, false
+	}
+	do, ok := dst.(fs.SourceModTimer)
+	if !ok {
+		return t, false
+	}
+	t = do.SourceModTime(ctx)
+	return t, !t.IsZero()
+}
+
+// modTimeFallback is called when the modification time of dst can't
+// be set to srcModTime even though it has the same contents as the
+// source.
+//
+// It returns true if --modtime-fallback means that dst doesn't need
+// uploading again.
+func modTimeFallback(ctx context.Context, dst fs.Object, srcModTime time.Time) bool {
+	switch fs.Config.ModTimeFallback {
+	case fs.ModTimeFallbackIgnore:
+		fs.Debugf(dst, "src and dst identical but can't set mod time - ignoring as --modtime-fallback is %v", fs.Config.ModTimeFallback)
+		return true
+	case fs.ModTimeFallbackMetadata:
+		do, ok := dst.(fs.SourceModTimer)
+		if !ok {
+			fs.Debugf(dst, "src and dst identical but can't set mod time or store it in metadata")
+			return false
+		}
+		err := do.SetSourceModTime(ctx, srcModTime)
+		if err != nil {
+			fs.Errorf(dst, "Failed to store modification time in metadata: %v", err)
+			return false
+		}
+		fs.Infof(dst, "Stored source modification time in metadata")
+		return true
+	}
+	return false
+}
+
+// storeSourceModTime stores the modification time of src in the
+// metadata of dst after an upload if dst didn't keep it and
+// --modtime-fallback is metadata.
+func storeSourceModTime(ctx context.Context, src fs.ObjectInfo, dst fs.Object) {
+	if fs.Config.ModTimeFallback != fs.ModTimeFallbackMetadata {
+		return
+	}
+	do, ok := dst.(fs.SourceModTimer)
+	if !ok {
+		return
+	}
+	modifyWindow := fs.GetModifyWindow(src.Fs(), dst.Fs())
+	if modifyWindow == fs.ModTimeNotSupported {
+		return
+	}
+	srcModTime := src.ModTime(ctx)
+	dt := dst.ModTime(ctx).Sub(srcModTime)
+	if dt < modifyWindow && dt > -modifyWindow {
+		return
+	}
+	err := do.SetSourceModTime(ctx, srcModTime)
+	if err != nil {
+		fs.Errorf(dst, "Failed to store modification time in metadata: %v", err)
+	}
+}
+
 // Used to remove a failed copy
 //
 // Returns whether the file was successfully removed or not
@@ -264,6 +391,62 @@ func removeFailedCopy(ctx context.Context, dst fs.Object) bool {
 	return true
 }
 
+// lookupBeforeUpload returns the object at remote in f, if any,
+// before a new object is uploaded there when --partial-cleanup is
+// delete so cleanupPartial can tell it from a partial object.
+func lookupBeforeUpload(ctx context.Context, f fs.Fs, remote string) fs.Object {
+	if fs.Config.PartialCleanup != fs.PartialCleanupDelete {
+		return nil
+	}
+	existing, err := f.NewObject(ctx, remote)
+	if err != nil {
+		return nil
+	}
+	return existing
+}
+
+// sameObject returns true if a and b look like the same version of
+// an object from their sizes, modification times and IDs.
+func sameObject(ctx context.Context, a, b fs.Object) bool {
+	if a.Size() != b.Size() || !a.ModTime(ctx).Equal(b.ModTime(ctx)) {
+		return false
+	}
+	aID, aOK := a.(fs.IDer)
+	bID, bOK := b.(fs.IDer)
+	return !aOK || !bOK || aID.ID() == bID.ID()
+}
+
+// cleanupPartial deals with any partial object left at remote in f
+// by a failed copy of a new object according to --partial-cleanup.
+//
+// existing is what was at remote before the copy, as returned by
+// lookupBeforeUpload, which isn't removed if it is still there, eg
+// with --no-check-dest.
+//
+// This isn't called for failed updates as whatever is at remote then
+// is the old object, not a partial.
+func cleanupPartial(ctx context.Context, f fs.Fs, remote string, existing fs.Object) {
+	partial, err := f.NewObject(ctx, remote)
+	if err != nil {
+		// nothing left behind
+		return
+	}
+	if existing != nil && sameObject(ctx, existing, partial) {
+		fs.Debugf(partial, "Not removing as it was there before the copy")
+		return
+	}
+	switch fs.Config.PartialCleanup {
+	case fs.PartialCleanupKeep:
+		fs.Infof(partial, "Keeping partial copy as --partial-cleanup is %v", fs.Config.PartialCleanup)
+	case fs.PartialCleanupResume:
+		fs.Infof(partial, "Keeping partial copy to resume the transfer from")
+	default:
+		if removeFailedCopy(ctx, partial) {
+			accounting.Stats(ctx).PartialsCleaned(1)
+		}
+	}
+}
+
 // OverrideRemote is a wrapper to override the Remote for an
 // ObjectInfo
 type OverrideRemote struct {
@@ -331,33 +514,112 @@ func CommonHash(fa, fb fs.Info) (hash.Type, *fs.HashesOption) {
 	if !fs.Config.IgnoreChecksum {
 		common = fb.Hashes().Overlap(fa.Hashes())
 		if common.Count() > 0 {
-			hashType = common.GetOne()
+			var err error
+			hashType, err = PreferredHash(fa, fb)
+			if err != nil {
+				// Verify the transfer with any common hash
+				hashType = common.GetOne()
+			}
 			common = hash.Set(hashType)
 		}
 	}
 	return hashType, &fs.HashesOption{Hashes: common}
 }
 
+// withDstBucket returns ctx marked with the bucket that remote is
+// being uploaded to in f so --bwlimit-bucket can be applied to it.
+//
+// ctx is returned unchanged if f isn't bucket based.
+func withDstBucket(ctx context.Context, f fs.Fs, remote string) context.Context {
+	if len(fs.Config.BwLimitBucket) == 0 || !f.Features().BucketBased {
+		return ctx
+	}
+	bucketName, _ := bucket.Split(path.Join(f.Root(), remote))
+	if bucketName == "" {
+		return ctx
+	}
+	return accounting.WithBucket(ctx, bucketName)
+}
+
+// withBind returns ctx marked with the --bind-remote address to bind
+// the connections for a transfer from src to f to so --bwlimit-bind
+// can be applied to it.
+//
+// The address of the destination is used if it has one, otherwise
+// the address of the source.
+func withBind(ctx context.Context, f fs.Fs, src fs.Info) context.Context {
+	if len(fs.Config.BindRemote) == 0 {
+		return ctx
+	}
+	if _, found := fs.Config.BindRemote[f.Name()]; found || src == nil {
+		return fshttp.WithBind(ctx, f.Name())
+	}
+	return fshttp.WithBind(ctx, src.Name())
+}
+
 // Copy src object to dst or f if nil.  If dst is nil then it uses
 // remote as the name of the new object.
 //
 // It returns the destination object if possible.  Note that this may
 // be nil.
 func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Object) (newDst fs.Object, err error) {
+	ctx = withBind(withDstBucket(ctx, f, remote), f, src.Fs())
 	tr := accounting.Stats(ctx).NewTransfer(src)
 	defer func() {
 		tr.Done(err)
 	}()
 	newDst = dst
+	if dst == nil {
+		remote, err = illegalCharsRemote(f, remote)
+		if err != nil {
+			return nil, err
+		}
+	}
+	tr.SetDst(f, remote)
 	if SkipDestructive(ctx, src, "copy") {
 		return newDst, nil
 	}
+	// Reserve the slots in the concurrency groups of the source and destination
+	ctx, releaseGroups, err := concurrencygroup.Reserve(ctx, f, src.Fs())
+	if err != nil {
+		return newDst, err
+	}
+	defer releaseGroups()
+	// Reserve the file handles for the source and destination
+	ctx, releaseFiles, err := openfiles.Reserve(ctx, 2)
+	if err != nil {
+		return newDst, err
+	}
+	defer releaseFiles()
+	// Deal with files too big for the destination with --on-oversize
+	if limit := maxObjectSize(ctx, f); limit > 0 && src.Size() > limit {
+		var skip bool
+		newDst, skip, err = copyOversize(ctx, f, dst, remote, src, limit, tr)
+		if skip {
+			tr.Reset() // don't account the skipped transfer
+			return nil, nil
+		}
+		if err != nil {
+			err = fs.CountError(err)
+			fs.Errorf(src, "Failed to copy: %v", err)
+		}
+		return newDst, err
+	}
 	maxTries := fs.Config.LowLevelRetries
 	tries := 0
+	var retryDeadline time.Time
+	if fs.Config.RetryDuration > 0 {
+		retryDeadline = time.Now().Add(fs.Config.RetryDuration)
+	}
 	doUpdate := dst != nil
 	hashType, hashOption := CommonHash(f, src.Fs())
+	var existing fs.Object // what was at remote before a new upload
+	if !doUpdate {
+		existing = lookupBeforeUpload(ctx, f, remote)
+	}
 
 	var actionTaken string
+	var uploadSum string // hash of the data uploaded if known
 	for {
 		// Try server side copy first - if has optional interface and
 		// is same underlying remote
@@ -366,10 +628,12 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 			(fs.Config.CutoffMode == fs.CutoffModeCautious && accounting.Stats(ctx).GetBytesWithPending()+src.Size() >= int64(fs.Config.MaxTransfer))) {
 			return nil, accounting.ErrorMaxTransferLimitReachedFatal
 		}
+		// The attempt can be cancelled with the transfers/cancel rc
+		tryCtx, done := tr.WithCancel(ctx)
 		if doCopy := f.Features().Copy; doCopy != nil && (SameConfig(src.Fs(), f) || (SameRemoteType(src.Fs(), f) && f.Features().ServerSideAcrossConfigs)) {
-			in := tr.Account(nil) // account the transfer
+			in := tr.Account(tryCtx, nil) // account the transfer
 			in.ServerSideCopyStart()
-			newDst, err = doCopy(ctx, src, remote)
+			newDst, err = doCopy(tryCtx, src, remote)
 			if err == nil {
 				dst = newDst
 				in.ServerSideCopyEnd(dst.Size()) // account the bytes for the server side transfer
@@ -383,6 +647,12 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 		} else {
 			err = fs.ErrorCantCopy
 		}
+		// Don't start reading until the --bwlimit has room for the transfer
+		if err == fs.ErrorCantCopy {
+			if waitErr := accounting.WaitForStartTokens(tryCtx); waitErr != nil {
+				err = waitErr
+			}
+		}
 		// If can't server side copy, do it manually
 		if err == fs.ErrorCantCopy {
 			if doMultiThreadCopy(f, src) {
@@ -395,7 +665,7 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 				if streams < 2 {
 					streams = 2
 				}
-				dst, err = multiThreadCopy(ctx, f, remote, src, int(streams), tr)
+				dst, err = multiThreadCopy(tryCtx, f, remote, src, int(streams), tr)
 				if doUpdate {
 					actionTaken = "Multi-thread Copied (replaced existing)"
 				} else {
@@ -407,7 +677,11 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 				for _, option := range fs.Config.DownloadHeaders {
 					options = append(options, option)
 				}
-				in0, err = NewReOpen(ctx, src, fs.Config.LowLevelRetries, options...)
+				if fs.Config.ShareReads {
+					in0, err = openShared(tryCtx, src, options...)
+				} else {
+					in0, err = NewReOpen(tryCtx, src, fs.Config.LowLevelRetries, options...)
+				}
 				if err != nil {
 					err = errors.Wrap(err, "failed to open source object")
 				} else {
@@ -419,10 +693,12 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 							actionTaken = "Copied (Rcat, new)"
 						}
 						// NB Rcat closes in0
-						dst, err = Rcat(ctx, f, remote, in0, src.ModTime(ctx))
+						dst, err = Rcat(tryCtx, f, remote, in0, src.ModTime(tryCtx))
 						newDst = dst
 					} else {
-						in := tr.Account(in0).WithBuffer() // account and buffer the transfer
+						var uploadHasher *hash.MultiHasher
+						uploadHasher, in0 = hashUpload(f, hashType, in0)
+						in := tr.Account(tryCtx, in0).WithBuffer() // account and buffer the transfer
 						var wrappedSrc fs.ObjectInfo = src
 						// We try to pass the original object if possible
 						if src.Remote() != remote {
@@ -434,65 +710,142 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 						}
 						if doUpdate {
 							actionTaken = "Copied (replaced existing)"
-							err = dst.Update(ctx, in, wrappedSrc, options...)
+							err = dst.Update(tryCtx, in, wrappedSrc, options...)
 						} else {
 							actionTaken = "Copied (new)"
-							dst, err = f.Put(ctx, in, wrappedSrc, options...)
+							dst, err = f.Put(tryCtx, in, wrappedSrc, options...)
 						}
 						closeErr := in.Close()
 						if err == nil {
 							newDst = dst
 							err = closeErr
 						}
+						if err == nil && uploadHasher != nil && uploadHasher.Size() == src.Size() {
+							uploadSum = uploadHasher.Sums()[hashType]
+						}
 					}
 				}
 			}
 		}
+		done()
+		if cancelErr := tr.Cancelled(); cancelErr != nil && err != nil {
+			fs.Infof(src, "Transfer cancelled")
+			err = cancelErr
+		}
+		if errors.Cause(err) == fs.ErrorSourceTruncated {
+			var skip bool
+			skip, err = truncatedRead(src, err)
+			if skip {
+				tr.Reset() // don't account the skipped transfer
+				if !doUpdate {
+					cleanupPartial(ctx, f, remote, existing)
+				}
+				return nil, nil
+			}
+		}
 		tries++
 		if tries >= maxTries {
 			break
 		}
+		if !retryDeadline.IsZero() && !time.Now().Before(retryDeadline) {
+			break
+		}
 		// Retry if err returned a retry error
 		if fserrors.IsRetryError(err) || fserrors.ShouldRetry(err) {
-			fs.Debugf(src, "Received error: %v - low level retry %d/%d", err, tries, maxTries)
+			fs.Debugf(src, "Received error: %v - low level retry %d/%d%s", err, tries, maxTries, fs.RetryBudget(retryDeadline))
 			tr.Reset() // skip incomplete accounting - will be overwritten by retry
 			continue
 		}
 		// otherwise finish
 		break
 	}
+	tr.SetTries(tries)
 	if err != nil {
 		err = fs.CountError(err)
 		fs.Errorf(src, "Failed to copy: %v", err)
+		if !doUpdate {
+			cleanupPartial(ctx, f, remote, existing)
+		}
 		return newDst, err
 	}
 
-	// Verify sizes are the same after transfer
-	if sizeDiffers(src, dst) {
-		err = errors.Errorf("corrupted on transfer: sizes differ %d vs %d", src.Size(), dst.Size())
+	// Verify the hash the destination computed matches the data we uploaded
+	verified, err := verifyUploadHash(ctx, dst, hashType, uploadSum)
+	if err != nil {
 		fs.Errorf(dst, "%v", err)
 		err = fs.CountError(err)
 		removeFailedCopy(ctx, dst)
 		return newDst, err
 	}
+	if !verified {
+		// Don't check the hash again if a mismatch was allowed
+		hashType = hash.None
+	}
 
-	// Verify hashes are the same after transfer - ignoring blank hashes
-	if hashType != hash.None {
-		// checkHashes has logged and counted errors
-		equal, _, srcSum, dstSum, _ := checkHashes(ctx, src, dst, hashType)
-		if !equal {
-			err = errors.Errorf("corrupted on transfer: %v hash differ %q vs %q", hashType, srcSum, dstSum)
+	// Verify sizes and hashes are the same after transfer
+	compared, srcSum, dstSum, err := verifyCopy(ctx, src, dst, hashType)
+	if err != nil {
+		err = copyMismatch(ctx, src, hashType, err)
+		if err != nil {
 			fs.Errorf(dst, "%v", err)
 			err = fs.CountError(err)
 			removeFailedCopy(ctx, dst)
 			return newDst, err
 		}
+	} else if srcSum != "" {
+		tr.SetHash(hashType, srcSum)
 	}
 
-	fs.Infof(src, actionTaken)
+	// Check the upload was stored correctly with --verify-upload
+	wantSum := srcSum
+	if wantSum == "" {
+		wantSum = uploadSum
+	}
+	err = verifyUpload(ctx, f, remote, src, hashType, wantSum)
+	if err != nil {
+		fs.Errorf(dst, "%v", err)
+		err = fs.CountError(err)
+		removeFailedCopy(ctx, dst)
+		return newDst, err
+	}
+
+	storeSourceModTime(ctx, src, dst)
+	SyncACL(ctx, src, dst)
+	fs.Infof(src, "%s%s", actionTaken, hashCompared(compared, srcSum, dstSum))
+	waitForConsistency(ctx, f, remote, src)
+	err = postFileCmd(ctx, f, remote)
 	return newDst, err
 }
 
+// verifyCopy checks the sizes and hashes of src and its copy dst are
+// the same, ignoring blank hashes.
+//
+// It returns the hash type compared, which is hash.None if the hashes
+// weren't both available, and the hashes of src and dst.
+func verifyCopy(ctx context.Context, src, dst fs.Object, hashType hash.Type) (ht hash.Type, srcSum, dstSum string, err error) {
+	if sizeDiffers(src, dst) {
+		return hash.None, "", "", errors.Errorf("sizes differ %d vs %d", src.Size(), dst.Size())
+	}
+	if hashType == hash.None {
+		return hash.None, "", "", nil
+	}
+	// checkHashes has logged and counted errors
+	equal, ht, srcSum, dstSum, _ := checkHashes(ctx, src, dst, hashType)
+	if !equal {
+		return ht, "", "", errors.Errorf("%v hash differ %q vs %q", hashType, srcSum, dstSum)
+	}
+	return ht, srcSum, dstSum, nil
+}
+
+// hashCompared describes the hashes compared by verifyCopy for the log
+// of the transfer.
+func hashCompared(ht hash.Type, srcSum, dstSum string) string {
+	if ht == hash.None {
+		return " - no hash compared"
+	}
+	return fmt.Sprintf(" - %v %s matches %s", ht, srcSum, dstSum)
+}
+
 // SameObject returns true if src and dst could be pointing to the
 // same object.
 func SameObject(src, dst fs.Object) bool {
@@ -527,9 +880,22 @@ func Move(ctx context.Context, fdst fs.Fs, dst fs.Object, remote string, src fs.
 		tr.Done(err)
 	}()
 	newDst = dst
+	if dst == nil {
+		remote, err = illegalCharsRemote(fdst, remote)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if SkipDestructive(ctx, src, "move") {
 		return newDst, nil
 	}
+	// Reserve the slots in the concurrency groups of the source and
+	// destination for the copy and delete too if the move falls back
+	ctx, releaseGroups, err := concurrencygroup.Reserve(ctx, fdst, src.Fs())
+	if err != nil {
+		return newDst, err
+	}
+	defer releaseGroups()
 	// See if we have Move available
 	if doMove := fdst.Features().Move; doMove != nil && (SameConfig(src.Fs(), fdst) || (SameRemoteType(src.Fs(), fdst) && fdst.Features().ServerSideAcrossConfigs)) {
 		// Delete destination if it exists and is not the same file as src (could be same file while seemingly different if the remote is case insensitive)
@@ -544,7 +910,8 @@ func Move(ctx context.Context, fdst fs.Fs, dst fs.Object, remote string, src fs.
 		switch err {
 		case nil:
 			fs.Infof(src, "Moved (server side)")
-			return newDst, nil
+			accounting.Stats(ctx).ServerSideMove(src.Size())
+			return newDst, postFileCmd(ctx, fdst, remote)
 		case fs.ErrorCantMove:
 			fs.Debugf(src, "Can't move, switching to copy")
 		default:
@@ -559,6 +926,10 @@ func Move(ctx context.Context, fdst fs.Fs, dst fs.Object, remote string, src fs.
 		fs.Errorf(src, "Not deleting source as copy failed: %v", err)
 		return newDst, err
 	}
+	if newDst == nil {
+		fs.Logf(src, "Not deleting source as copy was skipped")
+		return nil, nil
+	}
 	// Delete src if no error on copy
 	return newDst, DeleteFile(ctx, src)
 }
@@ -606,6 +977,13 @@ func DeleteFileWithBackupDir(ctx context.Context, dst fs.Object, backupDir fs.Fs
 	if backupDir != nil {
 		action, actioned = "move into backup dir", "Moved into backup dir"
 	}
+	// Reserve the slot in the concurrency groups of the backup dir
+	// too so the move into it can't wait for one while holding this
+	ctx, releaseGroups, err := concurrencygroup.Reserve(ctx, dst.Fs(), backupDir)
+	if err != nil {
+		return err
+	}
+	defer releaseGroups()
 	skip := SkipDestructive(ctx, dst, action)
 	if skip {
 		// do nothing
@@ -637,16 +1015,34 @@ func DeleteFile(ctx context.Context, dst fs.Object) (err error) {
 // If backupDir is set the files will be placed into that directory
 // instead of being deleted.
 func DeleteFilesWithBackupDir(ctx context.Context, toBeDeleted fs.ObjectsChan, backupDir fs.Fs) error {
+	deleters := fs.Config.Deleters
+	if deleters <= 0 {
+		deleters = fs.Config.Transfers
+	}
+	var limiter *rate.Limiter
+	if fs.Config.DeleteTPSLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(fs.Config.DeleteTPSLimit), 1)
+	}
 	var wg sync.WaitGroup
-	wg.Add(fs.Config.Transfers)
+	wg.Add(deleters)
 	var errorCount int32
 	var fatalErrorCount int32
 
-	for i := 0; i < fs.Config.Transfers; i++ {
+	for i := 0; i < deleters; i++ {
 		go func() {
 			defer wg.Done()
 			for dst := range toBeDeleted {
-				err := DeleteFileWithBackupDir(ctx, dst, backupDir)
+				var err error
+				if limiter != nil {
+					err = limiter.Wait(ctx)
+					if err != nil {
+						fs.Errorf(dst, "Couldn't delete: %v", err)
+						err = fs.CountError(err)
+					}
+				}
+				if err == nil {
+					err = DeleteFileWithBackupDir(ctx, dst, backupDir)
+				}
 				if err != nil {
 					atomic.AddInt32(&errorCount, 1)
 					if fserrors.IsFatalError(err) {
@@ -933,6 +1329,9 @@ func CheckFn(ctx context.Context, fdst, fsrc fs.Fs, check checkFn, oneway bool)
 
 // Check the files in fsrc and fdst according to Size and hash
 func Check(ctx context.Context, fdst, fsrc fs.Fs, oneway bool) error {
+	if _, err := PreferredHash(fdst, fsrc); err != nil {
+		return fserrors.FatalError(err)
+	}
 	return CheckFn(ctx, fdst, fsrc, checkIdentical, oneway)
 }
 
@@ -1006,7 +1405,7 @@ func checkIdenticalDownload(ctx context.Context, dst, src fs.Object) (differ boo
 	defer func() {
 		tr1.Done(nil) // error handling is done by the caller
 	}()
-	in1 = tr1.Account(in1).WithBuffer() // account and buffer the transfer
+	in1 = tr1.Account(ctx, in1).WithBuffer() // account and buffer the transfer
 
 	in2, err := src.Open(ctx)
 	if err != nil {
@@ -1016,7 +1415,7 @@ func checkIdenticalDownload(ctx context.Context, dst, src fs.Object) (differ boo
 	defer func() {
 		tr2.Done(nil) // error handling is done by the caller
 	}()
-	in2 = tr2.Account(in2).WithBuffer() // account and buffer the transfer
+	in2 = tr2.Account(ctx, in2).WithBuffer() // account and buffer the transfer
 
 	// To assign err variable before defer.
 	differ, err = CheckEqualReaders(in1, in2)
@@ -1127,25 +1526,102 @@ func hashSum(ctx context.Context, ht hash.Type, o fs.Object) (string, error) {
 
 // HashLister does an md5sum equivalent for the hash type passed in
 func HashLister(ctx context.Context, ht hash.Type, f fs.Fs, w io.Writer) error {
-	return ListFn(ctx, f, func(o fs.Object) {
-		sum, _ := hashSum(ctx, ht, o)
-		syncFprintf(w, "%*s  %s\n", hash.Width(ht), sum, o.Remote())
-	})
+	return hashLister(ctx, ht, false, f, w, nil)
 }
 
 // HashListerBase64 does an md5sum equivalent for the hash type passed in with base64 encoded
 func HashListerBase64(ctx context.Context, ht hash.Type, f fs.Fs, w io.Writer) error {
+	return hashLister(ctx, ht, true, f, w, nil)
+}
+
+// hashLister does an md5sum equivalent for the hash type passed in,
+// base64 encoding the output if outputBase64 is set and skipping any
+// objects whose remote is in skip.
+func hashLister(ctx context.Context, ht hash.Type, outputBase64 bool, f fs.Fs, w io.Writer, skip map[string]struct{}) error {
+	width := hash.Width(ht)
+	if outputBase64 {
+		width = base64.URLEncoding.EncodedLen(hash.Width(ht) / 2)
+	}
 	return ListFn(ctx, f, func(o fs.Object) {
+		if _, found := skip[o.Remote()]; found {
+			fs.Debugf(o, "Skipping as already in the sum file")
+			return
+		}
 		sum, err := hashSum(ctx, ht, o)
-		if err == nil {
+		if outputBase64 && err == nil {
 			hexBytes, _ := hex.DecodeString(sum)
 			sum = base64.URLEncoding.EncodeToString(hexBytes)
 		}
-		width := base64.URLEncoding.EncodedLen(hash.Width(ht) / 2)
 		syncFprintf(w, "%*s  %s\n", width, sum, o.Remote())
 	})
 }
 
+// HashSumFile does an md5sum equivalent for the hash type passed in,
+// writing each result to the sum file at sumPath as soon as the object
+// has been hashed.
+//
+// If the sum file already exists then the objects in it which have a
+// valid hash are skipped and the new results are appended to it, so
+// an interrupted run can be resumed. Any partially written last line
+// is discarded.
+func HashSumFile(ctx context.Context, ht hash.Type, outputBase64 bool, f fs.Fs, sumPath string) (err error) {
+	out, err := os.OpenFile(sumPath, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return errors.Wrap(err, "failed to open sum file")
+	}
+	defer fs.CheckClose(out, &err)
+	skip, kept, err := readSumFile(out)
+	if err != nil {
+		return errors.Wrap(err, "failed to read sum file")
+	}
+	if len(skip) > 0 {
+		fs.Infof(nil, "Resuming - skipping %d objects already in %q", len(skip), sumPath)
+	}
+	// Rewrite the file without the lines which will be done again
+	_, err = out.Seek(0, io.SeekStart)
+	if err != nil {
+		return errors.Wrap(err, "failed to seek sum file")
+	}
+	err = out.Truncate(0)
+	if err != nil {
+		return errors.Wrap(err, "failed to truncate sum file")
+	}
+	_, err = out.Write(kept)
+	if err != nil {
+		return errors.Wrap(err, "failed to rewrite sum file")
+	}
+	return hashLister(ctx, ht, outputBase64, f, out, skip)
+}
+
+// readSumFile reads a sum file in the md5sum format returning the
+// remotes which have a valid hash and the complete lines of the file
+// without those for remotes which failed to hash so they can be done
+// again.
+func readSumFile(in io.Reader) (done map[string]struct{}, kept []byte, err error) {
+	done = make(map[string]struct{})
+	r := bufio.NewReader(in)
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			// discard any incomplete last line
+			return done, kept, nil
+		} else if err != nil {
+			return nil, nil, err
+		}
+		parts := strings.SplitN(strings.TrimSuffix(line, "\n"), "  ", 2)
+		if len(parts) != 2 {
+			kept = append(kept, line...)
+			continue
+		}
+		sum := strings.TrimSpace(parts[0])
+		if sum == "" || sum == "ERROR" || sum == "UNSUPPORTED" {
+			continue
+		}
+		kept = append(kept, line...)
+		done[parts[1]] = struct{}{}
+	}
+}
+
 // Count counts the objects and their sizes in the Fs
 //
 // Obeys includes and excludes
@@ -1183,11 +1659,15 @@ func ListDir(ctx context.Context, f fs.Fs, w io.Writer) error {
 
 // Mkdir makes a destination directory or container
 func Mkdir(ctx context.Context, f fs.Fs, dir string) error {
+	dir, err := illegalCharsRemote(f, dir)
+	if err != nil {
+		return fs.CountError(err)
+	}
 	if SkipDestructive(ctx, fs.LogDirName(f, dir), "make directory") {
 		return nil
 	}
 	fs.Debugf(fs.LogDirName(f, dir), "Making directory")
-	err := f.Mkdir(ctx, dir)
+	err = f.Mkdir(ctx, dir)
 	if err != nil {
 		err = fs.CountError(err)
 		return err
@@ -1349,7 +1829,7 @@ func Cat(ctx context.Context, f fs.Fs, w io.Writer, offset, count int64) error {
 		if count >= 0 {
 			in = &readCloser{Reader: &io.LimitedReader{R: in, N: count}, Closer: in}
 		}
-		in = tr.Account(in).WithBuffer() // account and buffer the transfer
+		in = tr.Account(ctx, in).WithBuffer() // account and buffer the transfer
 		// take the lock just before we output stuff, so at the last possible moment
 		mu.Lock()
 		defer mu.Unlock()
@@ -1361,13 +1841,171 @@ func Cat(ctx context.Context, f fs.Fs, w io.Writer, offset, count int64) error {
 	})
 }
 
+// catFollowHeadSize is how much of the start of the object CatFollow
+// compares each time it changes to see if it has been replaced
+const catFollowHeadSize = 256
+
+// CatFollow outputs the object remote to w starting at offset (from
+// the end if negative), then checks it every interval and outputs any
+// data which has been appended to it, until ctx is cancelled.
+//
+// The new data is read with range requests through the accounting so
+// --bwlimit applies. If the object shrinks, disappears or is replaced
+// it is assumed to have been truncated or rotated and it is followed
+// again from the beginning.  It is seen as replaced if its ID changes
+// or, as the ID isn't known for most backends, if the start of it is
+// different, which is read again with a range request through the
+// accounting each time it changes.
+func CatFollow(ctx context.Context, f fs.Fs, remote string, w io.Writer, offset int64, interval time.Duration) error {
+	var (
+		pos         = int64(-1)
+		lastSize    int64
+		lastModTime time.Time
+		lastID      string
+		head        []byte // the start of the object when last changed
+	)
+	for {
+		o, err := f.NewObject(ctx, remote)
+		switch {
+		case err == fs.ErrorObjectNotFound:
+			if pos != 0 {
+				fs.Logf(remote, "Object disappeared - waiting for it to come back")
+			}
+			pos = 0
+		case err != nil:
+			return err
+		default:
+			size := o.Size()
+			if size < 0 {
+				return errors.Errorf("can't follow %q as its size is unknown", remote)
+			}
+			modTime := o.ModTime(ctx)
+			id := ""
+			if do, ok := o.(fs.IDer); ok {
+				id = do.ID()
+			}
+			changed := pos < 0 || size != lastSize || !modTime.Equal(lastModTime) || id != lastID
+			var newHead []byte
+			if changed && id == "" {
+				newHead, err = catHead(ctx, o, size)
+				if err != nil {
+					return err
+				}
+			}
+			switch {
+			case pos < 0:
+				pos = offset
+				if pos < 0 {
+					pos += size
+				}
+				if pos < 0 || pos > size {
+					pos = 0
+				}
+			case size < pos:
+				fs.Logf(o, "Object truncated - following from the start")
+				pos = 0
+			case changed && pos > 0 && (id != lastID || !samePrefix(head, newHead)):
+				fs.Logf(o, "Object replaced - following from the start")
+				pos = 0
+			}
+			if changed {
+				lastSize, lastModTime, lastID, head = size, modTime, id, newHead
+			}
+			if size > pos {
+				n, err := catRange(ctx, o, w, pos, size-pos)
+				pos += n
+				if err != nil {
+					return err
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// catHead reads up to catFollowHeadSize bytes from the start of o
+// which is size bytes long.
+func catHead(ctx context.Context, o fs.Object, size int64) (head []byte, err error) {
+	if size > catFollowHeadSize {
+		size = catFollowHeadSize
+	}
+	if size <= 0 {
+		return nil, nil
+	}
+	tr := accounting.Stats(ctx).NewTransfer(o)
+	defer func() {
+		tr.Done(err)
+	}()
+	options := []fs.OpenOption{&fs.RangeOption{Start: 0, End: size - 1}}
+	for _, option := range fs.Config.DownloadHeaders {
+		options = append(options, option)
+	}
+	in, err := o.Open(ctx, options...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open")
+	}
+	in = tr.Account(ctx, in) // account the transfer
+	defer fs.CheckClose(in, &err)
+	head = make([]byte, size)
+	n, err := io.ReadFull(in, head)
+	if err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the start")
+	}
+	return head[:n], nil
+}
+
+// samePrefix returns true if the shorter of a and b is the start of
+// the other
+func samePrefix(a, b []byte) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	return bytes.Equal(a, b[:len(a)])
+}
+
+// catRange outputs count bytes of o starting at offset to w returning
+// the number of bytes written.
+func catRange(ctx context.Context, o fs.Object, w io.Writer, offset, count int64) (n int64, err error) {
+	tr := accounting.Stats(ctx).NewTransfer(o)
+	defer func() {
+		tr.Done(err)
+	}()
+	options := []fs.OpenOption{&fs.RangeOption{Start: offset, End: offset + count - 1}}
+	for _, option := range fs.Config.DownloadHeaders {
+		options = append(options, option)
+	}
+	in, err := o.Open(ctx, options...)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to open")
+	}
+	in = &readCloser{Reader: &io.LimitedReader{R: in, N: count}, Closer: in}
+	in = tr.Account(ctx, in).WithBuffer() // account and buffer the transfer
+	n, err = io.Copy(w, in)
+	if err != nil {
+		return n, errors.Wrap(err, "failed to send to output")
+	}
+	return n, nil
+}
+
 // Rcat reads data from the Reader until EOF and uploads it to a file on remote
 func Rcat(ctx context.Context, fdst fs.Fs, dstFileName string, in io.ReadCloser, modTime time.Time) (dst fs.Object, err error) {
+	ctx = withDstBucket(ctx, fdst, dstFileName)
 	tr := accounting.Stats(ctx).NewTransferRemoteSize(dstFileName, -1)
 	defer func() {
 		tr.Done(err)
 	}()
-	in = tr.Account(in).WithBuffer()
+	dstFileName, err = illegalCharsRemote(fdst, dstFileName)
+	if err != nil {
+		return nil, err
+	}
+	in = tr.Account(ctx, in).WithBuffer()
 
 	readCounter := readers.NewCountingReader(in)
 	var trackingIn io.Reader
@@ -1657,6 +2295,14 @@ func NeedTransfer(ctx context.Context, dst, src fs.Object) bool {
 		fs.Debugf(src, "Transferring unconditionally as --ignore-times is in use")
 		return true
 	}
+	// A file split by --on-oversize split is compared with its metadata
+	if split, upToDate := splitUpToDate(ctx, src, dst); split {
+		if upToDate {
+			fs.Debugf(src, "Split file unchanged skipping")
+			return false
+		}
+		return true
+	}
 	// If UpdateOlder is in effect, skip if dst is newer than src
 	if fs.Config.UpdateOlder {
 		srcModTime := src.ModTime(ctx)
@@ -1705,6 +2351,7 @@ func NeedTransfer(ctx context.Context, dst, src fs.Object) bool {
 // RcatSize reads data from the Reader until EOF and uploads it to a file on remote.
 // Pass in size >=0 if known, <0 if not known
 func RcatSize(ctx context.Context, fdst fs.Fs, dstFileName string, in io.ReadCloser, size int64, modTime time.Time) (dst fs.Object, err error) {
+	ctx = withDstBucket(ctx, fdst, dstFileName)
 	var obj fs.Object
 
 	if size >= 0 {
@@ -1714,8 +2361,12 @@ func RcatSize(ctx context.Context, fdst fs.Fs, dstFileName string, in io.ReadClo
 		defer func() {
 			tr.Done(err)
 		}()
+		dstFileName, err = illegalCharsRemote(fdst, dstFileName)
+		if err != nil {
+			return nil, err
+		}
 		body := ioutil.NopCloser(in) // we let the server close the body
-		in := tr.Account(body)       // account the transfer (no buffering)
+		in := tr.Account(ctx, body)  // account the transfer (no buffering)
 
 		if SkipDestructive(ctx, dstFileName, "upload from pipe") {
 			// prevents "broken pipe" errors
@@ -1936,6 +2587,7 @@ func moveOrCopyFile(ctx context.Context, fdst fs.Fs, fsrc fs.Fs, dstFileName str
 		_, err = Op(ctx, fdst, dstObj, dstFileName, srcObj)
 	} else {
 		tr := accounting.Stats(ctx).NewCheckingTransfer(srcObj)
+		SyncACL(ctx, srcObj, dstObj)
 		if !cp {
 			err = DeleteFile(ctx, srcObj)
 		}
@@ -2273,10 +2925,23 @@ func skipDestructiveChoose(ctx context.Context, subject interface{}, action stri
 	return skip
 }
 
+// noModifyKey is the context key for WithNoModify
+type noModifyKey struct{}
+
+// WithNoModify returns a context which makes SkipDestructive skip
+// every destructive operation as if --dry-run was set, logging flag
+// as the reason.  This is used to check what needs doing without
+// changing anything, eg when making a plan with --plan-out.
+func WithNoModify(ctx context.Context, flag string) context.Context {
+	return context.WithValue(ctx, noModifyKey{}, flag)
+}
+
 // SkipDestructive should be called whenever rclone is about to do an destructive operation.
 //
 // It will check the --dry-run flag and it will ask the user if the --interactive flag is set.
 //
+// It skips the operation if ctx was made by WithNoModify.
+//
 // subject should be the object or directory in use
 //
 // action should be a descriptive word or short phrase
@@ -2284,8 +2949,10 @@ func skipDestructiveChoose(ctx context.Context, subject interface{}, action stri
 // Together they should make sense in this sentence: "Rclone is about
 // to action subject".
 func SkipDestructive(ctx context.Context, subject interface{}, action string) (skip bool) {
-	var flag string
+	flag, noModify := ctx.Value(noModifyKey{}).(string)
 	switch {
+	case noModify:
+		skip = true
 	case fs.Config.DryRun:
 		flag = "--dry-run"
 		skip = true
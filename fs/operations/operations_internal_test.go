@@ -3,13 +3,26 @@
 package operations
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/rclone/rclone/fstest/mockobject"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSizeDiffers(t *testing.T) {
@@ -38,3 +51,656 @@ func TestSizeDiffers(t *testing.T) {
 		assert.Equal(t, test.want, got, fmt.Sprintf("ignoreSize=%v, srcSize=%v, dstSize=%v", test.ignoreSize, test.srcSize, test.dstSize))
 	}
 }
+
+func TestReadSumFile(t *testing.T) {
+	in := `d6548b156ea68a4e003e786df99eee76  potato2
+336d5ebc5436534e61d16e63ddfca327  dir/empty space
+                           ERROR  error
+                     UNSUPPORTED  unsupported
+junk
+d6548b156ea68a4e003e786df99eee76  partial`
+	done, kept, err := readSumFile(strings.NewReader(in))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]struct{}{
+		"potato2":         {},
+		"dir/empty space": {},
+	}, done)
+	assert.Equal(t, `d6548b156ea68a4e003e786df99eee76  potato2
+336d5ebc5436534e61d16e63ddfca327  dir/empty space
+junk
+`, string(kept))
+}
+
+func TestRecentlyWritten(t *testing.T) {
+	ctx := context.Background()
+	f := mockfs.NewFs("mock", "root")
+	when := time.Now()
+	src := object.NewStaticObjectInfo("file", when, 10, true, nil, f)
+	other := object.NewStaticObjectInfo("file", when, 11, true, nil, f)
+
+	oldWindow := fs.Config.ConsistencyWindow
+	defer func() { fs.Config.ConsistencyWindow = oldWindow }()
+
+	// Off by default
+	fs.Config.ConsistencyWindow = 0
+	waitForConsistency(ctx, f, "file", src)
+	assert.False(t, RecentlyWritten(ctx, f, "file", src))
+
+	// The object never appears so this waits for the window
+	fs.Config.ConsistencyWindow = 300 * time.Millisecond
+	start := time.Now()
+	waitForConsistency(ctx, f, "file", src)
+	assert.True(t, time.Since(start) >= 100*time.Millisecond)
+	assert.True(t, RecentlyWritten(ctx, f, "file", src))
+	assert.False(t, RecentlyWritten(ctx, f, "file", other))
+	assert.False(t, RecentlyWritten(ctx, f, "file2", src))
+
+	// Forgotten after the window
+	time.Sleep(fs.Config.ConsistencyWindow)
+	assert.False(t, RecentlyWritten(ctx, f, "file", src))
+}
+
+// removeObject is a mock object which records whether it was removed
+type removeObject struct {
+	mockobject.Object
+	removed bool
+}
+
+// Remove this object
+func (o *removeObject) Remove(ctx context.Context) error {
+	o.removed = true
+	return nil
+}
+
+func TestCleanupPartial(t *testing.T) {
+	ctx := context.Background()
+	f := mockfs.NewFs("mock", "root")
+	partial := &removeObject{Object: mockobject.New("partial")}
+	f.AddObject(partial)
+
+	oldCleanup := fs.Config.PartialCleanup
+	defer func() { fs.Config.PartialCleanup = oldCleanup }()
+	stats := accounting.Stats(ctx)
+	stats.ResetCounters()
+
+	// Partials are kept by default
+	assert.Equal(t, fs.PartialCleanupKeep, fs.NewConfig().PartialCleanup)
+
+	// Nothing done if there is no partial
+	cleanupPartial(ctx, f, "missing", nil)
+	assert.Equal(t, int64(0), stats.PartialsCleaned(0))
+
+	for _, policy := range []fs.PartialCleanup{fs.PartialCleanupKeep, fs.PartialCleanupResume} {
+		fs.Config.PartialCleanup = policy
+		cleanupPartial(ctx, f, "partial", nil)
+		assert.False(t, partial.removed, policy.String())
+	}
+	assert.Equal(t, int64(0), stats.PartialsCleaned(0))
+
+	// An object which was there before the copy isn't removed
+	fs.Config.PartialCleanup = fs.PartialCleanupDelete
+	assert.Equal(t, partial, lookupBeforeUpload(ctx, f, "partial"))
+	cleanupPartial(ctx, f, "partial", partial)
+	assert.False(t, partial.removed)
+	assert.Equal(t, int64(0), stats.PartialsCleaned(0))
+
+	// Something different there before the copy doesn't protect it
+	before := mockobject.New("partial").WithContent([]byte("before"), mockobject.SeekModeNone)
+	cleanupPartial(ctx, f, "partial", before)
+	assert.True(t, partial.removed)
+	assert.Equal(t, int64(1), stats.PartialsCleaned(0))
+
+	partial.removed = false
+	cleanupPartial(ctx, f, "partial", nil)
+	assert.True(t, partial.removed)
+	assert.Equal(t, int64(2), stats.PartialsCleaned(0))
+}
+
+func TestTreeHashRoot(t *testing.T) {
+	// The empty tree is the node of an empty directory
+	empty := sha256.Sum256([]byte{treeHashDirNode})
+	assert.Equal(t, hex.EncodeToString(empty[:]), treeHashRoot(nil))
+
+	// Work out a small tree by hand
+	leaf := func(sum string) []byte {
+		d := sha256.Sum256(append([]byte{treeHashFileNode}, sum...))
+		return d[:]
+	}
+	node := func(entries ...[]byte) []byte {
+		h := sha256.New()
+		_, _ = h.Write([]byte{treeHashDirNode})
+		for _, entry := range entries {
+			_, _ = h.Write(entry)
+		}
+		return h.Sum(nil)
+	}
+	entry := func(kind byte, name string, digest []byte) []byte {
+		return append(append(append([]byte{kind}, name...), 0), digest...)
+	}
+	sub := node(entry('f', "b", leaf("bb")))
+	want := node(
+		entry('f', "a", leaf("aa")),
+		entry('f', "dir", leaf("cc")),
+		entry('d', "dir", sub),
+	)
+	sums := map[string]string{
+		"dir/b": "BB",
+		"a":     "aa",
+		"dir":   "cc",
+	}
+	assert.Equal(t, hex.EncodeToString(want), treeHashRoot(sums))
+
+	// A directory node is the same as its tree hash
+	assert.Equal(t, hex.EncodeToString(sub), treeHashRoot(map[string]string{"b": "bb"}))
+
+	// Changing a name or a hash changes the root
+	sums["dir/b"] = "bc"
+	assert.NotEqual(t, hex.EncodeToString(want), treeHashRoot(sums))
+	delete(sums, "dir/b")
+	sums["dir/c"] = "bb"
+	assert.NotEqual(t, hex.EncodeToString(want), treeHashRoot(sums))
+}
+
+// metadataObject is a mock object which can't set its modification
+// time but can store the source modification time in metadata
+type metadataObject struct {
+	mockobject.Object
+	f       fs.Info
+	modTime time.Time
+	stored  time.Time
+	sum     string
+}
+
+func (o *metadataObject) Fs() fs.Info                           { return o.f }
+func (o *metadataObject) ModTime(ctx context.Context) time.Time { return o.modTime }
+func (o *metadataObject) SetModTime(ctx context.Context, t time.Time) error {
+	return fs.ErrorCantSetModTime
+}
+func (o *metadataObject) Hash(ctx context.Context, ht hash.Type) (string, error) {
+	return o.sum, nil
+}
+func (o *metadataObject) SourceModTime(ctx context.Context) time.Time { return o.stored }
+func (o *metadataObject) SetSourceModTime(ctx context.Context, t time.Time) error {
+	o.stored = t
+	return nil
+}
+
+func TestModTimeFallback(t *testing.T) {
+	ctx := context.Background()
+	f := mockfs.NewFs("mock", "root")
+	f.SetHashes(hash.NewHashSet(hash.MD5))
+	srcModTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	sums := map[hash.Type]string{hash.MD5: "0123456789abcdef0123456789abcdef"}
+	src := object.NewStaticObjectInfo("file", srcModTime, 0, true, sums, f)
+	newDst := func() *metadataObject {
+		return &metadataObject{
+			Object:  mockobject.New("file"),
+			f:       f,
+			modTime: srcModTime.Add(time.Hour),
+			sum:     sums[hash.MD5],
+		}
+	}
+
+	oldFallback := fs.Config.ModTimeFallback
+	defer func() { fs.Config.ModTimeFallback = oldFallback }()
+
+	// By default it needs uploading every time
+	fs.Config.ModTimeFallback = fs.ModTimeFallbackUpload
+	dst := newDst()
+	assert.False(t, equal(ctx, src, dst, defaultEqualOpt()))
+	assert.True(t, dst.stored.IsZero())
+
+	// With ignore it is the same once the hashes match
+	fs.Config.ModTimeFallback = fs.ModTimeFallbackIgnore
+	assert.True(t, equal(ctx, src, dst, defaultEqualOpt()))
+	assert.True(t, dst.stored.IsZero())
+
+	// With metadata an existing object gets the time stored ...
+	fs.Config.ModTimeFallback = fs.ModTimeFallbackMetadata
+	assert.True(t, equal(ctx, src, dst, defaultEqualOpt()))
+	assert.Equal(t, srcModTime, dst.stored)
+
+	// ... which is used next time without checking the hash
+	dst.sum = "different"
+	assert.True(t, equal(ctx, src, dst, defaultEqualOpt()))
+
+	// ... unless the source changes
+	newSrc := object.NewStaticObjectInfo("file", srcModTime.Add(time.Minute), 0, true, sums, f)
+	assert.False(t, equal(ctx, newSrc, dst, defaultEqualOpt()))
+
+	// After an upload the time is stored if it didn't stick
+	dst = newDst()
+	storeSourceModTime(ctx, src, dst)
+	assert.Equal(t, srcModTime, dst.stored)
+	dst = newDst()
+	dst.modTime = srcModTime
+	storeSourceModTime(ctx, src, dst)
+	assert.True(t, dst.stored.IsZero())
+}
+
+func TestCopyMismatch(t *testing.T) {
+	ctx := context.Background()
+	oldOnSourceChange := fs.Config.OnSourceChange
+	defer func() { fs.Config.OnSourceChange = oldOnSourceChange }()
+	mismatch := errors.New("sizes differ 5 vs 11")
+
+	f := mockfs.NewFs("mock", "root")
+	src := mockobject.New("file").WithContent([]byte("hello"), mockobject.SeekModeNone)
+	f.AddObject(src)
+	statsCtx := accounting.WithStatsGroup(ctx, "test-copy-mismatch")
+
+	// The source hasn't changed so the copy is corrupted
+	err := copyMismatch(statsCtx, src, hash.None, mismatch)
+	require.Error(t, err)
+	assert.Equal(t, "corrupted on transfer: sizes differ 5 vs 11", err.Error())
+	assert.Equal(t, int64(0), accounting.Stats(statsCtx).GetSourceChanged())
+
+	// Now it has changed
+	f = mockfs.NewFs("mock", "root")
+	f.AddObject(mockobject.New("file").WithContent([]byte("hello world"), mockobject.SeekModeNone))
+	src.SetFs(f)
+	for _, test := range []struct {
+		mode    fs.SourceChange
+		wantErr bool
+		retry   bool
+	}{
+		{fs.SourceChangeRetry, true, true},
+		{fs.SourceChangeFail, true, false},
+		{fs.SourceChangeIgnore, false, false},
+	} {
+		fs.Config.OnSourceChange = test.mode
+		err := copyMismatch(statsCtx, src, hash.None, mismatch)
+		if test.wantErr {
+			require.Error(t, err, test.mode.String())
+			assert.Equal(t, "source changed during transfer: sizes differ 5 vs 11", err.Error())
+			assert.Equal(t, test.retry, fserrors.IsRetryError(err), test.mode.String())
+			assert.Equal(t, !test.retry, fserrors.IsNoRetryError(err), test.mode.String())
+		} else {
+			require.NoError(t, err, test.mode.String())
+		}
+	}
+	assert.Equal(t, int64(3), accounting.Stats(statsCtx).GetSourceChanged())
+}
+
+func TestTruncatedRead(t *testing.T) {
+	oldOnTruncatedRead := fs.Config.OnTruncatedRead
+	defer func() { fs.Config.OnTruncatedRead = oldOnTruncatedRead }()
+	src := mockobject.Object("file")
+	truncated := fmt.Errorf("read 5 of 10 bytes: %w", fs.ErrorSourceTruncated)
+
+	for _, test := range []struct {
+		mode  fs.TruncatedRead
+		skip  bool
+		retry bool
+	}{
+		{fs.TruncatedReadError, false, false},
+		{fs.TruncatedReadRetry, false, true},
+		{fs.TruncatedReadSkip, true, false},
+	} {
+		fs.Config.OnTruncatedRead = test.mode
+		skip, err := truncatedRead(src, truncated)
+		assert.Equal(t, test.skip, skip, test.mode.String())
+		if test.skip {
+			require.NoError(t, err, test.mode.String())
+			continue
+		}
+		require.Error(t, err, test.mode.String())
+		assert.Equal(t, truncated.Error(), err.Error())
+		assert.Equal(t, test.retry, fserrors.IsRetryError(err), test.mode.String())
+		assert.Equal(t, !test.retry, fserrors.IsNoRetryError(err), test.mode.String())
+	}
+}
+
+func TestVerifyUploadHash(t *testing.T) {
+	ctx := context.Background()
+	oldOnHashMismatch := fs.Config.OnHashMismatch
+	defer func() { fs.Config.OnHashMismatch = oldOnHashMismatch }()
+
+	// Hash the data as it is uploaded
+	f := mockfs.NewFs("mock", "root")
+	hasher, in := hashUpload(f, hash.MD5, ioutil.NopCloser(bytes.NewBufferString("hello")))
+	require.NotNil(t, hasher)
+	data, err := ioutil.ReadAll(in)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+	require.NoError(t, in.Close())
+	uploadSum := hasher.Sums()[hash.MD5]
+	assert.Equal(t, "5d41402abc4b2a76b9719d911017c592", uploadSum)
+	hasher, _ = hashUpload(f, hash.None, in)
+	assert.Nil(t, hasher)
+	fs.Config.OnHashMismatch = fs.HashMismatchOff
+	hasher, _ = hashUpload(f, hash.MD5, in)
+	assert.Nil(t, hasher)
+	fs.Config.OnHashMismatch = fs.HashMismatchDefault
+
+	dst := mockobject.New("file").WithContent([]byte("hello"), mockobject.SeekModeNone)
+	verified, err := verifyUploadHash(ctx, dst, hash.MD5, uploadSum)
+	require.NoError(t, err)
+	assert.True(t, verified)
+
+	// Nothing to check if the upload wasn't hashed
+	verified, err = verifyUploadHash(ctx, dst, hash.MD5, "")
+	require.NoError(t, err)
+	assert.True(t, verified)
+
+	corrupt := mockobject.New("file").WithContent([]byte("jello"), mockobject.SeekModeNone)
+	for _, test := range []struct {
+		mode    fs.HashMismatch
+		wantErr bool
+		fatal   bool
+	}{
+		{fs.HashMismatchError, true, false},
+		{fs.HashMismatchFatal, true, true},
+		{fs.HashMismatchWarn, false, false},
+	} {
+		fs.Config.OnHashMismatch = test.mode
+		verified, err := verifyUploadHash(ctx, corrupt, hash.MD5, uploadSum)
+		assert.False(t, verified, test.mode.String())
+		if test.wantErr {
+			require.Error(t, err, test.mode.String())
+			assert.Contains(t, err.Error(), "corrupted on upload: MD5 hash differ")
+			assert.Equal(t, test.fatal, fserrors.IsFatalError(err), test.mode.String())
+		} else {
+			require.NoError(t, err, test.mode.String())
+		}
+	}
+}
+
+func TestVerifyCopy(t *testing.T) {
+	ctx := context.Background()
+	src := mockobject.New("file").WithContent([]byte("hello"), mockobject.SeekModeNone)
+	dst := mockobject.New("file").WithContent([]byte("hello"), mockobject.SeekModeNone)
+
+	// The hashes compared are returned for the log
+	ht, srcSum, dstSum, err := verifyCopy(ctx, src, dst, hash.MD5)
+	require.NoError(t, err)
+	assert.Equal(t, hash.MD5, ht)
+	assert.Equal(t, "5d41402abc4b2a76b9719d911017c592", srcSum)
+	assert.Equal(t, srcSum, dstSum)
+	assert.Equal(t, " - MD5 5d41402abc4b2a76b9719d911017c592 matches 5d41402abc4b2a76b9719d911017c592", hashCompared(ht, srcSum, dstSum))
+
+	// Only the sizes are checked without a hash
+	ht, srcSum, dstSum, err = verifyCopy(ctx, src, dst, hash.None)
+	require.NoError(t, err)
+	assert.Equal(t, hash.None, ht)
+	assert.Equal(t, "", srcSum)
+	assert.Equal(t, "", dstSum)
+	assert.Equal(t, " - no hash compared", hashCompared(ht, srcSum, dstSum))
+
+	corrupt := mockobject.New("file").WithContent([]byte("jello"), mockobject.SeekModeNone)
+	_, _, _, err = verifyCopy(ctx, src, corrupt, hash.MD5)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MD5 hash differ")
+
+	short := mockobject.New("file").WithContent([]byte("hell"), mockobject.SeekModeNone)
+	_, _, _, err = verifyCopy(ctx, src, short, hash.MD5)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sizes differ 5 vs 4")
+}
+
+// laggyFs is an Fs whose NewObject doesn't find the objects the first
+// misses times it is called
+type laggyFs struct {
+	*mockfs.Fs
+	misses int
+}
+
+// NewObject finds the Object at remote
+func (f *laggyFs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	if f.misses > 0 {
+		f.misses--
+		return nil, fs.ErrorObjectNotFound
+	}
+	return f.Fs.NewObject(ctx, remote)
+}
+
+func TestVerifyUpload(t *testing.T) {
+	ctx := context.Background()
+	oldVerifyUpload, oldWait := fs.Config.VerifyUpload, verifyUploadWait
+	defer func() { fs.Config.VerifyUpload, verifyUploadWait = oldVerifyUpload, oldWait }()
+	verifyUploadWait = 500 * time.Millisecond
+	const md5sum = "5d41402abc4b2a76b9719d911017c592"
+	src := mockobject.New("file").WithContent([]byte("hello"), mockobject.SeekModeNone)
+	f := &laggyFs{Fs: mockfs.NewFs("mock", "root")}
+	f.AddObject(mockobject.New("file").WithContent([]byte("hello"), mockobject.SeekModeNone))
+
+	// Nothing checked unless --verify-upload is set
+	f.misses = 100
+	require.NoError(t, verifyUpload(ctx, f, "file", src, hash.MD5, md5sum))
+	f.misses = 0
+	fs.Config.VerifyUpload = true
+
+	require.NoError(t, verifyUpload(ctx, f, "file", src, hash.MD5, md5sum))
+	require.NoError(t, verifyUpload(ctx, f, "file", src, hash.None, ""))
+
+	// The metadata fetch is retried until the object shows up
+	f.misses = 2
+	require.NoError(t, verifyUpload(ctx, f, "file", src, hash.MD5, md5sum))
+	assert.Equal(t, 0, f.misses)
+
+	f.misses = 100
+	err := verifyUpload(ctx, f, "file", src, hash.MD5, md5sum)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "upload verification failed after")
+	assert.Contains(t, err.Error(), "can't find the object")
+	f.misses = 0
+
+	// A wrong size or hash fails the upload
+	f.AddObject(mockobject.New("short").WithContent([]byte("hell"), mockobject.SeekModeNone))
+	err = verifyUpload(ctx, f, "short", src, hash.MD5, md5sum)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "size 4 on destination vs 5 uploaded")
+
+	f.AddObject(mockobject.New("corrupt").WithContent([]byte("jello"), mockobject.SeekModeNone))
+	err = verifyUpload(ctx, f, "corrupt", src, hash.MD5, md5sum)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `MD5 hash "`)
+	assert.Contains(t, err.Error(), `on destination vs "`+md5sum+`" uploaded`)
+
+	// Only the size is checked without a hash
+	require.NoError(t, verifyUpload(ctx, f, "corrupt", src, hash.MD5, ""))
+
+	// A cancelled check stops at once
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	f.misses = 100
+	err = verifyUpload(cancelCtx, f, "file", src, hash.MD5, md5sum)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "upload verification cancelled")
+}
+
+// staleHashObject reports a wrong hash for one hash type
+type staleHashObject struct {
+	*mockobject.ContentMockObject
+	ht  hash.Type
+	sum string
+}
+
+// Hash returns sum for the stale hash type
+func (o *staleHashObject) Hash(ctx context.Context, t hash.Type) (string, error) {
+	if t == o.ht {
+		return o.sum, nil
+	}
+	return o.ContentMockObject.Hash(ctx, t)
+}
+
+func TestVerifyHashMismatch(t *testing.T) {
+	ctx := context.Background()
+	oldVerifyHashMismatch := fs.Config.VerifyHashMismatch
+	defer func() { fs.Config.VerifyHashMismatch = oldVerifyHashMismatch }()
+
+	f := mockfs.NewFs("mock", "root")
+	f.SetHashes(hash.NewHashSet(hash.MD5, hash.SHA1))
+	newObject := func(content string) *mockobject.ContentMockObject {
+		o := mockobject.New("file").WithContent([]byte(content), mockobject.SeekModeNone)
+		o.SetFs(f)
+		return o
+	}
+	dst := newObject("hello")
+	stale := &staleHashObject{ContentMockObject: newObject("hello"), ht: hash.MD5, sum: "0123456789abcdef0123456789abcdef"}
+	changed := newObject("jello")
+
+	for _, test := range []struct {
+		mode fs.VerifyHashMismatch
+		src  fs.Object
+		want bool
+	}{
+		{fs.VerifyHashMismatchOff, stale, false},
+		{fs.VerifyHashMismatchRehash, stale, true},
+		{fs.VerifyHashMismatchRehash, changed, false},
+		{fs.VerifyHashMismatchSecondHash, stale, true},
+		{fs.VerifyHashMismatchSecondHash, changed, false},
+	} {
+		fs.Config.VerifyHashMismatch = test.mode
+		what := fmt.Sprintf("%v %v", test.mode, test.src == stale)
+		assert.Equal(t, test.want, verifyHashMismatch(ctx, test.src, dst, hash.MD5), what)
+		assert.Equal(t, test.want, equal(ctx, test.src, dst, equalOpt{checkSum: true}), what)
+	}
+
+	// No second hash in common
+	fs.Config.VerifyHashMismatch = fs.VerifyHashMismatchSecondHash
+	f.SetHashes(hash.NewHashSet(hash.MD5))
+	assert.False(t, verifyHashMismatch(ctx, stale, dst, hash.MD5))
+}
+
+// aclObject is an object with an ACL which can only represent the
+// grants to the grantees in known if set
+type aclObject struct {
+	*mockobject.ContentMockObject
+	acl   fs.ACL
+	known map[string]bool
+	sets  int
+}
+
+// ACL returns the access control list of the object
+func (o *aclObject) ACL(ctx context.Context) (fs.ACL, error) {
+	return o.acl, nil
+}
+
+// SetACL replaces the access control list of the object with acl
+func (o *aclObject) SetACL(ctx context.Context, acl fs.ACL) error {
+	for _, g := range acl {
+		if o.known != nil && !o.known[g.Grantee] {
+			return fs.ErrorACLUnrepresentable
+		}
+	}
+	o.acl = acl
+	o.sets++
+	return nil
+}
+
+func TestSyncACL(t *testing.T) {
+	ctx := context.Background()
+	oldSyncMetadata := fs.Config.SyncMetadata
+	defer func() { fs.Config.SyncMetadata = oldSyncMetadata }()
+
+	newObject := func(acl fs.ACL) *aclObject {
+		return &aclObject{
+			ContentMockObject: mockobject.New("file").WithContent([]byte("hello"), mockobject.SeekModeNone),
+			acl:               acl,
+		}
+	}
+	public := fs.ACLGrant{Grantee: fs.ACLGranteeAllUsers, Permission: fs.ACLPermissionRead}
+	owner := fs.ACLGrant{Grantee: fs.ACLGranteeOwner, Permission: fs.ACLPermissionFullControl}
+	user := fs.ACLGrant{Grantee: "id:potato", Permission: fs.ACLPermissionRead}
+	src := newObject(fs.ACL{owner, public, user})
+
+	// Nothing is done without --sync-metadata
+	fs.Config.SyncMetadata = false
+	dst := newObject(fs.ACL{owner})
+	SyncACL(ctx, src, dst)
+	assert.Equal(t, 0, dst.sets)
+
+	fs.Config.SyncMetadata = true
+	SyncACL(ctx, src, dst)
+	assert.Equal(t, 1, dst.sets)
+	assert.True(t, src.acl.Equal(dst.acl))
+
+	// Unchanged ACLs aren't written
+	SyncACL(ctx, src, dst)
+	assert.Equal(t, 1, dst.sets)
+
+	// Only the portable grants are copied if the destination can't
+	// represent the others
+	dst = newObject(fs.ACL{owner})
+	dst.known = map[string]bool{fs.ACLGranteeOwner: true, fs.ACLGranteeAllUsers: true}
+	SyncACL(ctx, src, dst)
+	assert.Equal(t, 1, dst.sets)
+	assert.Equal(t, fs.ACL{owner, public}, dst.acl)
+
+	// Objects without ACLs are left alone
+	plain := mockobject.New("file").WithContent([]byte("hello"), mockobject.SeekModeNone)
+	SyncACL(ctx, src, plain)
+	SyncACL(ctx, plain, dst)
+	assert.Equal(t, 1, dst.sets)
+}
+
+// modTimeObject is a mock object which counts the times its
+// modification time is set
+type modTimeObject struct {
+	metadataObject
+	sets int
+}
+
+func (o *modTimeObject) ModTime(ctx context.Context) time.Time { return o.modTime }
+func (o *modTimeObject) SetModTime(ctx context.Context, t time.Time) error {
+	o.modTime = t
+	o.sets++
+	return nil
+}
+
+func TestDeferredModTimes(t *testing.T) {
+	f := mockfs.NewFs("mock", "root")
+	f.SetHashes(hash.NewHashSet(hash.MD5))
+	srcModTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	sum := "0123456789abcdef0123456789abcdef"
+	newDst := func(remote string) *modTimeObject {
+		return &modTimeObject{metadataObject: metadataObject{
+			Object:  mockobject.New(remote),
+			f:       f,
+			modTime: srcModTime.Add(time.Hour),
+			sum:     sum,
+		}}
+	}
+	srcObj := &metadataObject{Object: mockobject.New("file"), f: f, modTime: srcModTime, sum: sum}
+
+	// The modification time is only set by Apply
+	d := NewDeferredModTimes(f)
+	ctx := WithDeferredModTimes(context.Background(), d)
+	dst := newDst("file")
+	assert.True(t, equal(ctx, srcObj, dst, defaultEqualOpt()))
+	assert.Equal(t, 0, dst.sets)
+	require.NoError(t, d.Apply(ctx))
+	assert.Equal(t, 1, dst.sets)
+	assert.Equal(t, srcModTime, dst.modTime)
+
+	// Without a DeferredModTimes it is set straight away
+	dst = newDst("file")
+	assert.True(t, equal(context.Background(), srcObj, dst, defaultEqualOpt()))
+	assert.Equal(t, 1, dst.sets)
+
+	// If interrupted the modification times are left unset
+	d = NewDeferredModTimes(f)
+	ctx, cancel := context.WithCancel(WithDeferredModTimes(context.Background(), d))
+	dst = newDst("file")
+	dst2 := newDst("file2")
+	assert.True(t, equal(ctx, srcObj, dst, defaultEqualOpt()))
+	assert.True(t, equal(ctx, srcObj, dst2, defaultEqualOpt()))
+	cancel()
+	assert.Equal(t, context.Canceled, d.Apply(ctx))
+	assert.Equal(t, 0, dst.sets)
+	assert.Equal(t, 0, dst2.sets)
+	assert.Equal(t, 2, d.reportPending("testing"))
+
+	// Backends which can't set the modification time fall back
+	oldFallback := fs.Config.ModTimeFallback
+	defer func() { fs.Config.ModTimeFallback = oldFallback }()
+	fs.Config.ModTimeFallback = fs.ModTimeFallbackMetadata
+	d = NewDeferredModTimes(f)
+	ctx = WithDeferredModTimes(context.Background(), d)
+	metaDst := &metadataObject{Object: mockobject.New("file"), f: f, modTime: srcModTime.Add(time.Hour), sum: sum}
+	assert.True(t, equal(ctx, srcObj, metaDst, defaultEqualOpt()))
+	assert.True(t, metaDst.stored.IsZero())
+	require.NoError(t, d.Apply(ctx))
+	assert.Equal(t, srcModTime, metaDst.stored)
+}
@@ -30,7 +30,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -44,6 +46,9 @@ import (
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/rclone/rclone/lib/cdc"
 	"github.com/rclone/rclone/lib/random"
 	"github.com/rclone/rclone/lib/readers"
 	"github.com/stretchr/testify/assert"
@@ -265,6 +270,101 @@ func TestHashSums(t *testing.T) {
 	}
 }
 
+func TestHashSumFile(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	if !r.Fremote.Hashes().Contains(hash.MD5) {
+		t.Skip("MD5 not supported")
+	}
+	file1 := r.WriteBoth(context.Background(), "potato2", "------------------------------------------------------------", t1)
+	file2 := r.WriteBoth(context.Background(), "empty space", "-", t2)
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+
+	dir, err := ioutil.TempDir("", "rclone-hashsum-test")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	sumFile := filepath.Join(dir, "sums.md5")
+
+	// Already hashed potato2 with a bogus sum to check it is
+	// skipped, failed to hash empty space so it is done again, then
+	// crashed while writing the next line
+	err = ioutil.WriteFile(sumFile, []byte("00000000000000000000000000000000  potato2\n                           ERROR  empty space\n336d5eb"), 0666)
+	require.NoError(t, err)
+
+	err = operations.HashSumFile(context.Background(), hash.MD5, false, r.Fremote, sumFile)
+	require.NoError(t, err)
+	got, err := ioutil.ReadFile(sumFile)
+	require.NoError(t, err)
+	assert.Equal(t, "00000000000000000000000000000000  potato2\n336d5ebc5436534e61d16e63ddfca327  empty space\n", string(got))
+
+	// Running again does nothing
+	err = operations.HashSumFile(context.Background(), hash.MD5, false, r.Fremote, sumFile)
+	require.NoError(t, err)
+	got2, err := ioutil.ReadFile(sumFile)
+	require.NoError(t, err)
+	assert.Equal(t, got, got2)
+}
+
+func TestTreeHash(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	if !r.Fremote.Hashes().Contains(hash.MD5) {
+		t.Skip("MD5 not supported")
+	}
+	file1 := r.WriteBoth(ctx, "potato2", "------------------------------------------------------------", t1)
+	file2 := r.WriteBoth(ctx, "sub dir/empty space", "-", t2)
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+
+	// The same files give the same root on each remote
+	remoteRoot, err := operations.TreeHash(ctx, hash.MD5, r.Fremote)
+	require.NoError(t, err)
+	localRoot, err := operations.TreeHash(ctx, hash.MD5, r.Flocal)
+	require.NoError(t, err)
+	assert.Equal(t, localRoot, remoteRoot)
+	assert.Len(t, remoteRoot, 64)
+
+	// Changing a file changes the root
+	r.WriteObject(ctx, "sub dir/empty space", "+", t2)
+	changedRoot, err := operations.TreeHash(ctx, hash.MD5, r.Fremote)
+	require.NoError(t, err)
+	assert.NotEqual(t, remoteRoot, changedRoot)
+
+	_, err = operations.TreeHash(ctx, hash.None, r.Fremote)
+	assert.Error(t, err)
+}
+
+func TestChunkFingerprints(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	content := random.String(64 * 1024)
+	file1 := r.WriteObject(ctx, "file1", content, t1)
+	file2 := r.WriteObject(ctx, "sub/file2", "prefix"+content, t2)
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+
+	params := cdc.Params{MinSize: 512, AvgSize: 2048, MaxSize: 8192}
+	var buf bytes.Buffer
+	stats, err := operations.ChunkFingerprints(ctx, r.Fremote, params, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Files)
+	assert.Equal(t, file1.Size+file2.Size, stats.Bytes)
+	assert.Equal(t, int64(strings.Count(buf.String(), "\n")), stats.Chunks)
+	assert.True(t, stats.UniqueChunks < stats.Chunks)
+	assert.True(t, stats.UniqueBytes < stats.Bytes)
+	assert.True(t, stats.UniqueBytes >= file1.Size)
+
+	// The same every run
+	stats2, err := operations.ChunkFingerprints(ctx, r.Fremote, params, nil)
+	require.NoError(t, err)
+	assert.Equal(t, stats, stats2)
+
+	_, err = operations.ChunkFingerprints(ctx, r.Fremote, cdc.Params{MinSize: 1, AvgSize: 1000, MaxSize: 2000}, nil)
+	assert.Error(t, err)
+}
+
 func TestSuffixName(t *testing.T) {
 	origSuffix, origKeepExt := fs.Config.Suffix, fs.Config.SuffixKeepExtension
 	defer func() {
@@ -292,6 +392,84 @@ func TestSuffixName(t *testing.T) {
 	}
 }
 
+func TestUnSuffixName(t *testing.T) {
+	origSuffix, origKeepExt := fs.Config.Suffix, fs.Config.SuffixKeepExtension
+	defer func() {
+		fs.Config.Suffix, fs.Config.SuffixKeepExtension = origSuffix, origKeepExt
+	}()
+	for _, test := range []struct {
+		remote  string
+		suffix  string
+		keepExt bool
+		want    string
+		wantOK  bool
+	}{
+		{"test.txt", "", false, "test.txt", true},
+		{"dir/test.txt-suffix", "-suffix", false, "dir/test.txt", true},
+		{"dir/test-suffix.txt", "-suffix", true, "dir/test.txt", true},
+		{"test.txt-suffix.csv", "-suffix", true, "test.txt.csv", true},
+		{"test-suffix", "-suffix", true, "test", true},
+		{"test.txt", "-suffix", false, "test.txt", false},
+		{"test-suffix.txt", "-suffix", false, "test-suffix.txt", false},
+		{"dir-suffix/test.txt", "-suffix", false, "dir-suffix/test.txt", false},
+		{"-suffix", "-suffix", false, "-suffix", false},
+	} {
+		fs.Config.Suffix = test.suffix
+		fs.Config.SuffixKeepExtension = test.keepExt
+		got, gotOK := operations.UnSuffixName(test.remote)
+		assert.Equal(t, test.want, got, fmt.Sprintf("%+v", test))
+		assert.Equal(t, test.wantOK, gotOK, fmt.Sprintf("%+v", test))
+	}
+}
+
+func TestRestoreBackupDir(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	origSuffix, origDryRun := fs.Config.Suffix, fs.Config.DryRun
+	defer func() {
+		fs.Config.Suffix, fs.Config.DryRun = origSuffix, origDryRun
+	}()
+	fs.Config.Suffix = "-old"
+
+	file1 := r.WriteObject(ctx, "backup/file1-old", "file1 old", t1)
+	file2 := r.WriteObject(ctx, "backup/sub/file2-old", "file2 old", t1)
+	file3 := r.WriteObject(ctx, "backup/file3-old", "file3 old", t1)
+	file4 := r.WriteObject(ctx, "backup/file4", "no suffix", t1)
+	file3new := r.WriteObject(ctx, "dst/file3", "file3 new", t2)
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3, file4, file3new)
+
+	fdst, err := fs.NewFs(r.FremoteName + "/dst")
+	require.NoError(t, err)
+	fbackup, err := fs.NewFs(r.FremoteName + "/backup")
+	require.NoError(t, err)
+
+	// --dry-run does nothing
+	fs.Config.DryRun = true
+	require.NoError(t, operations.RestoreBackupDir(ctx, fdst, fbackup, false))
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3, file4, file3new)
+	fs.Config.DryRun = false
+
+	// Existing files and files without the suffix are left alone
+	require.NoError(t, operations.RestoreBackupDir(ctx, fdst, fbackup, false))
+	file1.Path = "dst/file1"
+	file2.Path = "dst/sub/file2"
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3, file4, file3new)
+
+	// Unless overwriting
+	require.NoError(t, operations.RestoreBackupDir(ctx, fdst, fbackup, true))
+	file3.Path = "dst/file3"
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3, file4)
+
+	// Purge the rest
+	fs.Config.DryRun = true
+	require.NoError(t, operations.PurgeBackupDir(ctx, fbackup))
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3, file4)
+	fs.Config.DryRun = false
+	require.NoError(t, operations.PurgeBackupDir(ctx, fbackup))
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3)
+}
+
 func TestCount(t *testing.T) {
 	r := fstest.NewRun(t)
 	defer r.Finalise()
@@ -329,6 +507,45 @@ func TestDelete(t *testing.T) {
 	fstest.CheckItems(t, r.Fremote, file3)
 }
 
+// markerFs pretends the file "<dir>.marker" is the directory marker
+// of dir
+type markerFs struct {
+	fs.Fs
+}
+
+func (f markerFs) DirMarker(ctx context.Context, dir string) (fs.Object, error) {
+	return f.NewObject(ctx, dir+".marker")
+}
+
+func TestDeleteDirMarker(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	file1 := r.WriteObject(ctx, "dir.marker", "", t1)
+	file2 := r.WriteObject(ctx, "dir/file", "potato", t1)
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+	f := markerFs{Fs: r.Fremote}
+
+	// Doesn't delete with --dry-run
+	fs.Config.DryRun = true
+	err := operations.DeleteDirMarker(ctx, f, "dir", nil)
+	fs.Config.DryRun = false
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+
+	// Ignores a directory without a marker
+	require.NoError(t, operations.DeleteDirMarker(ctx, f, "other", nil))
+
+	// Ignores an Fs which doesn't have markers
+	require.NoError(t, operations.DeleteDirMarker(ctx, r.Fremote, "dir", nil))
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, operations.DeleteDirMarker(ctx, f, "dir", nil))
+	fstest.CheckItems(t, r.Fremote, file2)
+	assert.Equal(t, int64(1), accounting.GlobalStats().DirMarkers(0))
+}
+
 func TestRetry(t *testing.T) {
 	var i int
 	var err error
@@ -446,6 +663,40 @@ func TestCheckSizeOnly(t *testing.T) {
 	TestCheck(t)
 }
 
+func TestCheckHashPreference(t *testing.T) {
+	oldPreference := fs.Config.HashPreference
+	defer func() { fs.Config.HashPreference = oldPreference }()
+	fs.Config.HashPreference = hash.TypeList{hash.CRC32, hash.MD5}
+	TestCheck(t)
+}
+
+func TestPreferredHash(t *testing.T) {
+	oldPreference := fs.Config.HashPreference
+	defer func() { fs.Config.HashPreference = oldPreference }()
+	fa := mockfs.NewFs("a", "")
+	fa.SetHashes(hash.NewHashSet(hash.MD5, hash.SHA1, hash.CRC32))
+	fb := mockfs.NewFs("b", "")
+	fb.SetHashes(hash.NewHashSet(hash.SHA1, hash.CRC32, hash.Whirlpool))
+
+	fs.Config.HashPreference = nil
+	ht, err := operations.PreferredHash(fa, fb)
+	require.NoError(t, err)
+	assert.Equal(t, hash.SHA1, ht)
+
+	fs.Config.HashPreference = hash.TypeList{hash.MD5, hash.CRC32, hash.SHA1}
+	ht, err = operations.PreferredHash(fa, fb)
+	require.NoError(t, err)
+	assert.Equal(t, hash.CRC32, ht)
+
+	fs.Config.HashPreference = hash.TypeList{hash.MD5, hash.Whirlpool}
+	_, err = operations.PreferredHash(fa, fb)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--hash-preference")
+	err = operations.Check(context.Background(), fa, fb, false)
+	require.Error(t, err)
+	assert.True(t, fserrors.IsFatalError(err))
+}
+
 func TestCat(t *testing.T) {
 	r := fstest.NewRun(t)
 	defer r.Finalise()
@@ -476,6 +727,54 @@ func TestCat(t *testing.T) {
 	}
 }
 
+// followFs returns a new version of the object from NewObject each
+// time it is called, cancelling the context when it runs out
+type followFs struct {
+	*mockfs.Fs
+	versions []string
+	cancel   func()
+}
+
+func (f *followFs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	version := f.versions[0]
+	if len(f.versions) > 1 {
+		f.versions = f.versions[1:]
+	} else {
+		f.cancel()
+	}
+	if version == "<missing>" {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return mockobject.New(remote).WithContent([]byte(version), mockobject.SeekModeNone), nil
+}
+
+func TestCatFollow(t *testing.T) {
+	ctx, cancel := context.WithCancel(accounting.WithStatsGroup(context.Background(), "test-cat-follow"))
+	f := &followFs{
+		Fs: mockfs.NewFs("mock", ""),
+		versions: []string{
+			"ABCDEFGHIJ",
+			"ABCDEFGHIJ",
+			"ABCDEFGHIJKLM", // appended
+			"xy",            // truncated
+			"<missing>",     // rotated
+			"123",
+			"123",
+			"abcdefgh", // replaced
+			"abcdefgh",
+		},
+		cancel: cancel,
+	}
+	var buf bytes.Buffer
+	err := operations.CatFollow(ctx, f, "file1", &buf, -3, time.Millisecond)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, "HIJKLMxy123abcdefgh", buf.String())
+	// The start of each changed version is read through the
+	// accounting as well as the output
+	heads := len("ABCDEFGHIJ") + len("ABCDEFGHIJKLM") + len("xy") + len("123") + len("abcdefgh")
+	assert.Equal(t, int64(heads+buf.Len()), accounting.Stats(ctx).GetBytes())
+}
+
 func TestPurge(t *testing.T) {
 	r := fstest.NewRunIndividual(t) // make new container (azureblob has delayed mkdir after rmdir)
 	defer r.Finalise()
@@ -879,6 +1178,270 @@ func TestCopyFile(t *testing.T) {
 	fstest.CheckItems(t, r.Fremote, file2)
 }
 
+func TestCopyPostFileCmd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	dir, err := ioutil.TempDir("", "rclone-post-file-cmd")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	logFile := filepath.Join(dir, "log")
+	oldCmd, oldError := fs.Config.PostFileCmd, fs.Config.PostFileCmdError
+	defer func() {
+		fs.Config.PostFileCmd, fs.Config.PostFileCmdError = oldCmd, oldError
+	}()
+
+	file1 := r.WriteFile("file1", "file1 contents", t1)
+	fstest.CheckItems(t, r.Flocal, file1)
+
+	// Successful command gets the path of the transferred file
+	fs.Config.PostFileCmd = fs.SpaceSepList{"sh", "-c", `echo "$1" >> "$0"`, logFile}
+	accounting.GlobalStats().ResetCounters()
+	err = operations.CopyFile(ctx, r.Fremote, r.Flocal, file1.Path, file1.Path)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1)
+	data, err := ioutil.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, r.Fremote.Root()+"/file1\n", string(data))
+	assert.Equal(t, int64(0), accounting.GlobalStats().PostFileCmdErrors(0))
+
+	// Failing command only warns by default
+	fs.Config.PostFileCmd = fs.SpaceSepList{"false"}
+	file2 := r.WriteFile("file2", "file2 contents", t1)
+	err = operations.CopyFile(ctx, r.Fremote, r.Flocal, file2.Path, file2.Path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), accounting.GlobalStats().PostFileCmdErrors(0))
+	assert.Equal(t, int64(0), accounting.GlobalStats().GetErrors())
+
+	// Failing command fails the transfer with fail
+	fs.Config.PostFileCmdError = fs.HookErrorFail
+	file3 := r.WriteFile("file3", "file3 contents", t1)
+	err = operations.CopyFile(ctx, r.Fremote, r.Flocal, file3.Path, file3.Path)
+	require.Error(t, err)
+	assert.Equal(t, int64(2), accounting.GlobalStats().PostFileCmdErrors(0))
+	assert.Equal(t, int64(1), accounting.GlobalStats().GetErrors())
+}
+
+func TestCopyIllegalChars(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	dir, err := ioutil.TempDir("", "rclone-illegal-chars")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	manifest := filepath.Join(dir, "manifest")
+	encodingEnv := fs.ConfigToEnv(r.Fremote.Name(), "encoding")
+	require.NoError(t, os.Setenv(encodingEnv, "Slash,Colon,Asterisk,Dot"))
+	oldIllegalChars, oldMap, oldManifest := fs.Config.IllegalChars, fs.Config.IllegalCharsMap, fs.Config.IllegalCharsManifest
+	defer func() {
+		_ = os.Unsetenv(encodingEnv)
+		fs.Config.IllegalChars, fs.Config.IllegalCharsMap, fs.Config.IllegalCharsManifest = oldIllegalChars, oldMap, oldManifest
+	}()
+	fs.Config.IllegalCharsManifest = manifest
+
+	file1 := r.WriteFile("dir:1/a:b*", "file1 contents", t1)
+	src, err := r.Flocal.NewObject(ctx, file1.Path)
+	require.NoError(t, err)
+
+	// Error refuses to copy the file
+	fs.Config.IllegalChars = fs.IllegalCharsError
+	_, err = operations.Copy(ctx, r.Fremote, nil, file1.Path, src)
+	require.Error(t, err)
+	assert.True(t, fserrors.IsNoRetryError(err))
+	fstest.CheckItems(t, r.Fremote)
+
+	// Strip removes the illegal characters
+	fs.Config.IllegalChars = fs.IllegalCharsStrip
+	dst, err := operations.Copy(ctx, r.Fremote, nil, file1.Path, src)
+	require.NoError(t, err)
+	assert.Equal(t, "dir1/ab", dst.Remote())
+
+	// Substitute replaces them
+	fs.Config.IllegalChars = fs.IllegalCharsSubstitute
+	fs.Config.IllegalCharsMap = map[rune]string{':': "-"}
+	dst, err = operations.Copy(ctx, r.Fremote, nil, file1.Path, src)
+	require.NoError(t, err)
+	assert.Equal(t, "dir-1/a-b_", dst.Remote())
+
+	file1.Path = "dir1/ab"
+	file2 := file1
+	file2.Path = "dir-1/a-b_"
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+
+	// The renames are recorded in the manifest
+	data, err := ioutil.ReadFile(manifest)
+	require.NoError(t, err)
+	root := r.Fremote.Root()
+	assert.Equal(t, fmt.Sprintf(`{"remote":%q,"original":%q}
+{"remote":%q,"original":%q}
+`, root+"/dir1/ab", root+"/dir:1/a:b*", root+"/dir-1/a-b_", root+"/dir:1/a:b*"), string(data))
+}
+
+func TestCopyOversize(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	oldOnOversize, oldMaxObjectSize := fs.Config.OnOversize, fs.Config.MaxObjectSize
+	defer func() {
+		fs.Config.OnOversize, fs.Config.MaxObjectSize = oldOnOversize, oldMaxObjectSize
+	}()
+	fs.Config.MaxObjectSize = 6
+
+	file1 := r.WriteFile("file1", "0123456789abcdef", t1)
+	src, err := r.Flocal.NewObject(ctx, file1.Path)
+	require.NoError(t, err)
+
+	// Error refuses to copy the file
+	fs.Config.OnOversize = fs.OversizeError
+	_, err = operations.Copy(ctx, r.Fremote, nil, file1.Path, src)
+	require.Error(t, err)
+	assert.True(t, fserrors.IsNoRetryError(err))
+	fstest.CheckItems(t, r.Fremote)
+
+	// Skip leaves it out
+	fs.Config.OnOversize = fs.OversizeSkip
+	dst, err := operations.Copy(ctx, r.Fremote, nil, file1.Path, src)
+	require.NoError(t, err)
+	assert.Nil(t, dst)
+	fstest.CheckItems(t, r.Fremote)
+
+	// Split uploads it in parts with the chunker metadata
+	fs.Config.OnOversize = fs.OversizeSplit
+	dst, err = operations.Copy(ctx, r.Fremote, nil, file1.Path, src)
+	require.NoError(t, err)
+	assert.Equal(t, "file1", dst.Remote())
+	meta := fstest.NewItem("file1", `{"ver":1,"size":16,"nchunks":3,"md5":"4032af8d61035123906e58e067140cc5"}`, t1)
+	chunk1 := fstest.NewItem("file1.rclone_chunk.001", "012345", t1)
+	chunk2 := fstest.NewItem("file1.rclone_chunk.002", "6789ab", t1)
+	chunk3 := fstest.NewItem("file1.rclone_chunk.003", "cdef", t1)
+	fstest.CheckItems(t, r.Fremote, meta, chunk1, chunk2, chunk3)
+
+	// The split file is up to date while its metadata matches
+	dst, err = r.Fremote.NewObject(ctx, file1.Path)
+	require.NoError(t, err)
+	assert.False(t, operations.NeedTransfer(ctx, dst, src))
+	changed := r.WriteFile("file1", "0123456789abcdeF", t1)
+	src, err = r.Flocal.NewObject(ctx, changed.Path)
+	require.NoError(t, err)
+	assert.True(t, operations.NeedTransfer(ctx, dst, src))
+
+	// Small files are copied as usual
+	file2 := r.WriteFile("file2", "small", t1)
+	src, err = r.Flocal.NewObject(ctx, file2.Path)
+	require.NoError(t, err)
+	_, err = operations.Copy(ctx, r.Fremote, nil, file2.Path, src)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, meta, chunk1, chunk2, chunk3, file2)
+}
+
+// serverTimeFs is an Fs whose objects have the modification time of
+// a clock offset from the local one
+type serverTimeFs struct {
+	fs.Fs
+	offset time.Duration
+}
+
+// serverTimeObject is an object with a server assigned modification time
+type serverTimeObject struct {
+	fs.Object
+	modTime time.Time
+}
+
+// ModTime returns the server assigned modification time
+func (o serverTimeObject) ModTime(ctx context.Context) time.Time {
+	return o.modTime
+}
+
+// NewObject stamps the object with the server clock
+func (f *serverTimeFs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	o, err := f.Fs.NewObject(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+	return serverTimeObject{Object: o, modTime: time.Now().Add(f.offset)}, nil
+}
+
+func TestClockOffset(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	r.Mkdir(ctx, r.Fremote)
+
+	// The remote keeps the modification time so the offset is unknown
+	info, err := operations.ClockOffset(ctx, r.Fremote)
+	require.NoError(t, err)
+	assert.False(t, info.Known)
+	assert.NotEqual(t, "", info.Reason)
+	fstest.CheckItems(t, r.Fremote)
+
+	// The remote uses its own clock
+	info, err = operations.ClockOffset(ctx, &serverTimeFs{Fs: r.Fremote, offset: time.Hour})
+	require.NoError(t, err)
+	assert.True(t, info.Known)
+	assert.Equal(t, "", info.Reason)
+	assert.InDelta(t, 3600, info.Offset, info.Uncertainty)
+	fstest.CheckItems(t, r.Fremote)
+}
+
+func TestAppendFile(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	// first append copies the file
+	file1 := r.WriteFile("app.log", "line 1\n", t1)
+	err := operations.AppendFile(ctx, r.Fremote, r.Flocal, file1.Path, file1.Path)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	dst, err := r.Fremote.NewObject(ctx, file1.Path)
+	require.NoError(t, err)
+	if _, ok := dst.(fs.Appender); !ok {
+		t.Skip("Skipping test as remote does not support appending")
+	}
+
+	// only the new data should be transferred
+	file2 := r.WriteFile("app.log", "line 1\nline 2\n", t2)
+	accounting.GlobalStats().ResetCounters()
+	err = operations.AppendFile(ctx, r.Fremote, r.Flocal, file2.Path, file2.Path)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file2)
+	assert.Equal(t, int64(len("line 2\n")), accounting.GlobalStats().GetBytes())
+	assert.Equal(t, int64(1), accounting.GlobalStats().GetTransfers())
+
+	// nothing to append
+	accounting.GlobalStats().ResetCounters()
+	err = operations.AppendFile(ctx, r.Fremote, r.Flocal, file2.Path, file2.Path)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file2)
+	assert.Equal(t, int64(0), accounting.GlobalStats().GetTransfers())
+
+	// destination bigger than the source
+	file3 := r.WriteObject(ctx, "app.log", "line 1\nline 2\nsomething else\n", t2)
+	accounting.GlobalStats().ResetCounters()
+	err = operations.AppendFile(ctx, r.Fremote, r.Flocal, file2.Path, file2.Path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), fs.ErrorAppendConflict.Error())
+	fstest.CheckItems(t, r.Fremote, file3)
+
+	// the destination changing size with the append is detected
+	dst, err = r.Fremote.NewObject(ctx, file3.Path)
+	require.NoError(t, err)
+	src, err := r.Flocal.NewObject(ctx, file2.Path)
+	require.NoError(t, err)
+	err = dst.(fs.Appender).Append(ctx, strings.NewReader("more"), int64(len("line 1\n")), src)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), fs.ErrorAppendConflict.Error())
+	fstest.CheckItems(t, r.Fremote, file3)
+}
+
 func TestCopyFileBackupDir(t *testing.T) {
 	r := fstest.NewRun(t)
 	defer r.Finalise()
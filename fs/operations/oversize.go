@@ -0,0 +1,199 @@
+package operations
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/object"
+)
+
+// splitChunkSuffix goes between the name of a file split by
+// --on-oversize split and the number of each part.  This and the
+// metadata are the defaults of the chunker backend so a chunker remote
+// wrapping the destination reads the parts as the original file.
+const splitChunkSuffix = ".rclone_chunk."
+
+// splitMeta is the metadata object written in place of a file split
+// by --on-oversize split, in the simplejson format of the chunker
+// backend.
+type splitMeta struct {
+	Version  int    `json:"ver"`
+	Size     int64  `json:"size"`    // total size of the parts
+	ChunkNum int    `json:"nchunks"` // number of parts
+	MD5      string `json:"md5,omitempty"`
+}
+
+// maxObjectSize returns the size of the largest object f can store
+// from --max-object-size or the backend, or -1 if there is no limit
+// or it isn't known.
+func maxObjectSize(ctx context.Context, f fs.Info) int64 {
+	if fs.Config.MaxObjectSize > 0 {
+		return int64(fs.Config.MaxObjectSize)
+	}
+	if do := f.Features().MaxObjectSize; do != nil {
+		return do(ctx)
+	}
+	return -1
+}
+
+// maxSplitMetaSize is the largest the metadata object of a split file
+// can be
+const maxSplitMetaSize = 1024
+
+// readSplitMeta returns the metadata of dst if it is the metadata
+// object of a file split by --on-oversize split or nil if not.
+func readSplitMeta(ctx context.Context, dst fs.Object) *splitMeta {
+	if dst.Size() < 0 || dst.Size() > maxSplitMetaSize {
+		return nil
+	}
+	in, err := dst.Open(ctx)
+	if err != nil {
+		fs.Debugf(dst, "Failed to open to check for split metadata: %v", err)
+		return nil
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(in, maxSplitMetaSize))
+	_ = in.Close()
+	if err != nil {
+		fs.Debugf(dst, "Failed to read to check for split metadata: %v", err)
+		return nil
+	}
+	var meta splitMeta
+	if json.Unmarshal(data, &meta) != nil || meta.Version != 1 || meta.ChunkNum <= 0 {
+		return nil
+	}
+	return &meta
+}
+
+// splitUpToDate checks whether dst is the metadata object of src
+// split by --on-oversize split.
+//
+// If it is then split is set and upToDate is set if the size and the
+// MD5 recorded match src, or the modification time if src has no MD5.
+func splitUpToDate(ctx context.Context, src fs.ObjectInfo, dst fs.Object) (split, upToDate bool) {
+	if fs.Config.OnOversize != fs.OversizeSplit {
+		return false, false
+	}
+	if limit := maxObjectSize(ctx, dst.Fs()); limit <= 0 || src.Size() <= limit {
+		return false, false
+	}
+	meta := readSplitMeta(ctx, dst)
+	if meta == nil {
+		return false, false
+	}
+	if meta.Size != src.Size() {
+		fs.Debugf(src, "Size of split file differs %d vs %d", meta.Size, src.Size())
+		return true, false
+	}
+	srcSum, err := src.Hash(ctx, hash.MD5)
+	if err == nil && srcSum != "" && meta.MD5 != "" {
+		if srcSum != meta.MD5 {
+			fs.Debugf(src, "MD5 of split file differs %q vs %q", meta.MD5, srcSum)
+			return true, false
+		}
+		return true, true
+	}
+	dt := dst.ModTime(ctx).Sub(src.ModTime(ctx))
+	if dt < 0 {
+		dt = -dt
+	}
+	modifyWindow := fs.GetModifyWindow(dst.Fs(), src.Fs())
+	if modifyWindow == fs.ModTimeNotSupported || dt > modifyWindow {
+		fs.Debugf(src, "Modification time of split file differs by %v", dt)
+		return true, false
+	}
+	return true, true
+}
+
+// splitChunkRemote returns the remote of part n, counting from 0, of
+// remote split by --on-oversize split
+func splitChunkRemote(remote string, n int) string {
+	return fmt.Sprintf("%s%s%03d", remote, splitChunkSuffix, n+1)
+}
+
+// copyOversize deals with src being bigger than limit, the largest
+// object f can store, according to --on-oversize.
+//
+// It returns the metadata object if the file was split or skip set if
+// the transfer should be skipped.
+func copyOversize(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Object, limit int64, tr *accounting.Transfer) (newDst fs.Object, skip bool, err error) {
+	switch fs.Config.OnOversize {
+	case fs.OversizeSkip:
+		fs.Logf(src, "Skipping as it is bigger than the largest object %v can store (%v) and --on-oversize is skip", f, fs.SizeSuffix(limit))
+		return nil, true, nil
+	case fs.OversizeSplit:
+		newDst, err = copySplit(ctx, f, dst, remote, src, limit, tr)
+		return newDst, false, err
+	}
+	return nil, false, fserrors.NoRetryError(errors.Errorf("size %v is bigger than the largest object %v can store (%v)", fs.SizeSuffix(src.Size()), f, fs.SizeSuffix(limit)))
+}
+
+// copySplit copies src to remote in f as parts of at most chunkSize
+// with a metadata object at remote describing them, replacing dst if
+// set, and returns the metadata object.
+//
+// The parts already uploaded are removed if it fails.
+func copySplit(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Object, chunkSize int64, tr *accounting.Transfer) (newDst fs.Object, err error) {
+	in0, err := NewReOpen(ctx, src, fs.Config.LowLevelRetries)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open source object")
+	}
+	in := tr.Account(ctx, in0).WithBuffer() // account and buffer the transfer
+	defer fs.CheckClose(in, &err)
+	md5sum := md5.New()
+	r := io.TeeReader(in, md5sum)
+
+	var chunks []fs.Object
+	defer func() {
+		if err != nil {
+			for _, chunk := range chunks {
+				removeFailedCopy(ctx, chunk)
+			}
+		}
+	}()
+	size, modTime := src.Size(), src.ModTime(ctx)
+	for n := 0; int64(n)*chunkSize < size; n++ {
+		chunkLen := size - int64(n)*chunkSize
+		if chunkLen > chunkSize {
+			chunkLen = chunkSize
+		}
+		info := object.NewStaticObjectInfo(splitChunkRemote(remote, n), modTime, chunkLen, true, nil, f)
+		chunk, err := f.Put(ctx, io.LimitReader(r, chunkLen), info)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to upload part %d", n+1)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	meta, err := json.Marshal(&splitMeta{
+		Version:  1,
+		Size:     size,
+		ChunkNum: len(chunks),
+		MD5:      hex.EncodeToString(md5sum.Sum(nil)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	info := object.NewStaticObjectInfo(remote, modTime, int64(len(meta)), true, nil, f)
+	if dst != nil {
+		err = dst.Update(ctx, bytes.NewReader(meta), info)
+		newDst = dst
+	} else {
+		newDst, err = f.Put(ctx, bytes.NewReader(meta), info)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to upload split metadata")
+	}
+	fs.Infof(src, "Copied (split into %d parts as it is bigger than %v)", len(chunks), fs.SizeSuffix(chunkSize))
+	return newDst, nil
+}
@@ -0,0 +1,78 @@
+package operations
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/fspath"
+	"github.com/rclone/rclone/lib/pacer"
+)
+
+// postFileCmdTokens limits the number of --post-file-cmd running at
+// once to --post-file-cmd-concurrency
+var postFileCmdTokens struct {
+	mu sync.Mutex
+	n  int
+	td *pacer.TokenDispenser
+}
+
+// getPostFileCmdTokens returns the token dispenser for the current
+// --post-file-cmd-concurrency or nil for unlimited
+func getPostFileCmdTokens() *pacer.TokenDispenser {
+	postFileCmdTokens.mu.Lock()
+	defer postFileCmdTokens.mu.Unlock()
+	n := fs.Config.PostFileCmdConcurrency
+	if n <= 0 {
+		return nil
+	}
+	if postFileCmdTokens.td == nil || postFileCmdTokens.n != n {
+		postFileCmdTokens.n = n
+		postFileCmdTokens.td = pacer.NewTokenDispenser(n)
+	}
+	return postFileCmdTokens.td
+}
+
+// postFileCmd runs the --post-file-cmd if set for remote which has
+// just been transferred to f.
+//
+// The path of the file is passed as the last argument. For local
+// remotes this is the path on disk, otherwise it is remote:path.
+//
+// If the command fails this is counted in the stats and logged. The
+// error is only returned if --post-file-cmd-error is fail.
+func postFileCmd(ctx context.Context, f fs.Fs, remote string) error {
+	args := fs.Config.PostFileCmd
+	if len(args) == 0 {
+		return nil
+	}
+	if td := getPostFileCmdTokens(); td != nil {
+		td.Get()
+		defer td.Put()
+	}
+	filePath := fspath.JoinRootPath(fs.ConfigString(f), remote)
+	cmd := exec.CommandContext(ctx, args[0], append(args[1:], filePath)...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	if output := strings.TrimSpace(out.String()); output != "" {
+		fs.Debugf(fs.LogDirName(f, remote), "--post-file-cmd output: %s", output)
+	}
+	if err == nil {
+		return nil
+	}
+	accounting.Stats(ctx).PostFileCmdErrors(1)
+	err = errors.Wrap(err, "--post-file-cmd failed")
+	if fs.Config.PostFileCmdError != fs.HookErrorFail {
+		fs.Logf(fs.LogDirName(f, remote), "%v", err)
+		return nil
+	}
+	fs.Errorf(fs.LogDirName(f, remote), "%v", err)
+	return fs.CountError(err)
+}
@@ -0,0 +1,142 @@
+package operations
+
+import (
+	"context"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+)
+
+// UnSuffixName removes the current --suffix from the remote, obeying
+// --suffix-keep-extension if set.  It is the reverse of SuffixName.
+//
+// It returns false if remote doesn't have the suffix.
+func UnSuffixName(remote string) (string, bool) {
+	suffix := fs.Config.Suffix
+	if suffix == "" {
+		return remote, true
+	}
+	dir, leaf := path.Split(remote)
+	if fs.Config.SuffixKeepExtension {
+		ext := path.Ext(leaf)
+		base := leaf[:len(leaf)-len(ext)]
+		if !strings.HasSuffix(base, suffix) {
+			return remote, false
+		}
+		leaf = base[:len(base)-len(suffix)] + ext
+	} else {
+		if !strings.HasSuffix(leaf, suffix) {
+			return remote, false
+		}
+		leaf = leaf[:len(leaf)-len(suffix)]
+	}
+	if leaf == "" {
+		return remote, false
+	}
+	return dir + leaf, true
+}
+
+// restoreBackupFile moves src from the backup dir back to its
+// original place in fdst
+func restoreBackupFile(ctx context.Context, fdst fs.Fs, src fs.Object, overwrite bool) error {
+	remote, ok := UnSuffixName(src.Remote())
+	if !ok {
+		fs.Logf(src, "Not restoring as it doesn't have the suffix %q", fs.Config.Suffix)
+		return nil
+	}
+	dst, err := fdst.NewObject(ctx, remote)
+	switch err {
+	case nil:
+		if !overwrite {
+			fs.Logf(src, "Not restoring as %q already exists - use --overwrite to replace it", remote)
+			return nil
+		}
+	case fs.ErrorObjectNotFound:
+		dst = nil
+	default:
+		err = fs.CountError(err)
+		fs.Errorf(src, "Couldn't check where to restore to: %v", err)
+		return err
+	}
+	if fs.Config.DryRun {
+		fs.Logf(src, "Skipped restore to %q as --dry-run is set", remote)
+		return nil
+	}
+	_, err = Move(ctx, fdst, dst, remote, src)
+	if err != nil {
+		return err
+	}
+	fs.Infof(src, "Restored to %q", remote)
+	return nil
+}
+
+// RestoreBackupDir moves the files in fbackup, which was used as the
+// --backup-dir of a sync to fdst, back to where they were in fdst.
+//
+// The files are restored to the same path relative to fdst that they
+// have relative to fbackup with the --suffix removed, so the same
+// --suffix and --suffix-keep-extension must be used as for the sync.
+// Files without the suffix are left alone as are files which already
+// exist in fdst unless overwrite is set.
+//
+// This obeys the filters and --dry-run and removes the directories
+// left empty in fbackup.
+func RestoreBackupDir(ctx context.Context, fdst, fbackup fs.Fs, overwrite bool) error {
+	if SameDir(fdst, fbackup) {
+		return errors.New("can't restore a backup dir to itself")
+	}
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex // protects the vars below
+		errorCount int
+		fatalErr   error
+	)
+	toBeRestored := make(chan fs.Object, fs.Config.Checkers)
+	wg.Add(fs.Config.Transfers)
+	for i := 0; i < fs.Config.Transfers; i++ {
+		go func() {
+			defer wg.Done()
+			for src := range toBeRestored {
+				err := restoreBackupFile(ctx, fdst, src, overwrite)
+				if err != nil {
+					mu.Lock()
+					errorCount++
+					if fserrors.IsFatalError(err) && fatalErr == nil {
+						fatalErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	err := ListFn(ctx, fbackup, func(o fs.Object) {
+		toBeRestored <- o
+	})
+	close(toBeRestored)
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	if fatalErr != nil {
+		return fatalErr
+	}
+	if errorCount > 0 {
+		return errors.Errorf("failed to restore %d files", errorCount)
+	}
+	return Rmdirs(ctx, fbackup, "", true)
+}
+
+// PurgeBackupDir permanently deletes the files in fbackup, which was
+// used as the --backup-dir of a sync, obeying the filters and
+// --dry-run, then removes the directories left empty.
+func PurgeBackupDir(ctx context.Context, fbackup fs.Fs) error {
+	err := Delete(ctx, fbackup)
+	if err != nil {
+		return err
+	}
+	return Rmdirs(ctx, fbackup, "", true)
+}
@@ -0,0 +1,228 @@
+package operations
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// sharedReadChunkSize is the most read from the source at once
+const sharedReadChunkSize = 1024 * 1024
+
+// sharedReads holds the source reads which can be joined keyed by
+// sharedReadKey
+var sharedReads = struct {
+	mu    sync.Mutex
+	reads map[string]*sharedRead
+}{
+	reads: map[string]*sharedRead{},
+}
+
+// sharedReadKey returns the key for src in sharedReads
+func sharedReadKey(src fs.Object) string {
+	f := src.Fs()
+	if f == nil {
+		return src.Remote()
+	}
+	return f.Name() + ":" + f.Root() + "\x00" + src.Remote()
+}
+
+// sharedRead is a single read of a source object which is shared
+// between all the transfers of that object in progress at once.
+//
+// The last bufferSize bytes read are kept so readers can go at
+// slightly different speeds.  A reader which falls further behind
+// than that is detached and opens the source again at the point it
+// got to so it doesn't hold up the others.
+type sharedRead struct {
+	key        string
+	src        fs.Object
+	options    []fs.OpenOption // options used to open in
+	bufferSize int             // max size of buf
+	cancel     func()          // cancel the context for in
+
+	mu      sync.Mutex
+	cond    *sync.Cond    // signalled when reading finishes
+	in      io.ReadCloser // the shared read of src
+	buf     []byte        // data read from in starting at start
+	start   int64         // offset in src of buf[0]
+	err     error         // error reading in - io.EOF at the end
+	reading bool          // set if a reader is reading from in
+	readers int           // number of readers still open
+}
+
+// sharedReader is one reader of a sharedRead
+type sharedReader struct {
+	ctx      context.Context
+	s        *sharedRead
+	pos      int64         // offset in src of the next byte read
+	detached io.ReadCloser // set if too far behind to use s
+	closed   bool
+}
+
+// openShared opens src for reading, joining an existing read of it
+// if one is in progress and hasn't got past the start of its buffer.
+//
+// The read is shared with any other transfers of src which start
+// soon enough afterwards.
+func openShared(ctx context.Context, src fs.Object, options ...fs.OpenOption) (io.ReadCloser, error) {
+	key := sharedReadKey(src)
+	sharedReads.mu.Lock()
+	defer sharedReads.mu.Unlock()
+	if s := sharedReads.reads[key]; s != nil {
+		s.mu.Lock()
+		joinable := s.start == 0 && s.readers > 0 && (s.err == nil || s.err == io.EOF)
+		if joinable {
+			s.readers++
+		}
+		s.mu.Unlock()
+		if joinable {
+			fs.Debugf(src, "Sharing read with transfer already in progress")
+			return &sharedReader{ctx: ctx, s: s}, nil
+		}
+	}
+	// The shared read mustn't be cancelled when the transfer which
+	// started it is, so it gets its own context.
+	readCtx, cancel := context.WithCancel(context.Background())
+	in, err := NewReOpen(readCtx, src, fs.Config.LowLevelRetries, options...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	s := &sharedRead{
+		key:        key,
+		src:        src,
+		options:    options,
+		bufferSize: int(fs.Config.BufferSize),
+		cancel:     cancel,
+		in:         in,
+		readers:    1,
+	}
+	if s.bufferSize < sharedReadChunkSize {
+		s.bufferSize = sharedReadChunkSize
+	}
+	s.cond = sync.NewCond(&s.mu)
+	sharedReads.reads[key] = s
+	return &sharedReader{ctx: ctx, s: s}, nil
+}
+
+// fill reads the next chunk from the source - call with lock held
+//
+// The lock is released while reading so other readers can use the
+// data already in the buffer.
+func (s *sharedRead) fill() {
+	s.reading = true
+	s.mu.Unlock()
+	chunk := make([]byte, sharedReadChunkSize)
+	n, err := io.ReadFull(s.in, chunk)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	s.mu.Lock()
+	s.buf = append(s.buf, chunk[:n]...)
+	if excess := len(s.buf) - s.bufferSize; excess > 0 {
+		// nobody new can join once the start has gone
+		s.buf = s.buf[excess:]
+		s.start += int64(excess)
+	}
+	s.err = err
+	s.reading = false
+	s.cond.Broadcast()
+}
+
+// forget removes s from sharedReads so no more readers join it
+func (s *sharedRead) forget() {
+	sharedReads.mu.Lock()
+	if sharedReads.reads[s.key] == s {
+		delete(sharedReads.reads, s.key)
+	}
+	sharedReads.mu.Unlock()
+}
+
+// release is called when a reader has finished with s
+func (s *sharedRead) release() (err error) {
+	s.mu.Lock()
+	s.readers--
+	last := s.readers == 0
+	s.mu.Unlock()
+	if !last {
+		return nil
+	}
+	s.forget()
+	err = s.in.Close()
+	s.cancel()
+	return err
+}
+
+// detach opens the source at the read point of r so it can carry on
+// without the shared read
+func (r *sharedReader) detach() error {
+	fs.Debugf(r.s.src, "Transfer fell too far behind shared read - opening source again at %d", r.pos)
+	options := []fs.OpenOption{&fs.RangeOption{Start: r.pos, End: -1}}
+	for _, option := range r.s.options {
+		switch option.(type) {
+		case *fs.HashesOption, *fs.RangeOption:
+			// these only apply when reading from the start
+		default:
+			options = append(options, option)
+		}
+	}
+	in, err := NewReOpen(r.ctx, r.s.src, fs.Config.LowLevelRetries, options...)
+	if err != nil {
+		return err
+	}
+	r.detached = in
+	return r.s.release()
+}
+
+// Read reads up to len(p) bytes into p
+func (r *sharedReader) Read(p []byte) (n int, err error) {
+	if r.closed {
+		return 0, errorFileClosed
+	}
+	if r.detached != nil {
+		return r.detached.Read(p)
+	}
+	s := r.s
+	s.mu.Lock()
+	for {
+		if r.pos < s.start {
+			s.mu.Unlock()
+			err = r.detach()
+			if err != nil {
+				return 0, err
+			}
+			return r.detached.Read(p)
+		}
+		if end := s.start + int64(len(s.buf)); r.pos < end {
+			n = copy(p, s.buf[r.pos-s.start:])
+			r.pos += int64(n)
+			s.mu.Unlock()
+			return n, nil
+		}
+		if s.err != nil {
+			err = s.err
+			s.mu.Unlock()
+			return 0, err
+		}
+		if s.reading {
+			s.cond.Wait()
+		} else {
+			s.fill()
+		}
+	}
+}
+
+// Close the reader
+func (r *sharedReader) Close() error {
+	if r.closed {
+		return errorFileClosed
+	}
+	r.closed = true
+	if r.detached != nil {
+		return r.detached.Close()
+	}
+	return r.s.release()
+}
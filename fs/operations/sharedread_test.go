@@ -0,0 +1,108 @@
+package operations
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/rclone/rclone/lib/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// check interface
+var _ io.ReadCloser = (*sharedReader)(nil)
+
+// this is a wrapper for a mockobject which counts the Open calls
+type countOpenObject struct {
+	fs.Object
+	opens int32
+}
+
+// Open opens the file for read.  Call Close() on the returned io.ReadCloser
+func (o *countOpenObject) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
+	atomic.AddInt32(&o.opens, 1)
+	return o.Object.Open(ctx, options...)
+}
+
+func newCountOpenObject(content []byte) *countOpenObject {
+	return &countOpenObject{
+		Object: mockobject.New("potato").WithContent(content, mockobject.SeekModeNone),
+	}
+}
+
+func TestSharedReadConcurrent(t *testing.T) {
+	ctx := context.Background()
+	content := []byte(random.String(3*sharedReadChunkSize + 17))
+	src := newCountOpenObject(content)
+
+	const n = 4
+	var readers []io.ReadCloser
+	for i := 0; i < n; i++ {
+		in, err := openShared(ctx, src)
+		require.NoError(t, err)
+		readers = append(readers, in)
+	}
+
+	var wg sync.WaitGroup
+	for _, in := range readers {
+		wg.Add(1)
+		go func(in io.ReadCloser) {
+			defer wg.Done()
+			got, err := ioutil.ReadAll(in)
+			assert.NoError(t, err)
+			assert.Equal(t, content, got)
+			assert.NoError(t, in.Close())
+		}(in)
+	}
+	wg.Wait()
+
+	// the source should be read once if nobody fell behind
+	assert.True(t, atomic.LoadInt32(&src.opens) <= n)
+	assert.Equal(t, 0, len(sharedReads.reads))
+}
+
+func TestSharedReadDetach(t *testing.T) {
+	ctx := context.Background()
+	content := []byte(random.String(int(fs.Config.BufferSize) + 3*sharedReadChunkSize))
+	src := newCountOpenObject(content)
+
+	fast, err := openShared(ctx, src)
+	require.NoError(t, err)
+	slow, err := openShared(ctx, src)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), src.opens)
+
+	// Read a little of the slow one then all of the fast one
+	buf := make([]byte, 10)
+	_, err = io.ReadFull(slow, buf)
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(fast)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+
+	// A new transfer can't join now so gets its own read
+	late, err := openShared(ctx, src)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), src.opens)
+	got, err = ioutil.ReadAll(late)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+	require.NoError(t, late.Close())
+
+	// The slow one should have to open the source again
+	got, err = ioutil.ReadAll(slow)
+	require.NoError(t, err)
+	assert.Equal(t, content[10:], got)
+	assert.Equal(t, int32(3), src.opens)
+
+	require.NoError(t, fast.Close())
+	require.NoError(t, slow.Close())
+	assert.Equal(t, errorFileClosed, slow.Close())
+	assert.Equal(t, 0, len(sharedReads.reads))
+}
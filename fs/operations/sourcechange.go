@@ -0,0 +1,79 @@
+package operations
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// sourceChanged returns true if src has changed since it was found,
+// which it checks by finding it again and comparing the size,
+// modification time and hash the source gives for it
+func sourceChanged(ctx context.Context, src fs.Object, hashType hash.Type) bool {
+	fsrc, ok := src.Fs().(fs.Fs)
+	if !ok {
+		return false
+	}
+	newSrc, err := fsrc.NewObject(ctx, src.Remote())
+	if err != nil {
+		fs.Debugf(src, "Couldn't find source again to see if it changed: %v", err)
+		return err == fs.ErrorObjectNotFound
+	}
+	if newSrc.Size() != src.Size() || !newSrc.ModTime(ctx).Equal(src.ModTime(ctx)) {
+		return true
+	}
+	if hashType == hash.None {
+		return false
+	}
+	srcSum, err := src.Hash(ctx, hashType)
+	if err != nil || srcSum == "" {
+		return false
+	}
+	newSrcSum, err := newSrc.Hash(ctx, hashType)
+	if err != nil || newSrcSum == "" {
+		return false
+	}
+	return newSrcSum != srcSum
+}
+
+// copyMismatch is called when the copy of src doesn't match it, with
+// err saying how.
+//
+// If src changed during the transfer then this is counted in the
+// stats and dealt with according to --on-source-change, returning
+// nil if the copy should be kept.  Otherwise the copy was corrupted
+// and this returns err.
+func copyMismatch(ctx context.Context, src fs.Object, hashType hash.Type, err error) error {
+	if !sourceChanged(ctx, src, hashType) {
+		return errors.Wrap(err, "corrupted on transfer")
+	}
+	accounting.Stats(ctx).SourceChanged()
+	err = errors.Wrap(err, "source changed during transfer")
+	switch fs.Config.OnSourceChange {
+	case fs.SourceChangeIgnore:
+		fs.Logf(src, "Keeping copy as --on-source-change is ignore: %v", err)
+		return nil
+	case fs.SourceChangeFail:
+		return fserrors.NoRetryError(err)
+	}
+	return fserrors.RetryError(err)
+}
+
+// truncatedRead is called when src was truncated while being read
+// for a transfer, with err saying how.  This deals with it according
+// to --on-truncated-read, returning the error to use or skip set if
+// the transfer should be skipped.
+func truncatedRead(src fs.Object, err error) (skip bool, _ error) {
+	switch fs.Config.OnTruncatedRead {
+	case fs.TruncatedReadSkip:
+		fs.Logf(src, "Skipping transfer as --on-truncated-read is skip: %v", err)
+		return true, nil
+	case fs.TruncatedReadRetry:
+		return false, fserrors.RetryError(err)
+	}
+	return false, fserrors.NoRetryError(err)
+}
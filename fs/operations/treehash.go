@@ -0,0 +1,144 @@
+package operations
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// Prefixes for the nodes of the tree hash so a file can never have
+// the same digest as a directory.
+const (
+	treeHashFileNode = 0x00
+	treeHashDirNode  = 0x01
+)
+
+// treeHashDir is a directory in the tree being hashed
+type treeHashDir struct {
+	files map[string]string       // leaf name to hex hash of the file
+	dirs  map[string]*treeHashDir // leaf name to subdirectory
+}
+
+func newTreeHashDir() *treeHashDir {
+	return &treeHashDir{
+		files: map[string]string{},
+		dirs:  map[string]*treeHashDir{},
+	}
+}
+
+// add the hex hash sum of the file at remote to the tree
+func (d *treeHashDir) add(remote, sum string) {
+	parts := strings.Split(remote, "/")
+	for _, part := range parts[:len(parts)-1] {
+		sub := d.dirs[part]
+		if sub == nil {
+			sub = newTreeHashDir()
+			d.dirs[part] = sub
+		}
+		d = sub
+	}
+	d.files[parts[len(parts)-1]] = strings.ToLower(sum)
+}
+
+// treeHashEntry is one entry of a directory node
+type treeHashEntry struct {
+	kind   byte   // 'f' for a file or 'd' for a directory
+	name   string // leaf name
+	digest []byte // digest of the file or directory node
+}
+
+// digest returns the SHA-256 digest of the directory node
+func (d *treeHashDir) digest() []byte {
+	entries := make([]treeHashEntry, 0, len(d.files)+len(d.dirs))
+	for name, sum := range d.files {
+		leaf := sha256.Sum256(append([]byte{treeHashFileNode}, sum...))
+		entries = append(entries, treeHashEntry{kind: 'f', name: name, digest: leaf[:]})
+	}
+	for name, sub := range d.dirs {
+		entries = append(entries, treeHashEntry{kind: 'd', name: name, digest: sub.digest()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].name != entries[j].name {
+			return entries[i].name < entries[j].name
+		}
+		return entries[i].kind > entries[j].kind
+	})
+	h := sha256.New()
+	_, _ = h.Write([]byte{treeHashDirNode})
+	for _, entry := range entries {
+		_, _ = h.Write([]byte{entry.kind})
+		_, _ = h.Write([]byte(entry.name))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write(entry.digest)
+	}
+	return h.Sum(nil)
+}
+
+// treeHashRoot returns the hex encoded Merkle root of the files in
+// sums which maps remote to hex hash sum
+func treeHashRoot(sums map[string]string) string {
+	root := newTreeHashDir()
+	for remote, sum := range sums {
+		root.add(remote, sum)
+	}
+	return hex.EncodeToString(root.digest())
+}
+
+// TreeHash returns a Merkle root of the Fs made from the paths of all
+// the objects in it and their hashes of type ht.
+//
+// The root is made like this, with all hashes SHA-256
+//
+//   - the leaf of a file is hash(0x00 + its hash of type ht in lower case hex)
+//   - a directory node is hash(0x01 + its entries) where the entries
+//     are sorted by name, files first, and each is the kind ('f' or
+//     'd'), the name, a 0x00 byte then the 32 byte leaf or node
+//   - the root is the node of the top directory in lower case hex
+//
+// Directories with no files in don't appear in the tree so the result
+// is the same on backends which don't have directories, and the node
+// of a directory is the root of the tree hash of that directory.
+//
+// It is an error if a hash of any object can't be read.
+func TreeHash(ctx context.Context, ht hash.Type, f fs.Fs) (string, error) {
+	if ht == hash.None {
+		return "", errors.New("need a hash type for the tree hash")
+	}
+	var (
+		mu      sync.Mutex
+		sums    = map[string]string{}
+		errs    int
+		lastErr error
+	)
+	err := ListFn(ctx, f, func(o fs.Object) {
+		sum, err := hashSum(ctx, ht, o)
+		if err == nil && sum == "" {
+			err = errors.Errorf("no %v hash available", ht)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			err = fs.CountError(err)
+			fs.Errorf(o, "Failed to read hash for tree hash: %v", err)
+			errs++
+			lastErr = err
+			return
+		}
+		sums[o.Remote()] = sum
+	})
+	if err != nil {
+		return "", err
+	}
+	if errs > 0 {
+		return "", errors.Wrapf(lastErr, "failed to read %d hashes, last error", errs)
+	}
+	fs.Debugf(f, "Tree hash of %d files", len(sums))
+	return treeHashRoot(sums), nil
+}
@@ -0,0 +1,66 @@
+package operations
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// hashingReadCloser hashes the data read through it
+type hashingReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// hashUpload returns a MultiHasher which hashes the data read from in
+// with hashType and in wrapped to do so.
+//
+// This is only done if f can read the hash back cheaply, which means
+// it was computed by the backend on upload, and --on-hash-mismatch
+// isn't off, otherwise it returns nil and in.
+func hashUpload(f fs.Fs, hashType hash.Type, in io.ReadCloser) (*hash.MultiHasher, io.ReadCloser) {
+	if hashType == hash.None || f.Features().SlowHash || fs.Config.OnHashMismatch == fs.HashMismatchOff {
+		return nil, in
+	}
+	hasher, err := hash.NewMultiHasherTypes(hash.NewHashSet(hashType))
+	if err != nil {
+		return nil, in
+	}
+	return hasher, hashingReadCloser{
+		Reader: io.TeeReader(in, hasher),
+		Closer: in,
+	}
+}
+
+// verifyUploadHash checks the hash of dst, which the backend computed
+// on upload, matches uploadSum, the hash of the data read to upload
+// it.
+//
+// A mismatch is dealt with according to --on-hash-mismatch. This
+// returns false if the copy should be kept despite a mismatch.
+func verifyUploadHash(ctx context.Context, dst fs.Object, hashType hash.Type, uploadSum string) (bool, error) {
+	if uploadSum == "" {
+		return true, nil
+	}
+	dstSum, err := dst.Hash(ctx, hashType)
+	if err != nil {
+		fs.Debugf(dst, "Can't read %v hash to verify upload: %v", hashType, err)
+		return true, nil
+	}
+	if dstSum == "" || dstSum == uploadSum {
+		return true, nil
+	}
+	err = errors.Errorf("corrupted on upload: %v hash differ %q uploaded vs %q on destination", hashType, uploadSum, dstSum)
+	switch fs.Config.OnHashMismatch {
+	case fs.HashMismatchWarn:
+		fs.Logf(dst, "Keeping copy as --on-hash-mismatch is warn: %v", err)
+		return false, nil
+	case fs.HashMismatchFatal:
+		return false, fserrors.FatalError(err)
+	}
+	return false, err
+}
@@ -0,0 +1,106 @@
+package operations
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// verifyHashMismatch makes the --verify-hash-mismatch check on src
+// and dst which are the same size but whose ht hashes differ.
+//
+// It returns true if the check shows that the hashes were wrong and
+// the files are the same so src doesn't need to be transferred.
+func verifyHashMismatch(ctx context.Context, src fs.ObjectInfo, dst fs.Object, ht hash.Type) bool {
+	switch fs.Config.VerifyHashMismatch {
+	case fs.VerifyHashMismatchRehash:
+		return verifyRehash(ctx, src, dst, ht)
+	case fs.VerifyHashMismatchSecondHash:
+		return verifySecondHash(ctx, src, dst, ht)
+	}
+	return false
+}
+
+// verifyRehash downloads src and calculates its ht hash to see
+// whether the hash src reported was stale.
+func verifyRehash(ctx context.Context, src fs.ObjectInfo, dst fs.Object, ht hash.Type) bool {
+	srcObj, ok := src.(fs.Object)
+	if !ok {
+		fs.Debugf(src, "--verify-hash-mismatch: can't rehash as source can't be read - transferring")
+		return false
+	}
+	dstHash, err := dst.Hash(ctx, ht)
+	if err != nil || dstHash == "" {
+		fs.Debugf(src, "--verify-hash-mismatch: can't read destination %v - transferring", ht)
+		return false
+	}
+	srcHash, err := rehash(ctx, srcObj, ht)
+	if err != nil {
+		err = fs.CountError(err)
+		fs.Errorf(src, "--verify-hash-mismatch: failed to rehash source: %v", err)
+		return false
+	}
+	if srcHash != dstHash {
+		fs.Infof(src, "--verify-hash-mismatch: rehashed source %v %s doesn't match destination %s - transferring", ht, srcHash, dstHash)
+		return false
+	}
+	fs.Logf(src, "--verify-hash-mismatch: rehashed source %v matches destination so the source reported a stale hash - not transferring", ht)
+	return true
+}
+
+// rehash reads o and returns its ht hash
+func rehash(ctx context.Context, o fs.Object, ht hash.Type) (sum string, err error) {
+	tr := accounting.Stats(ctx).NewCheckingTransfer(o)
+	defer func() {
+		tr.Done(err)
+	}()
+	in, err := o.Open(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open")
+	}
+	in = tr.Account(ctx, in).WithBuffer() // account and buffer the transfer
+	defer fs.CheckClose(in, &err)
+	hasher, err := hash.NewMultiHasherTypes(hash.NewHashSet(ht))
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(hasher, in)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read")
+	}
+	return hasher.Sums()[ht], nil
+}
+
+// verifySecondHash compares src and dst with a hash other than ht if
+// they have one in common.
+func verifySecondHash(ctx context.Context, src fs.ObjectInfo, dst fs.Object, ht hash.Type) bool {
+	second := hash.None
+	for _, t := range src.Fs().Hashes().Overlap(dst.Fs().Hashes()).Array() {
+		if t != ht {
+			second = t
+			break
+		}
+	}
+	if second == hash.None {
+		fs.Debugf(src, "--verify-hash-mismatch: no second hash in common - transferring")
+		return false
+	}
+	same, htOut, _, _, err := checkHashes(ctx, src, dst, second)
+	if err != nil {
+		return false
+	}
+	if htOut == hash.None {
+		fs.Debugf(src, "--verify-hash-mismatch: %v missing - transferring", second)
+		return false
+	}
+	if !same {
+		fs.Infof(src, "--verify-hash-mismatch: %v differs too - transferring", second)
+		return false
+	}
+	fs.Logf(src, "--verify-hash-mismatch: %v matches though %v differs so one of them is wrong - not transferring", second, ht)
+	return true
+}
@@ -0,0 +1,77 @@
+package operations
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// verifyUploadWait is the least time --verify-upload waits for the
+// metadata of an upload to become right before failing it
+var verifyUploadWait = 10 * time.Second
+
+// checkUploaded fetches the object at remote in f with NewObject and
+// checks its size is size, unless that is negative, and its hashType
+// hash is wantSum, unless that is blank.
+func checkUploaded(ctx context.Context, f fs.Fs, remote string, size int64, hashType hash.Type, wantSum string) error {
+	o, err := f.NewObject(ctx, remote)
+	if err != nil {
+		return errors.Wrap(err, "can't find the object")
+	}
+	if size >= 0 && o.Size() != size {
+		return errors.Errorf("size %d on destination vs %d uploaded", o.Size(), size)
+	}
+	if hashType == hash.None || wantSum == "" {
+		return nil
+	}
+	gotSum, err := o.Hash(ctx, hashType)
+	if err == hash.ErrUnsupported {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "can't read the %v hash", hashType)
+	}
+	if gotSum != "" && gotSum != wantSum {
+		return errors.Errorf("%v hash %q on destination vs %q uploaded", hashType, gotSum, wantSum)
+	}
+	return nil
+}
+
+// verifyUpload checks the object uploaded from src to remote in f was
+// stored correctly if --verify-upload is set by fetching its metadata
+// again and comparing the size and the hashType hash with wantSum.
+//
+// As the metadata may take a while to be right on an eventually
+// consistent remote the check is retried for the --consistency-window
+// or verifyUploadWait if longer.
+func verifyUpload(ctx context.Context, f fs.Fs, remote string, src fs.ObjectInfo, hashType hash.Type, wantSum string) error {
+	if !fs.Config.VerifyUpload {
+		return nil
+	}
+	wait := consistencyWindow(f)
+	if wait < verifyUploadWait {
+		wait = verifyUploadWait
+	}
+	start := time.Now()
+	sleep := 100 * time.Millisecond
+	for tries := 1; ; tries++ {
+		err := checkUploaded(ctx, f, remote, src.Size(), hashType, wantSum)
+		if err == nil {
+			fs.Debugf(fs.LogDirName(f, remote), "Upload verified after %v", time.Since(start))
+			return nil
+		}
+		if time.Since(start)+sleep > wait {
+			return errors.Wrapf(err, "upload verification failed after %d tries in %v", tries, time.Since(start).Round(time.Millisecond))
+		}
+		fs.Debugf(fs.LogDirName(f, remote), "Upload not verified yet - trying again in %v: %v", sleep, err)
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "upload verification cancelled")
+		case <-time.After(sleep):
+		}
+		sleep *= 2
+	}
+}
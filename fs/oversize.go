@@ -0,0 +1,50 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Oversize describes what to do with a file which is bigger than the
+// destination can store
+type Oversize byte
+
+// Oversize constants
+const (
+	OversizeError Oversize = iota
+	OversizeSkip
+	OversizeSplit
+	OversizeDefault = OversizeError
+)
+
+var oversizeToString = []string{
+	OversizeError: "error",
+	OversizeSkip:  "skip",
+	OversizeSplit: "split",
+}
+
+// String turns an Oversize into a string
+func (m Oversize) String() string {
+	if m >= Oversize(len(oversizeToString)) {
+		return fmt.Sprintf("Oversize(%d)", m)
+	}
+	return oversizeToString[m]
+}
+
+// Set an Oversize
+func (m *Oversize) Set(s string) error {
+	for n, name := range oversizeToString {
+		if s != "" && name == strings.ToLower(s) {
+			*m = Oversize(n)
+			return nil
+		}
+	}
+	return errors.Errorf("Unknown oversize mode %q", s)
+}
+
+// Type of the value
+func (m *Oversize) Type() string {
+	return "string"
+}
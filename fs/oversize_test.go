@@ -0,0 +1,22 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// Check it satisfies the interface
+var _ pflag.Value = (*Oversize)(nil)
+
+func TestOversizeSet(t *testing.T) {
+	var m Oversize
+	assert.NoError(t, m.Set("SPLIT"))
+	assert.Equal(t, OversizeSplit, m)
+	assert.Equal(t, "split", m.String())
+	assert.NoError(t, m.Set("skip"))
+	assert.Equal(t, OversizeSkip, m)
+	assert.Error(t, m.Set("potato"))
+	assert.Equal(t, "Oversize(17)", Oversize(17).String())
+}
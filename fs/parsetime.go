@@ -0,0 +1,59 @@
+package fs
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Time is a time.Time which can be set from a flag either as a date
+// or as a duration before now
+type Time time.Time
+
+// ParseTime parses a time string.  This can be in any of the date
+// formats --max-age accepts or a duration before now as parsed by
+// ParseDuration.  "off" returns the zero time.
+func ParseTime(s string) (t time.Time, err error) {
+	if s == "off" {
+		return t, nil
+	}
+	for _, timeFormat := range timeFormats {
+		t, err = time.Parse(timeFormat, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	d, err := ParseDuration(s)
+	if err != nil || Duration(d) == DurationOff {
+		return time.Time{}, errors.Errorf("couldn't parse %q as a time or a duration", s)
+	}
+	return time.Now().Add(-d), nil
+}
+
+// IsSet returns if the time is set
+func (t Time) IsSet() bool {
+	return !time.Time(t).IsZero()
+}
+
+// Turn Time into a string
+func (t Time) String() string {
+	if !t.IsSet() {
+		return "off"
+	}
+	return time.Time(t).Format(time.RFC3339Nano)
+}
+
+// Set a Time
+func (t *Time) Set(s string) error {
+	instant, err := ParseTime(s)
+	if err != nil {
+		return err
+	}
+	*t = Time(instant)
+	return nil
+}
+
+// Type of the value
+func (t Time) Type() string {
+	return "Time"
+}
@@ -0,0 +1,52 @@
+package fs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Check it satisfies the interface
+var _ pflag.Value = (*Time)(nil)
+
+func TestParseTime(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want time.Time
+		err  bool
+	}{
+		{"off", time.Time{}, false},
+		{"2001-02-03", time.Date(2001, 2, 3, 0, 0, 0, 0, time.UTC), false},
+		{"2001-02-03 10:11:12", time.Date(2001, 2, 3, 10, 11, 12, 0, time.UTC), false},
+		{"2001-02-03T10:11:12", time.Date(2001, 2, 3, 10, 11, 12, 0, time.UTC), false},
+		{"2001-02-03T10:11:12.123Z", time.Date(2001, 2, 3, 10, 11, 12, 123000000, time.UTC), false},
+		{"", time.Time{}, true},
+		{"potato", time.Time{}, true},
+	} {
+		got, err := ParseTime(test.in)
+		if test.err {
+			require.Error(t, err, test.in)
+		} else {
+			require.NoError(t, err, test.in)
+			assert.True(t, test.want.Equal(got), test.in)
+		}
+	}
+
+	// Durations are before now
+	got, err := ParseTime("1h")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(-time.Hour), got, time.Minute)
+}
+
+func TestTimeSetString(t *testing.T) {
+	var instant Time
+	assert.False(t, instant.IsSet())
+	assert.Equal(t, "off", instant.String())
+	require.NoError(t, instant.Set("2001-02-03T10:11:12Z"))
+	assert.True(t, instant.IsSet())
+	assert.Equal(t, "2001-02-03T10:11:12Z", instant.String())
+	assert.Error(t, instant.Set("potato"))
+}
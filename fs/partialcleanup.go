@@ -0,0 +1,50 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PartialCleanup describes what to do with the partial objects left
+// behind by failed transfers
+type PartialCleanup byte
+
+// PartialCleanup constants
+const (
+	PartialCleanupDelete PartialCleanup = iota
+	PartialCleanupKeep
+	PartialCleanupResume
+	PartialCleanupDefault = PartialCleanupKeep
+)
+
+var partialCleanupToString = []string{
+	PartialCleanupDelete: "delete",
+	PartialCleanupKeep:   "keep",
+	PartialCleanupResume: "resume",
+}
+
+// String turns a PartialCleanup into a string
+func (m PartialCleanup) String() string {
+	if m >= PartialCleanup(len(partialCleanupToString)) {
+		return fmt.Sprintf("PartialCleanup(%d)", m)
+	}
+	return partialCleanupToString[m]
+}
+
+// Set a PartialCleanup
+func (m *PartialCleanup) Set(s string) error {
+	for n, name := range partialCleanupToString {
+		if s != "" && name == strings.ToLower(s) {
+			*m = PartialCleanup(n)
+			return nil
+		}
+	}
+	return errors.Errorf("Unknown partial cleanup mode %q", s)
+}
+
+// Type of the value
+func (m *PartialCleanup) Type() string {
+	return "string"
+}
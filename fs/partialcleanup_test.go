@@ -0,0 +1,22 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// Check it satisfies the interface
+var _ pflag.Value = (*PartialCleanup)(nil)
+
+func TestPartialCleanupSet(t *testing.T) {
+	var m PartialCleanup
+	assert.NoError(t, m.Set("KEEP"))
+	assert.Equal(t, PartialCleanupKeep, m)
+	assert.Equal(t, "keep", m.String())
+	assert.NoError(t, m.Set("resume"))
+	assert.Equal(t, PartialCleanupResume, m)
+	assert.Error(t, m.Set("potato"))
+	assert.Equal(t, "PartialCleanup(17)", PartialCleanup(17).String())
+}
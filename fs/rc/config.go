@@ -6,6 +6,7 @@ package rc
 
 import (
 	"context"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -124,3 +125,60 @@ func rcOptionsSet(ctx context.Context, in Params) (out Params, err error) {
 	}
 	return out, nil
 }
+
+// Redacted is shown in place of secret values by DumpOptions
+const Redacted = "REDACTED"
+
+// DumpOptions returns the current values of all the option blocks as
+// they would be shown by options/get, but with any secrets such as
+// passwords and the values of HTTP headers replaced with Redacted.
+func DumpOptions() (out Params, err error) {
+	out = make(Params)
+	for name, options := range optionBlock {
+		var block interface{}
+		err = Reshape(&block, options)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read options from block %q", name)
+		}
+		out[name] = redactOptions("", block)
+	}
+	return out, nil
+}
+
+// isSecretOption returns true if the option called name holds a secret
+func isSecretOption(name string) bool {
+	name = strings.ToLower(name)
+	for _, secret := range []string{"pass", "secret", "token"} {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactOptions replaces the secrets in the option value called
+// name, recursing into any blocks or lists in it.
+func redactOptions(name string, value interface{}) interface{} {
+	switch x := value.(type) {
+	case map[string]interface{}:
+		for k, v := range x {
+			x[k] = redactOptions(k, v)
+		}
+		// HTTP headers may contain auth so blank their values
+		if _, isHeader := x["Key"]; isHeader && x["Value"] != nil {
+			x["Value"] = Redacted
+		}
+	case []interface{}:
+		if isSecretOption(name) && len(x) > 0 {
+			return Redacted
+		}
+		for i, v := range x {
+			x[i] = redactOptions(name, v)
+		}
+	case string:
+		if isSecretOption(name) && x != "" {
+			return Redacted
+		}
+	}
+	return value
+}
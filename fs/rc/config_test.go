@@ -143,3 +143,44 @@ func TestOptionsSet(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to write options")
 
 }
+
+func TestDumpOptions(t *testing.T) {
+	defer clearOptionBlock()()
+	secrets := struct {
+		User            string
+		Pass            string
+		EmptyPass       string
+		AskPassword     bool
+		PasswordCommand []string
+		Headers         []*fs.HTTPOption
+		Nested          struct{ ClientSecret string }
+	}{
+		User:            "user",
+		Pass:            "pass",
+		AskPassword:     true,
+		PasswordCommand: []string{"echo", "pass"},
+		Headers:         []*fs.HTTPOption{{Key: "Authorization", Value: "Bearer token"}},
+	}
+	secrets.Nested.ClientSecret = "secret"
+	AddOption("secrets", &secrets)
+
+	out, err := DumpOptions()
+	require.NoError(t, err)
+	assert.Equal(t, Params{
+		"secrets": map[string]interface{}{
+			"User":            "user",
+			"Pass":            Redacted,
+			"EmptyPass":       "",
+			"AskPassword":     true,
+			"PasswordCommand": Redacted,
+			"Headers": []interface{}{
+				map[string]interface{}{"Key": "Authorization", "Value": Redacted},
+			},
+			"Nested": map[string]interface{}{"ClientSecret": Redacted},
+		},
+	}, out)
+
+	// the options themselves are unchanged
+	assert.Equal(t, "pass", secrets.Pass)
+	assert.Equal(t, "Bearer token", secrets.Headers[0].Value)
+}
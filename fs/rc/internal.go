@@ -6,6 +6,7 @@ import (
 	"context"
 	"os"
 	"runtime"
+	"sort"
 	"time"
 
 	"github.com/pkg/errors"
@@ -198,6 +199,69 @@ func rcVersion(ctx context.Context, in Params) (out Params, err error) {
 	return out, nil
 }
 
+func init() {
+	Add(Call{
+		Path:  "core/backends",
+		Fn:    rcBackends,
+		Title: "Lists the backends and their capabilities.",
+		Help: `
+This returns the backends compiled into rclone and what each of them
+supports, eg for a UI to enable or disable options depending on the
+type of remote chosen.
+
+    rclone rc core/backends
+
+Returns
+
+- backends - an array of the backends sorted by name, each with
+    - name - name of the backend, eg "s3"
+    - description - description of the backend
+    - prefix - prefix of the flags of the backend
+    - commands - names of the backend specific commands
+    - known - true if the capabilities below are known
+    - features - optional features and whether they are available, eg "Copy", "Move", "About"
+    - hashes - names of the hashes supported
+    - precision - precision of the modification times in ns
+
+The capabilities of a backend are only known once a remote of it has
+been used, and as some depend on the config of the remote they are
+those of the last remote of the backend used.  Use operations/fsinfo
+for the capabilities of a particular remote.
+`,
+	})
+}
+
+// Return the backends and their capabilities
+func rcBackends(ctx context.Context, in Params) (out Params, err error) {
+	backends := []Params{}
+	for _, ri := range fs.Registry {
+		commands := []string{}
+		for _, command := range ri.CommandHelp {
+			commands = append(commands, command.Name)
+		}
+		backend := Params{
+			"name":        ri.Name,
+			"description": ri.Description,
+			"prefix":      ri.Prefix,
+			"commands":    commands,
+			"known":       false,
+		}
+		if bf := fs.GetBackendFeatures(ri.Name); bf != nil {
+			backend["known"] = true
+			backend["features"] = bf.Features
+			backend["hashes"] = bf.Hashes
+			backend["precision"] = bf.Precision
+		}
+		backends = append(backends, backend)
+	}
+	sort.Slice(backends, func(i, j int) bool {
+		return backends[i]["name"].(string) < backends[j]["name"].(string)
+	})
+	return Params{
+		"backends": backends,
+	}, nil
+}
+
 func init() {
 	Add(Call{
 		Path:  "core/obscure",
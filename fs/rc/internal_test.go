@@ -9,8 +9,11 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
 	"github.com/rclone/rclone/fs/config/obscure"
+	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/version"
+	"github.com/rclone/rclone/fstest/mockfs"
 )
 
 func TestInternalNoop(t *testing.T) {
@@ -97,6 +100,47 @@ func TestCoreVersion(t *testing.T) {
 	assert.True(t, len(v) >= 2)
 }
 
+func TestCoreBackends(t *testing.T) {
+	fs.Register(&fs.RegInfo{
+		Name:        "rcbackendstest",
+		Description: "Test backend",
+		NewFs: func(name, root string, m configmap.Mapper) (fs.Fs, error) {
+			f := mockfs.NewFs(name, root)
+			f.SetHashes(hash.NewHashSet(hash.MD5))
+			return f, nil
+		},
+		CommandHelp: []fs.CommandHelp{{Name: "potato"}},
+	})
+	call := Calls.Get("core/backends")
+	assert.NotNil(t, call)
+
+	find := func() Params {
+		out, err := call.Fn(context.Background(), Params{})
+		require.NoError(t, err)
+		for _, backend := range out["backends"].([]Params) {
+			if backend["name"] == "rcbackendstest" {
+				return backend
+			}
+		}
+		t.Fatal("backend not found")
+		return nil
+	}
+
+	backend := find()
+	assert.Equal(t, "Test backend", backend["description"])
+	assert.Equal(t, []string{"potato"}, backend["commands"])
+	assert.Equal(t, false, backend["known"])
+	assert.Nil(t, backend["features"])
+
+	_, err := fs.NewFs(":rcbackendstest:")
+	require.NoError(t, err)
+	backend = find()
+	assert.Equal(t, true, backend["known"])
+	assert.Equal(t, []string{"MD5"}, backend["hashes"])
+	features := backend["features"].(map[string]bool)
+	assert.Contains(t, features, "Copy")
+}
+
 func TestCoreObscure(t *testing.T) {
 	call := Calls.Get("core/obscure")
 	assert.NotNil(t, call)
@@ -30,6 +30,12 @@ type Job struct {
 	Output    rc.Params `json:"output"`
 	Stop      func()    `json:"-"`
 
+	// timeout is the time the job is allowed to run for if set
+	timeout time.Duration
+
+	// bwLimiter limits the bandwidth of the job's transfers
+	bwLimiter *accounting.BwLimiter
+
 	// realErr is the Error before printing it as a string, it's used to return
 	// the real error to the upper application layers while still printing the
 	// string error message.
@@ -147,7 +153,11 @@ func (job *Job) run(ctx context.Context, fn rc.Func, in rc.Params) {
 			job.finish(nil, errors.Errorf("panic received: %v \n%s", r, string(debug.Stack())))
 		}
 	}()
-	job.finish(fn(ctx, in))
+	out, err := fn(ctx, in)
+	if err != nil && job.timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+		err = errors.Wrapf(err, "job timed out after %v", job.timeout)
+	}
+	job.finish(out, err)
 }
 
 func getGroup(in rc.Params) string {
@@ -160,6 +170,39 @@ func getGroup(in rc.Params) string {
 	return group
 }
 
+func getTimeout(in rc.Params) time.Duration {
+	// Check to see if the timeout is set
+	timeout, err := in.GetDuration("_timeout")
+	if rc.NotErrParamNotFound(err) {
+		fs.Errorf(nil, "Can't get _timeout param %+v", err)
+	}
+	delete(in, "_timeout")
+	return timeout
+}
+
+func getBwLimit(in rc.Params) fs.SizeSuffix {
+	// Check to see if the bandwidth limit is set
+	bwlimit := fs.SizeSuffix(-1)
+	value, err := in.GetString("_bwlimit")
+	if err == nil {
+		err = bwlimit.Set(value)
+	}
+	if rc.NotErrParamNotFound(err) {
+		fs.Errorf(nil, "Can't get _bwlimit param %+v", err)
+	}
+	delete(in, "_bwlimit")
+	return bwlimit
+}
+
+// withTimeout returns a cancellable copy of ctx which is also
+// cancelled after timeout if it is set
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
 // NewAsyncJob start a new asynchronous Job off
 func (jobs *Jobs) NewAsyncJob(fn rc.Func, in rc.Params) *Job {
 	id := atomic.AddInt64(&jobID, 1)
@@ -168,8 +211,10 @@ func (jobs *Jobs) NewAsyncJob(fn rc.Func, in rc.Params) *Job {
 	if group == "" {
 		group = fmt.Sprintf("job/%d", id)
 	}
-	ctx := accounting.WithStatsGroup(context.Background(), group)
-	ctx, cancel := context.WithCancel(ctx)
+	timeout := getTimeout(in)
+	bwLimiter := accounting.NewBwLimiter(getBwLimit(in))
+	ctx := accounting.WithBwLimiter(accounting.WithStatsGroup(context.Background(), group), bwLimiter)
+	ctx, cancel := withTimeout(ctx, timeout)
 	stop := func() {
 		cancel()
 		// Wait for cancel to propagate before returning.
@@ -180,6 +225,8 @@ func (jobs *Jobs) NewAsyncJob(fn rc.Func, in rc.Params) *Job {
 		Group:     group,
 		StartTime: time.Now(),
 		Stop:      stop,
+		timeout:   timeout,
+		bwLimiter: bwLimiter,
 	}
 	jobs.mu.Lock()
 	jobs.jobs[job.ID] = job
@@ -195,8 +242,10 @@ func (jobs *Jobs) NewSyncJob(ctx context.Context, in rc.Params) (*Job, context.C
 	if group == "" {
 		group = fmt.Sprintf("job/%d", id)
 	}
-	ctxG := accounting.WithStatsGroup(ctx, fmt.Sprintf("job/%d", id))
-	ctx, cancel := context.WithCancel(ctxG)
+	timeout := getTimeout(in)
+	bwLimiter := accounting.NewBwLimiter(getBwLimit(in))
+	ctxG := accounting.WithBwLimiter(accounting.WithStatsGroup(ctx, fmt.Sprintf("job/%d", id)), bwLimiter)
+	ctx, cancel := withTimeout(ctxG, timeout)
 	stop := func() {
 		cancel()
 		// Wait for cancel to propagate before returning.
@@ -207,6 +256,8 @@ func (jobs *Jobs) NewSyncJob(ctx context.Context, in rc.Params) (*Job, context.C
 		Group:     group,
 		StartTime: time.Now(),
 		Stop:      stop,
+		timeout:   timeout,
+		bwLimiter: bwLimiter,
 	}
 	jobs.mu.Lock()
 	jobs.jobs[job.ID] = job
@@ -325,3 +376,67 @@ func rcJobStop(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 	job.Stop()
 	return out, nil
 }
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "job/bwlimit",
+		Fn:    rcJobBwLimit,
+		Title: "Set the bandwidth limit of the job ID",
+		Help: `This sets the bandwidth limit of a running job to that passed in.
+The limit applies to the transfers of the job on top of the global
+bandwidth limit set with --bwlimit or core/bwlimit.
+
+Parameters
+
+- jobid - id of the job (integer)
+- rate - the new bandwidth limit eg "1M" or "off" (string)
+
+If the rate parameter is not supplied then the bandwidth limit of the
+job is queried.
+
+Results
+
+- rate - the current bandwidth limit as a string
+- bytesPerSecond - the current bandwidth limit or -1 if unlimited
+
+Eg
+
+    rclone rc job/bwlimit jobid=3 rate=1M
+    {
+        "bytesPerSecond": 1048576,
+        "rate": "1M"
+    }
+`,
+	})
+}
+
+// Sets the bandwidth limit of the job
+func rcJobBwLimit(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	jobID, err := in.GetInt64("jobid")
+	if err != nil {
+		return nil, err
+	}
+	job := running.Get(jobID)
+	if job == nil {
+		return nil, errors.New("job not found")
+	}
+	if in["rate"] != nil {
+		rate, err := in.GetString("rate")
+		if err != nil {
+			return nil, err
+		}
+		var bwlimit fs.SizeSuffix
+		err = bwlimit.Set(rate)
+		if err != nil {
+			return nil, errors.Wrap(err, "bad rate")
+		}
+		job.bwLimiter.SetLimit(bwlimit)
+		fs.Logf(nil, "Bandwidth limit of job %d set to %v", jobID, bwlimit)
+	}
+	bytesPerSecond := int64(job.bwLimiter.Limit())
+	out = rc.Params{
+		"rate":           fs.SizeSuffix(bytesPerSecond).String(),
+		"bytesPerSecond": bytesPerSecond,
+	}
+	return out, nil
+}
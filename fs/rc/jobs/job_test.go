@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/rc"
 	"github.com/rclone/rclone/fs/rc/rcflags"
 	"github.com/rclone/rclone/fstest/testy"
@@ -346,3 +347,66 @@ func TestRcSyncJobStop(t *testing.T) {
 	assert.Equal(t, true, out["finished"])
 	assert.Equal(t, false, out["success"])
 }
+
+func TestRcAsyncJobTimeout(t *testing.T) {
+	jobID = 0
+	job := running.NewAsyncJob(ctxFn, rc.Params{"_timeout": "10ms"})
+	assert.Equal(t, 10*time.Millisecond, job.timeout)
+
+	// Wait for the timeout to propagate
+	for i := uint(0); i < 10; i++ {
+		job.mu.Lock()
+		finished := job.Finished
+		job.mu.Unlock()
+		if finished {
+			break
+		}
+		time.Sleep(time.Millisecond << i)
+	}
+
+	job.mu.Lock()
+	assert.Equal(t, true, job.Finished)
+	assert.Equal(t, false, job.Success)
+	assert.Equal(t, "job timed out after 10ms: context deadline exceeded", job.Error)
+	assert.Equal(t, context.DeadlineExceeded, errors.Cause(job.realErr))
+	job.mu.Unlock()
+}
+
+func TestExecuteJobTimeout(t *testing.T) {
+	jobID = 0
+	in := rc.Params{"_timeout": "10ms"}
+	_, _, err := ExecuteJob(context.Background(), ctxFn, in)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "job timed out after 10ms")
+	assert.Equal(t, rc.Params{}, in)
+}
+
+func TestRcJobBwLimit(t *testing.T) {
+	jobID = 0
+	job := running.NewAsyncJob(ctxFn, rc.Params{"_bwlimit": "1M"})
+	defer job.Stop()
+	assert.Equal(t, fs.SizeSuffix(1024*1024), job.bwLimiter.Limit())
+
+	call := rc.Calls.Get("job/bwlimit")
+	assert.NotNil(t, call)
+
+	in := rc.Params{"jobid": 1}
+	out, err := call.Fn(context.Background(), in)
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{"rate": "1M", "bytesPerSecond": int64(1024 * 1024)}, out)
+
+	in = rc.Params{"jobid": 1, "rate": "off"}
+	out, err = call.Fn(context.Background(), in)
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{"rate": "off", "bytesPerSecond": int64(-1)}, out)
+
+	in = rc.Params{"jobid": 1, "rate": "bad"}
+	_, err = call.Fn(context.Background(), in)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad rate")
+
+	in = rc.Params{"jobid": 123123123}
+	_, err = call.Fn(context.Background(), in)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "job not found")
+}
@@ -0,0 +1,50 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SourceChange describes what to do when a file changes on the source
+// while it is being transferred
+type SourceChange byte
+
+// SourceChange constants
+const (
+	SourceChangeRetry SourceChange = iota
+	SourceChangeFail
+	SourceChangeIgnore
+	SourceChangeDefault = SourceChangeRetry
+)
+
+var sourceChangeToString = []string{
+	SourceChangeRetry:  "retry",
+	SourceChangeFail:   "fail",
+	SourceChangeIgnore: "ignore",
+}
+
+// String turns a SourceChange into a string
+func (m SourceChange) String() string {
+	if m >= SourceChange(len(sourceChangeToString)) {
+		return fmt.Sprintf("SourceChange(%d)", m)
+	}
+	return sourceChangeToString[m]
+}
+
+// Set a SourceChange
+func (m *SourceChange) Set(s string) error {
+	for n, name := range sourceChangeToString {
+		if s != "" && name == strings.ToLower(s) {
+			*m = SourceChange(n)
+			return nil
+		}
+	}
+	return errors.Errorf("Unknown source change mode %q", s)
+}
+
+// Type of the value
+func (m *SourceChange) Type() string {
+	return "string"
+}
@@ -0,0 +1,22 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// Check it satisfies the interface
+var _ pflag.Value = (*SourceChange)(nil)
+
+func TestSourceChangeSet(t *testing.T) {
+	var m SourceChange
+	assert.NoError(t, m.Set("FAIL"))
+	assert.Equal(t, SourceChangeFail, m)
+	assert.Equal(t, "fail", m.String())
+	assert.NoError(t, m.Set("ignore"))
+	assert.Equal(t, SourceChangeIgnore, m)
+	assert.Error(t, m.Set("potato"))
+	assert.Equal(t, "SourceChange(17)", SourceChange(17).String())
+}
@@ -0,0 +1,25 @@
+package sync
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+)
+
+// atTimeFs returns a read only view of fsrc showing the version of
+// each object current at --at-time.
+func atTimeFs(ctx context.Context, fsrc fs.Fs) (fs.Fs, error) {
+	doAtTime := fsrc.Features().AtTime
+	if doAtTime == nil {
+		return nil, errors.Errorf("%v doesn't support --at-time as it doesn't keep old versions", fsrc)
+	}
+	t := time.Time(fs.Config.AtTime)
+	fs.Infof(fsrc, "Copying the versions of the objects current at %v", t)
+	newFsrc, err := doAtTime(ctx, t)
+	if err != nil {
+		return nil, errors.Wrap(err, "--at-time")
+	}
+	return newFsrc, nil
+}
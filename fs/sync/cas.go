@@ -0,0 +1,287 @@
+// Content addressed storage
+//
+// With --cas files are copied to the destination under a path made
+// from the hash of their contents and a manifest on the destination
+// maps their names to their contents.
+
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// casHashType is the hash the contents are stored under
+var casHashType = hash.SHA1
+
+// casEntry is a file in the manifest
+type casEntry struct {
+	Hash    string    `json:"hash"`    // hash of the contents
+	Size    int64     `json:"size"`    // size of the contents
+	ModTime time.Time `json:"modTime"` // modification time of the source
+}
+
+// casManifest maps the names of the files to their contents
+type casManifest struct {
+	HashType string              `json:"hashType"`
+	Files    map[string]casEntry `json:"files"`
+}
+
+// casPath returns where the contents with hash sum are stored
+func casPath(sum string) string {
+	return path.Join(sum[0:2], sum[2:4], sum)
+}
+
+// casContent is an upload of some contents to the destination
+type casContent struct {
+	done chan struct{} // closed when the upload has finished
+	err  error         // error from the upload
+}
+
+// casCopier copies files to a content addressed destination
+type casCopier struct {
+	fdst     fs.Fs
+	mu       sync.Mutex
+	manifest casManifest
+	contents map[string]*casContent
+}
+
+// newCasCopier makes a casCopier for fdst reading the existing
+// manifest if there is one
+func newCasCopier(ctx context.Context, fdst fs.Fs) (*casCopier, error) {
+	c := &casCopier{
+		fdst: fdst,
+		manifest: casManifest{
+			HashType: casHashType.String(),
+			Files:    make(map[string]casEntry),
+		},
+		contents: make(map[string]*casContent),
+	}
+	o, err := fdst.NewObject(ctx, fs.Config.CASManifest)
+	if err == fs.ErrorObjectNotFound || err == fs.ErrorDirNotFound {
+		return c, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to find --cas-manifest")
+	}
+	in, err := o.Open(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open --cas-manifest")
+	}
+	data, err := ioutil.ReadAll(in)
+	_ = in.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read --cas-manifest")
+	}
+	var old casManifest
+	err = json.Unmarshal(data, &old)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse --cas-manifest %q", fs.Config.CASManifest)
+	}
+	if old.HashType != c.manifest.HashType {
+		return nil, errors.Errorf("--cas-manifest %q uses hash %q not %q", fs.Config.CASManifest, old.HashType, c.manifest.HashType)
+	}
+	for remote, entry := range old.Files {
+		c.manifest.Files[remote] = entry
+	}
+	return c, nil
+}
+
+// hash returns the hash of the contents of src, reading it if the
+// source can't supply it
+func (c *casCopier) hash(ctx context.Context, src fs.Object) (sum string, err error) {
+	if src.Fs().Hashes().Contains(casHashType) {
+		sum, err = src.Hash(ctx, casHashType)
+		if err == nil && sum != "" {
+			return sum, nil
+		}
+	}
+	tr := accounting.Stats(ctx).NewCheckingTransfer(src)
+	defer func() {
+		tr.Done(err)
+	}()
+	in, err := src.Open(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open source to hash")
+	}
+	sums, err := hash.StreamTypes(in, hash.NewHashSet(casHashType))
+	_ = in.Close()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to hash source")
+	}
+	return sums[casHashType], nil
+}
+
+// copy copies src to the destination by the hash of its contents
+// unless the contents are already there
+func (c *casCopier) copy(ctx context.Context, src fs.Object) error {
+	if c.unchanged(ctx, src) {
+		tr := accounting.Stats(ctx).NewCheckingTransfer(src)
+		fs.Debugf(src, "Unchanged skipping")
+		tr.Done(nil)
+		return nil
+	}
+	sum, err := c.hash(ctx, src)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	content, found := c.contents[sum]
+	if !found {
+		content = &casContent{done: make(chan struct{})}
+		c.contents[sum] = content
+	}
+	c.mu.Unlock()
+	if found {
+		// Wait for the upload of the same contents to finish
+		select {
+		case <-content.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if content.err != nil {
+			return content.err
+		}
+		c.deduplicated(ctx, src)
+	} else {
+		uploaded, err := c.upload(ctx, sum, src)
+		content.err = err
+		close(content.done)
+		if err != nil {
+			return err
+		}
+		if !uploaded {
+			c.deduplicated(ctx, src)
+		}
+	}
+	c.mu.Lock()
+	c.manifest.Files[src.Remote()] = casEntry{
+		Hash:    sum,
+		Size:    src.Size(),
+		ModTime: src.ModTime(ctx).UTC(),
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// unchanged returns true if src has the same size and modification
+// time as it has in the manifest so doesn't need copying again
+func (c *casCopier) unchanged(ctx context.Context, src fs.Object) bool {
+	if fs.Config.CheckSum || fs.Config.IgnoreTimes {
+		return false
+	}
+	c.mu.Lock()
+	entry, found := c.manifest.Files[src.Remote()]
+	c.mu.Unlock()
+	return found && entry.Size == src.Size() && entry.ModTime.Equal(src.ModTime(ctx).UTC())
+}
+
+// upload copies src to the destination under sum unless a copy is
+// already there, returning whether it was uploaded
+func (c *casCopier) upload(ctx context.Context, sum string, src fs.Object) (uploaded bool, err error) {
+	remote := casPath(sum)
+	dst, err := c.fdst.NewObject(ctx, remote)
+	if err == nil {
+		if dst.Size() == src.Size() {
+			return false, nil
+		}
+		fs.Logf(dst, "Replacing as size %d doesn't match contents size %d", dst.Size(), src.Size())
+	} else if err != fs.ErrorObjectNotFound && err != fs.ErrorDirNotFound {
+		return false, err
+	} else {
+		dst = nil
+	}
+	_, err = operations.Copy(ctx, c.fdst, dst, remote, src)
+	return err == nil, err
+}
+
+// deduplicated records that src wasn't uploaded as the destination
+// already has its contents
+func (c *casCopier) deduplicated(ctx context.Context, src fs.Object) {
+	accounting.Stats(ctx).Deduplicated(src.Size())
+	fs.Infof(src, "Not uploading as contents are already on destination")
+}
+
+// writeManifest writes the manifest to the destination
+func (c *casCopier) writeManifest(ctx context.Context) error {
+	if operations.SkipDestructive(ctx, fs.Config.CASManifest, "update manifest") {
+		return nil
+	}
+	c.mu.Lock()
+	data, err := json.MarshalIndent(&c.manifest, "", "\t")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	_, err = operations.Rcat(ctx, c.fdst, fs.Config.CASManifest, ioutil.NopCloser(bytes.NewReader(append(data, '\n'))), time.Now())
+	if err != nil {
+		return errors.Wrap(err, "failed to write --cas-manifest")
+	}
+	return nil
+}
+
+// runCAS copies the files in fsrc to the content addressed fdst
+//
+// Files which already have their contents on fdst aren't uploaded
+// again and files which haven't changed since they were last copied
+// are skipped. The manifest is updated with the files copied even if some
+// of them failed.
+func runCAS(ctx context.Context, fdst, fsrc fs.Fs) (err error) {
+	if fs.Config.CASManifest == "" {
+		return fserrors.FatalError(errors.New("--cas-manifest can't be empty with --cas"))
+	}
+	c, err := newCasCopier(ctx, fdst)
+	if err != nil {
+		return fserrors.FatalError(err)
+	}
+	var (
+		wg      sync.WaitGroup
+		errMu   sync.Mutex
+		lastErr error
+	)
+	srcs := make(chan fs.Object, fs.Config.Transfers)
+	for i := 0; i < fs.Config.Transfers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for src := range srcs {
+				err := c.copy(ctx, src)
+				if err != nil {
+					err = fs.CountError(err)
+					fs.Errorf(src, "Failed to copy to --cas destination: %v", err)
+					errMu.Lock()
+					lastErr = err
+					errMu.Unlock()
+				}
+			}
+		}()
+	}
+	err = operations.ListFn(ctx, fsrc, func(o fs.Object) {
+		srcs <- o
+	})
+	close(srcs)
+	wg.Wait()
+	if err != nil {
+		err = fs.CountError(err)
+		fs.Errorf(fsrc, "Failed to list source: %v", err)
+		lastErr = err
+	}
+	err = c.writeManifest(ctx)
+	if err != nil {
+		err = fs.CountError(err)
+		fs.Errorf(fdst, "%v", err)
+		lastErr = err
+	}
+	return lastErr
+}
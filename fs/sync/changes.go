@@ -0,0 +1,245 @@
+// Incremental syncs
+//
+// With --changes-file the change feed of the source is used to only
+// sync what has changed since the last run.
+
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/fspath"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// changesToken is the contents of the --changes-file
+type changesToken struct {
+	Remote string `json:"remote"` // the source the token is for
+	Token  string `json:"token"`  // where the source change feed has got to
+}
+
+// readChangesToken reads the token for remote from fileName
+//
+// It returns "" if there isn't one.
+func readChangesToken(fileName, remote string) (token string, err error) {
+	data, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Wrap(err, "failed to read --changes-file")
+	}
+	var t changesToken
+	err = json.Unmarshal(data, &t)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse --changes-file %q", fileName)
+	}
+	if t.Remote != remote {
+		fs.Logf(nil, "Ignoring --changes-file %q as it is for %q not %q", fileName, t.Remote, remote)
+		return "", nil
+	}
+	return t.Token, nil
+}
+
+// writeChangesToken writes the token for remote to fileName
+func writeChangesToken(fileName, remote, token string) error {
+	data, err := json.Marshal(changesToken{Remote: remote, Token: token})
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(fileName, append(data, '\n'), 0666)
+	if err != nil {
+		return errors.Wrap(err, "failed to write --changes-file")
+	}
+	return nil
+}
+
+// changeSet is the changes read from the change feed
+type changeSet struct {
+	dirs  map[string]struct{}
+	files map[string]struct{}
+}
+
+// add a change to the set
+func (c *changeSet) add(remote string, entryType fs.EntryType) {
+	if entryType == fs.EntryDirectory {
+		c.dirs[remote] = struct{}{}
+	} else {
+		c.files[remote] = struct{}{}
+	}
+}
+
+// covered returns true if remote is inside one of the changed
+// directories
+func (c *changeSet) covered(remote string) bool {
+	for remote != "" {
+		remote = path.Dir(remote)
+		if remote == "." {
+			remote = ""
+		}
+		if _, found := c.dirs[remote]; found {
+			return true
+		}
+	}
+	return false
+}
+
+// list returns the sorted directories and files which need syncing,
+// leaving out any inside a changed directory as syncing that will do
+// them.
+func (c *changeSet) list() (dirs, files []string) {
+	for dir := range c.dirs {
+		if !c.covered(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	for file := range c.files {
+		if !c.covered(file) {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(dirs)
+	sort.Strings(files)
+	return dirs, files
+}
+
+// runChanges does the sync of fsrc into fdst using the change feed of
+// fsrc and --changes-file.
+//
+// sync is used to do a full sync of a pair of directories.
+func runChanges(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.DeleteMode, sync func(ctx context.Context, fdst, fsrc fs.Fs) error) error {
+	lister, ok := fsrc.(fs.ChangeLister)
+	if !ok {
+		fs.Logf(fsrc, "Ignoring --changes-file as the source can't list its changes")
+		return sync(ctx, fdst, fsrc)
+	}
+	remote := fs.ConfigString(fsrc)
+	token, err := readChangesToken(fs.Config.ChangesFile, remote)
+	if err != nil {
+		return fserrors.FatalError(err)
+	}
+	var changes = changeSet{
+		dirs:  map[string]struct{}{},
+		files: map[string]struct{}{},
+	}
+	var newToken string
+	if token != "" {
+		newToken, err = lister.ListChanges(ctx, token, changes.add)
+		if err != nil {
+			fs.Errorf(fsrc, "Doing a full sync as failed to list changes: %v", err)
+		}
+	}
+	if token == "" || err != nil {
+		// Read the token before the sync so no changes are missed
+		newToken, err = lister.ListChanges(ctx, "", nil)
+		if err != nil {
+			return errors.Wrap(err, "failed to read change feed token")
+		}
+		fs.Infof(fsrc, "Doing a full sync to start the --changes-file")
+		err = sync(ctx, fdst, fsrc)
+	} else {
+		err = syncChanges(ctx, fdst, fsrc, deleteMode, &changes, sync)
+	}
+	if err != nil {
+		// Don't save the token so the changes are done again
+		return err
+	}
+	return writeChangesToken(fs.Config.ChangesFile, remote, newToken)
+}
+
+// syncChanges syncs the changes from fsrc into fdst
+func syncChanges(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.DeleteMode, changes *changeSet, sync func(ctx context.Context, fdst, fsrc fs.Fs) error) error {
+	// Sync the parent of any directory which has gone from the
+	// source to delete it
+	for dir := range changes.dirs {
+		for dir != "" {
+			_, err := fsrc.List(ctx, dir)
+			if err != fs.ErrorDirNotFound {
+				break
+			}
+			delete(changes.dirs, dir)
+			dir = path.Dir(dir)
+			if dir == "." {
+				dir = ""
+			}
+			changes.dirs[dir] = struct{}{}
+		}
+	}
+	dirs, files := changes.list()
+	fs.Infof(fsrc, "Syncing %d changed directories and %d changed files", len(dirs), len(files))
+	if len(dirs) > 0 && (!filter.Active.InActive() || dirs[0] == "") {
+		// Filters are relative to the root so sync from there
+		return sync(ctx, fdst, fsrc)
+	}
+	var (
+		errCount int
+		lastErr  error
+	)
+	noteError := func(err error) {
+		if err != nil {
+			errCount++
+			lastErr = err
+		}
+	}
+	for _, dir := range dirs {
+		subSrc, err := fs.NewFs(fspath.JoinRootPath(fs.ConfigString(fsrc), dir))
+		if err != nil && err != fs.ErrorIsFile {
+			noteError(err)
+			continue
+		}
+		subDst, err := fs.NewFs(fspath.JoinRootPath(fs.ConfigString(fdst), dir))
+		if err != nil && err != fs.ErrorIsFile {
+			noteError(err)
+			continue
+		}
+		noteError(sync(ctx, subDst, subSrc))
+	}
+	var backupDir fs.Fs
+	if deleteMode != fs.DeleteModeOff && (fs.Config.BackupDir != "" || fs.Config.Suffix != "") {
+		var err error
+		backupDir, err = operations.BackupDir(fdst, fsrc, "")
+		if err != nil {
+			return err
+		}
+	}
+	for _, file := range files {
+		src, err := fsrc.NewObject(ctx, file)
+		if err == nil {
+			if filter.Active.IncludeObject(ctx, src) {
+				noteError(operations.CopyFile(ctx, fdst, fsrc, file, file))
+			}
+			continue
+		} else if err != fs.ErrorObjectNotFound {
+			fs.Errorf(file, "Failed to read changed file: %v", err)
+			noteError(fs.CountError(err))
+			continue
+		}
+		if deleteMode == fs.DeleteModeOff {
+			continue
+		}
+		dst, err := fdst.NewObject(ctx, file)
+		if err == fs.ErrorObjectNotFound {
+			continue
+		} else if err != nil {
+			fs.Errorf(file, "Failed to read file to delete: %v", err)
+			noteError(fs.CountError(err))
+			continue
+		}
+		if !filter.Active.IncludeObject(ctx, dst) {
+			continue
+		}
+		noteError(operations.DeleteFileWithBackupDir(ctx, dst, backupDir))
+	}
+	if errCount > 0 {
+		return errors.Wrapf(lastErr, "failed to sync %d changes, last error", errCount)
+	}
+	return nil
+}
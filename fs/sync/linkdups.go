@@ -0,0 +1,144 @@
+// Link duplicates
+//
+// With --link-duplicates files with the same contents as one already
+// copied in this sync are linked to it on the destination instead of
+// being copied again.
+
+package sync
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// dupKey identifies the contents of a source object
+type dupKey struct {
+	size    int64
+	hash    string
+	modTime int64 // only set if linking shares the modification time
+}
+
+// dupCopy is a copy of some contents to the destination
+type dupCopy struct {
+	done chan struct{} // closed when the copy has finished
+	dst  fs.Object     // the copy on the destination or nil if it failed
+}
+
+// dupLinker links transfers of the same contents to the first one
+type dupLinker struct {
+	fdst     fs.Fs
+	linker   fs.Linker
+	mode     fs.LinkMode
+	hashType hash.Type
+	mu       sync.Mutex
+	copies   map[dupKey]*dupCopy
+}
+
+// newDupLinker makes a dupLinker for copying fsrc to fdst or returns
+// nil if --link-duplicates is off or can't be used
+func newDupLinker(fdst, fsrc fs.Fs) *dupLinker {
+	mode := fs.Config.LinkDuplicates
+	if mode == fs.LinkModeOff {
+		return nil
+	}
+	linker, ok := fdst.(fs.Linker)
+	if !ok {
+		fs.Logf(fdst, "Ignoring --link-duplicates as the destination can't link files")
+		return nil
+	}
+	hashType := fsrc.Hashes().GetOne()
+	if hashType == hash.None {
+		fs.Logf(fsrc, "Ignoring --link-duplicates as the source doesn't support hashes")
+		return nil
+	}
+	return &dupLinker{
+		fdst:     fdst,
+		linker:   linker,
+		mode:     mode,
+		hashType: hashType,
+		copies:   make(map[dupKey]*dupCopy),
+	}
+}
+
+// copy copies src to the destination replacing dst if set, linking
+// it to an earlier copy of the same contents if there is one.
+func (d *dupLinker) copy(ctx context.Context, dst, src fs.Object) (err error) {
+	key := dupKey{size: src.Size()}
+	if key.size < 0 {
+		return d.copyFile(ctx, dst, src, nil)
+	}
+	key.hash, err = src.Hash(ctx, d.hashType)
+	if err != nil || key.hash == "" {
+		return d.copyFile(ctx, dst, src, nil)
+	}
+	if d.mode != fs.LinkModeReflink {
+		// Hardlinks share the modification time too so only link
+		// files which have the same one
+		key.modTime = src.ModTime(ctx).UnixNano()
+	}
+	d.mu.Lock()
+	c, found := d.copies[key]
+	if !found {
+		c = &dupCopy{done: make(chan struct{})}
+		d.copies[key] = c
+	}
+	d.mu.Unlock()
+	if !found {
+		return d.copyFile(ctx, dst, src, c)
+	}
+	// Wait for the first copy to finish
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if c.dst == nil {
+		return d.copyFile(ctx, dst, src, nil)
+	}
+	ok, err := d.link(ctx, c.dst, src)
+	if !ok {
+		return d.copyFile(ctx, dst, src, nil)
+	}
+	return err
+}
+
+// copyFile copies src normally, recording the copy in c if set
+func (d *dupLinker) copyFile(ctx context.Context, dst, src fs.Object, c *dupCopy) error {
+	newDst, err := operations.Copy(ctx, d.fdst, dst, src.Remote(), src)
+	if c != nil {
+		if err == nil {
+			c.dst = newDst
+		}
+		close(c.done)
+	}
+	return err
+}
+
+// link makes src on the destination by linking it to existing. This
+// returns false if it couldn't so src should be copied instead.
+func (d *dupLinker) link(ctx context.Context, existing, src fs.Object) (ok bool, err error) {
+	newDst, err := d.linker.Link(ctx, existing, src.Remote(), d.mode)
+	if err != nil {
+		fs.Debugf(src, "Copying as couldn't link to %q: %v", existing.Remote(), err)
+		return false, nil
+	}
+	tr := accounting.Stats(ctx).NewTransfer(src)
+	defer func() {
+		tr.Done(err)
+	}()
+	modTime := src.ModTime(ctx)
+	if !newDst.ModTime(ctx).Equal(modTime) {
+		err = newDst.SetModTime(ctx, modTime)
+		if err != nil {
+			fs.Errorf(newDst, "Failed to set modification time of link: %v", err)
+			return true, err
+		}
+	}
+	fs.Infof(src, "Linked to duplicate %q", existing.Remote())
+	return true, nil
+}
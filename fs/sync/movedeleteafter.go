@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// srcDeletes holds the source files a move with --move-delete-after
+// has copied so they can be deleted once all the transfers have
+// succeeded.
+//
+// The names are kept in a temporary file rather than in memory so a
+// huge move doesn't need memory for every file.
+type srcDeletes struct {
+	mu  sync.Mutex
+	f   *os.File
+	out *bufio.Writer
+	n   int   // number of files added
+	err error // first error writing the file
+}
+
+// newSrcDeletes makes a temporary file to keep the source files in
+func newSrcDeletes() (*srcDeletes, error) {
+	f, err := ioutil.TempFile("", "rclone-move-delete-after-")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make temporary file for --move-delete-after")
+	}
+	return &srcDeletes{
+		f:   f,
+		out: bufio.NewWriter(f),
+	}, nil
+}
+
+// add records remote as copied and needing deleting from the source
+func (d *srcDeletes) add(remote string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return
+	}
+	// remotes can't contain "\x00" so use it to separate them
+	_, d.err = d.out.WriteString(remote + "\x00")
+	d.n++
+}
+
+// close removes the temporary file
+func (d *srcDeletes) close() {
+	_ = d.f.Close()
+	_ = os.Remove(d.f.Name())
+}
+
+// finish deletes the source files from fsrc if the move finished
+// without moveErr, otherwise it reports the files which were copied
+// but not deleted.
+func (d *srcDeletes) finish(ctx context.Context, fsrc fs.Fs, moveErr error) error {
+	defer d.close()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err := d.err
+	if err == nil {
+		err = d.out.Flush()
+	}
+	if err == nil {
+		_, err = d.f.Seek(0, os.SEEK_SET)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to read the files to delete for --move-delete-after")
+	}
+	in := bufio.NewReader(d.f)
+	if moveErr != nil {
+		fs.Errorf(fsrc, "Not deleting the %d source files which were copied as there were errors - run the move again to finish it", d.n)
+		for {
+			remote, err := in.ReadString(0)
+			if err != nil {
+				break
+			}
+			fs.Infof(strings.TrimSuffix(remote, "\x00"), "Copied but not deleted from the source as there were errors")
+		}
+		return nil
+	}
+	fs.Infof(fsrc, "Deleting the %d source files moved as all the transfers succeeded", d.n)
+	toBeDeleted := make(fs.ObjectsChan, fs.Config.Checkers)
+	deleteErr := make(chan error, 1)
+	go func() {
+		deleteErr <- operations.DeleteFiles(ctx, toBeDeleted)
+	}()
+	var findErr error
+	for {
+		remote, err := in.ReadString(0)
+		if err != nil {
+			break
+		}
+		remote = strings.TrimSuffix(remote, "\x00")
+		o, err := fsrc.NewObject(ctx, remote)
+		if err != nil {
+			fs.Errorf(remote, "Couldn't find source file to delete: %v", err)
+			findErr = fs.CountError(err)
+			continue
+		}
+		toBeDeleted <- o
+	}
+	close(toBeDeleted)
+	err = <-deleteErr
+	if err == nil {
+		err = findErr
+	}
+	return err
+}
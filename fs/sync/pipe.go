@@ -26,6 +26,7 @@ type pipe struct {
 	closed    bool
 	totalSize int64
 	stats     func(items int, totalSize int64)
+	queued    func(remote string) // if set called with the remote of each pair Put
 	less      lessFn
 	fraction  int
 }
@@ -101,6 +102,9 @@ func (p *pipe) Put(ctx context.Context, pair fs.ObjectPair) (ok bool) {
 		p.totalSize += size
 	}
 	p.stats(len(p.queue), p.totalSize)
+	if p.queued != nil {
+		p.queued(pair.Src.Remote())
+	}
 	p.mu.Unlock()
 	select {
 	case <-ctx.Done():
@@ -168,6 +172,19 @@ func (p *pipe) Stats() (items int, totalSize int64) {
 	return items, totalSize
 }
 
+// countNew returns the number of pairs in the queue which don't have
+// a destination, ie which will create a new object when transferred
+func (p *pipe) countNew() (n int) {
+	p.mu.Lock()
+	for _, pair := range p.queue {
+		if pair.Dst == nil {
+			n++
+		}
+	}
+	p.mu.Unlock()
+	return n
+}
+
 // Close the pipe
 //
 // Writes to a closed pipe will panic as will double closing a pipe
@@ -200,6 +217,22 @@ func newLess(orderBy string) (less lessFn, fraction int, err error) {
 			ctx := context.Background()
 			return a.Src.ModTime(ctx).Before(b.Src.ModTime(ctx))
 		}
+	case "oldest-missing":
+		less = func(a, b fs.ObjectPair) bool {
+			// Files missing from the destination come first
+			aMissing, bMissing := a.Dst == nil, b.Dst == nil
+			if aMissing != bMissing {
+				return aMissing
+			}
+			// Then the oldest source files, then by name so the
+			// order is deterministic
+			ctx := context.Background()
+			aModTime, bModTime := a.Src.ModTime(ctx), b.Src.ModTime(ctx)
+			if !aModTime.Equal(bModTime) {
+				return aModTime.Before(bModTime)
+			}
+			return a.Src.Remote() < b.Src.Remote()
+		}
 	default:
 		return nil, fraction, errors.Errorf("unknown --order-by comparison %q", parts[0])
 	}
@@ -233,3 +266,22 @@ func newLess(orderBy string) (less lessFn, fraction int, err error) {
 	}
 	return less, fraction, nil
 }
+
+// queueStats adds up the stats of several pipes which make up one
+// queue
+type queueStats struct {
+	mu        sync.Mutex
+	items     [2]int
+	totalSize [2]int64
+	set       func(items int, totalSize int64)
+}
+
+// stats returns the stats function for the i-th pipe
+func (q *queueStats) stats(i int) func(items int, totalSize int64) {
+	return func(items int, totalSize int64) {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.items[i], q.totalSize[i] = items, totalSize
+		q.set(q.items[0]+q.items[1], q.totalSize[0]+q.totalSize[1])
+	}
+}
@@ -82,8 +82,51 @@ func TestPipe(t *testing.T) {
 
 }
 
+func TestPipeCountNew(t *testing.T) {
+	p, err := newPipe("", func(int, int64) {}, 10)
+	require.NoError(t, err)
+	ctx := context.Background()
+	assert.Equal(t, 0, p.countNew())
+
+	obj1 := mockobject.New("potato").WithContent([]byte("hello"), mockobject.SeekModeNone)
+	obj2 := mockobject.New("sausage").WithContent([]byte("world"), mockobject.SeekModeNone)
+	assert.True(t, p.Put(ctx, fs.ObjectPair{Src: obj1, Dst: nil}))
+	assert.True(t, p.Put(ctx, fs.ObjectPair{Src: obj2, Dst: obj2}))
+	assert.True(t, p.Put(ctx, fs.ObjectPair{Src: obj2, Dst: nil}))
+	assert.Equal(t, 2, p.countNew())
+
+	_, ok := p.Get(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, 1, p.countNew())
+}
+
 // TestPipeConcurrent runs concurrent Get and Put to flush out any
 // race conditions and concurrency problems.
+func TestPipeQueueStats(t *testing.T) {
+	var queueLength int
+	var queueSize int64
+	queues := &queueStats{set: func(n int, size int64) {
+		queueLength, queueSize = n, size
+	}}
+	p0, err := newPipe("", queues.stats(0), 10)
+	require.NoError(t, err)
+	p1, err := newPipe("", queues.stats(1), -1)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	pair := fs.ObjectPair{Src: mockobject.New("potato").WithContent([]byte("hello"), mockobject.SeekModeNone)}
+	assert.True(t, p0.Put(ctx, pair))
+	assert.True(t, p1.Put(ctx, pair))
+	assert.True(t, p1.Put(ctx, pair))
+	assert.Equal(t, 3, queueLength)
+	assert.Equal(t, int64(15), queueSize)
+
+	_, ok := p0.Get(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, 2, queueLength)
+	assert.Equal(t, int64(10), queueSize)
+}
+
 func TestPipeConcurrent(t *testing.T) {
 	const (
 		N           = 1000
@@ -262,6 +305,8 @@ func TestNewLess(t *testing.T) {
 		{"modtime,descending", true, true, -1},
 		{"modtime,mixed", false, false, 50},
 		{"modtime,mixed,30", false, false, 30},
+		{"oldest-missing", false, true, -1},
+		{"oldest-missing,desc", true, false, -1},
 	} {
 		t.Run(test.orderBy, func(t *testing.T) {
 			less, gotFraction, err := newLess(test.orderBy)
@@ -275,4 +320,12 @@ func TestNewLess(t *testing.T) {
 		})
 	}
 
+	t.Run("oldestMissing", func(t *testing.T) {
+		less, _, err := newLess("oldest-missing")
+		require.NoError(t, err)
+		present := fs.ObjectPair{Src: obj2, Dst: obj2}
+		missing := fs.ObjectPair{Src: obj1}
+		assert.True(t, less(missing, present))
+		assert.False(t, less(present, missing))
+	})
 }
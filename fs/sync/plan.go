@@ -0,0 +1,445 @@
+// Sync plans
+//
+// A plan is the list of actions a sync, copy or move would take. It
+// can be written out with --plan-out, reviewed and edited, then
+// executed exactly with --plan-in.
+
+package sync
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// Types of action in a plan
+const (
+	planCopy   = "copy"
+	planMove   = "move"
+	planDelete = "delete"
+)
+
+// planHeader is the first line of a plan file
+type planHeader struct {
+	Src string `json:"src"` // source the plan was made for
+	Dst string `json:"dst"` // destination the plan was made for
+}
+
+// planObject records the state of an object when the plan was made
+type planObject struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// newPlanObject returns the planObject for o or nil if o is nil
+func newPlanObject(ctx context.Context, o fs.Object) *planObject {
+	if o == nil {
+		return nil
+	}
+	return &planObject{
+		Size:    o.Size(),
+		ModTime: o.ModTime(ctx),
+	}
+}
+
+// planAction is one action in a plan file
+//
+// A delete has either Src set to delete from the source or Dst set
+// to delete from the destination.
+//
+// SrcInfo and DstInfo record the objects as they were when the plan
+// was made so it can be checked they haven't changed since.  DstInfo
+// is nil for a copy or move if the destination didn't exist.
+type planAction struct {
+	Action  string      `json:"action"`            // one of copy, move or delete
+	Src     string      `json:"src,omitempty"`     // path of the source object
+	Dst     string      `json:"dst,omitempty"`     // path of the destination object
+	Reason  string      `json:"reason,omitempty"`  // why the action is needed
+	SrcInfo *planObject `json:"srcInfo,omitempty"` // source object when planned
+	DstInfo *planObject `json:"dstInfo,omitempty"` // destination object when planned
+}
+
+// plannedAction is a planAction with the objects it acts on
+type plannedAction struct {
+	planAction
+	src fs.Object // source object - nil for deletes from the destination
+	dst fs.Object // destination object - nil if it doesn't exist
+}
+
+// planWriter writes the actions of a sync to a plan file instead of
+// carrying them out
+type planWriter struct {
+	mu  sync.Mutex
+	out *os.File
+	enc *json.Encoder
+	err error
+}
+
+// newPlanWriter creates the plan file at path for fsrc to fdst
+func newPlanWriter(path string, fdst, fsrc fs.Fs) (*planWriter, error) {
+	out, err := os.Create(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create plan file")
+	}
+	p := &planWriter{
+		out: out,
+		enc: json.NewEncoder(out),
+	}
+	p.err = p.enc.Encode(planHeader{
+		Src: fs.ConfigString(fsrc),
+		Dst: fs.ConfigString(fdst),
+	})
+	return p, nil
+}
+
+// add writes action to the plan
+func (p *planWriter) add(action planAction) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return
+	}
+	p.err = p.enc.Encode(action)
+}
+
+// addDeletes writes a delete from the destination for each object
+// passed in to the plan
+func (p *planWriter) addDeletes(ctx context.Context, toBeDeleted fs.ObjectsChan) {
+	for o := range toBeDeleted {
+		p.add(planAction{
+			Action:  planDelete,
+			Dst:     o.Remote(),
+			Reason:  "not in source",
+			DstInfo: newPlanObject(ctx, o),
+		})
+	}
+}
+
+// addTransferToPlan writes the copy or move of pair to the plan
+func (s *syncCopyMove) addTransferToPlan(pair fs.ObjectPair) {
+	action := planAction{
+		Action:  planCopy,
+		Src:     pair.Src.Remote(),
+		Dst:     pair.Src.Remote(),
+		Reason:  "changed",
+		SrcInfo: newPlanObject(s.ctx, pair.Src),
+		DstInfo: newPlanObject(s.ctx, pair.Dst),
+	}
+	if s.DoMove {
+		action.Action = planMove
+	}
+	if pair.Dst == nil {
+		action.Reason = "new"
+	}
+	s.plan.add(action)
+}
+
+// Close the plan file returning any errors from writing it
+func (p *planWriter) Close() error {
+	err := p.out.Close()
+	if p.err != nil {
+		return errors.Wrap(p.err, "failed to write plan file")
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to close plan file")
+	}
+	return nil
+}
+
+// checkPlanOut returns an error if the options in use would make the
+// sync change anything before the transfers, so couldn't be planned
+func checkPlanOut() error {
+	switch {
+	case fs.Config.TrackRenames:
+		return errors.New("can't use --plan-out with --track-renames")
+	case fs.Config.DetectMoves:
+		return errors.New("can't use --plan-out with --detect-moves")
+	case fs.Config.BackupDir != "" || fs.Config.Suffix != "":
+		return errors.New("can't use --plan-out with --backup-dir or --suffix")
+	case fs.Config.CopyDest != "":
+		return errors.New("can't use --plan-out with --copy-dest")
+	case fs.Config.PlanIn != "":
+		return errors.New("can't use --plan-out with --plan-in")
+	}
+	return nil
+}
+
+// readPlan reads the plan file at path, checking it was made for
+// fsrc to fdst
+func readPlan(path string, fdst, fsrc fs.Fs) (actions []planAction, err error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open plan file")
+	}
+	defer fs.CheckClose(in, &err)
+	scanner := bufio.NewScanner(in)
+	var header *planHeader
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if header == nil {
+			header = new(planHeader)
+			err = json.Unmarshal([]byte(line), header)
+			if err != nil {
+				return nil, errors.Wrapf(err, "plan file line %d: bad header", lineNumber)
+			}
+			if header.Src != fs.ConfigString(fsrc) || header.Dst != fs.ConfigString(fdst) {
+				return nil, errors.Errorf("plan file is for %q to %q not %q to %q", header.Src, header.Dst, fs.ConfigString(fsrc), fs.ConfigString(fdst))
+			}
+			continue
+		}
+		var action planAction
+		err = json.Unmarshal([]byte(line), &action)
+		if err != nil {
+			return nil, errors.Wrapf(err, "plan file line %d", lineNumber)
+		}
+		switch action.Action {
+		case planCopy, planMove:
+			if action.Src == "" || action.Dst == "" {
+				return nil, errors.Errorf("plan file line %d: %s needs src and dst", lineNumber, action.Action)
+			}
+		case planDelete:
+			if (action.Src == "") == (action.Dst == "") {
+				return nil, errors.Errorf("plan file line %d: delete needs one of src or dst", lineNumber)
+			}
+		default:
+			return nil, errors.Errorf("plan file line %d: unknown action %q", lineNumber, action.Action)
+		}
+		actions = append(actions, action)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read plan file")
+	}
+	if header == nil {
+		return nil, errors.New("plan file is empty")
+	}
+	return actions, nil
+}
+
+// findPlanObject returns the object at remote in f or nil if it
+// doesn't exist
+func findPlanObject(ctx context.Context, f fs.Fs, remote string) (fs.Object, error) {
+	o, err := f.NewObject(ctx, remote)
+	if err == fs.ErrorObjectNotFound {
+		return nil, nil
+	} else if err != nil {
+		err = fs.CountError(err)
+		fs.Errorf(fs.LogDirName(f, remote), "Failed to read object in plan: %v", err)
+		return nil, err
+	}
+	return o, nil
+}
+
+// planChanged returns how o differs from info recorded when the plan
+// was made or "" if it hasn't changed
+func planChanged(ctx context.Context, info *planObject, o fs.Object) string {
+	switch {
+	case info == nil && o == nil:
+		return ""
+	case info == nil:
+		return "created since plan was made"
+	case o == nil:
+		return "removed since plan was made"
+	case info.Size != o.Size():
+		return fmt.Sprintf("size changed from %d to %d since plan was made", info.Size, o.Size())
+	}
+	dt := o.ModTime(ctx).Sub(info.ModTime)
+	if dt < 0 {
+		dt = -dt
+	}
+	if dt > fs.GetModifyWindow(o.Fs()) {
+		return "modification time changed since plan was made"
+	}
+	return ""
+}
+
+// checkPlanAction finds the objects for action and checks whether
+// they have changed since the plan was made
+func checkPlanAction(ctx context.Context, fdst, fsrc fs.Fs, action planAction) (p plannedAction, changed bool, err error) {
+	p.planAction = action
+	if action.Src != "" {
+		p.src, err = findPlanObject(ctx, fsrc, action.Src)
+		if err != nil {
+			return p, false, err
+		}
+	}
+	if action.Dst != "" {
+		p.dst, err = findPlanObject(ctx, fdst, action.Dst)
+		if err != nil {
+			return p, false, err
+		}
+	}
+	check := func(f fs.Fs, remote string, info *planObject, o fs.Object) {
+		if reason := planChanged(ctx, info, o); reason != "" {
+			fs.Errorf(fs.LogDirName(f, remote), "Plan is stale: %s", reason)
+			changed = true
+		}
+	}
+	switch {
+	case action.SrcInfo == nil && action.DstInfo == nil:
+		// plan doesn't record the state of the objects
+	case action.Action != planDelete:
+		check(fsrc, action.Src, action.SrcInfo, p.src)
+		check(fdst, action.Dst, action.DstInfo, p.dst)
+	case p.src != nil:
+		check(fsrc, action.Src, action.SrcInfo, p.src)
+	case p.dst != nil:
+		check(fdst, action.Dst, action.DstInfo, p.dst)
+	}
+	return p, changed, nil
+}
+
+// checkPlan finds the objects for each action in parallel and checks
+// them against the state recorded in the plan, returning the number
+// of actions which have changed since the plan was made
+func checkPlan(ctx context.Context, fdst, fsrc fs.Fs, actions []planAction) (planned []plannedAction, stale int, err error) {
+	planned = make([]plannedAction, len(actions))
+	var (
+		mu sync.Mutex // protects stale and err
+		wg sync.WaitGroup
+	)
+	in := make(chan int, fs.Config.Checkers)
+	for i := 0; i < fs.Config.Checkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range in {
+				p, changed, checkErr := checkPlanAction(ctx, fdst, fsrc, actions[i])
+				planned[i] = p
+				mu.Lock()
+				if changed {
+					stale++
+				}
+				if err == nil {
+					err = checkErr
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range actions {
+		in <- i
+	}
+	close(in)
+	wg.Wait()
+	return planned, stale, err
+}
+
+// runPlan executes the plan in the file at path.
+//
+// Each action is checked first and the plan is refused if any of the
+// files it acts on have changed since it was made, unless
+// --plan-force is set.  Then the copies and moves are done, then if
+// there were no errors the deletes.
+func runPlan(ctx context.Context, fdst, fsrc fs.Fs, path string) error {
+	actions, err := readPlan(path, fdst, fsrc)
+	if err != nil {
+		return fserrors.FatalError(err)
+	}
+	fs.Infof(fdst, "Checking %d actions from plan %q", len(actions), path)
+	planned, stale, err := checkPlan(ctx, fdst, fsrc, actions)
+	if err != nil {
+		return errors.Wrap(err, "failed to check plan")
+	}
+	if stale > 0 {
+		if !fs.Config.PlanForce {
+			return fserrors.FatalError(errors.Errorf("plan is stale: %d actions changed since it was made - use --plan-force to run it anyway", stale))
+		}
+		fs.Logf(fdst, "Running plan with %d stale actions as --plan-force is set", stale)
+	}
+	fs.Infof(fdst, "Running %d actions from plan %q", len(actions), path)
+
+	var (
+		errMu    sync.Mutex
+		errCount int
+		lastErr  error
+	)
+	processError := func(err error) {
+		if err == nil {
+			return
+		}
+		errMu.Lock()
+		errCount++
+		lastErr = err
+		errMu.Unlock()
+	}
+
+	// Do the transfers
+	transfers := make(chan plannedAction, fs.Config.Transfers)
+	var wg sync.WaitGroup
+	for i := 0; i < fs.Config.Transfers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range transfers {
+				processError(runPlanTransfer(ctx, fdst, fsrc, p))
+			}
+		}()
+	}
+	var deletes []plannedAction
+	for _, p := range planned {
+		if p.Action == planDelete {
+			deletes = append(deletes, p)
+			continue
+		}
+		transfers <- p
+	}
+	close(transfers)
+	wg.Wait()
+
+	// Then the deletes
+	if len(deletes) > 0 {
+		if errCount > 0 && !fs.Config.IgnoreErrors {
+			fs.Errorf(fdst, "%v", fs.ErrorNotDeleting)
+		} else {
+			toBeDeleted := make(fs.ObjectsChan, fs.Config.Transfers)
+			go func() {
+				defer close(toBeDeleted)
+				for _, p := range deletes {
+					o, f, remote := p.dst, fdst, p.Dst
+					if p.Src != "" {
+						o, f, remote = p.src, fsrc, p.Src
+					}
+					if o == nil {
+						fs.Debugf(fs.LogDirName(f, remote), "Not deleting as already gone")
+						continue
+					}
+					toBeDeleted <- o
+				}
+			}()
+			processError(operations.DeleteFiles(ctx, toBeDeleted))
+		}
+	}
+
+	if errCount > 0 {
+		return errors.Wrapf(lastErr, "%d errors running plan, last error", errCount)
+	}
+	return nil
+}
+
+// runPlanTransfer carries out a single copy or move from a plan
+func runPlanTransfer(ctx context.Context, fdst, fsrc fs.Fs, p plannedAction) (err error) {
+	if p.src == nil {
+		err = fs.CountError(fs.ErrorObjectNotFound)
+		fs.Errorf(fs.LogDirName(fsrc, p.Src), "Failed to find source object: %v", err)
+		return err
+	}
+	if p.Action == planMove {
+		_, err = operations.Move(ctx, fdst, p.dst, p.Dst, p.src)
+	} else {
+		_, err = operations.Copy(ctx, fdst, p.dst, p.Dst, p.src)
+	}
+	return err
+}
@@ -0,0 +1,243 @@
+package sync
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/atexit"
+)
+
+// resumeFilterMagic starts a --resume-filter file
+const resumeFilterMagic = "rclone-resume-filter-1\n"
+
+// resumeFilterSaveInterval is how often the --resume-filter is saved
+// while the sync is running
+const resumeFilterSaveInterval = time.Minute
+
+// resumeFilter is a bloom filter of the files a sync has completed
+// which is saved in the --resume-filter file so an interrupted sync
+// can skip them when it is run again.
+//
+// A bloom filter can say a file is in it when it isn't, at
+// --resume-filter-error-rate, so a few files which weren't completed
+// are skipped too.
+type resumeFilter struct {
+	path string
+	sync string // the sync the filter is for
+
+	mu    sync.Mutex
+	bits  []uint64 // the bits of the filter
+	k     uint64   // number of bits set for each file
+	items uint64   // number of files the filter was sized for
+	n     uint64   // number of files added
+	warn  bool     // set once warned about too many files
+	dirty bool     // set if changed since last saved
+	stop  chan struct{}
+	wg    sync.WaitGroup
+
+	atexitHandle atexit.FnHandle
+}
+
+// newResumeFilter loads the --resume-filter for a sync of fsrc to
+// fdst or makes a new one if it doesn't exist.
+func newResumeFilter(fdst, fsrc fs.Fs) (*resumeFilter, error) {
+	r := &resumeFilter{
+		path: fs.Config.ResumeFilter,
+		sync: fs.ConfigString(fsrc) + " -> " + fs.ConfigString(fdst),
+		stop: make(chan struct{}),
+	}
+	err := r.load()
+	if os.IsNotExist(err) {
+		err = r.init(fs.Config.ResumeFilterItems, fs.Config.ResumeFilterErrorRate)
+		if err != nil {
+			return nil, err
+		}
+		fs.Infof(nil, "Made new --resume-filter %q for %d files", r.path, r.items)
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to read --resume-filter %q", r.path)
+	} else {
+		fs.Logf(nil, "Resuming sync skipping the %d files completed in --resume-filter %q", r.n, r.path)
+	}
+	if !fs.Config.DryRun {
+		r.atexitHandle = atexit.Register(func() {
+			_ = r.save()
+		})
+		r.wg.Add(1)
+		go r.saver()
+	}
+	return r, nil
+}
+
+// init sizes an empty filter for items files with a false positive
+// rate of errorRate
+func (r *resumeFilter) init(items int64, errorRate float64) error {
+	if items <= 0 {
+		return errors.New("--resume-filter-items must be more than 0")
+	}
+	if errorRate <= 0 || errorRate >= 1 {
+		return errors.New("--resume-filter-error-rate must be between 0 and 1")
+	}
+	m := math.Ceil(-float64(items) * math.Log(errorRate) / (math.Ln2 * math.Ln2))
+	r.bits = make([]uint64, (uint64(m)+63)/64)
+	r.k = uint64(math.Round(m / float64(items) * math.Ln2))
+	if r.k < 1 {
+		r.k = 1
+	}
+	r.items = uint64(items)
+	return nil
+}
+
+// positions calls fn with the position of each bit for remote
+func (r *resumeFilter) positions(remote string, fn func(word uint64, mask uint64) bool) bool {
+	h := fnv.New128a()
+	_, _ = h.Write([]byte(remote))
+	sum := h.Sum(nil)
+	h1 := binary.BigEndian.Uint64(sum[:8])
+	h2 := binary.BigEndian.Uint64(sum[8:])
+	m := uint64(len(r.bits)) * 64
+	for i := uint64(0); i < r.k; i++ {
+		bit := (h1 + i*h2) % m
+		if !fn(bit/64, 1<<(bit%64)) {
+			return false
+		}
+	}
+	return true
+}
+
+// add records remote as completed
+func (r *resumeFilter) add(remote string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.positions(remote, func(word uint64, mask uint64) bool {
+		r.bits[word] |= mask
+		return true
+	})
+	r.n++
+	r.dirty = true
+	if r.n > r.items && !r.warn {
+		r.warn = true
+		fs.Logf(nil, "More than --resume-filter-items %d files completed so more files will be skipped by mistake if resumed", r.items)
+	}
+}
+
+// contains returns true if remote has probably been completed
+func (r *resumeFilter) contains(remote string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.positions(remote, func(word uint64, mask uint64) bool {
+		return r.bits[word]&mask != 0
+	})
+}
+
+// load reads the filter from its file
+func (r *resumeFilter) load() (err error) {
+	in, err := os.Open(r.path)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(in, &err)
+	buf := bufio.NewReader(in)
+	magic := make([]byte, len(resumeFilterMagic))
+	_, err = io.ReadFull(buf, magic)
+	if err != nil || string(magic) != resumeFilterMagic {
+		return errors.New("not a resume filter file")
+	}
+	syncName, err := buf.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if syncName[:len(syncName)-1] != r.sync {
+		return errors.Errorf("it was made for a sync of %s", syncName[:len(syncName)-1])
+	}
+	var header [4]uint64 // k, items, n, words
+	err = binary.Read(buf, binary.BigEndian, &header)
+	if err != nil {
+		return err
+	}
+	r.k, r.items, r.n = header[0], header[1], header[2]
+	if r.k < 1 || header[3] < 1 {
+		return errors.New("corrupted resume filter file")
+	}
+	r.bits = make([]uint64, header[3])
+	return binary.Read(buf, binary.BigEndian, r.bits)
+}
+
+// save writes the filter to its file if it has changed
+func (r *resumeFilter) save() (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.dirty {
+		return nil
+	}
+	tmp := r.path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return errors.Wrap(err, "failed to save --resume-filter")
+	}
+	buf := bufio.NewWriter(out)
+	_, _ = buf.WriteString(resumeFilterMagic)
+	_, _ = buf.WriteString(r.sync + "\n")
+	_ = binary.Write(buf, binary.BigEndian, [4]uint64{r.k, r.items, r.n, uint64(len(r.bits))})
+	_ = binary.Write(buf, binary.BigEndian, r.bits)
+	err = buf.Flush()
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, r.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return errors.Wrap(err, "failed to save --resume-filter")
+	}
+	r.dirty = false
+	return nil
+}
+
+// saver saves the filter regularly until stopped
+func (r *resumeFilter) saver() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(resumeFilterSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			err := r.save()
+			if err != nil {
+				fs.Errorf(nil, "%v", err)
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// finish saves the filter if the sync finished with err or removes it
+// if the sync completed.
+func (r *resumeFilter) finish(err error) error {
+	if fs.Config.DryRun {
+		return nil
+	}
+	close(r.stop)
+	r.wg.Wait()
+	atexit.Unregister(r.atexitHandle)
+	if err != nil {
+		fs.Logf(nil, "Saving --resume-filter %q with %d files completed to resume the sync", r.path, r.n)
+		return r.save()
+	}
+	removeErr := os.Remove(r.path)
+	if removeErr != nil && !os.IsNotExist(removeErr) {
+		return errors.Wrap(removeErr, "failed to remove --resume-filter")
+	}
+	fs.Infof(nil, "Removed --resume-filter %q as the sync completed", r.path)
+	return nil
+}
@@ -8,16 +8,20 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/config"
 	"github.com/rclone/rclone/fs/filter"
 	"github.com/rclone/rclone/fs/fserrors"
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/march"
 	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/lib/pacer"
+	"golang.org/x/time/rate"
 )
 
 type syncCopyMove struct {
@@ -53,11 +57,14 @@ type syncCopyMove struct {
 	toBeChecked            *pipe                  // checkers channel
 	transfersWg            sync.WaitGroup         // wait for transfers
 	toBeUploaded           *pipe                  // copiers channel
+	transfersLargeWg       sync.WaitGroup         // wait for transfers of large files
+	toBeUploadedLarge      *pipe                  // copiers channel for large files if --transfers-large is set
 	errorMu                sync.Mutex             // Mutex covering the errors variables
 	err                    error                  // normal error from copy process
 	noRetryErr             error                  // error with NoRetry set
 	fatalErr               error                  // fatal error
 	commonHash             hash.Type              // common hash type between src and dst
+	plan                   *planWriter            // if set write the actions here instead of doing them
 	modifyWindow           time.Duration          // modify window between fsrc, fdst
 	renameMapMu            sync.Mutex             // mutex to protect the below
 	renameMap              map[string][]fs.Object // dst files by hash - only used by trackRenames
@@ -69,6 +76,14 @@ type syncCopyMove struct {
 	compareCopyDest        fs.Fs                  // place to check for files to server side copy
 	backupDir              fs.Fs                  // place to store overwrites/deletes
 	checkFirst             bool                   // if set run all the checkers before starting transfers
+	checkFreeInodes        bool                   // if set check there are enough free inodes before starting transfers
+	srcOnlyDirs            int64                  // number of directories only in the source - protected by srcEmptyDirsMu
+	transferStartLimiter   *rate.Limiter          // if set limits the rate transfers are started at
+	dups                   *dupLinker             // if set link duplicate contents instead of copying
+	collectDeletes         bool                   // if set collect the files to delete in dstFiles and delete them at the end
+	dstObjects             int64                  // number of objects found in the destination - use atomic
+	resume                 *resumeFilter          // if set skip the files completed in it and add the files completed
+	srcDeletes             *srcDeletes            // if set the source files to delete once the move has succeeded
 }
 
 type trackRenamesStrategy byte
@@ -103,30 +118,67 @@ func newSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.Delete
 		dstFilesResult:         make(chan error, 1),
 		dstEmptyDirs:           make(map[string]fs.DirEntry),
 		srcEmptyDirs:           make(map[string]fs.DirEntry),
-		noTraverse:             fs.Config.NoTraverse,
+		noTraverse:             fs.Config.NoTraverse || fs.Config.ProbeDest,
 		noCheckDest:            fs.Config.NoCheckDest,
 		noUnicodeNormalization: fs.Config.NoUnicodeNormalization,
 		deleteFilesCh:          make(chan fs.Object, fs.Config.Checkers),
 		trackRenames:           fs.Config.TrackRenames,
-		commonHash:             fsrc.Hashes().Overlap(fdst.Hashes()).GetOne(),
 		modifyWindow:           fs.GetModifyWindow(fsrc, fdst),
 		trackRenamesCh:         make(chan fs.Object, fs.Config.Checkers),
 		checkFirst:             fs.Config.CheckFirst,
+		checkFreeInodes:        fs.Config.CheckFreeInodes,
+	}
+	var err error
+	s.commonHash, err = operations.PreferredHash(fsrc, fdst)
+	if err != nil {
+		if fs.Config.CheckSum {
+			return nil, fserrors.FatalError(err)
+		}
+		s.commonHash = fsrc.Hashes().Overlap(fdst.Hashes()).GetOne()
+	}
+	if !DoMove {
+		s.dups = newDupLinker(fdst, fsrc)
+	}
+	if fs.Config.TransferStartRate > 0 {
+		s.transferStartLimiter = rate.NewLimiter(rate.Limit(fs.Config.TransferStartRate), 1)
+	}
+	if s.deleteMode == fs.DeleteModeAfter {
+		s.collectDeletes = true
+	} else if s.deleteMode != fs.DeleteModeOff && fs.Config.MaxDeleteFraction > 0 {
+		// Need to count the deletes before doing any
+		fs.Debugf(s.fdst, "Deleting files at the end to check --max-delete-fraction")
+		s.collectDeletes = true
+	}
+	if s.checkFreeInodes {
+		// Need all the checks done to count the files to create
+		s.checkFirst = true
 	}
 	backlog := fs.Config.MaxBacklog
 	if s.checkFirst {
 		fs.Infof(s.fdst, "Running all checks before starting transfers")
 		backlog = -1
 	}
-	var err error
 	s.toBeChecked, err = newPipe(fs.Config.OrderBy, accounting.Stats(ctx).SetCheckQueue, backlog)
 	if err != nil {
 		return nil, err
 	}
-	s.toBeUploaded, err = newPipe(fs.Config.OrderBy, accounting.Stats(ctx).SetTransferQueue, backlog)
+	transferQueueStats := accounting.Stats(ctx).SetTransferQueue
+	if fs.Config.TransfersLarge > 0 {
+		// Show the large files waiting to be transferred in the
+		// transfer queue too
+		queues := &queueStats{set: transferQueueStats}
+		transferQueueStats = queues.stats(0)
+		// This is unbounded as it is only filled from toBeUploaded
+		s.toBeUploadedLarge, err = newPipe(fs.Config.OrderBy, queues.stats(1), -1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.toBeUploaded, err = newPipe(fs.Config.OrderBy, transferQueueStats, backlog)
 	if err != nil {
 		return nil, err
 	}
+	s.toBeUploaded.queued = accounting.Stats(ctx).TransferQueued
 	s.toBeRenamed, err = newPipe(fs.Config.OrderBy, accounting.Stats(ctx).SetRenameQueue, backlog)
 	if err != nil {
 		return nil, err
@@ -139,7 +191,7 @@ func newSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.Delete
 	} else {
 		s.ctx, s.cancel = context.WithCancel(ctx)
 	}
-	if s.noTraverse && s.deleteMode != fs.DeleteModeOff {
+	if s.noTraverse && s.deleteMode != fs.DeleteModeOff && !fs.Config.ProbeDest {
 		fs.Errorf(nil, "Ignoring --no-traverse with sync")
 		s.noTraverse = false
 	}
@@ -147,6 +199,9 @@ func newSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.Delete
 	if err != nil {
 		return nil, err
 	}
+	if s.deleteMode != fs.DeleteModeOff && fs.Config.OnOversize == fs.OversizeSplit {
+		return nil, errors.New("can't use --on-oversize split with sync as the parts aren't on the source so would be deleted: use copy instead")
+	}
 	if s.noCheckDest {
 		if s.deleteMode != fs.DeleteModeOff {
 			return nil, errors.New("can't use --no-check-dest with sync: use copy instead")
@@ -158,6 +213,22 @@ func newSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.Delete
 			return nil, errors.New("can't use --no-check-dest with --backup-dir")
 		}
 	}
+	if fs.Config.DetectMoves && !s.trackRenames {
+		// Only use rename tracking if it can be done with server
+		// side moves, otherwise carry on as normal.
+		switch {
+		case fdst.Features().Move == nil:
+			fs.Debugf(fdst, "Not detecting moves as the destination does not support server-side move")
+		case s.commonHash == hash.None:
+			fs.Debugf(fdst, "Not detecting moves as the source and destination do not have a common hash")
+		case s.deleteMode != fs.DeleteModeAfter && s.deleteMode != fs.DeleteModeDuring:
+			fs.Debugf(fdst, "Not detecting moves as it only works with sync")
+		default:
+			fs.Debugf(fdst, "Detecting moves using %v hashes", s.commonHash)
+			s.trackRenames = true
+			s.trackRenamesStrategy = trackRenamesStrategyHash
+		}
+	}
 	if s.trackRenames {
 		// Don't track renames for remotes without server-side move support.
 		if !operations.CanServerSideMove(fdst) {
@@ -183,6 +254,7 @@ func newSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.Delete
 		// track renames needs delete after
 		if s.deleteMode != fs.DeleteModeOff {
 			s.deleteMode = fs.DeleteModeAfter
+			s.collectDeletes = true
 		}
 		if s.noTraverse {
 			fs.Errorf(nil, "Ignoring --no-traverse with --track-renames")
@@ -281,6 +353,11 @@ func (s *syncCopyMove) currentError() error {
 // FIXME potentially doing lots of hashes at once
 func (s *syncCopyMove) pairChecker(in *pipe, out *pipe, fraction int, wg *sync.WaitGroup) {
 	defer wg.Done()
+	// Don't change the destination while checking for a plan
+	checkCtx := s.ctx
+	if s.plan != nil {
+		checkCtx = operations.WithNoModify(s.ctx, "--plan-out")
+	}
 	for {
 		pair, ok := in.GetMax(s.ctx, fraction)
 		if !ok {
@@ -291,11 +368,11 @@ func (s *syncCopyMove) pairChecker(in *pipe, out *pipe, fraction int, wg *sync.W
 		tr := accounting.Stats(s.ctx).NewCheckingTransfer(src)
 		// Check to see if can store this
 		if src.Storable() {
-			NoNeedTransfer, err := operations.CompareOrCopyDest(s.ctx, s.fdst, pair.Dst, pair.Src, s.compareCopyDest, s.backupDir)
+			NoNeedTransfer, err := operations.CompareOrCopyDest(checkCtx, s.fdst, pair.Dst, pair.Src, s.compareCopyDest, s.backupDir)
 			if err != nil {
 				s.processError(err)
 			}
-			if !NoNeedTransfer && operations.NeedTransfer(s.ctx, pair.Dst, pair.Src) {
+			if !NoNeedTransfer && operations.NeedTransfer(checkCtx, pair.Dst, pair.Src) {
 				// If files are treated as immutable, fail if destination exists and does not match
 				if fs.Config.Immutable && pair.Dst != nil {
 					fs.Errorf(pair.Dst, "Source and destination exist but do not match: immutable file modified")
@@ -322,10 +399,25 @@ func (s *syncCopyMove) pairChecker(in *pipe, out *pipe, fraction int, wg *sync.W
 					}
 				}
 			} else {
+				s.completed(src)
+				if pair.Dst != nil && s.plan == nil {
+					operations.SyncACL(s.ctx, src, pair.Dst)
+				}
 				// If moving need to delete the files we don't need to copy
 				if s.DoMove {
-					// Delete src if no error on copy
-					s.processError(operations.DeleteFile(s.ctx, src))
+					if s.plan != nil {
+						s.plan.add(planAction{
+							Action:  planDelete,
+							Src:     src.Remote(),
+							Reason:  "identical in destination",
+							SrcInfo: newPlanObject(s.ctx, src),
+						})
+					} else if s.srcDeletes != nil {
+						s.srcDeletes.add(src.Remote())
+					} else {
+						// Delete src if no error on copy
+						s.processError(operations.DeleteFile(s.ctx, src))
+					}
 				}
 			}
 		}
@@ -363,12 +455,50 @@ func (s *syncCopyMove) pairCopyOrMove(ctx context.Context, in *pipe, fdst fs.Fs,
 			return
 		}
 		src := pair.Src
-		if s.DoMove {
+		if s.plan != nil {
+			s.addTransferToPlan(pair)
+			continue
+		}
+		if s.toBeUploadedLarge != nil && in != s.toBeUploadedLarge && src.Size() >= int64(fs.Config.SizeThreshold) {
+			// Leave large files to the --transfers-large transfers
+			ok = s.toBeUploadedLarge.Put(s.ctx, pair)
+			if !ok {
+				return
+			}
+			continue
+		}
+		if s.transferStartLimiter != nil {
+			err = s.transferStartLimiter.Wait(ctx)
+			if err != nil {
+				fs.Errorf(src, "Couldn't start transfer: %v", err)
+				s.processError(fs.CountError(err))
+				continue
+			}
+		}
+		if s.srcDeletes != nil {
+			_, err = operations.Copy(ctx, fdst, pair.Dst, src.Remote(), src)
+			if err == nil {
+				s.srcDeletes.add(src.Remote())
+			}
+		} else if s.DoMove {
 			_, err = operations.Move(ctx, fdst, pair.Dst, src.Remote(), src)
+		} else if s.dups != nil {
+			err = s.dups.copy(ctx, pair.Dst, src)
 		} else {
 			_, err = operations.Copy(ctx, fdst, pair.Dst, src.Remote(), src)
 		}
 		s.processError(err)
+		if err == nil {
+			s.completed(src)
+		}
+	}
+}
+
+// completed records that src has been synced to the destination in
+// the --resume-filter if set
+func (s *syncCopyMove) completed(src fs.Object) {
+	if s.resume != nil && s.plan == nil {
+		s.resume.add(src.Remote())
 	}
 }
 
@@ -395,6 +525,13 @@ func (s *syncCopyMove) startTransfers() {
 		fraction := (100 * i) / fs.Config.Transfers
 		go s.pairCopyOrMove(s.ctx, s.toBeUploaded, s.fdst, fraction, &s.transfersWg)
 	}
+	if s.toBeUploadedLarge != nil {
+		s.transfersLargeWg.Add(fs.Config.TransfersLarge)
+		for i := 0; i < fs.Config.TransfersLarge; i++ {
+			fraction := (100 * i) / fs.Config.TransfersLarge
+			go s.pairCopyOrMove(s.ctx, s.toBeUploadedLarge, s.fdst, fraction, &s.transfersLargeWg)
+		}
+	}
 }
 
 // This stops the background transfers
@@ -402,6 +539,12 @@ func (s *syncCopyMove) stopTransfers() {
 	s.toBeUploaded.Close()
 	fs.Debugf(s.fdst, "Waiting for transfers to finish")
 	s.transfersWg.Wait()
+	if s.toBeUploadedLarge != nil {
+		// Nothing more can be put in now the other transfers have
+		// finished
+		s.toBeUploadedLarge.Close()
+		s.transfersLargeWg.Wait()
+	}
 }
 
 // This starts the background renamers.
@@ -451,20 +594,20 @@ func (s *syncCopyMove) stopTrackRenames() {
 
 // This starts the background deletion of files for --delete-during
 func (s *syncCopyMove) startDeleters() {
-	if s.deleteMode != fs.DeleteModeDuring && s.deleteMode != fs.DeleteModeOnly {
+	if (s.deleteMode != fs.DeleteModeDuring && s.deleteMode != fs.DeleteModeOnly) || s.collectDeletes {
 		return
 	}
 	s.deletersWg.Add(1)
 	go func() {
 		defer s.deletersWg.Done()
-		err := operations.DeleteFilesWithBackupDir(s.ctx, s.deleteFilesCh, s.backupDir)
+		err := s.deleteObjects(s.deleteFilesCh)
 		s.processError(err)
 	}()
 }
 
 // This stops the background deleters
 func (s *syncCopyMove) stopDeleters() {
-	if s.deleteMode != fs.DeleteModeDuring && s.deleteMode != fs.DeleteModeOnly {
+	if (s.deleteMode != fs.DeleteModeDuring && s.deleteMode != fs.DeleteModeOnly) || s.collectDeletes {
 		return
 	}
 	close(s.deleteFilesCh)
@@ -483,16 +626,15 @@ func (s *syncCopyMove) deleteFiles(checkSrcMap bool) error {
 	}
 
 	// Delete the spare files
+	objects := s.filesToDelete(checkSrcMap)
+	err := s.checkDeleteFraction(len(objects))
+	if err != nil {
+		return err
+	}
 	toDelete := make(fs.ObjectsChan, fs.Config.Transfers)
 	go func() {
 	outer:
-		for remote, o := range s.dstFiles {
-			if checkSrcMap {
-				_, exists := s.srcFiles[remote]
-				if exists {
-					continue
-				}
-			}
+		for _, o := range objects {
 			if s.aborting() {
 				break
 			}
@@ -504,7 +646,77 @@ func (s *syncCopyMove) deleteFiles(checkSrcMap bool) error {
 		}
 		close(toDelete)
 	}()
-	return operations.DeleteFilesWithBackupDir(s.ctx, toDelete, s.backupDir)
+	return s.deleteObjects(toDelete)
+}
+
+// checkDeleteFraction checks that deleting deletes files isn't more
+// than --max-delete-fraction of the files in the destination
+//
+// If it is more then it asks the user to confirm with --interactive
+// and otherwise returns a fatal error unless --force is set.
+func (s *syncCopyMove) checkDeleteFraction(deletes int) error {
+	maxFraction := fs.Config.MaxDeleteFraction
+	total := atomic.LoadInt64(&s.dstObjects)
+	if maxFraction <= 0 || deletes == 0 || total == 0 {
+		return nil
+	}
+	fraction := float64(deletes) / float64(total)
+	if fraction <= maxFraction {
+		return nil
+	}
+	what := fmt.Sprintf("%d of the %d files (%.1f%%) in the destination which is more than --max-delete-fraction %g", deletes, total, 100*fraction, maxFraction)
+	switch {
+	case fs.Config.Force:
+		fs.Logf(s.fdst, "Deleting %s as --force is set", what)
+		return nil
+	case fs.Config.Interactive:
+		fmt.Printf("rclone is about to delete %s.\nAre you sure?\n", what)
+		if config.Confirm(false) {
+			return nil
+		}
+	}
+	err := errors.Errorf("not deleting %s - use --force to delete them anyway", what)
+	fs.Errorf(s.fdst, "%v", err)
+	return fserrors.FatalError(err)
+}
+
+// filesToDelete returns the objects in dstFiles to delete, skipping
+// any in srcFiles if checkSrcMap is set.
+//
+// If --delete-deepest-first is set they are sorted so the files in
+// the deepest directories come first.
+func (s *syncCopyMove) filesToDelete(checkSrcMap bool) []fs.Object {
+	objects := make([]fs.Object, 0, len(s.dstFiles))
+	for remote, o := range s.dstFiles {
+		if checkSrcMap {
+			_, exists := s.srcFiles[remote]
+			if exists {
+				continue
+			}
+		}
+		objects = append(objects, o)
+	}
+	if fs.Config.DeleteDeepestFirst {
+		sort.Slice(objects, func(i, j int) bool {
+			a, b := objects[i].Remote(), objects[j].Remote()
+			depthA, depthB := strings.Count(a, "/"), strings.Count(b, "/")
+			if depthA != depthB {
+				return depthA > depthB
+			}
+			return a < b
+		})
+	}
+	return objects
+}
+
+// deleteObjects deletes the objects from toBeDeleted, or adds them to
+// the plan if making one
+func (s *syncCopyMove) deleteObjects(toBeDeleted fs.ObjectsChan) error {
+	if s.plan != nil {
+		s.plan.addDeletes(s.ctx, toBeDeleted)
+		return nil
+	}
+	return operations.DeleteFilesWithBackupDir(s.ctx, toBeDeleted, s.backupDir)
 }
 
 // This deletes the empty directories in the slice passed in.  It
@@ -553,25 +765,65 @@ func deleteEmptyDirectories(ctx context.Context, f fs.Fs, entriesMap map[string]
 
 // This copies the empty directories in the slice passed in and logs
 // any errors copying the directories
+//
+// Up to --mkdir-concurrency directories are made at once, but each
+// is only made once its parent has been.
 func copyEmptyDirectories(ctx context.Context, f fs.Fs, entries map[string]fs.DirEntry) error {
 	if len(entries) == 0 {
 		return nil
 	}
 
-	var okCount int
+	var dirs []string
 	for _, entry := range entries {
 		dir, ok := entry.(fs.Directory)
 		if ok {
-			err := operations.Mkdir(ctx, f, dir.Remote())
-			if err != nil {
-				fs.Errorf(fs.LogDirName(f, dir.Remote()), "Failed to Mkdir: %v", err)
-			} else {
-				okCount++
-			}
+			dirs = append(dirs, dir.Remote())
 		} else {
 			fs.Errorf(f, "Not a directory: %v", entry)
 		}
 	}
+	// Sorting puts the parents first so they are started first
+	sort.Strings(dirs)
+	made := make(map[string]chan struct{}, len(dirs)) // closed when the directory is done
+	for _, dir := range dirs {
+		made[dir] = make(chan struct{})
+	}
+
+	concurrency := fs.Config.MkdirConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex // protects okCount
+		okCount int
+		tokens  = pacer.NewTokenDispenser(concurrency)
+	)
+	for _, dir := range dirs {
+		tokens.Get()
+		wg.Add(1)
+		go func(dir string) {
+			defer wg.Done()
+			defer tokens.Put()
+			defer close(made[dir])
+			// Wait for the nearest parent being made, if any
+			for parent := path.Dir(dir); parent != "." && parent != "/"; parent = path.Dir(parent) {
+				if parentMade, ok := made[parent]; ok {
+					<-parentMade
+					break
+				}
+			}
+			err := operations.Mkdir(ctx, f, dir)
+			if err != nil {
+				fs.Errorf(fs.LogDirName(f, dir), "Failed to Mkdir: %v", err)
+				return
+			}
+			mu.Lock()
+			okCount++
+			mu.Unlock()
+		}(dir)
+	}
+	wg.Wait()
 
 	if accounting.Stats(ctx).Errored() {
 		fs.Debugf(f, "failed to copy %d directories", accounting.Stats(ctx).GetErrors())
@@ -579,6 +831,9 @@ func copyEmptyDirectories(ctx context.Context, f fs.Fs, entries map[string]fs.Di
 
 	if okCount > 0 {
 		fs.Debugf(f, "copied %d directories", okCount)
+		if !f.Features().CanHaveEmptyDirectories && fs.Config.DirMarkers != fs.DirMarkersCreate {
+			fs.Logf(f, "Skipped %d empty directories as this remote can't have empty directories - use --handle-dir-markers create to make directory markers for them where supported", okCount)
+		}
 	}
 	return nil
 }
@@ -598,6 +853,42 @@ func (s *syncCopyMove) srcParentDirCheck(entry fs.DirEntry) {
 	}
 }
 
+// checkInodes checks, if --check-free-inodes is set, that the
+// destination has enough free inodes for the files and directories
+// which are about to be created.
+//
+// It must be called once all the checks are finished. It does nothing
+// if the destination doesn't report its free inodes.
+func (s *syncCopyMove) checkInodes() error {
+	if !s.checkFreeInodes {
+		return nil
+	}
+	do := s.fdst.Features().About
+	if do == nil {
+		fs.Debugf(s.fdst, "Not checking free inodes as About is not supported")
+		return nil
+	}
+	usage, err := do(s.ctx)
+	if err != nil {
+		fs.Debugf(s.fdst, "Not checking free inodes as About failed: %v", err)
+		return nil
+	}
+	if usage.FreeObjects == nil {
+		fs.Debugf(s.fdst, "Not checking free inodes as they aren't reported")
+		return nil
+	}
+	s.srcEmptyDirsMu.Lock()
+	dirs := s.srcOnlyDirs
+	s.srcEmptyDirsMu.Unlock()
+	need := int64(s.toBeUploaded.countNew()) + dirs
+	free := *usage.FreeObjects
+	fs.Infof(s.fdst, "Need %d inodes to create new files and directories, %d free", need, free)
+	if need > free {
+		return fserrors.FatalError(errors.Errorf("not enough free inodes on destination: need %d but only %d free", need, free))
+	}
+	return nil
+}
+
 // parseTrackRenamesStrategy turns a config string into a trackRenamesStrategy
 func parseTrackRenamesStrategy(strategies string) (strategy trackRenamesStrategy, err error) {
 	if len(strategies) == 0 {
@@ -783,6 +1074,21 @@ func (s *syncCopyMove) run() error {
 		return nil
 	}
 
+	// Collect the modification times to set after the transfers
+	var modTimes *operations.DeferredModTimes
+	if fs.Config.DeferModTime && !s.DoMove && s.plan == nil {
+		modTimes = operations.NewDeferredModTimes(s.fdst)
+		s.ctx = operations.WithDeferredModTimes(s.ctx, modTimes)
+	}
+
+	if s.DoMove && fs.Config.MoveDeleteAfter && s.plan == nil {
+		var err error
+		s.srcDeletes, err = newSrcDeletes()
+		if err != nil {
+			return fserrors.FatalError(err)
+		}
+	}
+
 	// Start background checking and transferring pipeline
 	s.startCheckers()
 	s.startRenamers()
@@ -824,19 +1130,31 @@ func (s *syncCopyMove) run() error {
 	// Stop background checking and transferring pipeline
 	s.stopCheckers()
 	if s.checkFirst {
-		fs.Infof(s.fdst, "Checks finished, now starting transfers")
-		s.startTransfers()
+		// Finish the renames so all the uploads are queued
+		s.stopRenamers()
+		err := s.checkInodes()
+		if err != nil {
+			s.processError(err)
+		} else {
+			fs.Infof(s.fdst, "Checks finished, now starting transfers")
+			s.startTransfers()
+		}
+	} else {
+		s.stopRenamers()
 	}
-	s.stopRenamers()
 	s.stopTransfers()
 	s.stopDeleters()
 
-	if s.copyEmptySrcDirs {
+	if modTimes != nil {
+		s.processError(modTimes.Apply(s.ctx))
+	}
+
+	if s.copyEmptySrcDirs && s.plan == nil {
 		s.processError(copyEmptyDirectories(s.ctx, s.fdst, s.srcEmptyDirs))
 	}
 
 	// Delete files after
-	if s.deleteMode == fs.DeleteModeAfter {
+	if s.collectDeletes {
 		if s.currentError() != nil && !fs.Config.IgnoreErrors {
 			fs.Errorf(s.fdst, "%v", fs.ErrorNotDeleting)
 		} else {
@@ -845,7 +1163,7 @@ func (s *syncCopyMove) run() error {
 	}
 
 	// Prune empty directories
-	if s.deleteMode != fs.DeleteModeOff {
+	if s.deleteMode != fs.DeleteModeOff && s.plan == nil {
 		if s.currentError() != nil && !fs.Config.IgnoreErrors {
 			fs.Errorf(s.fdst, "%v", fs.ErrorNotDeletingDirs)
 		} else {
@@ -853,9 +1171,18 @@ func (s *syncCopyMove) run() error {
 		}
 	}
 
+	// Delete the moved source files once all the transfers have worked
+	if s.srcDeletes != nil {
+		err := s.currentError()
+		if fs.Config.IgnoreErrors {
+			err = nil
+		}
+		s.processError(s.srcDeletes.finish(s.ctx, s.fsrc, err))
+	}
+
 	// Delete empty fsrc subdirectories
 	// if DoMove and --delete-empty-src-dirs flag is set
-	if s.DoMove && s.deleteEmptySrcDirs {
+	if s.DoMove && s.deleteEmptySrcDirs && s.plan == nil {
 		//delete empty subdirectories that were part of the move
 		s.processError(deleteEmptyDirectories(s.ctx, s.fsrc, s.srcEmptyDirs))
 	}
@@ -879,13 +1206,14 @@ func (s *syncCopyMove) DstOnly(dst fs.DirEntry) (recurse bool) {
 	}
 	switch x := dst.(type) {
 	case fs.Object:
-		switch s.deleteMode {
-		case fs.DeleteModeAfter:
+		atomic.AddInt64(&s.dstObjects, 1)
+		switch {
+		case s.collectDeletes:
 			// record object as needs deleting
 			s.dstFilesMu.Lock()
 			s.dstFiles[x.Remote()] = x
 			s.dstFilesMu.Unlock()
-		case fs.DeleteModeDuring, fs.DeleteModeOnly:
+		case s.deleteMode == fs.DeleteModeDuring, s.deleteMode == fs.DeleteModeOnly:
 			select {
 			case <-s.ctx.Done():
 				return
@@ -897,7 +1225,8 @@ func (s *syncCopyMove) DstOnly(dst fs.DirEntry) (recurse bool) {
 	case fs.Directory:
 		// Do the same thing to the entire contents of the directory
 		// Record directory as it is potentially empty and needs deleting
-		if s.fdst.Features().CanHaveEmptyDirectories {
+		// Directory markers can be removed like empty directories
+		if s.fdst.Features().CanHaveEmptyDirectories || fs.Config.DirMarkers != fs.DirMarkersSkip {
 			s.dstEmptyDirsMu.Lock()
 			s.dstEmptyDirs[dst.Remote()] = dst
 			s.dstEmptyDirsMu.Unlock()
@@ -910,6 +1239,19 @@ func (s *syncCopyMove) DstOnly(dst fs.DirEntry) (recurse bool) {
 	return false
 }
 
+// skipTooLarge returns true if src should be skipped because it is
+// bigger than --max-size and --max-size-skip is set, reporting it in
+// the log and the stats if so.
+func (s *syncCopyMove) skipTooLarge(src fs.Object) bool {
+	size := src.Size()
+	if !filter.Active.TooLarge(size) {
+		return false
+	}
+	fs.Logf(src, "Skipping as it is bigger than --max-size %v", filter.Active.Opt.MaxSize)
+	accounting.Stats(s.ctx).SkippedTooLarge(size)
+	return true
+}
+
 // SrcOnly have an object which is in the source only
 func (s *syncCopyMove) SrcOnly(src fs.DirEntry) (recurse bool) {
 	if s.deleteMode == fs.DeleteModeOnly {
@@ -924,6 +1266,9 @@ func (s *syncCopyMove) SrcOnly(src fs.DirEntry) (recurse bool) {
 		s.srcParentDirCheck(src)
 		s.srcEmptyDirsMu.Unlock()
 
+		if s.skipTooLarge(x) {
+			return false
+		}
 		if s.trackRenames {
 			// Save object to check for a rename later
 			select {
@@ -937,6 +1282,10 @@ func (s *syncCopyMove) SrcOnly(src fs.DirEntry) (recurse bool) {
 			if err != nil {
 				s.processError(err)
 			}
+			if !NoNeedTransfer && operations.RecentlyWritten(s.ctx, s.fdst, x.Remote(), x) {
+				fs.Debugf(x, "Not uploading as it was uploaded within --consistency-window")
+				NoNeedTransfer = true
+			}
 			if !NoNeedTransfer {
 				// No need to check since doesn't exist
 				ok := s.toBeUploaded.Put(s.ctx, fs.ObjectPair{Src: x, Dst: nil})
@@ -951,6 +1300,7 @@ func (s *syncCopyMove) SrcOnly(src fs.DirEntry) (recurse bool) {
 		s.srcEmptyDirsMu.Lock()
 		s.srcParentDirCheck(src)
 		s.srcEmptyDirs[src.Remote()] = src
+		s.srcOnlyDirs++
 		s.srcEmptyDirsMu.Unlock()
 		return true
 	default:
@@ -961,6 +1311,9 @@ func (s *syncCopyMove) SrcOnly(src fs.DirEntry) (recurse bool) {
 
 // Match is called when src and dst are present, so sync src to dst
 func (s *syncCopyMove) Match(ctx context.Context, dst, src fs.DirEntry) (recurse bool) {
+	if _, ok := dst.(fs.Object); ok {
+		atomic.AddInt64(&s.dstObjects, 1)
+	}
 	switch srcX := src.(type) {
 	case fs.Object:
 		s.srcEmptyDirsMu.Lock()
@@ -970,6 +1323,13 @@ func (s *syncCopyMove) Match(ctx context.Context, dst, src fs.DirEntry) (recurse
 		if s.deleteMode == fs.DeleteModeOnly {
 			return false
 		}
+		if s.skipTooLarge(srcX) {
+			return false
+		}
+		if s.resume != nil && s.resume.contains(srcX.Remote()) {
+			fs.Debugf(srcX, "Skipping as completed before according to --resume-filter")
+			return false
+		}
 		dstX, ok := dst.(fs.Object)
 		if ok {
 			ok = s.toBeChecked.Put(s.ctx, fs.ObjectPair{Src: srcX, Dst: dstX})
@@ -986,6 +1346,12 @@ func (s *syncCopyMove) Match(ctx context.Context, dst, src fs.DirEntry) (recurse
 		// Do the same thing to the entire contents of the directory
 		_, ok := dst.(fs.Directory)
 		if ok {
+			// Remove the directory marker of the destination so it
+			// doesn't show up as a difference
+			if fs.Config.DirMarkers == fs.DirMarkersDelete && s.plan == nil {
+				err := operations.DeleteDirMarker(s.ctx, s.fdst, dst.Remote(), s.backupDir)
+				s.processError(err)
+			}
 			// Only record matched (src & dst) empty dirs when performing move
 			if s.DoMove {
 				// Record the src directory for deletion
@@ -1014,10 +1380,74 @@ func (s *syncCopyMove) Match(ctx context.Context, dst, src fs.DirEntry) (recurse
 // If DoMove is true then files will be moved instead of copied
 //
 // dir is the start directory, "" for root
-func runSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.DeleteMode, DoMove bool, deleteEmptySrcDirs bool, copyEmptySrcDirs bool) error {
+func runSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.DeleteMode, DoMove bool, deleteEmptySrcDirs bool, copyEmptySrcDirs bool) (err error) {
 	if deleteMode != fs.DeleteModeOff && DoMove {
 		return fserrors.FatalError(errors.New("can't delete and move at the same time"))
 	}
+	if fs.Config.AtTime.IsSet() {
+		if DoMove {
+			return fserrors.FatalError(errors.New("can't use --at-time with move"))
+		}
+		fsrc, err = atTimeFs(ctx, fsrc)
+		if err != nil {
+			return fserrors.FatalError(err)
+		}
+	}
+	if fs.Config.PlanIn != "" {
+		return runPlan(ctx, fdst, fsrc, fs.Config.PlanIn)
+	}
+	if fs.Config.CAS {
+		if deleteMode != fs.DeleteModeOff || DoMove {
+			return fserrors.FatalError(errors.New("--cas can only be used with copy"))
+		}
+		return runCAS(ctx, fdst, fsrc)
+	}
+	if fs.Config.ChangesFile != "" && !DoMove {
+		return runChanges(ctx, fdst, fsrc, deleteMode, func(ctx context.Context, fdst, fsrc fs.Fs) error {
+			return runSyncCopyMoveListing(ctx, fdst, fsrc, deleteMode, DoMove, deleteEmptySrcDirs, copyEmptySrcDirs)
+		})
+	}
+	return runSyncCopyMoveListing(ctx, fdst, fsrc, deleteMode, DoMove, deleteEmptySrcDirs, copyEmptySrcDirs)
+}
+
+// runSyncCopyMoveListing does the sync, copy or move by listing fsrc
+// and fdst and comparing them.
+func runSyncCopyMoveListing(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.DeleteMode, DoMove bool, deleteEmptySrcDirs bool, copyEmptySrcDirs bool) (err error) {
+	var plan *planWriter
+	if fs.Config.PlanOut != "" {
+		err = checkPlanOut()
+		if err != nil {
+			return fserrors.FatalError(err)
+		}
+		plan, err = newPlanWriter(fs.Config.PlanOut, fdst, fsrc)
+		if err != nil {
+			return fserrors.FatalError(err)
+		}
+		defer func() {
+			closeErr := plan.Close()
+			if err == nil {
+				err = closeErr
+			}
+		}()
+	}
+	var resume *resumeFilter
+	if fs.Config.ResumeFilter != "" {
+		resume, err = newResumeFilter(fdst, fsrc)
+		if err != nil {
+			return fserrors.FatalError(err)
+		}
+		defer func() {
+			finishErr := resume.finish(err)
+			if err == nil {
+				err = finishErr
+			}
+		}()
+	}
+	// Files only on the destination can't be found without listing it
+	if fs.Config.ProbeDest && deleteMode != fs.DeleteModeOff {
+		fs.Logf(fdst, "Not deleting files which aren't in the source as they can't be found with --probe-dest")
+		deleteMode = fs.DeleteModeOff
+	}
 	// Run an extra pass to delete only
 	if deleteMode == fs.DeleteModeBefore {
 		if fs.Config.TrackRenames {
@@ -1028,6 +1458,7 @@ func runSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.Delete
 		if err != nil {
 			return err
 		}
+		do.plan = plan
 		err = do.run()
 		if err != nil {
 			return err
@@ -1039,6 +1470,8 @@ func runSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.Delete
 	if err != nil {
 		return err
 	}
+	do.plan = plan
+	do.resume = resume
 	return do.run()
 }
 
@@ -1065,7 +1498,7 @@ func MoveDir(ctx context.Context, fdst, fsrc fs.Fs, deleteEmptySrcDirs bool, cop
 	}
 
 	// First attempt to use DirMover if exists, same Fs and no filters are active
-	if fdstDirMove := fdst.Features().DirMove; fdstDirMove != nil && operations.SameConfig(fsrc, fdst) && filter.Active.InActive() {
+	if fdstDirMove := fdst.Features().DirMove; fdstDirMove != nil && operations.SameConfig(fsrc, fdst) && filter.Active.InActive() && fs.Config.PlanOut == "" && fs.Config.PlanIn == "" {
 		if operations.SkipDestructive(ctx, fdst, "server side directory move") {
 			return nil
 		}
@@ -4,9 +4,16 @@ package sync
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -19,6 +26,7 @@ import (
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/fstest/mockobject"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/text/unicode/norm"
@@ -80,6 +88,225 @@ func TestCopyMissingDirectory(t *testing.T) {
 	require.Error(t, err)
 }
 
+// Test copy with --transfer-start-rate
+func TestCopyTransferStartRate(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	oldTransferStartRate := fs.Config.TransferStartRate
+	fs.Config.TransferStartRate = 20
+	defer func() { fs.Config.TransferStartRate = oldTransferStartRate }()
+
+	var items []fstest.Item
+	for _, remote := range []string{"a", "b", "c", "d", "e"} {
+		items = append(items, r.WriteFile(remote, remote, t1))
+	}
+	r.Mkdir(ctx, r.Fremote)
+
+	accounting.GlobalStats().ResetCounters()
+	start := time.Now()
+	err := CopyDir(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+	// 5 transfers at 20/s with a burst of 1 take at least 200ms
+	assert.True(t, time.Since(start) >= 150*time.Millisecond)
+
+	fstest.CheckItems(t, r.Fremote, items...)
+	assert.Equal(t, int64(5), accounting.GlobalStats().GetTransfers())
+}
+
+// Test copy with --link-duplicates
+func TestCopyLinkDuplicates(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	if _, ok := r.Fremote.(fs.Linker); !ok {
+		t.Skip("Can't test as remote can't link")
+	}
+
+	oldLinkDuplicates := fs.Config.LinkDuplicates
+	fs.Config.LinkDuplicates = fs.LinkModeHardlink
+	defer func() { fs.Config.LinkDuplicates = oldLinkDuplicates }()
+
+	file1 := r.WriteFile("a", "same", t1)
+	file2 := r.WriteFile("sub/b", "same", t1)
+	file3 := r.WriteFile("c", "same", t2) // different modtime
+	file4 := r.WriteFile("d", "different", t1)
+	r.Mkdir(ctx, r.Fremote)
+
+	accounting.GlobalStats().ResetCounters()
+	err := CopyDir(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3, file4)
+	assert.Equal(t, int64(4), accounting.GlobalStats().GetTransfers())
+	stat := func(remote string) os.FileInfo {
+		fi, err := os.Stat(filepath.Join(r.Fremote.Root(), remote))
+		require.NoError(t, err)
+		return fi
+	}
+	assert.True(t, os.SameFile(stat("a"), stat("sub/b")))
+	assert.False(t, os.SameFile(stat("a"), stat("c")))
+	assert.False(t, os.SameFile(stat("a"), stat("d")))
+}
+
+// Test copy with --transfers-large
+func TestCopyTransfersLarge(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	oldTransfersLarge, oldSizeThreshold := fs.Config.TransfersLarge, fs.Config.SizeThreshold
+	defer func() {
+		fs.Config.TransfersLarge, fs.Config.SizeThreshold = oldTransfersLarge, oldSizeThreshold
+	}()
+	fs.Config.TransfersLarge = 1
+	fs.Config.SizeThreshold = 10
+
+	var items []fstest.Item
+	for i := 0; i < 5; i++ {
+		items = append(items, r.WriteFile(fmt.Sprintf("small%d", i), "small", t1))
+		items = append(items, r.WriteFile(fmt.Sprintf("sub/large%d", i), "large file contents", t1))
+	}
+	r.Mkdir(ctx, r.Fremote)
+
+	accounting.GlobalStats().ResetCounters()
+	err := CopyDir(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(items)), accounting.GlobalStats().GetTransfers())
+
+	fstest.CheckItems(t, r.Flocal, items...)
+	fstest.CheckItems(t, r.Fremote, items...)
+}
+
+// Test copy with --at-time from a source without versions
+func TestCopyAtTimeUnsupported(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	fs.Config.AtTime = fs.Time(t1)
+	defer func() { fs.Config.AtTime = fs.Time{} }()
+
+	file1 := r.WriteFile("sub dir/hello world", "hello world", t1)
+	r.Mkdir(ctx, r.Fremote)
+
+	accounting.GlobalStats().ResetCounters()
+	err := CopyDir(ctx, r.Fremote, r.Flocal, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "doesn't support --at-time")
+
+	err = MoveDir(ctx, r.Fremote, r.Flocal, false, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't use --at-time with move")
+
+	fstest.CheckItems(t, r.Flocal, file1)
+	fstest.CheckItems(t, r.Fremote)
+}
+
+// Test copy with --cas
+func TestCopyCAS(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	fs.Config.CAS = true
+	defer func() { fs.Config.CAS = false }()
+
+	sha1 := func(contents string) string {
+		sums, err := hash.StreamTypes(strings.NewReader(contents), hash.NewHashSet(hash.SHA1))
+		require.NoError(t, err)
+		return sums[hash.SHA1]
+	}
+	same, different := sha1("same"), sha1("different")
+	r.WriteFile("a", "same", t1)
+	r.WriteFile("sub/b", "same", t2)
+	r.WriteFile("c", "different", t1)
+	r.Mkdir(ctx, r.Fremote)
+
+	checkRemote := func() map[string]casEntry {
+		var remotes []string
+		require.NoError(t, operations.ListFn(ctx, r.Fremote, func(o fs.Object) {
+			remotes = append(remotes, o.Remote())
+		}))
+		sort.Strings(remotes)
+		want := []string{casPath(same), casPath(different), "manifest.json"}
+		sort.Strings(want)
+		assert.Equal(t, want, remotes)
+		data, err := ioutil.ReadFile(filepath.Join(r.Fremote.Root(), "manifest.json"))
+		require.NoError(t, err)
+		var manifest casManifest
+		require.NoError(t, json.Unmarshal(data, &manifest))
+		assert.Equal(t, "SHA-1", manifest.HashType)
+		return manifest.Files
+	}
+
+	accounting.GlobalStats().ResetCounters()
+	err := CopyDir(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+
+	files, bytes := accounting.GlobalStats().GetDeduplicated()
+	assert.Equal(t, int64(1), files)
+	assert.Equal(t, int64(4), bytes)
+	manifest := checkRemote()
+	assert.Equal(t, 3, len(manifest))
+	assert.Equal(t, same, manifest["a"].Hash)
+	assert.Equal(t, same, manifest["sub/b"].Hash)
+	assert.True(t, t2.Equal(manifest["sub/b"].ModTime))
+	assert.Equal(t, different, manifest["c"].Hash)
+	assert.Equal(t, int64(9), manifest["c"].Size)
+
+	// A new file with contents already stored isn't uploaded, the
+	// unchanged files are skipped and the manifest keeps them
+	r.WriteFile("d", "different", t1)
+	accounting.GlobalStats().ResetCounters()
+	err = CopyDir(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+
+	files, _ = accounting.GlobalStats().GetDeduplicated()
+	assert.Equal(t, int64(1), files)
+	manifest = checkRemote()
+	assert.Equal(t, 4, len(manifest))
+	assert.Equal(t, different, manifest["d"].Hash)
+
+	// Sync can't be used with --cas
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	assert.Error(t, err)
+}
+
+// Test sync with --illegal-chars strip
+func TestSyncIllegalCharsStrip(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	encodingEnv := fs.ConfigToEnv(r.Fremote.Name(), "encoding")
+	require.NoError(t, os.Setenv(encodingEnv, "Slash,Colon,Dot"))
+	fs.Config.IllegalChars = fs.IllegalCharsStrip
+	defer func() {
+		_ = os.Unsetenv(encodingEnv)
+		fs.Config.IllegalChars = fs.IllegalCharsEncode
+	}()
+
+	file1 := r.WriteFile("sub:dir/a:b", "file1 contents", t1)
+	r.Mkdir(ctx, r.Fremote)
+
+	accounting.GlobalStats().ResetCounters()
+	err := Sync(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+	file1Dst := file1
+	file1Dst.Path = "subdir/ab"
+	fstest.CheckItems(t, r.Fremote, file1Dst)
+	assert.Equal(t, int64(1), accounting.GlobalStats().GetTransfers())
+
+	// The stored name matches the source so nothing is done
+	accounting.GlobalStats().ResetCounters()
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1Dst)
+	assert.Equal(t, int64(0), accounting.GlobalStats().GetTransfers())
+	assert.Equal(t, int64(0), accounting.GlobalStats().Deletes(0))
+}
+
 // Now with --no-traverse
 func TestCopyNoTraverse(t *testing.T) {
 	r := fstest.NewRun(t)
@@ -114,6 +341,281 @@ func TestCopyCheckFirst(t *testing.T) {
 	fstest.CheckItems(t, r.Fremote, file1)
 }
 
+// Now with --check-free-inodes
+func TestCopyCheckFreeInodes(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	fs.Config.CheckFreeInodes = true
+	defer func() { fs.Config.CheckFreeInodes = false }()
+
+	file1 := r.WriteFile("sub dir/hello world", "hello world", t1)
+
+	err := CopyDir(context.Background(), r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+
+	fstest.CheckItems(t, r.Flocal, file1)
+	fstest.CheckItems(t, r.Fremote, file1)
+}
+
+// Test sync with --plan-out then --plan-in
+func TestSyncPlan(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	planFile, err := ioutil.TempFile("", "rclone-plan")
+	require.NoError(t, err)
+	require.NoError(t, planFile.Close())
+	planPath := planFile.Name()
+	defer func() { _ = os.Remove(planPath) }()
+
+	file1 := r.WriteFile("sub dir/hello world", "hello world", t1)
+	file2 := r.WriteBoth(ctx, "same", "same", t1)
+	file3 := r.WriteObject(ctx, "extra", "not in source", t2)
+	fstest.CheckItems(t, r.Flocal, file1, file2)
+	fstest.CheckItems(t, r.Fremote, file2, file3)
+
+	// Making the plan shouldn't change anything
+	accounting.GlobalStats().ResetCounters()
+	fs.Config.PlanOut = planPath
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	fs.Config.PlanOut = ""
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Flocal, file1, file2)
+	fstest.CheckItems(t, r.Fremote, file2, file3)
+
+	actions, err := readPlan(planPath, r.Fremote, r.Flocal)
+	require.NoError(t, err)
+	for i := range actions {
+		if actions[i].Action == planCopy {
+			require.NotNil(t, actions[i].SrcInfo)
+			assert.Equal(t, file1.Size, actions[i].SrcInfo.Size)
+			assert.Nil(t, actions[i].DstInfo)
+		} else {
+			assert.Nil(t, actions[i].SrcInfo)
+			require.NotNil(t, actions[i].DstInfo)
+			assert.Equal(t, file3.Size, actions[i].DstInfo.Size)
+		}
+		actions[i].SrcInfo, actions[i].DstInfo = nil, nil
+	}
+	assert.ElementsMatch(t, []planAction{
+		{Action: planCopy, Src: "sub dir/hello world", Dst: "sub dir/hello world", Reason: "new"},
+		{Action: planDelete, Dst: "extra", Reason: "not in source"},
+	}, actions)
+
+	// A plan for different remotes should be rejected
+	_, err = readPlan(planPath, r.Flocal, r.Fremote)
+	assert.Error(t, err)
+
+	// Running the plan should do the sync
+	fs.Config.PlanIn = planPath
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	fs.Config.PlanIn = ""
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Flocal, file1, file2)
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+}
+
+// Test a plan can't be made with --detect-moves as the moves would be
+// done while planning
+func TestSyncPlanDetectMoves(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	planFile, err := ioutil.TempFile("", "rclone-plan")
+	require.NoError(t, err)
+	require.NoError(t, planFile.Close())
+	planPath := planFile.Name()
+	defer func() { _ = os.Remove(planPath) }()
+
+	file1 := r.WriteFile("renamed", "potato", t1)
+	file2 := r.WriteObject(ctx, "original", "potato", t1)
+
+	fs.Config.PlanOut = planPath
+	fs.Config.DetectMoves = true
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	fs.Config.PlanOut = ""
+	fs.Config.DetectMoves = false
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't use --plan-out with --detect-moves")
+	fstest.CheckItems(t, r.Flocal, file1)
+	fstest.CheckItems(t, r.Fremote, file2)
+}
+
+// Test making a plan doesn't update the modification times of files
+// which are the same apart from them
+func TestSyncPlanNoModify(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	planFile, err := ioutil.TempFile("", "rclone-plan")
+	require.NoError(t, err)
+	require.NoError(t, planFile.Close())
+	planPath := planFile.Name()
+	defer func() { _ = os.Remove(planPath) }()
+
+	file1 := r.WriteFile("file1", "potato", t2)
+	file2 := r.WriteObject(ctx, "file1", "potato", t1)
+
+	fs.Config.PlanOut = planPath
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	fs.Config.PlanOut = ""
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Flocal, file1)
+	fstest.CheckItems(t, r.Fremote, file2)
+
+	// Whereas a sync does
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1)
+}
+
+// Test a plan is refused if the files in it changed since it was made
+func TestSyncPlanStale(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	planFile, err := ioutil.TempFile("", "rclone-plan")
+	require.NoError(t, err)
+	require.NoError(t, planFile.Close())
+	planPath := planFile.Name()
+	defer func() { _ = os.Remove(planPath) }()
+
+	r.WriteFile("file1", "hello world", t1)
+	file2 := r.WriteObject(ctx, "extra", "not in source", t2)
+
+	accounting.GlobalStats().ResetCounters()
+	fs.Config.PlanOut = planPath
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	fs.Config.PlanOut = ""
+	require.NoError(t, err)
+
+	// Change the source after the plan was made
+	file1 := r.WriteFile("file1", "hello world, again", t2)
+
+	// Running the plan should be refused and change nothing
+	fs.Config.PlanIn = planPath
+	defer func() { fs.Config.PlanIn = "" }()
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "plan is stale: 1 actions changed")
+	fstest.CheckItems(t, r.Fremote, file2)
+
+	// Unless forced
+	accounting.GlobalStats().ResetCounters()
+	fs.Config.PlanForce = true
+	defer func() { fs.Config.PlanForce = false }()
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1)
+}
+
+// changeListerFs is an Fs with a scripted change feed
+type changeListerFs struct {
+	fs.Fs
+	token   string   // token to return
+	changes []string // files to report as changed
+	asked   []string // tokens ListChanges was called with
+}
+
+// ListChanges returns the scripted changes
+func (f *changeListerFs) ListChanges(ctx context.Context, token string, fn func(string, fs.EntryType)) (string, error) {
+	f.asked = append(f.asked, token)
+	if token != "" {
+		for _, change := range f.changes {
+			fn(change, fs.EntryObject)
+		}
+	}
+	return f.token, nil
+}
+
+// Test sync with --changes-file only syncs the changes
+func TestSyncChangesFile(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	changesFile, err := ioutil.TempFile("", "rclone-changes")
+	require.NoError(t, err)
+	require.NoError(t, changesFile.Close())
+	changesPath := changesFile.Name()
+	require.NoError(t, os.Remove(changesPath))
+	defer func() { _ = os.Remove(changesPath) }()
+	fs.Config.ChangesFile = changesPath
+	defer func() { fs.Config.ChangesFile = "" }()
+
+	src := &changeListerFs{Fs: r.Flocal, token: "1"}
+
+	// The first sync is a full sync
+	file1 := r.WriteFile("one", "one", t1)
+	accounting.GlobalStats().ResetCounters()
+	err = Sync(ctx, r.Fremote, src, false)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1)
+	assert.Equal(t, []string{""}, src.asked)
+	token, err := readChangesToken(changesPath, fs.ConfigString(src))
+	require.NoError(t, err)
+	assert.Equal(t, "1", token)
+
+	// Only the changed files are synced after that
+	file2 := r.WriteFile("two", "two", t1)
+	file3 := r.WriteFile("three", "three", t1)
+	src.token, src.changes = "2", []string{"two"}
+	accounting.GlobalStats().ResetCounters()
+	err = Sync(ctx, r.Fremote, src, false)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+	assert.Equal(t, []string{"", "1"}, src.asked)
+
+	// Deleted files are deleted
+	o, err := r.Flocal.NewObject(ctx, "one")
+	require.NoError(t, err)
+	require.NoError(t, o.Remove(ctx))
+	src.token, src.changes = "3", []string{"one"}
+	accounting.GlobalStats().ResetCounters()
+	err = Sync(ctx, r.Fremote, src, false)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file2)
+	token, err = readChangesToken(changesPath, fs.ConfigString(src))
+	require.NoError(t, err)
+	assert.Equal(t, "3", token)
+
+	// A source without a change feed does a full sync
+	accounting.GlobalStats().ResetCounters()
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file2, file3)
+}
+
+// Test sync of a directory split into shards deletes the right files
+func TestSyncDirShards(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	oldThreshold := fs.Config.DirShardThreshold
+	fs.Config.DirShardThreshold = 2
+	defer func() { fs.Config.DirShardThreshold = oldThreshold }()
+
+	var want []fstest.Item
+	for i := 0; i < 5; i++ {
+		want = append(want, r.WriteFile(fmt.Sprintf("new%d", i), "new", t1))
+		want = append(want, r.WriteBoth(ctx, fmt.Sprintf("same%d", i), "same", t1))
+		r.WriteObject(ctx, fmt.Sprintf("old%d", i), "old", t1)
+	}
+
+	accounting.GlobalStats().ResetCounters()
+	err := Sync(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+
+	fstest.CheckItems(t, r.Flocal, want...)
+	fstest.CheckItems(t, r.Fremote, want...)
+}
+
 // Now with --no-traverse
 func TestSyncNoTraverse(t *testing.T) {
 	r := fstest.NewRun(t)
@@ -132,6 +634,31 @@ func TestSyncNoTraverse(t *testing.T) {
 	fstest.CheckItems(t, r.Fremote, file1)
 }
 
+// Test sync with --probe-dest looking up the source files on the
+// destination and not deleting
+func TestSyncProbeDest(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	fs.Config.ProbeDest = true
+	defer func() { fs.Config.ProbeDest = false }()
+
+	file1 := r.WriteFile("sub dir/hello world", "hello world", t1)
+	file2 := r.WriteBoth(ctx, "unchanged", "unchanged", t1)
+	file3 := r.WriteFile("changed", "new contents", t2)
+	r.WriteObject(ctx, "changed", "old", t1)
+	file4 := r.WriteObject(ctx, "extraneous", "not on the source", t1)
+
+	accounting.GlobalStats().ResetCounters()
+	err := Sync(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+
+	fstest.CheckItems(t, r.Flocal, file1, file2, file3)
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3, file4)
+	assert.Equal(t, int64(2), accounting.GlobalStats().GetTransfers())
+}
+
 // Test copy with depth
 func TestCopyWithDepth(t *testing.T) {
 	r := fstest.NewRun(t)
@@ -210,6 +737,63 @@ func TestCopyEmptyDirectories(t *testing.T) {
 	)
 }
 
+// mkdirFs records the order of the Mkdir calls
+type mkdirFs struct {
+	fs.Fs
+	mu      sync.Mutex
+	running int             // number of Mkdir calls running
+	max     int             // max number of Mkdir calls running at once
+	made    map[string]bool // directories made
+	early   []string        // directories started before their parent was made
+}
+
+// Mkdir records the call then makes the directory
+func (f *mkdirFs) Mkdir(ctx context.Context, dir string) error {
+	f.mu.Lock()
+	f.running++
+	if f.running > f.max {
+		f.max = f.running
+	}
+	if parent := path.Dir(dir); parent != "." && !f.made[parent] {
+		f.early = append(f.early, dir)
+	}
+	f.mu.Unlock()
+	time.Sleep(time.Millisecond)
+	err := f.Fs.Mkdir(ctx, dir)
+	f.mu.Lock()
+	f.running--
+	f.made[dir] = true
+	f.mu.Unlock()
+	return err
+}
+
+// Test copying empty directories with --mkdir-concurrency makes the
+// parents first
+func TestCopyEmptyDirectoriesConcurrency(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	oldConcurrency := fs.Config.MkdirConcurrency
+	fs.Config.MkdirConcurrency = 3
+	defer func() { fs.Config.MkdirConcurrency = oldConcurrency }()
+
+	entries := map[string]fs.DirEntry{}
+	var want []string
+	for _, top := range []string{"a", "b", "c", "d"} {
+		for _, dir := range []string{top, top + "/x", top + "/x/y", top + "/z"} {
+			entries[dir] = fs.NewDir(dir, t1)
+			want = append(want, dir)
+		}
+	}
+	f := &mkdirFs{Fs: r.Fremote, made: map[string]bool{}}
+	require.NoError(t, copyEmptyDirectories(ctx, f, entries))
+
+	assert.Equal(t, len(want), len(f.made))
+	assert.Equal(t, []string(nil), f.early)
+	assert.True(t, f.max <= 3, f.max)
+	fstest.CheckListingWithPrecision(t, r.Fremote, nil, want, fs.GetModifyWindow(r.Fremote))
+}
+
 // Test move empty directories
 func TestMoveEmptyDirectories(t *testing.T) {
 	r := fstest.NewRun(t)
@@ -236,6 +820,41 @@ func TestMoveEmptyDirectories(t *testing.T) {
 	)
 }
 
+// Test move with --move-delete-after
+func TestMoveDeleteAfter(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	fs.Config.MoveDeleteAfter = true
+	defer func() { fs.Config.MoveDeleteAfter = false }()
+
+	file1 := r.WriteFile("sub dir/hello world", "hello world", t1)
+	file2 := r.WriteBoth(ctx, "identical", "identical", t1)
+
+	err := moveDir(ctx, r.Fremote, r.Flocal, false, false)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Flocal)
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+
+	// The sources aren't deleted if the move had errors
+	file3 := r.WriteFile("file3", "not deleted", t1)
+	d, err := newSrcDeletes()
+	require.NoError(t, err)
+	d.add(file3.Path)
+	require.NoError(t, d.finish(ctx, r.Flocal, errors.New("transfer failed")))
+	fstest.CheckItems(t, r.Flocal, file3)
+	_, err = os.Stat(d.f.Name())
+	assert.True(t, os.IsNotExist(err))
+
+	// And are deleted if it didn't
+	d, err = newSrcDeletes()
+	require.NoError(t, err)
+	d.add(file3.Path)
+	require.NoError(t, d.finish(ctx, r.Flocal, nil))
+	fstest.CheckItems(t, r.Flocal)
+}
+
 // Test sync empty directories
 func TestSyncEmptyDirectories(t *testing.T) {
 	r := fstest.NewRun(t)
@@ -880,6 +1499,139 @@ func TestSyncDeleteBefore(t *testing.T) {
 	TestSyncAfterRemovingAFileAndAddingAFile(t)
 }
 
+// Sync test with --delete-before doing the deletes in parallel
+func TestSyncDeleteBeforeDeleters(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	oldDeleteMode, oldDeleters := fs.Config.DeleteMode, fs.Config.Deleters
+	fs.Config.DeleteMode = fs.DeleteModeBefore
+	fs.Config.Deleters = 4
+	defer func() {
+		fs.Config.DeleteMode, fs.Config.Deleters = oldDeleteMode, oldDeleters
+	}()
+
+	file1 := r.WriteFile("new", "new file", t1)
+	for _, remote := range []string{"a", "b/c", "b/d", "e/f", "g", "h"} {
+		r.WriteObject(ctx, remote, "delete me", t1)
+	}
+
+	accounting.GlobalStats().ResetCounters()
+	err := Sync(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+
+	fstest.CheckItems(t, r.Fremote, file1)
+	stats, err := accounting.GlobalStats().RemoteStats()
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), stats["deletes"])
+	assert.Equal(t, int64(1), stats["transfers"])
+}
+
+// Sync test with limited parallel deletes in deepest first order
+func TestSyncDeleteLimited(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	oldDeleters, oldTPS, oldDeepest := fs.Config.Deleters, fs.Config.DeleteTPSLimit, fs.Config.DeleteDeepestFirst
+	fs.Config.Deleters = 2
+	fs.Config.DeleteTPSLimit = 20
+	fs.Config.DeleteDeepestFirst = true
+	defer func() {
+		fs.Config.Deleters, fs.Config.DeleteTPSLimit, fs.Config.DeleteDeepestFirst = oldDeleters, oldTPS, oldDeepest
+	}()
+
+	file1 := r.WriteBoth(ctx, "keep", "keep", t1)
+	for _, remote := range []string{"a", "b/c", "b/d/e", "f/g", "h"} {
+		r.WriteObject(ctx, remote, "delete me", t1)
+	}
+
+	accounting.GlobalStats().ResetCounters()
+	start := time.Now()
+	err := Sync(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+	// 5 deletes at 20/s with a burst of 1 take at least 200ms
+	assert.True(t, time.Since(start) >= 150*time.Millisecond)
+
+	fstest.CheckItems(t, r.Fremote, file1)
+	stats, err := accounting.GlobalStats().RemoteStats()
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), stats["deletes"])
+	assert.True(t, stats["deletesPerSecond"].(float64) > 0)
+}
+
+// Sync test with --max-delete-fraction counting only the filtered files
+func TestSyncMaxDeleteFraction(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	oldFraction, oldForce, oldDeleteMode := fs.Config.MaxDeleteFraction, fs.Config.Force, fs.Config.DeleteMode
+	fs.Config.MaxDeleteFraction = 0.5
+	fs.Config.DeleteMode = fs.DeleteModeDuring
+	defer func() {
+		fs.Config.MaxDeleteFraction, fs.Config.Force, fs.Config.DeleteMode = oldFraction, oldForce, oldDeleteMode
+	}()
+
+	f, err := filter.NewFilter(nil)
+	require.NoError(t, err)
+	require.NoError(t, f.AddRule("- *.log"))
+	oldFilter := filter.Active
+	filter.Active = f
+	defer func() { filter.Active = oldFilter }()
+
+	file1 := r.WriteBoth(ctx, "keep", "keep", t1)
+	file2 := r.WriteObject(ctx, "a", "delete me", t1)
+	file3 := r.WriteObject(ctx, "b", "delete me", t1)
+	var logs []fstest.Item
+	for _, remote := range []string{"1.log", "2.log", "3.log"} {
+		logs = append(logs, r.WriteObject(ctx, remote, "excluded", t1))
+	}
+
+	// 2 of the 3 files which aren't excluded is too many
+	accounting.GlobalStats().ResetCounters()
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	require.Error(t, err)
+	assert.True(t, fserrors.IsFatalError(err))
+	assert.Contains(t, err.Error(), "not deleting 2 of the 3 files")
+	fstest.CheckItems(t, r.Fremote, append(logs, file1, file2, file3)...)
+
+	// Unless --force is set
+	fs.Config.Force = true
+	accounting.GlobalStats().ResetCounters()
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, append(logs, file1)...)
+
+	// A fraction below the limit is deleted without --force
+	fs.Config.Force = false
+	file4 := r.WriteBoth(ctx, "keep2", "keep", t1)
+	r.WriteObject(ctx, "c", "delete me", t1)
+	accounting.GlobalStats().ResetCounters()
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, append(logs, file1, file4)...)
+}
+
+func TestFilesToDeleteDeepestFirst(t *testing.T) {
+	s := &syncCopyMove{dstFiles: map[string]fs.Object{}, srcFiles: map[string]fs.Object{}}
+	for _, remote := range []string{"a", "b/c", "b/d/e", "f/g", "h", "i/j"} {
+		s.dstFiles[remote] = mockobject.Object(remote)
+	}
+	s.srcFiles["f/g"] = mockobject.Object("f/g")
+
+	oldDeepest := fs.Config.DeleteDeepestFirst
+	fs.Config.DeleteDeepestFirst = true
+	defer func() { fs.Config.DeleteDeepestFirst = oldDeepest }()
+
+	var got []string
+	for _, o := range s.filesToDelete(true) {
+		got = append(got, o.Remote())
+	}
+	assert.Equal(t, []string{"b/d/e", "b/c", "i/j", "a", "h"}, got)
+}
+
 // Copy test delete before - shouldn't delete anything
 func TestCopyDeleteBefore(t *testing.T) {
 	r := fstest.NewRun(t)
@@ -931,6 +1683,38 @@ func TestSyncWithExclude(t *testing.T) {
 	fstest.CheckItems(t, r.Flocal, file2, file1, file3)
 }
 
+// Test with --max-size-skip reporting the files bigger than --max-size
+func TestSyncWithMaxSizeSkip(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	file1 := r.WriteFile("potato2", "------------------------------------------------------------", t1) // 60 bytes
+	file2 := r.WriteBoth(context.Background(), "empty space", "-", t2)
+	file3 := r.WriteFile("enormous", "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", t1) // 100 bytes
+	file4 := r.WriteObject(context.Background(), "potato2", "old", t2)
+	file5 := r.WriteFile("small", "small", t1)
+	fstest.CheckItems(t, r.Fremote, file2, file4)
+	fstest.CheckItems(t, r.Flocal, file1, file2, file3, file5)
+
+	filter.Active.Opt.MaxSize = 40
+	filter.Active.Opt.MaxSizeSkip = true
+	defer func() {
+		filter.Active.Opt.MaxSize = -1
+		filter.Active.Opt.MaxSizeSkip = false
+	}()
+
+	accounting.GlobalStats().ResetCounters()
+	err := Sync(context.Background(), r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file2, file4, file5)
+
+	// the skipped files are counted but not transferred
+	files, bytes := accounting.GlobalStats().GetSkippedTooLarge()
+	assert.Equal(t, int64(2), files)
+	assert.Equal(t, int64(160), bytes)
+	assert.Equal(t, int64(1), accounting.GlobalStats().GetTransfers())
+	assert.Equal(t, int64(5), accounting.GlobalStats().GetBytes())
+}
+
 // Test with exclude and delete excluded
 func TestSyncWithExcludeAndDeleteExcluded(t *testing.T) {
 	r := fstest.NewRun(t)
@@ -1009,6 +1793,33 @@ func TestSyncWithUpdateOlder(t *testing.T) {
 }
 
 // Test with a max transfer duration
+// Test sync refuses --on-oversize split as the parts would be deleted
+func TestSyncOversizeSplit(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	oldOnOversize, oldMaxObjectSize := fs.Config.OnOversize, fs.Config.MaxObjectSize
+	defer func() {
+		fs.Config.OnOversize, fs.Config.MaxObjectSize = oldOnOversize, oldMaxObjectSize
+	}()
+	fs.Config.OnOversize = fs.OversizeSplit
+	fs.Config.MaxObjectSize = 6
+
+	file1 := r.WriteFile("file1", "0123456789abcdef", t1)
+	fstest.CheckItems(t, r.Flocal, file1)
+
+	err := Sync(ctx, r.Fremote, r.Flocal, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't use --on-oversize split with sync")
+	fstest.CheckItems(t, r.Fremote)
+
+	// Copy splits the file once and leaves it alone after
+	require.NoError(t, CopyDir(ctx, r.Fremote, r.Flocal, false))
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, CopyDir(ctx, r.Fremote, r.Flocal, false))
+	assert.Equal(t, int64(0), accounting.GlobalStats().GetTransfers())
+}
+
 func TestSyncWithMaxDuration(t *testing.T) {
 	if *fstest.RemoteName != "" {
 		t.Skip("Skipping test on non local remote")
@@ -1040,8 +1851,11 @@ func TestSyncWithMaxDuration(t *testing.T) {
 	startTime := time.Now()
 	err := Sync(context.Background(), r.Fremote, r.Flocal, false)
 	require.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+	// A transfer waiting for the --bwlimit is stopped at the deadline
 	err = accounting.GlobalStats().GetLastError()
-	require.NoError(t, err)
+	if err != nil {
+		require.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+	}
 
 	elapsed := time.Since(startTime)
 	maxTransferTime := (time.Duration(len(testFiles)) * 60 * time.Second) / time.Duration(bytesPerSecond)
@@ -1091,6 +1905,49 @@ func TestSyncWithTrackRenames(t *testing.T) {
 	}
 }
 
+// Test a sync with --detect-moves
+func TestSyncWithDetectMoves(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	fs.Config.DetectMoves = true
+	defer func() {
+		fs.Config.DetectMoves = false
+	}()
+
+	haveHash := r.Fremote.Hashes().Overlap(r.Flocal.Hashes()).GetOne() != hash.None
+	canDetectMoves := haveHash && r.Fremote.Features().Move != nil
+	t.Logf("Can detect moves: %v", canDetectMoves)
+
+	f1 := r.WriteBoth(ctx, "potato", "Potato Content", t1)
+	f2 := r.WriteBoth(ctx, "yam", "Yam Content", t2)
+	fstest.CheckItems(t, r.Fremote, f1, f2)
+
+	// Now move locally
+	f2 = r.RenameFile(f2, "yaml")
+
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, Sync(ctx, r.Fremote, r.Flocal, false))
+	fstest.CheckItems(t, r.Fremote, f1, f2)
+
+	moves, bytes := accounting.GlobalStats().ServerSideMoves()
+	if canDetectMoves {
+		assert.Equal(t, int64(1), moves)
+		assert.Equal(t, f2.Size, bytes)
+		assert.Equal(t, int64(0), accounting.GlobalStats().GetTransfers())
+	} else {
+		assert.Equal(t, int64(0), moves)
+	}
+
+	// Nothing happens with copy
+	f1 = r.RenameFile(f1, "potato2")
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, CopyDir(ctx, r.Fremote, r.Flocal, false))
+	moves, _ = accounting.GlobalStats().ServerSideMoves()
+	assert.Equal(t, int64(0), moves)
+}
+
 func TestParseRenamesStrategyModtime(t *testing.T) {
 	for _, test := range []struct {
 		in      string
@@ -1850,3 +2707,120 @@ func TestAbort(t *testing.T) {
 	fserrors.Count(expectedErr)
 	assert.Equal(t, expectedErr, err)
 }
+
+// Test sync with --watch
+func TestSyncWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	if _, ok := r.Flocal.(fs.ChangeNotifier); !ok {
+		t.Skip("local can't be watched on this OS")
+	}
+
+	file1 := r.WriteFile("file1", "initial", t1)
+	fstest.CheckItems(t, r.Flocal, file1)
+	accounting.GlobalStats().ResetCounters()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- Watch(ctx, r.Fremote, r.Flocal, fs.DeleteModeDuring, false, 10*time.Millisecond)
+	}()
+	defer func() {
+		cancel()
+		assert.NoError(t, <-errs)
+	}()
+
+	// Wait for remote to exist or not on the destination
+	waitFor := func(remote string, exists bool) {
+		var err error
+		for i := 0; i < 500; i++ {
+			_, err = r.Fremote.NewObject(ctx, remote)
+			if (err == nil) == exists {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("timed out waiting for %q exists=%v: %v", remote, exists, err)
+	}
+	waitFor("file1", true)
+
+	// New files and files in new directories
+	r.WriteFile("file2", "new file", t2)
+	waitFor("file2", true)
+	require.NoError(t, os.MkdirAll(filepath.Join(r.LocalName, "dir", "sub"), 0777))
+	r.WriteFile("dir/sub/file3", "in a new dir", t2)
+	waitFor("dir/sub/file3", true)
+
+	// Renames and deletes
+	require.NoError(t, os.Rename(filepath.Join(r.LocalName, "file2"), filepath.Join(r.LocalName, "file2-renamed")))
+	waitFor("file2-renamed", true)
+	waitFor("file2", false)
+	require.NoError(t, os.RemoveAll(filepath.Join(r.LocalName, "dir")))
+	waitFor("dir/sub/file3", false)
+	require.NoError(t, os.Remove(filepath.Join(r.LocalName, "file1")))
+	waitFor("file1", false)
+}
+
+// Test the --resume-filter bloom filter
+func TestResumeFilterBloom(t *testing.T) {
+	r := &resumeFilter{}
+	require.NoError(t, r.init(10000, 0.01))
+	for i := 0; i < 10000; i++ {
+		r.add(fmt.Sprintf("dir/file%d", i))
+	}
+	for i := 0; i < 10000; i++ {
+		assert.True(t, r.contains(fmt.Sprintf("dir/file%d", i)))
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if r.contains(fmt.Sprintf("other/file%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.True(t, falsePositives < 200, falsePositives)
+
+	assert.Error(t, r.init(0, 0.01))
+	assert.Error(t, r.init(100, 0))
+	assert.Error(t, r.init(100, 1))
+}
+
+// Test sync resuming with --resume-filter
+func TestSyncResumeFilter(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	dir, err := ioutil.TempDir("", "rclone-resume")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	oldResumeFilter := fs.Config.ResumeFilter
+	fs.Config.ResumeFilter = filepath.Join(dir, "resume")
+	defer func() { fs.Config.ResumeFilter = oldResumeFilter }()
+
+	file1 := r.WriteFile("file1", "new", t2)
+	r.WriteFile("file2", "changed since", t2)
+	file2 := r.WriteObject(ctx, "file2", "old", t1)
+	r.WriteObject(ctx, "file1", "old", t1)
+
+	// An interrupted sync which completed file2
+	resume, err := newResumeFilter(r.Fremote, r.Flocal)
+	require.NoError(t, err)
+	resume.add("file2")
+	require.NoError(t, resume.finish(errors.New("interrupted")))
+
+	// A filter for a different sync can't be used
+	_, err = newResumeFilter(r.Flocal, r.Fremote)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "it was made for a sync of")
+
+	// Resuming skips file2
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+
+	// The filter is removed when the sync completes
+	_, err = os.Stat(fs.Config.ResumeFilter)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,240 @@
+package sync
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// watcher transfers the changes notified by the source to the
+// destination
+type watcher struct {
+	fdst             fs.Fs
+	fsrc             fs.Fs
+	deleteMode       fs.DeleteMode
+	copyEmptySrcDirs bool
+	backupDir        fs.Fs
+
+	mu      sync.Mutex
+	changes map[string]fs.EntryType // changes not transferred yet
+	wake    chan struct{}           // signalled when changes are added
+}
+
+// notify is called by the source with each change
+func (w *watcher) notify(remote string, entryType fs.EntryType) {
+	w.mu.Lock()
+	w.changes[remote] = entryType
+	w.mu.Unlock()
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the changes not transferred yet
+func (w *watcher) take() map[string]fs.EntryType {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	changes := w.changes
+	w.changes = make(map[string]fs.EntryType)
+	return changes
+}
+
+// transfer makes the destination match the source for changes,
+// returning the most serious error.
+//
+// The files are transferred first using --transfers at once then the
+// directories in order so directories are made before the
+// directories in them and removed once their files have gone.
+func (w *watcher) transfer(ctx context.Context, changes map[string]fs.EntryType) error {
+	var (
+		wg      sync.WaitGroup
+		errMu   sync.Mutex
+		lastErr error
+		dirs    []string
+	)
+	setErr := func(err error) {
+		errMu.Lock()
+		if err != nil && (lastErr == nil || fserrors.IsFatalError(err)) {
+			lastErr = err
+		}
+		errMu.Unlock()
+	}
+	in := make(chan string)
+	wg.Add(fs.Config.Transfers)
+	for i := 0; i < fs.Config.Transfers; i++ {
+		go func() {
+			defer wg.Done()
+			for remote := range in {
+				setErr(w.transferFile(ctx, remote))
+			}
+		}()
+	}
+	for remote, entryType := range changes {
+		if entryType == fs.EntryDirectory {
+			dirs = append(dirs, remote)
+		} else {
+			in <- remote
+		}
+	}
+	close(in)
+	wg.Wait()
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		setErr(w.transferDir(ctx, dir))
+	}
+	return lastErr
+}
+
+// transferFile copies remote if it has changed on the source or
+// deletes it from the destination if it has gone from the source
+func (w *watcher) transferFile(ctx context.Context, remote string) error {
+	dst, err := w.fdst.NewObject(ctx, remote)
+	if err == fs.ErrorObjectNotFound {
+		dst = nil
+	} else if err != nil {
+		return err
+	}
+	src, err := w.fsrc.NewObject(ctx, remote)
+	if err == fs.ErrorObjectNotFound {
+		if w.deleteMode == fs.DeleteModeOff || dst == nil || !filter.Active.IncludeObject(ctx, dst) {
+			return nil
+		}
+		return operations.DeleteFileWithBackupDir(ctx, dst, w.backupDir)
+	} else if err != nil {
+		// Changes to directories are notified separately
+		if err == fs.ErrorNotAFile {
+			return nil
+		}
+		return err
+	}
+	if !filter.Active.IncludeObject(ctx, src) || !operations.NeedTransfer(ctx, dst, src) {
+		return nil
+	}
+	if dst != nil && w.backupDir != nil {
+		err = operations.MoveBackupDir(ctx, w.backupDir, dst)
+		if err != nil {
+			return errors.Wrap(err, "failed to move to backup dir")
+		}
+		dst = nil
+	}
+	_, err = operations.Copy(ctx, w.fdst, dst, remote, src)
+	return err
+}
+
+// transferDir makes dir on the destination if it is new on the
+// source or deletes it from the destination if it has gone from the
+// source.  The files in it are notified separately.
+//
+// A change to the root means the changes weren't tracked so the
+// whole source is synced again.
+func (w *watcher) transferDir(ctx context.Context, dir string) error {
+	if dir == "" {
+		return runSyncCopyMove(ctx, w.fdst, w.fsrc, w.deleteMode, false, false, w.copyEmptySrcDirs)
+	}
+	_, err := w.fsrc.List(ctx, dir)
+	if err == nil {
+		if !w.copyEmptySrcDirs {
+			return nil
+		}
+		return operations.Mkdir(ctx, w.fdst, dir)
+	} else if err != fs.ErrorDirNotFound {
+		return err
+	}
+	if w.deleteMode == fs.DeleteModeOff {
+		return nil
+	}
+	// This will have gone already if its parent was removed
+	_, err = w.fdst.List(ctx, dir)
+	if err == fs.ErrorDirNotFound {
+		return nil
+	}
+	err = walk.ListR(ctx, w.fdst, dir, false, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		var err error
+		entries.ForObject(func(dst fs.Object) {
+			if err == nil {
+				err = operations.DeleteFileWithBackupDir(ctx, dst, w.backupDir)
+			}
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return operations.Rmdirs(ctx, w.fdst, dir, false)
+}
+
+// Watch syncs fsrc to fdst, or copies it if deleteMode is off, then
+// watches fsrc for changes and transfers the changed files to fdst as
+// they happen until ctx is cancelled.
+//
+// The changes are gathered for delay before being transferred so a
+// burst of changes to a file only transfers it once.
+func Watch(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.DeleteMode, copyEmptySrcDirs bool, delay time.Duration) (err error) {
+	doChangeNotify := fsrc.Features().ChangeNotify
+	if do, ok := fsrc.(fs.ChangeNotifier); ok && doChangeNotify == nil && fsrc.Features().IsLocal {
+		// The local backend only advertises ChangeNotify with
+		// --local-watch-changes as watching is expensive
+		doChangeNotify = do.ChangeNotify
+	}
+	if doChangeNotify == nil {
+		return fserrors.FatalError(errors.Errorf("%v can't be watched for changes", fsrc))
+	}
+	if delay <= 0 {
+		return fserrors.FatalError(errors.New("the delay to gather changes for must be more than 0"))
+	}
+	if operations.Overlapping(fdst, fsrc) {
+		return fserrors.FatalError(fs.ErrorOverlapping)
+	}
+	w := &watcher{
+		fdst:             fdst,
+		fsrc:             fsrc,
+		deleteMode:       deleteMode,
+		copyEmptySrcDirs: copyEmptySrcDirs,
+		changes:          make(map[string]fs.EntryType),
+		wake:             make(chan struct{}, 1),
+	}
+	if fs.Config.BackupDir != "" || fs.Config.Suffix != "" {
+		w.backupDir, err = operations.BackupDir(fdst, fsrc, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	// Start watching before the sync so no changes are missed
+	pollInterval := make(chan time.Duration)
+	defer close(pollInterval)
+	doChangeNotify(ctx, w.notify, pollInterval)
+	pollInterval <- delay
+
+	err = runSyncCopyMove(ctx, fdst, fsrc, deleteMode, false, false, copyEmptySrcDirs)
+	if fserrors.IsFatalError(err) {
+		return err
+	} else if err != nil {
+		fs.Errorf(fsrc, "Carrying on watching for changes after failed sync: %v", err)
+	}
+	fs.Logf(fsrc, "Watching for changes")
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-w.wake:
+		}
+		changes := w.take()
+		fs.Debugf(fsrc, "Transferring %d changes", len(changes))
+		err = w.transfer(ctx, changes)
+		if fserrors.IsFatalError(err) {
+			return err
+		} else if err != nil {
+			fs.Errorf(fsrc, "Carrying on watching for changes after failed transfer: %v", err)
+		}
+	}
+}
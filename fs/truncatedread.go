@@ -0,0 +1,50 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TruncatedRead describes what to do when a source file is truncated
+// while it is being read
+type TruncatedRead byte
+
+// TruncatedRead constants
+const (
+	TruncatedReadError TruncatedRead = iota
+	TruncatedReadRetry
+	TruncatedReadSkip
+	TruncatedReadDefault = TruncatedReadError
+)
+
+var truncatedReadToString = []string{
+	TruncatedReadError: "error",
+	TruncatedReadRetry: "retry",
+	TruncatedReadSkip:  "skip",
+}
+
+// String turns a TruncatedRead into a string
+func (m TruncatedRead) String() string {
+	if m >= TruncatedRead(len(truncatedReadToString)) {
+		return fmt.Sprintf("TruncatedRead(%d)", m)
+	}
+	return truncatedReadToString[m]
+}
+
+// Set a TruncatedRead
+func (m *TruncatedRead) Set(s string) error {
+	for n, name := range truncatedReadToString {
+		if s != "" && name == strings.ToLower(s) {
+			*m = TruncatedRead(n)
+			return nil
+		}
+	}
+	return errors.Errorf("Unknown truncated read mode %q", s)
+}
+
+// Type of the value
+func (m *TruncatedRead) Type() string {
+	return "string"
+}
@@ -0,0 +1,22 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// Check it satisfies the interface
+var _ pflag.Value = (*TruncatedRead)(nil)
+
+func TestTruncatedReadSet(t *testing.T) {
+	var m TruncatedRead
+	assert.NoError(t, m.Set("SKIP"))
+	assert.Equal(t, TruncatedReadSkip, m)
+	assert.Equal(t, "skip", m.String())
+	assert.NoError(t, m.Set("retry"))
+	assert.Equal(t, TruncatedReadRetry, m)
+	assert.Error(t, m.Set("potato"))
+	assert.Equal(t, "TruncatedRead(17)", TruncatedRead(17).String())
+}
@@ -0,0 +1,50 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// VerifyHashMismatch describes the extra check to make when the
+// source and destination are the same size but their hashes differ
+type VerifyHashMismatch byte
+
+// VerifyHashMismatch constants
+const (
+	VerifyHashMismatchOff VerifyHashMismatch = iota
+	VerifyHashMismatchRehash
+	VerifyHashMismatchSecondHash
+	VerifyHashMismatchDefault = VerifyHashMismatchOff
+)
+
+var verifyHashMismatchToString = []string{
+	VerifyHashMismatchOff:        "off",
+	VerifyHashMismatchRehash:     "rehash",
+	VerifyHashMismatchSecondHash: "second-hash",
+}
+
+// String turns a VerifyHashMismatch into a string
+func (m VerifyHashMismatch) String() string {
+	if m >= VerifyHashMismatch(len(verifyHashMismatchToString)) {
+		return fmt.Sprintf("VerifyHashMismatch(%d)", m)
+	}
+	return verifyHashMismatchToString[m]
+}
+
+// Set a VerifyHashMismatch
+func (m *VerifyHashMismatch) Set(s string) error {
+	for n, name := range verifyHashMismatchToString {
+		if s != "" && name == strings.ToLower(s) {
+			*m = VerifyHashMismatch(n)
+			return nil
+		}
+	}
+	return errors.Errorf("Unknown verify hash mismatch mode %q", s)
+}
+
+// Type of the value
+func (m *VerifyHashMismatch) Type() string {
+	return "string"
+}
@@ -0,0 +1,22 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// Check it satisfies the interface
+var _ pflag.Value = (*VerifyHashMismatch)(nil)
+
+func TestVerifyHashMismatchSet(t *testing.T) {
+	var m VerifyHashMismatch
+	assert.NoError(t, m.Set("REHASH"))
+	assert.Equal(t, VerifyHashMismatchRehash, m)
+	assert.Equal(t, "rehash", m.String())
+	assert.NoError(t, m.Set("second-hash"))
+	assert.Equal(t, VerifyHashMismatchSecondHash, m)
+	assert.Error(t, m.Set("potato"))
+	assert.Equal(t, "VerifyHashMismatch(17)", VerifyHashMismatch(17).String())
+}
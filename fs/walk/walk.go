@@ -13,6 +13,7 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/dirtree"
 	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/fshttp"
 	"github.com/rclone/rclone/fs/list"
 )
 
@@ -283,7 +284,7 @@ func listR(ctx context.Context, f fs.Fs, path string, includeAll bool, listType
 		dm = newDirMap(path)
 	}
 	var mu sync.Mutex
-	err := doListR(ctx, path, func(entries fs.DirEntries) (err error) {
+	err := doListR(fshttp.WithBind(fshttp.WithListing(ctx, f.Name()), f.Name()), path, func(entries fs.DirEntries) (err error) {
 		if synthesizeDirs {
 			err = dm.addEntries(entries)
 			if err != nil {
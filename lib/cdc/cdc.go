@@ -0,0 +1,155 @@
+// Package cdc splits streams into content defined chunks using the
+// FastCDC algorithm.
+//
+// The chunk boundaries depend only on the content and the Params so
+// the same data always splits the same way, wherever it is in a file.
+package cdc
+
+import (
+	"io"
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+// Params are the chunk size parameters
+type Params struct {
+	MinSize int // no chunk is smaller than this except the last
+	AvgSize int // the normal chunk size - must be a power of 2
+	MaxSize int // no chunk is bigger than this
+}
+
+// DefaultParams are the chunk size parameters used if none are set
+var DefaultParams = Params{
+	MinSize: 256 * 1024,
+	AvgSize: 1024 * 1024,
+	MaxSize: 4 * 1024 * 1024,
+}
+
+// Check the params are valid
+func (p Params) Check() error {
+	if p.AvgSize <= 0 || p.AvgSize&(p.AvgSize-1) != 0 {
+		return errors.Errorf("average chunk size %d must be a power of 2", p.AvgSize)
+	}
+	if p.MinSize <= 0 || p.MinSize > p.AvgSize {
+		return errors.Errorf("minimum chunk size %d must be between 1 and the average chunk size %d", p.MinSize, p.AvgSize)
+	}
+	if p.MaxSize < p.AvgSize {
+		return errors.Errorf("maximum chunk size %d must be at least the average chunk size %d", p.MaxSize, p.AvgSize)
+	}
+	if bits.TrailingZeros(uint(p.AvgSize)) < 3 {
+		return errors.Errorf("average chunk size %d must be at least 8", p.AvgSize)
+	}
+	return nil
+}
+
+// gear is the table of random values used by the rolling hash.
+//
+// It is made with splitmix64 from a fixed seed so it is the same
+// everywhere - changing it changes all the chunk boundaries.
+var gear [256]uint64
+
+func init() {
+	x := uint64(0x5eedcdc000000001)
+	for i := range gear {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gear[i] = z ^ (z >> 31)
+	}
+}
+
+// mask returns a mask of the top n bits of the hash.
+//
+// The rolling hash shifts left so the top bits depend on the most
+// bytes.
+func mask(n int) uint64 {
+	return ^uint64(0) << (64 - uint(n))
+}
+
+// Chunker splits a stream into chunks
+type Chunker struct {
+	in    io.Reader
+	p     Params
+	maskS uint64 // harder to match mask used before AvgSize
+	maskL uint64 // easier to match mask used after AvgSize
+	buf   []byte // data read but not yet returned
+	used  int    // bytes of buf returned by the last Next
+	off   int64  // offset in the stream of buf[0]
+	eof   bool   // set if in is exhausted
+}
+
+// Chunk is one chunk of the stream
+type Chunk struct {
+	Offset int64  // offset of the chunk in the stream
+	Data   []byte // the chunk - only valid until the next call of Next
+}
+
+// New makes a Chunker which reads in and splits it into chunks
+// according to p.
+func New(in io.Reader, p Params) (*Chunker, error) {
+	if err := p.Check(); err != nil {
+		return nil, err
+	}
+	avgBits := bits.TrailingZeros(uint(p.AvgSize))
+	return &Chunker{
+		in:    in,
+		p:     p,
+		maskS: mask(avgBits + 2),
+		maskL: mask(avgBits - 2),
+		buf:   make([]byte, 0, p.MaxSize),
+	}, nil
+}
+
+// cut returns the length of the chunk at the start of data
+func (c *Chunker) cut(data []byte) int {
+	n := len(data)
+	if n <= c.p.MinSize {
+		return n
+	}
+	if n > c.p.MaxSize {
+		n = c.p.MaxSize
+	}
+	normal := c.p.AvgSize
+	if normal > n {
+		normal = n
+	}
+	var hash uint64
+	i := c.p.MinSize
+	for ; i < normal; i++ {
+		hash = (hash << 1) + gear[data[i]]
+		if hash&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		hash = (hash << 1) + gear[data[i]]
+		if hash&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// Next returns the next chunk or io.EOF when there are no more
+func (c *Chunker) Next() (chunk Chunk, err error) {
+	// Discard the last chunk and top up the buffer
+	c.off += int64(c.used)
+	c.buf = c.buf[:copy(c.buf, c.buf[c.used:])]
+	c.used = 0
+	if !c.eof {
+		n, err := io.ReadFull(c.in, c.buf[len(c.buf):cap(c.buf)])
+		c.buf = c.buf[:len(c.buf)+n]
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			c.eof = true
+		} else if err != nil {
+			return chunk, err
+		}
+	}
+	if len(c.buf) == 0 {
+		return chunk, io.EOF
+	}
+	c.used = c.cut(c.buf)
+	return Chunk{Offset: c.off, Data: c.buf[:c.used]}, nil
+}
@@ -0,0 +1,104 @@
+package cdc
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testParams = Params{
+	MinSize: 1024,
+	AvgSize: 4096,
+	MaxSize: 16384,
+}
+
+// testData returns n bytes of reproducible random data
+func testData(n int) []byte {
+	data := make([]byte, n)
+	_, _ = rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+// chunks splits data into chunks returning copies of them
+func chunks(t *testing.T, data []byte, p Params) (out [][]byte) {
+	c, err := New(bytes.NewReader(data), p)
+	require.NoError(t, err)
+	var offset int64
+	for {
+		chunk, err := c.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		assert.Equal(t, offset, chunk.Offset)
+		offset += int64(len(chunk.Data))
+		out = append(out, append([]byte(nil), chunk.Data...))
+	}
+	assert.Equal(t, int64(len(data)), offset)
+	return out
+}
+
+func TestParamsCheck(t *testing.T) {
+	assert.NoError(t, DefaultParams.Check())
+	assert.NoError(t, testParams.Check())
+	for _, p := range []Params{
+		{MinSize: 1024, AvgSize: 3000, MaxSize: 16384},
+		{MinSize: 0, AvgSize: 4096, MaxSize: 16384},
+		{MinSize: 8192, AvgSize: 4096, MaxSize: 16384},
+		{MinSize: 1024, AvgSize: 4096, MaxSize: 2048},
+		{MinSize: 1, AvgSize: 4, MaxSize: 16},
+	} {
+		assert.Error(t, p.Check(), p)
+	}
+}
+
+func TestChunker(t *testing.T) {
+	data := testData(1024 * 1024)
+	got := chunks(t, data, testParams)
+	assert.Equal(t, data, bytes.Join(got, nil))
+	for i, chunk := range got {
+		assert.True(t, len(chunk) <= testParams.MaxSize)
+		if i != len(got)-1 {
+			assert.True(t, len(chunk) >= testParams.MinSize)
+		}
+	}
+	// average should be roughly right
+	avg := len(data) / len(got)
+	assert.True(t, avg > testParams.AvgSize/2 && avg < testParams.AvgSize*2, avg)
+
+	// The chunking must never change as that would change all the
+	// fingerprints
+	var sizes []int
+	for _, chunk := range got[:8] {
+		sizes = append(sizes, len(chunk))
+	}
+	assert.Equal(t, []int{1806, 5057, 8131, 1342, 4443, 4790, 4728, 4996}, sizes)
+}
+
+func TestChunkerShift(t *testing.T) {
+	data := testData(256 * 1024)
+	before := chunks(t, data, testParams)
+	after := chunks(t, append([]byte("inserted at the start"), data...), testParams)
+
+	// Only the first few chunks should change
+	seen := map[string]bool{}
+	for _, chunk := range before {
+		seen[string(chunk)] = true
+	}
+	same := 0
+	for _, chunk := range after {
+		if seen[string(chunk)] {
+			same++
+		}
+	}
+	assert.True(t, same >= len(before)-2, "%d/%d chunks the same", same, len(before))
+}
+
+func TestChunkerEmpty(t *testing.T) {
+	assert.Equal(t, 0, len(chunks(t, nil, testParams)))
+	assert.Equal(t, [][]byte{[]byte("small")}, chunks(t, []byte("small"), testParams))
+}
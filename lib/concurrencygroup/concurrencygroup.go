@@ -0,0 +1,162 @@
+// Package concurrencygroup limits the number of operations, such as
+// transfers and deletes, which run at once on the remotes tagged with
+// each concurrency group.
+//
+// Remotes are tagged by setting concurrency_group in their config to
+// a comma separated list of groups, and the limits are set with
+// --concurrency-group name=N. This lets remotes which share a rate
+// limited upstream account keep under its limit between them.
+package concurrencygroup
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"golang.org/x/sync/semaphore"
+)
+
+// ConfigKey is the key in the config of a remote for the groups it
+// is in
+const ConfigKey = "concurrency_group"
+
+var (
+	mu   sync.Mutex                     // protects the vars below
+	sems map[string]*semaphore.Weighted // the semaphore for each limited group
+	tags map[string][]string            // the limited groups of each remote name
+)
+
+// SetLimits sets the maximum number of operations which can run at
+// once on the remotes in each group. Groups with a limit of 0 or less
+// are unlimited.
+//
+// This should be called before any operations are started.
+func SetLimits(groupLimits map[string]int) {
+	mu.Lock()
+	defer mu.Unlock()
+	sems = make(map[string]*semaphore.Weighted, len(groupLimits))
+	for group, limit := range groupLimits {
+		if limit > 0 {
+			sems[group] = semaphore.NewWeighted(int64(limit))
+		}
+	}
+	tags = map[string][]string{}
+}
+
+// remoteGroups returns the limited groups the remote called name is
+// tagged with.
+//
+// The caller must hold mu.
+func remoteGroups(name string) []string {
+	groups, ok := tags[name]
+	if ok {
+		return groups
+	}
+	value, _ := fs.ConfigMap(nil, name).Get(ConfigKey)
+	for _, group := range strings.Split(value, ",") {
+		group = strings.TrimSpace(group)
+		if _, limited := sems[group]; limited {
+			groups = append(groups, group)
+		}
+	}
+	tags[name] = groups
+	return groups
+}
+
+// Groups returns the sorted limited groups that any of remotes are
+// tagged with, each only once.
+func Groups(remotes ...fs.Info) []string {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sems) == 0 {
+		return nil
+	}
+	var groups []string
+	seen := map[string]struct{}{}
+	for _, f := range remotes {
+		if f == nil {
+			continue
+		}
+		for _, group := range remoteGroups(f.Name()) {
+			if _, found := seen[group]; !found {
+				seen[group] = struct{}{}
+				groups = append(groups, group)
+			}
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// reservedKey is the context key for the groups reserved already
+type reservedKey struct{}
+
+// reserved returns the groups reserved already for ctx
+func reserved(ctx context.Context) map[string]struct{} {
+	groups, _ := ctx.Value(reservedKey{}).(map[string]struct{})
+	return groups
+}
+
+// Reserve waits until there is a free slot for an operation in each
+// of the groups of remotes and reserves them.
+//
+// An operation between remotes in different groups holds a slot in
+// each of them, but only one slot in a group both remotes are in.
+//
+// It returns a context to run the operation with, which makes any
+// further calls to Reserve with it skip the groups reserved already
+// so operations made as part of this one, like the copy and delete of
+// a move, aren't counted twice, and a func to call to release the
+// slots once the operation is finished.
+//
+// The slots are acquired in the order of the group names so
+// operations waiting for each other's groups can't deadlock.
+func Reserve(ctx context.Context, remotes ...fs.Info) (context.Context, func(), error) {
+	held := reserved(ctx)
+	var todo []string
+	for _, group := range Groups(remotes...) {
+		if _, found := held[group]; !found {
+			todo = append(todo, group)
+		}
+	}
+	if len(todo) == 0 {
+		return ctx, func() {}, nil
+	}
+	mu.Lock()
+	toAcquire := make([]*semaphore.Weighted, len(todo))
+	for i, group := range todo {
+		toAcquire[i] = sems[group]
+	}
+	mu.Unlock()
+	var acquired []*semaphore.Weighted
+	release := func() {
+		for _, s := range acquired {
+			s.Release(1)
+		}
+		acquired = nil
+	}
+	for i, s := range toAcquire {
+		if !s.TryAcquire(1) {
+			fs.Debugf(nil, "Waiting for a free slot in concurrency group %q", todo[i])
+			err := s.Acquire(ctx, 1)
+			if err != nil {
+				release()
+				return ctx, nil, err
+			}
+		}
+		acquired = append(acquired, s)
+	}
+	newHeld := make(map[string]struct{}, len(held)+len(todo))
+	for group := range held {
+		newHeld[group] = struct{}{}
+	}
+	for _, group := range todo {
+		newHeld[group] = struct{}{}
+	}
+	var once sync.Once
+	return context.WithValue(ctx, reservedKey{}, newHeld), func() {
+		once.Do(release)
+	}, nil
+}
@@ -0,0 +1,190 @@
+package concurrencygroup
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tagRemotes sets the concurrency_group of the remotes for the test
+func tagRemotes(t *testing.T, groups map[string]string) {
+	oldConfigFileGet := fs.ConfigFileGet
+	fs.ConfigFileGet = func(section, key string) (string, bool) {
+		if key != ConfigKey {
+			return "", false
+		}
+		value, ok := groups[section]
+		return value, ok
+	}
+	t.Cleanup(func() {
+		fs.ConfigFileGet = oldConfigFileGet
+		SetLimits(nil)
+	})
+}
+
+var (
+	remoteA  = mockfs.NewFs("a1", "root")
+	remoteA2 = mockfs.NewFs("a2", "root")
+	remoteAB = mockfs.NewFs("ab", "root")
+	remoteB  = mockfs.NewFs("b1", "root")
+	remoteX  = mockfs.NewFs("none", "root")
+)
+
+func TestGroups(t *testing.T) {
+	tagRemotes(t, map[string]string{
+		"a1": "a",
+		"a2": "a, unlimited",
+		"ab": "b,a",
+		"b1": "b",
+	})
+	SetLimits(map[string]int{"a": 2, "b": 1, "unlimited": 0})
+
+	assert.Equal(t, []string{"a"}, Groups(remoteA))
+	assert.Equal(t, []string{"a"}, Groups(remoteA, remoteA2))
+	assert.Equal(t, []string{"a", "b"}, Groups(remoteAB))
+	assert.Equal(t, []string{"a", "b"}, Groups(remoteB, remoteA))
+	assert.Nil(t, Groups(remoteX))
+	assert.Nil(t, Groups(remoteX, nil))
+
+	// Nothing is limited without limits
+	SetLimits(nil)
+	assert.Nil(t, Groups(remoteAB))
+}
+
+func TestReserveUnlimited(t *testing.T) {
+	tagRemotes(t, map[string]string{"a1": "a"})
+	SetLimits(nil)
+	ctx := context.Background()
+	newCtx, release, err := Reserve(ctx, remoteA)
+	require.NoError(t, err)
+	assert.Equal(t, ctx, newCtx)
+	release()
+}
+
+func TestReserveLimited(t *testing.T) {
+	tagRemotes(t, map[string]string{
+		"a1": "a",
+		"ab": "a,b",
+		"b1": "b",
+	})
+	const maxA, maxB = 3, 2
+	SetLimits(map[string]int{"a": maxA, "b": maxB})
+
+	var (
+		wg           sync.WaitGroup
+		inA, inB     int32
+		peakA, peakB int32
+	)
+	enter := func(in, peak *int32) {
+		n := atomic.AddInt32(in, 1)
+		for {
+			old := atomic.LoadInt32(peak)
+			if n <= old || atomic.CompareAndSwapInt32(peak, old, n) {
+				break
+			}
+		}
+	}
+	// Operations within a, within b and between them at once
+	pairs := [][2]fs.Info{
+		{remoteA, remoteA},
+		{remoteB, remoteB},
+		{remoteA, remoteB},
+		{remoteB, remoteA},
+		{remoteAB, remoteA},
+	}
+	for i := 0; i < 20; i++ {
+		pair := pairs[i%len(pairs)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, release, err := Reserve(context.Background(), pair[0], pair[1])
+			require.NoError(t, err)
+			defer release()
+
+			// Operations made with the reserved ctx aren't counted again
+			_, nestedRelease, err := Reserve(ctx, pair[1])
+			require.NoError(t, err)
+			nestedRelease()
+
+			groups := Groups(pair[0], pair[1])
+			for _, group := range groups {
+				if group == "a" {
+					enter(&inA, &peakA)
+				} else {
+					enter(&inB, &peakB)
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			for _, group := range groups {
+				if group == "a" {
+					atomic.AddInt32(&inA, -1)
+				} else {
+					atomic.AddInt32(&inB, -1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.True(t, peakA <= maxA, "peak a %d", peakA)
+	assert.True(t, peakB <= maxB, "peak b %d", peakB)
+	assert.Equal(t, int32(0), inA)
+	assert.Equal(t, int32(0), inB)
+}
+
+func TestReserveNested(t *testing.T) {
+	tagRemotes(t, map[string]string{
+		"a1": "a",
+		"b1": "b",
+	})
+	SetLimits(map[string]int{"a": 1, "b": 1})
+
+	// Holding a, reserving b as well only waits for b
+	ctx, release, err := Reserve(context.Background(), remoteA)
+	require.NoError(t, err)
+	_, release2, err := Reserve(ctx, remoteA, remoteB)
+	require.NoError(t, err)
+
+	// Both groups are full now
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err = Reserve(timeoutCtx, remoteB)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// Releasing the nested reservation only frees b
+	release2()
+	release2() // releasing twice is harmless
+	_, release3, err := Reserve(context.Background(), remoteB)
+	require.NoError(t, err)
+	release3()
+	_, _, err = Reserve(timeoutCtx, remoteA)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	release()
+}
+
+func TestReserveCancelled(t *testing.T) {
+	tagRemotes(t, map[string]string{
+		"a1": "a",
+		"b1": "b",
+	})
+	SetLimits(map[string]int{"a": 1, "b": 1})
+
+	_, release, err := Reserve(context.Background(), remoteB)
+	require.NoError(t, err)
+	defer release()
+
+	// Waiting for b fails, so a is given back
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err = Reserve(ctx, remoteA, remoteB)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	_, releaseA, err := Reserve(context.Background(), remoteA)
+	require.NoError(t, err)
+	releaseA()
+}
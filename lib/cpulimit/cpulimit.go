@@ -0,0 +1,47 @@
+// Package cpulimit limits the number of CPU intensive operations,
+// such as hashing and encryption, which run at once.
+//
+// This is separate from the limits on the number of transfers so
+// that rclone doesn't starve other processes of CPU on constrained
+// hardware while still keeping the IO busy.
+package cpulimit
+
+import (
+	"sync"
+
+	"github.com/rclone/rclone/lib/pacer"
+)
+
+var (
+	mu     sync.RWMutex          // protects tokens
+	tokens *pacer.TokenDispenser // nil for unlimited
+)
+
+// SetMax sets the maximum number of CPU intensive operations which
+// can run at once. Setting it to 0 or less means unlimited.
+//
+// This should be called before any operations are started.
+func SetMax(n int) {
+	mu.Lock()
+	defer mu.Unlock()
+	if n <= 0 {
+		tokens = nil
+	} else {
+		tokens = pacer.NewTokenDispenser(n)
+	}
+}
+
+// Do calls fn, first waiting for one of the other CPU intensive
+// operations to finish if the maximum are already running.
+func Do(fn func()) {
+	mu.RLock()
+	td := tokens
+	mu.RUnlock()
+	if td == nil {
+		fn()
+		return
+	}
+	td.Get()
+	defer td.Put()
+	fn()
+}
@@ -0,0 +1,49 @@
+package cpulimit
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoUnlimited(t *testing.T) {
+	SetMax(0)
+	called := false
+	Do(func() { called = true })
+	assert.True(t, called)
+}
+
+func TestDoLimited(t *testing.T) {
+	const max = 2
+	SetMax(max)
+	defer SetMax(0)
+
+	var (
+		wg      sync.WaitGroup
+		running int32
+		peak    int32
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Do(func() {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					old := atomic.LoadInt32(&peak)
+					if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+			})
+		}()
+	}
+	wg.Wait()
+	assert.True(t, peak <= max, "peak %d", peak)
+	assert.Equal(t, int32(0), running)
+}
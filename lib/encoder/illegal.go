@@ -0,0 +1,112 @@
+package encoder
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// probe is a character which no encoding changes, used to see how a
+// character is encoded at the start, middle and end of a name
+const probe = "a"
+
+// illegalAt returns true if mask would replace r when converting name
+// from Standard encoding, where r is at the start and/or end of name
+// as given. Characters which are only quoted aren't illegal as they
+// are restored unchanged.
+func (mask MultiEncoder) illegalAt(r rune, start, end bool) bool {
+	c := string(r)
+	if r == QuoteRune {
+		return false
+	}
+	var in string
+	switch {
+	case start && end:
+		in = c
+	case start:
+		in = c + probe
+	case end:
+		in = probe + c
+	default:
+		in = probe + c + probe
+	}
+	out := FromStandardName(mask, in)
+	return out != in && out != strings.Replace(in, c, string(QuoteRune)+c, 1)
+}
+
+// replaceIllegalOnce does a single pass of ReplaceIllegal
+func (mask MultiEncoder) replaceIllegalOnce(in string, replace func(r rune) string) string {
+	var out strings.Builder
+	changed := false
+	for i, r := range in {
+		size := utf8.RuneLen(r)
+		if r == utf8.RuneError {
+			_, size = utf8.DecodeRuneInString(in[i:])
+		}
+		illegal := false
+		if r == utf8.RuneError && size == 1 {
+			illegal = mask.Has(EncodeInvalidUtf8)
+		} else {
+			illegal = mask.illegalAt(r, i == 0, i+size == len(in))
+		}
+		if illegal {
+			out.WriteString(replace(r))
+			changed = true
+		} else {
+			out.WriteString(in[i : i+size])
+		}
+	}
+	if !changed {
+		return in
+	}
+	return out.String()
+}
+
+// ReplaceIllegal returns the name in, which is in Standard encoding,
+// with each character mask would have to replace to store it
+// replaced with the result of calling replace on it.  Invalid UTF-8
+// bytes are passed to replace as utf8.RuneError.
+//
+// Characters which become illegal as others are replaced, such as a
+// second leading space when the first is removed, are replaced too.
+// The names "." and ".." are left for the encoding to deal with.
+func (mask MultiEncoder) ReplaceIllegal(in string, replace func(r rune) string) string {
+	if in == "." || in == ".." {
+		return in
+	}
+	for i := 0; i <= len(in); i++ {
+		out := mask.replaceIllegalOnce(in, replace)
+		if out == in {
+			break
+		}
+		in = out
+	}
+	return in
+}
+
+// ReplaceIllegalPath calls ReplaceIllegal on each part of the /
+// separated path in.
+func (mask MultiEncoder) ReplaceIllegalPath(in string, replace func(r rune) string) string {
+	parts := strings.Split(in, "/")
+	changed := false
+	for i, p := range parts {
+		out := mask.ReplaceIllegal(p, replace)
+		changed = changed || out != p
+		parts[i] = out
+	}
+	if !changed {
+		return in
+	}
+	return strings.Join(parts, "/")
+}
+
+// HasIllegal returns true if mask would have to replace any of the
+// characters in the / separated path in, which is in Standard
+// encoding, to store it.
+func (mask MultiEncoder) HasIllegal(in string) bool {
+	found := false
+	mask.ReplaceIllegalPath(in, func(r rune) string {
+		found = true
+		return ""
+	})
+	return found
+}
@@ -0,0 +1,45 @@
+package encoder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplaceIllegal(t *testing.T) {
+	strip := func(r rune) string { return "" }
+	underscore := func(r rune) string { return "_" }
+	for _, test := range []struct {
+		mask    MultiEncoder
+		in      string
+		replace func(r rune) string
+		want    string
+	}{
+		{EncodeWin, "hello", strip, "hello"},
+		{EncodeWin, "a:b*c", strip, "abc"},
+		{EncodeWin, "a:b*c", underscore, "a_b_c"},
+		{EncodeWin, "a：b", strip, "a：b"}, // only quoted
+		{EncodeWin, "‛", strip, "‛"},
+		{EncodeColon, "a:b*c", underscore, "a_b*c"},
+		{EncodeLeftSpace, "  x ", strip, "x "},
+		{EncodeRightSpace | EncodeRightPeriod, "x. .", strip, "x"},
+		{EncodeRightPeriod, "x.y", strip, "x.y"},
+		{EncodeInvalidUtf8, "a\xffb", underscore, "a_b"},
+		{EncodeDot, ".", strip, "."},
+		{EncodeDot, "..", strip, ".."},
+		{EncodeZero, "a:b", strip, "a:b"},
+	} {
+		got := test.mask.ReplaceIllegal(test.in, test.replace)
+		assert.Equal(t, test.want, got, "%v %q", test.mask, test.in)
+	}
+}
+
+func TestReplaceIllegalPath(t *testing.T) {
+	mask := EncodeWin | EncodeRightSpace
+	strip := func(r rune) string { return "" }
+	assert.Equal(t, "dir/file", mask.ReplaceIllegalPath("dir/file", strip))
+	assert.Equal(t, "dir/file", mask.ReplaceIllegalPath("dir:/fi?le ", strip))
+	assert.True(t, mask.HasIllegal("dir/a|b"))
+	assert.True(t, mask.HasIllegal("dir /ab"))
+	assert.False(t, mask.HasIllegal("dir/ab"))
+}
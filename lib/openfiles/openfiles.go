@@ -0,0 +1,104 @@
+// Package openfiles limits the number of file handles, such as the
+// source and destination of a transfer, which are open at once.
+//
+// This is separate from the limits on the number of transfers and
+// checkers so rclone can run on systems with a low limit on the
+// number of open files without failing with "too many open files".
+package openfiles
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"golang.org/x/sync/semaphore"
+)
+
+// reportInterval is the minimum time between the reports of the
+// waits for a free file handle
+const reportInterval = time.Minute
+
+var (
+	mu         sync.Mutex          // protects the vars below
+	max        int64               // the maximum or 0 for unlimited
+	sem        *semaphore.Weighted // nil for unlimited
+	waits      int                 // number of waits since the last report
+	waited     time.Duration       // time waited since the last report
+	lastReport time.Time           // when the waits were last reported
+)
+
+// SetMax sets the maximum number of file handles which can be open at
+// once. Setting it to 0 or less means unlimited.
+//
+// This should be called before any files are opened.
+func SetMax(n int) {
+	mu.Lock()
+	defer mu.Unlock()
+	if n <= 0 {
+		max, sem = 0, nil
+	} else {
+		max, sem = int64(n), semaphore.NewWeighted(int64(n))
+	}
+	waits, waited, lastReport = 0, 0, time.Time{}
+}
+
+// reservedKey is the context key marking the file handles as reserved
+type reservedKey struct{}
+
+// Reserved returns true if the file handles for ctx have been reserved
+// already with Reserve
+func Reserved(ctx context.Context) bool {
+	return ctx.Value(reservedKey{}) != nil
+}
+
+// Reserve waits until n file handles are free and reserves them.
+//
+// It returns a context to open the files with, which makes any
+// further calls to Reserve with it do nothing so the files opened
+// during a transfer which reserved them aren't counted twice, and a
+// func to call to release the handles once the files are closed.
+//
+// The handles are reserved all at once so transfers waiting for their
+// destination can't hold on to all the handles for their sources.
+func Reserve(ctx context.Context, n int) (context.Context, func(), error) {
+	mu.Lock()
+	s, limit := sem, max
+	mu.Unlock()
+	if s == nil || Reserved(ctx) {
+		return ctx, func() {}, nil
+	}
+	weight := int64(n)
+	if weight > limit {
+		weight = limit
+	}
+	if !s.TryAcquire(weight) {
+		start := time.Now()
+		err := s.Acquire(ctx, weight)
+		if err != nil {
+			return ctx, nil, err
+		}
+		wait(time.Since(start))
+	}
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			s.Release(weight)
+		})
+	}
+	return context.WithValue(ctx, reservedKey{}, struct{}{}), release, nil
+}
+
+// wait records a wait of d for a free file handle, reporting the
+// waits if it is time to
+func wait(d time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	waits++
+	waited += d
+	if time.Since(lastReport) < reportInterval {
+		return
+	}
+	fs.Logf(nil, "Waited %d times for %v in total for a free file handle - --max-open-files %d is limiting the transfers", waits, waited.Truncate(time.Millisecond), max)
+	waits, waited, lastReport = 0, 0, time.Now()
+}
@@ -0,0 +1,85 @@
+package openfiles
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReserveUnlimited(t *testing.T) {
+	SetMax(0)
+	ctx, release, err := Reserve(context.Background(), 2)
+	require.NoError(t, err)
+	assert.False(t, Reserved(ctx))
+	release()
+}
+
+func TestReserveLimited(t *testing.T) {
+	const max = 4
+	SetMax(max)
+	defer SetMax(0)
+
+	var (
+		wg   sync.WaitGroup
+		open int32
+		peak int32
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, release, err := Reserve(context.Background(), 2)
+			require.NoError(t, err)
+			defer release()
+			assert.True(t, Reserved(ctx))
+
+			// Files opened with the reserved ctx aren't counted again
+			_, nestedRelease, err := Reserve(ctx, 1)
+			require.NoError(t, err)
+			nestedRelease()
+
+			n := atomic.AddInt32(&open, 2)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&open, -2)
+		}()
+	}
+	wg.Wait()
+	assert.True(t, peak <= max, "peak %d", peak)
+	assert.Equal(t, int32(0), open)
+}
+
+func TestReserveMoreThanMax(t *testing.T) {
+	SetMax(1)
+	defer SetMax(0)
+
+	// This would wait forever if it tried to reserve 2
+	_, release, err := Reserve(context.Background(), 2)
+	require.NoError(t, err)
+	release()
+	release() // releasing twice is harmless
+}
+
+func TestReserveCancelled(t *testing.T) {
+	SetMax(1)
+	defer SetMax(0)
+
+	_, release, err := Reserve(context.Background(), 1)
+	require.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err = Reserve(ctx, 1)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,91 @@
+// Sharing chunk uploads between multipart uploads
+
+package pacer
+
+import "sync"
+
+// ChunkScheduler shares a limited number of slots for uploading
+// chunks between the multipart uploads in progress.
+//
+// A free slot is given to the waiting upload with the fewest chunks
+// in flight, so the chunks of the uploads are interleaved rather than
+// one upload holding all the slots.
+type ChunkScheduler struct {
+	mu      sync.Mutex
+	free    int            // number of slots not in use
+	waiting []*ChunkUpload // uploads waiting for a slot
+}
+
+// NewChunkScheduler makes a ChunkScheduler uploading up to n chunks
+// at once.
+func NewChunkScheduler(n int) *ChunkScheduler {
+	return &ChunkScheduler{
+		free: n,
+	}
+}
+
+// ChunkUpload controls the concurrency of the chunks of a single
+// multipart upload
+type ChunkUpload struct {
+	s        *ChunkScheduler // shared scheduler or nil
+	tokens   *TokenDispenser // limits the chunks of this upload
+	inFlight int             // chunks uploading - protected by s.mu
+	ready    chan struct{}   // signalled when given a slot
+}
+
+// NewUpload starts a multipart upload which uploads up to
+// concurrency chunks at once.
+//
+// If s is nil then the upload is only limited by concurrency.
+func (s *ChunkScheduler) NewUpload(concurrency int) *ChunkUpload {
+	return &ChunkUpload{
+		s:      s,
+		tokens: NewTokenDispenser(concurrency),
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// Get waits until the upload may upload another chunk - don't forget
+// to call Put when it has been uploaded
+func (u *ChunkUpload) Get() {
+	u.tokens.Get()
+	s := u.s
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.free > 0 && len(s.waiting) == 0 {
+		s.free--
+		u.inFlight++
+		s.mu.Unlock()
+		return
+	}
+	s.waiting = append(s.waiting, u)
+	s.mu.Unlock()
+	<-u.ready
+}
+
+// Put marks a chunk got with Get as uploaded
+func (u *ChunkUpload) Put() {
+	s := u.s
+	if s != nil {
+		s.mu.Lock()
+		u.inFlight--
+		if len(s.waiting) > 0 {
+			best := 0
+			for i, waiter := range s.waiting {
+				if waiter.inFlight < s.waiting[best].inFlight {
+					best = i
+				}
+			}
+			next := s.waiting[best]
+			s.waiting = append(s.waiting[:best], s.waiting[best+1:]...)
+			next.inFlight++
+			next.ready <- struct{}{}
+		} else {
+			s.free++
+		}
+		s.mu.Unlock()
+	}
+	u.tokens.Put()
+}
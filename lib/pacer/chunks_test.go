@@ -0,0 +1,83 @@
+package pacer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkUploadNoScheduler(t *testing.T) {
+	var s *ChunkScheduler
+	u := s.NewUpload(2)
+	u.Get()
+	u.Get()
+	assert.Equal(t, 0, len(u.tokens.tokens))
+	u.Put()
+	u.Put()
+	assert.Equal(t, 2, len(u.tokens.tokens))
+}
+
+func TestChunkScheduler(t *testing.T) {
+	s := NewChunkScheduler(3)
+	a := s.NewUpload(3)
+	b := s.NewUpload(3)
+	c := s.NewUpload(1)
+
+	// a takes all the slots
+	a.Get()
+	a.Get()
+	a.Get()
+	assert.Equal(t, 0, s.free)
+
+	got := make(chan *ChunkUpload, 3)
+	wait := func(u *ChunkUpload) {
+		u.Get()
+		got <- u
+	}
+	go wait(b)
+	go wait(c)
+	for {
+		s.mu.Lock()
+		n := len(s.waiting)
+		s.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The slots go to the uploads with the fewest chunks in flight
+	a.Put()
+	first := <-got
+	a.Put()
+	second := <-got
+	assert.ElementsMatch(t, []*ChunkUpload{b, c}, []*ChunkUpload{first, second})
+	assert.Equal(t, 1, a.inFlight)
+	assert.Equal(t, 1, b.inFlight)
+	assert.Equal(t, 1, c.inFlight)
+
+	// c is limited to 1 chunk so b gets the next slot
+	go wait(c)
+	go wait(b)
+	for {
+		s.mu.Lock()
+		n := len(s.waiting)
+		s.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	a.Put()
+	assert.Equal(t, b, <-got)
+
+	// Returning all the chunks frees the slots
+	c.Put()
+	assert.Equal(t, c, <-got)
+	b.Put()
+	b.Put()
+	c.Put()
+	assert.Equal(t, 3, s.free)
+	assert.Equal(t, 0, len(s.waiting))
+}
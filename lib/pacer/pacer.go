@@ -37,11 +37,16 @@ type pacerOptions struct {
 	retries        int         // Max number of retries
 	calculator     Calculator  // switchable pacing algorithm - call with mu held
 	invoker        InvokerFunc // wrapper function used to invoke the target function
+
+	retryDuration time.Duration // Max time to keep retrying for, 0 for no limit
 }
 
 // InvokerFunc is the signature of the wrapper function used to invoke the
 // target function in Pacer.
-type InvokerFunc func(try, tries int, f Paced) (bool, error)
+//
+// deadline is the time retries stop if a retry duration is set, or
+// the zero time if not.
+type InvokerFunc func(try, tries int, deadline time.Time, f Paced) (bool, error)
 
 // Option can be used in New to configure the Pacer.
 type Option func(*pacerOptions)
@@ -56,6 +61,13 @@ func RetriesOption(retries int) Option {
 	return func(p *pacerOptions) { p.retries = retries }
 }
 
+// RetryDurationOption sets the maximum time to keep retrying a call
+// for in the new Pacer.  Retries stop when either this or the retries
+// number is used up.
+func RetryDurationOption(retryDuration time.Duration) Option {
+	return func(p *pacerOptions) { p.retryDuration = retryDuration }
+}
+
 // MaxConnectionsOption sets the maximum connections number for the new Pacer.
 func MaxConnectionsOption(maxConnections int) Option {
 	return func(p *pacerOptions) { p.maxConnections = maxConnections }
@@ -125,6 +137,13 @@ func (p *Pacer) SetRetries(retries int) {
 	p.retries = retries
 }
 
+// SetRetryDuration sets the max time to keep retrying for in Call
+func (p *Pacer) SetRetryDuration(retryDuration time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.retryDuration = retryDuration
+}
+
 // SetCalculator sets the pacing algorithm. Don't modify the Calculator object
 // afterwards, use the ModifyCalculator method when needed.
 //
@@ -190,15 +209,27 @@ func (p *Pacer) endCall(retry bool, err error) {
 }
 
 // call implements Call but with settable retries
-func (p *Pacer) call(fn Paced, retries int) (err error) {
-	var retry bool
+//
+// If retryDuration is set then it stops retrying once that has
+// elapsed even if there are retries left.
+func (p *Pacer) call(fn Paced, retries int, retryDuration time.Duration) (err error) {
+	var (
+		retry    bool
+		deadline time.Time
+	)
+	if retryDuration > 0 {
+		deadline = time.Now().Add(retryDuration)
+	}
 	for i := 1; i <= retries; i++ {
 		p.beginCall()
-		retry, err = p.invoker(i, retries, fn)
+		retry, err = p.invoker(i, retries, deadline, fn)
 		p.endCall(retry, err)
 		if !retry {
 			break
 		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			break
+		}
 	}
 	return err
 }
@@ -211,9 +242,9 @@ func (p *Pacer) call(fn Paced, retries int) (err error) {
 // number of retries is exceeded.
 func (p *Pacer) Call(fn Paced) (err error) {
 	p.mu.Lock()
-	retries := p.retries
+	retries, retryDuration := p.retries, p.retryDuration
 	p.mu.Unlock()
-	return p.call(fn, retries)
+	return p.call(fn, retries, retryDuration)
 }
 
 // CallNoRetry paces the remote operations to not exceed the limits
@@ -222,10 +253,10 @@ func (p *Pacer) Call(fn Paced) (err error) {
 // This calls fn and wraps the output in a RetryError if it would like
 // it to be retried
 func (p *Pacer) CallNoRetry(fn Paced) error {
-	return p.call(fn, 1)
+	return p.call(fn, 1, 0)
 }
 
-func invoke(try, tries int, f Paced) (bool, error) {
+func invoke(try, tries int, deadline time.Time, f Paced) (bool, error) {
 	return f()
 }
 
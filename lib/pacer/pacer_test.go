@@ -7,6 +7,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNew(t *testing.T) {
@@ -308,7 +309,7 @@ func TestCallFixed(t *testing.T) {
 	p := New(CalculatorOption(NewDefault(MinSleep(1*time.Millisecond), MaxSleep(2*time.Millisecond))))
 
 	dp := &dummyPaced{retry: false}
-	err := p.call(dp.fn, 10)
+	err := p.call(dp.fn, 10, 0)
 	assert.Equal(t, 1, dp.called)
 	assert.Equal(t, errFoo, err)
 }
@@ -317,11 +318,36 @@ func Test_callRetry(t *testing.T) {
 	p := New(CalculatorOption(NewDefault(MinSleep(1*time.Millisecond), MaxSleep(2*time.Millisecond))))
 
 	dp := &dummyPaced{retry: true}
-	err := p.call(dp.fn, 10)
+	err := p.call(dp.fn, 10, 0)
 	assert.Equal(t, 10, dp.called)
 	assert.Equal(t, errFoo, err)
 }
 
+func Test_callRetryDuration(t *testing.T) {
+	p := New(CalculatorOption(NewDefault(MinSleep(10*time.Millisecond), MaxSleep(10*time.Millisecond))))
+	var deadlines []time.Time
+	p.invoker = func(try, tries int, deadline time.Time, f Paced) (bool, error) {
+		deadlines = append(deadlines, deadline)
+		return f()
+	}
+
+	// Stops when the duration is used up before the retries
+	dp := &dummyPaced{retry: true}
+	start := time.Now()
+	err := p.call(dp.fn, 1000, 50*time.Millisecond)
+	assert.Equal(t, errFoo, err)
+	assert.True(t, dp.called > 1 && dp.called < 1000, dp.called)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	require.Len(t, deadlines, dp.called)
+	assert.False(t, deadlines[0].IsZero())
+
+	// Stops when the retries are used up before the duration
+	dp = &dummyPaced{retry: true}
+	err = p.call(dp.fn, 3, time.Hour)
+	assert.Equal(t, errFoo, err)
+	assert.Equal(t, 3, dp.called)
+}
+
 func TestCall(t *testing.T) {
 	p := New(RetriesOption(20), CalculatorOption(NewDefault(MinSleep(1*time.Millisecond), MaxSleep(2*time.Millisecond))))
 
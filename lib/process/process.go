@@ -0,0 +1,2 @@
+// Package process finds out whether programs are running
+package process
@@ -0,0 +1,59 @@
+//+build linux
+
+package process
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxCommLen is the longest name the kernel keeps in /proc/PID/comm
+const maxCommLen = 15
+
+// Running returns true if a process called name is running
+//
+// This reads the process names from /proc.
+func Running(name string) (bool, error) {
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return false, errors.Wrap(err, "failed to list processes")
+	}
+	commName := name
+	if len(commName) > maxCommLen {
+		commName = commName[:maxCommLen]
+	}
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		dir := filepath.Join("/proc", entry.Name())
+		comm, err := ioutil.ReadFile(filepath.Join(dir, "comm"))
+		if err != nil {
+			// The process has probably exited
+			continue
+		}
+		if strings.TrimSpace(string(comm)) != commName {
+			continue
+		}
+		if len(name) <= maxCommLen {
+			return true, nil
+		}
+		// The name was truncated so check the program run
+		cmdline, err := ioutil.ReadFile(filepath.Join(dir, "cmdline"))
+		if err != nil {
+			continue
+		}
+		if i := bytes.IndexByte(cmdline, 0); i >= 0 {
+			cmdline = cmdline[:i]
+		}
+		if filepath.Base(string(cmdline)) == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
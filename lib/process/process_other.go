@@ -0,0 +1,24 @@
+//+build !linux,!windows
+
+package process
+
+import (
+	"os/exec"
+
+	"github.com/pkg/errors"
+)
+
+// Running returns true if a process called name is running
+//
+// This uses pgrep which is available on macOS and the BSDs.
+func Running(name string) (bool, error) {
+	err := exec.Command("pgrep", "-x", name).Run()
+	if err == nil {
+		return true, nil
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		// pgrep exits with 1 if there are no matches
+		return false, nil
+	}
+	return false, errors.Wrap(err, "failed to run pgrep")
+}
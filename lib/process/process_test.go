@@ -0,0 +1,53 @@
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// copySleep copies the sleep program to dst
+func copySleep(t *testing.T, dst string) {
+	src, err := exec.LookPath("sleep")
+	require.NoError(t, err)
+	data, err := ioutil.ReadFile(src)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(dst, data, 0755))
+}
+
+func TestRunning(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sleep")
+	}
+	running, err := Running("rclone-no-such-process")
+	require.NoError(t, err)
+	assert.False(t, running)
+
+	// Run sleep under a name nothing else will be using
+	dir, err := ioutil.TempDir("", "rclone-process-test")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	sleep := filepath.Join(dir, "rclonetestsleep")
+	copySleep(t, sleep)
+	running, err = Running("rclonetestsleep")
+	require.NoError(t, err)
+	assert.False(t, running)
+
+	cmd := exec.Command(sleep, "30")
+	require.NoError(t, cmd.Start())
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	running, err = Running("rclonetestsleep")
+	require.NoError(t, err)
+	assert.True(t, running)
+}
@@ -0,0 +1,41 @@
+//+build windows
+
+package process
+
+import (
+	"encoding/csv"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// trimExe removes a trailing .exe from name
+func trimExe(name string) string {
+	if strings.HasSuffix(strings.ToLower(name), ".exe") {
+		return name[:len(name)-4]
+	}
+	return name
+}
+
+// Running returns true if a process called name is running
+//
+// This looks for name with or without a .exe suffix in the output of
+// tasklist.
+func Running(name string) (bool, error) {
+	out, err := exec.Command("tasklist", "/NH", "/FO", "CSV").Output()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to list processes")
+	}
+	records, err := csv.NewReader(strings.NewReader(string(out))).ReadAll()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to parse process list")
+	}
+	name = strings.ToLower(trimExe(name))
+	for _, record := range records {
+		if len(record) > 0 && strings.ToLower(trimExe(record[0])) == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}